@@ -0,0 +1,145 @@
+package gotcl
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// autoIndexHeader is the first line of a tclIndex file.
+const autoIndexHeader = "# Tcl autoload index file, version 2.0"
+
+// unknown cmdName ?arg ...?
+//
+// The unknown command is invoked with the words of any command that
+// does not exist. This default implementation attempts to autoload
+// cmdName from the libraries in auto_path and, if that succeeds,
+// invokes it with the args; otherwise it returns an invalid command
+// name error. It may be replaced by a procedure to handle unknown
+// commands differently.
+func cmdUnknown(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "cmdName ?arg ...?")
+	}
+	loaded, err := interp.autoLoad(args[1].String())
+	if err != nil {
+		return nil, err
+	}
+	if !loaded {
+		return nil, fmt.Errorf("invalid command name \"%s\"", args[1])
+	}
+	return interp.invoke(args[1:])
+}
+
+// auto_load cmd
+//
+// This command attempts to load the definition for a Tcl command
+// named cmd. To do this, it searches an auto-load path, which is a
+// list of one or more directories given by the global variable
+// auto_path. Each directory in the auto-load path must contain a
+// file tclIndex that describes one or more commands defined in that
+// directory and a script to evaluate to load each of the commands.
+// If cmd was successfully loaded then auto_load returns 1. If cmd
+// was not found, the return value is 0.
+//
+// The tclIndex files are evaluated with the variable dir set to their
+// directory, and set elements of the global auto_index array to the
+// scripts that load the commands. They are read again only when
+// auto_path changes.
+func cmdAutoLoad(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, wrongNumArgs(args, 1, "cmd ?namespace?")
+	}
+	loaded, err := interp.autoLoad(args[1].String())
+	if err != nil {
+		return nil, err
+	}
+	return NewBoolValue(loaded), nil
+}
+
+// autoLoad evaluates the script of the global auto_index array that
+// loads the command name, and reports whether the command exists
+// afterwards. Safe interpreters do not autoload.
+func (interp *Interp) autoLoad(name string) (bool, error) {
+	if interp.safe {
+		return false, nil
+	}
+	if err := interp.loadAutoIndex(); err != nil {
+		return false, err
+	}
+	name = commandName(name)
+	script, err := interp.getVar2("::auto_index", name, true)
+	if err != nil {
+		return false, nil
+	}
+	if _, err := interp.evalGlobal(script); err != nil {
+		return false, err
+	}
+	return interp.CommandExists(name), nil
+}
+
+// loadAutoIndex evaluates the tclIndex files of the directories in
+// auto_path, unless they have been loaded for its current value.
+// Earlier directories take precedence, so the files are evaluated
+// last to first.
+func (interp *Interp) loadAutoIndex() error {
+	path, err := interp.getVar("::auto_path")
+	if err != nil {
+		return nil
+	}
+	if interp.autoIndexed && interp.autoPath == path.String() {
+		return nil
+	}
+	dirs, err := path.List()
+	if err != nil {
+		return err
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := interp.loadIndexFile(dirs[i].String()); err != nil {
+			return err
+		}
+	}
+	interp.autoPath, interp.autoIndexed = path.String(), true
+	return nil
+}
+
+// loadIndexFile evaluates the tclIndex file of dir, if there is one,
+// in a frame of its own where dir is set and auto_index refers to the
+// global array.
+func (interp *Interp) loadIndexFile(dir string) error {
+	file := filepath.Join(dir, "tclIndex")
+	data, err := interp.readScriptFile(file)
+	if err != nil {
+		return nil
+	}
+	script := string(data)
+	if line, _, _ := strings.Cut(script, "\n"); strings.TrimSpace(line) != autoIndexHeader {
+		return fmt.Errorf("%s isn't a proper Tcl index file", file)
+	}
+	return interp.evalIndexFile(file, []rune(script), "auto_index")
+}
+
+// evalIndexFile evaluates script, the contents of the index file,
+// in a frame of its own where dir is set to the directory of file and
+// the global variables globals are linked in.
+func (interp *Interp) evalIndexFile(file string, script []rune, globals ...string) error {
+	frame := &callFrame{
+		vars:   map[string]*variable{"dir": {value: NewStringValue(filepath.Dir(file))}},
+		level:  interp.frame.level + 1,
+		caller: interp.frame,
+	}
+	for _, name := range globals {
+		vr, err := interp.frameVar(interp.globalFrame, name)
+		if err != nil {
+			return err
+		}
+		vr.linked = true
+		frame.vars[name] = vr
+		frame.link(name)
+	}
+	saved := interp.frame
+	interp.frame = frame
+	defer func() { interp.frame = saved }()
+	_, err := interp.evalScriptFrom(script, &scriptSource{typ: "source", file: file})
+	return err
+}
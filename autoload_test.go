@@ -0,0 +1,41 @@
+package gotcl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAutoLoad(t *testing.T) {
+	lib1, lib2 := t.TempDir(), t.TempDir()
+	index := autoIndexHeader + `
+set auto_index(greet) [list proc greet {who} "list [list $dir] \$who"]
+set auto_index(broken) {list not defined}
+`
+	if err := os.WriteFile(filepath.Join(lib1, "tclIndex"), []byte(index), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(lib2, "tclIndex"), []byte(autoIndexHeader+"\nset auto_index(greet) {proc greet {who} {list shadowed}}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bad := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bad, "tclIndex"), []byte("set x 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `greet bob`, err: `invalid command name "greet"`},
+		{script: `set auto_path [list ` + lib1 + ` ` + lib2 + `]; info procs`, result: ""},
+		{script: `greet bob`, result: lib1 + " bob"},
+		{script: `info procs`, result: "greet"},
+		{script: `auto_load broken`, result: "0"},
+		{script: `broken`, err: `invalid command name "broken"`},
+		{script: `auto_load greet`, result: "1"},
+		{script: `set auto_path [list ` + bad + `]; auto_load x`, err: filepath.Join(bad, "tclIndex") + ` isn't a proper Tcl index file`},
+	})
+	safe := NewSafeInterp()
+	runEvalTests(t, safe, []evalTest{
+		{script: `set auto_path [list ` + lib1 + `]; greet bob`, err: `invalid command name "greet"`},
+		{script: `auto_load greet`, err: `invalid command name "auto_load"`},
+	})
+}
@@ -0,0 +1,46 @@
+package gotcl
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// A bytesRep is the internal representation of a byte array Value,
+// such as binary format produces and binary scan consumes. Its
+// string representation has one character for each byte, with the
+// same code; the bytes themselves are kept, so that they need not be
+// recovered from the UTF-8 of that string.
+type bytesRep []byte
+
+func (b bytesRep) String() string {
+	s := make([]byte, 0, len(b))
+	for _, c := range b {
+		s = utf8.AppendRune(s, rune(c))
+	}
+	return string(s)
+}
+
+// NewBytesValue returns a byte array Value holding b, which the
+// caller must not modify afterwards.
+func NewBytesValue(b []byte) *Value {
+	return newRepValue(bytesRep(b))
+}
+
+// Bytes returns v as a byte array. Each character of the string
+// representation of v is one byte, so characters beyond U+00FF are
+// an error. The returned slice must not be modified.
+func (v *Value) Bytes() ([]byte, error) {
+	if b, ok := v.rep.(bytesRep); ok {
+		return b, nil
+	}
+	runes := v.Runes()
+	b := make([]byte, len(runes))
+	for i, r := range runes {
+		if r > 0xff {
+			return nil, fmt.Errorf("expected byte sequence but character %d was '%c' (U+%06X)", i, r, r)
+		}
+		b[i] = byte(r)
+	}
+	v.setRep(bytesRep(b))
+	return b, nil
+}
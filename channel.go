@@ -0,0 +1,773 @@
+package gotcl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"unicode/utf8"
+)
+
+// A channel is a Tcl channel. A channel may be registered in several
+// interpreters at once and is closed when the last of them
+// unregisters it.
+//
+// Input is buffered as raw bytes and decoded, translated and
+// checked for the end-of-file character as it is consumed, so that
+// the position of the channel is always known. Output is translated
+// and encoded as it is written and buffered according to the
+// -buffering option.
+type channel struct {
+	name string
+	refs int
+	// r and w read from and write to the underlying stream; either
+	// is nil if the channel was not opened in that direction.
+	// seeker is nil if the stream cannot seek. close closes the
+	// stream.
+	r      io.Reader
+	w      io.Writer
+	seeker io.Seeker
+	close  func() error
+	// closeWrite, if set, closes the write side of the stream when
+	// it is half-closed. pids holds the process ids of a command
+	// pipeline.
+	closeWrite func() error
+	pids       []*Value
+
+	// The configuration set by fconfigure.
+	inTranslation  string
+	outTranslation string
+	encoding       string
+	buffering      string
+	bufferSize     int
+	blocking       bool
+	eofChar        byte
+
+	in  []byte
+	out []byte
+	// pending receives the result of a read started by readAhead,
+	// and readErr holds a read error yet to be reported. notify,
+	// guarded by mu, is called when the pending read completes.
+	pending chan readResult
+	readErr error
+	mu      sync.Mutex
+	notify  func()
+	// atEOF is set once the stream has no more input, and eofHit
+	// once the end-of-file character has been read, after which no
+	// more input is read from the stream until the channel seeks.
+	atEOF  bool
+	eofHit bool
+	// eof is set when the last input operation ran out of input,
+	// and blocked when it stopped short because a nonblocking
+	// channel had no more input available.
+	eof     bool
+	blocked bool
+}
+
+// newChannel returns a channel with the default configuration.
+func newChannel(name string, r io.Reader, w io.Writer, seeker io.Seeker, close func() error) *channel {
+	return &channel{
+		name:           name,
+		r:              r,
+		w:              w,
+		seeker:         seeker,
+		close:          close,
+		inTranslation:  "auto",
+		outTranslation: "lf",
+		encoding:       "utf-8",
+		buffering:      "full",
+		bufferSize:     4096,
+		blocking:       true,
+	}
+}
+
+func (interp *Interp) registerChannel(ch *channel) {
+	if _, ok := interp.channels[ch.name]; ok {
+		return
+	}
+	interp.channels[ch.name] = ch
+	ch.refs++
+}
+
+func (interp *Interp) unregisterChannel(ch *channel) error {
+	if interp.channels[ch.name] != ch {
+		return nil
+	}
+	delete(interp.channels, ch.name)
+	interp.removeFileHandlers(ch)
+	ch.refs--
+	if ch.refs > 0 {
+		return nil
+	}
+	return ch.shutdown()
+}
+
+func (interp *Interp) lookupChannel(name string) (*channel, error) {
+	ch, ok := interp.channels[name]
+	if !ok {
+		return nil, fmt.Errorf("can not find channel named \"%s\"", name)
+	}
+	return ch, nil
+}
+
+// fileChannelName returns a name for a channel to the file f that is
+// not in use in interp: file followed by the descriptor of f if it
+// has one, or by the next unused number.
+func (interp *Interp) fileChannelName(f File) string {
+	n := 3
+	if fd, ok := f.(interface{ Fd() uintptr }); ok {
+		n = int(fd.Fd())
+	}
+	for ; ; n++ {
+		name := fmt.Sprintf("file%d", n)
+		if _, ok := interp.channels[name]; !ok {
+			return name
+		}
+	}
+}
+
+// RegisterChannel makes rw available to scripts in interp as a
+// readable and writable channel called name, with the default
+// configuration. If rw also implements io.Seeker, the channel
+// supports seek and tell. rw is closed when the channel is closed
+// in the last interpreter that has it.
+func (interp *Interp) RegisterChannel(name string, rw io.ReadWriteCloser) error {
+	if _, ok := interp.channels[name]; ok {
+		return fmt.Errorf("channel \"%s\" already exists", name)
+	}
+	seeker, _ := rw.(io.Seeker)
+	interp.registerChannel(newChannel(name, rw, rw, seeker, rw.Close))
+	return nil
+}
+
+// UnregisterChannel removes the channel name from interp, closing it
+// if no other interpreter has it.
+func (interp *Interp) UnregisterChannel(name string) error {
+	ch, err := interp.lookupChannel(name)
+	if err != nil {
+		return err
+	}
+	return interp.unregisterChannel(ch)
+}
+
+// channelNames returns the sorted names of the channels in interp.
+func (interp *Interp) channelNames() []string {
+	names := make([]string, 0, len(interp.channels))
+	for name := range interp.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var stdChannels struct {
+	once                  sync.Once
+	stdin, stdout, stderr *channel
+}
+
+// registerStdChannels registers stdin, stdout and stderr in interp.
+// They are shared by every interpreter and never close the process's
+// standard files.
+func (interp *Interp) registerStdChannels() {
+	stdChannels.once.Do(func() {
+		stdChannels.stdin = newChannel("stdin", os.Stdin, nil, nil, nil)
+		stdChannels.stdout = newChannel("stdout", nil, os.Stdout, nil, nil)
+		stdChannels.stderr = newChannel("stderr", nil, os.Stderr, nil, nil)
+		// Unlike Tcl, stdout is line buffered even when it is not
+		// a terminal, since hosts have no reason to flush it
+		// before exiting.
+		stdChannels.stdout.buffering = "line"
+		stdChannels.stderr.buffering = "none"
+	})
+	interp.registerChannel(stdChannels.stdin)
+	interp.registerChannel(stdChannels.stdout)
+	interp.registerChannel(stdChannels.stderr)
+}
+
+// shutdown flushes ch and closes its stream.
+func (ch *channel) shutdown() error {
+	err := ch.flush()
+	ch.in = nil
+	if ch.close != nil {
+		if cerr := ch.close(); err == nil {
+			err = cerr
+		}
+	}
+	var tclErr *Error
+	if errors.As(err, &tclErr) {
+		return err
+	}
+	if err != nil {
+		return ch.ioError("closing", err)
+	}
+	return nil
+}
+
+// ioError returns the error for an operation on ch that failed with
+// err, such as: error reading "file3": permission denied.
+func (ch *channel) ioError(operation string, err error) error {
+	return fmt.Errorf("error %s \"%s\": %s", operation, ch.name, posixError(err))
+}
+
+func (ch *channel) checkReadable() error {
+	if ch.r == nil {
+		return fmt.Errorf("channel \"%s\" wasn't opened for reading", ch.name)
+	}
+	return nil
+}
+
+func (ch *channel) checkWritable() error {
+	if ch.w == nil {
+		return fmt.Errorf("channel \"%s\" wasn't opened for writing", ch.name)
+	}
+	return nil
+}
+
+// posixError returns the message Tcl reports for err: the lower-case
+// description of the underlying system error.
+func posixError(err error) string {
+	var errno syscall.Errno
+	switch {
+	case errors.As(err, &errno):
+		switch errno {
+		case syscall.EISDIR:
+			return "illegal operation on a directory"
+		case syscall.EEXIST:
+			return "file already exists"
+		}
+		return errno.Error()
+	case errors.Is(err, fs.ErrNotExist):
+		return "no such file or directory"
+	case errors.Is(err, fs.ErrPermission):
+		return "permission denied"
+	case errors.Is(err, fs.ErrExist):
+		return "file already exists"
+	}
+	return err.Error()
+}
+
+// A readResult is the outcome of reading a chunk of a stream.
+type readResult struct {
+	buf []byte
+	err error
+}
+
+func readChunk(r io.Reader, size int) readResult {
+	buf := make([]byte, size)
+	n, err := r.Read(buf)
+	return readResult{buf[:n], err}
+}
+
+// errWouldBlock is returned by fill when a nonblocking channel has
+// no input available.
+var errWouldBlock = errors.New("input would block")
+
+// fill reads more of the stream into ch.in, waiting for the
+// background read started by readAhead if there is one. A
+// nonblocking channel does not wait: it starts a background read if
+// there is none and returns errWouldBlock until the read completes.
+func (ch *channel) fill() error {
+	if ch.readErr == nil && !ch.atEOF {
+		switch {
+		case !ch.blocking:
+			ch.readAhead(nil)
+			select {
+			case res := <-ch.pending:
+				ch.absorb(res)
+				ch.pending = nil
+			default:
+				return errWouldBlock
+			}
+		case ch.pending != nil:
+			ch.settle()
+		default:
+			ch.absorb(readChunk(ch.r, ch.bufferSize))
+		}
+	}
+	if err := ch.readErr; err != nil {
+		ch.readErr = nil
+		return ch.ioError("reading", err)
+	}
+	return nil
+}
+
+// absorb adds the result of a read to ch.in.
+func (ch *channel) absorb(res readResult) {
+	buf := res.buf
+	if ch.eofChar != 0 {
+		if i := bytes.IndexByte(buf, ch.eofChar); i >= 0 {
+			buf = buf[:i]
+			ch.atEOF, ch.eofHit = true, true
+		}
+	}
+	ch.in = append(ch.in, buf...)
+	switch {
+	case res.err == io.EOF:
+		ch.atEOF = true
+	case res.err != nil:
+		ch.readErr = res.err
+	}
+}
+
+// readAhead starts reading the next chunk of the stream in a
+// separate goroutine, unless a read is already pending, so that the
+// event loop can wait for input without blocking. notify, if not
+// nil, is called once the chunk has been read, even if the read was
+// started earlier.
+func (ch *channel) readAhead(notify func()) {
+	if notify != nil {
+		ch.mu.Lock()
+		ch.notify = notify
+		ch.mu.Unlock()
+		if ch.pending != nil && len(ch.pending) > 0 {
+			notify()
+		}
+	}
+	if ch.pending != nil || ch.atEOF || ch.r == nil {
+		return
+	}
+	pending := make(chan readResult, 1)
+	ch.pending = pending
+	r, size := ch.r, ch.bufferSize
+	go func() {
+		pending <- readChunk(r, size)
+		ch.mu.Lock()
+		notify := ch.notify
+		ch.mu.Unlock()
+		if notify != nil {
+			notify()
+		}
+	}()
+}
+
+// settle waits for the background read of ch, if any, to complete.
+func (ch *channel) settle() {
+	if ch.pending == nil {
+		return
+	}
+	ch.absorb(<-ch.pending)
+	ch.pending = nil
+}
+
+// inputReady reports whether an input operation on ch can proceed
+// without waiting for the stream.
+func (ch *channel) inputReady() bool {
+	if ch.pending != nil {
+		select {
+		case res := <-ch.pending:
+			ch.absorb(res)
+			ch.pending = nil
+		default:
+		}
+	}
+	return len(ch.in) > 0 || ch.atEOF || ch.readErr != nil
+}
+
+// decode decodes the next character of in, input of ch, translating
+// end of line sequences to newlines, and returns it with the number
+// of bytes it took up. It reports false if more input is needed to
+// decide; with final set, whatever input there is is decoded.
+func (ch *channel) decode(in []byte, final bool) (rune, int, bool) {
+	if len(in) == 0 {
+		return 0, 0, false
+	}
+	c := in[0]
+	if c == '\r' {
+		switch ch.inTranslation {
+		case "cr":
+			return '\n', 1, true
+		case "auto", "crlf":
+			if len(in) < 2 && !final {
+				return 0, 0, false
+			}
+			if len(in) >= 2 && in[1] == '\n' {
+				return '\n', 2, true
+			}
+			if ch.inTranslation == "auto" {
+				return '\n', 1, true
+			}
+		}
+	}
+	if c < utf8.RuneSelf || ch.encoding != "utf-8" {
+		return rune(c), 1, true
+	}
+	if !utf8.FullRune(in) && !final {
+		return 0, 0, false
+	}
+	r, size := utf8.DecodeRune(in)
+	if r == utf8.RuneError && size <= 1 {
+		// Invalid bytes are taken as the characters with the
+		// same code, as Tcl's tcl8 encoding profile does.
+		return rune(c), 1, true
+	}
+	return r, size, true
+}
+
+// startInput prepares ch for an input operation.
+func (ch *channel) startInput() error {
+	if err := ch.flush(); err != nil {
+		return ch.ioError("flushing", err)
+	}
+	// The stream is read again once the end of it has been
+	// reported, in case it has grown.
+	if ch.eof && !ch.eofHit {
+		ch.atEOF = false
+	}
+	ch.eof, ch.blocked = false, false
+	return nil
+}
+
+// readChars reads up to n characters from ch, or all of its input if
+// n is negative. If line is set, it stops after a newline, which is
+// not returned. It reports whether it ran out of input first, in
+// which case ch.eof is set.
+//
+// If a nonblocking channel runs out of available input first,
+// ch.blocked is set and the characters read so far are returned,
+// except that an incomplete line is left to be read once the rest
+// of it arrives.
+func (ch *channel) readChars(n int, line bool) (string, bool, error) {
+	b, eof, err := ch.readInput(n, line)
+	if ch.encoding != "utf-8" {
+		return bytesRep(b).String(), eof, err
+	}
+	return string(b), eof, err
+}
+
+// readInput reads characters as readChars does, but returns them
+// in the encoding of ch: as UTF-8 for utf-8, and otherwise as one
+// byte for each character, which is copied from the input buffer
+// unless its end of line sequences need translating.
+func (ch *channel) readInput(n int, line bool) ([]byte, bool, error) {
+	if err := ch.startInput(); err != nil {
+		return nil, false, err
+	}
+	bytewise := ch.encoding != "utf-8"
+	var b []byte
+	pos := 0
+	for count := 0; n < 0 || count < n; {
+		if bytewise && ch.inTranslation == "lf" && !line {
+			k := len(ch.in) - pos
+			if n >= 0 {
+				k = min(k, n-count)
+			}
+			if k > 0 {
+				b = append(b, ch.in[pos:pos+k]...)
+				pos += k
+				count += k
+				continue
+			}
+		}
+		r, size, ok := ch.decode(ch.in[pos:], ch.atEOF)
+		if !ok {
+			if ch.atEOF {
+				ch.in = ch.in[pos:]
+				ch.eof = true
+				return b, true, nil
+			}
+			err := ch.fill()
+			if err == errWouldBlock {
+				ch.blocked = true
+				if line {
+					return nil, false, nil
+				}
+				err = nil
+			}
+			if err != nil || ch.blocked {
+				ch.in = ch.in[pos:]
+				return b, false, err
+			}
+			continue
+		}
+		pos += size
+		if line && r == '\n' {
+			break
+		}
+		if bytewise {
+			b = append(b, byte(r))
+		} else {
+			b = utf8.AppendRune(b, r)
+		}
+		count++
+	}
+	ch.in = ch.in[pos:]
+	return b, false, nil
+}
+
+// write translates, encodes and buffers s for output, flushing as
+// the buffering mode requires.
+func (ch *channel) write(s string) error {
+	if err := ch.startOutput(); err != nil {
+		return err
+	}
+	for _, r := range s {
+		switch {
+		case r == '\n':
+			ch.newline()
+		case ch.encoding == "utf-8":
+			ch.out = utf8.AppendRune(ch.out, r)
+		case ch.encoding == "ascii" && r > 0x7f, r > 0xff:
+			ch.out = append(ch.out, '?')
+		default:
+			ch.out = append(ch.out, byte(r))
+		}
+	}
+	return ch.endOutput(strings.ContainsRune(s, '\n'))
+}
+
+// writeBytes buffers b, whose bytes are characters with the same
+// codes, for output on ch, whose encoding must be iso8859-1. The
+// bytes are copied to the output buffer, translating only newlines.
+func (ch *channel) writeBytes(b []byte) error {
+	if err := ch.startOutput(); err != nil {
+		return err
+	}
+	if ch.outTranslation == "lf" {
+		ch.out = append(ch.out, b...)
+	} else {
+		for _, c := range b {
+			if c == '\n' {
+				ch.newline()
+			} else {
+				ch.out = append(ch.out, c)
+			}
+		}
+	}
+	return ch.endOutput(bytes.IndexByte(b, '\n') >= 0)
+}
+
+// startOutput prepares ch for an output operation. Only a seekable
+// channel waits for its pending read, to reposition the stream at the
+// input consumed; the input and output of other channels are
+// independent.
+func (ch *channel) startOutput() error {
+	if ch.seeker != nil {
+		ch.settle()
+		if len(ch.in) > 0 {
+			if _, err := ch.seeker.Seek(-int64(len(ch.in)), io.SeekCurrent); err != nil {
+				return ch.ioError("writing", err)
+			}
+			ch.in = nil
+		}
+	}
+	return nil
+}
+
+// newline buffers the end of line sequence of ch for output.
+func (ch *channel) newline() {
+	switch ch.outTranslation {
+	case "cr":
+		ch.out = append(ch.out, '\r')
+	case "crlf":
+		ch.out = append(ch.out, '\r', '\n')
+	default:
+		ch.out = append(ch.out, '\n')
+	}
+}
+
+// endOutput flushes the output of ch as its buffering mode requires,
+// given whether the output just buffered held a newline.
+func (ch *channel) endOutput(newline bool) error {
+	switch {
+	case ch.buffering == "none",
+		ch.buffering == "line" && newline,
+		len(ch.out) >= ch.bufferSize:
+		if err := ch.flush(); err != nil {
+			return ch.ioError("writing", err)
+		}
+	}
+	return nil
+}
+
+// flush writes the buffered output of ch to its stream.
+func (ch *channel) flush() error {
+	if len(ch.out) == 0 {
+		return nil
+	}
+	_, err := ch.w.Write(ch.out)
+	ch.out = ch.out[:0]
+	return err
+}
+
+// seek moves the access position of ch, discarding buffered input.
+func (ch *channel) seek(offset int64, whence int) error {
+	ch.settle()
+	if err := ch.flush(); err != nil {
+		return ch.ioError("during seek on", err)
+	}
+	if ch.seeker == nil {
+		return fmt.Errorf("error during seek on \"%s\": invalid argument", ch.name)
+	}
+	if whence == io.SeekCurrent {
+		offset -= int64(len(ch.in))
+	}
+	if _, err := ch.seeker.Seek(offset, whence); err != nil {
+		return ch.ioError("during seek on", err)
+	}
+	ch.in, ch.readErr = nil, nil
+	ch.atEOF, ch.eofHit, ch.eof = false, false, false
+	return nil
+}
+
+// tell returns the access position of ch, or -1 if it cannot seek.
+func (ch *channel) tell() int64 {
+	if ch.seeker == nil {
+		return -1
+	}
+	ch.settle()
+	pos, err := ch.seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return -1
+	}
+	return pos - int64(len(ch.in)) + int64(len(ch.out))
+}
+
+var (
+	channelOptions   = []string{"-blocking", "-buffering", "-buffersize", "-encoding", "-eofchar", "-translation"}
+	translationModes = []string{"auto", "binary", "cr", "lf", "crlf", "platform"}
+	bufferingModes   = []string{"full", "line", "none"}
+	channelEncodings = []string{"ascii", "binary", "iso8859-1", "utf-8"}
+)
+
+// lookupOption returns the channel option named by a unique prefix
+// of name.
+func (ch *channel) lookupOption(name string) (string, error) {
+	match := ""
+	for _, opt := range channelOptions {
+		if opt == name {
+			return opt, nil
+		}
+		if len(name) > 1 && strings.HasPrefix(opt, name) {
+			if match != "" {
+				match = ""
+				break
+			}
+			match = opt
+		}
+	}
+	if match == "" {
+		return "", fmt.Errorf("bad option \"%s\": should be one of %s", name, mustBe(channelOptions))
+	}
+	return match, nil
+}
+
+// option returns the value of the channel option opt.
+func (ch *channel) option(opt string) *Value {
+	switch opt {
+	case "-blocking":
+		return NewBoolValue(ch.blocking)
+	case "-buffering":
+		return NewStringValue(ch.buffering)
+	case "-buffersize":
+		return NewIntValue(int64(ch.bufferSize))
+	case "-encoding":
+		return NewStringValue(ch.encoding)
+	case "-eofchar":
+		if ch.eofChar == 0 {
+			return emptyValue()
+		}
+		return NewStringValue(string(rune(ch.eofChar)))
+	}
+	switch {
+	case ch.r != nil && ch.w != nil:
+		return NewListValue([]*Value{NewStringValue(ch.inTranslation), NewStringValue(ch.outTranslation)})
+	case ch.r != nil:
+		return NewStringValue(ch.inTranslation)
+	}
+	return NewStringValue(ch.outTranslation)
+}
+
+// setOption sets the channel option opt to v.
+func (ch *channel) setOption(opt string, v *Value) error {
+	switch opt {
+	case "-blocking":
+		b, err := v.Bool()
+		if err != nil {
+			return err
+		}
+		ch.blocking = b
+	case "-buffering":
+		i, err := lookupIndex(bufferingModes, "value for -buffering", v)
+		if err != nil {
+			return fmt.Errorf("bad value for -buffering: must be one of %s", mustBe(bufferingModes))
+		}
+		ch.buffering = bufferingModes[i]
+	case "-buffersize":
+		n, err := getInt(v)
+		if err != nil {
+			return err
+		}
+		ch.bufferSize = max(1, min(n, 1<<20))
+	case "-encoding":
+		enc := v.String()
+		if !containsString(channelEncodings, enc) {
+			return fmt.Errorf("unknown encoding \"%s\"", enc)
+		}
+		if enc == "binary" {
+			enc = "iso8859-1"
+		}
+		ch.encoding = enc
+	case "-eofchar":
+		s := v.String()
+		switch {
+		case s == "":
+			ch.eofChar = 0
+		case len(s) == 1 && s[0] < utf8.RuneSelf:
+			ch.eofChar = s[0]
+		default:
+			return fmt.Errorf("bad value for -eofchar: must be non-NUL ASCII character")
+		}
+	case "-translation":
+		return ch.setTranslation(v)
+	}
+	return nil
+}
+
+// setTranslation sets the input and output translation modes from a
+// list of one mode for both directions or of the input and output
+// modes.
+func (ch *channel) setTranslation(v *Value) error {
+	modes, err := v.List()
+	if err != nil {
+		return err
+	}
+	if len(modes) == 0 || len(modes) > 2 {
+		return fmt.Errorf("bad value for -translation: must be a one or two element list")
+	}
+	if len(modes) == 1 {
+		modes = append(modes, modes[0])
+	}
+	var set [2]string
+	for i, m := range modes {
+		mode := m.String()
+		if !containsString(translationModes, mode) {
+			return fmt.Errorf("bad value for -translation: must be one of %s", mustBe(translationModes))
+		}
+		switch {
+		case mode == "binary":
+			ch.encoding = "iso8859-1"
+			ch.eofChar = 0
+			mode = "lf"
+		case mode == "platform", mode == "auto" && i == 1:
+			mode = "lf"
+		}
+		set[i] = mode
+	}
+	ch.inTranslation, ch.outTranslation = set[0], set[1]
+	return nil
+}
+
+func containsString(table []string, s string) bool {
+	for _, t := range table {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
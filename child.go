@@ -0,0 +1,225 @@
+package gotcl
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CreateChild creates a child interpreter of interp called name. A
+// command called name is created in interp that can be used to
+// manipulate the child. The child is a safe interpreter if safe is
+// true or if interp is itself safe.
+func (interp *Interp) CreateChild(name string, safe bool) (*Interp, error) {
+	if _, ok := interp.children[name]; ok {
+		return nil, fmt.Errorf("interpreter named \"%s\" already exists, cannot create", name)
+	}
+	child := newInterp(safe || interp.safe)
+	child.parent = interp
+	child.name = name
+	interp.children[name] = child
+	interp.createCommand(&command{
+		name: name,
+		fn:   child.childCommand,
+		kind: "interp",
+		deleteProc: func() {
+			child.Delete()
+		},
+	})
+	return child, nil
+}
+
+// Child returns the child interpreter of interp called name, or nil
+// if there is no such child.
+func (interp *Interp) Child(name string) *Interp {
+	return interp.children[name]
+}
+
+// Parent returns the interpreter that created interp, or nil if
+// interp was created by NewInterp or NewSafeInterp.
+func (interp *Interp) Parent() *Interp {
+	return interp.parent
+}
+
+// DeleteChild deletes the child interpreter of interp called name,
+// together with all of its descendants.
+func (interp *Interp) DeleteChild(name string) error {
+	child, ok := interp.children[name]
+	if !ok {
+		return fmt.Errorf("could not find interpreter \"%s\"", name)
+	}
+	child.Delete()
+	return nil
+}
+
+// Delete deletes interp, its children, the aliases defined in it
+// and the aliases in other interpreters that target it, releases its
+// channels, cancels its timer and idle handlers and kills its
+// coroutines, so that their goroutines exit. A deleted interpreter
+// can no longer evaluate scripts.
+func (interp *Interp) Delete() {
+	if interp.deleted {
+		return
+	}
+	interp.deleted = true
+	for _, child := range interp.children {
+		child.Delete()
+	}
+	for a := range interp.targets {
+		a.source.deleteAlias(a)
+	}
+	for _, a := range interp.aliases {
+		delete(a.target.targets, a)
+	}
+	for _, ch := range interp.channels {
+		interp.unregisterChannel(ch)
+	}
+	interp.events.afterEvents = nil
+	for _, cmds := range []map[string]*command{interp.commands, interp.hidden} {
+		for name, cmd := range cmds {
+			delete(cmds, name)
+			if cmd.deleteProc != nil {
+				cmd.deleteProc()
+			}
+		}
+	}
+	if parent := interp.parent; parent != nil {
+		delete(parent.children, interp.name)
+		if cmd, ok := parent.commands[interp.name]; ok && cmd.deleteProc != nil {
+			delete(parent.commands, interp.name)
+		}
+	}
+}
+
+// path returns the path of interp relative to its ancestor, or false
+// if ancestor is not an ancestor of interp.
+func (interp *Interp) path(ancestor *Interp) ([]string, bool) {
+	var names []string
+	for i := interp; i != ancestor; i = i.parent {
+		if i == nil {
+			return nil, false
+		}
+		names = append([]string{i.name}, names...)
+	}
+	return names, true
+}
+
+// childCommand implements the command that is created in the parent
+// of interp to manipulate it.
+func (interp *Interp) childCommand(parent *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "cmd ?arg ...?")
+	}
+	subs := []subcommand{
+		{"alias", cmdChildAlias},
+		{"aliases", cmdInterpAliases},
+		{"eval", cmdInterpEval},
+		{"expose", cmdInterpExpose},
+		{"hidden", cmdInterpHidden},
+		{"hide", cmdInterpHide},
+		{"invokehidden", cmdInterpInvokeHidden},
+		{"issafe", cmdInterpIsSafe},
+	}
+	words := make([]*Value, 0, len(args)+2)
+	words = append(words, NewStringValue("interp"), args[1])
+	words = append(words, NewStringValue(formatList([]string{interp.name})))
+	words = append(words, args[2:]...)
+	return dispatchSubcommand(parent, words, subs)
+}
+
+// cmdChildAlias implements child alias srcCmd ?targetCmd ?arg ...??,
+// passed on as interp alias child srcCmd ... In the form that creates
+// an alias, targetCmd is a command of the parent, so the path of the
+// parent, {}, is inserted before it.
+func cmdChildAlias(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 5 || (len(args) == 5 && args[4].String() != "") {
+		words := make([]*Value, 0, len(args)+1)
+		words = append(words, args[:4]...)
+		words = append(words, emptyValue())
+		args = append(words, args[4:]...)
+	}
+	return cmdInterpAlias(interp, args)
+}
+
+// An alias is a command in a source interpreter that invokes a
+// command, with some leading arguments, in a target interpreter.
+type alias struct {
+	source *Interp
+	token  string
+	// cmd is the command of the alias, which may have been renamed
+	// since it was created as token.
+	cmd    *command
+	target *Interp
+	prefix []*Value
+}
+
+// Alias creates a command srcCmd in interp that, when invoked,
+// invokes targetCmd in the target interpreter with args followed by
+// the arguments srcCmd was invoked with.
+func (interp *Interp) Alias(srcCmd string, target *Interp, targetCmd string, args ...string) error {
+	prefix := stringsToValues(append([]string{targetCmd}, args...))
+	return interp.createAlias(srcCmd, target, prefix)
+}
+
+func (interp *Interp) createAlias(token string, target *Interp, prefix []*Value) error {
+	token = commandName(token)
+	a := &alias{source: interp, token: token, target: target, prefix: prefix}
+	for i, name := target, commandName(prefix[0].String()); ; {
+		if i == interp && name == token {
+			return fmt.Errorf("cannot define or rename alias \"%s\": would create a loop", token)
+		}
+		next, ok := i.aliases[name]
+		if !ok {
+			break
+		}
+		i, name = next.target, commandName(next.prefix[0].String())
+	}
+	a.cmd = &command{
+		name: token,
+		fn:   a.invoke,
+		kind: "alias",
+		deleteProc: func() {
+			if interp.aliases[token] == a {
+				delete(interp.aliases, token)
+			}
+			delete(target.targets, a)
+		},
+	}
+	interp.createCommand(a.cmd)
+	interp.aliases[token] = a
+	target.targets[a] = true
+	return nil
+}
+
+func (interp *Interp) deleteAlias(a *alias) {
+	if interp.aliases[a.token] != a {
+		return
+	}
+	if interp.commands[a.cmd.name] == a.cmd {
+		interp.removeCommand(a.cmd)
+	}
+}
+
+func (a *alias) invoke(interp *Interp, args []*Value) (*Value, error) {
+	words := make([]*Value, 0, len(a.prefix)+len(args)-1)
+	words = append(words, a.prefix...)
+	words = append(words, args[1:]...)
+	return a.target.invoke(words)
+}
+
+func (interp *Interp) aliasNames() []string {
+	names := make([]string, 0, len(interp.aliases))
+	for name := range interp.aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (interp *Interp) childNames() []string {
+	names := make([]string, 0, len(interp.children))
+	for name := range interp.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,298 @@
+package gotcl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// The zoneinfo database is embedded so that clock finds time
+	// zones on hosts without one.
+	_ "time/tzdata"
+)
+
+// Julian day numbers used by the calendar computations of clock.
+const (
+	jdUnixEpoch         = 2440588 // 1 January 1970
+	jdGregorianEpoch    = 1721426 // 1 January 1 CE in the Gregorian calendar
+	jdJulianEpoch       = 1721424 // 1 January 1 CE in the Julian calendar
+	jdGregorianChange   = 2299161 // 15 October 1582, used by clock format and clock add
+	jdBritishChangeover = 2361222 // 14 September 1752, used by free-form clock scan
+)
+
+var daysBeforeMonth = [2][13]int{
+	{0, 31, 59, 90, 120, 151, 181, 212, 243, 273, 304, 334, 365},
+	{0, 31, 60, 91, 121, 152, 182, 213, 244, 274, 305, 335, 366},
+}
+
+// floorDiv returns a/b rounded towards negative infinity and floorMod
+// the corresponding remainder, as Tcl's / and % operators do.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+func floorMod(a, b int64) int64 {
+	return a - floorDiv(a, b)*b
+}
+
+// A clockDate holds the fields of a point in time in a time zone, as
+// clock format and clock add see it. Dates before the Gregorian
+// changeover are in the Julian calendar. year is the astronomical
+// year, so that 1 BCE is year 0.
+type clockDate struct {
+	seconds      int64
+	localSeconds int64
+	offset       int
+	zone         string
+
+	julianDay  int64
+	gregorian  bool
+	year       int64
+	month      int
+	dayOfMonth int
+	dayOfYear  int
+	// dayOfWeek runs from 1 for Monday to 7 for Sunday.
+	dayOfWeek int
+
+	iso8601Year int64
+	iso8601Week int
+}
+
+// newClockDate returns the fields of the time seconds in loc.
+func newClockDate(seconds int64, loc *time.Location, changeover int64) *clockDate {
+	zone, offset := time.Unix(seconds, 0).In(loc).Zone()
+	d := &clockDate{seconds: seconds, localSeconds: seconds + int64(offset), offset: offset, zone: zone}
+	d.setJulianDay(floorDiv(d.localSeconds, 86400)+jdUnixEpoch, changeover)
+	return d
+}
+
+// secondOfDay returns the local time of day of d in seconds.
+func (d *clockDate) secondOfDay() int64 {
+	return floorMod(d.localSeconds, 86400)
+}
+
+// setJulianDay sets the calendar fields of d to those of the Julian
+// day number jd.
+func (d *clockDate) setJulianDay(jd, changeover int64) {
+	d.julianDay = jd
+	d.dayOfWeek = int(floorMod(jd, 7)) + 1
+	d.year, d.dayOfYear, d.gregorian = yearAndDay(jd, changeover)
+	d.month, d.dayOfMonth = monthAndDay(d.dayOfYear, isLeapYear(d.year, d.gregorian))
+	// The ISO 8601 week belongs to the year holding its Thursday.
+	thursday := jd - int64(d.dayOfWeek) + 4
+	year, day, _ := yearAndDay(thursday, changeover)
+	d.iso8601Year, d.iso8601Week = year, (day-1)/7+1
+}
+
+// yearAndDay returns the year and the day of the year of the Julian
+// day number jd, and whether it is in the Gregorian calendar.
+func yearAndDay(jd, changeover int64) (int64, int, bool) {
+	if jd >= changeover {
+		day := jd - jdGregorianEpoch
+		n := floorDiv(day, 146097)
+		day -= 146097 * n
+		year := 1 + 400*n
+		n = min(day/36524, 3)
+		day -= 36524 * n
+		year += 100 * n
+		n = day / 1461
+		day -= 1461 * n
+		year += 4 * n
+		n = min(day/365, 3)
+		day -= 365 * n
+		return year + n, int(day) + 1, true
+	}
+	day := jd - jdJulianEpoch
+	n := floorDiv(day, 1461)
+	day -= 1461 * n
+	year := 1 + 4*n
+	n = min(day/365, 3)
+	day -= 365 * n
+	return year + n, int(day) + 1, false
+}
+
+// monthAndDay returns the month and day of the month of the day of
+// the year day.
+func monthAndDay(day int, leap bool) (int, int) {
+	days := &daysBeforeMonth[boolIndex(leap)]
+	month := 1
+	for month < 12 && day > days[month] {
+		month++
+	}
+	return month, day - days[month-1]
+}
+
+func boolIndex(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func isLeapYear(year int64, gregorian bool) bool {
+	if !gregorian {
+		return floorMod(year, 4) == 0
+	}
+	return floorMod(year, 4) == 0 && (floorMod(year, 100) != 0 || floorMod(year, 400) == 0)
+}
+
+// daysInMonth returns the number of days in month of year.
+func daysInMonth(year int64, month int, gregorian bool) int {
+	days := &daysBeforeMonth[boolIndex(isLeapYear(year, gregorian))]
+	return days[month] - days[month-1]
+}
+
+// julianDayOf returns the Julian day number of the date year, month
+// and day, where a month or day out of range carries into the next or
+// previous one.
+func julianDayOf(year int64, month int, day int64, changeover int64) int64 {
+	m := int64(month - 1)
+	year += floorDiv(m, 12)
+	m = floorMod(m, 12)
+	y := year - 1
+	jd := jdGregorianEpoch - 1 + day + int64(daysBeforeMonth[boolIndex(isLeapYear(year, true))][m]) +
+		365*y + floorDiv(y, 4) - floorDiv(y, 100) + floorDiv(y, 400)
+	if jd >= changeover {
+		return jd
+	}
+	return jdJulianEpoch - 1 + day + int64(daysBeforeMonth[boolIndex(isLeapYear(year, false))][m]) +
+		365*y + floorDiv(y, 4)
+}
+
+// julianDayOfWeek returns the Julian day number of the day of the
+// week weekday, 1 for Monday to 7 for Sunday, in the ISO 8601 week
+// week of year.
+func julianDayOfWeek(year int64, week, weekday int, changeover int64) int64 {
+	jan4 := julianDayOf(year, 1, 4, changeover)
+	monday := jan4 - floorMod(jan4, 7)
+	return monday + 7*int64(week-1) + int64(weekday-1)
+}
+
+// localSecondsOf returns the local seconds at secondOfDay on the
+// Julian day jd.
+func localSecondsOf(jd, secondOfDay int64) int64 {
+	return (jd-jdUnixEpoch)*86400 + secondOfDay
+}
+
+// localToUTC returns the time at which the clocks of loc show local.
+// As in Tcl, a local time skipped by a change to daylight saving time
+// is taken as the corresponding time after it, and a local time that
+// occurs twice is taken as the first.
+func localToUTC(local int64, loc *time.Location) int64 {
+	var seen []int
+	seconds := local
+	for {
+		_, offset := time.Unix(seconds, 0).In(loc).Zone()
+		seconds = local - int64(offset)
+		for _, o := range seen {
+			if o == offset {
+				return seconds
+			}
+		}
+		seen = append(seen, offset)
+	}
+}
+
+var (
+	clockZoneCache sync.Map
+	numericZoneRE  = regexp.MustCompile(`^([-+])(\d\d):?(\d\d)(?::?(\d\d))?$`)
+)
+
+// clockLocation returns the time zone named by the -timezone option
+// of clock: :localtime or an empty string for the local time zone, a
+// name from the zoneinfo database with or without a leading colon, an
+// offset from UTC such as -0500 or +05:30:00, or a POSIX TZ string
+// such as EST5EDT,M3.2.0,M11.1.0.
+func clockLocation(name string) (*time.Location, error) {
+	if name == "" || name == ":localtime" {
+		return time.Local, nil
+	}
+	if loc, ok := clockZoneCache.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := loadClockLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	clockZoneCache.Store(name, loc)
+	return loc, nil
+}
+
+func loadClockLocation(name string) (*time.Location, error) {
+	if m := numericZoneRE.FindStringSubmatch(name); m != nil {
+		h, _ := strconv.Atoi(m[2])
+		mi, _ := strconv.Atoi(m[3])
+		s, _ := strconv.Atoi(m[4])
+		offset := h*3600 + mi*60 + s
+		if m[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(name, offset), nil
+	}
+	zone := strings.TrimPrefix(name, ":")
+	if zone != "" && !strings.Contains(zone, "..") {
+		if loc, err := time.LoadLocation(zone); err == nil {
+			return loc, nil
+		}
+	}
+	if !strings.HasPrefix(name, ":") {
+		if loc := posixLocation(name); loc != nil {
+			return loc, nil
+		}
+	}
+	return nil, &Error{
+		Code: []string{"CLOCK", "badTimeZone", name},
+		Msg:  fmt.Sprintf("time zone \"%s\" not found", name),
+	}
+}
+
+// posixLocation returns the time zone described by the POSIX TZ
+// string tz, or nil if tz is not one. The time package understands
+// such strings only as the footer of zoneinfo data, so the zone is
+// built from data holding nothing else.
+func posixLocation(tz string) *time.Location {
+	const invalid = "-"
+	var data bytes.Buffer
+	for range 2 {
+		data.WriteString("TZif2")
+		data.Write(make([]byte, 15))
+		// One local time type, with a name of two bytes.
+		for _, n := range []uint32{0, 0, 0, 0, 1, 2} {
+			binary.Write(&data, binary.BigEndian, n)
+		}
+		data.Write([]byte{0, 0, 0, 0, 0, 0})
+		data.WriteString(invalid + "\x00")
+	}
+	data.WriteString("\n" + tz + "\n")
+	loc, err := time.LoadLocationFromTZData(tz, data.Bytes())
+	if err != nil {
+		return nil
+	}
+	// The local time type is only used if tz cannot be parsed.
+	if zone, _ := time.Unix(0, 0).In(loc).Zone(); zone == invalid {
+		return nil
+	}
+	return loc
+}
+
+// formatNumericZone formats the offset from UTC in seconds as +hhmm,
+// or +hhmmss if it is not a whole number of minutes.
+func formatNumericZone(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	s := fmt.Sprintf("%c%02d%02d", sign, offset/3600, offset/60%60)
+	if offset%60 != 0 {
+		s += fmt.Sprintf("%02d", offset%60)
+	}
+	return s
+}
@@ -0,0 +1,990 @@
+package gotcl
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The kinds of token of the free form of clock scan, named after those
+// of the yacc grammar of Tcl's earlier clock scan.
+type dateTokenKind int
+
+const (
+	dateEnd dateTokenKind = iota
+	dateChar
+	dateNumber
+	dateISOBase
+	dateMonth
+	dateDay
+	dateMeridian
+	dateZone
+	dateDayZone
+	dateDST
+	dateSecUnit
+	dateDayUnit
+	dateMonthUnit
+	dateNext
+	dateAgo
+	dateEpoch
+	dateStardate
+	dateID
+)
+
+// A dateToken is a token of the free form of clock scan. start and
+// end are the indexes of its first and last characters.
+type dateToken struct {
+	kind       dateTokenKind
+	value      int64
+	digits     int
+	start, end int
+}
+
+// The meridians of a time of day.
+const (
+	merid24 = iota
+	meridAM
+	meridPM
+)
+
+type dateWord struct {
+	name  string
+	kind  dateTokenKind
+	value int64
+}
+
+var (
+	dateMonthDayWords = []dateWord{
+		{"january", dateMonth, 1},
+		{"february", dateMonth, 2},
+		{"march", dateMonth, 3},
+		{"april", dateMonth, 4},
+		{"may", dateMonth, 5},
+		{"june", dateMonth, 6},
+		{"july", dateMonth, 7},
+		{"august", dateMonth, 8},
+		{"september", dateMonth, 9},
+		{"sept", dateMonth, 9},
+		{"october", dateMonth, 10},
+		{"november", dateMonth, 11},
+		{"december", dateMonth, 12},
+		{"sunday", dateDay, 0},
+		{"monday", dateDay, 1},
+		{"tuesday", dateDay, 2},
+		{"tues", dateDay, 2},
+		{"wednesday", dateDay, 3},
+		{"wednes", dateDay, 3},
+		{"thursday", dateDay, 4},
+		{"thur", dateDay, 4},
+		{"thurs", dateDay, 4},
+		{"friday", dateDay, 5},
+		{"saturday", dateDay, 6},
+	}
+	dateUnitWords = []dateWord{
+		{"year", dateMonthUnit, 12},
+		{"month", dateMonthUnit, 1},
+		{"fortnight", dateDayUnit, 14},
+		{"week", dateDayUnit, 7},
+		{"day", dateDayUnit, 1},
+		{"hour", dateSecUnit, 3600},
+		{"minute", dateSecUnit, 60},
+		{"min", dateSecUnit, 60},
+		{"second", dateSecUnit, 1},
+		{"sec", dateSecUnit, 1},
+	}
+	dateOtherWords = []dateWord{
+		{"tomorrow", dateDayUnit, 1},
+		{"yesterday", dateDayUnit, -1},
+		{"today", dateDayUnit, 0},
+		{"now", dateSecUnit, 0},
+		{"last", dateNumber, -1},
+		{"this", dateSecUnit, 0},
+		{"next", dateNext, 1},
+		{"first", dateNumber, 1},
+		{"third", dateNumber, 3},
+		{"fourth", dateNumber, 4},
+		{"fifth", dateNumber, 5},
+		{"sixth", dateNumber, 6},
+		{"seventh", dateNumber, 7},
+		{"eighth", dateNumber, 8},
+		{"ninth", dateNumber, 9},
+		{"tenth", dateNumber, 10},
+		{"eleventh", dateNumber, 11},
+		{"twelfth", dateNumber, 12},
+		{"ago", dateAgo, 1},
+		{"epoch", dateEpoch, 0},
+		{"stardate", dateStardate, 0},
+	}
+	// The values of time zones are in minutes west of UTC.
+	dateZoneWords = []dateWord{
+		{"gmt", dateZone, 0},
+		{"ut", dateZone, 0},
+		{"utc", dateZone, 0},
+		{"uct", dateZone, 0},
+		{"wet", dateZone, 0},
+		{"bst", dateDayZone, 0},
+		{"wat", dateZone, 60},
+		{"at", dateZone, 120},
+		{"nft", dateZone, 210},
+		{"nst", dateZone, 210},
+		{"ndt", dateDayZone, 210},
+		{"ast", dateZone, 240},
+		{"adt", dateDayZone, 240},
+		{"est", dateZone, 300},
+		{"edt", dateDayZone, 300},
+		{"cst", dateZone, 360},
+		{"cdt", dateDayZone, 360},
+		{"mst", dateZone, 420},
+		{"mdt", dateDayZone, 420},
+		{"pst", dateZone, 480},
+		{"pdt", dateDayZone, 480},
+		{"yst", dateZone, 540},
+		{"ydt", dateDayZone, 540},
+		{"akst", dateZone, 540},
+		{"akdt", dateDayZone, 540},
+		{"hst", dateZone, 600},
+		{"hdt", dateDayZone, 600},
+		{"cat", dateZone, 600},
+		{"ahst", dateZone, 600},
+		{"nt", dateZone, 660},
+		{"idlw", dateZone, 720},
+		{"cet", dateZone, -60},
+		{"cest", dateDayZone, -60},
+		{"met", dateZone, -60},
+		{"mewt", dateZone, -60},
+		{"mest", dateDayZone, -60},
+		{"swt", dateZone, -60},
+		{"sst", dateDayZone, -60},
+		{"fwt", dateZone, -60},
+		{"fst", dateDayZone, -60},
+		{"eet", dateZone, -120},
+		{"bt", dateZone, -180},
+		{"it", dateZone, -210},
+		{"zp4", dateZone, -240},
+		{"zp5", dateZone, -300},
+		{"ist", dateZone, -330},
+		{"zp6", dateZone, -360},
+		{"wast", dateZone, -420},
+		{"wadt", dateDayZone, -420},
+		{"jt", dateZone, -450},
+		{"cct", dateZone, -480},
+		{"jst", dateZone, -540},
+		{"jdt", dateDayZone, -540},
+		{"kst", dateZone, -540},
+		{"kdt", dateDayZone, -540},
+		{"cast", dateZone, -570},
+		{"cadt", dateDayZone, -570},
+		{"east", dateZone, -600},
+		{"eadt", dateDayZone, -600},
+		{"gst", dateZone, -600},
+		{"nzt", dateZone, -720},
+		{"nzst", dateZone, -720},
+		{"nzdt", dateDayZone, -720},
+		{"idle", dateZone, -720},
+	}
+)
+
+// findDateWord returns the entry of words named name.
+func findDateWord(words []dateWord, name string) (dateToken, bool) {
+	for _, w := range words {
+		if w.name == name {
+			return dateToken{kind: w.kind, value: w.value}, true
+		}
+	}
+	return dateToken{}, false
+}
+
+// lookupDateWord returns the token for the word w.
+func lookupDateWord(w string) dateToken {
+	w = strings.ToLower(w)
+	switch w {
+	case "am", "a.m.":
+		return dateToken{kind: dateMeridian, value: meridAM}
+	case "pm", "p.m.":
+		return dateToken{kind: dateMeridian, value: meridPM}
+	case "dst":
+		return dateToken{kind: dateDST}
+	}
+	// Three letters, optionally followed by a period, abbreviate the
+	// name of a month or day.
+	abbrev := len(w) == 3 || len(w) == 4 && w[3] == '.'
+	for _, e := range dateMonthDayWords {
+		if abbrev && strings.HasPrefix(e.name, w[:3]) || e.name == w {
+			return dateToken{kind: e.kind, value: e.value}
+		}
+	}
+	if t, ok := findDateWord(dateZoneWords, w); ok {
+		return t
+	}
+	if t, ok := findDateWord(dateUnitWords, w); ok {
+		return t
+	}
+	if t, ok := findDateWord(dateUnitWords, strings.TrimSuffix(w, "s")); ok && strings.HasSuffix(w, "s") {
+		return t
+	}
+	if t, ok := findDateWord(dateOtherWords, w); ok {
+		return t
+	}
+	// Single letters are military time zones, A to M east of UTC
+	// and N to Y west of it. There is no J.
+	if len(w) == 1 && w[0] >= 'a' && w[0] <= 'z' && w[0] != 'j' {
+		switch c := int64(w[0]); {
+		case c < 'j':
+			return dateToken{kind: dateZone, value: -60 * (c - 'a' + 1)}
+		case c <= 'm':
+			return dateToken{kind: dateZone, value: -60 * (c - 'a')}
+		case c == 'z':
+			return dateToken{kind: dateZone, value: 0}
+		default:
+			return dateToken{kind: dateZone, value: 60 * (c - 'n' + 1)}
+		}
+	}
+	if t, ok := findDateWord(dateZoneWords, strings.ReplaceAll(w, ".", "")); ok {
+		return t
+	}
+	return dateToken{kind: dateID}
+}
+
+func isASCIILetter(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// lexDate splits s into the tokens of the free form of clock scan.
+// Text in parentheses is a comment.
+func lexDate(s []rune) []dateToken {
+	var toks []dateToken
+	for i := 0; ; {
+		for i < len(s) && unicode.IsSpace(s[i]) {
+			i++
+		}
+		if i == len(s) {
+			return append(toks, dateToken{kind: dateEnd, start: i, end: i})
+		}
+		start := i
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			var n int64
+			for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+				n = 10*n + int64(s[i]-'0')
+			}
+			// Six or more digits make a date or time in the basic
+			// format of ISO 8601.
+			kind := dateNumber
+			if i-start >= 6 {
+				kind = dateISOBase
+			}
+			toks = append(toks, dateToken{kind: kind, value: n, digits: i - start, start: start, end: i - 1})
+		case isASCIILetter(c):
+			for i < len(s) && (isASCIILetter(s[i]) || s[i] == '.') {
+				i++
+			}
+			t := lookupDateWord(string(s[start:i]))
+			t.start, t.end = start, i-1
+			toks = append(toks, t)
+		case c == '(':
+			for depth := 0; i < len(s); {
+				if s[i] == '(' {
+					depth++
+				} else if s[i] == ')' {
+					depth--
+				}
+				if i++; depth == 0 {
+					break
+				}
+			}
+		default:
+			toks = append(toks, dateToken{kind: dateChar, value: int64(c), start: i, end: i})
+			i++
+		}
+	}
+}
+
+// A dateScanner parses the free form of clock scan. Each have field
+// counts the items of its kind in the string; the other fields hold
+// what they set.
+type dateScanner struct {
+	toks []dateToken
+	pos  int
+
+	haveDate, haveTime, haveZone, haveDay, haveRel, haveOrdinalMonth int
+
+	year, month, day             int64
+	hour, minutes, seconds       int64
+	meridian                     int64
+	timezone                     int64
+	dst                          bool
+	dayOrdinal, dayOfWeek        int64
+	monthOrdinalIncr, monthOrd   int64
+	relMonth, relDay, relSeconds int64
+}
+
+func (p *dateScanner) peek(n int) dateToken {
+	return p.toks[min(p.pos+n, len(p.toks)-1)]
+}
+
+func (p *dateScanner) next() dateToken {
+	t := p.peek(0)
+	p.pos++
+	return t
+}
+
+// is reports whether the nth token from the current one is of kind.
+func (p *dateScanner) is(n int, kind dateTokenKind) bool {
+	return p.peek(n).kind == kind
+}
+
+// isChar reports whether the nth token from the current one is the
+// character c.
+func (p *dateScanner) isChar(n int, c rune) bool {
+	t := p.peek(n)
+	return t.kind == dateChar && t.value == int64(c)
+}
+
+// isUnit reports whether the nth token from the current one is a unit
+// of relative time.
+func (p *dateScanner) isUnit(n int) bool {
+	switch p.peek(n).kind {
+	case dateSecUnit, dateDayUnit, dateMonthUnit:
+		return true
+	}
+	return false
+}
+
+// isT reports whether the nth token from the current one is the T
+// that separates the date and time of ISO 8601.
+func (p *dateScanner) isT(n int) bool {
+	t := p.peek(n)
+	return t.kind == dateZone && t.value == 420
+}
+
+func (p *dateScanner) syntaxError(n int) error {
+	t := p.peek(n)
+	return fmt.Errorf("syntax error (characters %d-%d)", t.start, t.end)
+}
+
+// parse parses the tokens of the string.
+func (p *dateScanner) parse() error {
+	for !p.is(0, dateEnd) {
+		if err := p.item(); err != nil {
+			return err
+		}
+	}
+	switch {
+	case p.haveDate > 1:
+		return errors.New("more than one date in string")
+	case p.haveTime > 1:
+		return errors.New("more than one time of day in string")
+	case p.haveZone > 1:
+		return errors.New("more than one time zone in string")
+	case p.haveDay > 1:
+		return errors.New("more than one weekday in string")
+	case p.haveOrdinalMonth > 1:
+		return errors.New("more than one ordinal month in string")
+	}
+	return nil
+}
+
+func (p *dateScanner) setTime(hour, minutes, seconds, meridian int64) {
+	p.hour, p.minutes, p.seconds, p.meridian = hour, minutes, seconds, meridian
+	p.haveTime++
+}
+
+func (p *dateScanner) setDate(year, month, day int64) {
+	p.year, p.month, p.day = year, month, day
+	p.haveDate++
+}
+
+func (p *dateScanner) setISOTime(hhmmss int64) {
+	p.setTime(hhmmss/10000, hhmmss%10000/100, hhmmss%100, merid24)
+}
+
+func (p *dateScanner) setDay(ordinal, weekday int64) {
+	p.dayOrdinal, p.dayOfWeek = ordinal, weekday
+	p.haveDay++
+}
+
+// rel adds n of the unit u to the relative time, which ago then
+// negates.
+func (p *dateScanner) rel(n int64, u dateToken) {
+	switch u.kind {
+	case dateSecUnit:
+		p.relSeconds += n * u.value
+	case dateDayUnit:
+		p.relDay += n * u.value
+	case dateMonthUnit:
+		p.relMonth += n * u.value
+	}
+	if p.is(0, dateAgo) {
+		p.pos++
+		p.relSeconds, p.relDay, p.relMonth = -p.relSeconds, -p.relDay, -p.relMonth
+	}
+	p.haveRel++
+}
+
+// item parses the next item of the string.
+func (p *dateScanner) item() error {
+	t := p.next()
+	switch t.kind {
+	case dateNumber:
+		return p.numberItem(t)
+	case dateISOBase:
+		p.setDate(t.value/10000, t.value%10000/100, t.value%100)
+		switch {
+		case p.is(0, dateISOBase):
+			p.setISOTime(p.next().value)
+		case p.isT(0) && p.is(1, dateISOBase):
+			p.pos++
+			p.setISOTime(p.next().value)
+		case p.isT(0) && p.is(1, dateNumber) && p.isChar(2, ':') && p.is(3, dateNumber) && p.isChar(4, ':') && p.is(5, dateNumber):
+			p.setTime(p.peek(1).value, p.peek(3).value, p.peek(5).value, merid24)
+			p.pos += 6
+		}
+	case dateMonth:
+		if !p.is(0, dateNumber) {
+			return p.syntaxError(0)
+		}
+		p.setDate(p.year, t.value, p.next().value)
+		if p.isChar(0, ',') && p.is(1, dateNumber) {
+			p.year = p.peek(1).value
+			p.pos += 2
+		}
+	case dateDay:
+		if p.isChar(0, ',') {
+			p.pos++
+		}
+		p.setDay(1, t.value)
+	case dateChar:
+		sign := int64(1)
+		switch t.value {
+		case '-':
+			sign = -1
+		case '+':
+		default:
+			return p.syntaxError(-1)
+		}
+		if !p.is(0, dateNumber) {
+			return p.syntaxError(0)
+		}
+		n := sign * p.next().value
+		switch {
+		case p.is(0, dateDay):
+			p.setDay(n, p.next().value)
+		case p.isUnit(0):
+			p.rel(n, p.next())
+		default:
+			return p.syntaxError(0)
+		}
+	case dateNext:
+		switch {
+		case p.is(0, dateDay):
+			p.setDay(2, p.next().value)
+		case p.is(0, dateMonth):
+			p.monthOrdinalIncr, p.monthOrd = 1, p.next().value
+			p.haveOrdinalMonth++
+		case p.is(0, dateNumber) && p.is(1, dateMonth):
+			p.monthOrdinalIncr, p.monthOrd = p.peek(0).value, p.peek(1).value
+			p.pos += 2
+			p.haveOrdinalMonth++
+		case p.is(0, dateNumber) && p.isUnit(1):
+			n := p.next().value
+			p.rel(n, p.next())
+		case p.isUnit(0):
+			p.rel(1, p.next())
+		default:
+			return p.syntaxError(0)
+		}
+	case dateSecUnit, dateDayUnit, dateMonthUnit:
+		p.rel(1, t)
+	case dateZone, dateDayZone:
+		p.timezone, p.dst = t.value, t.kind == dateDayZone
+		if t.kind == dateZone && p.is(0, dateDST) {
+			p.pos++
+			p.dst = true
+		}
+		p.haveZone++
+	case dateEpoch:
+		p.setDate(1970, 1, 1)
+	case dateStardate:
+		if !p.is(0, dateNumber) || !p.isChar(1, '.') || !p.is(2, dateNumber) {
+			return p.syntaxError(0)
+		}
+		n, fraction := p.peek(0).value, p.peek(2).value
+		p.pos += 3
+		p.setDate(n/1000+2323-377, 1, 1)
+		days := int64(365)
+		if isLeapYear(p.year, true) {
+			days = 366
+		}
+		p.relDay += n % 1000 * days / 1000
+		p.relSeconds += fraction * 144 * 60
+		p.haveTime++
+		p.haveRel++
+	default:
+		return p.syntaxError(-1)
+	}
+	return nil
+}
+
+// numberItem parses the item starting with the number t.
+func (p *dateScanner) numberItem(t dateToken) error {
+	switch {
+	case p.is(0, dateMeridian):
+		p.setTime(t.value, 0, 0, p.next().value)
+	case p.isChar(0, ':') && p.is(1, dateNumber):
+		minutes := p.peek(1).value
+		p.pos += 2
+		var seconds int64
+		if p.isChar(0, ':') && p.is(1, dateNumber) {
+			seconds = p.peek(1).value
+			p.pos += 2
+		}
+		switch {
+		case p.isChar(0, '-') && p.is(1, dateNumber):
+			// A time followed by an offset from UTC, as in
+			// 10:00-0500.
+			zone := p.peek(1).value
+			p.pos += 2
+			p.setTime(t.value, minutes, seconds, merid24)
+			p.timezone, p.dst = zone%100+zone/100*60, false
+			p.haveZone++
+		case p.is(0, dateMeridian):
+			p.setTime(t.value, minutes, seconds, p.next().value)
+		default:
+			p.setTime(t.value, minutes, seconds, merid24)
+		}
+	case p.isChar(0, '/') && p.is(1, dateNumber):
+		p.setDate(p.year, t.value, p.peek(1).value)
+		p.pos += 2
+		if p.isChar(0, '/') && p.is(1, dateNumber) {
+			p.year = p.peek(1).value
+			p.pos += 2
+		}
+	case p.isChar(0, '-') && p.is(1, dateMonth) && p.isChar(2, '-') && p.is(3, dateNumber):
+		p.setDate(p.peek(3).value, p.peek(1).value, t.value)
+		p.pos += 4
+	case p.isChar(0, '-') && p.is(1, dateNumber) && p.isChar(2, '-') && p.is(3, dateNumber):
+		p.setDate(t.value, p.peek(1).value, p.peek(3).value)
+		p.pos += 4
+		if p.isT(0) && p.is(1, dateNumber) && p.isChar(2, ':') && p.is(3, dateNumber) && p.isChar(4, ':') && p.is(5, dateNumber) {
+			p.setTime(p.peek(1).value, p.peek(3).value, p.peek(5).value, merid24)
+			p.pos += 6
+		}
+	case p.is(0, dateMonth):
+		p.setDate(p.year, p.next().value, t.value)
+		if p.is(0, dateNumber) {
+			p.year = p.next().value
+		}
+	case p.is(0, dateDay):
+		p.setDay(t.value, p.next().value)
+	case p.isUnit(0):
+		p.rel(t.value, p.next())
+	case p.haveTime > 0 && p.haveDate > 0 && p.haveRel == 0:
+		// A lone number after a date and a time is the year.
+		p.year = t.value
+	case t.digits <= 2:
+		p.setTime(t.value, 0, 0, merid24)
+	default:
+		p.setTime(t.value/100, t.value%100, 0, merid24)
+	}
+	return nil
+}
+
+// secondOfDay returns the time of day that was scanned in seconds, or
+// -1 if it is not a valid one.
+func (p *dateScanner) secondOfDay() int64 {
+	if p.minutes < 0 || p.minutes > 59 || p.seconds < 0 || p.seconds > 59 {
+		return -1
+	}
+	hour := p.hour
+	switch p.meridian {
+	case merid24:
+		if hour < 0 || hour > 23 {
+			return -1
+		}
+	case meridAM, meridPM:
+		if hour < 1 || hour > 12 {
+			return -1
+		}
+		hour %= 12
+		if p.meridian == meridPM {
+			hour += 12
+		}
+	}
+	return (hour*60+p.minutes)*60 + p.seconds
+}
+
+// weekdayOnOrBefore returns the Julian day number of the day of the
+// week weekday, 0 for Sunday to 6 for Saturday, on or before the
+// Julian day jd.
+func weekdayOnOrBefore(weekday, jd int64) int64 {
+	k := floorMod(weekday+6, 7)
+	return jd - floorMod(jd-k, 7)
+}
+
+// freeScan scans s in the free form of clock scan, taking the fields
+// it lacks from the time base in loc.
+func freeScan(s string, base int64, loc *time.Location) (int64, error) {
+	date := newClockDate(base, loc, jdBritishChangeover)
+	p := &dateScanner{
+		toks:  lexDate([]rune(s)),
+		year:  date.year,
+		month: int64(date.month),
+		day:   int64(date.dayOfMonth),
+	}
+	if err := p.parse(); err != nil {
+		return 0, fmt.Errorf("unable to convert date-time string \"%s\": %s", s, err)
+	}
+	year, month, day := date.year, int64(date.month), int64(date.dayOfMonth)
+	secondOfDay, haveTime := date.secondOfDay(), p.haveTime > 0
+	if haveTime {
+		if secondOfDay = p.secondOfDay(); secondOfDay < 0 {
+			return 0, fmt.Errorf("unable to convert date-time string \"%s\": invalid time", s)
+		}
+	}
+	if p.haveDate > 0 {
+		year, month, day = p.year, p.month, p.day
+		switch {
+		case year >= 100:
+		case year >= 39:
+			year += 1900
+		default:
+			year += 2000
+		}
+		if !haveTime {
+			secondOfDay, haveTime = 0, true
+		}
+	}
+	if p.haveZone > 0 {
+		offset := -60 * p.timezone
+		if p.dst {
+			offset += 3600
+		}
+		loc = time.FixedZone(formatNumericZone(int(offset)), int(offset))
+	}
+	haveWeekday := p.haveDay > 0 && p.haveDate == 0
+	if !haveTime && (haveWeekday || p.haveOrdinalMonth > 0 || p.relMonth != 0 || p.relDay != 0) {
+		secondOfDay = 0
+	}
+	jd := julianDayOf(year, int(month), day, jdBritishChangeover)
+	seconds := localToUTC(localSecondsOf(jd, secondOfDay), loc)
+	if p.haveRel > 0 {
+		seconds = addMonths(seconds, p.relMonth, loc)
+		seconds = addDays(seconds, p.relDay, loc)
+		seconds += p.relSeconds
+	}
+	if haveWeekday {
+		d := newClockDate(seconds, loc, jdBritishChangeover)
+		jd := weekdayOnOrBefore(p.dayOfWeek, d.julianDay+6) + 7*p.dayOrdinal
+		if p.dayOrdinal > 0 {
+			jd -= 7
+		}
+		seconds = localToUTC(localSecondsOf(jd, secondOfDay), loc)
+	}
+	if p.haveOrdinalMonth > 0 {
+		ordinal := p.monthOrdinalIncr
+		var diff int64
+		if ordinal > 0 {
+			if diff = p.monthOrd - month; diff <= 0 {
+				diff += 12
+			}
+			ordinal--
+		} else {
+			if diff = month - p.monthOrd; diff >= 0 {
+				diff -= 12
+			}
+			ordinal++
+		}
+		seconds = addMonths(seconds, 12*ordinal+diff, loc)
+	}
+	return seconds, nil
+}
+
+// A scanFields holds the fields matched by clock scan -format, named
+// as in Tcl's implementation.
+type scanFields map[string]int64
+
+func (f scanFields) has(names ...string) bool {
+	for _, name := range names {
+		if _, ok := f[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// A formatScanner matches strings against a clock scan format. Each
+// capturing group of re has a setter in setters, which records the
+// fields for the text it matched.
+type formatScanner struct {
+	re      strings.Builder
+	setters []func(f scanFields, s string) error
+	zone    *time.Location
+}
+
+var stardateRE = regexp.MustCompile(`^(?i:stardate)\s+([-+]?\d+)(\d\d\d)[.](\d)$`)
+
+// group adds the capturing group re, whose text sets field.
+func (sc *formatScanner) group(re, field string) {
+	sc.re.WriteString("(" + re + ")")
+	sc.setters = append(sc.setters, func(f scanFields, s string) error {
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		f[field] = n
+		return err
+	})
+}
+
+// names adds a group matching any of names and their three-letter
+// abbreviations, which sets field to value(i) for the ith name.
+func (sc *formatScanner) names(names []string, field string, value func(i int) int64) {
+	alternatives := append([]string(nil), names...)
+	for _, name := range names {
+		alternatives = append(alternatives, name[:3])
+	}
+	sc.re.WriteString("(" + strings.Join(alternatives, "|") + ")")
+	sc.setters = append(sc.setters, func(f scanFields, s string) error {
+		for i, name := range names {
+			if strings.EqualFold(s, name) || strings.EqualFold(s, name[:3]) {
+				f[field] = value(i)
+			}
+		}
+		return nil
+	})
+}
+
+// setter adds the capturing group re, whose text is recorded by set.
+func (sc *formatScanner) setter(re string, set func(f scanFields, s string) error) {
+	sc.re.WriteString("(" + re + ")")
+	sc.setters = append(sc.setters, set)
+}
+
+// compile adds the regular expression matching format.
+func (sc *formatScanner) compile(format string) {
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f' {
+			sc.re.WriteString(`\s*`)
+			continue
+		}
+		if c != '%' || i+1 == len(format) {
+			sc.re.WriteString(regexp.QuoteMeta(string(c)))
+			continue
+		}
+		i++
+		c = format[i]
+		if (c == 'E' || c == 'O') && i+1 < len(format) {
+			// The modifiers make no difference in the root locale.
+			if strings.IndexByte("EcCxXyY", format[i+1]) >= 0 && c == 'E' ||
+				strings.IndexByte("deHIklmMSuwy", format[i+1]) >= 0 && c == 'O' {
+				i++
+				c = format[i]
+			}
+		}
+		switch c {
+		case 'a', 'A':
+			sc.names(clockWeekdays, "dayOfWeek", func(i int) int64 {
+				if i == 0 {
+					return 7
+				}
+				return int64(i)
+			})
+		case 'b', 'B', 'h':
+			sc.names(clockMonths, "month", func(i int) int64 { return int64(i) + 1 })
+		case 'c':
+			sc.compile(clockDateTimeFormat)
+		case 'C':
+			sc.group(`\s*\d\d?`, "century")
+		case 'd', 'e':
+			sc.group(`\s*\d\d?`, "dayOfMonth")
+		case 'D', 'x':
+			sc.compile(clockDateFormat)
+		case 'g':
+			sc.group(`\s*\d\d`, "iso8601YearOfCentury")
+		case 'G':
+			sc.setter(`\s*\d\d?\d?\d?`, func(f scanFields, s string) error {
+				n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+				f["iso8601Century"], f["iso8601YearOfCentury"] = n/100, n%100
+				return err
+			})
+		case 'H', 'k':
+			sc.group(`\s*\d\d?`, "hour")
+		case 'I', 'l':
+			sc.group(`\s*\d\d?`, "hourAMPM")
+		case 'j':
+			sc.group(`\s*\d\d?\d?`, "dayOfYear")
+		case 'J':
+			sc.group(`\s*\d+`, "julianDay")
+		case 'm', 'N':
+			sc.group(`\s*\d\d?`, "month")
+		case 'M':
+			sc.group(`\s*\d\d?`, "minute")
+		case 'n', 't':
+			sc.re.WriteString(`\s*`)
+		case 'p', 'P':
+			sc.setter(`am|pm`, func(f scanFields, s string) error {
+				f["amPmIndicator"] = int64(boolIndex(strings.EqualFold(s, "pm")))
+				return nil
+			})
+		case 'Q':
+			sc.setter(`(?i:stardate)\s+[-+]?\d+\d\d\d[.]\d`, func(f scanFields, s string) error {
+				m := stardateRE.FindStringSubmatch(s)
+				year, _ := strconv.ParseInt(m[1], 10, 64)
+				fraction, _ := strconv.ParseInt(m[2], 10, 64)
+				tenths, _ := strconv.ParseInt(m[3], 10, 64)
+				year += 1946
+				days := int64(365)
+				if isLeapYear(year, true) {
+					days = 366
+				}
+				// Stardates are always in the Gregorian calendar.
+				jd := julianDayOf(year, 1, fraction*days/1000+1, 0)
+				f["seconds"] = localSecondsOf(jd, 8640*tenths)
+				return nil
+			})
+		case 'r':
+			sc.compile(clockTimeFormat12)
+		case 'R':
+			sc.compile("%H:%M")
+		case 's':
+			sc.group(`\s*[-+]?\d+`, "seconds")
+		case 'S':
+			sc.group(`\s*\d\d?`, "second")
+		case 'T', 'X':
+			sc.compile(clockTimeFormat)
+		case 'u':
+			sc.group(`\s*\d`, "dayOfWeek")
+		case 'U', 'W':
+			// The week of the year is matched but not used.
+			sc.re.WriteString(`\s*\d\d?`)
+		case 'V':
+			sc.group(`\s*\d\d?`, "iso8601Week")
+		case 'w':
+			sc.setter(`\s*\d`, func(f scanFields, s string) error {
+				n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+				if n == 0 {
+					n = 7
+				}
+				f["dayOfWeek"] = n
+				return err
+			})
+		case 'y':
+			sc.group(`\s*\d\d?`, "yearOfCentury")
+		case 'Y':
+			sc.setter(`\s*\d\d?\d?\d?`, func(f scanFields, s string) error {
+				n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+				f["century"], f["yearOfCentury"] = n/100, n%100
+				return err
+			})
+		case 'z', 'Z':
+			sc.setter(`[-+]\d\d(?::?\d\d(?::?\d\d)?)?|[[:alnum:]]{1,4}`, func(f scanFields, s string) error {
+				loc, err := scanZone(s)
+				sc.zone = loc
+				return err
+			})
+		case '+':
+			sc.compile(clockFullFormat)
+		case '%':
+			sc.re.WriteString("%")
+		default:
+			sc.re.WriteString(regexp.QuoteMeta("%" + string(c)))
+		}
+	}
+}
+
+// scanZone returns the time zone named name in a string matched by
+// clock scan -format: an offset from UTC or the abbreviation of a
+// time zone known to the free form of clock scan.
+func scanZone(name string) (*time.Location, error) {
+	if numericZoneRE.MatchString(name) {
+		return clockLocation(name)
+	}
+	t, ok := findDateWord(dateZoneWords, strings.ToLower(name))
+	if !ok {
+		return nil, &Error{
+			Code: []string{"CLOCK", "badTimeZone", name},
+			Msg:  fmt.Sprintf("time zone \"%s\" not found", name),
+		}
+	}
+	offset := -60 * int(t.value)
+	if t.kind == dateDayZone {
+		offset += 3600
+	}
+	return time.FixedZone(formatNumericZone(offset), offset), nil
+}
+
+// twoDigitYear returns the year of the century y in the years 1938
+// to 2037.
+func twoDigitYear(y int64) int64 {
+	if y < 38 {
+		return 2000 + y
+	}
+	return 1900 + y
+}
+
+// formatScan scans s according to the clock scan format format,
+// taking the fields it lacks from the time base in loc.
+func formatScan(s, format string, base int64, loc *time.Location) (int64, error) {
+	sc := &formatScanner{}
+	sc.re.WriteString("(?i)^")
+	sc.compile(format)
+	sc.re.WriteString("$")
+	re, err := regexp.Compile(sc.re.String())
+	if err != nil {
+		return 0, err
+	}
+	m := re.FindStringSubmatch(s)
+	if m == nil {
+		return 0, errBadInputString
+	}
+	f := scanFields{}
+	for i, set := range sc.setters {
+		if err := set(f, m[i+1]); err != nil {
+			return 0, err
+		}
+	}
+	if seconds, ok := f["seconds"]; ok {
+		return seconds, nil
+	}
+	if sc.zone != nil {
+		loc = sc.zone
+	}
+	date := newClockDate(base, loc, jdGregorianChange)
+	var jd int64
+	switch {
+	case f.has("julianDay"):
+		jd = f["julianDay"]
+	case f.has("century", "yearOfCentury", "month", "dayOfMonth"):
+		jd = julianDayOf(100*f["century"]+f["yearOfCentury"], int(f["month"]), f["dayOfMonth"], jdGregorianChange)
+	case f.has("century", "yearOfCentury", "dayOfYear"):
+		jd = julianDayOf(100*f["century"]+f["yearOfCentury"], 1, f["dayOfYear"], jdGregorianChange)
+	case f.has("iso8601Century", "iso8601YearOfCentury", "iso8601Week", "dayOfWeek"):
+		jd = julianDayOfWeek(100*f["iso8601Century"]+f["iso8601YearOfCentury"], int(f["iso8601Week"]), int(f["dayOfWeek"]), jdGregorianChange)
+	case f.has("yearOfCentury", "month", "dayOfMonth"):
+		jd = julianDayOf(twoDigitYear(f["yearOfCentury"]), int(f["month"]), f["dayOfMonth"], jdGregorianChange)
+	case f.has("yearOfCentury", "dayOfYear"):
+		jd = julianDayOf(twoDigitYear(f["yearOfCentury"]), 1, f["dayOfYear"], jdGregorianChange)
+	case f.has("iso8601YearOfCentury", "iso8601Week", "dayOfWeek"):
+		jd = julianDayOfWeek(twoDigitYear(f["iso8601YearOfCentury"]), int(f["iso8601Week"]), int(f["dayOfWeek"]), jdGregorianChange)
+	case f.has("month", "dayOfMonth"):
+		jd = julianDayOf(date.year, int(f["month"]), f["dayOfMonth"], jdGregorianChange)
+	case f.has("dayOfYear"):
+		jd = julianDayOf(date.year, 1, f["dayOfYear"], jdGregorianChange)
+	case f.has("iso8601Week", "dayOfWeek"):
+		jd = julianDayOfWeek(date.iso8601Year, int(f["iso8601Week"]), int(f["dayOfWeek"]), jdGregorianChange)
+	case f.has("dayOfMonth"):
+		jd = julianDayOf(date.year, date.month, f["dayOfMonth"], jdGregorianChange)
+	case f.has("dayOfWeek"):
+		jd = julianDayOfWeek(date.iso8601Year, date.iso8601Week, int(f["dayOfWeek"]), jdGregorianChange)
+	default:
+		jd = date.julianDay
+	}
+	hour := f["hour"]
+	if !f.has("hour") && f.has("hourAMPM", "amPmIndicator") {
+		hour = f["hourAMPM"]%12 + 12*f["amPmIndicator"]
+	}
+	secondOfDay := 3600*hour + 60*f["minute"] + f["second"]
+	return localToUTC(localSecondsOf(jd, secondOfDay), loc), nil
+}
@@ -0,0 +1,424 @@
+package gotcl
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var arraySubcommands = []subcommand{
+	{"anymore", cmdArrayAnymore},
+	{"donesearch", cmdArrayDoneSearch},
+	{"exists", cmdArrayExists},
+	{"get", cmdArrayGet},
+	{"names", cmdArrayNames},
+	{"nextelement", cmdArrayNextElement},
+	{"set", cmdArraySet},
+	{"size", cmdArraySize},
+	{"startsearch", cmdArrayStartSearch},
+	{"statistics", cmdArrayStatistics},
+	{"unset", cmdArrayUnset},
+}
+
+// array option arrayName ?arg arg ...?
+//
+// This command performs one of several operations on the variable
+// given by arrayName.
+func cmdArray(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) >= 3 {
+		if err := interp.traceArray(args[2].String()); err != nil {
+			return nil, err
+		}
+	}
+	return dispatchSubcommand(interp, args, arraySubcommands)
+}
+
+// lookupArray returns the array variable called name, or nil if name
+// does not exist or is not an array.
+func (interp *Interp) lookupArray(name *Value) *variable {
+	vr := interp.lookupVar(name.String())
+	if vr == nil || vr.array == nil {
+		return nil
+	}
+	return vr
+}
+
+// mustLookupArray is like lookupArray but returns an error if name
+// is not an array.
+func (interp *Interp) mustLookupArray(name *Value) (*variable, error) {
+	vr := interp.lookupArray(name)
+	if vr == nil {
+		return nil, fmt.Errorf("\"%s\" isn't an array", name)
+	}
+	return vr, nil
+}
+
+// sortedNames returns the names of the defined elements of the
+// array vr in a stable order.
+func (vr *variable) sortedNames() []string {
+	names := make([]string, 0, len(vr.array))
+	for name, elem := range vr.array {
+		if elem.value != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+var arrayMatchModes = []string{"-exact", "-glob", "-regexp"}
+
+// arrayMatcher returns a function reporting whether an element name
+// matches the optional ?mode? ?pattern? arguments of array get, names
+// and unset, which begin at args[3].
+func arrayMatcher(args []*Value) (func(string) bool, error) {
+	switch len(args) {
+	case 3:
+		return func(string) bool { return true }, nil
+	case 4:
+		pattern := args[3].Runes()
+		return func(name string) bool {
+			return stringMatch(pattern, []rune(name), false)
+		}, nil
+	}
+	mode, err := lookupIndex(arrayMatchModes, "option", args[3])
+	if err != nil {
+		return nil, err
+	}
+	pattern := args[4]
+	switch arrayMatchModes[mode] {
+	case "-exact":
+		return func(name string) bool { return name == pattern.String() }, nil
+	case "-regexp":
+		re, err := pattern.regexp(0)
+		if err != nil {
+			return nil, err
+		}
+		return re.matchString, nil
+	}
+	return func(name string) bool {
+		return stringMatch(pattern.Runes(), []rune(name), false)
+	}, nil
+}
+
+// array exists arrayName
+//
+// Returns 1 if arrayName is an array variable, 0 if there is no
+// variable by that name or if it is a scalar variable.
+func cmdArrayExists(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "arrayName")
+	}
+	return NewBoolValue(interp.lookupArray(args[2]) != nil), nil
+}
+
+// array get arrayName ?mode? ?pattern?
+//
+// Returns a list containing pairs of elements. The first element in
+// each pair is the name of an element in arrayName and the second
+// element of each pair is the value of the array element. If pattern
+// is specified, only those elements whose names match pattern, using
+// the matching style given by mode, are included. If arrayName is
+// not the name of an array variable, or if the array contains no
+// elements, then an empty list is returned.
+func cmdArrayGet(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 || len(args) > 5 {
+		return nil, wrongNumArgs(args, 2, "arrayName ?mode? ?pattern?")
+	}
+	match, err := arrayMatcher(args)
+	if err != nil {
+		return nil, err
+	}
+	var out []*Value
+	if vr := interp.lookupArray(args[2]); vr != nil {
+		for _, name := range vr.sortedNames() {
+			if match(name) {
+				out = append(out, NewStringValue(name), vr.array[name].value)
+			}
+		}
+	}
+	return NewListValue(out), nil
+}
+
+// array names arrayName ?mode? ?pattern?
+//
+// Returns a list containing the names of all of the elements in the
+// array that match pattern. Mode may be one of -exact, -glob, or
+// -regexp. If specified, mode designates which matching rules to use
+// when matching pattern against the names of the elements in the
+// array. If not specified, mode defaults to -glob.
+func cmdArrayNames(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 || len(args) > 5 {
+		return nil, wrongNumArgs(args, 2, "arrayName ?mode? ?pattern?")
+	}
+	match, err := arrayMatcher(args)
+	if err != nil {
+		return nil, err
+	}
+	var out []*Value
+	if vr := interp.lookupArray(args[2]); vr != nil {
+		for _, name := range vr.sortedNames() {
+			if match(name) {
+				out = append(out, NewStringValue(name))
+			}
+		}
+	}
+	return NewListValue(out), nil
+}
+
+// array set arrayName list
+//
+// Sets the values of one or more elements in arrayName. list must
+// have a form like that returned by array get, consisting of an even
+// number of elements. If the variable arrayName does not already
+// exist and list is empty, arrayName is created with an empty array
+// value.
+func cmdArraySet(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "arrayName list")
+	}
+	elems, err := args[3].List()
+	if err != nil {
+		return nil, err
+	}
+	if len(elems)%2 != 0 {
+		return nil, fmt.Errorf("list must have an even number of elements")
+	}
+	name := args[2].String()
+	if _, _, isElem := splitVarName(name); isElem {
+		return nil, fmt.Errorf("can't array set \"%s\": variable isn't array", name)
+	}
+	vr := interp.createVar(name)
+	if vr.value != nil {
+		return nil, fmt.Errorf("can't array set \"%s\": variable isn't array", name)
+	}
+	if vr.array == nil {
+		vr.array = map[string]*variable{}
+	}
+	for i := 0; i < len(elems); i += 2 {
+		if _, err := interp.setVar2(name, elems[i].String(), true, elems[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// array size arrayName
+//
+// Returns a decimal string giving the number of elements in the
+// array. If arrayName is not the name of an array then 0 is returned.
+func cmdArraySize(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "arrayName")
+	}
+	n := 0
+	if vr := interp.lookupArray(args[2]); vr != nil {
+		n = len(vr.sortedNames())
+	}
+	return NewIntValue(int64(n)), nil
+}
+
+// array unset arrayName ?mode? ?pattern?
+//
+// Unsets all of the elements in the array that match pattern. If
+// arrayName is not the name of an array variable or there are no
+// matching elements in the array, no error will be raised. If
+// pattern is omitted, the entire array is unset.
+func cmdArrayUnset(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 || len(args) > 5 {
+		return nil, wrongNumArgs(args, 2, "arrayName ?mode? ?pattern?")
+	}
+	vr := interp.lookupArray(args[2])
+	if vr == nil {
+		return nil, nil
+	}
+	if len(args) == 3 {
+		return nil, interp.unsetVar2(args[2].String(), "", false)
+	}
+	match, err := arrayMatcher(args)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range vr.sortedNames() {
+		if match(name) {
+			if err := interp.unsetVar2(args[2].String(), name, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, nil
+}
+
+// An arraySearch is an element-by-element search through an array
+// started by array startsearch.
+type arraySearch struct {
+	names []string
+	pos   int
+}
+
+// next returns the name of the next element of vr in the search, or
+// false if there are no more. Elements that have been unset since
+// the search was started are skipped.
+func (s *arraySearch) next(vr *variable, advance bool) (string, bool) {
+	for pos := s.pos; pos < len(s.names); pos++ {
+		if elem, ok := vr.array[s.names[pos]]; ok && elem.value != nil {
+			if advance {
+				s.pos = pos + 1
+			}
+			return s.names[pos], true
+		}
+	}
+	return "", false
+}
+
+// array startsearch arrayName
+//
+// This command initializes an element-by-element search through the
+// array given by arrayName, such that invocations of the array
+// nextelement command will return the names of the individual
+// elements in the array. The return value is a search identifier
+// that must be used in array nextelement and array donesearch
+// commands.
+func cmdArrayStartSearch(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "arrayName")
+	}
+	vr, err := interp.mustLookupArray(args[2])
+	if err != nil {
+		return nil, err
+	}
+	if vr.searches == nil {
+		vr.searches = map[string]*arraySearch{}
+	}
+	vr.nextSearch++
+	id := fmt.Sprintf("s-%d-%s", vr.nextSearch, args[2])
+	vr.searches[id] = &arraySearch{names: vr.sortedNames()}
+	return NewStringValue(id), nil
+}
+
+// lookupSearch returns the array and the search named by the
+// arrayName and searchId arguments of array anymore, nextelement and
+// donesearch.
+func (interp *Interp) lookupSearch(args []*Value) (*variable, *arraySearch, error) {
+	if len(args) != 4 {
+		return nil, nil, wrongNumArgs(args, 2, "arrayName searchId")
+	}
+	vr, err := interp.mustLookupArray(args[2])
+	if err != nil {
+		return nil, nil, err
+	}
+	id := args[3].String()
+	parts := strings.SplitN(id, "-", 3)
+	if len(parts) != 3 || parts[0] != "s" {
+		return nil, nil, fmt.Errorf("illegal search identifier \"%s\"", id)
+	}
+	if _, err := strconv.Atoi(parts[1]); err != nil {
+		return nil, nil, fmt.Errorf("illegal search identifier \"%s\"", id)
+	}
+	if parts[2] != args[2].String() {
+		return nil, nil, fmt.Errorf("search identifier \"%s\" isn't for variable \"%s\"", id, args[2])
+	}
+	s, ok := vr.searches[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("couldn't find search \"%s\"", id)
+	}
+	return vr, s, nil
+}
+
+// array anymore arrayName searchId
+//
+// Returns 1 if there are any more elements left to be processed in
+// an array search, 0 if all elements have already been returned.
+func cmdArrayAnymore(interp *Interp, args []*Value) (*Value, error) {
+	vr, s, err := interp.lookupSearch(args)
+	if err != nil {
+		return nil, err
+	}
+	_, ok := s.next(vr, false)
+	return NewBoolValue(ok), nil
+}
+
+// array nextelement arrayName searchId
+//
+// Returns the name of the next element in arrayName, or an empty
+// string if all elements of arrayName have already been returned in
+// this search.
+func cmdArrayNextElement(interp *Interp, args []*Value) (*Value, error) {
+	vr, s, err := interp.lookupSearch(args)
+	if err != nil {
+		return nil, err
+	}
+	name, _ := s.next(vr, true)
+	return NewStringValue(name), nil
+}
+
+// array donesearch arrayName searchId
+//
+// This command terminates an array search and destroys all the state
+// associated with that search. It returns an empty string.
+func cmdArrayDoneSearch(interp *Interp, args []*Value) (*Value, error) {
+	vr, _, err := interp.lookupSearch(args)
+	if err != nil {
+		return nil, err
+	}
+	delete(vr.searches, args[3].String())
+	return nil, nil
+}
+
+// array statistics arrayName
+//
+// Returns statistics about the distribution of data within the
+// hashtable that represents the array, in the format used by Tcl.
+// The bucket distribution is that of a Tcl hash table holding the
+// same keys.
+func cmdArrayStatistics(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "arrayName")
+	}
+	vr, err := interp.mustLookupArray(args[2])
+	if err != nil {
+		return nil, err
+	}
+	return NewStringValue(hashStats(vr.sortedNames())), nil
+}
+
+// hashStats describes how keys would be distributed among the
+// buckets of a Tcl hash table, which starts with 4 buckets and grows
+// by a factor of 4 whenever it holds 3 entries per bucket.
+func hashStats(keys []string) string {
+	const numCounters = 10
+	buckets := 4
+	for len(keys) >= buckets*3 {
+		buckets *= 4
+	}
+	sizes := make([]int, buckets)
+	for _, key := range keys {
+		var h uint32
+		for i := 0; i < len(key); i++ {
+			h += (h << 3) + uint32(key[i])
+		}
+		sizes[h&uint32(buckets-1)]++
+	}
+	var count [numCounters]int
+	overflow := 0
+	average := 0.0
+	for _, n := range sizes {
+		if n < numCounters {
+			count[n]++
+		} else {
+			overflow++
+		}
+		if len(keys) > 0 {
+			average += (float64(n) + 1) * (float64(n) / float64(len(keys))) / 2
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d entries in table, %d buckets\n", len(keys), buckets)
+	for i, n := range count {
+		fmt.Fprintf(&b, "number of buckets with %d entries: %d\n", i, n)
+	}
+	fmt.Fprintf(&b, "number of buckets with %d or more entries: %d\n", numCounters, overflow)
+	fmt.Fprintf(&b, "average search distance for entry: %.1f", average)
+	return b.String()
+}
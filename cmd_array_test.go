@@ -0,0 +1,72 @@
+package gotcl
+
+import (
+	"testing"
+)
+
+func TestArrayVariables(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `set a(x) 1`, result: "1"},
+		{script: `set a(y) 2; set a(x)`, result: "1"},
+		{script: `set k y; set a($k)`, result: "2"},
+		{script: `string cat $a(x) $a($k)`, result: "12"},
+		{script: `set a()`, err: `can't read "a()": no such element in array`},
+		{script: `set a() empty; set a()`, result: "empty"},
+		{script: `set a`, err: `can't read "a": variable is array`},
+		{script: `string cat $a`, err: `can't read "a": variable is array`},
+		{script: `set a 1`, err: `can't set "a": variable is array`},
+		{script: `set a(z)`, err: `can't read "a(z)": no such element in array`},
+		{script: `set s 1; set s(x)`, err: `can't read "s(x)": variable isn't array`},
+		{script: `set s(x) 1`, err: `can't set "s(x)": variable isn't array`},
+		{script: `unset s(x)`, err: `can't unset "s(x)": variable isn't array`},
+		{script: `unset a(z)`, err: `can't unset "a(z)": no such element in array`},
+		{script: `lappend a(l) p q`, result: "p q"},
+		{script: `unset a(l) a()`, result: ""},
+		{script: `unset a; set a(x)`, err: `can't read "a(x)": no such variable`},
+	})
+	if err := interp.SetVar("g", "k", "v"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := interp.GetVar("g", "k"); err != nil || v != "v" {
+		t.Errorf("GetVar(g, k) = %q, %v; want \"v\"", v, err)
+	}
+}
+
+func TestArrayCommand(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `array set a {b 2 a 1 c 3 ab 4}`, result: ""},
+		{script: `array size a`, result: "4"},
+		{script: `array names a`, result: "a ab b c"},
+		{script: `array names a a*`, result: "a ab"},
+		{script: `array names a -exact a`, result: "a"},
+		{script: `array names a -regexp {^[bc]$}`, result: "b c"},
+		{script: `array names a -bogus x`, err: `bad option "-bogus": must be -exact, -glob, or -regexp`},
+		{script: `array get a a*`, result: "a 1 ab 4"},
+		{script: `array get a -exact b`, result: "b 2"},
+		{script: `array exists a`, result: "1"},
+		{script: `array exists nosuch`, result: "0"},
+		{script: `array size nosuch`, result: "0"},
+		{script: `array get nosuch`, result: ""},
+		{script: `array set a {x}`, err: `list must have an even number of elements`},
+		{script: `set sc 1; array set sc {a 1}`, err: `can't array set "sc": variable isn't array`},
+		{script: `array exists sc`, result: "0"},
+		{script: `array unset a -glob a*; array names a`, result: "b c"},
+		{script: `array set e {}; array exists e`, result: "1"},
+		{script: `set id [array startsearch a]`, result: "s-1-a"},
+		{script: `array anymore a $id`, result: "1"},
+		{script: `array nextelement a $id`, result: "b"},
+		{script: `array nextelement a $id`, result: "c"},
+		{script: `array anymore a $id`, result: "0"},
+		{script: `array nextelement a $id`, result: ""},
+		{script: `array donesearch a $id`, result: ""},
+		{script: `array nextelement a $id`, err: `couldn't find search "s-1-a"`},
+		{script: `array nextelement a bogus`, err: `illegal search identifier "bogus"`},
+		{script: `array nextelement e s-1-a`, err: `search identifier "s-1-a" isn't for variable "e"`},
+		{script: `array startsearch sc`, err: `"sc" isn't an array`},
+		{script: `array statistics a`, result: "2 entries in table, 4 buckets\nnumber of buckets with 0 entries: 2\nnumber of buckets with 1 entries: 2\nnumber of buckets with 2 entries: 0\nnumber of buckets with 3 entries: 0\nnumber of buckets with 4 entries: 0\nnumber of buckets with 5 entries: 0\nnumber of buckets with 6 entries: 0\nnumber of buckets with 7 entries: 0\nnumber of buckets with 8 entries: 0\nnumber of buckets with 9 entries: 0\nnumber of buckets with 10 or more entries: 0\naverage search distance for entry: 1.0"},
+		{script: `array unset a; array exists a`, result: "0"},
+		{script: `array bogus a`, err: `unknown or ambiguous subcommand "bogus": must be anymore, donesearch, exists, get, names, nextelement, set, size, startsearch, statistics, or unset`},
+	})
+}
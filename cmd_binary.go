@@ -0,0 +1,875 @@
+package gotcl
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+)
+
+var binarySubcommands = []subcommand{
+	{"decode", cmdBinaryDecode},
+	{"encode", cmdBinaryEncode},
+	{"format", cmdBinaryFormat},
+	{"scan", cmdBinaryScan},
+}
+
+var binaryEncodeSubcommands = []subcommand{
+	{"base64", cmdBinaryEncodeBase64},
+	{"hex", cmdBinaryEncodeHex},
+	{"uuencode", cmdBinaryEncodeUuencode},
+}
+
+var binaryDecodeSubcommands = []subcommand{
+	{"base64", cmdBinaryDecodeBase64},
+	{"hex", cmdBinaryDecodeHex},
+	{"uuencode", cmdBinaryDecodeUuencode},
+}
+
+// binary subcommand ?arg ...?
+//
+// This command provides facilities for manipulating binary data. The
+// subcommand binary format creates a binary string from normal Tcl
+// values, and binary scan does the reverse, extracting data from a
+// binary string and returning it as ordinary Tcl values. The binary
+// encode and binary decode subcommands convert binary data to and
+// from string encodings such as base64, commonly used in emails and
+// other protocols.
+func cmdBinary(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "subcommand ?arg ...?")
+	}
+	return dispatchSubcommand(interp, args, binarySubcommands)
+}
+
+// Special counts of a binary field specifier.
+const (
+	binaryNoCount = -1 // no count was given
+	binaryAll     = -2 // the count was *
+)
+
+// A binaryField is one field specifier of the format string of binary
+// format or binary scan: a type letter, optionally followed by u and
+// by a count.
+type binaryField struct {
+	typ      rune
+	unsigned bool
+	count    int
+}
+
+// nextBinaryField returns the field specifier that starts at or after
+// format[i], skipping white space, with the index of the rest of the
+// format. It reports false at the end of format.
+func nextBinaryField(format string, i int) (binaryField, int, bool) {
+	for i < len(format) && isSpaceByte(format[i]) {
+		i++
+	}
+	if i == len(format) {
+		return binaryField{}, i, false
+	}
+	typ, size := utf8.DecodeRuneInString(format[i:])
+	f := binaryField{typ: typ, count: binaryNoCount}
+	i += size
+	if i < len(format) && format[i] == 'u' {
+		f.unsigned = true
+		i++
+	}
+	switch {
+	case i < len(format) && format[i] == '*':
+		f.count = binaryAll
+		i++
+	case i < len(format) && isDigitByte(format[i]):
+		f.count = 0
+		for ; i < len(format) && isDigitByte(format[i]); i++ {
+			f.count = min(f.count*10+int(format[i]-'0'), math.MaxInt32)
+		}
+	}
+	return f, i, true
+}
+
+func isSpaceByte(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\v', '\f', '\r':
+		return true
+	}
+	return false
+}
+
+func isDigitByte(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// binaryNumberSize returns the size in bytes of the numbers of the
+// field type typ, or 0 if typ is not a numeric type.
+func binaryNumberSize(typ rune) int {
+	switch typ {
+	case 'c':
+		return 1
+	case 's', 'S', 't':
+		return 2
+	case 'i', 'I', 'n', 'f', 'r', 'R':
+		return 4
+	case 'w', 'W', 'm', 'd', 'q', 'Q':
+		return 8
+	}
+	return 0
+}
+
+// binaryByteOrder returns the byte order of the numbers of the field
+// type typ.
+func binaryByteOrder(typ rune) binary.ByteOrder {
+	switch typ {
+	case 's', 'i', 'w', 'r', 'q':
+		return binary.LittleEndian
+	case 'S', 'I', 'W', 'R', 'Q':
+		return binary.BigEndian
+	}
+	return binary.NativeEndian
+}
+
+var (
+	errBinaryArgs    = errors.New("not enough arguments for all format specifiers")
+	errBinaryCount   = errors.New("number of elements in list does not match count")
+	errBinaryAtCount = errors.New("missing count for \"@\" field specifier")
+	maxUint64        = new(big.Int).SetUint64(math.MaxUint64)
+)
+
+func badBinaryField(typ rune) error {
+	return fmt.Errorf("bad field specifier \"%c\"", typ)
+}
+
+// binary format formatString ?arg arg ...?
+//
+// Returns a binary string whose layout is specified by formatString
+// and whose contents come from the additional arguments. Each field
+// specifier of formatString is a type letter, optionally followed by
+// u, which binary format ignores, and by a count or *: a and A store
+// bytes padded with nulls or spaces; b and B binary digits and h and
+// H hexadecimal digits, low or high first in each byte; c, s, S, t,
+// i, I, n, w, W and m 8, 16, 32 and 64-bit integers, and f, r, R, d,
+// q and Q single and double precision floating-point numbers, in
+// little-endian, big-endian or native byte order; x null bytes; and
+// X and @ move the cursor backwards or to an absolute position. The
+// numeric types take a list of count numbers if a count is given.
+func cmdBinaryFormat(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 2, "formatString ?arg ...?")
+	}
+	format := args[2].String()
+	values := args[3:]
+	next := func() (*Value, error) {
+		if len(values) == 0 {
+			return nil, errBinaryArgs
+		}
+		v := values[0]
+		values = values[1:]
+		return v, nil
+	}
+	var buf []byte
+	pos := 0
+	// field returns the n bytes at the cursor, which it moves past
+	// them, growing buf as needed.
+	field := func(n int) []byte {
+		if end := pos + n; end > len(buf) {
+			buf = append(buf, make([]byte, end-len(buf))...)
+		}
+		pos += n
+		return buf[pos-n : pos]
+	}
+	for i := 0; ; {
+		f, j, ok := nextBinaryField(format, i)
+		if !ok {
+			break
+		}
+		i = j
+		switch f.typ {
+		case 'a', 'A':
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			data, err := v.Bytes()
+			if err != nil {
+				return nil, err
+			}
+			n := f.count
+			switch n {
+			case binaryNoCount:
+				n = 1
+			case binaryAll:
+				n = len(data)
+			}
+			b := field(n)
+			pad := b[copy(b, data):]
+			if f.typ == 'A' {
+				for k := range pad {
+					pad[k] = ' '
+				}
+			} else {
+				clear(pad)
+			}
+		case 'b', 'B', 'h', 'H':
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			digits := v.String()
+			n := f.count
+			switch n {
+			case binaryNoCount:
+				n = 1
+			case binaryAll:
+				n = len(digits)
+			}
+			perByte := 8
+			if f.typ == 'h' || f.typ == 'H' {
+				perByte = 2
+			}
+			b := field((n + perByte - 1) / perByte)
+			clear(b)
+			if err := packDigits(b, digits, n, f.typ); err != nil {
+				return nil, err
+			}
+		case 'c', 's', 'S', 't', 'i', 'I', 'n', 'w', 'W', 'm', 'f', 'r', 'R', 'd', 'q', 'Q':
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			elems := []*Value{v}
+			if f.count != binaryNoCount {
+				if elems, err = v.List(); err != nil {
+					return nil, err
+				}
+				if f.count != binaryAll {
+					if f.count > len(elems) {
+						return nil, errBinaryCount
+					}
+					elems = elems[:f.count]
+				}
+			}
+			size := binaryNumberSize(f.typ)
+			for _, elem := range elems {
+				if err := putBinaryNumber(field(size), f.typ, elem); err != nil {
+					return nil, err
+				}
+			}
+		case 'x':
+			switch f.count {
+			case binaryNoCount:
+				f.count = 1
+			case binaryAll:
+				return nil, errors.New("cannot use \"*\" in format string with \"x\"")
+			}
+			clear(field(f.count))
+		case 'X':
+			switch f.count {
+			case binaryNoCount:
+				f.count = 1
+			case binaryAll:
+				f.count = pos
+			}
+			pos = max(pos-f.count, 0)
+		case '@':
+			switch f.count {
+			case binaryNoCount:
+				return nil, errBinaryAtCount
+			case binaryAll:
+				f.count = len(buf)
+			}
+			pos = 0
+			field(f.count)
+		default:
+			return nil, badBinaryField(f.typ)
+		}
+	}
+	if buf == nil {
+		buf = []byte{}
+	}
+	return NewBytesValue(buf), nil
+}
+
+// packDigits stores up to n of the binary or hexadecimal digits in
+// b, a field of type typ.
+func packDigits(b []byte, digits string, n int, typ rune) error {
+	for k := 0; k < n && k < len(digits); k++ {
+		c := digits[k]
+		switch typ {
+		case 'b', 'B':
+			if c != '0' && c != '1' {
+				return fmt.Errorf("expected binary string but got \"%s\" instead", digits)
+			}
+			shift := k % 8
+			if typ == 'B' {
+				shift = 7 - shift
+			}
+			b[k/8] |= (c - '0') << shift
+		default:
+			d, ok := hexDigitValue(c)
+			if !ok {
+				return fmt.Errorf("expected hexadecimal string but got \"%s\" instead", digits)
+			}
+			shift := 4 * (k % 2)
+			if typ == 'H' {
+				shift = 4 - shift
+			}
+			b[k/2] |= d << shift
+		}
+	}
+	return nil
+}
+
+func hexDigitValue(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// putBinaryNumber stores the number v in b, a field of the numeric
+// type typ. Integers are truncated to the size of the field, and
+// numbers too large for a single-precision field are stored as the
+// largest one.
+func putBinaryNumber(b []byte, typ rune, v *Value) error {
+	order := binaryByteOrder(typ)
+	switch typ {
+	case 'f', 'r', 'R':
+		d, err := v.Double()
+		if err != nil {
+			return err
+		}
+		if !math.IsInf(d, 0) && math.Abs(d) > math.MaxFloat32 {
+			d = math.Copysign(math.MaxFloat32, d)
+		}
+		order.PutUint32(b, math.Float32bits(float32(d)))
+		return nil
+	case 'd', 'q', 'Q':
+		d, err := v.Double()
+		if err != nil {
+			return err
+		}
+		order.PutUint64(b, math.Float64bits(d))
+		return nil
+	}
+	var n uint64
+	if i, err := v.Int(); err == nil {
+		n = uint64(i)
+	} else {
+		i, err := v.BigInt()
+		if err != nil {
+			return err
+		}
+		n = new(big.Int).And(i, maxUint64).Uint64()
+	}
+	switch len(b) {
+	case 1:
+		b[0] = byte(n)
+	case 2:
+		order.PutUint16(b, uint16(n))
+	case 4:
+		order.PutUint32(b, uint32(n))
+	default:
+		order.PutUint64(b, n)
+	}
+	return nil
+}
+
+// binary scan string formatString ?varName varName ...?
+//
+// Parses fields from a binary string, storing each in the next
+// varName, and returns the number of variables set. formatString
+// has the field specifiers of binary format, with a and A
+// extracting bytes, the latter with trailing spaces and nulls
+// removed, and numeric types signed integers unless followed by u.
+// A count or * scans a list of numbers. Scanning stops at the first
+// field for which string holds too few bytes, leaving its variable
+// and any later ones unset.
+func cmdBinaryScan(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 4 {
+		return nil, wrongNumArgs(args, 2, "value formatString ?varName ...?")
+	}
+	data, err := args[2].Bytes()
+	if err != nil {
+		return nil, err
+	}
+	format := args[3].String()
+	varNames := args[4:]
+	set := 0
+	store := func(v *Value) error {
+		if _, err := interp.setVar(varNames[set].String(), v); err != nil {
+			return err
+		}
+		set++
+		return nil
+	}
+	pos := 0
+scan:
+	for i := 0; ; {
+		f, j, ok := nextBinaryField(format, i)
+		if !ok {
+			break
+		}
+		i = j
+		switch f.typ {
+		case 'a', 'A', 'b', 'B', 'h', 'H', 'c', 's', 'S', 't', 'i', 'I', 'n', 'w', 'W', 'm', 'f', 'r', 'R', 'd', 'q', 'Q':
+			if set == len(varNames) {
+				return nil, errBinaryArgs
+			}
+		}
+		left := len(data) - pos
+		switch f.typ {
+		case 'a', 'A':
+			n := f.count
+			switch n {
+			case binaryNoCount:
+				n = 1
+			case binaryAll:
+				n = left
+			}
+			if n > left {
+				break scan
+			}
+			b := data[pos : pos+n]
+			pos += n
+			if f.typ == 'A' {
+				for len(b) > 0 && (b[len(b)-1] == ' ' || b[len(b)-1] == 0) {
+					b = b[:len(b)-1]
+				}
+			}
+			if err := store(NewBytesValue(b)); err != nil {
+				return nil, err
+			}
+		case 'b', 'B', 'h', 'H':
+			perByte := 8
+			if f.typ == 'h' || f.typ == 'H' {
+				perByte = 2
+			}
+			n := f.count
+			switch n {
+			case binaryNoCount:
+				n = 1
+			case binaryAll:
+				n = left * perByte
+			}
+			size := (n + perByte - 1) / perByte
+			if size > left {
+				break scan
+			}
+			digits := unpackDigits(data[pos:pos+size], n, f.typ)
+			pos += size
+			if err := store(NewStringValue(digits)); err != nil {
+				return nil, err
+			}
+		case 'c', 's', 'S', 't', 'i', 'I', 'n', 'w', 'W', 'm', 'f', 'r', 'R', 'd', 'q', 'Q':
+			size := binaryNumberSize(f.typ)
+			var v *Value
+			if f.count == binaryNoCount {
+				if size > left {
+					break scan
+				}
+				v = binaryNumber(data[pos:pos+size], f.typ, f.unsigned)
+				pos += size
+			} else {
+				n := f.count
+				if n == binaryAll {
+					n = left / size
+				}
+				if n*size > left {
+					break scan
+				}
+				elems := make([]*Value, n)
+				for k := range elems {
+					elems[k] = binaryNumber(data[pos:pos+size], f.typ, f.unsigned)
+					pos += size
+				}
+				v = NewListValue(elems)
+			}
+			if err := store(v); err != nil {
+				return nil, err
+			}
+		case 'x':
+			switch f.count {
+			case binaryNoCount:
+				f.count = 1
+			case binaryAll:
+				f.count = left
+			}
+			pos += min(f.count, left)
+		case 'X':
+			switch f.count {
+			case binaryNoCount:
+				f.count = 1
+			case binaryAll:
+				f.count = pos
+			}
+			pos = max(pos-f.count, 0)
+		case '@':
+			switch f.count {
+			case binaryNoCount:
+				return nil, errBinaryAtCount
+			case binaryAll:
+				f.count = len(data)
+			}
+			pos = min(f.count, len(data))
+		default:
+			return nil, badBinaryField(f.typ)
+		}
+	}
+	return NewIntValue(int64(set)), nil
+}
+
+// unpackDigits returns the first n binary or hexadecimal digits of
+// b, a field of type typ.
+func unpackDigits(b []byte, n int, typ rune) string {
+	const hexDigits = "0123456789abcdef"
+	var s strings.Builder
+	for k := 0; k < n; k++ {
+		switch typ {
+		case 'b', 'B':
+			shift := k % 8
+			if typ == 'B' {
+				shift = 7 - shift
+			}
+			s.WriteByte('0' + b[k/8]>>shift&1)
+		default:
+			shift := 4 * (k % 2)
+			if typ == 'H' {
+				shift = 4 - shift
+			}
+			s.WriteByte(hexDigits[b[k/2]>>shift&0xf])
+		}
+	}
+	return s.String()
+}
+
+// binaryNumber returns the number stored in b, a field of the
+// numeric type typ.
+func binaryNumber(b []byte, typ rune, unsigned bool) *Value {
+	order := binaryByteOrder(typ)
+	switch typ {
+	case 'f', 'r', 'R':
+		return NewDoubleValue(float64(math.Float32frombits(order.Uint32(b))))
+	case 'd', 'q', 'Q':
+		return NewDoubleValue(math.Float64frombits(order.Uint64(b)))
+	}
+	switch len(b) {
+	case 1:
+		if unsigned {
+			return NewIntValue(int64(b[0]))
+		}
+		return NewIntValue(int64(int8(b[0])))
+	case 2:
+		if unsigned {
+			return NewIntValue(int64(order.Uint16(b)))
+		}
+		return NewIntValue(int64(int16(order.Uint16(b))))
+	case 4:
+		if unsigned {
+			return NewIntValue(int64(order.Uint32(b)))
+		}
+		return NewIntValue(int64(int32(order.Uint32(b))))
+	}
+	n := order.Uint64(b)
+	if unsigned && n > math.MaxInt64 {
+		return NewBigIntValue(new(big.Int).SetUint64(n))
+	}
+	return NewIntValue(int64(n))
+}
+
+// binary encode format ?-option value ...? data
+//
+// Converts binary data into an encoded string using the specified
+// format: base64, hex or uuencode.
+func cmdBinaryEncode(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 2, "subcommand ?arg ...?")
+	}
+	words := append([]*Value{NewStringValue("binary encode")}, args[2:]...)
+	return dispatchSubcommand(interp, words, binaryEncodeSubcommands)
+}
+
+// binaryEncodeOptions parses the -maxlen and -wrapchar options of
+// binary encode base64 and binary encode uuencode, whose defaults are
+// maxLen and "\n", and returns them with the data to encode.
+func binaryEncodeOptions(args []*Value, maxLen int) (int, string, []byte, error) {
+	if len(args)%2 != 1 {
+		return 0, "", nil, wrongNumArgs(args, 2, "?-maxlen len? ?-wrapchar char? data")
+	}
+	wrapChar := "\n"
+	for i := 2; i < len(args)-1; i += 2 {
+		opt, err := lookupIndex([]string{"-maxlen", "-wrapchar"}, "option", args[i])
+		if err != nil {
+			return 0, "", nil, err
+		}
+		if opt == 1 {
+			wrapChar = args[i+1].String()
+			continue
+		}
+		if maxLen, err = getInt(args[i+1]); err != nil {
+			return 0, "", nil, err
+		}
+	}
+	data, err := args[len(args)-1].Bytes()
+	return maxLen, wrapChar, data, err
+}
+
+var errLineLength = errors.New("line length out of range")
+
+// binary encode base64 ?-maxlen length? ?-wrapchar character? data
+//
+// Encodes data in base64. If -maxlen is given and is not zero, the
+// output is broken into lines of at most length characters separated
+// by -wrapchar, which defaults to a newline.
+func cmdBinaryEncodeBase64(interp *Interp, args []*Value) (*Value, error) {
+	maxLen, wrapChar, data, err := binaryEncodeOptions(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if maxLen < 0 {
+		return nil, errLineLength
+	}
+	s := base64.StdEncoding.EncodeToString(data)
+	if maxLen == 0 || wrapChar == "" {
+		return NewStringValue(s), nil
+	}
+	var b strings.Builder
+	for len(s) > maxLen {
+		b.WriteString(s[:maxLen])
+		b.WriteString(wrapChar)
+		s = s[maxLen:]
+	}
+	b.WriteString(s)
+	return NewStringValue(b.String()), nil
+}
+
+// binary encode hex data
+//
+// Encodes data as pairs of lowercase hexadecimal digits.
+func cmdBinaryEncodeHex(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "data")
+	}
+	data, err := args[2].Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return NewStringValue(hex.EncodeToString(data)), nil
+}
+
+// binary encode uuencode ?-maxlen length? ?-wrapchar character? data
+//
+// Encodes data with uuencode, in lines of at most length characters,
+// which defaults to 61 and must be between 5 and 85. Each line,
+// including the last, is ended by -wrapchar, which must be white
+// space containing a newline.
+func cmdBinaryEncodeUuencode(interp *Interp, args []*Value) (*Value, error) {
+	maxLen, wrapChar, data, err := binaryEncodeOptions(args, 61)
+	if err != nil {
+		return nil, err
+	}
+	if maxLen < 5 || maxLen > 85 {
+		return nil, errLineLength
+	}
+	if !strings.Contains(wrapChar, "\n") || strings.Trim(wrapChar, "\t\n\v\f\r") != "" {
+		return nil, errors.New("invalid wrapchar; will defeat decoding")
+	}
+	// Each line holds a length character and four characters for
+	// every three bytes.
+	perLine := (maxLen - 1) / 4 * 3
+	var b strings.Builder
+	for len(data) > 0 {
+		n := min(len(data), perLine)
+		b.WriteByte(uuDigit(n))
+		var acc, bits uint
+		for _, c := range data[:n] {
+			acc, bits = acc<<8|uint(c), bits+8
+			for ; bits >= 6; bits -= 6 {
+				b.WriteByte(uuDigit(int(acc >> (bits - 6) & 0x3f)))
+			}
+		}
+		if bits > 0 {
+			b.WriteByte(uuDigit(int(acc << (6 - bits) & 0x3f)))
+		}
+		b.WriteString(wrapChar)
+		data = data[n:]
+	}
+	return NewStringValue(b.String()), nil
+}
+
+func uuDigit(n int) byte {
+	if n == 0 {
+		return '`'
+	}
+	return byte(' ' + n)
+}
+
+// binary decode format ?-option value ...? data
+//
+// Converts formatted data back into binary data using the specified
+// format: base64, hex or uuencode.
+func cmdBinaryDecode(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 2, "subcommand ?arg ...?")
+	}
+	words := append([]*Value{NewStringValue("binary decode")}, args[2:]...)
+	return dispatchSubcommand(interp, words, binaryDecodeSubcommands)
+}
+
+// binaryDecodeOptions parses the -strict option of the binary decode
+// subcommands and returns it with the data to decode.
+func binaryDecodeOptions(args []*Value) (bool, []byte, error) {
+	if len(args) < 3 {
+		return false, nil, wrongNumArgs(args, 2, "?options? data")
+	}
+	strict := false
+	for _, arg := range args[2 : len(args)-1] {
+		if _, err := lookupIndex([]string{"-strict"}, "option", arg); err != nil {
+			return false, nil, err
+		}
+		strict = true
+	}
+	data, err := args[len(args)-1].Bytes()
+	return strict, data, err
+}
+
+// binary decode base64 ?-strict? data
+//
+// Decodes base64 data. White space is ignored unless -strict is
+// given, in which case a missing final padding is an error too.
+func cmdBinaryDecodeBase64(interp *Interp, args []*Value) (*Value, error) {
+	strict, data, err := binaryDecodeOptions(args)
+	if err != nil {
+		return nil, err
+	}
+	bad := func(i int) error {
+		return fmt.Errorf("invalid base64 character \"%c\" at position %d", data[i], i)
+	}
+	out := []byte{}
+	var group uint
+	n, pad := 0, 0
+	for i, c := range data {
+		var d byte
+		switch {
+		case c >= 'A' && c <= 'Z':
+			d = c - 'A'
+		case c >= 'a' && c <= 'z':
+			d = c - 'a' + 26
+		case c >= '0' && c <= '9':
+			d = c - '0' + 52
+		case c == '+':
+			d = 62
+		case c == '/':
+			d = 63
+		case c == '=' && n >= 2:
+			pad++
+		case isSpaceByte(c) && !strict:
+			continue
+		default:
+			return nil, bad(i)
+		}
+		if pad > 0 && c != '=' {
+			return nil, bad(i)
+		}
+		group, n = group<<6|uint(d), n+1
+		if n == 4 {
+			out = append(out, byte(group>>16), byte(group>>8), byte(group))
+			out = out[:len(out)-pad]
+			group, n, pad = 0, 0, 0
+		}
+	}
+	if n > 0 {
+		if strict {
+			return nil, bad(len(data) - 1)
+		}
+		group <<= 6 * (4 - n)
+		b := []byte{byte(group >> 16), byte(group >> 8)}
+		out = append(out, b[:(n-pad)*6/8]...)
+	}
+	return NewBytesValue(out), nil
+}
+
+// binary decode hex ?-strict? data
+//
+// Decodes pairs of hexadecimal digits, in either case. White space
+// is ignored unless -strict is given. A final unpaired digit is
+// ignored.
+func cmdBinaryDecodeHex(interp *Interp, args []*Value) (*Value, error) {
+	strict, data, err := binaryDecodeOptions(args)
+	if err != nil {
+		return nil, err
+	}
+	out := []byte{}
+	var value byte
+	n := 0
+	for i, c := range data {
+		d, ok := hexDigitValue(c)
+		if !ok {
+			if isSpaceByte(c) && !strict {
+				continue
+			}
+			return nil, fmt.Errorf("invalid hexadecimal digit \"%c\" at position %d", c, i)
+		}
+		value, n = value<<4|d, n+1
+		if n == 2 {
+			out = append(out, value)
+			value, n = 0, 0
+		}
+	}
+	return NewBytesValue(out), nil
+}
+
+// binary decode uuencode ?-strict? data
+//
+// Decodes uuencoded lines, each beginning with a character giving
+// its length in bytes. Other white space than newlines is ignored
+// unless -strict is given.
+func cmdBinaryDecodeUuencode(interp *Interp, args []*Value) (*Value, error) {
+	strict, data, err := binaryDecodeOptions(args)
+	if err != nil {
+		return nil, err
+	}
+	out := []byte{}
+	// want is the number of bytes still to be decoded from the
+	// current line, or -1 before its length character.
+	want := -1
+	var acc, bits uint
+	for i, c := range data {
+		switch {
+		case c == '\n':
+			for ; want > 0; want-- {
+				out = append(out, 0)
+			}
+			want, acc, bits = -1, 0, 0
+			continue
+		case c < ' ' || c > '`':
+			if isSpaceByte(c) && !strict {
+				continue
+			}
+			return nil, fmt.Errorf("invalid uuencode character \"%c\" at position %d", c, i)
+		}
+		d := uint(c-' ') & 0x3f
+		if want < 0 {
+			want = int(d)
+			continue
+		}
+		acc, bits = acc<<6|d, bits+6
+		if bits >= 8 && want > 0 {
+			bits -= 8
+			out = append(out, byte(acc>>bits))
+			want--
+		}
+	}
+	for ; want > 0; want-- {
+		out = append(out, 0)
+	}
+	return NewBytesValue(out), nil
+}
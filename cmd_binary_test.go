@@ -0,0 +1,113 @@
+package gotcl
+
+import (
+	"testing"
+)
+
+func TestBinaryFormat(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `binary encode hex [binary format a7A7a* alpha bravo charlie]`, result: "616c7068610000627261766f2020636861726c6965"},
+		{script: `binary encode hex [binary format b5b* 11100 111000011010]`, result: "078705"},
+		{script: `binary encode hex [binary format B5B* 11100 111000011010]`, result: "e0e1a0"},
+		{script: `binary encode hex [binary format h3h* AB def]`, result: "ba00ed0f"},
+		{script: `binary encode hex [binary format H3H* AB def]`, result: "ab00def0"},
+		{script: `binary encode hex [binary format c3cc* {3 -3 128 1} 260 {2 5}]`, result: "03fd80040205"},
+		{script: `binary encode hex [binary format s3S3t {3 -3 258 1} {3 -3 258} 1]`, result: "0300fdff02010003fffd01020100"},
+		{script: `binary encode hex [binary format i2In {3 -3} 65536 1]`, result: "03000000fdffffff0001000001000000"},
+		{script: `binary encode hex [binary format w2Wm {3 -3} 18446744073709551615 1]`, result: "0300000000000000fdffffffffffffffffffffffffffffff0100000000000000"},
+		{script: `binary encode hex [binary format cusuiu 255 65535 4294967295]`, result: "ffffffffffffff"},
+		{script: `binary encode hex [binary format f2 {1.6 3.4}]`, result: "cdcccc3f9a995940"},
+		{script: `binary encode hex [binary format rR 1.6 1e40]`, result: "cdcccc3f7f7fffff"},
+		{script: `binary encode hex [binary format dqQ 1.6 1.6 1.6]`, result: "9a9999999999f93f9a9999999999f93f3ff999999999999a"},
+		{script: `binary encode hex [binary format a3X*a3x3X2a3 abc def ghi]`, result: "64656600676869"},
+		{script: `binary encode hex [binary format a@5a abc x]`, result: "610000000078"},
+		{script: `binary encode hex [binary format {a*  @1 a} abcdef X]`, result: "615863646566"},
+		{script: `string length [binary format x3a0@* ignored]`, result: "3"},
+
+		{script: `binary format a3 Ā`, err: "expected byte sequence but character 0 was 'Ā' (U+000100)"},
+		{script: `binary format b 2`, err: `expected binary string but got "2" instead`},
+		{script: `binary format H4 zz`, err: `expected hexadecimal string but got "zz" instead`},
+		{script: `binary format c2 1`, err: "number of elements in list does not match count"},
+		{script: `binary format ac x`, err: "not enough arguments for all format specifiers"},
+		{script: `binary format z 1`, err: `bad field specifier "z"`},
+		{script: `binary format x*`, err: `cannot use "*" in format string with "x"`},
+		{script: `binary format @`, err: `missing count for "@" field specifier`},
+		{script: `binary format i x`, err: `expected integer but got "x"`},
+		{script: `binary format`, err: `wrong # args: should be "binary format formatString ?arg ...?"`},
+		{script: `binary bogus`, err: `unknown or ambiguous subcommand "bogus": must be decode, encode, format, or scan`},
+	})
+}
+
+func TestBinaryScan(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `list [binary scan abcde\000fghi a6a10 var1 var2] [string length $var1] [info exists var2]`, result: "1 6 0"},
+		{script: `list [binary scan \x07\x87\x05 b5b* var1 var2] $var1 $var2`, result: "2 11100 1110000110100000"},
+		{script: `list [binary scan \x07\x87\x05 B5B* var1 var2] $var1 $var2`, result: "2 00000 1000011100000101"},
+		{script: `list [binary scan \x07\x87\x05 h3H* var1 var2] $var1 $var2`, result: "2 707 05"},
+		{script: `list [binary scan \x05\x00\xfc\xff\x01\x02 s3s v1 v2] $v1 [info exists v2]`, result: "1 {5 -4 513} 0"},
+		{script: `list [binary scan \x00\x05\xff\xfc\x01\x02 S2t var1 var2] $var1 $var2`, result: "2 {5 -4} 513"},
+		{script: `list [binary scan \xff\xff\xff\xff\xff\xff\xff\xff cucsu a b c] $a $b $c`, result: "3 255 -1 65535"},
+		{script: `list [binary scan \xff\xff\xff\xff\xff\xff\xff\xff iuI a b] $a $b`, result: "2 4294967295 -1"},
+		{script: `list [binary scan \xff\xff\xff\xff\xff\xff\xff\xff wWu w1 w2] $w1 [info exists w2]`, result: "1 -1 0"},
+		{script: `list [binary scan \xff\xff\xff\xff\xff\xff\xff\xff Wu a] $a`, result: "1 18446744073709551615"},
+		{script: `list [binary scan [binary format n2m {1 -2} 3] n2m a b] $a $b`, result: "2 {1 -2} 3"},
+		{script: `list [binary scan [binary format f2 {1.6 3.4}] f* x] $x`, result: "1 {1.600000023841858 3.4000000953674316}"},
+		{script: `list [binary scan [binary format rRdqQ 1.5 -2 1.6 1.6 1.6] rRdqQ a b c d e] $a $b $c $d $e`, result: "5 1.5 -2.0 1.6 1.6 1.6"},
+		{script: `list [binary scan "abc  \0\0" A* x] $x`, result: "1 abc"},
+		{script: `list [binary scan abcdef x2a2X3a* a b] $a $b`, result: "2 cd bcdef"},
+		{script: `list [binary scan abcdef {@4a* @1a2} a b] $a $b`, result: "2 ef bc"},
+		{script: `list [binary scan abcdef x*a*c* a b] $a $b`, result: "2 {} {}"},
+		{script: `list [binary scan abc x9X*a x] $x`, result: "1 a"},
+		{script: `binary scan abc q x`, result: "0"},
+
+		{script: `binary scan abc a`, err: "not enough arguments for all format specifiers"},
+		{script: `binary scan Ā a x`, err: "expected byte sequence but character 0 was 'Ā' (U+000100)"},
+		{script: `binary scan abc @ x`, err: `missing count for "@" field specifier`},
+		{script: `binary scan abc k x`, err: `bad field specifier "k"`},
+		{script: `binary scan abc`, err: `wrong # args: should be "binary scan value formatString ?varName ...?"`},
+	})
+}
+
+func TestBinaryEncode(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `binary encode base64 "Hello, World!"`, result: "SGVsbG8sIFdvcmxkIQ=="},
+		{script: `binary encode base64 -maxlen 8 "Hello, World!"`, result: "SGVsbG8s\nIFdvcmxk\nIQ=="},
+		{script: `binary encode base64 -maxlen 8 -wrapchar | "Hello, World!"`, result: "SGVsbG8s|IFdvcmxk|IQ=="},
+		{script: `binary decode base64 SGVsbG8sIFdvcmxkIQ==`, result: "Hello, World!"},
+		{script: `binary decode base64 "SGVs\nbG8s IFdv cmxk IQ"`, result: "Hello, World!"},
+		{script: `binary decode base64 -strict SGVsbG8sIFdvcmxkIQ`, err: `invalid base64 character "Q" at position 17`},
+		{script: `binary decode base64 -strict "SGVs bG8="`, err: `invalid base64 character " " at position 4`},
+		{script: `binary decode base64 SGV*`, err: `invalid base64 character "*" at position 3`},
+		{script: `binary encode base64 -maxlen -1 x`, err: "line length out of range"},
+		{script: `binary encode base64 -bogus 1 x`, err: `bad option "-bogus": must be -maxlen or -wrapchar`},
+		{script: `binary encode base64`, err: `wrong # args: should be "binary encode base64 ?-maxlen len? ?-wrapchar char? data"`},
+		{script: `binary decode base64 -bogus x`, err: `bad option "-bogus": must be -strict`},
+
+		{script: `binary encode hex \x01\xab\xff`, result: "01abff"},
+		{script: `binary encode hex [binary decode hex {01 AB ff}]`, result: "01abff"},
+		{script: `binary decode hex -strict {01 AB}`, err: `invalid hexadecimal digit " " at position 2`},
+		{script: `binary decode hex 0g`, err: `invalid hexadecimal digit "g" at position 1`},
+
+		{script: `binary encode uuencode abc`, result: "#86)C\n"},
+		{script: `binary encode uuencode \0\0`, result: "\"```\n"},
+		{script: `binary encode uuencode -maxlen 9 abcdefghij`, result: "&86)C9&5F\n$9VAI:@\n"},
+		{script: `binary decode uuencode [binary encode uuencode [string repeat 0123456789 10]]`, result: "0123456789012345678901234567890123456789012345678901234567890123456789012345678901234567890123456789"},
+		{script: `binary decode uuencode "#86)C\n"`, result: "abc"},
+		{script: `binary encode uuencode -wrapchar x abc`, err: "invalid wrapchar; will defeat decoding"},
+		{script: `binary encode uuencode -maxlen 100 abc`, err: "line length out of range"},
+		{script: `binary encode bogus x`, err: `unknown or ambiguous subcommand "bogus": must be base64, hex, or uuencode`},
+	})
+}
+
+func TestBinaryChannel(t *testing.T) {
+	interp := NewInterp()
+	interp.SetVFS(NewMemFS())
+	runEvalTests(t, interp, []evalTest{
+		{script: `set f [open /frame.bin wb]; puts -nonewline $f [binary format Sa*c 5 hello 200]; close $f`},
+		{script: `set f [open /frame.bin rb]; set data [read $f]; close $f; string length $data`, result: "8"},
+		{script: `list [binary scan $data Sa5cu len body tag] $len $body $tag`, result: "3 5 hello 200"},
+	})
+}
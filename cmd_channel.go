@@ -0,0 +1,753 @@
+package gotcl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+)
+
+// The channel commands are also subcommands of chan. Each is
+// implemented by a function that takes the number of words n that
+// name the command, so that its error messages name it correctly.
+
+var chanSubcommands = []subcommand{
+	{"blocked", chanSubcommand(channelBlocked)},
+	{"close", chanSubcommand(channelClose)},
+	{"configure", chanSubcommand(channelConfigure)},
+	{"copy", chanSubcommand(channelCopy)},
+	{"eof", chanSubcommand(channelEOF)},
+	{"event", chanSubcommand(channelEvent)},
+	{"flush", chanSubcommand(channelFlush)},
+	{"gets", chanSubcommand(channelGets)},
+	{"names", cmdChanNames},
+	{"puts", chanSubcommand(channelPuts)},
+	{"read", chanSubcommand(channelRead)},
+	{"seek", chanSubcommand(channelSeek)},
+	{"tell", chanSubcommand(channelTell)},
+}
+
+func chanSubcommand(fn func(interp *Interp, args []*Value, n int) (*Value, error)) CommandFunc {
+	return func(interp *Interp, args []*Value) (*Value, error) {
+		return fn(interp, args, 2)
+	}
+}
+
+// chan operation ?arg arg ...?
+//
+// This command provides several operations for reading from,
+// writing to and otherwise manipulating open channels.
+func cmdChan(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "subcommand ?arg ...?")
+	}
+	return dispatchSubcommand(interp, args, chanSubcommands)
+}
+
+// readableChannel returns the channel named by v, which must have
+// been opened for reading.
+func (interp *Interp) readableChannel(v *Value) (*channel, error) {
+	ch, err := interp.lookupChannel(v.String())
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.checkReadable(); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// writableChannel returns the channel named by v, which must have
+// been opened for writing.
+func (interp *Interp) writableChannel(v *Value) (*channel, error) {
+	ch, err := interp.lookupChannel(v.String())
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.checkWritable(); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// chan blocked channelId
+//
+// This tests whether the last input operation on the channel called
+// channelId failed because it would have otherwise caused the
+// process to block, which only happens if its -blocking option is
+// off.
+func channelBlocked(interp *Interp, args []*Value, n int) (*Value, error) {
+	if len(args) != n+1 {
+		return nil, wrongNumArgs(args, n, "channelId")
+	}
+	ch, err := interp.lookupChannel(args[n].String())
+	if err != nil {
+		return nil, err
+	}
+	return NewBoolValue(ch.blocked), nil
+}
+
+// close channelId ?r(ead)|w(rite)?
+//
+// Closes or half-closes the channel given by channelId. Any
+// buffered output is flushed to the channel's output device, any
+// buffered input is discarded, the underlying file or device is
+// closed, and channelId becomes unavailable for use. If the channel
+// is shared with other interpreters it is only removed from this
+// one.
+//
+// If the direction argument is given, only that side of a channel
+// opened for both reading and writing is closed.
+func cmdClose(interp *Interp, args []*Value) (*Value, error) {
+	return channelClose(interp, args, 1)
+}
+
+func channelClose(interp *Interp, args []*Value, n int) (*Value, error) {
+	if len(args) != n+1 && len(args) != n+2 {
+		return nil, wrongNumArgs(args, n, "channelId ?direction?")
+	}
+	ch, err := interp.lookupChannel(args[n].String())
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == n+1 {
+		return nil, interp.unregisterChannel(ch)
+	}
+	dir, err := lookupIndex([]string{"read", "write"}, "direction", args[n+1])
+	if err != nil {
+		return nil, err
+	}
+	if dir == 0 {
+		if ch.r == nil || ch.w == nil {
+			return nil, fmt.Errorf("Half-close of read-side not possible, side not opened or already closed")
+		}
+		ch.r, ch.in = nil, nil
+		return nil, nil
+	}
+	if ch.r == nil || ch.w == nil {
+		return nil, fmt.Errorf("Half-close of write-side not possible, side not opened or already closed")
+	}
+	if err := ch.flush(); err != nil {
+		return nil, ch.ioError("flushing", err)
+	}
+	if ch.closeWrite != nil {
+		if err := ch.closeWrite(); err != nil {
+			return nil, ch.ioError("closing", err)
+		}
+	}
+	ch.w = nil
+	return nil, nil
+}
+
+// fconfigure channelId ?name? ?value name value ...?
+//
+// The fconfigure command sets and retrieves options for channels.
+// If no name or value arguments are supplied, the command returns a
+// list containing alternating option names and values for the
+// channel. If name is supplied but no value then the command
+// returns the current value of the given option. If one or more
+// pairs of name and value are supplied, the command sets each of
+// the named options to the corresponding value; in this case the
+// return value is an empty string.
+//
+// The options are -blocking, -buffering (full, line or none),
+// -buffersize, -encoding (utf-8, iso8859-1, ascii or binary),
+// -eofchar and -translation (auto, binary, cr, crlf, lf or
+// platform, or a list of the input and output modes).
+func cmdFconfigure(interp *Interp, args []*Value) (*Value, error) {
+	return channelConfigure(interp, args, 1)
+}
+
+func channelConfigure(interp *Interp, args []*Value, n int) (*Value, error) {
+	if len(args) < n+1 {
+		return nil, wrongNumArgs(args, n, "channelId ?-option value ...?")
+	}
+	ch, err := interp.lookupChannel(args[n].String())
+	if err != nil {
+		return nil, err
+	}
+	opts := args[n+1:]
+	switch len(opts) {
+	case 0:
+		var values []*Value
+		for _, opt := range channelOptions {
+			values = append(values, NewStringValue(opt), ch.option(opt))
+		}
+		return NewListValue(values), nil
+	case 1:
+		opt, err := ch.lookupOption(opts[0].String())
+		if err != nil {
+			return nil, err
+		}
+		return ch.option(opt), nil
+	}
+	for i := 0; i < len(opts); i += 2 {
+		opt, err := ch.lookupOption(opts[i].String())
+		if err != nil {
+			return nil, err
+		}
+		if i+1 >= len(opts) {
+			return nil, fmt.Errorf("value for \"%s\" missing", opts[i])
+		}
+		if err := ch.setOption(opt, opts[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// fcopy inchan outchan ?-size size? ?-command callback?
+//
+// The fcopy command copies data from one channel, inchan, to
+// another channel, outchan. The -size option limits the number of
+// characters copied; by default everything up to the end of file is
+// copied. The number of characters copied is returned.
+//
+// If -command is given, fcopy returns immediately and the copy is
+// done in the background by the event loop. Once it is done, the
+// number of characters copied is appended to callback, along with an
+// error message if the copy failed, and the result is evaluated.
+func cmdFcopy(interp *Interp, args []*Value) (*Value, error) {
+	return channelCopy(interp, args, 1)
+}
+
+func channelCopy(interp *Interp, args []*Value, n int) (*Value, error) {
+	const usage = "input output ?-size size? ?-command callback?"
+	if len(args) < n+2 || (len(args)-n)%2 != 0 {
+		return nil, wrongNumArgs(args, n, usage)
+	}
+	in, err := interp.readableChannel(args[n])
+	if err != nil {
+		return nil, err
+	}
+	out, err := interp.writableChannel(args[n+1])
+	if err != nil {
+		return nil, err
+	}
+	size := -1
+	var callback *Value
+	for i := n + 2; i < len(args); i += 2 {
+		opt, err := lookupIndex([]string{"-size", "-command"}, "switch", args[i])
+		if err != nil {
+			return nil, err
+		}
+		if opt == 1 {
+			callback = args[i+1]
+			continue
+		}
+		if size, err = getInt(args[i+1]); err != nil {
+			return nil, err
+		}
+	}
+
+	if callback == nil {
+		total, err := copyChannel(in, out, size)
+		if err != nil {
+			return nil, err
+		}
+		return NewIntValue(total), nil
+	}
+	words, err := callback.List()
+	if err != nil {
+		return nil, err
+	}
+	interp.QueueEvent(func(interp *Interp) error {
+		total, err := copyChannel(in, out, size)
+		words := append(append([]*Value(nil), words...), NewIntValue(total))
+		if err != nil {
+			words = append(words, NewStringValue(err.Error()))
+		}
+		_, err = interp.invoke(words)
+		return err
+	})
+	return nil, nil
+}
+
+// copyChannel copies up to size characters, or everything if size is
+// negative, from in to out and returns the number copied. in blocks
+// for the copy, whatever its -blocking option.
+func copyChannel(in, out *channel, size int) (int64, error) {
+	blocking := in.blocking
+	in.blocking = true
+	defer func() { in.blocking = blocking }()
+	var total int64
+	for size < 0 || total < int64(size) {
+		n := in.bufferSize
+		if size >= 0 {
+			n = min(n, size-int(total))
+		}
+		if in.encoding == "iso8859-1" && out.encoding == "iso8859-1" {
+			b, eof, err := in.readInput(n, false)
+			if err != nil {
+				return total, err
+			}
+			if err := out.writeBytes(b); err != nil {
+				return total, err
+			}
+			total += int64(len(b))
+			if eof {
+				break
+			}
+			continue
+		}
+		s, eof, err := in.readChars(n, false)
+		if err != nil {
+			return total, err
+		}
+		if err := out.write(s); err != nil {
+			return total, err
+		}
+		total += int64(utf8.RuneCountInString(s))
+		if eof {
+			break
+		}
+	}
+	return total, nil
+}
+
+// eof channelId
+//
+// Returns 1 if an end of file condition occurred during the most
+// recent input operation on channelId (such as gets), 0 otherwise.
+func cmdEOF(interp *Interp, args []*Value) (*Value, error) {
+	return channelEOF(interp, args, 1)
+}
+
+func channelEOF(interp *Interp, args []*Value, n int) (*Value, error) {
+	if len(args) != n+1 {
+		return nil, wrongNumArgs(args, n, "channelId")
+	}
+	ch, err := interp.lookupChannel(args[n].String())
+	if err != nil {
+		return nil, err
+	}
+	return NewBoolValue(ch.eof), nil
+}
+
+// flush channelId
+//
+// Flushes any output that has been buffered for channelId.
+func cmdFlush(interp *Interp, args []*Value) (*Value, error) {
+	return channelFlush(interp, args, 1)
+}
+
+func channelFlush(interp *Interp, args []*Value, n int) (*Value, error) {
+	if len(args) != n+1 {
+		return nil, wrongNumArgs(args, n, "channelId")
+	}
+	ch, err := interp.writableChannel(args[n])
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.flush(); err != nil {
+		return nil, ch.ioError("flushing", err)
+	}
+	return nil, nil
+}
+
+// gets channelId ?varName?
+//
+// This command reads the next line from channelId, returns
+// everything in the line up to (but not including) the end-of-line
+// character(s), and discards the end-of-line character(s).
+//
+// If varName is omitted the line is returned as the result of the
+// command. If varName is specified then the line is placed in the
+// variable by that name and the return value is a count of the
+// number of characters returned, or -1 if end of file occurred
+// before any characters were read.
+//
+// If the channel is nonblocking and a full line of input is not
+// available, gets consumes no input and returns an empty string, or
+// -1 if varName is given; chan blocked then returns 1.
+func cmdGets(interp *Interp, args []*Value) (*Value, error) {
+	return channelGets(interp, args, 1)
+}
+
+func channelGets(interp *Interp, args []*Value, n int) (*Value, error) {
+	if len(args) != n+1 && len(args) != n+2 {
+		return nil, wrongNumArgs(args, n, "channelId ?varName?")
+	}
+	ch, err := interp.readableChannel(args[n])
+	if err != nil {
+		return nil, err
+	}
+	line, eof, err := ch.readChars(-1, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == n+1 {
+		return NewStringValue(line), nil
+	}
+	if _, err := interp.setVar(args[n+1].String(), NewStringValue(line)); err != nil {
+		return nil, err
+	}
+	if (eof || ch.blocked) && line == "" {
+		return NewIntValue(-1), nil
+	}
+	return NewIntValue(int64(utf8.RuneCountInString(line))), nil
+}
+
+// chan names ?pattern?
+//
+// Produces a list of all channel names. If pattern is specified,
+// only those channel names that match it (according to the rules
+// of string match) will be returned.
+func cmdChanNames(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 3 {
+		return nil, wrongNumArgs(args, 2, "?pattern?")
+	}
+	names := interp.channelNames()
+	if len(args) == 3 {
+		pattern := args[2].Runes()
+		matched := names[:0]
+		for _, name := range names {
+			if stringMatch(pattern, []rune(name), false) {
+				matched = append(matched, name)
+			}
+		}
+		names = matched
+	}
+	return NewListValue(stringsToValues(names)), nil
+}
+
+var openFlags = []string{
+	"APPEND", "BINARY", "CREAT", "EXCL", "NOCTTY", "NONBLOCK",
+	"RDONLY", "RDWR", "TRUNC", "WRONLY",
+}
+
+// parseOpenAccess parses the access argument of open, returning the
+// flags for os.OpenFile and whether the channel is binary.
+func parseOpenAccess(v *Value) (int, bool, error) {
+	s := v.String()
+	if s != "" && strings.ContainsRune("rwa", rune(s[0])) {
+		var flags int
+		switch s[0] {
+		case 'r':
+			flags = os.O_RDONLY
+		case 'w':
+			flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		case 'a':
+			flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		plus, binary := false, false
+		for _, c := range s[1:] {
+			switch {
+			case c == '+' && !plus:
+				plus = true
+				flags = flags&^os.O_WRONLY | os.O_RDWR
+			case c == 'b' && !binary:
+				binary = true
+			default:
+				return 0, false, fmt.Errorf("illegal access mode \"%s\"", s)
+			}
+		}
+		return flags, binary, nil
+	}
+
+	words, err := v.List()
+	if err != nil {
+		return 0, false, err
+	}
+	flags, binary, gotRW := 0, false, false
+	for _, w := range words {
+		switch w.String() {
+		case "RDONLY":
+			flags, gotRW = flags|os.O_RDONLY, true
+		case "WRONLY":
+			flags, gotRW = flags|os.O_WRONLY, true
+		case "RDWR":
+			flags, gotRW = flags|os.O_RDWR, true
+		case "APPEND":
+			flags |= os.O_APPEND
+		case "BINARY":
+			binary = true
+		case "CREAT":
+			flags |= os.O_CREATE
+		case "EXCL":
+			flags |= os.O_EXCL
+		case "NOCTTY":
+			flags |= syscall.O_NOCTTY
+		case "NONBLOCK":
+			flags |= syscall.O_NONBLOCK
+		case "TRUNC":
+			flags |= os.O_TRUNC
+		default:
+			return 0, false, fmt.Errorf("invalid access mode \"%s\": must be %s", w, mustBe(openFlags))
+		}
+	}
+	if !gotRW {
+		return 0, false, fmt.Errorf("access mode must include either RDONLY, WRONLY, or RDWR")
+	}
+	return flags, binary, nil
+}
+
+// parseOpenPermissions parses the permissions argument of open, an
+// integer that is octal if it has a leading 0.
+func parseOpenPermissions(v *Value) (int, error) {
+	s := strings.Trim(v.String(), tclSpace)
+	if len(s) > 1 && s[0] == '0' {
+		if n, err := strconv.ParseUint(s[1:], 8, 32); err == nil {
+			return int(n), nil
+		}
+	}
+	return getInt(v)
+}
+
+// open fileName ?access? ?permissions?
+//
+// This command opens a file and returns a channel identifier that
+// may be used in future invocations of commands like read, puts,
+// and close.
+//
+// The access argument, if present, indicates the way in which the
+// file is to be accessed. In the first form access may have any of
+// the values r, r+, w, w+, a and a+, optionally with b added to
+// open the file in binary mode. In the second form, access consists
+// of a list of any of the flags APPEND, BINARY, CREAT, EXCL, NOCTTY,
+// NONBLOCK, RDONLY, RDWR, TRUNC and WRONLY, one of which must be
+// RDONLY, WRONLY or RDWR. A file opened for appending starts at its
+// end. If a new file is created, permissions is used to set its
+// permissions, 0666 by default; a leading 0 makes it octal.
+func cmdOpen(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 || len(args) > 4 {
+		return nil, wrongNumArgs(args, 1, "fileName ?access? ?permissions?")
+	}
+	flags, binary := os.O_RDONLY, false
+	if len(args) > 2 {
+		var err error
+		if flags, binary, err = parseOpenAccess(args[2]); err != nil {
+			return nil, err
+		}
+	}
+	perm := 0o666
+	if len(args) > 3 {
+		var err error
+		if perm, err = parseOpenPermissions(args[3]); err != nil {
+			return nil, err
+		}
+	}
+	path := args[1].String()
+	if cmd, ok := strings.CutPrefix(path, "|"); ok {
+		if err := interp.checkPipelinePermission(); err != nil {
+			return nil, err
+		}
+		words, err := NewStringValue(cmd).List()
+		if err != nil {
+			return nil, err
+		}
+		ch, err := interp.openPipeline(valuesToStrings(words), flags)
+		if err != nil {
+			return nil, err
+		}
+		if binary {
+			ch.setTranslation(NewStringValue("binary"))
+		}
+		interp.registerChannel(ch)
+		return NewStringValue(ch.name), nil
+	}
+	f, err := interp.filesystem().OpenFile(path, flags, fs.FileMode(perm)&fs.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open \"%s\": %s", path, posixError(err))
+	}
+	if flags&os.O_APPEND != 0 {
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("couldn't open \"%s\": %s", path, posixError(err))
+		}
+	}
+	var r io.Reader
+	var w io.Writer
+	switch flags & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+	case os.O_RDONLY:
+		r = f
+	case os.O_WRONLY:
+		w = f
+	default:
+		r, w = f, f
+	}
+	ch := newChannel(interp.fileChannelName(f), r, w, f, f.Close)
+	if binary {
+		ch.setTranslation(NewStringValue("binary"))
+	}
+	interp.registerChannel(ch)
+	return NewStringValue(ch.name), nil
+}
+
+// puts ?-nonewline? ?channelId? string
+//
+// Writes the characters given by string to the channel given by
+// channelId, which defaults to stdout. Puts normally outputs a
+// newline character after string, but this feature may be
+// suppressed by specifying the -nonewline switch.
+func cmdPuts(interp *Interp, args []*Value) (*Value, error) {
+	return channelPuts(interp, args, 1)
+}
+
+func channelPuts(interp *Interp, args []*Value, n int) (*Value, error) {
+	newline := true
+	chanName := NewStringValue("stdout")
+	var s *Value
+	switch len(args) - n {
+	case 1:
+		s = args[n]
+	case 2:
+		if args[n].String() == "-nonewline" {
+			newline = false
+		} else {
+			chanName = args[n]
+		}
+		s = args[n+1]
+	case 3:
+		if args[n].String() != "-nonewline" {
+			return nil, wrongNumArgs(args, n, "?-nonewline? ?channelId? string")
+		}
+		newline = false
+		chanName, s = args[n+1], args[n+2]
+	default:
+		return nil, wrongNumArgs(args, n, "?-nonewline? ?channelId? string")
+	}
+	ch, err := interp.writableChannel(chanName)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := s.rep.(bytesRep); ok && ch.encoding == "iso8859-1" {
+		b, _ := s.Bytes()
+		if newline {
+			b = append(b[:len(b):len(b)], '\n')
+		}
+		return nil, ch.writeBytes(b)
+	}
+	str := s.String()
+	if newline {
+		str += "\n"
+	}
+	return nil, ch.write(str)
+}
+
+// read ?-nonewline? channelId
+// read channelId numChars
+//
+// In the first form, the read command reads all of the data from
+// channelId up to the end of the file. If the -nonewline switch is
+// specified then the last character of the file is discarded if it
+// is a newline. In the second form, the extra argument specifies
+// how many characters to read. Exactly that many characters will be
+// read and returned, unless there are fewer than numChars left in
+// the file; in this case all the remaining characters are returned.
+//
+// If the channel is nonblocking, read returns only the input that is
+// available, and chan blocked returns 1 if that was not all of it.
+func cmdRead(interp *Interp, args []*Value) (*Value, error) {
+	return channelRead(interp, args, 1)
+}
+
+func channelRead(interp *Interp, args []*Value, n int) (*Value, error) {
+	argError := func() error {
+		err := wrongNumArgs(args, n, "channelId ?numChars?")
+		name := valuesToStrings(args[:n])
+		return fmt.Errorf("%s or \"%s ?-nonewline? channelId\"", err, strings.Join(name, " "))
+	}
+	if len(args) != n+1 && len(args) != n+2 {
+		return nil, argError()
+	}
+	i := n
+	nonewline := false
+	if args[i].String() == "-nonewline" {
+		nonewline = true
+		i++
+	}
+	if i == len(args) {
+		return nil, argError()
+	}
+	ch, err := interp.readableChannel(args[i])
+	if err != nil {
+		return nil, err
+	}
+	count := -1
+	if i++; i < len(args) {
+		count, err = getInt(args[i])
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("expected non-negative integer but got \"%s\"", args[i])
+		}
+	}
+	if ch.encoding == "iso8859-1" {
+		// Each character read is a byte, which binary scan will
+		// want back, so the bytes are kept as they are.
+		b, _, err := ch.readInput(count, false)
+		if err != nil {
+			return nil, err
+		}
+		if nonewline {
+			b = bytes.TrimSuffix(b, []byte("\n"))
+		}
+		return NewBytesValue(b), nil
+	}
+	s, _, err := ch.readChars(count, false)
+	if err != nil {
+		return nil, err
+	}
+	if nonewline {
+		s = strings.TrimSuffix(s, "\n")
+	}
+	return NewStringValue(s), nil
+}
+
+var seekOrigins = []string{"start", "current", "end"}
+
+// seek channelId offset ?origin?
+//
+// Changes the current access position for channelId. Offset and
+// origin specify the position at which the next read or write will
+// occur for channelId. Origin must be one of start, current or end,
+// and defaults to start.
+func cmdSeek(interp *Interp, args []*Value) (*Value, error) {
+	return channelSeek(interp, args, 1)
+}
+
+func channelSeek(interp *Interp, args []*Value, n int) (*Value, error) {
+	if len(args) != n+2 && len(args) != n+3 {
+		return nil, wrongNumArgs(args, n, "channelId offset ?origin?")
+	}
+	ch, err := interp.lookupChannel(args[n].String())
+	if err != nil {
+		return nil, err
+	}
+	offset, err := args[n+1].Int()
+	if err != nil {
+		return nil, err
+	}
+	whence := io.SeekStart
+	if len(args) == n+3 {
+		origin, err := lookupIndex(seekOrigins, "origin", args[n+2])
+		if err != nil {
+			return nil, err
+		}
+		whence = []int{io.SeekStart, io.SeekCurrent, io.SeekEnd}[origin]
+	}
+	return nil, ch.seek(offset, whence)
+}
+
+// tell channelId
+//
+// Returns an integer giving the current access position in
+// channelId, or -1 if the channel does not support seeking.
+func cmdTell(interp *Interp, args []*Value) (*Value, error) {
+	return channelTell(interp, args, 1)
+}
+
+func channelTell(interp *Interp, args []*Value, n int) (*Value, error) {
+	if len(args) != n+1 {
+		return nil, wrongNumArgs(args, n, "channelId")
+	}
+	ch, err := interp.lookupChannel(args[n].String())
+	if err != nil {
+		return nil, err
+	}
+	return NewIntValue(ch.tell()), nil
+}
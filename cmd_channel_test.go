@@ -0,0 +1,218 @@
+package gotcl
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestChannelFiles(t *testing.T) {
+	interp := NewInterp()
+	path := filepath.Join(t.TempDir(), "data.txt")
+	if _, err := interp.setVar("path", NewStringValue(path)); err != nil {
+		t.Fatal(err)
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `set f [open $path w]; string match file* $f`, result: "1"},
+		{script: `puts $f "line one"`},
+		{script: `puts -nonewline $f "line two"`},
+		{script: `tell $f`, result: "17"},
+		{script: `close $f`},
+		{script: `set f [open $path]; gets $f`, result: "line one"},
+		{script: `eof $f`, result: "0"},
+		{script: `gets $f line`, result: "8"},
+		{script: `set line`, result: "line two"},
+		{script: `eof $f`, result: "1"},
+		{script: `gets $f line`, result: "-1"},
+		{script: `seek $f 5; read $f 3`, result: "one"},
+		{script: `tell $f`, result: "8"},
+		{script: `seek $f -3 end; read $f`, result: "two"},
+		{script: `seek $f 0; read -nonewline $f`, result: "line one\nline two"},
+		{script: `seek $f 0 bogus`, err: `bad origin "bogus": must be start, current, or end`},
+		{script: `read $f -1`, err: `expected non-negative integer but got "-1"`},
+		{script: `close $f`},
+
+		{script: `set f [open $path a]; tell $f`, result: "17"},
+		{script: `puts $f three; tell $f`, result: "23"},
+		{script: `close $f`},
+		{script: `set f [open $path r+]; read $f`, result: "line one\nline twothree\n"},
+		{script: `seek $f 0; puts -nonewline $f LINE; seek $f 0; gets $f`, result: "LINE one"},
+		{script: `close $f`},
+		{script: `set f [open $path {WRONLY TRUNC}]; close $f`},
+		{script: `set f [open $path]; read $f`, result: ""},
+		{script: `close $f`},
+		{script: `open $path {CREAT EXCL WRONLY}`, err: `couldn't open "` + path + `": file already exists`},
+		{script: `open $path.missing`, err: `couldn't open "` + path + `.missing": no such file or directory`},
+		{script: `close [open $path.new {WRONLY CREAT} 0600]; file attributes $path.new -permissions`, result: "00600"},
+		{script: `close [open $path.dec {WRONLY CREAT} 384]; file attributes $path.dec -permissions`, result: "00600"},
+		{script: `open $path.bad w 06x0`, err: `expected integer but got "06x0"`},
+		{script: `open $path rw`, err: `illegal access mode "rw"`},
+		{script: `open $path {RDONLY BOGUS}`, err: `invalid access mode "BOGUS": must be APPEND, BINARY, CREAT, EXCL, NOCTTY, NONBLOCK, RDONLY, RDWR, TRUNC, or WRONLY`},
+		{script: `open $path CREAT`, err: `access mode must include either RDONLY, WRONLY, or RDWR`},
+		{script: `open`, err: `wrong # args: should be "open fileName ?access? ?permissions?"`},
+	})
+}
+
+func TestChannelTranslation(t *testing.T) {
+	interp := NewInterp()
+	path := filepath.Join(t.TempDir(), "data.bin")
+	if _, err := interp.setVar("path", NewStringValue(path)); err != nil {
+		t.Fatal(err)
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `set f [open $path w]; fconfigure $f -translation crlf; puts $f "a\nb"; close $f`},
+		{script: `set f [open $path rb]; read $f`, result: "a\r\nb\r\n"},
+		{script: `fconfigure $f -translation`, result: "lf"},
+		{script: `fconfigure $f -encoding`, result: "iso8859-1"},
+		{script: `seek $f 0; fconfigure $f -translation auto; read $f`, result: "a\nb\n"},
+		{script: `seek $f 0; fconfigure $f -translation cr; read $f`, result: "a\n\nb\n\n"},
+		{script: `close $f`},
+		{script: `set f [open $path w+]; fconfigure $f -translation`, result: "auto lf"},
+		{script: `fconfigure $f -translation {crlf cr}; fconfigure $f -translation`, result: "crlf cr"},
+		{script: `fconfigure $f`, result: "-blocking 1 -buffering full -buffersize 4096 -encoding utf-8 -eofchar {} -translation {crlf cr}"},
+		{script: `fconfigure $f -translation lf -eofchar x; puts $f "abéxcd"; seek $f 0; read $f`, result: "abé"},
+		{script: `eof $f`, result: "1"},
+		{script: `seek $f 0; fconfigure $f -eofchar {} -encoding binary; read $f`, result: "abÃ©xcd\n"},
+		{script: `tell $f`, result: "8"},
+		{script: `fconfigure $f -encoding ascii; puts -nonewline $f "é"; seek $f -1 end; read $f`, result: "?"},
+		{script: `fconfigure $f -b`, err: `bad option "-b": should be one of -blocking, -buffering, -buffersize, -encoding, -eofchar, or -translation`},
+		{script: `fconfigure $f -buffering fast`, err: `bad value for -buffering: must be one of full, line, or none`},
+		{script: `fconfigure $f -translation bogus`, err: `bad value for -translation: must be one of auto, binary, cr, lf, crlf, or platform`},
+		{script: `fconfigure $f -encoding koi8`, err: `unknown encoding "koi8"`},
+		{script: `fconfigure $f -eofchar xy`, err: `bad value for -eofchar: must be non-NUL ASCII character`},
+		{script: `fconfigure $f -blocking`, result: "1"},
+		{script: `fconfigure $f -buffering`, result: "full"},
+		{script: `fconfigure $f -buffering line -buffersize`, err: `value for "-buffersize" missing`},
+		{script: `chan configure $f -buffering`, result: "line"},
+		{script: `close $f`},
+	})
+}
+
+// bufferChannel is an in-memory channel for tests.
+type bufferChannel struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufferChannel) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestRegisterChannel(t *testing.T) {
+	interp := NewInterp()
+	in := &bufferChannel{}
+	in.WriteString("first\r\nsecond\nthird")
+	out := &bufferChannel{}
+	if err := interp.RegisterChannel("in", in); err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.RegisterChannel("out", out); err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.RegisterChannel("out", out); err == nil {
+		t.Error("registered channel twice")
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `gets in`, result: "first"},
+		{script: `tell in`, result: "-1"},
+		{script: `seek in 0`, err: `error during seek on "in": invalid argument`},
+		{script: `fcopy in out -size 3`, result: "3"},
+		{script: `fcopy in out -command {set copied}`},
+		{script: `vwait copied; set copied`, result: "9"},
+		{script: `eof in`, result: "1"},
+		{script: `chan blocked in`, result: "0"},
+		{script: `chan names {[io]*}`, result: "in out"},
+		{script: `chan puts out !`},
+		{script: `fcopy in`, err: `wrong # args: should be "fcopy input output ?-size size? ?-command callback?"`},
+		{script: `fcopy in out -bogus 1`, err: `bad switch "-bogus": must be -size or -command`},
+		{script: `chan read`, err: `wrong # args: should be "chan read channelId ?numChars?" or "chan read ?-nonewline? channelId"`},
+		{script: `read -nonewline in 3`, err: `wrong # args: should be "read channelId ?numChars?" or "read ?-nonewline? channelId"`},
+		{script: `puts a b c d`, err: `wrong # args: should be "puts ?-nonewline? ?channelId? string"`},
+		{script: `chan bogus`, err: `unknown or ambiguous subcommand "bogus": must be blocked, close, configure, copy, eof, event, flush, gets, names, puts, read, seek, or tell`},
+	})
+	if out.String() != "" {
+		t.Errorf("output flushed early: %q", out.String())
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `flush out`},
+	})
+	if got, want := out.String(), "second\nthird!\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	if err := interp.UnregisterChannel("in"); err != nil {
+		t.Fatal(err)
+	}
+	if !in.closed {
+		t.Error("channel not closed when unregistered")
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `close out write`, result: ""},
+		{script: `puts out x`, err: `channel "out" wasn't opened for writing`},
+		{script: `close out read`, err: `Half-close of read-side not possible, side not opened or already closed`},
+		{script: `close out`},
+	})
+	if !out.closed {
+		t.Error("channel not closed by close")
+	}
+}
+
+func TestChannelBytes(t *testing.T) {
+	interp := NewInterp()
+	in := &bufferChannel{}
+	in.WriteString("\x00\xff\r\n")
+	out := &bufferChannel{}
+	if err := interp.RegisterChannel("in", in); err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.RegisterChannel("out", out); err != nil {
+		t.Fatal(err)
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `fconfigure in -translation binary; set data [read in]; list`},
+	})
+	v, err := interp.getVar("data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.rep.(bytesRep); !ok || v.valid {
+		t.Errorf("read result has rep %T and string %v, want bytes only", v.rep, v.valid)
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `fconfigure out -translation binary; puts -nonewline out $data; flush out`},
+	})
+	if v.valid {
+		t.Error("puts converted its bytes to a string")
+	}
+	if got, want := out.String(), "\x00\xff\r\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `binary scan $data H* hex; set hex`, result: "00ff0d0a"},
+		{script: `fconfigure out -translation crlf -encoding iso8859-1; puts out $data; flush out`},
+	})
+	if got, want := out.String(), "\x00\xff\r\n\x00\xff\r\r\n\r\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestStandardChannels(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `chan names std*`, result: "stderr stdin stdout"},
+		{script: `fconfigure stderr -buffering`, result: "none"},
+		{script: `fconfigure stdout -translation`, result: "lf"},
+		{script: `puts stdin x`, err: `channel "stdin" wasn't opened for writing`},
+		{script: `close stdout; chan names std*`, result: "stderr stdin"},
+	})
+	// Closing a standard channel in one interpreter leaves it open
+	// in the others.
+	if _, err := NewInterp().Eval(`flush stdout`); err != nil {
+		t.Error(err)
+	}
+	safe := NewSafeInterp()
+	runEvalTests(t, safe, []evalTest{
+		{script: `chan names`, result: ""},
+		{script: `open x`, err: `invalid command name "open"`},
+	})
+}
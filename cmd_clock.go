@@ -0,0 +1,620 @@
+package gotcl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var clockSubcommands = []subcommand{
+	{"add", cmdClockAdd},
+	{"clicks", cmdClockClicks},
+	{"format", cmdClockFormat},
+	{"microseconds", cmdClockMicroseconds},
+	{"milliseconds", cmdClockMilliseconds},
+	{"scan", cmdClockScan},
+	{"seconds", cmdClockSeconds},
+}
+
+var (
+	clockWeekdays = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+	clockMonths   = []string{
+		"January", "February", "March", "April", "May", "June", "July",
+		"August", "September", "October", "November", "December",
+	}
+)
+
+// The formats that clock uses for the locale-dependent format groups.
+// Only the root locale, whose names are in English, is supported.
+const (
+	clockDefaultFormat  = "%a %b %d %H:%M:%S %Z %Y"
+	clockDateTimeFormat = "%a %b %e %H:%M:%S %Y"
+	clockDateFormat     = "%m/%d/%Y"
+	clockTimeFormat     = "%H:%M:%S"
+	clockTimeFormat12   = "%I:%M:%S %P"
+	clockFullFormat     = "%a %b %e %H:%M:%S %Z %Y"
+)
+
+// clock subcommand ?arg ...?
+//
+// The clock command performs several operations that obtain and
+// manipulate values that represent times, as a count of seconds from
+// the epoch time of 00:00 UTC on 1 January 1970. Time zones are those
+// of the zoneinfo database, which is built into the interpreter so
+// that it works on hosts without one. Only the root locale, whose
+// names are in English, is available; other locales' message catalogs
+// are not loaded.
+func cmdClock(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "subcommand ?arg ...?")
+	}
+	return dispatchSubcommand(interp, args, clockSubcommands)
+}
+
+// clock seconds
+//
+// Returns the current time as an integer number of seconds.
+func cmdClockSeconds(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 2 {
+		return nil, wrongNumArgs(args, 2, "")
+	}
+	return NewIntValue(time.Now().Unix()), nil
+}
+
+// clock milliseconds
+//
+// Returns the current time as an integer number of milliseconds.
+func cmdClockMilliseconds(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 2 {
+		return nil, wrongNumArgs(args, 2, "")
+	}
+	return NewIntValue(time.Now().UnixMilli()), nil
+}
+
+// clock microseconds
+//
+// Returns the current time as an integer number of microseconds.
+func cmdClockMicroseconds(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 2 {
+		return nil, wrongNumArgs(args, 2, "")
+	}
+	return NewIntValue(time.Now().UnixMicro()), nil
+}
+
+var clockClicksSwitches = []string{"-milliseconds", "-microseconds"}
+
+// clock clicks ?-milliseconds|-microseconds?
+//
+// If no -option argument is supplied, returns a high-resolution time
+// value as a system-dependent integer value, here the number of
+// microseconds since the epoch. With -milliseconds or -microseconds,
+// it is equivalent to clock milliseconds or clock microseconds.
+func cmdClockClicks(interp *Interp, args []*Value) (*Value, error) {
+	switch len(args) {
+	case 2:
+		return NewIntValue(time.Now().UnixMicro()), nil
+	case 3:
+		sw, err := lookupIndex(clockClicksSwitches, "switch", args[2])
+		if err != nil {
+			return nil, err
+		}
+		if clockClicksSwitches[sw] == "-milliseconds" {
+			return NewIntValue(time.Now().UnixMilli()), nil
+		}
+		return NewIntValue(time.Now().UnixMicro()), nil
+	}
+	return nil, wrongNumArgs(args, 2, "?-switch?")
+}
+
+// clockOptions holds the options of clock format, clock scan and
+// clock add.
+type clockOptions struct {
+	base     *Value
+	format   *Value
+	gmt      *Value
+	locale   *Value
+	timezone *Value
+}
+
+// set sets the option name to v.
+func (o *clockOptions) set(name string, v *Value) {
+	switch name {
+	case "-base":
+		o.base = v
+	case "-format":
+		o.format = v
+	case "-gmt":
+		o.gmt = v
+	case "-locale":
+		o.locale = v
+	case "-timezone":
+		o.timezone = v
+	}
+}
+
+// location returns the time zone selected by the -gmt and -timezone
+// options.
+func (o *clockOptions) location() (*time.Location, error) {
+	if o.gmt != nil && o.timezone != nil {
+		return nil, &Error{
+			Code: []string{"CLOCK", "gmtWithTimezone"},
+			Msg:  "cannot use -gmt and -timezone in same call",
+		}
+	}
+	if o.gmt != nil {
+		gmt, err := o.gmt.Bool()
+		if err != nil {
+			return nil, err
+		}
+		if gmt {
+			return time.FixedZone("GMT", 0), nil
+		}
+	}
+	if o.timezone != nil {
+		return clockLocation(o.timezone.String())
+	}
+	return time.Local, nil
+}
+
+// clockLocales holds the locales accepted by -locale, all of which
+// are the root locale; current is the default.
+var clockLocales = []string{"C", "current", "root"}
+
+// checkLocale returns an error if -locale names a locale other than
+// the root locale.
+func (o *clockOptions) checkLocale() error {
+	if o.locale == nil {
+		return nil
+	}
+	name := o.locale.String()
+	for _, l := range clockLocales {
+		if strings.EqualFold(name, l) {
+			return nil
+		}
+	}
+	return &Error{
+		Code: []string{"CLOCK", "badLocale", name},
+		Msg:  fmt.Sprintf("unsupported locale \"%s\": must be %s", name, mustBe(clockLocales)),
+	}
+}
+
+// badClockOption returns the error of clock scan and clock add for
+// the unknown option opt.
+func badClockOption(opt *Value, table []string) error {
+	return &Error{
+		Code: []string{"CLOCK", "badOption", opt.String()},
+		Msg:  fmt.Sprintf("bad option \"%s\", must be %s", opt, strings.Replace(mustBe(table), ", or ", " or ", 1)),
+	}
+}
+
+var clockFormatOptions = []string{"-format", "-gmt", "-locale", "-timezone"}
+
+// clock format timeVal ?-format format? ?-gmt boolean? ?-locale localeName? ?-timezone zoneName?
+//
+// Formats a time that is expressed as an integer count of seconds
+// since the epoch into a human-readable form. The format defaults to
+// "%a %b %d %H:%M:%S %Z %Y", and consists of literal text and format
+// groups, each a percent sign followed by a character, which are
+// replaced as follows:
+//
+//	%a  abbreviated name of the day of the week
+//	%A  full name of the day of the week
+//	%b  abbreviated name of the month, also %h
+//	%B  full name of the month
+//	%c  the date and time as "%a %b %e %H:%M:%S %Y"
+//	%C  century, 00-99
+//	%d  day of the month, 01-31
+//	%D  the date as "%m/%d/%Y"
+//	%e  day of the month, 1-31, padded with a space
+//	%g  year of the ISO 8601 week, 00-99
+//	%G  year of the ISO 8601 week, with century
+//	%H  hour in the 24-hour clock, 00-23
+//	%I  hour in the 12-hour clock, 01-12
+//	%j  day of the year, 001-366
+//	%J  Julian day number
+//	%k  hour in the 24-hour clock, 0-23, padded with a space
+//	%l  hour in the 12-hour clock, 1-12, padded with a space
+//	%m  month, 01-12
+//	%M  minute, 00-59
+//	%n  newline
+//	%N  month, 1-12, padded with a space
+//	%p  AM or PM
+//	%P  am or pm
+//	%Q  the time as a Star Trek stardate
+//	%r  the time as "%I:%M:%S %P"
+//	%R  the time as "%H:%M"
+//	%s  count of seconds since the epoch
+//	%S  second, 00-59
+//	%t  tab
+//	%T  the time as "%H:%M:%S"
+//	%u  day of the week, 1 for Monday to 7 for Sunday
+//	%U  week of the year, 00-53, where weeks start on Sunday
+//	%V  ISO 8601 week of the year, 01-53
+//	%w  day of the week, 0 for Sunday to 6 for Saturday
+//	%W  week of the year, 00-53, where weeks start on Monday
+//	%x  the date as "%m/%d/%Y"
+//	%X  the time as "%H:%M:%S"
+//	%y  year of the century, 00-99
+//	%Y  year with century
+//	%z  offset from UTC as +hhmm or -hhmm
+//	%Z  name of the time zone
+//	%+  the date and time as "%a %b %e %H:%M:%S %Z %Y"
+//	%%  a percent sign
+//
+// %E before c, x, X, C, y or Y, and %O before a numeric group, select
+// a locale's alternative era and numerals; %EE gives the era, C.E. or
+// B.C.E. Dates before 15 October 1582 are in the Julian calendar.
+//
+// The -gmt option formats the time in Greenwich Mean Time, and the
+// -timezone option in the given time zone rather than the local one.
+// The -locale option may only name the root locale, as C, root or
+// current.
+func cmdClockFormat(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 || len(args)%2 == 0 {
+		return nil, wrongNumArgs(args, 2, "clockval ?-format string? ?-gmt boolean? ?-locale LOCALE? ?-timezone ZONE?")
+	}
+	o := &clockOptions{}
+	for i := 3; i < len(args); i += 2 {
+		opt, err := lookupIndex(clockFormatOptions, "option", args[i])
+		if err != nil {
+			return nil, err
+		}
+		o.set(clockFormatOptions[opt], args[i+1])
+	}
+	seconds, err := args[2].Int()
+	if err != nil {
+		return nil, err
+	}
+	loc, err := o.location()
+	if err != nil {
+		return nil, err
+	}
+	if err := o.checkLocale(); err != nil {
+		return nil, err
+	}
+	format := clockDefaultFormat
+	if o.format != nil {
+		format = o.format.String()
+	}
+	var b strings.Builder
+	newClockDate(seconds, loc, jdGregorianChange).format(&b, format)
+	return NewStringValue(b.String()), nil
+}
+
+// yearOfEra returns the year of d counted from 1 in its era.
+func (d *clockDate) yearOfEra() int64 {
+	if d.year <= 0 {
+		return 1 - d.year
+	}
+	return d.year
+}
+
+// format appends d formatted according to the clock format string
+// format to b.
+func (d *clockDate) format(b *strings.Builder, format string) {
+	second := d.secondOfDay()
+	hour := int(second / 3600)
+	hour12 := (hour+11)%12 + 1
+	numbers := map[byte]int{
+		'd': d.dayOfMonth, 'e': d.dayOfMonth,
+		'H': hour, 'k': hour, 'I': hour12, 'l': hour12,
+		'm': d.month, 'M': int(second / 60 % 60), 'S': int(second % 60),
+		'u': d.dayOfWeek, 'w': d.dayOfWeek % 7, 'y': int(d.yearOfEra() % 100),
+	}
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' || i+1 == len(format) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch c = format[i]; c {
+		case 'a':
+			b.WriteString(clockWeekdays[d.dayOfWeek%7][:3])
+		case 'A':
+			b.WriteString(clockWeekdays[d.dayOfWeek%7])
+		case 'b', 'h':
+			b.WriteString(clockMonths[d.month-1][:3])
+		case 'B':
+			b.WriteString(clockMonths[d.month-1])
+		case 'c':
+			d.format(b, clockDateTimeFormat)
+		case 'C':
+			fmt.Fprintf(b, "%02d", d.yearOfEra()/100)
+		case 'd', 'H', 'I', 'm', 'M', 'S', 'y':
+			fmt.Fprintf(b, "%02d", numbers[c])
+		case 'e', 'k', 'l':
+			fmt.Fprintf(b, "%2d", numbers[c])
+		case 'D', 'x':
+			d.format(b, clockDateFormat)
+		case 'g':
+			fmt.Fprintf(b, "%02d", floorMod(d.iso8601Year, 100))
+		case 'G':
+			fmt.Fprintf(b, "%04d", d.iso8601Year)
+		case 'j':
+			fmt.Fprintf(b, "%03d", d.dayOfYear)
+		case 'J':
+			fmt.Fprintf(b, "%d", d.julianDay)
+		case 'n':
+			b.WriteByte('\n')
+		case 'N':
+			fmt.Fprintf(b, "%2d", d.month)
+		case 'p', 'P':
+			meridian := "AM"
+			if hour >= 12 {
+				meridian = "PM"
+			}
+			if c == 'P' {
+				meridian = strings.ToLower(meridian)
+			}
+			b.WriteString(meridian)
+		case 'Q':
+			days := int64(365)
+			if isLeapYear(d.year, d.gregorian) {
+				days = 366
+			}
+			fmt.Fprintf(b, "Stardate %02d%03d.%1d", d.year-1946, 1000*int64(d.dayOfYear-1)/days, second/8640)
+		case 'r':
+			d.format(b, clockTimeFormat12)
+		case 'R':
+			d.format(b, "%H:%M")
+		case 's':
+			fmt.Fprintf(b, "%d", d.seconds)
+		case 't':
+			b.WriteByte('\t')
+		case 'T', 'X':
+			d.format(b, clockTimeFormat)
+		case 'u', 'w':
+			fmt.Fprintf(b, "%d", numbers[c])
+		case 'U':
+			fmt.Fprintf(b, "%02d", (d.dayOfYear+6-d.dayOfWeek%7)/7)
+		case 'V':
+			fmt.Fprintf(b, "%02d", d.iso8601Week)
+		case 'W':
+			fmt.Fprintf(b, "%02d", (d.dayOfYear+6-(d.dayOfWeek+6)%7)/7)
+		case 'Y':
+			fmt.Fprintf(b, "%04d", d.yearOfEra())
+		case 'z':
+			b.WriteString(formatNumericZone(d.offset))
+		case 'Z':
+			b.WriteString(d.zone)
+		case '+':
+			d.format(b, clockFullFormat)
+		case '%':
+			b.WriteByte('%')
+		case 'E':
+			d.formatModified(b, format[i:], map[byte]string{
+				'c': clockDateTimeFormat, 'C': "%C", 'x': clockDateFormat,
+				'X': clockTimeFormat, 'y': "%y", 'Y': "%Y",
+			})
+			i++
+		case 'O':
+			// The numerals of the root locale are the two-digit
+			// numbers.
+			if i+1 < len(format) {
+				if n, ok := numbers[format[i+1]]; ok {
+					fmt.Fprintf(b, "%02d", n)
+					i++
+					continue
+				}
+			}
+			d.formatModified(b, format[i:], nil)
+			i++
+		default:
+			b.WriteByte('%')
+			b.WriteByte(c)
+		}
+	}
+}
+
+// formatModified appends the group of format, which starts with the
+// modifier E or O, using the formats it stands for in groups.
+func (d *clockDate) formatModified(b *strings.Builder, format string, groups map[byte]string) {
+	if len(format) < 2 {
+		b.WriteString("%" + format)
+		return
+	}
+	switch group, ok := groups[format[1]]; {
+	case ok:
+		d.format(b, group)
+	case format[:2] == "EE":
+		if d.year <= 0 {
+			b.WriteString("B.C.E.")
+		} else {
+			b.WriteString("C.E.")
+		}
+	default:
+		b.WriteString("%" + format[:2])
+	}
+}
+
+var clockAddOptions = []string{"-gmt", "-locale", "-timezone"}
+
+// clock add timeVal ?count unit...? ?-option value?
+//
+// Adds a (possibly negative) offset to a time that is expressed as an
+// integer number of seconds. The units are years, months, weeks,
+// days, weekdays, hours, minutes and seconds, or their singular
+// forms, and are applied in the order given. Weekdays skip Saturdays
+// and Sundays.
+//
+// Years, months, weeks, days and weekdays are added to the calendar
+// date in the time zone given by -gmt or -timezone, keeping the local
+// time of day, so that a day is not always 86400 seconds when the time
+// zone observes daylight saving time. A date past the end of a month
+// is moved back to the month's last day. Hours, minutes and seconds
+// are added to the absolute time.
+func cmdClockAdd(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 || len(args)%2 == 0 {
+		return nil, wrongNumArgs(args, 2, "clockval ?number units?... ?-gmt boolean? ?-locale LOCALE? ?-timezone ZONE?")
+	}
+	o := &clockOptions{}
+	var offsets []*Value
+	for i := 3; i < len(args); i += 2 {
+		if _, _, ok := parseInteger(args[i].String()); ok {
+			offsets = append(offsets, args[i], args[i+1])
+			continue
+		}
+		opt, err := lookupIndex(clockAddOptions, "option", args[i])
+		if err != nil {
+			return nil, badClockOption(args[i], clockAddOptions)
+		}
+		o.set(clockAddOptions[opt], args[i+1])
+	}
+	seconds, err := args[2].Int()
+	if err != nil {
+		return nil, err
+	}
+	loc, err := o.location()
+	if err != nil {
+		return nil, err
+	}
+	if err := o.checkLocale(); err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(offsets); i += 2 {
+		count, err := offsets[i].Int()
+		if err != nil {
+			return nil, err
+		}
+		if seconds, err = clockAdd(seconds, count, offsets[i+1].String(), loc); err != nil {
+			return nil, err
+		}
+	}
+	return NewIntValue(seconds), nil
+}
+
+// clockAdd returns seconds advanced by count of unit in loc.
+func clockAdd(seconds, count int64, unit string, loc *time.Location) (int64, error) {
+	switch unit {
+	case "years", "year":
+		return addMonths(seconds, 12*count, loc), nil
+	case "months", "month":
+		return addMonths(seconds, count, loc), nil
+	case "weeks", "week":
+		return addDays(seconds, 7*count, loc), nil
+	case "days", "day":
+		return addDays(seconds, count, loc), nil
+	case "weekdays", "weekday":
+		return addWeekdays(seconds, count, loc), nil
+	case "hours", "hour":
+		return seconds + 3600*count, nil
+	case "minutes", "minute":
+		return seconds + 60*count, nil
+	case "seconds", "second":
+		return seconds + count, nil
+	}
+	return 0, &Error{
+		Code: []string{"CLOCK", "badUnit", unit},
+		Msg:  fmt.Sprintf("unknown unit \"%s\", must be years, months, weeks, days, hours, minutes or seconds", unit),
+	}
+}
+
+func addMonths(seconds, months int64, loc *time.Location) int64 {
+	d := newClockDate(seconds, loc, jdGregorianChange)
+	m := int64(d.month-1) + months
+	year, month := d.year+floorDiv(m, 12), int(floorMod(m, 12))+1
+	day := min(d.dayOfMonth, daysInMonth(year, month, d.gregorian))
+	jd := julianDayOf(year, month, int64(day), jdGregorianChange)
+	return localToUTC(localSecondsOf(jd, d.secondOfDay()), loc)
+}
+
+func addDays(seconds, days int64, loc *time.Location) int64 {
+	d := newClockDate(seconds, loc, jdGregorianChange)
+	return localToUTC(localSecondsOf(d.julianDay+days, d.secondOfDay()), loc)
+}
+
+func addWeekdays(seconds, days int64, loc *time.Location) int64 {
+	d := newClockDate(seconds, loc, jdGregorianChange)
+	jd, step := d.julianDay, int64(1)
+	if days < 0 {
+		days, step = -days, -1
+	}
+	// Any seven consecutive days hold five weekdays.
+	if days > 5 {
+		weeks := (days - 1) / 5
+		jd += 7 * weeks * step
+		days -= 5 * weeks
+	}
+	for days > 0 {
+		jd += step
+		if floorMod(jd, 7) < 5 {
+			days--
+		}
+	}
+	return localToUTC(localSecondsOf(jd, d.secondOfDay()), loc)
+}
+
+var clockScanOptions = []string{"-base", "-format", "-gmt", "-locale", "-timezone"}
+
+// clock scan inputString ?-base time? ?-format format? ?-gmt boolean? ?-locale localeName? ?-timezone zoneName?
+//
+// Scans a time that is expressed as a human-readable string and
+// returns it as a count of seconds since the epoch.
+//
+// With -format, inputString must match format, whose format groups
+// are those of clock format. Each group matches the field it would
+// produce, white space in format matches any amount of white space,
+// and the names of days, months and AM and PM are matched without
+// regard to case. Fields missing from the string are taken from the
+// time given by -base, or the current time, in the time zone given
+// by -gmt or -timezone; a missing time of day is midnight.
+//
+// Without -format, inputString is scanned in the free form of Tcl's
+// earlier clock scan. It may hold a date such as 12/25/2024,
+// 2024-12-25, 25 Dec 2024 or Dec 25, 2024; a time of day such as 1pm,
+// 13:00 or 13:00:00 EST; the day of the week; relative times such as
+// "+2 weeks", "3 hours ago", "next month", "tomorrow" or "last
+// Monday"; and ISO 8601 forms such as 20241225T130000. -locale may not
+// be given with the free form, and otherwise may only name the root
+// locale.
+func cmdClockScan(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 || len(args)%2 == 0 {
+		return nil, wrongNumArgs(args, 2, "string ?-base seconds? ?-format string? ?-gmt boolean? ?-locale LOCALE? ?-timezone ZONE?")
+	}
+	o := &clockOptions{}
+	for i := 3; i < len(args); i += 2 {
+		opt, err := lookupIndex(clockScanOptions, "option", args[i])
+		if err != nil {
+			return nil, badClockOption(args[i], clockScanOptions)
+		}
+		o.set(clockScanOptions[opt], args[i+1])
+	}
+	base := time.Now().Unix()
+	if o.base != nil {
+		var err error
+		if base, err = o.base.Int(); err != nil {
+			return nil, err
+		}
+	}
+	loc, err := o.location()
+	if err != nil {
+		return nil, err
+	}
+	var seconds int64
+	if o.format == nil {
+		if o.locale != nil {
+			return nil, &Error{
+				Code: []string{"CLOCK", "flagWithLegacyFormat"},
+				Msg:  "legacy [clock scan] does not support -locale",
+			}
+		}
+		seconds, err = freeScan(args[2].String(), base, loc)
+	} else {
+		if err := o.checkLocale(); err != nil {
+			return nil, err
+		}
+		seconds, err = formatScan(args[2].String(), o.format.String(), base, loc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewIntValue(seconds), nil
+}
+
+// errBadInputString is returned by clock scan for a string that does
+// not match its format.
+var errBadInputString = &Error{
+	Code: []string{"CLOCK", "badInputString"},
+	Msg:  "input string does not match supplied format",
+}
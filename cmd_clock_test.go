@@ -0,0 +1,111 @@
+package gotcl
+
+import (
+	"testing"
+)
+
+func TestClockFormat(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `clock format 0 -gmt 1`, result: "Thu Jan 01 00:00:00 GMT 1970"},
+		{script: `clock format 1700000000 -gmt 1 -format {%Y-%m-%d %H:%M:%S %j %u %w}`, result: "2023-11-14 22:13:20 318 2 2"},
+		{script: `clock format 1700000000 -gmt 1 -format {%a %A %b %B %h}`, result: "Tue Tuesday Nov November Nov"},
+		{script: `clock format 1700000000 -gmt 1 -format {%D %T %R %r %p %I %l %e %k}`, result: "11/14/2023 22:13:20 22:13 10:13:20 pm PM 10 10 14 22"},
+		{script: `clock format 1700000000 -gmt 1 -format {%G %g %V %U %W %C %y %s %J %%}`, result: "2023 23 46 46 46 20 23 1700000000 2460263 %"},
+		{script: `clock format 1700000000 -gmt 1 -format {%c|%x|%X}`, result: "Tue Nov 14 22:13:20 2023|11/14/2023|22:13:20"},
+		{script: `clock format 1700000000 -gmt 1 -format {%Q}`, result: "Stardate 77868.9"},
+		{script: `clock format 1700000000 -gmt 1 -format {%Ey %EC %Od %Om %q %+}`, result: "23 20 14 11 %q Tue Nov 14 22:13:20 GMT 2023"},
+		{script: `clock format 1704067200 -gmt 1 -format {%G-W%V-%u}`, result: "2024-W01-1"},
+		{script: `clock format 1703980800 -gmt 1 -format {%G-W%V-%u %U %W}`, result: "2023-W52-7 53 52"},
+
+		{script: `clock format 1700000000 -timezone :America/New_York -format {%Y-%m-%d %H:%M %Z %z}`, result: "2023-11-14 17:13 EST -0500"},
+		{script: `clock format 1690000000 -timezone America/New_York -format {%H:%M %Z %z}`, result: "00:26 EDT -0400"},
+		{script: `clock format 1700000000 -timezone +05:30 -format {%H:%M %z}`, result: "03:43 +0530"},
+		{script: `clock format 1700000000 -timezone -0800 -format {%d %H:%M %z}`, result: "14 14:13 -0800"},
+		{script: `clock format 1690000000 -timezone EST5EDT,M3.2.0,M11.1.0 -format {%H:%M %Z %z}`, result: "00:26 EDT -0400"},
+		{script: `clock format 1700000000 -timezone EST5EDT,M3.2.0,M11.1.0 -format {%H:%M %Z %z}`, result: "17:13 EST -0500"},
+		{script: `clock format 1700000000 -timezone :Asia/Kolkata -format %z -locale current`, result: "+0530"},
+		{script: `clock format 0 -gmt 1 -format %Y -locale root`, result: "1970"},
+		{script: `clock format 0 -gmt 1 -format %r`, result: "12:00:00 am"},
+		{script: `clock format 0 -locale en`, err: `unsupported locale "en": must be C, current, or root`},
+		{script: `clock format 0 -locale fr`, err: `unsupported locale "fr": must be C, current, or root`},
+
+		{script: `clock format -12219292800 -gmt 1 -format %Y-%m-%d`, result: "1582-10-15"},
+		{script: `clock format -12219292801 -gmt 1 -format %Y-%m-%d`, result: "1582-10-04"},
+		{script: `clock format -86400000000 -gmt 1 -format {%Y %EY %EE}`, result: "0769 0769 B.C.E."},
+		{script: `clock format 0 -gmt 1 -format %EE`, result: "C.E."},
+
+		{script: `clock format 0 -timezone :Nowhere/Else`, err: `time zone ":Nowhere/Else" not found`},
+		{script: `clock format 0 -gmt 1 -timezone :UTC`, err: "cannot use -gmt and -timezone in same call"},
+		{script: `clock format 0 -bogus 1`, err: `bad option "-bogus": must be -format, -gmt, -locale, or -timezone`},
+		{script: `clock format 0 -gmt`, err: `wrong # args: should be "clock format clockval ?-format string? ?-gmt boolean? ?-locale LOCALE? ?-timezone ZONE?"`},
+		{script: `clock format x`, err: `expected integer but got "x"`},
+		{script: `clock bogus`, err: `unknown or ambiguous subcommand "bogus": must be add, clicks, format, microseconds, milliseconds, scan, or seconds`},
+		{script: `string is integer [clock seconds]`, result: "1"},
+		{script: `string is integer [clock clicks -milliseconds]`, result: "1"},
+	})
+}
+
+func TestClockAdd(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `clock add 0 1 day 2 hours 3 minutes 4 seconds -gmt 1`, result: "93784"},
+		{script: `clock format [clock add 1706659200 1 month -gmt 1] -gmt 1 -format %Y-%m-%d`, result: "2024-02-29"},
+		{script: `clock format [clock add 1706659200 1 year 1 month -gmt 1] -gmt 1 -format %Y-%m-%d`, result: "2025-02-28"},
+		{script: `clock format [clock add 1706659200 -2 weeks -gmt 1] -gmt 1 -format %Y-%m-%d`, result: "2024-01-17"},
+		{script: `clock format [clock add 1706832000 1 weekday -gmt 1] -gmt 1 -format {%a %Y-%m-%d}`, result: "Mon 2024-02-05"},
+		{script: `clock format [clock add 1706832000 -5 weekdays -gmt 1] -gmt 1 -format {%a %Y-%m-%d}`, result: "Fri 2024-01-26"},
+
+		// Days keep the time of day across a change to daylight saving
+		// time; hours do not.
+		{script: `clock format [clock add 1710003600 1 day -timezone :America/New_York] -timezone :America/New_York -format {%d %H:%M %Z}`, result: "10 12:00 EDT"},
+		{script: `clock format [clock add 1710003600 24 hours -timezone :America/New_York] -timezone :America/New_York -format {%d %H:%M %Z}`, result: "10 13:00 EDT"},
+
+		{script: `clock add 0 1 fortnight`, err: `unknown unit "fortnight", must be years, months, weeks, days, hours, minutes or seconds`},
+		{script: `clock add 0 1 day -locale C`, result: "86400"},
+		{script: `clock add 0 1 day -locale de_DE`, err: `unsupported locale "de_DE": must be C, current, or root`},
+		{script: `clock add 0 x days`, err: `bad option "x", must be -gmt, -locale or -timezone`},
+	})
+}
+
+func TestClockScan(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `clock scan 2024-12-25T13:00:00 -gmt 1`, result: "1735131600"},
+		{script: `clock scan 20241225T130000 -gmt 1`, result: "1735131600"},
+		{script: `clock scan {12/25/2024 13:00 EST}`, result: "1735149600"},
+		{script: `clock scan {1 Jan 2024 12:00:00-0500}`, result: "1704128400"},
+		{script: `clock scan {Dec 25, 2024 1:00pm} -gmt 1`, result: "1735131600"},
+		{script: `clock scan {2024-01-15 (a comment)} -timezone :Asia/Tokyo`, result: "1705244400"},
+		{script: `clock scan {} -base 1700000000 -gmt 1`, result: "1700000000"},
+		{script: `clock format [clock scan {10am tomorrow} -base 1700000000 -gmt 1] -gmt 1`, result: "Wed Nov 15 10:00:00 GMT 2023"},
+		{script: `clock format [clock scan {yesterday 23:59:59} -base 1700000000 -gmt 1] -gmt 1`, result: "Mon Nov 13 23:59:59 GMT 2023"},
+		{script: `clock format [clock scan {3 months 2 days ago} -base 1700000000 -gmt 1] -gmt 1`, result: "Sat Aug 12 00:00:00 GMT 2023"},
+		{script: `clock format [clock scan {next monday} -base 1700000000 -gmt 1] -gmt 1`, result: "Mon Nov 27 00:00:00 GMT 2023"},
+		{script: `clock format [clock scan {monday 10:00} -base 1700000000 -gmt 1] -gmt 1`, result: "Mon Nov 20 10:00:00 GMT 2023"},
+		{script: `clock format [clock scan 1/2/99 -base 1700000000 -gmt 1] -gmt 1 -format %Y`, result: "1999"},
+		{script: `clock format [clock scan 1/2/38 -base 1700000000 -gmt 1] -gmt 1 -format %Y`, result: "2038"},
+		{script: `clock format [clock scan {2:30 am 03/10/2024} -timezone :America/New_York] -timezone :America/New_York -format {%H:%M %Z}`, result: "03:30 EDT"},
+
+		{script: `clock scan 13pm`, err: `unable to convert date-time string "13pm": invalid time`},
+		{script: `clock scan {1/1/2000 1/2/2000}`, err: `unable to convert date-time string "1/1/2000 1/2/2000": more than one date in string`},
+		{script: `clock scan {10:00 +}`, err: `unable to convert date-time string "10:00 +": syntax error (characters 7-7)`},
+		{script: `clock scan today -locale fr`, err: "legacy [clock scan] does not support -locale"},
+		{script: `clock scan 2024 -format %Y -locale fr`, err: `unsupported locale "fr": must be C, current, or root`},
+
+		{script: `clock scan {2024-12-25 13:00} -format {%Y-%m-%d %H:%M} -gmt 1`, result: "1735131600"},
+		{script: `clock scan {Wed, 25 Dec 24 01:00:00 PM +0100} -format {%a, %d %b %y %I:%M:%S %p %z}`, result: "1735128000"},
+		{script: `clock scan {december 25 2024} -format {%B %d %Y} -gmt 1`, result: "1735084800"},
+		{script: `clock scan 2024-360 -format %Y-%j -gmt 1`, result: "1735084800"},
+		{script: `clock scan 2024-W52-3 -format %G-W%V-%u -gmt 1`, result: "1735084800"},
+		{script: `clock scan 2460263 -format %J -gmt 1`, result: "1699920000"},
+		{script: `clock scan 1700000000 -format %s -gmt 1`, result: "1700000000"},
+		{script: `clock scan 14 -format %d -base 1700000000 -gmt 1`, result: "1699920000"},
+		{script: `clock scan sat -format %a -base 1700000000 -gmt 1`, result: "1700265600"},
+		{script: `clock scan {10:30 EST} -format {%H:%M %Z} -base 1700000000`, result: "1699975800"},
+		{script: `clock format [clock scan [clock format 1700000000 -gmt 1] -format {%a %b %d %H:%M:%S %Z %Y}] -gmt 1`, result: "Tue Nov 14 22:13:20 GMT 2023"},
+
+		{script: `clock scan 2024-12 -format %Y-%m-%d`, err: "input string does not match supplied format"},
+		{script: `clock scan {10:30 XYZ} -format {%H:%M %Z}`, err: `time zone "XYZ" not found`},
+	})
+}
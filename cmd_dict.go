@@ -0,0 +1,706 @@
+package gotcl
+
+import (
+	"fmt"
+)
+
+var dictSubcommands = []subcommand{
+	{"append", cmdDictAppend},
+	{"create", cmdDictCreate},
+	{"exists", cmdDictExists},
+	{"filter", cmdDictFilter},
+	{"for", cmdDictFor},
+	{"get", cmdDictGet},
+	{"getdef", cmdDictGetWithDefault},
+	{"getwithdefault", cmdDictGetWithDefault},
+	{"incr", cmdDictIncr},
+	{"keys", cmdDictKeys},
+	{"lappend", cmdDictLappend},
+	{"map", cmdDictMap},
+	{"merge", cmdDictMerge},
+	{"remove", cmdDictRemove},
+	{"replace", cmdDictReplace},
+	{"set", cmdDictSet},
+	{"size", cmdDictSize},
+	{"unset", cmdDictUnset},
+	{"update", cmdDictUpdate},
+	{"values", cmdDictValues},
+	{"with", cmdDictWith},
+}
+
+// dict option arg ?arg ...?
+//
+// Performs one of several operations on dictionary values or
+// variables containing dictionary values, depending on option.
+func cmdDict(interp *Interp, args []*Value) (*Value, error) {
+	return dispatchSubcommand(interp, args, dictSubcommands)
+}
+
+// dictVar returns the dictionary stored in the variable called name,
+// or nil if the variable does not exist.
+func (interp *Interp) dictVar(name *Value) *Value {
+	v, err := interp.getVar(name.String())
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// dict append dictionaryVariable key ?string ...?
+//
+// This appends the given string (or strings) to the value that the
+// given key maps to in the dictionary value contained in the given
+// variable, writing the resulting dictionary value back to that
+// variable. Non-existent keys are treated as if they map to an empty
+// string.
+func cmdDictAppend(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 4 {
+		return nil, wrongNumArgs(args, 2, "dictVarName key ?value ...?")
+	}
+	dv := interp.dictVar(args[2])
+	var s string
+	if dv != nil {
+		d, err := dv.dict()
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := d.get(args[3]); ok {
+			s = v.String()
+		}
+	}
+	for _, arg := range args[4:] {
+		s += arg.String()
+	}
+	dv, err := dictSetPath(dv, args[3:4], NewStringValue(s))
+	if err != nil {
+		return nil, err
+	}
+	return interp.setVar(args[2].String(), dv)
+}
+
+// dict create ?key value ...?
+//
+// Return a new dictionary that contains each of the key/value
+// mappings listed as arguments (keys and values alternating, with
+// each key being followed by its associated value.)
+func cmdDictCreate(interp *Interp, args []*Value) (*Value, error) {
+	if len(args)%2 != 0 {
+		return nil, wrongNumArgs(args, 2, "?key value ...?")
+	}
+	d := newDictRep()
+	for i := 2; i < len(args); i += 2 {
+		d.set(args[i], args[i+1])
+	}
+	return newDictValue(d), nil
+}
+
+// dict exists dictionaryValue key ?key ...?
+//
+// This returns a boolean value indicating whether the given key (or
+// path of keys through a set of nested dictionaries) exists in the
+// given dictionary value.
+func cmdDictExists(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 4 {
+		return nil, wrongNumArgs(args, 2, "dictionary key ?key ...?")
+	}
+	v := args[2]
+	for i, key := range args[3:] {
+		d, err := v.dict()
+		if err != nil {
+			if i == 0 {
+				return nil, err
+			}
+			return NewBoolValue(false), nil
+		}
+		var ok bool
+		if v, ok = d.get(key); !ok {
+			return NewBoolValue(false), nil
+		}
+	}
+	return NewBoolValue(true), nil
+}
+
+var dictFilterTypes = []string{"key", "script", "value"}
+
+// dict filter dictionaryValue filterType arg ?arg ...?
+//
+// This takes a dictionary value and returns a new dictionary that
+// contains just those key/value pairs that match the specified
+// filter type (which may be abbreviated.) The key and value filter
+// types take any number of glob patterns; the script filter type
+// takes a list of two variable names and a script whose boolean
+// result decides whether each pair is kept.
+func cmdDictFilter(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 4 {
+		return nil, wrongNumArgs(args, 2, "dictionary filterType ?arg ...?")
+	}
+	d, err := args[2].dict()
+	if err != nil {
+		return nil, err
+	}
+	filter, err := lookupIndex(dictFilterTypes, "filterType", args[3])
+	if err != nil {
+		return nil, err
+	}
+	out := newDictRep()
+	switch dictFilterTypes[filter] {
+	case "key", "value":
+		for _, e := range d.entries {
+			v := e.key
+			if dictFilterTypes[filter] == "value" {
+				v = e.value
+			}
+			for _, pattern := range args[4:] {
+				if stringMatch(pattern.Runes(), v.Runes(), false) {
+					out.set(e.key, e.value)
+					break
+				}
+			}
+		}
+	case "script":
+		if len(args) != 6 {
+			return nil, wrongNumArgs(args, 2, "dictionary script {keyVarName valueVarName} filterScript")
+		}
+		keyVar, valueVar, err := dictLoopVars(args[4])
+		if err != nil {
+			return nil, err
+		}
+		body := args[5].Runes()
+	loop:
+		for _, e := range d.entries {
+			if err := interp.setDictLoopVars(keyVar, valueVar, e); err != nil {
+				return nil, err
+			}
+			result, err := interp.evalScript(body)
+			switch errorCode(err) {
+			case CodeOK:
+				keep, err := result.Bool()
+				if err != nil {
+					return nil, err
+				}
+				if keep {
+					out.set(e.key, e.value)
+				}
+			case CodeContinue:
+			case CodeBreak:
+				break loop
+			default:
+				return nil, err
+			}
+		}
+	}
+	return newDictValue(out), nil
+}
+
+// dictLoopVars returns the key and value variable names given as the
+// first argument of dict filter, for and map.
+func dictLoopVars(v *Value) (string, string, error) {
+	names, err := v.List()
+	if err != nil {
+		return "", "", err
+	}
+	if len(names) != 2 {
+		return "", "", fmt.Errorf("must have exactly two variable names")
+	}
+	return names[0].String(), names[1].String(), nil
+}
+
+func (interp *Interp) setDictLoopVars(keyVar, valueVar string, e dictEntry) error {
+	if _, err := interp.setVar(keyVar, e.key); err != nil {
+		return err
+	}
+	_, err := interp.setVar(valueVar, e.value)
+	return err
+}
+
+// dict for {keyVariable valueVariable} dictionaryValue body
+//
+// This command takes three arguments, the first a two-element list of
+// variable names (for the key and value respectively of each mapping
+// in the dictionary), the second the dictionary value to iterate
+// across, and the third a script to be evaluated for each mapping
+// with the key and value variables set appropriately (in the manner
+// of foreach.) The result of the command is an empty string.
+func cmdDictFor(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 5 {
+		return nil, wrongNumArgs(args, 2, "{keyVarName valueVarName} dictionary script")
+	}
+	keyVar, valueVar, err := dictLoopVars(args[2])
+	if err != nil {
+		return nil, err
+	}
+	d, err := args[3].dict()
+	if err != nil {
+		return nil, err
+	}
+	body := args[4].Runes()
+	for _, e := range d.entries {
+		if err := interp.setDictLoopVars(keyVar, valueVar, e); err != nil {
+			return nil, err
+		}
+		_, err := interp.evalScript(body)
+		switch errorCode(err) {
+		case CodeOK, CodeContinue:
+		case CodeBreak:
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// dict get dictionaryValue ?key ...?
+//
+// Given a dictionary value (first argument) and a key (second
+// argument), this will retrieve the value for that key. Where
+// several keys are supplied, the behaviour of the command shall be
+// as if the result of dict get $dictVal $key was passed as the first
+// argument to dict get with the remaining arguments as second (and
+// possibly subsequent) arguments. If no keys are provided, dict get
+// will return the dictionary.
+func cmdDictGet(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 2, "dictionary ?key ...?")
+	}
+	if len(args) == 3 {
+		if _, err := args[2].dict(); err != nil {
+			return nil, err
+		}
+		return args[2], nil
+	}
+	return dictGetPath(args[2], args[3:])
+}
+
+// dict getwithdefault dictionaryValue ?key ...? key default
+//
+// This behaves the same as dict get (with at least one key
+// argument), returning the value that the key path maps to in the
+// dictionary dictionaryValue, except that instead of producing an
+// error because the key (or one of the keys on the key path) is
+// absent, it returns the default argument instead.
+func cmdDictGetWithDefault(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 5 {
+		return nil, wrongNumArgs(args, 2, "dictionary ?key ...? key default")
+	}
+	v := args[2]
+	for _, key := range args[3 : len(args)-1] {
+		d, err := v.dict()
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if v, ok = d.get(key); !ok {
+			return args[len(args)-1], nil
+		}
+	}
+	return v, nil
+}
+
+// dict incr dictionaryVariable key ?increment?
+//
+// This adds the given increment value (an integer that defaults to 1
+// if not specified) to the value that the given key maps to in the
+// dictionary value contained in the given variable, writing the
+// resulting dictionary value back to that variable. Non-existent
+// keys are treated as if they map to 0.
+func cmdDictIncr(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 && len(args) != 5 {
+		return nil, wrongNumArgs(args, 2, "dictVarName key ?increment?")
+	}
+	inc := NewIntValue(1)
+	if len(args) == 5 {
+		inc = args[4]
+		if _, err := inc.BigInt(); err != nil {
+			return nil, err
+		}
+	}
+	dv := interp.dictVar(args[2])
+	v := NewIntValue(0)
+	if dv != nil {
+		d, err := dv.dict()
+		if err != nil {
+			return nil, err
+		}
+		if old, ok := d.get(args[3]); ok {
+			v = old
+		}
+	}
+	v, err := incrValue(v, inc)
+	if err != nil {
+		return nil, err
+	}
+	if dv, err = dictSetPath(dv, args[3:4], v); err != nil {
+		return nil, err
+	}
+	return interp.setVar(args[2].String(), dv)
+}
+
+// dictMatching returns the keys or values of the dictionary v,
+// restricted to those matching pattern if it is not nil.
+func dictMatching(v, pattern *Value, values bool) (*Value, error) {
+	d, err := v.dict()
+	if err != nil {
+		return nil, err
+	}
+	var out []*Value
+	for _, e := range d.entries {
+		match := e.key
+		if values {
+			match = e.value
+		}
+		if pattern == nil || stringMatch(pattern.Runes(), match.Runes(), false) {
+			out = append(out, match)
+		}
+	}
+	return NewListValue(out), nil
+}
+
+// dict keys dictionaryValue ?globPattern?
+//
+// Return a list of all keys in the given dictionary value. If a
+// pattern is supplied, only those keys that match it (according to
+// the rules of string match) will be returned. The returned keys
+// will be in the order that they were inserted into the dictionary.
+func cmdDictKeys(interp *Interp, args []*Value) (*Value, error) {
+	switch len(args) {
+	case 3:
+		return dictMatching(args[2], nil, false)
+	case 4:
+		return dictMatching(args[2], args[3], false)
+	}
+	return nil, wrongNumArgs(args, 2, "dictionary ?pattern?")
+}
+
+// dict values dictionaryValue ?globPattern?
+//
+// Return a list of all values in the given dictionary value. If a
+// pattern is supplied, only those values that match it (according to
+// the rules of string match) will be returned. The returned values
+// will be in the order of that the keys associated with those values
+// were inserted into the dictionary.
+func cmdDictValues(interp *Interp, args []*Value) (*Value, error) {
+	switch len(args) {
+	case 3:
+		return dictMatching(args[2], nil, true)
+	case 4:
+		return dictMatching(args[2], args[3], true)
+	}
+	return nil, wrongNumArgs(args, 2, "dictionary ?pattern?")
+}
+
+// dict lappend dictionaryVariable key ?value ...?
+//
+// This appends the given items to the list value that the given key
+// maps to in the dictionary value contained in the given variable,
+// writing the resulting dictionary value back to that variable.
+// Non-existent keys are treated as if they map to an empty list.
+func cmdDictLappend(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 4 {
+		return nil, wrongNumArgs(args, 2, "dictVarName key ?value ...?")
+	}
+	dv := interp.dictVar(args[2])
+	var elems []*Value
+	if dv != nil {
+		d, err := dv.dict()
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := d.get(args[3]); ok {
+			if elems, err = v.List(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	out := make([]*Value, 0, len(elems)+len(args)-4)
+	out = append(out, elems...)
+	out = append(out, args[4:]...)
+	dv, err := dictSetPath(dv, args[3:4], NewListValue(out))
+	if err != nil {
+		return nil, err
+	}
+	return interp.setVar(args[2].String(), dv)
+}
+
+// dict map {keyVariable valueVariable} dictionaryValue body
+//
+// This command applies a transformation to each element of a
+// dictionary, returning a new dictionary. The result of each
+// evaluation of body becomes the new value for the current key;
+// continue skips the key and break ends the iteration early.
+func cmdDictMap(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 5 {
+		return nil, wrongNumArgs(args, 2, "{keyVarName valueVarName} dictionary script")
+	}
+	keyVar, valueVar, err := dictLoopVars(args[2])
+	if err != nil {
+		return nil, err
+	}
+	d, err := args[3].dict()
+	if err != nil {
+		return nil, err
+	}
+	body := args[4].Runes()
+	out := newDictRep()
+	for _, e := range d.entries {
+		if err := interp.setDictLoopVars(keyVar, valueVar, e); err != nil {
+			return nil, err
+		}
+		result, err := interp.evalScript(body)
+		switch errorCode(err) {
+		case CodeOK:
+			key, err := interp.getVar(keyVar)
+			if err != nil {
+				return nil, err
+			}
+			out.set(key, result)
+		case CodeContinue:
+		case CodeBreak:
+			return newDictValue(out), nil
+		default:
+			return nil, err
+		}
+	}
+	return newDictValue(out), nil
+}
+
+// dict merge ?dictionaryValue ...?
+//
+// Return a dictionary that contains the contents of each of the
+// dictionaryValue arguments. Where two (or more) dictionaries
+// contain a mapping for the same key, the resulting dictionary maps
+// that key to the value according to the last dictionary on the
+// command line containing a mapping for that key.
+func cmdDictMerge(interp *Interp, args []*Value) (*Value, error) {
+	out := newDictRep()
+	for _, arg := range args[2:] {
+		d, err := arg.dict()
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range d.entries {
+			out.set(e.key, e.value)
+		}
+	}
+	return newDictValue(out), nil
+}
+
+// dict remove dictionaryValue ?key ...?
+//
+// Return a new dictionary that is a copy of an old one passed in as
+// first argument except without mappings for each of the keys listed.
+func cmdDictRemove(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 2, "dictionary ?key ...?")
+	}
+	d, err := args[2].dict()
+	if err != nil {
+		return nil, err
+	}
+	out := d.clone()
+	for _, key := range args[3:] {
+		out.remove(key)
+	}
+	return newDictValue(out), nil
+}
+
+// dict replace dictionaryValue ?key value ...?
+//
+// Return a new dictionary that is a copy of an old one passed in as
+// first argument except with some values different or some extra
+// key/value pairs added.
+func cmdDictReplace(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 || len(args)%2 == 0 {
+		return nil, wrongNumArgs(args, 2, "dictionary ?key value ...?")
+	}
+	d, err := args[2].dict()
+	if err != nil {
+		return nil, err
+	}
+	out := d.clone()
+	for i := 3; i < len(args); i += 2 {
+		out.set(args[i], args[i+1])
+	}
+	return newDictValue(out), nil
+}
+
+// dict set dictionaryVariable key ?key ...? value
+//
+// This operation takes the name of a variable containing a
+// dictionary value and places an updated dictionary value in that
+// variable containing a mapping from the given key to the given
+// value. When multiple keys are present, this operation creates or
+// updates a chain of nested dictionaries.
+func cmdDictSet(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 5 {
+		return nil, wrongNumArgs(args, 2, "dictVarName key ?key ...? value")
+	}
+	dv, err := dictSetPath(interp.dictVar(args[2]), args[3:len(args)-1], args[len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+	return interp.setVar(args[2].String(), dv)
+}
+
+// dict size dictionaryValue
+//
+// Return the number of key/value mappings in the given dictionary
+// value.
+func cmdDictSize(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "dictionary")
+	}
+	d, err := args[2].dict()
+	if err != nil {
+		return nil, err
+	}
+	return NewIntValue(int64(len(d.entries))), nil
+}
+
+// dict unset dictionaryVariable key ?key ...?
+//
+// This operation (the companion to dict set) takes the name of a
+// variable containing a dictionary value and places an updated
+// dictionary value in that variable that does not contain a mapping
+// for the given key. Where multiple keys are present, this describes
+// a path through nested dictionaries to the mapping to remove. At
+// least one key must be specified, but the last key on the key-path
+// need not exist. All other components on the path must exist.
+func cmdDictUnset(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 4 {
+		return nil, wrongNumArgs(args, 2, "dictVarName key ?key ...?")
+	}
+	dv := interp.dictVar(args[2])
+	if dv == nil {
+		dv = newDictValue(newDictRep())
+	}
+	dv, err := dictUnsetPath(dv, args[3:])
+	if err != nil {
+		return nil, err
+	}
+	return interp.setVar(args[2].String(), dv)
+}
+
+// dict update dictionaryVariable key varName ?key varName ...? body
+//
+// Execute the Tcl script in body with the value for each key (as
+// found by reading the dictionary value in dictionaryVariable) mapped
+// to the variable varName. There may be multiple key/varName pairs.
+// If a key does not have a mapping, that corresponds to an unset
+// varName. When body terminates, any changes made to the varNames is
+// reflected back to the dictionary within dictionaryVariable (unless
+// dictionaryVariable itself becomes unreadable, when all updates are
+// silently discarded), even if the result of body is an error or
+// some other kind of exceptional exit. The result of dict update is
+// (unless some kind of error occurs) the result of the evaluation of
+// body.
+func cmdDictUpdate(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 6 || len(args)%2 != 0 {
+		return nil, wrongNumArgs(args, 2, "dictVarName key varName ?key varName ...? script")
+	}
+	pairs := args[3 : len(args)-1]
+	dv := interp.dictVar(args[2])
+	if dv == nil {
+		dv = newDictValue(newDictRep())
+	}
+	d, err := dv.dict()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(pairs); i += 2 {
+		name := pairs[i+1].String()
+		if v, ok := d.get(pairs[i]); ok {
+			if _, err := interp.setVar(name, v); err != nil {
+				return nil, err
+			}
+		} else {
+			_ = interp.unsetVar(name)
+		}
+	}
+	result, bodyErr := interp.evalScript(args[len(args)-1].Runes())
+
+	// Write the variables back into the dictionary.
+	dv = interp.dictVar(args[2])
+	if dv == nil {
+		return result, bodyErr
+	}
+	if d, err = dv.dict(); err != nil {
+		return nil, err
+	}
+	out := d.clone()
+	for i := 0; i < len(pairs); i += 2 {
+		if v, err := interp.getVar(pairs[i+1].String()); err == nil {
+			out.set(pairs[i], v)
+		} else {
+			out.remove(pairs[i])
+		}
+	}
+	if _, err := interp.setVar(args[2].String(), newDictValue(out)); err != nil {
+		return nil, err
+	}
+	return result, bodyErr
+}
+
+// dict with dictionaryVariable ?key ...? body
+//
+// Execute the Tcl script in body with the value for each key in
+// dictionaryVariable mapped (in a manner similarly to dict update) to
+// a variable with the same name. Where one or more keys are
+// available, these indicate a chain of nested dictionaries, with the
+// innermost dictionary being the one opened out for the execution of
+// body. Changes made to the variables are reflected back to the
+// dictionary when body terminates.
+func cmdDictWith(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 4 {
+		return nil, wrongNumArgs(args, 2, "dictVarName ?key ...? script")
+	}
+	keys := args[3 : len(args)-1]
+	dv, err := interp.getVar(args[2].String())
+	if err != nil {
+		return nil, err
+	}
+	inner, err := dictGetPath(dv, keys)
+	if err != nil {
+		return nil, err
+	}
+	d, err := inner.dict()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range d.entries {
+		if _, err := interp.setVar(e.key.String(), e.value); err != nil {
+			return nil, err
+		}
+	}
+	result, bodyErr := interp.evalScript(args[len(args)-1].Runes())
+
+	// Write the variables back into the dictionary.
+	if dv, err = interp.getVar(args[2].String()); err != nil {
+		return result, bodyErr
+	}
+	if inner, err = dictGetPath(dv, keys); err != nil {
+		return nil, err
+	}
+	cur, err := inner.dict()
+	if err != nil {
+		return nil, err
+	}
+	out := cur.clone()
+	for _, e := range d.entries {
+		if v, err := interp.getVar(e.key.String()); err == nil {
+			out.set(e.key, v)
+		} else {
+			out.remove(e.key)
+		}
+	}
+	inner = newDictValue(out)
+	if len(keys) > 0 {
+		if inner, err = dictSetPath(dv, keys, inner); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := interp.setVar(args[2].String(), inner); err != nil {
+		return nil, err
+	}
+	return result, bodyErr
+}
@@ -0,0 +1,62 @@
+package gotcl
+
+import (
+	"testing"
+)
+
+func TestDictCommand(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `dict create a 1 b 2 a 3`, result: "a 3 b 2"},
+		{script: `dict create a`, err: `wrong # args: should be "dict create ?key value ...?"`},
+		{script: `dict get {a 1 b {c 2}} b c`, result: "2"},
+		{script: `dict get {a 1 b 2}`, result: "a 1 b 2"},
+		{script: `dict get {a 1} x`, err: `key "x" not known in dictionary`},
+		{script: `dict get {a 1 b} a`, err: `missing value to go with key`},
+		{script: `dict getwithdefault {a {b 1}} a c 42`, result: "42"},
+		{script: `dict getdef {a {b 1}} a b 42`, result: "1"},
+		{script: `dict exists {a {b 1}} a b`, result: "1"},
+		{script: `dict exists {a {b 1}} a c`, result: "0"},
+		{script: `dict exists {a 1} a b`, result: "0"},
+		{script: `dict keys {a 1 b 2 ab 3} a*`, result: "a ab"},
+		{script: `dict values {a 1 b 2}`, result: "1 2"},
+		{script: `dict size {a 1 b 2}`, result: "2"},
+		{script: `dict merge {a 1 b 2} {b 3 c 4}`, result: "a 1 b 3 c 4"},
+		{script: `dict remove {a 1 b 2 c 3} b x`, result: "a 1 c 3"},
+		{script: `dict replace {a 1 b 2} b 3 c 4`, result: "a 1 b 3 c 4"},
+		{script: `dict filter {a 1 b 2 ab 3} key a*`, result: "a 1 ab 3"},
+		{script: `dict filter {a 1 b 2 c 3} value 2 3`, result: "b 2 c 3"},
+		{script: `dict filter {a 1 b 2 c 3} script {k v} {string equal $v 2}`, result: "b 2"},
+		{script: `dict filter {a 1} bogus`, err: `bad filterType "bogus": must be key, script, or value`},
+		{script: `dict map {k v} {a 1 b 2} {string cat $k $v}`, result: "a a1 b b2"},
+		{script: `dict map {k v} {a 1 b 2} {continue}`, result: ""},
+		{script: `dict map {k v} {a 1 b 2} {break}`, result: ""},
+		{script: `set r {}; dict for {k v} {a 1 b 2 c 3} {lappend r $k; string equal $k b}; set r`, result: "a b c"},
+		{script: `set r {}; dict for {k v} {a 1 b 2 c 3} {lappend r $k; break}; set r`, result: "a"},
+		{script: `dict for {k} {a 1} {}`, err: `must have exactly two variable names`},
+		{script: `dict bogus`, err: `unknown or ambiguous subcommand "bogus": must be append, create, exists, filter, for, get, getdef, getwithdefault, incr, keys, lappend, map, merge, remove, replace, set, size, unset, update, values, or with`},
+	})
+}
+
+func TestDictVariables(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `dict set d a 1`, result: "a 1"},
+		{script: `dict set d b c d 2`, result: "a 1 b {c {d 2}}"},
+		{script: `dict set d b c e 3`, result: "a 1 b {c {d 2 e 3}}"},
+		{script: `dict set d a 4`, result: "a 4 b {c {d 2 e 3}}"},
+		{script: `dict unset d b c d`, result: "a 4 b {c {e 3}}"},
+		{script: `dict unset d x y`, err: `key "x" not known in dictionary`},
+		{script: `dict unset d x`, result: "a 4 b {c {e 3}}"},
+		{script: `dict incr d a`, result: "a 5 b {c {e 3}}"},
+		{script: `dict incr d n 10`, result: "a 5 b {c {e 3}} n 10"},
+		{script: `dict incr d b`, err: `expected integer but got "c {e 3}"`},
+		{script: `dict append d s foo bar`, result: "a 5 b {c {e 3}} n 10 s foobar"},
+		{script: `dict lappend d l x {y z}`, result: "a 5 b {c {e 3}} n 10 s foobar l {x {y z}}"},
+		{script: `set u {a 1 b 2}; dict update u a x b y {set x 10; unset y; set z 1}`, result: "1"},
+		{script: `set u`, result: "a 10"},
+		{script: `set w {p {q 1 r 2}}; dict with w p {set q 5}; set w`, result: "p {q 5 r 2}"},
+		{script: `dict with w {set p}`, result: "q 5 r 2"},
+		{script: `unset -nocomplain nd; dict with nd {}`, err: `can't read "nd": no such variable`},
+	})
+}
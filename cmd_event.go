@@ -0,0 +1,235 @@
+package gotcl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var afterSubcommands = []string{"cancel", "idle", "info"}
+
+// after ms ?script script script ...?
+// after cancel id|script script script ...
+// after idle script ?script script ...?
+// after info ?id?
+//
+// This command is used to delay execution of the program or to
+// execute a command in background sometime in the future. With only
+// ms, it sleeps for ms milliseconds. With one or more scripts, it
+// concatenates them like concat and arranges for the result to be
+// evaluated at global level after ms milliseconds, returning an
+// identifier that can be used to cancel it. The script is only run
+// while the application is processing events, for example in vwait
+// or update.
+//
+// after idle arranges for the script to be evaluated the next time
+// the event loop is entered and there are no events to process.
+// after cancel cancels the handler with the given identifier, or the
+// first handler whose script matches. after info returns the
+// identifiers of all existing handlers, or the script and type
+// (timer or idle) of the handler with the given identifier.
+func cmdAfter(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "option ?arg ...?")
+	}
+	el := interp.events
+	if ms, err := args[1].Int(); err == nil {
+		delay := time.Duration(max(0, min(ms, int64(time.Duration(1<<62)/time.Millisecond)))) * time.Millisecond
+		if len(args) == 2 {
+			time.Sleep(delay)
+			return nil, nil
+		}
+		return interp.createAfterEvent(args[2:], time.Now().Add(delay), false), nil
+	}
+	sub, err := lookupIndex(afterSubcommands, "argument", args[1])
+	if err != nil {
+		return nil, fmt.Errorf("bad argument \"%s\": must be cancel, idle, info, or an integer", args[1])
+	}
+	switch afterSubcommands[sub] {
+	case "cancel":
+		if len(args) < 3 {
+			return nil, wrongNumArgs(args, 2, "id|command")
+		}
+		if len(args) == 3 {
+			if ev := interp.lookupAfterEvent(args[2].String()); ev != nil {
+				interp.removeAfterEvent(ev)
+				return nil, nil
+			}
+		}
+		script := concatValues(args[2:])
+		for _, ev := range el.afterEvents {
+			if ev.script.String() == script {
+				interp.removeAfterEvent(ev)
+				break
+			}
+		}
+		return nil, nil
+	case "idle":
+		if len(args) < 3 {
+			return nil, wrongNumArgs(args, 2, "script ?script ...?")
+		}
+		return interp.createAfterEvent(args[2:], time.Time{}, true), nil
+	}
+	if len(args) > 3 {
+		return nil, wrongNumArgs(args, 2, "?id?")
+	}
+	if len(args) == 2 {
+		ids := make([]*Value, len(el.afterEvents))
+		for i, ev := range el.afterEvents {
+			ids[len(ids)-1-i] = NewStringValue(ev.id)
+		}
+		return NewListValue(ids), nil
+	}
+	ev := interp.lookupAfterEvent(args[2].String())
+	if ev == nil {
+		return nil, fmt.Errorf("event \"%s\" doesn't exist", args[2])
+	}
+	kind := "timer"
+	if ev.idle {
+		kind = "idle"
+	}
+	return NewListValue([]*Value{ev.script, NewStringValue(kind)}), nil
+}
+
+// createAfterEvent creates a timer handler due at when, or an idle
+// handler, for the concatenation of scripts and returns its
+// identifier.
+func (interp *Interp) createAfterEvent(scripts []*Value, when time.Time, idle bool) *Value {
+	el := interp.events
+	script := scripts[0]
+	if len(scripts) > 1 {
+		script = NewStringValue(concatValues(scripts))
+	}
+	ev := &afterEvent{
+		id:     fmt.Sprintf("after#%d", el.nextAfterID),
+		script: script,
+		when:   when,
+		idle:   idle,
+	}
+	el.nextAfterID++
+	el.afterEvents = append(el.afterEvents, ev)
+	return NewStringValue(ev.id)
+}
+
+func (interp *Interp) lookupAfterEvent(id string) *afterEvent {
+	if !strings.HasPrefix(id, "after#") {
+		return nil
+	}
+	for _, ev := range interp.events.afterEvents {
+		if ev.id == id {
+			return ev
+		}
+	}
+	return nil
+}
+
+// fileevent channelId readable ?script?
+// fileevent channelId writable ?script?
+//
+// This command is used to create file event handlers. A file event
+// handler is a binding between a channel and a script, such that
+// the script is evaluated whenever the channel becomes readable or
+// writable. A channel is considered to be readable if there is
+// unread data available, or if an end of file condition is present.
+// A channel is considered to be writable if at least one byte of
+// data can be written to it without blocking.
+//
+// If the script argument is specified, fileevent creates a new event
+// handler, replacing any existing one; if script is an empty string
+// the handler is deleted. If script is omitted, the current script
+// is returned. A handler whose script returns an error is deleted
+// after the error is reported with bgerror.
+func cmdFileevent(interp *Interp, args []*Value) (*Value, error) {
+	return channelEvent(interp, args, 1)
+}
+
+func channelEvent(interp *Interp, args []*Value, n int) (*Value, error) {
+	if len(args) != n+2 && len(args) != n+3 {
+		return nil, wrongNumArgs(args, n, "channelId event ?script?")
+	}
+	ch, err := interp.lookupChannel(args[n].String())
+	if err != nil {
+		return nil, err
+	}
+	event, err := lookupIndex([]string{"readable", "writable"}, "event name", args[n+1])
+	if err != nil {
+		return nil, err
+	}
+	readable := event == 0
+	switch {
+	case readable && ch.r == nil:
+		return nil, fmt.Errorf("channel is not readable")
+	case !readable && ch.w == nil:
+		return nil, fmt.Errorf("channel is not writable")
+	}
+	if len(args) == n+2 {
+		h := interp.fileHandler(ch)
+		switch {
+		case h == nil:
+			return nil, nil
+		case readable:
+			return h.readable, nil
+		}
+		return h.writable, nil
+	}
+	script := args[n+2]
+	if script.String() == "" {
+		script = nil
+	}
+	interp.setFileHandler(ch, readable, script)
+	return nil, nil
+}
+
+// update ?idletasks?
+//
+// This command is used to bring the application "up to date" by
+// entering the event loop repeatedly until all pending events
+// (including idle callbacks) have been processed. If the idletasks
+// keyword is specified as an argument to the command, then no new
+// events are processed; only idle callbacks are invoked.
+func cmdUpdate(interp *Interp, args []*Value) (*Value, error) {
+	flags := AllEvents | DontWait
+	switch len(args) {
+	case 1:
+	case 2:
+		if _, err := lookupIndex([]string{"idletasks"}, "option", args[1]); err != nil {
+			return nil, err
+		}
+		flags = IdleEvents | DontWait
+	default:
+		return nil, wrongNumArgs(args, 1, "?idletasks?")
+	}
+	for interp.DoOneEvent(flags) {
+	}
+	return nil, nil
+}
+
+// vwait varName
+//
+// This command enters the Tcl event loop to process events,
+// blocking the application if no events are ready. It continues
+// processing events until some event handler sets the value of the
+// global variable varName. Once varName has been set, the vwait
+// command will return as soon as the event handler that modified
+// varName completes.
+func cmdVwait(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 2 {
+		return nil, wrongNumArgs(args, 1, "name")
+	}
+	name := args[1].String()
+	done := false
+	remove, err := interp.TraceVar(name, TraceWrite|TraceUnset, func(interp *Interp, name1, name2 string, op TraceOp) error {
+		done = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer remove()
+	for !done {
+		if !interp.DoOneEvent(AllEvents) {
+			return nil, fmt.Errorf("can't wait for variable \"%s\": would wait forever", name)
+		}
+	}
+	return nil, nil
+}
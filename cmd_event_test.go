@@ -0,0 +1,164 @@
+package gotcl
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAfter(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `after 20 {lappend order slow}`, result: "after#0"},
+		{script: `after 0 lappend order fast`, result: "after#1"},
+		{script: `after idle {lappend order idle}`, result: "after#2"},
+		{script: `after info`, result: "after#2 after#1 after#0"},
+		{script: `after info after#1`, result: "{lappend order fast} timer"},
+		{script: `after info after#2`, result: "{lappend order idle} idle"},
+		{script: `after 10 {set done 1}; vwait done; set order`, result: "fast idle"},
+		{script: `vwait order; set order`, result: "fast idle slow"},
+		{script: `after info`, result: ""},
+		{script: `after 0 {lappend x a}; after 0 {lappend x b}; after cancel after#5; update; set x`, result: "a"},
+		{script: `after idle {lappend y a}; after 0 {lappend y b}; update idletasks; set y`, result: "a"},
+		{script: `update; set y`, result: "a b"},
+		{script: `after 0 lappend z a; after 0 {lappend z b}; after cancel lappend z a; update; set z`, result: "b"},
+		{script: `proc wait {} {after 0 {set fromEvent 1}; update}; wait; set fromEvent`, result: "1"},
+		{script: `after 0 {set ::ta 1}; after 0 {set ::tb 1}; vwait ta; info exists tb`, result: "1"},
+		{script: `after cancel bogus`},
+		{script: `after 1`},
+		{script: `after info bogus`, err: `event "bogus" doesn't exist`},
+		{script: `after bogus`, err: `bad argument "bogus": must be cancel, idle, info, or an integer`},
+		{script: `after`, err: `wrong # args: should be "after option ?arg ...?"`},
+		{script: `after cancel`, err: `wrong # args: should be "after cancel id|command"`},
+		{script: `after idle`, err: `wrong # args: should be "after idle script ?script ...?"`},
+		{script: `update now`, err: `bad option "now": must be idletasks`},
+		{script: `vwait nothing`, err: `can't wait for variable "nothing": would wait forever`},
+	})
+}
+
+func TestBackgroundError(t *testing.T) {
+	interp := NewInterp()
+	interp.CreateCommand("bgerror", func(interp *Interp, args []*Value) (*Value, error) {
+		return interp.setVar("reported", args[1])
+	})
+	runEvalTests(t, interp, []evalTest{
+		{script: `after 0 {error-command}; vwait reported; set reported`, result: `invalid command name "error-command"`},
+	})
+}
+
+func TestQueueEvent(t *testing.T) {
+	interp := NewInterp()
+	release := interp.HoldEvents()
+	go func() {
+		defer release()
+		time.Sleep(10 * time.Millisecond)
+		interp.QueueEvent(func(interp *Interp) error {
+			_, err := interp.Eval(`set result done`)
+			return err
+		})
+	}()
+	runEvalTests(t, interp, []evalTest{
+		{script: `vwait result; set result`, result: "done"},
+	})
+	interp.QueueEvent(func(interp *Interp) error {
+		return errors.New("queued failure")
+	})
+	var reported string
+	interp.CreateCommand("bgerror", func(interp *Interp, args []*Value) (*Value, error) {
+		reported = args[1].String()
+		return nil, nil
+	})
+	if !interp.DoOneEvent(AllEvents | DontWait) {
+		t.Fatal("queued event was not processed")
+	}
+	if reported != "queued failure" {
+		t.Errorf("background error = %q", reported)
+	}
+	if interp.DoOneEvent(AllEvents) {
+		t.Error("DoOneEvent processed an event with nothing queued")
+	}
+}
+
+// pipeChannel is a channel whose input is written to a pipe by the
+// test.
+type pipeChannel struct {
+	*io.PipeReader
+	strings.Builder
+}
+
+func TestFileevent(t *testing.T) {
+	interp := NewInterp()
+	interp.CreateCommand("bgerror", func(interp *Interp, args []*Value) (*Value, error) {
+		return nil, nil
+	})
+	r, w := io.Pipe()
+	if err := interp.RegisterChannel("pipe", &pipeChannel{PipeReader: r}); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		io.WriteString(w, "one\n")
+		time.Sleep(10 * time.Millisecond)
+		io.WriteString(w, "two\n")
+		w.Close()
+	}()
+	runEvalTests(t, interp, []evalTest{
+		{script: `fileevent pipe readable {lappend lines [gets pipe]}`},
+		{script: `fileevent pipe readable`, result: "lappend lines [gets pipe]"},
+		{script: `vwait lines; vwait lines; set lines`, result: "one two"},
+		{script: `vwait lines; list $lines [eof pipe]`, result: "{one two {}} 1"},
+		{script: `chan event pipe readable {}; chan event pipe readable`, result: ""},
+		{script: `fileevent pipe writable {set w 1}; vwait w`},
+		{script: `fileevent pipe writable error-command`},
+		{script: `update; fileevent pipe writable`, result: ""},
+		{script: `fileevent stdin writable x`, err: `channel is not writable`},
+		{script: `fileevent pipe bogus x`, err: `bad event name "bogus": must be readable or writable`},
+		{script: `fileevent pipe`, err: `wrong # args: should be "fileevent channelId event ?script?"`},
+	})
+}
+
+func TestFileeventWrite(t *testing.T) {
+	interp := NewInterp()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The readable handler leaves a read pending, which the
+		// writes must not wait for.
+		runEvalTests(t, interp, []evalTest{
+			{script: `set ch [open "|cat" r+]; fileevent $ch readable {set got [gets $ch]}; update`},
+			{script: `puts $ch hello; flush $ch`},
+			{script: `vwait got; set got`, result: "hello"},
+			{script: `close $ch`},
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writing to a pipeline with a readable handler did not return")
+	}
+}
+
+func TestNonblockingInput(t *testing.T) {
+	interp := NewInterp()
+	r, w := io.Pipe()
+	if err := interp.RegisterChannel("pipe", &pipeChannel{PipeReader: r}); err != nil {
+		t.Fatal(err)
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `fconfigure pipe -blocking 0; list [gets pipe line] [chan blocked pipe]`, result: "-1 1"},
+	})
+	// The background read started by gets takes the whole write.
+	io.WriteString(w, "one\ntw")
+	runEvalTests(t, interp, []evalTest{
+		{script: `fileevent pipe readable {set ready 1}; vwait ready; fileevent pipe readable {}`},
+		{script: `list [gets pipe line] $line [chan blocked pipe]`, result: "3 one 0"},
+		{script: `list [gets pipe line] [chan blocked pipe]`, result: "-1 1"},
+		{script: `list [read pipe] [chan blocked pipe] [eof pipe]`, result: "tw 1 0"},
+	})
+	w.Close()
+	runEvalTests(t, interp, []evalTest{
+		{script: `fileevent pipe readable {set ready 2}; vwait ready; fileevent pipe readable {}`},
+		{script: `list [read pipe] [chan blocked pipe] [eof pipe]`, result: "{} 0 1"},
+	})
+}
@@ -0,0 +1,1290 @@
+package gotcl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var fileSubcommands = []subcommand{
+	{"atime", cmdFileAtime},
+	{"attributes", cmdFileAttributes},
+	{"copy", cmdFileCopy},
+	{"delete", cmdFileDelete},
+	{"dirname", cmdFileDirname},
+	{"executable", cmdFileExecutable},
+	{"exists", cmdFileExists},
+	{"extension", cmdFileExtension},
+	{"home", cmdFileHome},
+	{"isdirectory", cmdFileIsdirectory},
+	{"isfile", cmdFileIsfile},
+	{"join", cmdFileJoin},
+	{"link", cmdFileLink},
+	{"lstat", cmdFileLstat},
+	{"mkdir", cmdFileMkdir},
+	{"mtime", cmdFileMtime},
+	{"nativename", cmdFileNativename},
+	{"normalize", cmdFileNormalize},
+	{"pathtype", cmdFilePathtype},
+	{"readable", cmdFileReadable},
+	{"readlink", cmdFileReadlink},
+	{"rename", cmdFileRename},
+	{"rootname", cmdFileRootname},
+	{"separator", cmdFileSeparator},
+	{"size", cmdFileSize},
+	{"split", cmdFileSplit},
+	{"stat", cmdFileStat},
+	{"tail", cmdFileTail},
+	{"tempfile", cmdFileTempfile},
+	{"tildeexpand", cmdFileTildeexpand},
+	{"type", cmdFileType},
+	{"writable", cmdFileWritable},
+}
+
+// file option name ?arg arg ...?
+//
+// This command provides several operations on a file's name or
+// attributes. Name is the name of a file; the file system operations
+// go through the VFS of the interpreter, the host filesystem unless
+// the host has set another. File names use / as the separator and a
+// leading / makes them absolute; unlike earlier versions of Tcl, a
+// leading ~ is not special except to file tildeexpand.
+func cmdFile(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "subcommand ?arg ...?")
+	}
+	return dispatchSubcommand(interp, args, fileSubcommands)
+}
+
+// statFields holds the status of a file that fs.FileInfo does not
+// provide directly.
+type statFields struct {
+	dev, ino, nlink uint64
+	uid, gid        uint64
+	atime, ctime    time.Time
+	mtime           time.Time
+}
+
+// fileStat returns the status of the file described by info.
+func fileStat(info fs.FileInfo) statFields {
+	if s, ok := info.Sys().(*statFields); ok {
+		return *s
+	}
+	s := statFields{nlink: 1, atime: info.ModTime(), ctime: info.ModTime(), mtime: info.ModTime()}
+	sysStat(info, &s)
+	return s
+}
+
+// fileType returns the name file type uses for the type of mode.
+func fileType(mode fs.FileMode) string {
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		return "link"
+	case mode.IsDir():
+		return "directory"
+	case mode&fs.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&fs.ModeSocket != 0:
+		return "socket"
+	case mode&fs.ModeCharDevice != 0:
+		return "characterSpecial"
+	case mode&fs.ModeDevice != 0:
+		return "blockSpecial"
+	}
+	return "file"
+}
+
+// unixMode returns mode as a POSIX st_mode.
+func unixMode(mode fs.FileMode) int64 {
+	m := int64(mode.Perm())
+	switch fileType(mode) {
+	case "link":
+		m |= syscall.S_IFLNK
+	case "directory":
+		m |= syscall.S_IFDIR
+	case "fifo":
+		m |= syscall.S_IFIFO
+	case "socket":
+		m |= syscall.S_IFSOCK
+	case "characterSpecial":
+		m |= syscall.S_IFCHR
+	case "blockSpecial":
+		m |= syscall.S_IFBLK
+	default:
+		m |= syscall.S_IFREG
+	}
+	if mode&fs.ModeSetuid != 0 {
+		m |= syscall.S_ISUID
+	}
+	if mode&fs.ModeSetgid != 0 {
+		m |= syscall.S_ISGID
+	}
+	if mode&fs.ModeSticky != 0 {
+		m |= syscall.S_ISVTX
+	}
+	return m
+}
+
+// statFile describes the file name, following symbolic links, with
+// the error file reports when it cannot.
+func (interp *Interp) statFile(name string) (fs.FileInfo, error) {
+	info, err := interp.filesystem().Stat(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not read \"%s\": %s", name, posixError(err))
+	}
+	return info, nil
+}
+
+// file atime name ?time?
+//
+// Returns a decimal string giving the time at which file name was
+// last accessed. If time is specified, it is an access time to set
+// for the file. The time is measured in the standard POSIX fashion
+// as seconds from a fixed starting time.
+func cmdFileAtime(interp *Interp, args []*Value) (*Value, error) {
+	return fileTime(interp, args, "access", func(s statFields) time.Time { return s.atime })
+}
+
+// file mtime name ?time?
+//
+// Returns a decimal string giving the time at which file name was
+// last modified. If time is specified, it is a modification time to
+// set for the file.
+func cmdFileMtime(interp *Interp, args []*Value) (*Value, error) {
+	return fileTime(interp, args, "modification", func(s statFields) time.Time { return s.mtime })
+}
+
+// fileTime implements file atime and file mtime, with which naming
+// the time and get returning it from the status of a file.
+func fileTime(interp *Interp, args []*Value, which string, get func(statFields) time.Time) (*Value, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "name ?time?")
+	}
+	name := args[2].String()
+	if len(args) == 4 {
+		secs, err := getInt(args[3])
+		if err != nil {
+			return nil, err
+		}
+		info, err := interp.statFile(name)
+		if err != nil {
+			return nil, err
+		}
+		s := fileStat(info)
+		t := time.Unix(int64(secs), 0)
+		atime, mtime := s.atime, t
+		if which == "access" {
+			atime, mtime = t, s.mtime
+		}
+		if err := interp.filesystem().Chtimes(name, atime, mtime); err != nil {
+			return nil, fmt.Errorf("could not set %s time for file \"%s\": %s", which, name, posixError(err))
+		}
+	}
+	info, err := interp.statFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewIntValue(get(fileStat(info)).Unix()), nil
+}
+
+// fileAttributes holds the options of file attributes.
+var fileAttributes = []string{"-group", "-owner", "-permissions"}
+
+// file attributes name ?option? ?value option value ...?
+//
+// This subcommand returns or sets platform-specific values
+// associated with a file. The first form returns a list of all the
+// attributes and their values. The second form returns the value for
+// the specific option. The third form sets one or more of the values.
+//
+// The attributes are -group, the name of the group of the file,
+// -owner, the name of its owner, and -permissions, its octal mode.
+// Permissions may be set in octal, as a string such as rwxr-xr-x, or
+// in the symbolic form of chmod, such as u+x,go-w.
+func cmdFileAttributes(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 2, "name ?-option value ...?")
+	}
+	name := args[2].String()
+	info, err := interp.statFile(name)
+	if err != nil {
+		return nil, err
+	}
+	opts := args[3:]
+	if len(opts) > 0 && !containsString(fileAttributes, opts[0].String()) {
+		return nil, fmt.Errorf("bad option \"%s\": must be %s", opts[0], mustBe(fileAttributes))
+	}
+	get := func(opt string) string {
+		s := fileStat(info)
+		switch opt {
+		case "-group":
+			if g, err := user.LookupGroupId(strconv.FormatUint(s.gid, 10)); err == nil {
+				return g.Name
+			}
+			return strconv.FormatUint(s.gid, 10)
+		case "-owner":
+			if u, err := user.LookupId(strconv.FormatUint(s.uid, 10)); err == nil {
+				return u.Username
+			}
+			return strconv.FormatUint(s.uid, 10)
+		}
+		return fmt.Sprintf("%#05o", unixMode(info.Mode())&0o7777)
+	}
+	switch len(opts) {
+	case 0:
+		var attrs []string
+		for _, opt := range fileAttributes {
+			attrs = append(attrs, opt, get(opt))
+		}
+		return NewListValue(stringsToValues(attrs)), nil
+	case 1:
+		return NewStringValue(get(opts[0].String())), nil
+	}
+	if len(opts)%2 != 0 {
+		return nil, fmt.Errorf("value for \"%s\" missing", opts[len(opts)-1])
+	}
+	for i := 0; i < len(opts); i += 2 {
+		opt, value := opts[i].String(), opts[i+1].String()
+		if !containsString(fileAttributes, opt) {
+			return nil, fmt.Errorf("bad option \"%s\": must be %s", opt, mustBe(fileAttributes))
+		}
+		if err := interp.setFileAttribute(name, opt, value); err != nil {
+			return nil, err
+		}
+	}
+	return emptyValue(), nil
+}
+
+// setFileAttribute sets the attribute opt of the file name to value.
+func (interp *Interp) setFileAttribute(name, opt, value string) error {
+	vfs := interp.filesystem()
+	var err error
+	switch opt {
+	case "-group":
+		gid, lerr := strconv.Atoi(value)
+		if lerr != nil {
+			g, lerr := user.LookupGroup(value)
+			if lerr != nil {
+				return fmt.Errorf("could not set group for file \"%s\": group \"%s\" does not exist", name, value)
+			}
+			gid, _ = strconv.Atoi(g.Gid)
+		}
+		err = vfs.Chown(name, -1, gid)
+	case "-owner":
+		uid, lerr := strconv.Atoi(value)
+		if lerr != nil {
+			u, lerr := user.Lookup(value)
+			if lerr != nil {
+				return fmt.Errorf("could not set owner for file \"%s\": user \"%s\" does not exist", name, value)
+			}
+			uid, _ = strconv.Atoi(u.Uid)
+		}
+		err = vfs.Chown(name, uid, -1)
+	case "-permissions":
+		info, serr := vfs.Stat(name)
+		if serr != nil {
+			return fmt.Errorf("could not read \"%s\": %s", name, posixError(serr))
+		}
+		mode, ok := parsePermissions(value, info.Mode())
+		if !ok {
+			return fmt.Errorf("unknown permission string format \"%s\"", value)
+		}
+		err = vfs.Chmod(name, mode)
+	}
+	if err != nil {
+		return fmt.Errorf("could not set %s for file \"%s\": %s", opt[1:], name, posixError(err))
+	}
+	return nil
+}
+
+// parsePermissions parses the permissions s to apply to a file with
+// mode: an octal number, a string such as rwxr-xr-x, or a
+// comma-separated list of changes of the form [ugoa]*[+-=][rwxst]*.
+func parsePermissions(s string, mode fs.FileMode) (fs.FileMode, bool) {
+	if n, err := strconv.ParseUint(strings.TrimPrefix(s, "0o"), 8, 32); err == nil && n <= 0o7777 {
+		return fileMode(n), true
+	}
+	if len(s) == 9 && strings.Trim(s, "rwx-") == "" {
+		var perm fs.FileMode
+		for i, c := range s {
+			if c != '-' {
+				if c != rune("rwx"[i%3]) {
+					return 0, false
+				}
+				perm |= 1 << (8 - i)
+			}
+		}
+		return perm, true
+	}
+	bits := uint64(unixMode(mode) & 0o7777)
+	for _, clause := range strings.Split(s, ",") {
+		i := strings.IndexAny(clause, "+-=")
+		if i < 0 || strings.Trim(clause[:i], "ugoa") != "" || strings.Trim(clause[i+1:], "rwxst") != "" {
+			return 0, false
+		}
+		who := uint64(0)
+		for _, c := range clause[:i] {
+			switch c {
+			case 'u':
+				who |= 0o4700
+			case 'g':
+				who |= 0o2070
+			case 'o':
+				who |= 0o1007
+			case 'a':
+				who |= 0o7777
+			}
+		}
+		if who == 0 {
+			who = 0o7777
+		}
+		what := uint64(0)
+		for _, c := range clause[i+1:] {
+			switch c {
+			case 'r':
+				what |= 0o444
+			case 'w':
+				what |= 0o222
+			case 'x':
+				what |= 0o111
+			case 's':
+				what |= 0o6000
+			case 't':
+				what |= 0o1000
+			}
+		}
+		switch clause[i] {
+		case '+':
+			bits |= who & what
+		case '-':
+			bits &^= who & what
+		case '=':
+			bits = bits&^who | who&what
+		}
+	}
+	return fileMode(bits), true
+}
+
+// fileMode converts the POSIX permission bits to an fs.FileMode.
+func fileMode(bits uint64) fs.FileMode {
+	mode := fs.FileMode(bits & 0o777)
+	if bits&syscall.S_ISUID != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if bits&syscall.S_ISGID != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if bits&syscall.S_ISVTX != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
+
+// parseFileOptions parses the -force and -- options that start the
+// arguments of file copy, delete and rename, returning the index of
+// the first argument after them.
+func parseFileOptions(args []*Value) (force bool, i int, err error) {
+	for i = 2; i < len(args) && strings.HasPrefix(args[i].String(), "-"); i++ {
+		switch args[i].String() {
+		case "-force":
+			force = true
+		case "--":
+			return force, i + 1, nil
+		default:
+			return false, 0, fmt.Errorf("bad option \"%s\": must be -force or --", args[i])
+		}
+	}
+	return force, i, nil
+}
+
+// file copy ?-force? ?--? source target
+// file copy ?-force? ?--? source ?source ...? targetDir
+//
+// The first form makes a copy of the file or directory source under
+// the pathname target. If target is an existing directory, then the
+// second form is used. The second form makes a copy inside targetDir
+// of each source file listed. If a directory is specified as a
+// source, then the contents of the directory will be recursively
+// copied into targetDir. Existing files will not be overwritten
+// unless the -force option is specified. Symbolic links are copied
+// as links, and copies keep the modification time of their source.
+func cmdFileCopy(interp *Interp, args []*Value) (*Value, error) {
+	return fileCopyRename(interp, args, "copying", interp.copyFile)
+}
+
+// file rename ?-force? ?--? source target
+// file rename ?-force? ?--? source ?source ...? targetDir
+//
+// The first form takes the file or directory specified by pathname
+// source and renames it to target, moving the file if the pathname
+// target specifies a name in a different directory. If target is an
+// existing directory, then the second form is used. The second form
+// moves each source file or directory into the directory targetDir.
+// Existing files will not be overwritten unless the -force option is
+// specified.
+func cmdFileRename(interp *Interp, args []*Value) (*Value, error) {
+	return fileCopyRename(interp, args, "renaming", interp.renameFile)
+}
+
+// fileCopyRename implements file copy and file rename, which moves
+// or copies each source with op.
+func fileCopyRename(interp *Interp, args []*Value, doing string, op func(src, dst string) error) (*Value, error) {
+	force, i, err := parseFileOptions(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(args)-i < 2 {
+		return nil, wrongNumArgs(args, 2, "?-option value ...? source ?source ...? target")
+	}
+	vfs := interp.filesystem()
+	sources, target := valuesToStrings(args[i:len(args)-1]), args[len(args)-1].String()
+	info, err := vfs.Stat(target)
+	targetDir := err == nil && info.IsDir()
+	if len(sources) > 1 && !targetDir {
+		return nil, fmt.Errorf("error %s: target \"%s\" is not a directory", doing, target)
+	}
+	for _, src := range sources {
+		dst := target
+		if targetDir {
+			dst = joinPath([]string{target, fileTail(src)})
+		}
+		srcInfo, err := vfs.Lstat(src)
+		if err != nil {
+			return nil, fmt.Errorf("error %s \"%s\": %s", doing, src, posixError(err))
+		}
+		if dstInfo, err := vfs.Lstat(dst); err == nil {
+			switch {
+			case !force:
+				err = fs.ErrExist
+			case dstInfo.IsDir() && !srcInfo.IsDir():
+				err = syscall.EISDIR
+			case dstInfo.IsDir():
+				err = vfs.Remove(dst)
+				if errors.Is(err, syscall.ENOTEMPTY) {
+					err = fs.ErrExist
+				}
+			default:
+				err = vfs.Remove(dst)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error %s \"%s\" to \"%s\": %s", doing, src, dst, posixError(err))
+			}
+		}
+		if err := op(src, dst); err != nil {
+			return nil, fmt.Errorf("error %s \"%s\" to \"%s\": %s", doing, src, dst, posixError(err))
+		}
+	}
+	return emptyValue(), nil
+}
+
+// renameFile moves src to dst, copying it if they are on different
+// devices.
+func (interp *Interp) renameFile(src, dst string) error {
+	err := interp.filesystem().Rename(src, dst)
+	if errors.Is(err, syscall.EXDEV) {
+		if err = interp.copyFile(src, dst); err == nil {
+			err = interp.removeAll(src)
+		}
+	}
+	return err
+}
+
+// copyFile copies the file, link or directory tree src to dst.
+func (interp *Interp) copyFile(src, dst string) error {
+	vfs := interp.filesystem()
+	info, err := vfs.Lstat(src)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := vfs.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return vfs.Symlink(target, dst)
+	case info.IsDir():
+		entries, err := vfs.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		if err := vfs.Mkdir(dst, info.Mode().Perm()|0o700); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := interp.copyFile(joinPath([]string{src, e.Name()}), joinPath([]string{dst, e.Name()})); err != nil {
+				return err
+			}
+		}
+		return vfs.Chmod(dst, info.Mode())
+	}
+	in, err := vfs.OpenFile(src, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := vfs.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return vfs.Chtimes(dst, fileStat(info).atime, info.ModTime())
+}
+
+// removeAll removes the file, link or directory tree name.
+func (interp *Interp) removeAll(name string) error {
+	vfs := interp.filesystem()
+	info, err := vfs.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		entries, err := vfs.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := interp.removeAll(joinPath([]string{name, e.Name()})); err != nil {
+				return err
+			}
+		}
+	}
+	return vfs.Remove(name)
+}
+
+// file delete ?-force? ?--? ?pathname ... ?
+//
+// Removes the file or directory specified by each pathname argument.
+// Non-empty directories will be removed only if the -force option is
+// specified. When operating on symbolic links, the links themselves
+// will be deleted, not the objects they point to. Trying to delete a
+// non-existent file is not considered an error.
+func cmdFileDelete(interp *Interp, args []*Value) (*Value, error) {
+	force, i, err := parseFileOptions(args)
+	if err != nil {
+		return nil, err
+	}
+	vfs := interp.filesystem()
+	for _, arg := range args[i:] {
+		name := arg.String()
+		info, err := vfs.Lstat(name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("error deleting \"%s\": %s", name, posixError(err))
+		}
+		if force && info.IsDir() {
+			err = interp.removeAll(name)
+		} else {
+			err = vfs.Remove(name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error deleting \"%s\": %s", name, posixError(err))
+		}
+	}
+	return emptyValue(), nil
+}
+
+// splitPath splits name into its components: its root if name is
+// absolute, / or the zipfs root //zipfs:/, followed by the names
+// between the separators.
+func splitPath(name string) []string {
+	var parts []string
+	switch {
+	case strings.HasPrefix(name, zipfsRoot):
+		parts = append(parts, zipfsRoot)
+		name = name[len(zipfsRoot):]
+	case strings.HasPrefix(name, "/"):
+		parts = append(parts, "/")
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// joinPath joins the components of names, as file join does.
+func joinPath(names []string) string {
+	var result string
+	for _, name := range names {
+		for _, part := range splitPath(name) {
+			switch {
+			case isRoot(part):
+				result = part
+			case result == "":
+				result = part
+			case strings.HasSuffix(result, "/"):
+				result += part
+			default:
+				result += "/" + part
+			}
+		}
+	}
+	return result
+}
+
+// isRoot reports whether the path component part is the root of an
+// absolute path.
+func isRoot(part string) bool {
+	return part == "/" || part == zipfsRoot
+}
+
+// fileDirname returns all the components of name but the last.
+func fileDirname(name string) string {
+	parts := splitPath(name)
+	switch {
+	case len(parts) > 1:
+		return joinPath(parts[:len(parts)-1])
+	case len(parts) == 1 && isRoot(parts[0]):
+		return parts[0]
+	}
+	return "."
+}
+
+// fileTail returns the last component of name, or "" if it is /.
+func fileTail(name string) string {
+	parts := splitPath(name)
+	if len(parts) == 0 || isRoot(parts[len(parts)-1]) {
+		return ""
+	}
+	return parts[len(parts)-1]
+}
+
+// extensionIndex returns the index of the dot starting the extension
+// of name, or -1 if it has none.
+func extensionIndex(name string) int {
+	dot := strings.LastIndexByte(name, '.')
+	if dot < strings.LastIndexByte(name, '/') {
+		return -1
+	}
+	return dot
+}
+
+// file dirname name
+//
+// Returns a name comprised of all of the path components in name
+// excluding the last element. If name is a relative file name and
+// only contains one path element, then returns ".". If name refers
+// to the root directory, then the root directory is returned.
+func cmdFileDirname(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "name")
+	}
+	return NewStringValue(fileDirname(args[2].String())), nil
+}
+
+// file tail name
+//
+// Returns all of the characters in the last filesystem component of
+// name. Any trailing directory separator in name is ignored. If name
+// contains no separators then returns name.
+func cmdFileTail(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "name")
+	}
+	return NewStringValue(fileTail(args[2].String())), nil
+}
+
+// file extension name
+//
+// Returns all of the characters in name after and including the last
+// dot in the last element of name. If there is no dot in the last
+// element of name then returns the empty string.
+func cmdFileExtension(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "name")
+	}
+	name := args[2].String()
+	if i := extensionIndex(name); i >= 0 {
+		return NewStringValue(name[i:]), nil
+	}
+	return emptyValue(), nil
+}
+
+// file rootname name
+//
+// Returns all of the characters in name up to but not including the
+// last "." character in the last component of name. If the last
+// component of name does not contain a dot, then returns name.
+func cmdFileRootname(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "name")
+	}
+	name := args[2].String()
+	if i := extensionIndex(name); i >= 0 {
+		return NewStringValue(name[:i]), nil
+	}
+	return args[2], nil
+}
+
+// file join name ?name ...?
+//
+// Takes one or more file names and combines them, using the correct
+// path separator for the current platform. If a particular name is
+// relative, then it will be joined to the previous file name
+// argument. Otherwise, any earlier arguments will be discarded, and
+// joining will proceed from the current argument.
+func cmdFileJoin(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 2, "name ?name ...?")
+	}
+	return NewStringValue(joinPath(valuesToStrings(args[2:]))), nil
+}
+
+// file split name
+//
+// Returns a list whose elements are the path components in name. The
+// first element of the list will have the same path type as name.
+// All other elements will be relative. Path separators will be
+// discarded.
+func cmdFileSplit(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "name")
+	}
+	return NewListValue(stringsToValues(splitPath(args[2].String()))), nil
+}
+
+// file nativename name
+//
+// Returns the platform-specific name of the file. This is useful if
+// the filename is needed to pass to a platform-specific call, such as
+// to a subprocess via exec.
+func cmdFileNativename(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "name")
+	}
+	return NewStringValue(joinPath(valuesToStrings(args[2:]))), nil
+}
+
+// file pathtype name
+//
+// Returns absolute if name is an absolute path and relative
+// otherwise.
+func cmdFilePathtype(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "name")
+	}
+	if parts := splitPath(args[2].String()); len(parts) > 0 && isRoot(parts[0]) {
+		return NewStringValue("absolute"), nil
+	}
+	return NewStringValue("relative"), nil
+}
+
+// file separator ?name?
+//
+// If no argument is given, returns the character which is used to
+// separate path segments for native files on this platform. If a
+// path is given, returns the separator used for that path.
+func cmdFileSeparator(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "?name?")
+	}
+	return NewStringValue("/"), nil
+}
+
+// file normalize name
+//
+// Returns a unique normalized path representation for the file-system
+// object, whose string value can be used as a unique identifier for
+// it. A normalized path is an absolute path which has all "../" and
+// "./" removed. Also it is one which is in the "standard" format for
+// the native platform. Symbolic links are resolved in every
+// component but the last one.
+func cmdFileNormalize(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "name")
+	}
+	name, err := interp.normalizePath(args[2].String())
+	if err != nil {
+		return nil, err
+	}
+	return NewStringValue(name), nil
+}
+
+// normalizePath returns the normalized form of name.
+func (interp *Interp) normalizePath(name string) (string, error) {
+	vfs := interp.filesystem()
+	if !strings.HasPrefix(name, "/") {
+		wd, err := vfs.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("error getting working directory name: %s", posixError(err))
+		}
+		name = joinPath([]string{wd, name})
+	}
+	parts := splitPath(name)
+	root := parts[0]
+	parts = parts[1:]
+	var resolved []string
+	for hops := 0; len(parts) > 0; {
+		part := parts[0]
+		parts = parts[1:]
+		switch part {
+		case ".":
+			continue
+		case "..":
+			if len(resolved) > 0 {
+				resolved = resolved[:len(resolved)-1]
+			}
+			continue
+		}
+		resolved = append(resolved, part)
+		if len(parts) == 0 || hops > 40 {
+			continue
+		}
+		p := root + strings.Join(resolved, "/")
+		if info, err := vfs.Lstat(p); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+			continue
+		}
+		target, err := vfs.Readlink(p)
+		if err != nil {
+			continue
+		}
+		hops++
+		resolved = resolved[:len(resolved)-1]
+		targetParts := splitPath(target)
+		if len(targetParts) > 0 && isRoot(targetParts[0]) {
+			root, resolved, targetParts = targetParts[0], nil, targetParts[1:]
+		}
+		parts = append(targetParts, parts...)
+	}
+	return root + strings.Join(resolved, "/"), nil
+}
+
+// file exists name
+//
+// Returns 1 if file name exists and the current user has search
+// privileges for the directories leading to it, 0 otherwise.
+func cmdFileExists(interp *Interp, args []*Value) (*Value, error) {
+	return fileCheck(interp, args, func(vfs VFS, name string) bool {
+		_, err := vfs.Stat(name)
+		return err == nil
+	})
+}
+
+// file isdirectory name
+//
+// Returns 1 if file name is a directory, 0 otherwise.
+func cmdFileIsdirectory(interp *Interp, args []*Value) (*Value, error) {
+	return fileCheck(interp, args, func(vfs VFS, name string) bool {
+		info, err := vfs.Stat(name)
+		return err == nil && info.IsDir()
+	})
+}
+
+// file isfile name
+//
+// Returns 1 if file name is a regular file, 0 otherwise.
+func cmdFileIsfile(interp *Interp, args []*Value) (*Value, error) {
+	return fileCheck(interp, args, func(vfs VFS, name string) bool {
+		info, err := vfs.Stat(name)
+		return err == nil && info.Mode().IsRegular()
+	})
+}
+
+// file readable name
+//
+// Returns 1 if file name is readable by the current user, 0
+// otherwise.
+func cmdFileReadable(interp *Interp, args []*Value) (*Value, error) {
+	return fileCheck(interp, args, func(vfs VFS, name string) bool {
+		return vfs.Access(name, 4) == nil
+	})
+}
+
+// file writable name
+//
+// Returns 1 if file name is writable by the current user, 0
+// otherwise.
+func cmdFileWritable(interp *Interp, args []*Value) (*Value, error) {
+	return fileCheck(interp, args, func(vfs VFS, name string) bool {
+		return vfs.Access(name, 2) == nil
+	})
+}
+
+// file executable name
+//
+// Returns 1 if file name is executable by the current user, 0
+// otherwise.
+func cmdFileExecutable(interp *Interp, args []*Value) (*Value, error) {
+	return fileCheck(interp, args, func(vfs VFS, name string) bool {
+		return vfs.Access(name, 1) == nil
+	})
+}
+
+// fileCheck implements the file subcommands that return whether the
+// file name passes check.
+func fileCheck(interp *Interp, args []*Value, check func(vfs VFS, name string) bool) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "name")
+	}
+	return NewBoolValue(check(interp.filesystem(), args[2].String())), nil
+}
+
+// file size name
+//
+// Returns a decimal string giving the size of file name in bytes. If
+// the file does not exist or its size cannot be queried then an
+// error is generated.
+func cmdFileSize(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "name")
+	}
+	info, err := interp.statFile(args[2].String())
+	if err != nil {
+		return nil, err
+	}
+	return NewIntValue(info.Size()), nil
+}
+
+// file type name
+//
+// Returns a string giving the type of file name, which will be one of
+// file, directory, characterSpecial, blockSpecial, fifo, link, or
+// socket.
+func cmdFileType(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "name")
+	}
+	name := args[2].String()
+	info, err := interp.filesystem().Lstat(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not read \"%s\": %s", name, posixError(err))
+	}
+	return NewStringValue(fileType(info.Mode())), nil
+}
+
+// file stat name ?varName?
+//
+// Invokes the stat kernel call on name, and returns a dictionary
+// with the information returned from the kernel call, or stores it
+// in the array varName if given. The keys are atime, ctime, dev,
+// gid, ino, mode, mtime, nlink, size, type and uid.
+func cmdFileStat(interp *Interp, args []*Value) (*Value, error) {
+	return fileStatCmd(interp, args, interp.filesystem().Stat)
+}
+
+// file lstat name ?varName?
+//
+// Same as the stat option except uses the lstat kernel call instead
+// of stat. This means that if name refers to a symbolic link the
+// information returned is for the link rather than the file it
+// refers to.
+func cmdFileLstat(interp *Interp, args []*Value) (*Value, error) {
+	return fileStatCmd(interp, args, interp.filesystem().Lstat)
+}
+
+func fileStatCmd(interp *Interp, args []*Value, stat func(string) (fs.FileInfo, error)) (*Value, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "name ?varName?")
+	}
+	name := args[2].String()
+	info, err := stat(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not read \"%s\": %s", name, posixError(err))
+	}
+	s := fileStat(info)
+	fields := []struct {
+		key   string
+		value *Value
+	}{
+		{"atime", NewIntValue(s.atime.Unix())},
+		{"ctime", NewIntValue(s.ctime.Unix())},
+		{"dev", NewIntValue(int64(s.dev))},
+		{"gid", NewIntValue(int64(s.gid))},
+		{"ino", NewIntValue(int64(s.ino))},
+		{"mode", NewIntValue(unixMode(info.Mode()))},
+		{"mtime", NewIntValue(info.ModTime().Unix())},
+		{"nlink", NewIntValue(int64(s.nlink))},
+		{"size", NewIntValue(info.Size())},
+		{"type", NewStringValue(fileType(info.Mode()))},
+		{"uid", NewIntValue(int64(s.uid))},
+	}
+	if len(args) == 3 {
+		var elems []*Value
+		for _, f := range fields {
+			elems = append(elems, NewStringValue(f.key), f.value)
+		}
+		return NewListValue(elems), nil
+	}
+	for _, f := range fields {
+		if _, err := interp.setVar2(args[3].String(), f.key, true, f.value); err != nil {
+			return nil, err
+		}
+	}
+	return emptyValue(), nil
+}
+
+// file mkdir ?dir ...?
+//
+// Creates each directory specified. For each pathname dir specified,
+// this command will create all non-existing parent directories as
+// well as dir itself. If an existing directory is specified, then no
+// action is taken and no error is returned. Trying to overwrite an
+// existing file with a directory will result in an error.
+func cmdFileMkdir(interp *Interp, args []*Value) (*Value, error) {
+	vfs := interp.filesystem()
+	for _, arg := range args[2:] {
+		name := arg.String()
+		parts := splitPath(name)
+		for i := range parts {
+			dir := joinPath(parts[:i+1])
+			info, err := vfs.Stat(dir)
+			switch {
+			case err == nil && info.IsDir():
+				continue
+			case err == nil:
+				err = fs.ErrExist
+			case errors.Is(err, fs.ErrNotExist):
+				if err = vfs.Mkdir(dir, 0o777); err == nil {
+					continue
+				}
+			}
+			return nil, fmt.Errorf("can't create directory \"%s\": %s", dir, posixError(err))
+		}
+	}
+	return emptyValue(), nil
+}
+
+// file readlink name
+//
+// This command returns the value of a symbolic link given by name
+// (i.e. the name of the file it points to). If name is not a symbolic
+// link or its value cannot be read, then an error is returned.
+func cmdFileReadlink(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "name")
+	}
+	return readLink(interp, args[2].String())
+}
+
+func readLink(interp *Interp, name string) (*Value, error) {
+	target, err := interp.filesystem().Readlink(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not read link \"%s\": %s", name, posixError(err))
+	}
+	return NewStringValue(target), nil
+}
+
+// file link ?-linktype? linkName ?target?
+//
+// If only one argument is given, that argument is assumed to be
+// linkName, and this command returns the value of the link given by
+// linkName. If two arguments are given, then these are assumed to be
+// linkName and target. If linkName already exists, or if target does
+// not exist, an error will be returned. Otherwise, Tcl creates a new
+// link called linkName which points to the existing filesystem
+// object at target, a symbolic link unless -linktype is -hard.
+func cmdFileLink(interp *Interp, args []*Value) (*Value, error) {
+	hard := false
+	if len(args) == 5 {
+		switch args[2].String() {
+		case "-symbolic":
+		case "-hard":
+			hard = true
+		default:
+			return nil, fmt.Errorf("bad option \"%s\": must be -symbolic or -hard", args[2])
+		}
+		args = append(args[:2:2], args[3:]...)
+	}
+	switch len(args) {
+	case 3:
+		return readLink(interp, args[2].String())
+	case 4:
+	default:
+		return nil, wrongNumArgs(args, 2, "?-linktype? linkName ?target?")
+	}
+	vfs := interp.filesystem()
+	name, target := args[2].String(), args[3].String()
+	if _, err := vfs.Lstat(name); err == nil {
+		return nil, fmt.Errorf("could not create new link \"%s\": that path already exists", name)
+	}
+	if _, err := vfs.Stat(target); err != nil {
+		return nil, fmt.Errorf("could not create new link \"%s\" since target \"%s\" doesn't exist", name, target)
+	}
+	var err error
+	if hard {
+		err = vfs.Link(target, name)
+	} else {
+		err = vfs.Symlink(target, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not create new link \"%s\" pointing to \"%s\": %s", name, target, posixError(err))
+	}
+	return NewStringValue(target), nil
+}
+
+// file tempfile ?nameVar? ?template?
+//
+// Creates a temporary file and returns a read-write channel opened
+// on that file. If the nameVar is given, it specifies a variable that
+// the name of the temporary file will be written into; otherwise the
+// file is deleted as soon as it has been opened. If the template is
+// present, it specifies parts of the template of the filename to
+// use when creating it: its directory, the prefix of the file name
+// and its extension. By default the file is created in the directory
+// named by TMPDIR, or /tmp, with the prefix tcl_.
+func cmdFileTempfile(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 4 {
+		return nil, wrongNumArgs(args, 2, "?nameVar? ?template?")
+	}
+	dir, prefix, ext := os.TempDir(), "tcl_", ""
+	if len(args) == 4 {
+		template := args[3].String()
+		if strings.Contains(template, "/") {
+			dir = fileDirname(template)
+		}
+		base := fileTail(template)
+		if i := extensionIndex(base); i >= 0 {
+			base, ext = base[:i], base[i:]
+		}
+		if base != "" {
+			prefix = base
+		}
+	}
+	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	vfs := interp.filesystem()
+	var name string
+	var f File
+	var err error
+	for tries := 0; tries < 100; tries++ {
+		suffix := make([]byte, 6)
+		for i := range suffix {
+			suffix[i] = letters[rand.Intn(len(letters))]
+		}
+		name = joinPath([]string{dir, prefix + string(suffix) + ext})
+		f, err = vfs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+		if !errors.Is(err, fs.ErrExist) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create temporary file: %s", posixError(err))
+	}
+	if len(args) > 2 {
+		if _, err := interp.setVar(args[2].String(), NewStringValue(name)); err != nil {
+			f.Close()
+			vfs.Remove(name)
+			return nil, err
+		}
+	} else {
+		vfs.Remove(name)
+	}
+	ch := newChannel(interp.fileChannelName(f), f, f, f, f.Close)
+	interp.registerChannel(ch)
+	return NewStringValue(ch.name), nil
+}
+
+// homeDir returns the home directory of the user called name, or of
+// the current user if name is empty.
+func homeDir(name string) (string, error) {
+	if name == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return "", errors.New("couldn't find HOME environment variable to expand path")
+		}
+		return home, nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", fmt.Errorf("user \"%s\" doesn't exist", name)
+	}
+	return u.HomeDir, nil
+}
+
+// file home ?username?
+//
+// If no argument is specified, the command returns the home directory
+// of the current user, as given by the HOME environment variable.
+// Otherwise it returns the home directory of the user username.
+func cmdFileHome(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 3 {
+		return nil, wrongNumArgs(args, 2, "?user?")
+	}
+	name := ""
+	if len(args) == 3 {
+		name = args[2].String()
+	}
+	home, err := homeDir(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewStringValue(home), nil
+}
+
+// file tildeexpand name
+//
+// Returns the result of performing tilde substitution on name: if
+// the first component of name is ~ or ~user, it is replaced by the
+// home directory of the current user or of user. Otherwise name is
+// returned unchanged.
+func cmdFileTildeexpand(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "name")
+	}
+	name := args[2].String()
+	if !strings.HasPrefix(name, "~") {
+		return args[2], nil
+	}
+	first, rest, _ := strings.Cut(name, "/")
+	home, err := homeDir(first[1:])
+	if err != nil {
+		return nil, err
+	}
+	return NewStringValue(joinPath([]string{home, rest})), nil
+}
+
+// pwd
+//
+// Returns the absolute path name of the current working directory.
+func cmdPwd(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 1 {
+		return nil, wrongNumArgs(args, 1, "")
+	}
+	wd, err := interp.filesystem().Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("error getting working directory name: %s", posixError(err))
+	}
+	return NewStringValue(wd), nil
+}
+
+// cd ?dirName?
+//
+// Change the current working directory to dirName, or to the home
+// directory (as specified in the HOME environment variable) if
+// dirName is not given. Returns an empty string.
+func cmdCd(interp *Interp, args []*Value) (*Value, error) {
+	var dir string
+	switch len(args) {
+	case 1:
+		home, err := homeDir("")
+		if err != nil {
+			return nil, err
+		}
+		dir = home
+	case 2:
+		dir = args[1].String()
+	default:
+		return nil, wrongNumArgs(args, 1, "?dirName?")
+	}
+	if err := interp.filesystem().Chdir(dir); err != nil {
+		return nil, fmt.Errorf("couldn't change working directory to \"%s\": %s", dir, posixError(err))
+	}
+	return emptyValue(), nil
+}
@@ -0,0 +1,172 @@
+package gotcl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilePaths(t *testing.T) {
+	t.Setenv("HOME", "/home/tcl")
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `file join a b/ c`, result: "a/b/c"},
+		{script: `file join a /b c`, result: "/b/c"},
+		{script: `file join / a`, result: "/a"},
+		{script: `file join {}`, result: ""},
+		{script: `file split /a//b/c/`, result: "/ a b c"},
+		{script: `file split a/b`, result: "a b"},
+		{script: `file dirname /a/b`, result: "/a"},
+		{script: `file dirname /a`, result: "/"},
+		{script: `file dirname a`, result: "."},
+		{script: `file dirname a/b/`, result: "a"},
+		{script: `file tail /a/b.c/`, result: "b.c"},
+		{script: `file tail /`, result: ""},
+		{script: `file extension foo/bar.tar.gz`, result: ".gz"},
+		{script: `file extension a.b/c`, result: ""},
+		{script: `file rootname foo/bar.tar.gz`, result: "foo/bar.tar"},
+		{script: `file rootname a.b/c`, result: "a.b/c"},
+		{script: `file pathtype /a`, result: "absolute"},
+		{script: `file pathtype ~/a`, result: "relative"},
+		{script: `file nativename a//b/`, result: "a/b"},
+		{script: `file separator`, result: "/"},
+		{script: `file tildeexpand ~/lib`, result: "/home/tcl/lib"},
+		{script: `file tildeexpand a/~b`, result: "a/~b"},
+		{script: `file home`, result: "/home/tcl"},
+		{script: `file home nosuchuser-gotcl`, err: `user "nosuchuser-gotcl" doesn't exist`},
+		{script: `file join`, err: `wrong # args: should be "file join name ?name ...?"`},
+		{script: `file bogus x`, err: `unknown or ambiguous subcommand "bogus": must be atime, attributes, copy, delete, dirname, executable, exists, extension, home, isdirectory, isfile, join, link, lstat, mkdir, mtime, nativename, normalize, pathtype, readable, readlink, rename, rootname, separator, size, split, stat, tail, tempfile, tildeexpand, type, or writable`},
+	})
+}
+
+func TestFileMemFS(t *testing.T) {
+	interp := NewInterp()
+	interp.SetVFS(NewMemFS())
+	runEvalTests(t, interp, []evalTest{
+		{script: `pwd`, result: "/"},
+		{script: `file mkdir /lib/tcl/sub /tmp`},
+		{script: `list [file isdirectory /lib/tcl] [file isfile /lib/tcl] [file exists /lib/none]`, result: "1 0 0"},
+		{script: `cd /lib; pwd`, result: "/lib"},
+		{script: `set f [open tcl/a.tcl w 0o644]; puts -nonewline $f hello; close $f; file size /lib/tcl/a.tcl`, result: "5"},
+		{script: `set f [open tcl/a.tcl]; set data [read $f]; close $f; set data`, result: "hello"},
+		{script: `file type tcl/a.tcl`, result: "file"},
+		{script: `file mkdir tcl/a.tcl`, err: `can't create directory "tcl/a.tcl": file already exists`},
+		{script: `file mtime tcl/a.tcl 1000000000`, result: "1000000000"},
+		{script: `file atime tcl/a.tcl 1000000001; file mtime tcl/a.tcl`, result: "1000000000"},
+		{script: `file size nofile`, err: `could not read "nofile": no such file or directory`},
+
+		{script: `file attributes tcl/a.tcl -permissions`, result: "00644"},
+		{script: `file attributes tcl/a.tcl -permissions 0600; file attributes tcl/a.tcl -permissions`, result: "00600"},
+		{script: `file attributes tcl/a.tcl -permissions u+x,g=r; file attributes tcl/a.tcl -permissions`, result: "00740"},
+		{script: `file attributes tcl/a.tcl -permissions rw-r--r--; file attributes tcl/a.tcl -permissions`, result: "00644"},
+		{script: `file attributes tcl/a.tcl -permissions bogus`, err: `unknown permission string format "bogus"`},
+		{script: `file attributes tcl/a.tcl -bogus`, err: `bad option "-bogus": must be -group, -owner, or -permissions`},
+		{script: `list [file readable tcl/a.tcl] [file writable tcl/a.tcl] [file executable tcl/a.tcl]`, result: "1 1 0"},
+		{script: `file attributes tcl/a.tcl -permissions 0444; file writable tcl/a.tcl`, result: "0"},
+		{script: `open tcl/a.tcl w`, err: `couldn't open "tcl/a.tcl": permission denied`},
+		{script: `file attributes tcl/a.tcl -permissions 0644`},
+
+		{script: `file copy tcl/a.tcl tcl/b.tcl; file size tcl/b.tcl`, result: "5"},
+		{script: `file mtime tcl/b.tcl`, result: "1000000000"},
+		{script: `file copy tcl/a.tcl tcl/b.tcl`, err: `error copying "tcl/a.tcl" to "tcl/b.tcl": file already exists`},
+		{script: `file copy -force tcl/a.tcl tcl/b.tcl`},
+		{script: `file copy nofile x`, err: `error copying "nofile": no such file or directory`},
+		{script: `file copy tcl/a.tcl tcl/b.tcl tcl/a.tcl`, err: `error copying: target "tcl/a.tcl" is not a directory`},
+		{script: `file copy tcl /copy; glob -directory /copy *`, result: "/copy/a.tcl /copy/b.tcl /copy/sub"},
+		{script: `file rename tcl/b.tcl tcl/sub; glob -tails -directory tcl/sub *`, result: "b.tcl"},
+		{script: `file rename tcl/sub/b.tcl tcl/c.tcl; file exists tcl/sub/b.tcl`, result: "0"},
+		{script: `file rename tcl /lib/tcl/sub`, err: `error renaming "tcl" to "/lib/tcl/sub/tcl": invalid argument`},
+		{script: `file rename -bogus a b`, err: `bad option "-bogus": must be -force or --`},
+
+		{script: `file link -symbolic link.tcl tcl/a.tcl`, result: "tcl/a.tcl"},
+		{script: `list [file type link.tcl] [file readlink link.tcl] [file link link.tcl] [file size link.tcl]`, result: "link tcl/a.tcl tcl/a.tcl 5"},
+		{script: `file link link.tcl tcl/a.tcl`, err: `could not create new link "link.tcl": that path already exists`},
+		{script: `file link new.tcl none.tcl`, err: `could not create new link "new.tcl" since target "none.tcl" doesn't exist`},
+		{script: `file link -hard hard.tcl tcl/a.tcl; dict get [file stat hard.tcl] nlink`, result: "2"},
+		{script: `file readlink tcl/a.tcl`, err: `could not read link "tcl/a.tcl": invalid argument`},
+		{script: `file link -soft x y`, err: `bad option "-soft": must be -symbolic or -hard`},
+		{script: `file lstat link.tcl st; list $st(type) $st(size)`, result: "link 9"},
+		{script: `dict get [file stat link.tcl] type`, result: "file"},
+		{script: `file link tmp /tmp; file normalize tmp/../lib/./tcl/x`, result: "/lib/tcl/x"},
+		{script: `file normalize /a/../b/../../c`, result: "/c"},
+
+		{script: `file delete /copy`, err: `error deleting "/copy": directory not empty`},
+		{script: `file delete -force /copy nofile; file exists /copy`, result: "0"},
+		{script: `file delete link.tcl; list [file exists link.tcl] [file exists tcl/a.tcl]`, result: "0 1"},
+
+		{script: `set f [file tempfile name /tmp/test.txt]; puts $f data; close $f; list [file dirname $name] [file extension $name] [file size $name]`, result: "/tmp .txt 5"},
+		{script: `string match /tmp/test??????.txt $name`, result: "1"},
+		{script: `set f [file tempfile]; puts $f scratch; seek $f 0; set data [gets $f]; close $f; list $data [llength [glob -nocomplain /tmp/tcl_*]]`, result: "scratch 0"},
+
+		{script: `cd nodir`, err: `couldn't change working directory to "nodir": no such file or directory`},
+		{script: `cd tcl/a.tcl`, err: `couldn't change working directory to "tcl/a.tcl": not a directory`},
+	})
+}
+
+func TestGlob(t *testing.T) {
+	fsys := NewMemFS()
+	interp := NewInterp()
+	interp.SetVFS(fsys)
+	runEvalTests(t, interp, []evalTest{
+		{script: `file mkdir /src/pkg /src/.git; cd /src`},
+		{script: `close [open a.c w]; close [open b.c w]; close [open b.h w]; close [open .hidden w]`},
+		{script: `close [open pkg/x.c w]; close [open pkg/y.go w]`},
+		{script: `glob *.c`, result: "a.c b.c"},
+		{script: `glob *.{c,h}`, result: "a.c b.c b.h"},
+		{script: `glob {*.[ch]}`, result: "a.c b.c b.h"},
+		{script: `glob */*.c /src/pkg/*.go`, result: "pkg/x.c /src/pkg/y.go"},
+		{script: `glob .*`, result: ".git .hidden"},
+		{script: `glob */`, result: "pkg/"},
+		{script: `glob -types d *`, result: "pkg"},
+		{script: `glob -types {f hidden} * .*`, result: ".hidden"},
+		{script: `glob -directory /src/pkg *`, result: "/src/pkg/x.c /src/pkg/y.go"},
+		{script: `glob -tails -directory /src/pkg *.c`, result: "x.c"},
+		{script: `glob -path /src/b *`, result: "/src/b.c /src/b.h"},
+		{script: `glob -tails -path /src/b .c`, result: "b.c"},
+		{script: `glob -join pkg *.go`, result: "pkg/y.go"},
+		{script: `glob pkg/x.c`, result: "pkg/x.c"},
+		{script: `glob *.none`, err: `no files matched glob pattern "*.none"`},
+		{script: `glob *.none *.other`, err: `no files matched glob patterns "*.none *.other"`},
+		{script: `glob -nocomplain *.none`, result: ""},
+		{script: `glob -tails *`, err: `"-tails" must be used with either "-directory" or "-path"`},
+		{script: `glob -types q *`, err: `bad argument to "-types": q`},
+		{script: `glob a\{b`, err: `unmatched open-brace in file name`},
+		{script: `glob a\}`, err: `unmatched close-brace in file name`},
+		{script: `glob -bogus *`, err: `bad option "-bogus": must be -directory, -join, -nocomplain, -path, -tails, -types, or --`},
+		{script: `glob`, err: `wrong # args: should be "glob ?-option value ...? pattern ?pattern ...?"`},
+	})
+
+	safe := NewSafeInterp()
+	if _, err := safe.Eval(`glob *`); err == nil || !strings.Contains(err.Error(), "invalid command name") {
+		t.Errorf("glob in a safe interpreter: got %v", err)
+	}
+	safe.SetVFS(fsys)
+	for _, name := range []string{"file", "glob", "open"} {
+		if err := safe.ExposeCommand(name, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runEvalTests(t, safe, []evalTest{
+		{script: `glob -directory /src -tails *.h`, result: "b.h"},
+		{script: `close [open /src/new.c w]; file exists /src/new.c`, result: "1"},
+	})
+}
+
+func TestFileHost(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data.txt"), []byte("host\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `set dir ` + dir + `; file size $dir/data.txt`, result: "5"},
+		{script: `file copy $dir/data.txt $dir/copy.txt; file mkdir $dir/sub; file rename $dir/copy.txt $dir/sub`},
+		{script: `glob -tails -directory $dir *`, result: "data.txt sub"},
+		{script: `glob -tails -directory $dir/sub -types f *`, result: "copy.txt"},
+		{script: `file link $dir/link $dir/data.txt; file type $dir/link`, result: "link"},
+		{script: `file normalize $dir/sub/../data.txt`, result: filepath.Join(dir, "data.txt")},
+		{script: `dict get [file stat $dir/data.txt] type`, result: "file"},
+		{script: `file delete -force $dir/sub; file exists $dir/sub`, result: "0"},
+	})
+}
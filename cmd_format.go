@@ -0,0 +1,357 @@
+package gotcl
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// formatSpec holds the flags, width, precision and size modifier of
+// a format conversion specifier.
+type formatSpec struct {
+	left, zero, hash, plus, space bool
+	width, precision              int
+	hasPrecision                  bool
+	// size is 'h' for 16-bit, 'l' for 64-bit or 'L' for
+	// arbitrary-precision integers.
+	size byte
+}
+
+// pad pads the field made up of sign, prefix and digits to the
+// field width. Zero padding goes between the prefix and the digits.
+func (spec *formatSpec) pad(sign, prefix, digits string, zero bool) string {
+	n := len([]rune(sign)) + len([]rune(prefix)) + len([]rune(digits))
+	if n >= spec.width {
+		return sign + prefix + digits
+	}
+	fill := strings.Repeat(" ", spec.width-n)
+	switch {
+	case spec.left:
+		return sign + prefix + digits + fill
+	case zero:
+		return sign + prefix + strings.Repeat("0", spec.width-n) + digits
+	}
+	return fill + sign + prefix + digits
+}
+
+// formatInteger formats v with the integer conversion conv, which is
+// one of d, i, u, o, x, X, b or p.
+func (spec *formatSpec) formatInteger(v *Value, conv rune) (string, error) {
+	i, err := v.BigInt()
+	if err != nil {
+		return "", err
+	}
+	signed := conv == 'd' || conv == 'i'
+	if spec.size != 'L' {
+		bits := uint(64)
+		if spec.size == 'h' {
+			bits = 16
+		}
+		mod := new(big.Int).Lsh(big.NewInt(1), bits)
+		i.And(i, new(big.Int).Sub(mod, big.NewInt(1)))
+		if signed && i.Bit(int(bits)-1) == 1 {
+			i.Sub(i, mod)
+		}
+	}
+	base, prefix := 10, ""
+	switch conv {
+	case 'd', 'i':
+		prefix = "0d"
+	case 'o':
+		base, prefix = 8, "0o"
+	case 'x', 'p':
+		base, prefix = 16, "0x"
+	case 'X':
+		base, prefix = 16, "0X"
+	case 'b':
+		base, prefix = 2, "0b"
+	}
+	if !spec.hash && conv != 'p' || conv == 'u' {
+		prefix = ""
+	}
+	sign := ""
+	switch {
+	case i.Sign() < 0:
+		sign = "-"
+		i.Neg(i)
+	case signed && spec.plus:
+		sign = "+"
+	case signed && spec.space:
+		sign = " "
+	}
+	digits := i.Text(base)
+	if conv == 'X' {
+		digits = strings.ToUpper(digits)
+	}
+	if spec.hasPrecision && len(digits) < spec.precision {
+		digits = strings.Repeat("0", spec.precision-len(digits)) + digits
+	}
+	return spec.pad(sign, prefix, digits, spec.zero && !spec.hasPrecision), nil
+}
+
+// formatFloat formats v with the floating-point conversion conv,
+// which is one of f, e, E, g, G, a or A.
+func (spec *formatSpec) formatFloat(v *Value, conv rune) (string, error) {
+	f, err := v.Double()
+	if err != nil {
+		return "", err
+	}
+	var flags strings.Builder
+	if spec.plus {
+		flags.WriteByte('+')
+	} else if spec.space {
+		flags.WriteByte(' ')
+	}
+	if spec.hash {
+		flags.WriteByte('#')
+	}
+	precision := spec.precision
+	if !spec.hasPrecision {
+		precision = 6
+	}
+	var s string
+	switch conv {
+	case 'a', 'A':
+		verb := "%" + flags.String()
+		if spec.hasPrecision {
+			verb += "." + strconv.Itoa(precision)
+		}
+		s = hexFloatExponent(fmt.Sprintf(verb+"x", f))
+		if conv == 'A' {
+			s = strings.ToUpper(s)
+		}
+	default:
+		s = fmt.Sprintf("%"+flags.String()+"."+strconv.Itoa(precision)+string(conv), f)
+	}
+	sign := ""
+	if s != "" && strings.ContainsRune("+- ", rune(s[0])) {
+		sign, s = s[:1], s[1:]
+	}
+	if sign == "+" && !spec.plus {
+		sign = ""
+	}
+	finite := !math.IsInf(f, 0) && !math.IsNaN(f)
+	return spec.pad(sign, "", s, spec.zero && finite), nil
+}
+
+// hexFloatExponent removes the leading zeros that Go writes in the
+// exponent of a hexadecimal floating-point number, so that 0x1p+00
+// becomes 0x1p+0 as in C.
+func hexFloatExponent(s string) string {
+	i := strings.LastIndexByte(s, 'p')
+	if i < 0 || i+2 >= len(s) {
+		return s
+	}
+	exp := strings.TrimLeft(s[i+2:], "0")
+	if exp == "" {
+		exp = "0"
+	}
+	return s[:i+2] + exp
+}
+
+// format formatString ?arg arg ...?
+//
+// This command generates a formatted string in a fashion similar to
+// the ANSI C sprintf procedure. FormatString indicates how to format
+// the result, using % conversion specifiers as in sprintf, and the
+// additional arguments, if any, provide values to be substituted
+// into the result. The return value from format is the formatted
+// string.
+//
+// Each conversion specifier may contain up to six different parts:
+// an XPG3 position specifier, a set of flags, a minimum field width,
+// a precision, a size modifier, and a conversion character. An XPG3
+// position specifier consists of an integer followed by $ and
+// selects the argument to convert; specifiers with and without
+// positions may not be mixed. The flags are -, +, space, 0 and #.
+// The field width and precision may be given as * to take them from
+// the next argument. The size modifier h truncates integers to 16
+// bits, l to 64 bits, and ll leaves them unlimited. The conversion
+// characters are d, i, u, o, x, X, b, c, s, f, e, E, g, G, a, A, p
+// and %.
+func cmdFormat(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "formatString ?arg ...?")
+	}
+	format := args[1].Runes()
+	objs := args[2:]
+	objIndex := 0
+	gotXpg, gotSequential := false, false
+	badIndex := func() error {
+		if gotXpg {
+			return fmt.Errorf("\"%%n$\" argument index out of range")
+		}
+		return fmt.Errorf("not enough arguments for all format specifiers")
+	}
+	nextArg := func() (*Value, error) {
+		if objIndex >= len(objs) {
+			return nil, badIndex()
+		}
+		objIndex++
+		return objs[objIndex-1], nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(format); {
+		if format[i] != '%' {
+			b.WriteRune(format[i])
+			i++
+			continue
+		}
+		i++
+		if i < len(format) && format[i] == '%' {
+			b.WriteByte('%')
+			i++
+			continue
+		}
+
+		// Position specifier.
+		j := i
+		for j < len(format) && isASCIIDigit(format[j]) {
+			j++
+		}
+		if j > i && j < len(format) && format[j] == '$' {
+			if gotSequential {
+				return nil, fmt.Errorf("cannot mix \"%%\" and \"%%n$\" conversion specifiers")
+			}
+			gotXpg = true
+			n, err := strconv.Atoi(string(format[i:j]))
+			if err != nil || n < 1 || n > len(objs) {
+				return nil, badIndex()
+			}
+			objIndex = n - 1
+			i = j + 1
+		} else {
+			if gotXpg {
+				return nil, fmt.Errorf("cannot mix \"%%\" and \"%%n$\" conversion specifiers")
+			}
+			gotSequential = true
+		}
+
+		var spec formatSpec
+	flags:
+		for ; i < len(format); i++ {
+			switch format[i] {
+			case '-':
+				spec.left = true
+			case '0':
+				spec.zero = true
+			case '#':
+				spec.hash = true
+			case '+':
+				spec.plus = true
+			case ' ':
+				spec.space = true
+			default:
+				break flags
+			}
+		}
+
+		// Field width.
+		if i < len(format) && format[i] == '*' {
+			v, err := nextArg()
+			if err != nil {
+				return nil, err
+			}
+			if spec.width, err = getInt(v); err != nil {
+				return nil, err
+			}
+			if spec.width < 0 {
+				spec.width = -spec.width
+				spec.left = true
+			}
+			i++
+		} else {
+			for ; i < len(format) && isASCIIDigit(format[i]); i++ {
+				spec.width = spec.width*10 + int(format[i]-'0')
+			}
+		}
+
+		// Precision.
+		if i < len(format) && format[i] == '.' {
+			spec.hasPrecision = true
+			i++
+			if i < len(format) && format[i] == '*' {
+				v, err := nextArg()
+				if err != nil {
+					return nil, err
+				}
+				if spec.precision, err = getInt(v); err != nil {
+					return nil, err
+				}
+				if spec.precision < 0 {
+					spec.precision = 0
+				}
+				i++
+			} else {
+				for ; i < len(format) && isASCIIDigit(format[i]); i++ {
+					spec.precision = spec.precision*10 + int(format[i]-'0')
+				}
+			}
+		}
+
+		// Size modifier.
+		if i < len(format) {
+			switch format[i] {
+			case 'h':
+				spec.size = 'h'
+				i++
+			case 'l':
+				spec.size = 'l'
+				i++
+				if i < len(format) && format[i] == 'l' {
+					spec.size = 'L'
+					i++
+				}
+			case 'L':
+				spec.size = 'L'
+				i++
+			case 'j', 'q', 'z', 't':
+				spec.size = 'l'
+				i++
+			}
+		}
+
+		if i >= len(format) {
+			return nil, fmt.Errorf("format string ended in middle of field specifier")
+		}
+		conv := format[i]
+		i++
+		if !strings.ContainsRune("diuoxXbcsfeEgGaAp", conv) {
+			return nil, fmt.Errorf("bad field specifier \"%c\"", conv)
+		}
+		v, err := nextArg()
+		if err != nil {
+			return nil, err
+		}
+		var s string
+		switch conv {
+		case 's':
+			r := v.Runes()
+			if spec.hasPrecision && spec.precision < len(r) {
+				r = r[:spec.precision]
+			}
+			s = spec.pad("", "", string(r), false)
+		case 'c':
+			c, err := v.BigInt()
+			if err != nil {
+				return nil, err
+			}
+			if !c.IsInt64() || c.Int64() < math.MinInt32 || c.Int64() > math.MaxInt32 {
+				return nil, fmt.Errorf("integer value too large to represent")
+			}
+			s = spec.pad("", "", string(rune(c.Int64())), false)
+		case 'f', 'e', 'E', 'g', 'G', 'a', 'A':
+			s, err = spec.formatFloat(v, conv)
+		default:
+			s, err = spec.formatInteger(v, conv)
+		}
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(s)
+	}
+	return NewStringValue(b.String()), nil
+}
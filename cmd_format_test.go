@@ -0,0 +1,94 @@
+package gotcl
+
+import (
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `format "%s is %d years" Bob 42`, result: "Bob is 42 years"},
+		{script: `format {%5s|%-5s|%.2s} ab cd xyz`, result: "   ab|cd   |xy"},
+		{script: `format {%05d|%-5d|%+d|% d} 42 42 42 42`, result: "00042|42   |+42| 42"},
+		{script: `format {%.5d|%8.3d} 42 -7`, result: "00042|    -007"},
+		{script: `format {%x %X %o %b} 255 255 8 5`, result: "ff FF 10 101"},
+		{script: `format {%#x %#X %#o %#b %#d} 255 255 8 5 7`, result: "0xff 0XFF 0o10 0b101 0d7"},
+		{script: `format {%#06x} 255`, result: "0x00ff"},
+		{script: `format {%x %hx %hd %u} -1 -1 65537 -1`, result: "ffffffffffffffff ffff 1 18446744073709551615"},
+		{script: `format %d 18446744073709551617`, result: "1"},
+		{script: `format {%lld %llx} 123456789012345678901234567890 -255`, result: "123456789012345678901234567890 -ff"},
+		{script: `format {%ld} 9223372036854775808`, result: "-9223372036854775808"},
+		{script: `format %c%c%c 71 111 8364`, result: "Go€"},
+		{script: `format {%3c|%-3c|} 65 66`, result: "  A|B  |"},
+		{script: `format {%f %.2f %e %E} 3.14159 3.14159 1234.5 0.00012`, result: "3.141590 3.14 1.234500e+03 1.200000E-04"},
+		{script: `format {%g %g %g %G} 100000 1000000 0.0001 1e-5`, result: "100000 1e+06 0.0001 1E-05"},
+		{script: `format {%#g %.3g %010.3f %-8.1f|} 1.5 3.14159 -3.14159 2.25`, result: "1.50000 3.14 -00003.142 2.2     |"},
+		{script: `format {%f %5.1f %+f} Inf -Inf NaN`, result: "Inf  -Inf +NaN"},
+		{script: `format {%a %a %A %.2a} 1.0 3.0 0.5 1.0`, result: "0x1p+0 0x1.8p+1 0X1P-1 0x1.00p+0"},
+		{script: `format {%*d|%-*d|%.*f} 5 42 4 7 2 3.14159`, result: "   42|7   |3.14"},
+		{script: `format {%*d|} -4 1`, result: "1   |"},
+		{script: `format {%2$s %1$s} world hello`, result: "hello world"},
+		{script: `format {%1$s %1$s %2$5d} 3 7`, result: "3 3     7"},
+		{script: `format {100%%}`, result: "100%"},
+		{script: `format %p 255`, result: "0xff"},
+		{script: `format %d`, err: `not enough arguments for all format specifiers`},
+		{script: `format %d 1.5`, err: `expected integer but got "1.5"`},
+		{script: `format %f x`, err: `expected floating-point number but got "x"`},
+		{script: `format {%1$s %s} a b`, err: `cannot mix "%" and "%n$" conversion specifiers`},
+		{script: `format {%s %1$s} a b`, err: `cannot mix "%" and "%n$" conversion specifiers`},
+		{script: `format {%3$s} a b`, err: `"%n$" argument index out of range`},
+		{script: `format {%0$s} a`, err: `"%n$" argument index out of range`},
+		{script: `format {%5} a`, err: `format string ended in middle of field specifier`},
+		{script: `format {%y} a`, err: `bad field specifier "y"`},
+		{script: `format`, err: `wrong # args: should be "format formatString ?arg ...?"`},
+	})
+}
+
+func TestScan(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `scan "42 abc 3.5" "%d %s %f"`, result: "42 abc 3.5"},
+		{script: `scan "  12   34" "%d%d" a b`, result: "2"},
+		{script: `list $a $b`, result: "12 34"},
+		{script: `scan "ff 17 101 0x1f" "%x %o %b %x"`, result: "255 15 5 31"},
+		{script: `scan "0x10 0o10 0b10 10" "%i %i %i %i"`, result: "16 8 2 10"},
+		{script: `scan "-1" %u`, result: "18446744073709551615"},
+		{script: `scan "99999999999999999999 -99999999999999999999" "%d %d"`, result: "9223372036854775807 -9223372036854775808"},
+		{script: `scan "99999999999999999999" %lld`, result: "99999999999999999999"},
+		{script: `scan "-1" %llu`, err: `unsigned bignum scans are invalid`},
+		{script: `scan "abc" "%c%c"`, result: "97 98"},
+		{script: `scan " x" "%c"`, result: "32"},
+		{script: `scan "12345" "%2d%3d"`, result: "12 345"},
+		{script: `scan "abcdef" "%3s%s"`, result: "abc def"},
+		{script: `scan "key=value;" {%[a-z]=%[^;]}`, result: "key value"},
+		{script: `scan "a]b" {%[]a]%s}`, result: "{a]} b"},
+		{script: `scan "a-b" {%[a-]}`, result: "a-"},
+		{script: `scan "12 34" "%*d %d"`, result: "34"},
+		{script: `scan "ab cd" "%s%n %s%n"`, result: "ab 2 cd 5"},
+		{script: `scan "1.5e3 -2. .25 inf" "%f %e %g %f"`, result: "1500.0 -2.0 0.25 Inf"},
+		{script: `scan "7" "%f"`, result: "7.0"},
+		{script: `scan "10 20" {%2$d %1$d}`, result: "20 10"},
+		{script: `scan "10 20" {%2$d %1$d} x y`, result: "2"},
+		{script: `list $x $y`, result: "20 10"},
+		{script: `scan "10 20%" "%d %d%%"`, result: "10 20"},
+		{script: `scan "12 abc" "%d %d"`, result: "12 {}"},
+		{script: `unset -nocomplain p q; scan "12 abc" "%d %d" p q`, result: "1"},
+		{script: `set q`, err: `can't read "q": no such variable`},
+		{script: `list $p`, result: "12"},
+		{script: `scan "" "%d" p`, result: "-1"},
+		{script: `scan "   " "%d"`, result: ""},
+		{script: `scan "x12" "x%d"`, result: "12"},
+		{script: `scan "y12" "x%d"`, result: "{}"},
+		{script: `scan "12" "%d %d" a b`, result: "1"},
+		{script: `scan "1" "%d" a b`, err: `different numbers of variable names and field specifiers`},
+		{script: `scan "1 2" "%d %d" a`, err: `different numbers of variable names and field specifiers`},
+		{script: `scan "1" {%1$d %d}`, err: `cannot mix "%" and "%n$" conversion specifiers`},
+		{script: `scan "1" {%1$d %1$d}`, err: `variable is assigned by multiple "%n$" conversion specifiers`},
+		{script: `scan "1" {%2$d}`, err: `variable is not assigned by any conversion specifiers`},
+		{script: `scan "1" {%2$d} a`, err: `"%n$" argument index out of range`},
+		{script: `scan "1" {%[a-z}`, err: `unmatched [ in format string`},
+		{script: `scan "1" {%q}`, err: `bad scan conversion character "q"`},
+		{script: `scan "1" {%3c}`, err: `field width may not be specified in %c conversion`},
+		{script: `scan "1"`, err: `wrong # args: should be "scan string format ?varName ...?"`},
+	})
+}
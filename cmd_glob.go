@@ -0,0 +1,352 @@
+package gotcl
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+var globOptions = []string{"-directory", "-join", "-nocomplain", "-path", "-tails", "-types", "--"}
+
+// glob ?switches? ?pattern ...?
+//
+// This command performs file name "globbing" in a fashion similar to
+// the csh shell or bash shell. It returns a list of the files whose
+// names match any of the pattern arguments. No particular order is
+// guaranteed in the list; this implementation returns the matches of
+// each pattern sorted by directory entry.
+//
+// The patterns use the syntax of string match, with the addition of
+// {a,b,...}, which matches any of the sub-patterns a, b, and so on.
+// A file whose name starts with a dot is only matched by a pattern
+// whose component starts with a dot, and a pattern ending with /
+// matches only directories, which are returned with a trailing /.
+//
+// The switches are:
+//
+//	-directory directory  search for files matching the patterns in
+//	                      directory and return them joined to it
+//	-join                 join the remaining arguments into a single
+//	                      pattern as file join does
+//	-nocomplain           return an empty list rather than an error
+//	                      if no files match
+//	-path pathPrefix      search for files whose names start with
+//	                      pathPrefix and continue with a pattern
+//	-tails                return only the part of each file found
+//	                      which follows the -directory or -path
+//	-types typeList       only match files of at least one of the
+//	                      types b, c, d, f, l, p and s and with all the
+//	                      permissions r, w and x, hidden and readonly
+//	                      given in typeList
+//	--                    mark the end of the switches
+func cmdGlob(interp *Interp, args []*Value) (*Value, error) {
+	var dir, pathPrefix string
+	var hasDir, hasPath, join, nocomplain, tails bool
+	var types []string
+	i := 1
+options:
+	for ; i < len(args) && strings.HasPrefix(args[i].String(), "-"); i++ {
+		opt, err := lookupIndex(globOptions, "option", args[i])
+		if err != nil {
+			return nil, err
+		}
+		switch globOptions[opt] {
+		case "-join":
+			join = true
+			continue
+		case "-nocomplain":
+			nocomplain = true
+			continue
+		case "-tails":
+			tails = true
+			continue
+		case "--":
+			i++
+			break options
+		}
+		if i+1 == len(args) {
+			return nil, fmt.Errorf("missing argument to \"%s\"", globOptions[opt])
+		}
+		i++
+		switch globOptions[opt] {
+		case "-directory":
+			if hasPath {
+				return nil, errors.New(`"-directory" cannot be used with "-path"`)
+			}
+			dir, hasDir = args[i].String(), true
+		case "-path":
+			if hasDir {
+				return nil, errors.New(`"-path" cannot be used with "-directory"`)
+			}
+			pathPrefix, hasPath = args[i].String(), true
+		case "-types":
+			list, err := args[i].List()
+			if err != nil {
+				return nil, err
+			}
+			types = valuesToStrings(list)
+		}
+	}
+	if i == len(args) {
+		return nil, wrongNumArgs(args, 1, "?-option value ...? pattern ?pattern ...?")
+	}
+	if tails && !hasDir && !hasPath {
+		return nil, errors.New(`"-tails" must be used with either "-directory" or "-path"`)
+	}
+	patterns := valuesToStrings(args[i:])
+	if join {
+		patterns = []string{joinPath(patterns)}
+	}
+	g := &globber{vfs: interp.filesystem()}
+	if err := g.setTypes(types); err != nil {
+		return nil, err
+	}
+	base := dir
+	if hasPath {
+		base = ""
+		if strings.Contains(pathPrefix, "/") {
+			base = fileDirname(pathPrefix)
+		}
+	}
+	for _, pattern := range patterns {
+		expanded, err := expandBraces(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range expanded {
+			if hasPath {
+				p = quoteGlob(fileTail(pathPrefix)) + p
+			}
+			g.dirOnly = strings.HasSuffix(p, "/") && p != "/"
+			parts := splitPath(p)
+			d, shown := base, base
+			if tails {
+				shown = ""
+			}
+			if len(parts) > 0 && isRoot(parts[0]) {
+				d, shown, parts = parts[0], parts[0], parts[1:]
+			}
+			if len(parts) > 0 || d != "" {
+				g.walk(d, shown, parts)
+			}
+		}
+	}
+	if len(g.matches) == 0 && !nocomplain {
+		if len(patterns) == 1 {
+			return nil, fmt.Errorf("no files matched glob pattern \"%s\"", patterns[0])
+		}
+		return nil, fmt.Errorf("no files matched glob patterns \"%s\"", strings.Join(patterns, " "))
+	}
+	return NewListValue(stringsToValues(g.matches)), nil
+}
+
+// A globber finds the files matching glob patterns.
+type globber struct {
+	vfs VFS
+	// classes holds the file types a match must have one of, if
+	// any, and perms the permissions it must all have. hidden and
+	// readonly are set by the corresponding types.
+	classes  string
+	perms    []uint32
+	hidden   bool
+	readonly bool
+	// dirOnly is set while matching a pattern ending with /.
+	dirOnly bool
+	matches []string
+}
+
+// setTypes sets the requirements of the -types switch.
+func (g *globber) setTypes(types []string) error {
+	for _, t := range types {
+		switch t {
+		case "b", "c", "d", "f", "l", "p", "s":
+			g.classes += t
+		case "r":
+			g.perms = append(g.perms, 4)
+		case "w":
+			g.perms = append(g.perms, 2)
+		case "x":
+			g.perms = append(g.perms, 1)
+		case "hidden":
+			g.hidden = true
+		case "readonly":
+			g.readonly = true
+		default:
+			return fmt.Errorf("bad argument to \"-types\": %s", t)
+		}
+	}
+	return nil
+}
+
+// walk matches the pattern components parts against the files in the
+// directory dir, which is shown as shown in the matches.
+func (g *globber) walk(dir, shown string, parts []string) {
+	if len(parts) == 0 {
+		g.add(dir, shown)
+		return
+	}
+	part, rest := parts[0], parts[1:]
+	if !hasWildcards(part) {
+		part = unquoteGlob(part)
+		name := joinPath([]string{dir, part})
+		if _, err := g.vfs.Lstat(name); err == nil {
+			g.walk(name, joinPath([]string{shown, part}), rest)
+		}
+		return
+	}
+	list := dir
+	if list == "" {
+		list = "."
+	}
+	entries, err := g.vfs.ReadDir(list)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(part, ".") {
+			continue
+		}
+		if stringMatch([]rune(part), []rune(name), false) {
+			g.walk(joinPath([]string{dir, name}), joinPath([]string{shown, name}), rest)
+		}
+	}
+}
+
+// add adds the file name, shown as shown, to the matches if it has
+// the types required.
+func (g *globber) add(name, shown string) {
+	info, err := g.vfs.Stat(name)
+	linfo, lerr := g.vfs.Lstat(name)
+	if lerr != nil {
+		return
+	}
+	if err != nil {
+		info = linfo
+	}
+	if g.dirOnly {
+		if !info.IsDir() {
+			return
+		}
+		shown += "/"
+	}
+	if g.classes != "" && !strings.Contains(g.classes, globClass(info.Mode())) &&
+		!(strings.Contains(g.classes, "l") && linfo.Mode()&fs.ModeSymlink != 0) {
+		return
+	}
+	for _, perm := range g.perms {
+		if g.vfs.Access(name, perm) != nil {
+			return
+		}
+	}
+	if g.hidden && !strings.HasPrefix(fileTail(name), ".") {
+		return
+	}
+	if g.readonly && g.vfs.Access(name, 2) == nil {
+		return
+	}
+	g.matches = append(g.matches, shown)
+}
+
+// globClass returns the letter -types uses for the type of mode.
+func globClass(mode fs.FileMode) string {
+	switch fileType(mode) {
+	case "directory":
+		return "d"
+	case "link":
+		return "l"
+	case "fifo":
+		return "p"
+	case "socket":
+		return "s"
+	case "characterSpecial":
+		return "c"
+	case "blockSpecial":
+		return "b"
+	}
+	return "f"
+}
+
+// expandBraces returns the patterns that pattern stands for once each
+// {a,b,...} in it is replaced by each of its alternatives.
+func expandBraces(pattern string) ([]string, error) {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '}':
+			return nil, errors.New("unmatched close-brace in file name")
+		case '{':
+			depth, start := 0, i+1
+			var alternatives []string
+			for j := i; j < len(pattern); j++ {
+				switch pattern[j] {
+				case '\\':
+					j++
+				case '{':
+					depth++
+				case ',':
+					if depth == 1 {
+						alternatives = append(alternatives, pattern[start:j])
+						start = j + 1
+					}
+				case '}':
+					if depth--; depth > 0 {
+						continue
+					}
+					var expanded []string
+					for _, alt := range append(alternatives, pattern[start:j]) {
+						more, err := expandBraces(pattern[:i] + alt + pattern[j+1:])
+						if err != nil {
+							return nil, err
+						}
+						expanded = append(expanded, more...)
+					}
+					return expanded, nil
+				}
+			}
+			return nil, errors.New("unmatched open-brace in file name")
+		}
+	}
+	return []string{pattern}, nil
+}
+
+// hasWildcards reports whether the pattern s has unquoted wildcards.
+func hasWildcards(s string) bool {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+// quoteGlob quotes the characters of s that are special in glob
+// patterns.
+func quoteGlob(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune("*?[]{}\\", c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// unquoteGlob removes the backslashes quoting characters of the glob
+// pattern s.
+func unquoteGlob(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
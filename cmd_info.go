@@ -0,0 +1,478 @@
+package gotcl
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// tclPatchLevel is the version of Tcl the interpreter implements.
+const tclPatchLevel = "9.0.0"
+
+var infoSubcommands = []subcommand{
+	{"args", cmdInfoArgs},
+	{"body", cmdInfoBody},
+	{"class", cmdInfoClass},
+	{"cmdcount", cmdInfoCmdcount},
+	{"cmdtype", cmdInfoCmdtype},
+	{"commands", cmdInfoCommands},
+	{"complete", cmdInfoComplete},
+	{"coroutine", cmdInfoCoroutine},
+	{"default", cmdInfoDefault},
+	{"errorstack", cmdInfoErrorstack},
+	{"exists", cmdInfoExists},
+	{"frame", cmdInfoFrame},
+	{"globals", cmdInfoGlobals},
+	{"hostname", cmdInfoHostname},
+	{"level", cmdInfoLevel},
+	{"locals", cmdInfoLocals},
+	{"nameofexecutable", cmdInfoNameofexecutable},
+	{"object", cmdInfoObject},
+	{"patchlevel", cmdInfoPatchlevel},
+	{"procs", cmdInfoProcs},
+	{"script", cmdInfoScript},
+	{"vars", cmdInfoVars},
+}
+
+// info option ?arg arg ...?
+//
+// This command provides information about various internals of the
+// Tcl interpreter.
+func cmdInfo(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "subcommand ?arg ...?")
+	}
+	return dispatchSubcommand(interp, args, infoSubcommands)
+}
+
+// info coroutine
+//
+// Returns the name of the currently executing coroutine, or the
+// empty string if either no coroutine is currently executing, or
+// the current coroutine has been deleted (but has not yet returned
+// or yielded since deletion).
+func cmdInfoCoroutine(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 2 {
+		return nil, wrongNumArgs(args, 2, "")
+	}
+	co := interp.coroutine
+	if co == nil || co.deleted {
+		return emptyValue(), nil
+	}
+	return NewStringValue("::" + co.cmd.name), nil
+}
+
+// lookupProc returns the procedure called name.
+func (interp *Interp) lookupProc(name *Value) (*procedure, error) {
+	cmd, ok := interp.commands[commandName(name.String())]
+	if !ok || cmd.proc == nil {
+		return nil, fmt.Errorf("\"%s\" isn't a procedure", name)
+	}
+	return cmd.proc, nil
+}
+
+// matchNames returns the sorted list of the names matching the
+// optional pattern args[idx]. Names are qualified with :: only if the
+// pattern is, and names in other namespaces are only listed for a
+// pattern qualified with a namespace.
+func matchNames(names []string, args []*Value, idx int) *Value {
+	var pattern []rune
+	if idx < len(args) {
+		pattern = args[idx].Runes()
+	}
+	qualified := strings.Contains(string(pattern), "::")
+	prefix := ""
+	if strings.HasPrefix(string(pattern), "::") {
+		prefix = "::"
+	}
+	var matched []string
+	for _, name := range names {
+		if !qualified && strings.Contains(name, "::") {
+			continue
+		}
+		name = prefix + name
+		if pattern == nil || stringMatch(pattern, []rune(name), false) {
+			matched = append(matched, name)
+		}
+	}
+	sort.Strings(matched)
+	return NewListValue(stringsToValues(matched))
+}
+
+// definedVars returns the names of the defined variables of vars,
+// leaving out those of links if it is not nil.
+func definedVars(vars map[string]*variable, links map[string]bool) []string {
+	var names []string
+	for name, vr := range vars {
+		if !vr.undefined() && !links[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// info args procname
+//
+// Returns the names of the parameters to procedure procname, in
+// order.
+func cmdInfoArgs(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "procname")
+	}
+	proc, err := interp.lookupProc(args[2])
+	if err != nil {
+		return nil, err
+	}
+	names := make([]*Value, len(proc.params))
+	for i, p := range proc.params {
+		names[i] = NewStringValue(p.name)
+	}
+	return NewListValue(names), nil
+}
+
+// info body procname
+//
+// Returns the body of procedure procname.
+func cmdInfoBody(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "procname")
+	}
+	proc, err := interp.lookupProc(args[2])
+	if err != nil {
+		return nil, err
+	}
+	return proc.body, nil
+}
+
+// info cmdcount
+//
+// Returns a count of the total number of commands that have been
+// invoked in this interpreter.
+func cmdInfoCmdcount(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 2 {
+		return nil, wrongNumArgs(args, 2, "")
+	}
+	return NewIntValue(int64(interp.cmdCount)), nil
+}
+
+// info cmdtype commandName
+//
+// Returns the type of the command named commandName: proc for
+// procedures, alias for aliases, interp for the commands of child
+// interpreters, coroutine for coroutines, object for objects, and
+// native for commands implemented in Go.
+func cmdInfoCmdtype(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "commandName")
+	}
+	cmd, ok := interp.commands[commandName(args[2].String())]
+	if !ok {
+		return nil, fmt.Errorf("invalid command name \"%s\"", args[2])
+	}
+	if cmd.kind == "" {
+		return NewStringValue("native"), nil
+	}
+	return NewStringValue(cmd.kind), nil
+}
+
+// info commands ?pattern?
+//
+// If pattern is not specified, returns a list of names of all the
+// Tcl commands in the global namespace, including both the built-in
+// commands written in Go and the command procedures defined using
+// the proc command. If pattern is specified, only those names
+// matching pattern are returned. Matching is determined using the
+// same rules as for string match.
+func cmdInfoCommands(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 3 {
+		return nil, wrongNumArgs(args, 2, "?pattern?")
+	}
+	names := make([]string, 0, len(interp.commands))
+	for name := range interp.commands {
+		names = append(names, name)
+	}
+	return matchNames(names, args, 2), nil
+}
+
+// info complete command
+//
+// Returns 1 if command is a complete Tcl command in the sense of
+// having no unclosed quotes, braces, brackets or array element
+// names. If the command does not appear to be complete then 0 is
+// returned.
+func cmdInfoComplete(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "command")
+	}
+	script := args[2].Runes()
+	for idx := 0; idx < len(script); {
+		_, size, err := ParseCommand(script[idx:], false)
+		if err != nil {
+			switch err.Error() {
+			case "missing close-bracket", "missing close-brace for variable name",
+				"unterminated brace word", "unterminated double-quote word":
+				return NewBoolValue(false), nil
+			}
+			return NewBoolValue(true), nil
+		}
+		if size == 0 {
+			break
+		}
+		idx += size
+	}
+	return NewBoolValue(true), nil
+}
+
+// info default procname arg varname
+//
+// Procname must be the name of a Tcl command procedure and arg must
+// be the name of an argument to that procedure. If arg does not have
+// a default value then the command returns 0. Otherwise it returns 1
+// and places the default value of arg into variable varname.
+func cmdInfoDefault(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 5 {
+		return nil, wrongNumArgs(args, 2, "procname arg varname")
+	}
+	proc, err := interp.lookupProc(args[2])
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range proc.params {
+		if p.name != args[3].String() {
+			continue
+		}
+		def := emptyValue()
+		if p.hasDef {
+			def = p.def
+		}
+		if _, err := interp.setVar(args[4].String(), def); err != nil {
+			return nil, fmt.Errorf("couldn't store default value in variable \"%s\"", args[4])
+		}
+		return NewBoolValue(p.hasDef), nil
+	}
+	return nil, fmt.Errorf("procedure \"%s\" doesn't have an argument \"%s\"", args[2], args[3])
+}
+
+// info errorstack ?interp?
+//
+// Returns a description of the active command at each level for the
+// last error in the current interpreter, or in the interpreter
+// interp if given. The result is a list of the form INNER cmd
+// followed by CALL proc ... pairs, giving the command that raised the
+// error and the procedure calls it propagated through.
+func cmdInfoErrorstack(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 3 {
+		return nil, wrongNumArgs(args, 2, "?interp?")
+	}
+	target, err := interp.optionalInterp(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return NewListValue(target.errorStack), nil
+}
+
+// info exists varName
+//
+// Returns 1 if the variable named varName exists in the current
+// context (either as a global or local variable) and has been
+// defined by being given a value, returns 0 otherwise.
+func cmdInfoExists(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "varName")
+	}
+	name, index, isElem := splitVarName(args[2].String())
+	vr, elem := interp.lookupVar2(name, index, isElem)
+	if isElem {
+		vr = elem
+	}
+	return NewBoolValue(vr != nil && !vr.undefined()), nil
+}
+
+// info frame ?number?
+//
+// If number is not specified, returns the number of commands being
+// executed. Otherwise returns a dictionary describing the command at
+// that position: 1 is the outermost command, 0 the current one, and
+// negative numbers are relative to the current command. The
+// dictionary holds the keys type (source, proc or eval), line (the
+// line of the command in its script), file (for sourced scripts),
+// cmd (the command text), proc (for procedure bodies) and level (the
+// stack level the command is executed at).
+func cmdInfoFrame(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 3 {
+		return nil, wrongNumArgs(args, 2, "?number?")
+	}
+	n := len(interp.cmdFrames)
+	if len(args) == 2 {
+		return NewIntValue(int64(n)), nil
+	}
+	i, err := args[2].Int()
+	if i <= 0 {
+		i += int64(n)
+	}
+	if err != nil || i < 1 || i > int64(n) {
+		return nil, fmt.Errorf("bad level \"%s\"", args[2])
+	}
+	return interp.cmdFrames[i-1].dict(), nil
+}
+
+// info globals ?pattern?
+//
+// If pattern is not specified, returns a list of all the names of
+// currently-defined global variables. If pattern is specified, only
+// those names matching pattern are returned.
+func cmdInfoGlobals(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 3 {
+		return nil, wrongNumArgs(args, 2, "?pattern?")
+	}
+	return matchNames(definedVars(interp.globals, nil), args, 2), nil
+}
+
+// info hostname
+//
+// Returns the name of the computer on which this invocation is being
+// executed. A safe interpreter gets an empty string.
+func cmdInfoHostname(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 2 {
+		return nil, wrongNumArgs(args, 2, "")
+	}
+	if interp.safe {
+		return emptyValue(), nil
+	}
+	name, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	return NewStringValue(name), nil
+}
+
+// info level ?number?
+//
+// If number is not specified, this command returns a number giving
+// the stack level of the invoking procedure, or 0 if the command is
+// invoked at top-level. If number is specified, then the result is a
+// list consisting of the name and arguments for the procedure call
+// at level number on the stack. If number is positive then it
+// selects a particular stack level; if number is zero or negative
+// then it refers to a level relative to the current level.
+func cmdInfoLevel(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 3 {
+		return nil, wrongNumArgs(args, 2, "?number?")
+	}
+	cur := int64(interp.frame.level)
+	if len(args) == 2 {
+		return NewIntValue(cur), nil
+	}
+	n, err := args[2].Int()
+	if n <= 0 {
+		n += cur
+	}
+	if err != nil || n < 1 || n > cur {
+		return nil, fmt.Errorf("bad level \"%s\"", args[2])
+	}
+	frame := interp.frame
+	for int64(frame.level) > n {
+		frame = frame.caller
+	}
+	return NewListValue(frame.args), nil
+}
+
+// info locals ?pattern?
+//
+// If pattern is not specified, returns a list of all the names of
+// currently-defined local variables, including arguments to the
+// current procedure, if any. Variables defined with the global and
+// upvar commands will not be returned. If pattern is specified, only
+// those names matching pattern are returned.
+func cmdInfoLocals(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 3 {
+		return nil, wrongNumArgs(args, 2, "?pattern?")
+	}
+	if interp.frame == interp.globalFrame {
+		return emptyValue(), nil
+	}
+	return matchNames(definedVars(interp.frame.vars, interp.frame.links), args, 2), nil
+}
+
+// info nameofexecutable
+//
+// Returns the full path name of the binary file from which the
+// application was invoked. If the path cannot be determined, or the
+// interpreter is safe, an empty string is returned.
+func cmdInfoNameofexecutable(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 2 {
+		return nil, wrongNumArgs(args, 2, "")
+	}
+	if interp.safe {
+		return emptyValue(), nil
+	}
+	name, err := os.Executable()
+	if err != nil {
+		return emptyValue(), nil
+	}
+	return NewStringValue(name), nil
+}
+
+// info patchlevel
+//
+// Returns the exact version of Tcl implemented by the interpreter.
+func cmdInfoPatchlevel(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 2 {
+		return nil, wrongNumArgs(args, 2, "")
+	}
+	return NewStringValue(tclPatchLevel), nil
+}
+
+// info procs ?pattern?
+//
+// If pattern is not specified, returns a list of all the names of
+// Tcl command procedures in the global namespace. If pattern is
+// specified, only those procedure names matching pattern are
+// returned.
+func cmdInfoProcs(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 3 {
+		return nil, wrongNumArgs(args, 2, "?pattern?")
+	}
+	var names []string
+	for name, cmd := range interp.commands {
+		if cmd.proc != nil {
+			names = append(names, name)
+		}
+	}
+	return matchNames(names, args, 2), nil
+}
+
+// info script ?filename?
+//
+// If a Tcl script file is currently being evaluated, then this
+// command returns the name of the innermost file being processed.
+// If filename is specified, then the return value of this command
+// will be modified for the duration of the active invocation to
+// return that name. Otherwise, the command returns an empty string.
+func cmdInfoScript(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 3 {
+		return nil, wrongNumArgs(args, 2, "?filename?")
+	}
+	if len(args) == 3 {
+		interp.scriptFile = args[2].String()
+	}
+	return NewStringValue(interp.scriptFile), nil
+}
+
+// info vars ?pattern?
+//
+// If pattern is not specified, returns a list of all the names of
+// currently-visible variables. This includes locals and
+// currently-visible globals. If pattern is specified, only those
+// names matching pattern are returned.
+func cmdInfoVars(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 3 {
+		return nil, wrongNumArgs(args, 2, "?pattern?")
+	}
+	vars := interp.frame.vars
+	if len(args) == 3 && strings.HasPrefix(args[2].String(), "::") {
+		vars = interp.globals
+	}
+	return matchNames(definedVars(vars, nil), args, 2), nil
+}
@@ -0,0 +1,70 @@
+package gotcl
+
+import "testing"
+
+func TestInfoProcs(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `proc p {a {b 2} args} {list $a $b}`},
+		{script: `info args p`, result: "a b args"},
+		{script: `info body p`, result: "list $a $b"},
+		{script: `list [info default p b v] $v [info default p a w] $w`, result: "1 2 0 {}"},
+		{script: `info default p c v`, err: `procedure "p" doesn't have an argument "c"`},
+		{script: `info args set`, err: `"set" isn't a procedure`},
+		{script: `proc q {} {}; info procs`, result: "p q"},
+		{script: `info commands inf*`, result: "info"},
+		{script: `info commands ::q`, result: "::q"},
+		{script: `interp alias {} al {} list; interp create kid; coroutine co yield; oo::class create K`, result: "::K"},
+		{script: `lmap c {p al kid co K set} {info cmdtype $c}`, result: "proc alias interp coroutine object native"},
+		{script: `info cmdtype nope`, err: `invalid command name "nope"`},
+		{script: `info bogus`, err: `unknown or ambiguous subcommand "bogus": must be args, body, class, cmdcount, cmdtype, commands, complete, coroutine, default, errorstack, exists, frame, globals, hostname, level, locals, nameofexecutable, object, patchlevel, procs, script, or vars`},
+	})
+}
+
+func TestInfoVars(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `set g1 1; set g2(a) 1; info globals g*`, result: "g1 g2"},
+		{script: `list [info exists g1] [info exists g2(a)] [info exists g2(b)] [info exists nope]`, result: "1 1 0 0"},
+		{script: `proc p {x} {global g1; upvar 1 g2 u; set y 1; list [info locals] [info vars] [info vars ::g*] [info exists u(a)]}; p 0`, result: "{x y} {g1 u x y} {::g1 ::g2} 1"},
+		{script: `info locals`, result: ""},
+		{script: `proc lv {args} {list [info level] [info level 0] [info level -1]}; proc outer {} {lv a b}; outer`, result: "2 {lv a b} outer"},
+		{script: `info level`, result: "0"},
+		{script: `info level 1`, err: `bad level "1"`},
+	})
+}
+
+func TestInfoFrameErrorstack(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `proc f {} {
+			info frame 0
+		}; f`, result: "type proc line 2 cmd {info frame 0} proc ::f level 1"},
+		{script: `proc g {} {
+			set a 1
+
+			info frame 0
+		}; dict get [g] line`, result: "4"},
+		{script: `info frame`, result: "1"},
+		{script: `proc depth {} {info frame}; list [depth]`, result: "2"},
+		{script: `info frame 2`, err: `bad level "2"`},
+		{script: `proc inner {x} {set nope}; proc mid {} {inner 1}; mid`, err: `can't read "nope": no such variable`},
+		{script: `info errorstack`, result: "INNER {set nope} CALL {inner 1} CALL mid"},
+		{script: `list [info complete {set x {a}}] [info complete "set x \{a"] [info complete {set x [a}] [info complete {set x "a}]`, result: "1 0 0 0"},
+		{script: `info script`, result: ""},
+		{script: `info script a.tcl; info script`, result: "a.tcl"},
+		{script: `info patchlevel`, result: tclPatchLevel},
+		{script: `string is integer [info cmdcount]`, result: "1"},
+	})
+}
+
+func TestInfoHost(t *testing.T) {
+	runEvalTests(t, NewInterp(), []evalTest{
+		{script: `string equal [info hostname] ""`, result: "0"},
+		{script: `file exists [info nameofexecutable]`, result: "1"},
+	})
+	runEvalTests(t, NewSafeInterp(), []evalTest{
+		{script: `info hostname`, result: ""},
+		{script: `info nameofexecutable`, result: ""},
+	})
+}
@@ -0,0 +1,437 @@
+package gotcl
+
+import (
+	"fmt"
+)
+
+var interpSubcommands = []subcommand{
+	{"alias", cmdInterpAlias},
+	{"aliases", cmdInterpAliases},
+	{"children", cmdInterpChildren},
+	{"create", cmdInterpCreate},
+	{"delete", cmdInterpDelete},
+	{"eval", cmdInterpEval},
+	{"exists", cmdInterpExists},
+	{"expose", cmdInterpExpose},
+	{"hidden", cmdInterpHidden},
+	{"hide", cmdInterpHide},
+	{"invokehidden", cmdInterpInvokeHidden},
+	{"issafe", cmdInterpIsSafe},
+	{"share", cmdInterpShare},
+	{"slaves", cmdInterpChildren},
+	{"target", cmdInterpTarget},
+	{"transfer", cmdInterpTransfer},
+}
+
+// interp subcommand ?arg ...?
+//
+// This command makes it possible to create one or more new Tcl
+// interpreters that co-exist with the creating interpreter in the
+// same application.
+func cmdInterp(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "cmd ?arg ...?")
+	}
+	return dispatchSubcommand(interp, args, interpSubcommands)
+}
+
+// lookupInterp returns the interpreter identified by path, relative
+// to interp.
+func (interp *Interp) lookupInterp(path *Value) (*Interp, error) {
+	names, err := path.List()
+	if err != nil {
+		return nil, err
+	}
+	target := interp
+	for _, name := range names {
+		child, ok := target.children[name.String()]
+		if !ok {
+			return nil, fmt.Errorf("could not find interpreter \"%s\"", path)
+		}
+		target = child
+	}
+	return target, nil
+}
+
+// optionalInterp returns the interpreter identified by args[idx], or
+// interp if there are not that many arguments.
+func (interp *Interp) optionalInterp(args []*Value, idx int) (*Interp, error) {
+	if idx >= len(args) {
+		return interp, nil
+	}
+	return interp.lookupInterp(args[idx])
+}
+
+// interp alias srcPath srcToken
+// interp alias srcPath srcToken {}
+// interp alias srcPath srcCmd targetPath targetCmd ?arg arg ...?
+//
+// Returns a list whose elements are the targetCmd and args
+// associated with the alias represented by srcToken, deletes the
+// alias when given an empty string, or creates an alias such that
+// whenever srcCmd is invoked in srcPath, targetCmd is invoked in
+// targetPath.
+func cmdInterpAlias(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 4 || (len(args) == 5 && args[4].String() != "") {
+		return nil, wrongNumArgs(args, 2, "childPath childCmd ?parentPath parentCmd? ?arg ...?")
+	}
+	source, err := interp.lookupInterp(args[2])
+	if err != nil {
+		return nil, err
+	}
+	token := commandName(args[3].String())
+	if len(args) <= 5 {
+		a, ok := source.aliases[token]
+		if !ok {
+			return nil, fmt.Errorf("alias \"%s\" not found", args[3])
+		}
+		if len(args) == 5 {
+			source.deleteAlias(a)
+			return nil, nil
+		}
+		return NewListValue(a.prefix), nil
+	}
+	target, err := interp.lookupInterp(args[4])
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]*Value, len(args)-5)
+	copy(prefix, args[5:])
+	if err := source.createAlias(token, target, prefix); err != nil {
+		return nil, err
+	}
+	return args[3], nil
+}
+
+// interp aliases ?path?
+//
+// This command returns a Tcl list of the tokens of all the source
+// commands for aliases defined in the interpreter identified by path.
+func cmdInterpAliases(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 3 {
+		return nil, wrongNumArgs(args, 2, "?path?")
+	}
+	target, err := interp.optionalInterp(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return NewStringValue(formatList(target.aliasNames())), nil
+}
+
+// interp children ?path?
+//
+// Returns a Tcl list of the names of all the child interpreters
+// associated with the interpreter identified by path.
+func cmdInterpChildren(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 3 {
+		return nil, wrongNumArgs(args, 2, "?path?")
+	}
+	target, err := interp.optionalInterp(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return NewStringValue(formatList(target.childNames())), nil
+}
+
+// interp create ?-safe? ?--? ?path?
+//
+// Creates a child interpreter identified by path and a new command,
+// called a child command. If path is omitted, Tcl creates a unique
+// name of the form interpx, where x is an integer.
+func cmdInterpCreate(interp *Interp, args []*Value) (*Value, error) {
+	safe := false
+	idx := 2
+options:
+	for ; idx < len(args); idx++ {
+		s := args[idx].String()
+		if len(s) == 0 || s[0] != '-' {
+			break
+		}
+		opt, err := lookupIndex([]string{"-safe", "--"}, "option", args[idx])
+		if err != nil {
+			return nil, err
+		}
+		switch opt {
+		case 0:
+			safe = true
+		case 1:
+			idx++
+			break options
+		}
+	}
+	if idx < len(args)-1 {
+		return nil, wrongNumArgs(args, 2, "?-safe? ?--? ?path?")
+	}
+	var names []string
+	if idx < len(args) {
+		elems, err := args[idx].List()
+		if err != nil {
+			return nil, err
+		}
+		names = valuesToStrings(elems)
+	}
+	parent := interp
+	if len(names) == 0 {
+		for i := 0; ; i++ {
+			name := fmt.Sprintf("interp%d", i)
+			if _, ok := interp.children[name]; !ok && !interp.CommandExists(name) {
+				names = []string{name}
+				break
+			}
+		}
+	} else {
+		var err error
+		parent, err = interp.lookupInterp(NewStringValue(formatList(names[:len(names)-1])))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, err := parent.CreateChild(names[len(names)-1], safe); err != nil {
+		return nil, err
+	}
+	return NewStringValue(formatList(names)), nil
+}
+
+// interp delete ?path ...?
+//
+// Deletes zero or more interpreters given by the optional path
+// arguments, and for each interpreter, it also deletes its children.
+func cmdInterpDelete(interp *Interp, args []*Value) (*Value, error) {
+	for _, path := range args[2:] {
+		target, err := interp.lookupInterp(path)
+		if err != nil {
+			return nil, err
+		}
+		if target == interp {
+			return nil, fmt.Errorf("cannot delete the current interpreter")
+		}
+		target.Delete()
+	}
+	return nil, nil
+}
+
+// interp eval path arg ?arg ...?
+//
+// This command concatenates all of the arg arguments in the same
+// fashion as the concat command, then evaluates the resulting string
+// as a Tcl script in the child interpreter identified by path.
+func cmdInterpEval(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 4 {
+		return nil, wrongNumArgs(args, 2, "path arg ?arg ...?")
+	}
+	target, err := interp.lookupInterp(args[2])
+	if err != nil {
+		return nil, err
+	}
+	return target.evalScript([]rune(concatValues(args[3:])))
+}
+
+// interp exists path
+//
+// Returns 1 if a child interpreter by the specified path exists in
+// this parent, 0 otherwise.
+func cmdInterpExists(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 3 {
+		return nil, wrongNumArgs(args, 2, "?path?")
+	}
+	if _, err := interp.optionalInterp(args, 2); err != nil {
+		return NewStringValue("0"), nil
+	}
+	return NewStringValue("1"), nil
+}
+
+// interp share srcPath channelId destPath
+//
+// Causes the IO channel identified by channelId to become shared
+// between the interpreter identified by srcPath and the interpreter
+// identified by destPath.
+func cmdInterpShare(interp *Interp, args []*Value) (*Value, error) {
+	return interpShare(interp, args, false)
+}
+
+// interp transfer srcPath channelId destPath
+//
+// Causes the IO channel identified by channelId to become available
+// in the interpreter identified by destPath and unavailable in the
+// interpreter identified by srcPath.
+func cmdInterpTransfer(interp *Interp, args []*Value) (*Value, error) {
+	return interpShare(interp, args, true)
+}
+
+func interpShare(interp *Interp, args []*Value, transfer bool) (*Value, error) {
+	if len(args) != 5 {
+		return nil, wrongNumArgs(args, 2, "srcPath channelId destPath")
+	}
+	source, err := interp.lookupInterp(args[2])
+	if err != nil {
+		return nil, err
+	}
+	dest, err := interp.lookupInterp(args[4])
+	if err != nil {
+		return nil, err
+	}
+	ch, err := source.lookupChannel(args[3].String())
+	if err != nil {
+		return nil, err
+	}
+	dest.registerChannel(ch)
+	if transfer && source != dest {
+		if err := source.unregisterChannel(ch); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// interp target path alias
+//
+// Returns a Tcl list describing the target interpreter for an alias.
+// The alias is specified with an interpreter path and source command
+// name.
+func cmdInterpTarget(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "path alias")
+	}
+	source, err := interp.lookupInterp(args[2])
+	if err != nil {
+		return nil, err
+	}
+	a, ok := source.aliases[commandName(args[3].String())]
+	if !ok {
+		return nil, fmt.Errorf("alias \"%s\" in path \"%s\" not found", args[3], args[2])
+	}
+	names, ok := a.target.path(interp)
+	if !ok {
+		return nil, fmt.Errorf("target interpreter for alias \"%s\" in path \"%s\" is not my descendant", args[3], args[2])
+	}
+	return NewStringValue(formatList(names)), nil
+}
+
+// interp expose path hiddenName ?exposedCmdName?
+//
+// Makes the hidden command hiddenName exposed, eventually bringing
+// it back under a new exposedCmdName name.
+func cmdInterpExpose(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 && len(args) != 5 {
+		return nil, wrongNumArgs(args, 2, "path hiddenCmdName ?cmdName?")
+	}
+	if interp.safe {
+		return nil, fmt.Errorf("permission denied: safe interpreter cannot expose commands")
+	}
+	target, err := interp.lookupInterp(args[2])
+	if err != nil {
+		return nil, err
+	}
+	hiddenName := args[3].String()
+	cmdName := hiddenName
+	if len(args) == 5 {
+		cmdName = args[4].String()
+	}
+	return nil, target.ExposeCommand(hiddenName, cmdName)
+}
+
+// interp hide path exposedCmdName ?hiddenCmdName?
+//
+// Makes the exposed command exposedCmdName hidden, renaming it to
+// the hidden command hiddenCmdName, or keeping the same name if
+// hiddenCmdName is not given.
+func cmdInterpHide(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 && len(args) != 5 {
+		return nil, wrongNumArgs(args, 2, "path cmdName ?hiddenCmdName?")
+	}
+	if interp.safe {
+		return nil, fmt.Errorf("permission denied: safe interpreter cannot hide commands")
+	}
+	target, err := interp.lookupInterp(args[2])
+	if err != nil {
+		return nil, err
+	}
+	cmdName := args[3].String()
+	hiddenName := commandName(cmdName)
+	if len(args) == 5 {
+		hiddenName = args[4].String()
+	}
+	return nil, target.HideCommand(cmdName, hiddenName)
+}
+
+// interp hidden path
+//
+// Returns a list of the names of all hidden commands in the
+// interpreter identified by path.
+func cmdInterpHidden(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 3 {
+		return nil, wrongNumArgs(args, 2, "?path?")
+	}
+	target, err := interp.optionalInterp(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	names := target.HiddenCommands()
+	return NewStringValue(formatList(names)), nil
+}
+
+// interp invokehidden path ?-option ...? hiddenCmdName ?arg ...?
+//
+// Invokes the hidden command hiddenCmdName with the arguments
+// supplied in the interpreter denoted by path. No substitutions or
+// evaluation are applied to the arguments.
+func cmdInterpInvokeHidden(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 4 {
+		return nil, wrongNumArgs(args, 2, "path ?-option value ...? cmd ?arg ..?")
+	}
+	if interp.safe {
+		return nil, fmt.Errorf("not allowed to invoke hidden commands from safe interpreter")
+	}
+	target, err := interp.lookupInterp(args[2])
+	if err != nil {
+		return nil, err
+	}
+	idx := 3
+options:
+	for ; idx < len(args); idx++ {
+		s := args[idx].String()
+		if len(s) == 0 || s[0] != '-' {
+			break
+		}
+		opt, err := lookupIndex([]string{"-global", "-namespace", "--"}, "option", args[idx])
+		if err != nil {
+			return nil, err
+		}
+		switch opt {
+		case 0:
+		case 1:
+			if idx+1 >= len(args) {
+				return nil, wrongNumArgs(args, 2, "path ?-option value ...? cmd ?arg ..?")
+			}
+			idx++
+			if ns := args[idx].String(); ns != "::" && ns != "" {
+				return nil, fmt.Errorf("namespace \"%s\" not found", ns)
+			}
+		case 2:
+			idx++
+			break options
+		}
+	}
+	if idx >= len(args) {
+		return nil, wrongNumArgs(args, 2, "path ?-option value ...? cmd ?arg ..?")
+	}
+	return target.invokeHidden(args[idx:])
+}
+
+// interp issafe ?path?
+//
+// Returns 1 if the interpreter identified by the specified path is
+// safe, 0 otherwise.
+func cmdInterpIsSafe(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 3 {
+		return nil, wrongNumArgs(args, 2, "?path?")
+	}
+	target, err := interp.optionalInterp(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	if target.safe {
+		return NewStringValue("1"), nil
+	}
+	return NewStringValue("0"), nil
+}
@@ -0,0 +1,709 @@
+package gotcl
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// indexArgs returns the indices named by the index arguments of a
+// command such as lindex or lset. A single argument is itself parsed
+// as a list of indices.
+func indexArgs(args []*Value) ([]*Value, error) {
+	if len(args) == 1 {
+		return args[0].List()
+	}
+	return args, nil
+}
+
+// indexOutOfRange returns the error for an index that does not name
+// an element of a list.
+func indexOutOfRange(index *Value) error {
+	return fmt.Errorf("index \"%s\" out of range", index.String())
+}
+
+// replaceElements returns a new slice in which the elements of elems
+// from first through last have been replaced by values. first and
+// last are clamped as lreplace does, so that values are inserted
+// before first when last is less than first.
+func replaceElements(elems []*Value, first, last int, values []*Value) []*Value {
+	if first < 0 {
+		first = 0
+	}
+	if first > len(elems) {
+		first = len(elems)
+	}
+	if last >= len(elems) {
+		last = len(elems) - 1
+	}
+	if last < first {
+		last = first - 1
+	}
+	out := make([]*Value, 0, len(elems)-(last-first+1)+len(values))
+	out = append(out, elems[:first]...)
+	out = append(out, values...)
+	out = append(out, elems[last+1:]...)
+	return out
+}
+
+// list ?arg arg ...?
+//
+// This command returns a list comprised of all the args, or an empty
+// string if no args are specified.
+func cmdList(interp *Interp, args []*Value) (*Value, error) {
+	elems := make([]*Value, len(args)-1)
+	copy(elems, args[1:])
+	return NewListValue(elems), nil
+}
+
+// llength list
+//
+// Treats list as a list and returns a decimal string giving the
+// number of elements in it.
+func cmdLlength(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 2 {
+		return nil, wrongNumArgs(args, 1, "list")
+	}
+	elems, err := args[1].List()
+	if err != nil {
+		return nil, err
+	}
+	return NewIntValue(int64(len(elems))), nil
+}
+
+// lindex list ?index ...?
+//
+// The lindex command accepts a parameter, list, which it treats as a
+// Tcl list. It also accepts zero or more indices into the list. The
+// indices may be presented either consecutively on the command line,
+// or grouped in a Tcl list and presented as a single argument. If no
+// indices are presented, the command takes the form lindex list and
+// simply returns the value of the list parameter. If an index is out
+// of range, an empty string is returned.
+func cmdLindex(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "list ?index ...?")
+	}
+	if len(args) == 2 {
+		return args[1], nil
+	}
+	indices, err := indexArgs(args[2:])
+	if err != nil {
+		return nil, err
+	}
+	v := args[1]
+	for _, index := range indices {
+		elems, err := v.List()
+		if err != nil {
+			return nil, err
+		}
+		i, err := getIndex(index, len(elems)-1)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= len(elems) {
+			return emptyValue(), nil
+		}
+		v = elems[i]
+	}
+	return v, nil
+}
+
+// lsetPath returns a copy of list in which the element named by
+// indices has been replaced by v. An index equal to the length of a
+// list appends a new element to it.
+func lsetPath(list *Value, indices []*Value, v *Value) (*Value, error) {
+	if len(indices) == 0 {
+		return v, nil
+	}
+	elems, err := list.List()
+	if err != nil {
+		return nil, err
+	}
+	i, err := getIndex(indices[0], len(elems)-1)
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 || i > len(elems) {
+		return nil, indexOutOfRange(indices[0])
+	}
+	out := make([]*Value, len(elems), len(elems)+1)
+	copy(out, elems)
+	if i == len(elems) {
+		out = append(out, emptyValue())
+	}
+	if out[i], err = lsetPath(out[i], indices[1:], v); err != nil {
+		return nil, err
+	}
+	return NewListValue(out), nil
+}
+
+// lset varName ?index ...? newValue
+//
+// The lset command accepts a parameter, varName, which it interprets
+// as the name of a variable containing a Tcl list. It also accepts
+// zero or more indices into the list. Finally, it accepts a new
+// value for an element of varName. The indices may be presented
+// either consecutively on the command line, or grouped in a Tcl list
+// and presented as a single argument. If an index is equal to the
+// length of the list, the new element is appended to the list.
+func cmdLset(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 1, "listVar ?index? ?index ...? value")
+	}
+	list, err := interp.getVar(args[1].String())
+	if err != nil {
+		return nil, err
+	}
+	indices, err := indexArgs(args[2 : len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+	v, err := lsetPath(list, indices, args[len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+	return interp.setVar(args[1].String(), v)
+}
+
+// lrange list first last
+//
+// List must be a valid Tcl list. This command will return a new list
+// consisting of elements first through last, inclusive.
+func cmdLrange(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 {
+		return nil, wrongNumArgs(args, 1, "list first last")
+	}
+	elems, err := args[1].List()
+	if err != nil {
+		return nil, err
+	}
+	first, err := getIndex(args[2], len(elems)-1)
+	if err != nil {
+		return nil, err
+	}
+	last, err := getIndex(args[3], len(elems)-1)
+	if err != nil {
+		return nil, err
+	}
+	if first < 0 {
+		first = 0
+	}
+	if last >= len(elems) {
+		last = len(elems) - 1
+	}
+	if first > last {
+		return emptyValue(), nil
+	}
+	out := make([]*Value, last-first+1)
+	copy(out, elems[first:last+1])
+	return NewListValue(out), nil
+}
+
+// linsert list index ?element element ...?
+//
+// This command produces a new list from list by inserting all of the
+// element arguments just before the index'th element of list. If
+// index is end, the new elements are added after the last element.
+func cmdLinsert(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 1, "list index ?element ...?")
+	}
+	elems, err := args[1].List()
+	if err != nil {
+		return nil, err
+	}
+	i, err := getIndex(args[2], len(elems))
+	if err != nil {
+		return nil, err
+	}
+	return NewListValue(replaceElements(elems, i, i-1, args[3:])), nil
+}
+
+// lreplace list first last ?element element ...?
+//
+// lreplace returns a new list formed by replacing zero or more
+// elements of list with the element arguments. If last is less than
+// first, then any specified elements will be inserted into the list
+// before the element specified by first with no elements being
+// deleted.
+func cmdLreplace(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 4 {
+		return nil, wrongNumArgs(args, 1, "list first last ?element ...?")
+	}
+	elems, err := args[1].List()
+	if err != nil {
+		return nil, err
+	}
+	first, err := getIndex(args[2], len(elems)-1)
+	if err != nil {
+		return nil, err
+	}
+	last, err := getIndex(args[3], len(elems)-1)
+	if err != nil {
+		return nil, err
+	}
+	return NewListValue(replaceElements(elems, first, last, args[4:])), nil
+}
+
+// ledit listVar first last ?value value ...?
+//
+// Replace the elements of the list value stored in the variable
+// listVar from first through last with the values given, as lreplace
+// does, and store the result back in listVar.
+func cmdLedit(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 4 {
+		return nil, wrongNumArgs(args, 1, "listVar first last ?element ...?")
+	}
+	list, err := interp.getVar(args[1].String())
+	if err != nil {
+		return nil, err
+	}
+	elems, err := list.List()
+	if err != nil {
+		return nil, err
+	}
+	first, err := getIndex(args[2], len(elems)-1)
+	if err != nil {
+		return nil, err
+	}
+	last, err := getIndex(args[3], len(elems)-1)
+	if err != nil {
+		return nil, err
+	}
+	return interp.setVar(args[1].String(), NewListValue(replaceElements(elems, first, last, args[4:])))
+}
+
+// lappend varName ?value value value ...?
+//
+// This command treats the variable given by varName as a list and
+// appends each of the value arguments to that list as a separate
+// element. If varName does not exist, it is created as a list with
+// elements given by the value arguments.
+func cmdLappend(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "varName ?value ...?")
+	}
+	var elems []*Value
+	if list, err := interp.getVar(args[1].String()); err == nil {
+		if elems, err = list.List(); err != nil {
+			return nil, err
+		}
+	}
+	out := make([]*Value, 0, len(elems)+len(args)-2)
+	out = append(out, elems...)
+	out = append(out, args[2:]...)
+	return interp.setVar(args[1].String(), NewListValue(out))
+}
+
+// lassign list ?varName ...?
+//
+// This command treats the value list as a list and assigns successive
+// elements from that list to the variables given by the varName
+// arguments in order. If there are more variable names than list
+// elements, the remaining variables are set to the empty string. If
+// there are more list elements than variables, a list of unassigned
+// elements is returned.
+func cmdLassign(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "list ?varName ...?")
+	}
+	elems, err := args[1].List()
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range args[2:] {
+		v := emptyValue()
+		if i < len(elems) {
+			v = elems[i]
+		}
+		if _, err := interp.setVar(name.String(), v); err != nil {
+			return nil, err
+		}
+	}
+	if len(args)-2 >= len(elems) {
+		return emptyValue(), nil
+	}
+	out := make([]*Value, len(elems)-(len(args)-2))
+	copy(out, elems[len(args)-2:])
+	return NewListValue(out), nil
+}
+
+// lrepeat count ?element ...?
+//
+// The lrepeat command creates a list of size count * number of
+// elements by repeating count times the sequence of elements
+// element ....
+func cmdLrepeat(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "count ?value ...?")
+	}
+	count, err := getInt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 {
+		return nil, fmt.Errorf("bad count \"%s\": must be integer >= 0", args[1].String())
+	}
+	values := args[2:]
+	if len(values) > 0 && count > math.MaxInt32/len(values) {
+		return nil, fmt.Errorf("max length of a Tcl list exceeded")
+	}
+	out := make([]*Value, 0, count*len(values))
+	for i := 0; i < count; i++ {
+		out = append(out, values...)
+	}
+	return NewListValue(out), nil
+}
+
+// lreverse list
+//
+// This command returns a list that has the same elements as its input
+// argument, list, except in reverse order.
+func cmdLreverse(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 2 {
+		return nil, wrongNumArgs(args, 1, "list")
+	}
+	elems, err := args[1].List()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Value, len(elems))
+	for i, elem := range elems {
+		out[len(elems)-1-i] = elem
+	}
+	return NewListValue(out), nil
+}
+
+// lpopPath returns a copy of list with the element named by indices
+// removed, along with the removed element.
+func lpopPath(list *Value, indices []*Value) (*Value, *Value, error) {
+	elems, err := list.List()
+	if err != nil {
+		return nil, nil, err
+	}
+	i, err := getIndex(indices[0], len(elems)-1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if i < 0 || i >= len(elems) {
+		return nil, nil, indexOutOfRange(indices[0])
+	}
+	if len(indices) == 1 {
+		return NewListValue(replaceElements(elems, i, i, nil)), elems[i], nil
+	}
+	elem, popped, err := lpopPath(elems[i], indices[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+	out := make([]*Value, len(elems))
+	copy(out, elems)
+	out[i] = elem
+	return NewListValue(out), popped, nil
+}
+
+// lpop varName ?index ...?
+//
+// The lpop command accepts a parameter, varName, which it interprets
+// as the name of a variable containing a Tcl list. It also accepts
+// one or more indices into the list. If no indices are presented,
+// the last element of the list is removed. The removed element is
+// returned.
+func cmdLpop(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "listvar ?index?")
+	}
+	list, err := interp.getVar(args[1].String())
+	if err != nil {
+		return nil, err
+	}
+	indices := []*Value{NewStringValue("end")}
+	if len(args) > 2 {
+		if indices, err = indexArgs(args[2:]); err != nil {
+			return nil, err
+		}
+		if len(indices) == 0 {
+			_, err := interp.setVar(args[1].String(), emptyValue())
+			return list, err
+		}
+	}
+	list, popped, err := lpopPath(list, indices)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := interp.setVar(args[1].String(), list); err != nil {
+		return nil, err
+	}
+	return popped, nil
+}
+
+// concat ?arg arg ...?
+//
+// This command joins each of its arguments together with spaces after
+// trimming leading and trailing white-space from each of them.
+func cmdConcat(interp *Interp, args []*Value) (*Value, error) {
+	return NewStringValue(concatValues(args[1:])), nil
+}
+
+// join list ?joinString?
+//
+// The list argument must be a valid Tcl list. This command returns
+// the string formed by joining all of the elements of list together
+// with joinString separating each adjacent pair of elements. The
+// joinString argument defaults to a space character.
+func cmdJoin(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, wrongNumArgs(args, 1, "list ?joinString?")
+	}
+	elems, err := args[1].List()
+	if err != nil {
+		return nil, err
+	}
+	sep := " "
+	if len(args) == 3 {
+		sep = args[2].String()
+	}
+	return NewStringValue(strings.Join(valuesToStrings(elems), sep)), nil
+}
+
+// split string ?splitChars?
+//
+// Returns a list created by splitting string at each character that
+// is in the splitChars argument. If splitChars is an empty string
+// then each character of string becomes a separate element of the
+// result list. SplitChars defaults to the standard white-space
+// characters.
+func cmdSplit(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, wrongNumArgs(args, 1, "string ?splitChars?")
+	}
+	r := args[1].Runes()
+	chars := []rune(" \t\n\r")
+	if len(args) == 3 {
+		chars = args[2].Runes()
+	}
+	var out []*Value
+	if len(r) == 0 {
+		return NewListValue(out), nil
+	}
+	if len(chars) == 0 {
+		for _, c := range r {
+			out = append(out, newRunesValue([]rune{c}))
+		}
+		return NewListValue(out), nil
+	}
+	start := 0
+	for i, c := range r {
+		for _, sc := range chars {
+			if c == sc {
+				out = append(out, newRunesValue(r[start:i]))
+				start = i + 1
+				break
+			}
+		}
+	}
+	out = append(out, newRunesValue(r[start:]))
+	return NewListValue(out), nil
+}
+
+// lmap varname list body
+// lmap varlist1 list1 ?varlist2 list2 ...? body
+//
+// The lmap command implements a loop where the loop variable(s) take
+// on values from one or more lists, and the loop returns a list of
+// results collected from each iteration. The result of each
+// evaluation of body is appended to the result unless the body
+// completes with continue; break ends the loop early.
+func cmdLmap(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 4 || len(args)%2 != 0 {
+		return nil, wrongNumArgs(args, 1, "varList list ?varList list ...? command")
+	}
+	n := (len(args) - 2) / 2
+	varLists := make([][]*Value, n)
+	lists := make([][]*Value, n)
+	iterations := 0
+	for i := 0; i < n; i++ {
+		var err error
+		if varLists[i], err = args[1+2*i].List(); err != nil {
+			return nil, err
+		}
+		if len(varLists[i]) == 0 {
+			return nil, fmt.Errorf("lmap varlist is empty")
+		}
+		if lists[i], err = args[2+2*i].List(); err != nil {
+			return nil, err
+		}
+		count := (len(lists[i]) + len(varLists[i]) - 1) / len(varLists[i])
+		if count > iterations {
+			iterations = count
+		}
+	}
+	body := args[len(args)-1].Runes()
+	var out []*Value
+	for iter := 0; iter < iterations; iter++ {
+		for i, vars := range varLists {
+			for j, name := range vars {
+				v := emptyValue()
+				if k := iter*len(vars) + j; k < len(lists[i]) {
+					v = lists[i][k]
+				}
+				if _, err := interp.setVar(name.String(), v); err != nil {
+					return nil, err
+				}
+			}
+		}
+		result, err := interp.evalScript(body)
+		switch errorCode(err) {
+		case CodeOK:
+			out = append(out, result)
+		case CodeContinue:
+		case CodeBreak:
+			return NewListValue(out), nil
+		default:
+			return nil, err
+		}
+	}
+	return NewListValue(out), nil
+}
+
+var lseqOperations = []string{"..", "to", "count", "by"}
+
+// lseqNumber parses v as a number for lseq, reporting whether it is
+// an integer.
+func lseqNumber(v *Value) (int64, float64, bool, error) {
+	if i, err := v.Int(); err == nil {
+		return i, float64(i), true, nil
+	}
+	f, err := v.Double()
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("expected integer but got \"%s\"", v.String())
+	}
+	return 0, f, false, nil
+}
+
+// decimalPlaces returns the number of digits after the decimal point
+// in the string form of v, for rounding lseq elements.
+func decimalPlaces(v *Value) int {
+	s := strings.ToLower(v.String())
+	if strings.ContainsAny(s, "en") {
+		return -1
+	}
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return len(strings.TrimSpace(s[i+1:]))
+	}
+	return 0
+}
+
+// lseq start ?(..|to)? end ??by? step?
+// lseq start count count ??by? step?
+// lseq count ?by step?
+//
+// The lseq command creates a sequence of numeric values using the
+// given parameters start, end, and step. The operation argument ".."
+// or "to" defines the range. The "count" option is used to define a
+// count of the number of elements in the list. A short form use of
+// the command, with a single count value, will create a range from 0
+// to count-1. If step is not given, it defaults to 1, or -1 when end
+// is less than start.
+func cmdLseq(interp *Interp, args []*Value) (*Value, error) {
+	usage := wrongNumArgs(args, 1, "n ??op? n ??by? n??")
+	if len(args) < 2 || len(args) > 6 {
+		return nil, usage
+	}
+
+	// Classify the arguments as numbers or operations, producing a
+	// form such as "n..n" or "ncountnbyn".
+	var form strings.Builder
+	var numbers []*Value
+	for _, arg := range args[1:] {
+		if op, err := lookupIndex(lseqOperations, "operation", arg); err == nil {
+			form.WriteString(lseqOperations[op])
+			continue
+		}
+		if _, _, _, err := lseqNumber(arg); err != nil {
+			return nil, err
+		}
+		form.WriteString("n")
+		numbers = append(numbers, arg)
+	}
+	var start, end, step, count *Value
+	switch form.String() {
+	case "n":
+		count = numbers[0]
+	case "nbyn":
+		count, step = numbers[0], numbers[1]
+	case "nn", "n..n", "nton":
+		start, end = numbers[0], numbers[1]
+	case "nnn", "n..nn", "ntonn", "nnbyn", "n..nbyn", "ntonbyn":
+		start, end, step = numbers[0], numbers[1], numbers[2]
+	case "ncountn":
+		start, count = numbers[0], numbers[1]
+	case "ncountnn", "ncountnbyn":
+		start, count, step = numbers[0], numbers[1], numbers[2]
+	default:
+		return nil, usage
+	}
+
+	if start == nil {
+		start = NewIntValue(0)
+	}
+	s, sf, sInt, _ := lseqNumber(start)
+	st, stf, stInt := int64(1), 1.0, true
+	if step != nil {
+		st, stf, stInt, _ = lseqNumber(step)
+	}
+	var n int64
+	isInt := sInt && stInt
+	if count != nil {
+		c, err := count.Int()
+		if err != nil {
+			return nil, err
+		}
+		n = c
+	} else {
+		e, ef, eInt, _ := lseqNumber(end)
+		isInt = isInt && eInt
+		if step == nil && ef < sf {
+			st, stf = -1, -1
+		}
+		switch {
+		case stf == 0 || (stf > 0 && ef < sf) || (stf < 0 && ef > sf):
+			n = 0
+		case isInt:
+			n = (e-s)/st + 1
+		default:
+			n = int64(math.Floor((ef-sf)/stf+1e-9)) + 1
+		}
+	}
+	if n <= 0 {
+		return emptyValue(), nil
+	}
+	if n > math.MaxInt32 {
+		return nil, fmt.Errorf("max length of a Tcl list exceeded")
+	}
+	out := make([]*Value, n)
+	if isInt {
+		for i := range out {
+			out[i] = NewIntValue(s + int64(i)*st)
+		}
+		return NewListValue(out), nil
+	}
+
+	// Round each element to the precision of the arguments so that
+	// accumulated floating-point error does not show in the result.
+	places := decimalPlaces(start)
+	if step != nil {
+		if p := decimalPlaces(step); p < 0 || p > places {
+			places = p
+		}
+	}
+	scale := math.Pow(10, float64(places))
+	for i := range out {
+		f := sf + float64(i)*stf
+		if places >= 0 && places < 16 {
+			f = math.Round(f*scale) / scale
+		}
+		out[i] = NewDoubleValue(f)
+	}
+	return NewListValue(out), nil
+}
@@ -0,0 +1,157 @@
+package gotcl
+
+import (
+	"testing"
+)
+
+func TestListCommands(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `list a {b c} {} "d e"`, result: "a {b c} {} {d e}"},
+		{script: `list {#a} b`, result: "{#a} b"},
+		{script: `llength {a {b c} d}`, result: "3"},
+		{script: `llength {a {b}c}`, err: `list element in braces followed by "c" instead of space`},
+		{script: `lindex {a {b c} d} 1`, result: "b c"},
+		{script: `lindex {a {b c} d} 1 1`, result: "c"},
+		{script: `lindex {a {b c} d} {1 0}`, result: "b"},
+		{script: `lindex {a b c} end-1`, result: "b"},
+		{script: `lindex {a b c} 5`, result: ""},
+		{script: `lindex {a b c}`, result: "a b c"},
+		{script: `lindex {a b c} {}`, result: "a b c"},
+		{script: `set l {a {b c} d}`, result: "a {b c} d"},
+		{script: `lset l 1 0 x`, result: "a {x c} d"},
+		{script: `lset l end y`, result: "a {x c} y"},
+		{script: `lset l 3 z`, result: "a {x c} y z"},
+		{script: `lset l 9 z`, err: `index "9" out of range`},
+		{script: `lset l {} new`, result: "new"},
+		{script: `lrange {a b c d e} 1 3`, result: "b c d"},
+		{script: `lrange {a b c d e} end-1 end`, result: "d e"},
+		{script: `lrange {a b c} 2 1`, result: ""},
+		{script: `linsert {a b c} 1 x y`, result: "a x y b c"},
+		{script: `linsert {a b c} end x`, result: "a b c x"},
+		{script: `linsert {a b c} end-1 x`, result: "a b x c"},
+		{script: `lreplace {a b c d} 1 2 x`, result: "a x d"},
+		{script: `lreplace {a b c d} 1 0 x`, result: "a x b c d"},
+		{script: `lreplace {a b c d} 1 end`, result: "a"},
+		{script: `lreplace {a b c} 10 10 x`, result: "a b c x"},
+		{script: `unset -nocomplain v; lappend v a b`, result: "a b"},
+		{script: `lappend v {c d}`, result: "a b {c d}"},
+		{script: `ledit v 0 1 x y z`, result: "x y z {c d}"},
+		{script: `lassign {1 2 3 4} a b`, result: "3 4"},
+		{script: `list $a $b`, result: "1 2"},
+		{script: `lassign {1} a b; list $a $b`, result: "1 {}"},
+		{script: `lrepeat 3 a b`, result: "a b a b a b"},
+		{script: `lrepeat 0 a`, result: ""},
+		{script: `lrepeat -1 a`, err: `bad count "-1": must be integer >= 0`},
+		{script: `lreverse {a b {c d}}`, result: "{c d} b a"},
+		{script: `concat " a b " {c d} {} e`, result: "a b c d e"},
+		{script: `join {a {b c} d} ,`, result: "a,b c,d"},
+		{script: `join {a b c}`, result: "a b c"},
+		{script: `split a,b,,c ,`, result: "a b {} c"},
+		{script: `split "a b\tc"`, result: "a b c"},
+		{script: `split abc {}`, result: "a b c"},
+		{script: `split {} ,`, result: ""},
+		{script: `set p {a {b c} d}; lpop p`, result: "d"},
+		{script: `set p`, result: "a {b c}"},
+		{script: `lpop p 1 0`, result: "b"},
+		{script: `set p`, result: "a c"},
+		{script: `lpop p 5`, err: `index "5" out of range`},
+		{script: `list {*}{a b} c {*}{} {*}"d {e f}"`, result: "a b c d {e f}"},
+		{script: `llength [list {*}{}]`, result: "0"},
+	})
+}
+
+func TestLmapLseq(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `lmap x {a b c} {string toupper $x}`, result: "A B C"},
+		{script: `lmap {x y} {a b c} {list $x $y}`, result: "{a b} {c {}}"},
+		{script: `lmap x {a b} y {1 2 3} {string cat $x $y}`, result: "a1 b2 3"},
+		{script: `lmap x {a b c d} {string equal $x c}`, result: "0 0 1 0"},
+		{script: `lmap x {} {}`, result: ""},
+		{script: `lmap {} {a} {}`, err: `lmap varlist is empty`},
+		{script: `lmap x {a b c d} {continue}`, result: ""},
+		{script: `lmap x {a b c d} {break}`, result: ""},
+		{script: `lseq 5`, result: "0 1 2 3 4"},
+		{script: `lseq 1 5`, result: "1 2 3 4 5"},
+		{script: `lseq 5 1`, result: "5 4 3 2 1"},
+		{script: `lseq 1 10 3`, result: "1 4 7 10"},
+		{script: `lseq 1 to 10 by 4`, result: "1 5 9"},
+		{script: `lseq 1 .. 3`, result: "1 2 3"},
+		{script: `lseq 10 count 3`, result: "10 11 12"},
+		{script: `lseq 0 count 3 by 5`, result: "0 5 10"},
+		{script: `lseq 5 by 2`, result: "0 2 4 6 8"},
+		{script: `lseq 1 5 -1`, result: ""},
+		{script: `lseq 0 1 0.25`, result: "0.0 0.25 0.5 0.75 1.0"},
+		{script: `lseq 0 0.5 0.1`, result: "0.0 0.1 0.2 0.3 0.4 0.5"},
+		{script: `lseq 1 x`, err: `expected integer but got "x"`},
+		{script: `lseq`, err: `wrong # args: should be "lseq n ??op? n ??by? n??"`},
+	})
+}
+
+func TestLsort(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `lsort {c a B b}`, result: "B a b c"},
+		{script: `lsort -nocase {c a B}`, result: "a B c"},
+		{script: `lsort -decreasing {a c b}`, result: "c b a"},
+		{script: `lsort -integer {10 9 0x10 -1}`, result: "-1 9 10 0x10"},
+		{script: `lsort -integer {1 a}`, err: `expected integer but got "a"`},
+		{script: `lsort -real {1.5 1e1 -2}`, result: "-2 1.5 1e1"},
+		{script: `lsort -dictionary {x10 x9 X1 x1 a}`, result: "a X1 x1 x9 x10"},
+		{script: `lsort -dictionary {a01 a1 a001}`, result: "a1 a01 a001"},
+		{script: `lsort -unique {b a b c a}`, result: "a b c"},
+		{script: `lsort -indices {c a b}`, result: "1 2 0"},
+		{script: `lsort -index 1 {{a 3} {b 1} {c 2}}`, result: "{b 1} {c 2} {a 3}"},
+		{script: `lsort -index end -integer {{a 10} {b 9}}`, result: "{b 9} {a 10}"},
+		{script: `lsort -index 1 {{a 3} b}`, err: `element 1 missing from sublist "b"`},
+		{script: `lsort -stride 2 {c 1 a 2 b 3}`, result: "a 2 b 3 c 1"},
+		{script: `lsort -stride 2 -index 1 -decreasing {c 1 a 2 b 3}`, result: "b 3 a 2 c 1"},
+		{script: `lsort -stride 2 -indices {c 1 a 2}`, result: "2 3 0 1"},
+		{script: `lsort -stride 2 {a b c}`, err: `list size must be a multiple of the stride length`},
+		{script: `lsort -stride 2 -index 2 {a b c d}`, err: `when used with "-stride", the leading "-index" value must be within the group`},
+		{script: `lsort -stride 1 {a}`, err: `stride length must be at least 2`},
+		{script: `lsort -command {string compare} {c a b}`, result: "a b c"},
+		{script: `lsort -command {string cat} {c a b}`, err: `-compare command returned non-integer result`},
+		{script: `lsort -command {string bogus} {c a b}`, err: `unknown or ambiguous subcommand "bogus": must be cat, compare, equal, first, index, is, last, length, map, match, range, repeat, replace, reverse, tolower, totitle, toupper, trim, trimleft, trimright, wordend, or wordstart`},
+		{script: `lsort -command {a b}`, err: `"-command" option must be followed by comparison command`},
+		{script: `lsort -bogus {a b}`, err: `bad option "-bogus": must be -ascii, -command, -decreasing, -dictionary, -increasing, -index, -indices, -integer, -nocase, -real, -stride, or -unique`},
+		{script: `lsort`, err: `wrong # args: should be "lsort ?-option value ...? list"`},
+	})
+}
+
+func TestLsearch(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `lsearch {a b c} b`, result: "1"},
+		{script: `lsearch {a b c} x`, result: "-1"},
+		{script: `lsearch {apple banana cherry} *an*`, result: "1"},
+		{script: `lsearch -exact {a* b} a*`, result: "0"},
+		{script: `lsearch -all {a b a c} a`, result: "0 2"},
+		{script: `lsearch -all -inline {a1 b a2} a*`, result: "a1 a2"},
+		{script: `lsearch -all -not {a b a c} a`, result: "1 3"},
+		{script: `lsearch -inline {a b c} x`, result: ""},
+		{script: `lsearch -start 1 {a b a} a`, result: "2"},
+		{script: `lsearch -nocase {A B} b`, result: "1"},
+		{script: `lsearch -exact -integer {1 2 0x3} 3`, result: "2"},
+		{script: `lsearch -exact -real {1 2.0 3} 2`, result: "1"},
+		{script: `lsearch -regexp {foo bar baz} {^ba}`, result: "1"},
+		{script: `lsearch -regexp {foo} {(}`, err: `couldn't compile regular expression pattern: parentheses () not balanced`},
+		{script: `lsearch -sorted {a b b c d} b`, result: "1"},
+		{script: `lsearch -sorted -integer {1 3 5 7} 5`, result: "2"},
+		{script: `lsearch -sorted -decreasing {d c b a} c`, result: "1"},
+		{script: `lsearch -bisect -integer {1 3 5 7} 4`, result: "1"},
+		{script: `lsearch -bisect -integer {1 3 5 7} 0`, result: "-1"},
+		{script: `lsearch -bisect -all {a b} a`, err: `-bisect is not compatible with -all or -not`},
+		{script: `lsearch -index 1 {{a x} {b y}} y`, result: "1"},
+		{script: `lsearch -index 1 -inline {{a x} {b y}} y`, result: "b y"},
+		{script: `lsearch -index 1 -subindices {{a x} {b y}} y`, result: "1 1"},
+		{script: `lsearch -index 1 -subindices -inline {{a x} {b y}} y`, result: "y"},
+		{script: `lsearch -subindices {a} a`, err: `-subindices cannot be used without -index option`},
+		{script: `lsearch -stride 2 {a 1 b 2} b`, result: "2"},
+		{script: `lsearch -stride 2 -index 1 -inline {a 1 b 2} 2`, result: "b 2"},
+		{script: `lsearch -stride 2 -index 1 -all -inline {a 1 b 2 c 1} 1`, result: "a 1 c 1"},
+		{script: `lsearch -bogus {a} a`, err: `bad option "-bogus": must be -all, -ascii, -bisect, -decreasing, -dictionary, -exact, -glob, -increasing, -index, -inline, -integer, -nocase, -not, -real, -regexp, -sorted, -start, -stride, or -subindices`},
+		{script: `lsearch a`, err: `wrong # args: should be "lsearch ?-option value ...? list pattern"`},
+	})
+}
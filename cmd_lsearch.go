@@ -0,0 +1,295 @@
+package gotcl
+
+import (
+	"fmt"
+	"math/big"
+)
+
+var lsearchOptions = []string{
+	"-all", "-ascii", "-bisect", "-decreasing", "-dictionary", "-exact",
+	"-glob", "-increasing", "-index", "-inline", "-integer", "-nocase",
+	"-not", "-real", "-regexp", "-sorted", "-start", "-stride",
+	"-subindices",
+}
+
+const (
+	searchExact = iota
+	searchGlob
+	searchRegexp
+	searchSorted
+)
+
+// lsearch ?options? list pattern
+//
+// This command searches the elements of list to see if one of them
+// matches pattern. If so, the command returns the index of the first
+// matching element (unless the options -all or -inline are
+// specified.) If not, the command returns -1. The -exact, -glob,
+// -regexp, -sorted and -bisect options select the matching style;
+// the -ascii, -dictionary, -integer, -real, -nocase, -increasing and
+// -decreasing options describe how elements are compared; and -all,
+// -inline, -not, -start, -index, -stride and -subindices modify
+// which elements are searched and what is returned.
+func cmdLsearch(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 1, "?-option value ...? list pattern")
+	}
+	c := &listComparer{interp: interp}
+	mode := searchGlob
+	all, bisect, decreasing, inline, negate, subindices := false, false, false, false, false, false
+	var start *Value
+	var searchIndex []*Value
+	stride := 1
+	for i := 1; i < len(args)-2; i++ {
+		opt, err := lookupIndex(lsearchOptions, "option", args[i])
+		if err != nil {
+			return nil, err
+		}
+		switch lsearchOptions[opt] {
+		case "-all":
+			all = true
+		case "-ascii":
+			c.mode = sortASCII
+		case "-bisect":
+			mode, bisect = searchSorted, true
+		case "-decreasing":
+			decreasing = true
+		case "-dictionary":
+			c.mode = sortDictionary
+		case "-exact":
+			mode = searchExact
+		case "-glob":
+			mode = searchGlob
+		case "-increasing":
+			decreasing = false
+		case "-index":
+			if i == len(args)-3 {
+				return nil, fmt.Errorf("\"-index\" option must be followed by list index")
+			}
+			i++
+			if searchIndex, err = args[i].List(); err != nil {
+				return nil, err
+			}
+			for _, index := range searchIndex {
+				if _, err := getIndex(index, 0); err != nil {
+					return nil, err
+				}
+			}
+		case "-inline":
+			inline = true
+		case "-integer":
+			c.mode = sortInteger
+		case "-nocase":
+			c.nocase = true
+		case "-not":
+			negate = true
+		case "-real":
+			c.mode = sortReal
+		case "-regexp":
+			mode = searchRegexp
+		case "-sorted":
+			mode = searchSorted
+		case "-start":
+			if i == len(args)-3 {
+				return nil, fmt.Errorf("missing starting index")
+			}
+			i++
+			if _, err := getIndex(args[i], 0); err != nil {
+				return nil, err
+			}
+			start = args[i]
+		case "-stride":
+			if i == len(args)-3 {
+				return nil, fmt.Errorf("\"-stride\" option must be followed by stride length")
+			}
+			i++
+			if stride, err = getInt(args[i]); err != nil {
+				return nil, err
+			}
+			if stride < 1 {
+				return nil, fmt.Errorf("stride length must be at least 1")
+			}
+		case "-subindices":
+			subindices = true
+		}
+	}
+	if bisect && (all || negate) {
+		return nil, fmt.Errorf("-bisect is not compatible with -all or -not")
+	}
+	if subindices && len(searchIndex) == 0 {
+		return nil, fmt.Errorf("-subindices cannot be used without -index option")
+	}
+	elems, err := args[len(args)-2].List()
+	if err != nil {
+		return nil, err
+	}
+	pattern := args[len(args)-1]
+	groups, err := groupElements(elems, stride)
+	if err != nil {
+		return nil, err
+	}
+	offset := 0
+	if stride > 1 && len(searchIndex) > 0 {
+		if offset, err = getIndex(searchIndex[0], stride-1); err != nil {
+			return nil, err
+		}
+		if offset < 0 || offset >= stride {
+			return nil, fmt.Errorf("index value larger than stride length")
+		}
+		searchIndex = searchIndex[1:]
+	}
+	first := 0
+	if start != nil {
+		if first, err = getIndex(start, len(elems)-1); err != nil {
+			return nil, err
+		}
+		if first < 0 {
+			first = 0
+		}
+		first = (first + stride - 1) / stride
+	}
+
+	// Convert the pattern once for numeric comparisons.
+	var bigPattern *big.Int
+	var realPattern float64
+	if mode == searchExact || mode == searchSorted {
+		switch c.mode {
+		case sortInteger:
+			if bigPattern, err = pattern.BigInt(); err != nil {
+				return nil, err
+			}
+		case sortReal:
+			if realPattern, err = pattern.Double(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	var re *tclRegexp
+	if mode == searchRegexp {
+		var flags regexpFlags
+		if c.nocase {
+			flags |= reNocase
+		}
+		if re, err = pattern.regexp(flags); err != nil {
+			return nil, err
+		}
+	}
+	key := func(i int) (*Value, error) {
+		return selectFromSublist(groups[i][offset], searchIndex)
+	}
+
+	// result returns what is reported for a match of group i.
+	result := func(i int) (*Value, error) {
+		if inline {
+			if subindices {
+				return key(i)
+			}
+			if stride > 1 {
+				return NewListValue(groups[i]), nil
+			}
+			return groups[i][0], nil
+		}
+		if subindices {
+			path := []*Value{NewIntValue(int64(i*stride + offset))}
+			return NewListValue(append(path, searchIndex...)), nil
+		}
+		return NewIntValue(int64(i * stride)), nil
+	}
+	notFound := func() (*Value, error) {
+		if inline {
+			return emptyValue(), nil
+		}
+		return NewIntValue(-1), nil
+	}
+
+	if mode == searchSorted && !all && !negate {
+		// Binary search, finding the leftmost match, or with -bisect
+		// the last element not after pattern.
+		found := -1
+		lo, hi := first, len(groups)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			k, err := key(mid)
+			if err != nil {
+				return nil, err
+			}
+			n, err := c.compare(k, pattern)
+			if err != nil {
+				return nil, err
+			}
+			if decreasing {
+				n = -n
+			}
+			switch {
+			case bisect && n <= 0:
+				found, lo = mid, mid+1
+			case bisect:
+				hi = mid
+			case n == 0:
+				found, hi = mid, mid
+			case n < 0:
+				lo = mid + 1
+			default:
+				hi = mid
+			}
+		}
+		if found < 0 {
+			return notFound()
+		}
+		return result(found)
+	}
+
+	var matches []*Value
+	for i := first; i < len(groups); i++ {
+		k, err := key(i)
+		if err != nil {
+			return nil, err
+		}
+		var match bool
+		switch mode {
+		case searchGlob:
+			match = stringMatch(pattern.Runes(), k.Runes(), c.nocase)
+		case searchRegexp:
+			match = re.matchString(k.String())
+		default:
+			switch c.mode {
+			case sortInteger:
+				n, err := k.BigInt()
+				if err != nil {
+					return nil, err
+				}
+				match = n.Cmp(bigPattern) == 0
+			case sortReal:
+				f, err := k.Double()
+				if err != nil {
+					return nil, err
+				}
+				match = f == realPattern
+			case sortDictionary:
+				match = dictionaryCompare(k.Runes(), pattern.Runes()) == 0
+			default:
+				match = compareRunes(k.Runes(), pattern.Runes(), c.nocase, -1) == 0
+			}
+		}
+		if match == negate {
+			continue
+		}
+		v, err := result(i)
+		if err != nil {
+			return nil, err
+		}
+		if !all {
+			return v, nil
+		}
+		if inline && stride > 1 && !subindices {
+			elems, _ := v.List()
+			matches = append(matches, elems...)
+			continue
+		}
+		matches = append(matches, v)
+	}
+	if all {
+		return NewListValue(matches), nil
+	}
+	return notFound()
+}
@@ -0,0 +1,360 @@
+package gotcl
+
+import (
+	"fmt"
+	"sort"
+	"unicode"
+)
+
+// A sortMode selects how the elements of a list are compared by lsort
+// and lsearch.
+type sortMode int
+
+const (
+	sortASCII sortMode = iota
+	sortDictionary
+	sortInteger
+	sortReal
+	sortCommand
+)
+
+// A listComparer compares list elements as selected by the options of
+// lsort or lsearch.
+type listComparer struct {
+	interp  *Interp
+	mode    sortMode
+	nocase  bool
+	command []*Value
+}
+
+// compare returns a negative number, zero or a positive number as a
+// is less than, equal to or greater than b.
+func (c *listComparer) compare(a, b *Value) (int, error) {
+	switch c.mode {
+	case sortDictionary:
+		return dictionaryCompare(a.Runes(), b.Runes()), nil
+	case sortInteger:
+		x, err := a.BigInt()
+		if err != nil {
+			return 0, err
+		}
+		y, err := b.BigInt()
+		if err != nil {
+			return 0, err
+		}
+		return x.Cmp(y), nil
+	case sortReal:
+		x, err := a.Double()
+		if err != nil {
+			return 0, err
+		}
+		y, err := b.Double()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case x < y:
+			return -1, nil
+		case x > y:
+			return 1, nil
+		}
+		return 0, nil
+	case sortCommand:
+		words := make([]*Value, 0, len(c.command)+2)
+		words = append(words, c.command...)
+		words = append(words, a, b)
+		result, err := c.interp.invoke(words)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.Int()
+		if err != nil {
+			return 0, fmt.Errorf("-compare command returned non-integer result")
+		}
+		switch {
+		case n < 0:
+			return -1, nil
+		case n > 0:
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return compareRunes(a.Runes(), b.Runes(), c.nocase, -1), nil
+}
+
+func isASCIIDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+// dictionaryCompare compares left and right in dictionary order:
+// case is ignored except as a tie-breaker, and embedded runs of
+// digits compare as integers.
+func dictionaryCompare(left, right []rune) int {
+	at := func(r []rune, i int) rune {
+		if i < len(r) {
+			return r[i]
+		}
+		return 0
+	}
+	diff, secondaryDiff := 0, 0
+	i, j := 0, 0
+	for {
+		if isASCIIDigit(at(right, j)) && isASCIIDigit(at(left, i)) {
+			// Compare runs of digits numerically. Leading zeros
+			// are skipped but used to break ties.
+			zeros := 0
+			for at(right, j) == '0' && isASCIIDigit(at(right, j+1)) {
+				j++
+				zeros--
+			}
+			for at(left, i) == '0' && isASCIIDigit(at(left, i+1)) {
+				i++
+				zeros++
+			}
+			if secondaryDiff == 0 {
+				secondaryDiff = zeros
+			}
+			diff = 0
+			for {
+				if diff == 0 {
+					diff = int(at(left, i)) - int(at(right, j))
+				}
+				i++
+				j++
+				if !isASCIIDigit(at(right, j)) {
+					if isASCIIDigit(at(left, i)) {
+						return 1
+					}
+					if diff != 0 {
+						return diff
+					}
+					break
+				} else if !isASCIIDigit(at(left, i)) {
+					return -1
+				}
+			}
+			continue
+		}
+		if i >= len(left) || j >= len(right) {
+			diff = int(at(left, i)) - int(at(right, j))
+			break
+		}
+		l, r := left[i], right[j]
+		i++
+		j++
+		ll, rl := unicode.ToLower(l), unicode.ToLower(r)
+		if ll != rl {
+			diff = int(ll) - int(rl)
+			break
+		}
+		if secondaryDiff == 0 {
+			if unicode.IsUpper(l) && unicode.IsLower(r) {
+				secondaryDiff = -1
+			} else if unicode.IsUpper(r) && unicode.IsLower(l) {
+				secondaryDiff = 1
+			}
+		}
+	}
+	if diff == 0 {
+		diff = secondaryDiff
+	}
+	return diff
+}
+
+// selectFromSublist returns the element of v named by the -index
+// option of lsort or lsearch.
+func selectFromSublist(v *Value, indices []*Value) (*Value, error) {
+	for _, index := range indices {
+		elems, err := v.List()
+		if err != nil {
+			return nil, err
+		}
+		i, err := getIndex(index, len(elems)-1)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= len(elems) {
+			return nil, fmt.Errorf("element %d missing from sublist \"%s\"", i, v.String())
+		}
+		v = elems[i]
+	}
+	return v, nil
+}
+
+// groupElements splits elems into groups of stride elements for the
+// -stride option of lsort and lsearch.
+func groupElements(elems []*Value, stride int) ([][]*Value, error) {
+	if len(elems)%stride != 0 {
+		return nil, fmt.Errorf("list size must be a multiple of the stride length")
+	}
+	groups := make([][]*Value, len(elems)/stride)
+	for i := range groups {
+		groups[i] = elems[i*stride : (i+1)*stride]
+	}
+	return groups, nil
+}
+
+var lsortOptions = []string{
+	"-ascii", "-command", "-decreasing", "-dictionary", "-increasing",
+	"-index", "-indices", "-integer", "-nocase", "-real", "-stride",
+	"-unique",
+}
+
+// lsort ?options? list
+//
+// This command sorts the elements of list, returning a new list in
+// sorted order. By default ASCII sorting is used with the result
+// returned in increasing order. The -ascii, -dictionary, -integer,
+// -real and -command options select how elements are compared;
+// -increasing and -decreasing select the order; -index sorts on an
+// element of each sublist; -stride sorts groups of elements; -indices
+// returns indices rather than values; -nocase compares
+// case-insensitively; and -unique retains only the last of a set of
+// duplicate elements.
+func cmdLsort(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "?-option value ...? list")
+	}
+	c := &listComparer{interp: interp}
+	decreasing, indices, unique := false, false, false
+	var sortIndex []*Value
+	stride := 1
+	for i := 1; i < len(args)-1; i++ {
+		opt, err := lookupIndex(lsortOptions, "option", args[i])
+		if err != nil {
+			return nil, err
+		}
+		switch lsortOptions[opt] {
+		case "-ascii":
+			c.mode = sortASCII
+		case "-command":
+			if i == len(args)-2 {
+				return nil, fmt.Errorf("\"-command\" option must be followed by comparison command")
+			}
+			i++
+			if c.command, err = args[i].List(); err != nil {
+				return nil, err
+			}
+			c.mode = sortCommand
+		case "-decreasing":
+			decreasing = true
+		case "-dictionary":
+			c.mode = sortDictionary
+		case "-increasing":
+			decreasing = false
+		case "-index":
+			if i == len(args)-2 {
+				return nil, fmt.Errorf("\"-index\" option must be followed by list index")
+			}
+			i++
+			if sortIndex, err = args[i].List(); err != nil {
+				return nil, err
+			}
+			for _, index := range sortIndex {
+				if _, err := getIndex(index, 0); err != nil {
+					return nil, err
+				}
+			}
+		case "-indices":
+			indices = true
+		case "-integer":
+			c.mode = sortInteger
+		case "-nocase":
+			c.nocase = true
+		case "-real":
+			c.mode = sortReal
+		case "-stride":
+			if i == len(args)-2 {
+				return nil, fmt.Errorf("\"-stride\" option must be followed by stride length")
+			}
+			i++
+			if stride, err = getInt(args[i]); err != nil {
+				return nil, err
+			}
+			if stride < 2 {
+				return nil, fmt.Errorf("stride length must be at least 2")
+			}
+		case "-unique":
+			unique = true
+		}
+	}
+	elems, err := args[len(args)-1].List()
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort groups of stride elements, keyed on the element of each
+	// group selected by the leading -index value.
+	groups, err := groupElements(elems, stride)
+	if err != nil {
+		return nil, err
+	}
+	offset := 0
+	if stride > 1 && len(sortIndex) > 0 {
+		if offset, err = getIndex(sortIndex[0], stride-1); err != nil {
+			return nil, err
+		}
+		if offset < 0 || offset >= stride {
+			return nil, fmt.Errorf("when used with \"-stride\", the leading \"-index\" value must be within the group")
+		}
+		sortIndex = sortIndex[1:]
+	}
+	type sortItem struct {
+		index int
+		key   *Value
+	}
+	items := make([]sortItem, len(groups))
+	for i, group := range groups {
+		key, err := selectFromSublist(group[offset], sortIndex)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = sortItem{i, key}
+	}
+	var sortErr error
+	sort.SliceStable(items, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+		n, err := c.compare(items[i].key, items[j].key)
+		if err != nil {
+			sortErr = err
+			return false
+		}
+		if decreasing {
+			return n > 0
+		}
+		return n < 0
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+	if unique {
+		// Keep the last of each run of equal elements.
+		kept := items[:0]
+		for i, item := range items {
+			if i+1 < len(items) {
+				n, err := c.compare(item.key, items[i+1].key)
+				if err != nil {
+					return nil, err
+				}
+				if n == 0 {
+					continue
+				}
+			}
+			kept = append(kept, item)
+		}
+		items = kept
+	}
+	out := make([]*Value, 0, len(items)*stride)
+	for _, item := range items {
+		for j := 0; j < stride; j++ {
+			if indices {
+				out = append(out, NewIntValue(int64(item.index*stride+j)))
+			} else {
+				out = append(out, groups[item.index][j])
+			}
+		}
+	}
+	return NewListValue(out), nil
+}
@@ -0,0 +1,1323 @@
+package gotcl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A nativeMethod is a method of oo::object or oo::class implemented
+// in Go.
+type nativeMethod struct {
+	name     string
+	exported bool
+	fn       func(interp *Interp, ctx *methodContext, args []*Value) (*Value, error)
+}
+
+var objectMethods = []nativeMethod{
+	{"destroy", true, objectDestroy},
+	{"eval", false, objectEval},
+	{"unknown", false, objectUnknown},
+	{"variable", false, objectVariable},
+	{"varname", false, objectVarname},
+}
+
+var classMethods = []nativeMethod{
+	{"create", true, classCreate},
+	{"new", true, classNew},
+}
+
+// wrongNumArgs returns the wrong # args error of the method of ctx,
+// whose arguments are described by message.
+func (ctx *methodContext) wrongNumArgs(message string) error {
+	return wrongNumArgs(ctx.prefix, len(ctx.prefix), message)
+}
+
+// obj destroy
+//
+// This method destroys the object, running its destructors. If the
+// object is a class, its instances and subclasses are destroyed too.
+func objectDestroy(interp *Interp, ctx *methodContext, args []*Value) (*Value, error) {
+	if len(args) != 0 {
+		return nil, ctx.wrongNumArgs("")
+	}
+	if err := interp.destroyObject(ctx.obj); err != nil {
+		return nil, err
+	}
+	return emptyValue(), nil
+}
+
+// obj eval ?arg ...?
+//
+// This method concatenates the arguments as if with concat and
+// evaluates the result in a new frame in the context of the object,
+// where my and self refer to it.
+func objectEval(interp *Interp, ctx *methodContext, args []*Value) (*Value, error) {
+	if len(args) == 0 {
+		return nil, ctx.wrongNumArgs("arg ?arg ...?")
+	}
+	frame := &callFrame{
+		vars:   map[string]*variable{},
+		level:  interp.frame.level + 1,
+		caller: interp.frame,
+		method: ctx,
+	}
+	for _, c := range ctx.obj.methodOrder() {
+		names := ctx.obj.vars
+		if c != nil {
+			names = c.vars
+		}
+		for _, name := range names {
+			vr, err := ctx.obj.variable(interp, name)
+			if err != nil {
+				return nil, err
+			}
+			frame.vars[name] = vr
+			frame.link(name)
+		}
+	}
+	caller := interp.frame
+	interp.frame = frame
+	defer func() { interp.frame = caller }()
+	return interp.evalScript([]rune(concatValues(args)))
+}
+
+// obj unknown methodName ?arg ...?
+//
+// This method is invoked when an attempt is made to invoke a method
+// that the object does not have. It returns an error listing the
+// methods that may be invoked.
+func objectUnknown(interp *Interp, ctx *methodContext, args []*Value) (*Value, error) {
+	if len(args) == 0 {
+		return nil, ctx.wrongNumArgs("method ?arg ...?")
+	}
+	names := ctx.obj.methodNames(ctx.private)
+	switch len(names) {
+	case 0:
+		return nil, fmt.Errorf("object \"%s\" has no visible methods", ctx.obj.name())
+	case 1:
+		return nil, fmt.Errorf("unknown method \"%s\": must be %s", args[0], names[0])
+	}
+	return nil, fmt.Errorf("unknown method \"%s\": must be %s or %s",
+		args[0], strings.Join(names[:len(names)-1], ", "), names[len(names)-1])
+}
+
+// obj variable ?varName ...?
+//
+// This method makes the variables of the object named by the
+// arguments available in the current method as local variables.
+func objectVariable(interp *Interp, ctx *methodContext, args []*Value) (*Value, error) {
+	for _, arg := range args {
+		name := arg.String()
+		if _, _, isElem := splitVarName(name); isElem {
+			return nil, fmt.Errorf("bad variable name \"%s\": can't create a scalar variable that looks like an array element", name)
+		}
+		vr, err := ctx.obj.variable(interp, name)
+		if err != nil {
+			return nil, err
+		}
+		if err := interp.linkVar(name, vr); err != nil {
+			return nil, err
+		}
+	}
+	return emptyValue(), nil
+}
+
+// obj varname varName
+//
+// This method returns the fully qualified name of the variable
+// varName of the object, which may be used from any context, for
+// example with vwait or trace.
+func objectVarname(interp *Interp, ctx *methodContext, args []*Value) (*Value, error) {
+	if len(args) != 1 {
+		return nil, ctx.wrongNumArgs("varName")
+	}
+	if _, err := ctx.obj.variable(interp, args[0].String()); err != nil {
+		return nil, err
+	}
+	return NewStringValue(ctx.obj.varName(args[0].String())), nil
+}
+
+// cls create name ?arg ...?
+//
+// This method creates a new instance of the class called name,
+// passing the arguments to its constructor, and returns the fully
+// qualified name of the object.
+func classCreate(interp *Interp, ctx *methodContext, args []*Value) (*Value, error) {
+	if len(args) == 0 {
+		return nil, ctx.wrongNumArgs("objectName ?arg ...?")
+	}
+	if args[0].String() == "" {
+		return nil, fmt.Errorf("object name must not be empty")
+	}
+	obj, err := interp.newObject(ctx.obj.classDef, args[0].String())
+	if err != nil {
+		return nil, err
+	}
+	prefix := append(append([]*Value(nil), ctx.prefix...), args[0])
+	return interp.construct(obj, args[1:], prefix)
+}
+
+// cls new ?arg ...?
+//
+// This method creates a new instance of the class with a generated
+// name, passing the arguments to its constructor, and returns the
+// fully qualified name of the object.
+func classNew(interp *Interp, ctx *methodContext, args []*Value) (*Value, error) {
+	obj, err := interp.newObject(ctx.obj.classDef, "")
+	if err != nil {
+		return nil, err
+	}
+	return interp.construct(obj, args, ctx.prefix)
+}
+
+// classConstructor is the constructor of oo::class, which takes an
+// optional definition script for the new class.
+func classConstructor(interp *Interp, ctx *methodContext, args []*Value) (*Value, error) {
+	if len(args) > 1 {
+		return nil, ctx.wrongNumArgs("?definitionScript?")
+	}
+	if len(args) == 1 {
+		return interp.evalDefinition(&definer{cls: ctx.obj.classDef}, args[0])
+	}
+	return emptyValue(), nil
+}
+
+// A definer is the target of oo::define, a class, or of
+// oo::objdefine, an object.
+type definer struct {
+	cls *class
+	obj *object
+}
+
+// methods returns the methods the definer defines.
+func (def *definer) methods() map[string]*method {
+	if def.cls != nil {
+		return def.cls.methods
+	}
+	return def.obj.methods
+}
+
+// addMethod adds m to the methods of the definer. A redefined method
+// keeps the visibility of the method it replaces.
+func (def *definer) addMethod(m *method) {
+	methods := def.methods()
+	if old := methods[m.name]; old != nil {
+		m.exported = old.exported
+	}
+	m.class = def.cls
+	if def.cls == nil {
+		m.obj = def.obj
+	}
+	methods[m.name] = m
+}
+
+// A definition is a command of a definition script.
+type definition func(interp *Interp, def *definer, args []*Value) error
+
+var classDefinitions map[string]definition
+
+var objectDefinitions map[string]definition
+
+func init() {
+	classDefinitions = map[string]definition{
+		"constructor":  defConstructor,
+		"deletemethod": defDeleteMethod,
+		"destructor":   defDestructor,
+		"export":       defExport,
+		"filter":       defFilter,
+		"forward":      defForward,
+		"method":       defMethod,
+		"mixin":        defMixin,
+		"renamemethod": defRenameMethod,
+		"self":         defSelf,
+		"superclass":   defSuperclass,
+		"unexport":     defUnexport,
+		"variable":     defVariable,
+	}
+	objectDefinitions = map[string]definition{
+		"class":        defClass,
+		"deletemethod": defDeleteMethod,
+		"export":       defExport,
+		"filter":       defFilter,
+		"forward":      defForward,
+		"method":       defMethod,
+		"mixin":        defMixin,
+		"renamemethod": defRenameMethod,
+		"unexport":     defUnexport,
+		"variable":     defVariable,
+	}
+}
+
+// evalDefinition evaluates the definition script script for def.
+// Each command of the script is a definition, such as method or
+// superclass; its words are substituted as usual.
+func (interp *Interp) evalDefinition(def *definer, script *Value) (*Value, error) {
+	r := script.Runes()
+	for idx := 0; idx < len(r); {
+		ts, size, err := ParseCommand(r[idx:], false)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			break
+		}
+		idx += size
+		if len(ts) == 0 {
+			continue
+		}
+		words, err := interp.substWords(ts)
+		if err != nil {
+			return nil, err
+		}
+		if err := interp.define(def, words); err != nil {
+			return nil, err
+		}
+	}
+	return emptyValue(), nil
+}
+
+// define applies the definition words to def.
+func (interp *Interp) define(def *definer, words []*Value) error {
+	if len(words) == 0 {
+		return nil
+	}
+	table := classDefinitions
+	if def.cls == nil {
+		table = objectDefinitions
+	}
+	fn, ok := table[words[0].String()]
+	if !ok {
+		return fmt.Errorf("invalid command name \"%s\"", words[0])
+	}
+	return fn(interp, def, words)
+}
+
+// oo::define class defScript
+// oo::define class subcommand arg ?arg ...?
+//
+// This command is used to change the definition of a class. The
+// definition is either a script of definition commands, or a single
+// definition command and its arguments. The definition commands are
+// constructor, deletemethod, destructor, export, filter, forward,
+// method, mixin, renamemethod, self, superclass, unexport and
+// variable.
+func cmdOODefine(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 1, "className arg ?arg ...?")
+	}
+	c, err := interp.lookupClass(args[1])
+	if err != nil {
+		return nil, err
+	}
+	def := &definer{cls: c}
+	if len(args) == 3 {
+		return interp.evalDefinition(def, args[2])
+	}
+	return emptyValue(), interp.define(def, args[2:])
+}
+
+// oo::objdefine object defScript
+// oo::objdefine object subcommand arg ?arg ...?
+//
+// This command is used to change the definition of an object, in
+// the same way as oo::define changes a class. The definition
+// commands are class, deletemethod, export, filter, forward, method,
+// mixin, renamemethod, unexport and variable.
+func cmdOOObjdefine(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 1, "objectName arg ?arg ...?")
+	}
+	obj, err := interp.lookupObject(args[1])
+	if err != nil {
+		return nil, err
+	}
+	def := &definer{obj: obj}
+	if len(args) == 3 {
+		return interp.evalDefinition(def, args[2])
+	}
+	return emptyValue(), interp.define(def, args[2:])
+}
+
+// constructor argList bodyScript
+func defConstructor(interp *Interp, def *definer, args []*Value) error {
+	if len(args) != 3 {
+		return wrongNumArgs(args, 1, "arguments body")
+	}
+	if args[2].String() == "" {
+		def.cls.constructor = nil
+		return nil
+	}
+	params, err := parseProcParams(args[1])
+	if err != nil {
+		return err
+	}
+	def.cls.constructor = &method{
+		name:  "<constructor>",
+		proc:  &procedure{params: params, body: args[2]},
+		class: def.cls,
+	}
+	return nil
+}
+
+// destructor bodyScript
+func defDestructor(interp *Interp, def *definer, args []*Value) error {
+	if len(args) != 2 {
+		return wrongNumArgs(args, 1, "body")
+	}
+	if args[1].String() == "" {
+		def.cls.destructor = nil
+		return nil
+	}
+	def.cls.destructor = &method{
+		name:  "<destructor>",
+		proc:  &procedure{body: args[1]},
+		class: def.cls,
+	}
+	return nil
+}
+
+// method name argList bodyScript
+func defMethod(interp *Interp, def *definer, args []*Value) error {
+	if len(args) != 4 {
+		return wrongNumArgs(args, 1, "name args body")
+	}
+	params, err := parseProcParams(args[2])
+	if err != nil {
+		return err
+	}
+	name := args[1].String()
+	def.addMethod(&method{
+		name:     name,
+		exported: isExportedName(name),
+		proc:     &procedure{params: params, body: args[3]},
+	})
+	return nil
+}
+
+// forward name cmdName ?arg ...?
+func defForward(interp *Interp, def *definer, args []*Value) error {
+	if len(args) < 3 {
+		return wrongNumArgs(args, 1, "name cmdName ?arg ...?")
+	}
+	name := args[1].String()
+	def.addMethod(&method{
+		name:     name,
+		exported: isExportedName(name),
+		forward:  append([]*Value(nil), args[2:]...),
+	})
+	return nil
+}
+
+// deletemethod name ?name ...?
+func defDeleteMethod(interp *Interp, def *definer, args []*Value) error {
+	methods := def.methods()
+	for _, arg := range args[1:] {
+		if m := methods[arg.String()]; m == nil || !m.isImpl() {
+			return fmt.Errorf("method \"%s\" does not exist", arg)
+		}
+		delete(methods, arg.String())
+	}
+	return nil
+}
+
+// renamemethod fromName toName
+func defRenameMethod(interp *Interp, def *definer, args []*Value) error {
+	if len(args) != 3 {
+		return wrongNumArgs(args, 1, "oldName newName")
+	}
+	methods := def.methods()
+	from, to := args[1].String(), args[2].String()
+	m := methods[from]
+	if m == nil || !m.isImpl() {
+		return fmt.Errorf("method \"%s\" does not exist", from)
+	}
+	if methods[to] != nil {
+		return fmt.Errorf("method called %s already exists", to)
+	}
+	delete(methods, from)
+	m.name = to
+	methods[to] = m
+	return nil
+}
+
+// export name ?name ...?
+func defExport(interp *Interp, def *definer, args []*Value) error {
+	setVisibility(def, args[1:], true)
+	return nil
+}
+
+// unexport name ?name ...?
+func defUnexport(interp *Interp, def *definer, args []*Value) error {
+	setVisibility(def, args[1:], false)
+	return nil
+}
+
+// setVisibility exports or unexports the methods names of def. A
+// name def does not declare is declared without an implementation,
+// to record its visibility.
+func setVisibility(def *definer, names []*Value, exported bool) {
+	methods := def.methods()
+	for _, name := range names {
+		m := methods[name.String()]
+		if m == nil {
+			m = &method{name: name.String(), class: def.cls}
+			if def.cls == nil {
+				m.obj = def.obj
+			}
+			methods[m.name] = m
+		}
+		m.exported = exported
+	}
+}
+
+// slotArgs parses the arguments of a slot definition, such as
+// superclass or variable, which may start with the operation
+// -append, -clear or -set. It returns the operation, or op if none
+// is given, and the values.
+func slotArgs(args []*Value, op string) (string, []*Value, error) {
+	if len(args) > 1 {
+		switch s := args[1].String(); s {
+		case "-append", "-clear", "-set":
+			op = s
+			if op == "-clear" && len(args) > 2 {
+				return "", nil, wrongNumArgs(args, 2, "")
+			}
+			return op, args[2:], nil
+		}
+	}
+	return op, args[1:], nil
+}
+
+// variable ?-append|-clear|-set? ?name ...?
+func defVariable(interp *Interp, def *definer, args []*Value) error {
+	op, values, err := slotArgs(args, "-append")
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, v := range values {
+		name := v.String()
+		if strings.Contains(name, "::") {
+			return fmt.Errorf("invalid declared variable name \"%s\": must not contain namespace separators", name)
+		}
+		if _, _, isElem := splitVarName(name); isElem {
+			return fmt.Errorf("invalid declared variable name \"%s\": must not refer to an array element", name)
+		}
+		names = append(names, name)
+	}
+	var vars *[]string
+	if def.cls != nil {
+		vars = &def.cls.vars
+	} else {
+		vars = &def.obj.vars
+	}
+	if op == "-append" {
+		names = append(append([]string(nil), *vars...), names...)
+	}
+	*vars = names
+	return nil
+}
+
+// filter ?-append|-clear|-set? ?methodName ...?
+func defFilter(interp *Interp, def *definer, args []*Value) error {
+	op, values, err := slotArgs(args, "-append")
+	if err != nil {
+		return err
+	}
+	var filters *[]string
+	if def.cls != nil {
+		filters = &def.cls.filters
+	} else {
+		filters = &def.obj.filters
+	}
+	names := valuesToStrings(values)
+	if op == "-append" {
+		names = append(append([]string(nil), *filters...), names...)
+	}
+	*filters = names
+	return nil
+}
+
+// lookupClasses returns the classes named by values.
+func (interp *Interp) lookupClasses(values []*Value) ([]*class, error) {
+	classes := make([]*class, len(values))
+	for i, v := range values {
+		c, err := interp.lookupClass(v)
+		if err != nil {
+			return nil, err
+		}
+		classes[i] = c
+	}
+	return classes, nil
+}
+
+// mixin ?-append|-clear|-set? ?className ...?
+func defMixin(interp *Interp, def *definer, args []*Value) error {
+	op, values, err := slotArgs(args, "-set")
+	if err != nil {
+		return err
+	}
+	classes, err := interp.lookupClasses(values)
+	if err != nil {
+		return err
+	}
+	var mixins *[]*class
+	if def.cls != nil {
+		mixins = &def.cls.mixins
+		for _, m := range classes {
+			if m.isSubclassOf(def.cls) {
+				return fmt.Errorf("may not mix a class into itself")
+			}
+		}
+	} else {
+		mixins = &def.obj.mixins
+	}
+	if op == "-append" {
+		classes = append(append([]*class(nil), *mixins...), classes...)
+	}
+	*mixins = classes
+	return nil
+}
+
+// superclass ?-append|-clear|-set? ?className ...?
+func defSuperclass(interp *Interp, def *definer, args []*Value) error {
+	op, values, err := slotArgs(args, "-set")
+	if err != nil {
+		return err
+	}
+	c := def.cls
+	if c == interp.oo.object {
+		return fmt.Errorf("may not modify the superclass of the root object")
+	}
+	supers, err := interp.lookupClasses(values)
+	if err != nil {
+		return err
+	}
+	if op == "-append" {
+		supers = append(append([]*class(nil), c.superclasses...), supers...)
+	}
+	seen := map[*class]bool{}
+	for _, s := range supers {
+		if seen[s] {
+			return fmt.Errorf("class should only be a direct superclass once")
+		}
+		seen[s] = true
+		if s.isSubclassOf(c) {
+			return fmt.Errorf("attempt to form circular dependency graph")
+		}
+	}
+	if len(supers) == 0 {
+		supers = []*class{interp.oo.object}
+	}
+	for _, s := range c.superclasses {
+		s.subclasses = removeClass(s.subclasses, c)
+	}
+	c.superclasses = supers
+	for _, s := range supers {
+		s.subclasses = append(s.subclasses, c)
+	}
+	return nil
+}
+
+// self ?subcommand arg ...?
+// self script
+func defSelf(interp *Interp, def *definer, args []*Value) error {
+	objDef := &definer{obj: def.cls.obj}
+	switch len(args) {
+	case 1:
+		return nil
+	case 2:
+		_, err := interp.evalDefinition(objDef, args[1])
+		return err
+	}
+	return interp.define(objDef, args[1:])
+}
+
+// class className
+func defClass(interp *Interp, def *definer, args []*Value) error {
+	if len(args) != 2 {
+		return wrongNumArgs(args, 1, "className")
+	}
+	c, err := interp.lookupClass(args[1])
+	if err != nil {
+		return err
+	}
+	obj := def.obj
+	if obj.classDef != nil && !c.isSubclassOf(interp.oo.class) {
+		return fmt.Errorf("may not change a class object into a non-class object")
+	}
+	obj.class.instances = removeObject(obj.class.instances, obj)
+	obj.class = c
+	c.instances = append(c.instances, obj)
+	return nil
+}
+
+// currentMethod returns the context of the method running, or an
+// error naming cmd if there is none.
+func (interp *Interp) currentMethod(cmd string) (*methodContext, error) {
+	if ctx := interp.frame.method; ctx != nil {
+		return ctx, nil
+	}
+	return nil, fmt.Errorf("%s may only be called from inside a method", cmd)
+}
+
+var selfSubcommands = []string{"class", "method", "namespace", "next", "object", "target"}
+
+// self ?subcommand?
+//
+// The self command, which should only be used from within the
+// context of a call to a method, returns information about the
+// call. Without a subcommand, or with object, it returns the name of
+// the object. The class subcommand returns the class declaring the
+// method, method the name of the method, namespace the namespace of
+// the object, next the class or object and name of the next method
+// in the call chain, if any, and target, inside a filter, the class
+// or object and name of the method the filter was applied to.
+func cmdSelf(interp *Interp, args []*Value) (*Value, error) {
+	ctx, err := interp.currentMethod("self")
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 2 {
+		return nil, wrongNumArgs(args, 1, "?subcommand?")
+	}
+	if len(args) == 1 {
+		return NewStringValue(ctx.obj.name()), nil
+	}
+	i, err := lookupIndex(selfSubcommands, "subcommand", args[1])
+	if err != nil {
+		return nil, err
+	}
+	switch selfSubcommands[i] {
+	case "class":
+		m := ctx.chain[ctx.index].m
+		if m.class == nil {
+			return nil, fmt.Errorf("method not defined by a class")
+		}
+		return NewStringValue(m.class.obj.name()), nil
+	case "method":
+		return NewStringValue(ctx.name), nil
+	case "namespace":
+		return NewStringValue("::" + ctx.obj.ns), nil
+	case "next":
+		if ctx.index+1 >= len(ctx.chain) {
+			return emptyValue(), nil
+		}
+		next := ctx.chain[ctx.index+1].m
+		return NewListValue(stringsToValues([]string{next.declarer(), ctx.name})), nil
+	case "target":
+		if !ctx.chain[ctx.index].filter {
+			return nil, fmt.Errorf("not inside a filtering context")
+		}
+		for _, e := range ctx.chain[ctx.index:] {
+			if !e.filter {
+				return NewListValue(stringsToValues([]string{e.m.declarer(), ctx.name})), nil
+			}
+		}
+		return emptyValue(), nil
+	}
+	return NewStringValue(ctx.obj.name()), nil
+}
+
+// my methodName ?arg ...?
+//
+// The my command is used to allow methods of objects to invoke
+// methods of the object, including unexported methods.
+func cmdMy(interp *Interp, args []*Value) (*Value, error) {
+	ctx := interp.frame.method
+	if ctx == nil {
+		return nil, fmt.Errorf("invalid command name \"%s\"", args[0])
+	}
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "method ?arg ...?")
+	}
+	return interp.invokeMethod(ctx.obj, args[1].String(), args[2:], args[:2], true)
+}
+
+// myTarget resolves the my command args, run by tailcall in the
+// method context method, to the procedure of the method it invokes.
+func myTarget(interp *Interp, args []*Value, method *methodContext) (*procedure, []*Value, *methodContext) {
+	if method == nil || len(args) < 2 {
+		return nil, nil, nil
+	}
+	return methodTarget(method.obj, args[1].String(), args[2:], args[:2], true)
+}
+
+// next ?arg ...?
+//
+// The next command is used to call implementations of a method by a
+// class, superclass or mixin that are overridden by the current
+// method. It invokes the next implementation in the call chain with
+// the arguments and returns its result.
+func cmdNext(interp *Interp, args []*Value) (*Value, error) {
+	ctx, err := interp.currentMethod("next")
+	if err != nil {
+		return nil, err
+	}
+	if ctx.index+1 >= len(ctx.chain) {
+		return nil, fmt.Errorf("no next %s implementation", ctx.kind)
+	}
+	next := *ctx
+	next.index++
+	next.prefix = args[:1]
+	return interp.callChainEntry(&next, args[1:])
+}
+
+// nextto class ?arg ...?
+//
+// The nextto command is the same as the next command, except that
+// it skips ahead in the call chain to the implementation of the
+// method by the class class.
+func cmdNextto(interp *Interp, args []*Value) (*Value, error) {
+	ctx, err := interp.currentMethod("nextto")
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "class ?arg ...?")
+	}
+	c, err := interp.lookupClass(args[1])
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range ctx.chain {
+		if e.filter || e.m.class != c {
+			continue
+		}
+		if i <= ctx.index {
+			return nil, fmt.Errorf("method implementation by \"%s\" not reachable from here", args[1])
+		}
+		next := *ctx
+		next.index = i
+		next.prefix = args[:2]
+		return interp.callChainEntry(&next, args[2:])
+	}
+	return nil, fmt.Errorf("method has no non-filter implementation by \"%s\"", args[1])
+}
+
+// paramList returns the formal parameter list of proc, as given to
+// proc.
+func (proc *procedure) paramList() *Value {
+	params := make([]*Value, len(proc.params))
+	for i, p := range proc.params {
+		params[i] = NewStringValue(p.name)
+		if p.hasDef {
+			params[i] = NewListValue([]*Value{params[i], p.def})
+		}
+	}
+	return NewListValue(params)
+}
+
+// methodDefinition returns the argument list and body of the method
+// name in methods.
+func methodDefinition(methods map[string]*method, name *Value) (*Value, error) {
+	m := methods[name.String()]
+	if m == nil || !m.isImpl() {
+		return nil, fmt.Errorf("method \"%s\" does not exist", name)
+	}
+	if m.proc == nil {
+		return nil, fmt.Errorf("definition not available for this kind of method")
+	}
+	return NewListValue([]*Value{m.proc.paramList(), m.proc.body}), nil
+}
+
+// methodForward returns the command prefix of the forwarded method
+// name in methods.
+func methodForward(methods map[string]*method, name *Value) (*Value, error) {
+	m := methods[name.String()]
+	if m == nil || !m.isImpl() {
+		return nil, fmt.Errorf("method \"%s\" does not exist", name)
+	}
+	if m.forward == nil {
+		return nil, fmt.Errorf("prefix argument list not available for this kind of method")
+	}
+	return NewListValue(m.forward), nil
+}
+
+// methodTypeOf returns the type of the method name in methods.
+func methodTypeOf(methods map[string]*method, name *Value) (*Value, error) {
+	m := methods[name.String()]
+	if m == nil || !m.isImpl() {
+		return nil, fmt.Errorf("method \"%s\" does not exist", name)
+	}
+	return NewStringValue(m.methodType()), nil
+}
+
+var methodsOptions = []string{"-all", "-private"}
+
+// parseMethodsOptions parses the options of info object methods and
+// info class methods.
+func parseMethodsOptions(args []*Value) (all, private bool, err error) {
+	for _, arg := range args {
+		i, err := lookupIndex(methodsOptions, "option", arg)
+		if err != nil {
+			return false, false, err
+		}
+		switch methodsOptions[i] {
+		case "-all":
+			all = true
+		case "-private":
+			private = true
+		}
+	}
+	return all, private, nil
+}
+
+// visibleMethods returns the sorted names of the methods declared by
+// the method tables, which are in order of precedence, that have an
+// implementation and are exported or private is set.
+func visibleMethods(tables []map[string]*method, private bool) []string {
+	exported := map[string]bool{}
+	impl := map[string]bool{}
+	var names []string
+	for _, methods := range tables {
+		for name, m := range methods {
+			if _, ok := exported[name]; !ok {
+				exported[name] = m.exported
+				names = append(names, name)
+			}
+			impl[name] = impl[name] || m.isImpl()
+		}
+	}
+	var visible []string
+	for _, name := range names {
+		if impl[name] && (private || exported[name]) {
+			visible = append(visible, name)
+		}
+	}
+	sort.Strings(visible)
+	return visible
+}
+
+// classNames returns the names of classes.
+func classNames(classes []*class) *Value {
+	names := make([]*Value, len(classes))
+	for i, c := range classes {
+		names[i] = NewStringValue(c.obj.name())
+	}
+	return NewListValue(names)
+}
+
+// matchingObjects returns the names of objs matching the glob
+// pattern in args, if any.
+func matchingObjects(objs []*object, args []*Value) *Value {
+	var names []*Value
+	for _, obj := range objs {
+		if len(args) == 0 || stringMatch(args[0].Runes(), []rune(obj.name()), false) {
+			names = append(names, NewStringValue(obj.name()))
+		}
+	}
+	return NewListValue(names)
+}
+
+var infoObjectSubcommands = []subcommand{
+	{"class", cmdInfoObjectClass},
+	{"definition", cmdInfoObjectDefinition},
+	{"filters", cmdInfoObjectFilters},
+	{"forward", cmdInfoObjectForward},
+	{"isa", cmdInfoObjectIsa},
+	{"methods", cmdInfoObjectMethods},
+	{"methodtype", cmdInfoObjectMethodtype},
+	{"mixins", cmdInfoObjectMixins},
+	{"namespace", cmdInfoObjectNamespace},
+	{"variables", cmdInfoObjectVariables},
+	{"vars", cmdInfoObjectVars},
+}
+
+// info object subcommand object ?arg ...?
+//
+// This subcommand provides information about the object object.
+func cmdInfoObject(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 2, "subcommand ?arg ...?")
+	}
+	words := append([]*Value{NewStringValue("info object")}, args[2:]...)
+	return dispatchSubcommand(interp, words, infoObjectSubcommands)
+}
+
+// info object class object ?className?
+func cmdInfoObjectClass(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "objName ?className?")
+	}
+	obj, err := interp.lookupObject(args[2])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 3 {
+		return NewStringValue(obj.class.obj.name()), nil
+	}
+	c, err := interp.lookupClass(args[3])
+	if err != nil {
+		return nil, err
+	}
+	return NewBoolValue(obj.class.isSubclassOf(c)), nil
+}
+
+// info object definition object method
+func cmdInfoObjectDefinition(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "objName methodName")
+	}
+	obj, err := interp.lookupObject(args[2])
+	if err != nil {
+		return nil, err
+	}
+	return methodDefinition(obj.methods, args[3])
+}
+
+// info object filters object
+func cmdInfoObjectFilters(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "objName")
+	}
+	obj, err := interp.lookupObject(args[2])
+	if err != nil {
+		return nil, err
+	}
+	return NewListValue(stringsToValues(obj.filters)), nil
+}
+
+// info object forward object method
+func cmdInfoObjectForward(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "objName methodName")
+	}
+	obj, err := interp.lookupObject(args[2])
+	if err != nil {
+		return nil, err
+	}
+	return methodForward(obj.methods, args[3])
+}
+
+var isaCategories = []string{"class", "metaclass", "mixin", "object", "typeof"}
+
+// info object isa category object ?arg?
+//
+// This subcommand tests whether object belongs to category: class,
+// whether it is a class; metaclass, whether it is a class whose
+// instances are classes; mixin, whether the class arg is mixed into
+// it; object, whether it is an object at all; and typeof, whether
+// the class arg is its class, one of its superclasses or a mixin.
+func cmdInfoObjectIsa(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 4 {
+		return nil, wrongNumArgs(args, 2, "category objName ?arg ...?")
+	}
+	i, err := lookupIndex(isaCategories, "category", args[2])
+	if err != nil {
+		return nil, err
+	}
+	category := isaCategories[i]
+	withClass := category == "mixin" || category == "typeof"
+	switch {
+	case withClass && len(args) != 5:
+		return nil, wrongNumArgs(args, 3, "objName className")
+	case !withClass && len(args) != 4:
+		return nil, wrongNumArgs(args, 3, "objName")
+	}
+	obj, err := interp.lookupObject(args[3])
+	if category == "object" {
+		return NewBoolValue(err == nil), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch category {
+	case "class":
+		return NewBoolValue(obj.classDef != nil), nil
+	case "metaclass":
+		return NewBoolValue(obj.classDef != nil && obj.classDef.isSubclassOf(interp.oo.class)), nil
+	}
+	c, err := interp.lookupClass(args[4])
+	if err != nil {
+		return nil, err
+	}
+	if category == "mixin" {
+		return NewBoolValue(len(removeClass(obj.mixins, c)) != len(obj.mixins)), nil
+	}
+	for _, other := range obj.methodOrder() {
+		if other == c {
+			return NewBoolValue(true), nil
+		}
+	}
+	return NewBoolValue(false), nil
+}
+
+// info object methods object ?-all? ?-private?
+//
+// This subcommand returns the names of the methods of object that
+// are exported, or also those that are unexported with -private.
+// Only the methods defined on the object itself are listed, unless
+// -all is given.
+func cmdInfoObjectMethods(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 2, "objName ?-option value ...?")
+	}
+	obj, err := interp.lookupObject(args[2])
+	if err != nil {
+		return nil, err
+	}
+	all, private, err := parseMethodsOptions(args[3:])
+	if err != nil {
+		return nil, err
+	}
+	if all {
+		return NewListValue(stringsToValues(obj.methodNames(private))), nil
+	}
+	return NewListValue(stringsToValues(visibleMethods([]map[string]*method{obj.methods}, private))), nil
+}
+
+// info object methodtype object method
+func cmdInfoObjectMethodtype(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "objName methodName")
+	}
+	obj, err := interp.lookupObject(args[2])
+	if err != nil {
+		return nil, err
+	}
+	return methodTypeOf(obj.methods, args[3])
+}
+
+// info object mixins object
+func cmdInfoObjectMixins(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "objName")
+	}
+	obj, err := interp.lookupObject(args[2])
+	if err != nil {
+		return nil, err
+	}
+	return classNames(obj.mixins), nil
+}
+
+// info object namespace object
+func cmdInfoObjectNamespace(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "objName")
+	}
+	obj, err := interp.lookupObject(args[2])
+	if err != nil {
+		return nil, err
+	}
+	return NewStringValue("::" + obj.ns), nil
+}
+
+// info object variables object
+func cmdInfoObjectVariables(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "objName")
+	}
+	obj, err := interp.lookupObject(args[2])
+	if err != nil {
+		return nil, err
+	}
+	return NewListValue(stringsToValues(obj.vars)), nil
+}
+
+// info object vars object ?pattern?
+//
+// This subcommand returns the sorted names of the variables of
+// object that exist, matching the glob pattern if given.
+func cmdInfoObjectVars(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "objName ?pattern?")
+	}
+	obj, err := interp.lookupObject(args[2])
+	if err != nil {
+		return nil, err
+	}
+	prefix := obj.ns + "::"
+	var names []string
+	for key, vr := range interp.globals {
+		name := strings.TrimPrefix(key, prefix)
+		if name == key || vr.undefined() {
+			continue
+		}
+		if len(args) == 4 && !stringMatch(args[3].Runes(), []rune(name), false) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return NewListValue(stringsToValues(names)), nil
+}
+
+var infoClassSubcommands = []subcommand{
+	{"constructor", cmdInfoClassConstructor},
+	{"definition", cmdInfoClassDefinition},
+	{"destructor", cmdInfoClassDestructor},
+	{"filters", cmdInfoClassFilters},
+	{"forward", cmdInfoClassForward},
+	{"instances", cmdInfoClassInstances},
+	{"methods", cmdInfoClassMethods},
+	{"methodtype", cmdInfoClassMethodtype},
+	{"mixins", cmdInfoClassMixins},
+	{"subclasses", cmdInfoClassSubclasses},
+	{"superclasses", cmdInfoClassSuperclasses},
+	{"variables", cmdInfoClassVariables},
+}
+
+// info class subcommand class ?arg ...?
+//
+// This subcommand provides information about the class class.
+func cmdInfoClass(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 2, "subcommand ?arg ...?")
+	}
+	words := append([]*Value{NewStringValue("info class")}, args[2:]...)
+	return dispatchSubcommand(interp, words, infoClassSubcommands)
+}
+
+// classArg returns the class named by args[2], checking that args
+// has n words.
+func (interp *Interp) classArg(args []*Value, n int, message string) (*class, error) {
+	if len(args) != n {
+		return nil, wrongNumArgs(args, 2, message)
+	}
+	return interp.lookupClass(args[2])
+}
+
+// info class constructor class
+func cmdInfoClassConstructor(interp *Interp, args []*Value) (*Value, error) {
+	c, err := interp.classArg(args, 3, "className")
+	if err != nil {
+		return nil, err
+	}
+	if c.constructor == nil || c.constructor.proc == nil {
+		return emptyValue(), nil
+	}
+	proc := c.constructor.proc
+	return NewListValue([]*Value{proc.paramList(), proc.body}), nil
+}
+
+// info class definition class method
+func cmdInfoClassDefinition(interp *Interp, args []*Value) (*Value, error) {
+	c, err := interp.classArg(args, 4, "className methodName")
+	if err != nil {
+		return nil, err
+	}
+	return methodDefinition(c.methods, args[3])
+}
+
+// info class destructor class
+func cmdInfoClassDestructor(interp *Interp, args []*Value) (*Value, error) {
+	c, err := interp.classArg(args, 3, "className")
+	if err != nil {
+		return nil, err
+	}
+	if c.destructor == nil || c.destructor.proc == nil {
+		return emptyValue(), nil
+	}
+	return c.destructor.proc.body, nil
+}
+
+// info class filters class
+func cmdInfoClassFilters(interp *Interp, args []*Value) (*Value, error) {
+	c, err := interp.classArg(args, 3, "className")
+	if err != nil {
+		return nil, err
+	}
+	return NewListValue(stringsToValues(c.filters)), nil
+}
+
+// info class forward class method
+func cmdInfoClassForward(interp *Interp, args []*Value) (*Value, error) {
+	c, err := interp.classArg(args, 4, "className methodName")
+	if err != nil {
+		return nil, err
+	}
+	return methodForward(c.methods, args[3])
+}
+
+// info class instances class ?pattern?
+func cmdInfoClassInstances(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "className ?pattern?")
+	}
+	c, err := interp.lookupClass(args[2])
+	if err != nil {
+		return nil, err
+	}
+	return matchingObjects(c.instances, args[3:]), nil
+}
+
+// info class methods class ?-all? ?-private?
+//
+// This subcommand returns the names of the exported methods declared
+// by class, or also those that are unexported with -private. With
+// -all, the methods class inherits are included.
+func cmdInfoClassMethods(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 2, "className ?-option value ...?")
+	}
+	c, err := interp.lookupClass(args[2])
+	if err != nil {
+		return nil, err
+	}
+	all, private, err := parseMethodsOptions(args[3:])
+	if err != nil {
+		return nil, err
+	}
+	tables := []map[string]*method{c.methods}
+	if all {
+		tables = nil
+		for _, a := range c.ancestors() {
+			tables = append(tables, a.methods)
+		}
+	}
+	return NewListValue(stringsToValues(visibleMethods(tables, private))), nil
+}
+
+// info class methodtype class method
+func cmdInfoClassMethodtype(interp *Interp, args []*Value) (*Value, error) {
+	c, err := interp.classArg(args, 4, "className methodName")
+	if err != nil {
+		return nil, err
+	}
+	return methodTypeOf(c.methods, args[3])
+}
+
+// info class mixins class
+func cmdInfoClassMixins(interp *Interp, args []*Value) (*Value, error) {
+	c, err := interp.classArg(args, 3, "className")
+	if err != nil {
+		return nil, err
+	}
+	return classNames(c.mixins), nil
+}
+
+// info class subclasses class ?pattern?
+func cmdInfoClassSubclasses(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "className ?pattern?")
+	}
+	c, err := interp.lookupClass(args[2])
+	if err != nil {
+		return nil, err
+	}
+	objs := make([]*object, len(c.subclasses))
+	for i, sub := range c.subclasses {
+		objs[i] = sub.obj
+	}
+	return matchingObjects(objs, args[3:]), nil
+}
+
+// info class superclasses class
+func cmdInfoClassSuperclasses(interp *Interp, args []*Value) (*Value, error) {
+	c, err := interp.classArg(args, 3, "className")
+	if err != nil {
+		return nil, err
+	}
+	return classNames(c.superclasses), nil
+}
+
+// info class variables class
+func cmdInfoClassVariables(interp *Interp, args []*Value) (*Value, error) {
+	c, err := interp.classArg(args, 3, "className")
+	if err != nil {
+		return nil, err
+	}
+	return NewListValue(stringsToValues(c.vars)), nil
+}
@@ -0,0 +1,292 @@
+package gotcl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var packageSubcommands = []subcommand{
+	{"forget", cmdPackageForget},
+	{"ifneeded", cmdPackageIfneeded},
+	{"names", cmdPackageNames},
+	{"present", cmdPackagePresent},
+	{"provide", cmdPackageProvide},
+	{"require", cmdPackageRequire},
+	{"unknown", cmdPackageUnknown},
+	{"vcompare", cmdPackageVcompare},
+	{"versions", cmdPackageVersions},
+	{"vsatisfies", cmdPackageVsatisfies},
+}
+
+// package option ?arg arg ...?
+//
+// This command keeps a simple database of the packages available for
+// use by the current interpreter and how to load them into the
+// interpreter. It supports multiple versions of each package and
+// arranges for the correct version of a package to be loaded based
+// on what is needed by the application.
+//
+// Version numbers consist of one or more decimal numbers separated
+// by dots, such as 2 or 1.162 or 3.1.13.1; one of the dots may be
+// replaced by a or b to denote an alpha or beta release. A version
+// requirement is min, which admits versions from min up to but
+// excluding the next major version, min-, which admits any version
+// from min, or min-max, which admits versions from min up to but
+// excluding max.
+//
+// When a package is required that has no loading script for a
+// suitable version, the package unknown handler is invoked to look
+// for one. The default handler looks for Tcl modules in the module
+// paths of tcl::tm::path, and then evaluates the pkgIndex.tcl files
+// of the directories in auto_path, and of their immediate
+// subdirectories, with dir set to their directory; they are expected
+// to declare loading scripts with package ifneeded.
+func cmdPackage(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "subcommand ?arg ...?")
+	}
+	return dispatchSubcommand(interp, args, packageSubcommands)
+}
+
+// package forget ?package package ...?
+//
+// Removes all information about each specified package from this
+// interpreter, including information provided by both package
+// ifneeded and package provide.
+func cmdPackageForget(interp *Interp, args []*Value) (*Value, error) {
+	for _, name := range args[2:] {
+		delete(interp.packages, name.String())
+	}
+	return emptyValue(), nil
+}
+
+// package ifneeded package version ?script?
+//
+// This command typically appears only in system configuration
+// scripts to set up the package database. It indicates that a
+// particular version of a particular package is available if needed,
+// and that the package can be added to the interpreter by executing
+// script. If script is omitted, the script currently registered for
+// version of package is returned.
+func cmdPackageIfneeded(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 && len(args) != 5 {
+		return nil, wrongNumArgs(args, 2, "package version ?script?")
+	}
+	name, version := args[2].String(), args[3].String()
+	if _, err := parseVersion(version); err != nil {
+		return nil, err
+	}
+	if len(args) == 5 {
+		interp.pkg(name).ifneeded[version] = &pkgScript{script: args[4]}
+		return emptyValue(), nil
+	}
+	if p, ok := interp.packages[name]; ok {
+		if s, ok := p.ifneeded[version]; ok && s.script != nil {
+			return s.script, nil
+		}
+	}
+	return emptyValue(), nil
+}
+
+// package names
+//
+// Returns a list of the names of all packages in the interpreter for
+// which a version has been provided or for which a package ifneeded
+// script is available.
+func cmdPackageNames(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 2 {
+		return nil, wrongNumArgs(args, 2, "")
+	}
+	var names []string
+	for name, p := range interp.packages {
+		if p.version != "" || len(p.ifneeded) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return NewListValue(stringsToValues(names)), nil
+}
+
+// package present ?-exact? package ?requirement ...?
+//
+// This command is equivalent to package require except that it does
+// not try to load the package if it is not already loaded.
+func cmdPackagePresent(interp *Interp, args []*Value) (*Value, error) {
+	name, reqs, err := parseRequire(args)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := interp.packages[name]
+	if !ok || p.version == "" {
+		return nil, fmt.Errorf("package %s is not present", name)
+	}
+	if !satisfiesAny(p.version, reqs) {
+		return nil, fmt.Errorf("version conflict for package \"%s\": have %s, need %s", name, p.version, strings.Join(reqs, " "))
+	}
+	return NewStringValue(p.version), nil
+}
+
+// package provide package ?version?
+//
+// This command is invoked to indicate that version version of package
+// package is now present in the interpreter. It is typically invoked
+// once as part of an ifneeded script, and again by the package
+// itself when it is finally loaded. If the version argument is
+// omitted, then the command returns the version number that is
+// currently provided, or an empty string if no package provide
+// command has been invoked for package in this interpreter.
+func cmdPackageProvide(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "package ?version?")
+	}
+	name := args[2].String()
+	if len(args) == 3 {
+		if p, ok := interp.packages[name]; ok {
+			return NewStringValue(p.version), nil
+		}
+		return emptyValue(), nil
+	}
+	if err := interp.PackageProvide(name, args[3].String()); err != nil {
+		return nil, err
+	}
+	return emptyValue(), nil
+}
+
+// package require ?-exact? package ?requirement ...?
+//
+// This command is typically invoked by Tcl code that wishes to use a
+// particular version of a particular package. The arguments indicate
+// which package is wanted, and the command ensures that a suitable
+// version of the package is loaded into the interpreter. If the
+// command succeeds, it returns the version number that is loaded;
+// otherwise it generates an error.
+//
+// If a version of package has already been provided, it must satisfy
+// one of the requirements. Otherwise the highest version with an
+// ifneeded script that satisfies one of the requirements is chosen,
+// preferring stable versions to alpha and beta ones, and its script
+// is evaluated at global level; it must provide that version. With
+// -exact, only the version given is acceptable.
+func cmdPackageRequire(interp *Interp, args []*Value) (*Value, error) {
+	name, reqs, err := parseRequire(args)
+	if err != nil {
+		return nil, err
+	}
+	version, err := interp.requirePackage(name, reqs)
+	if err != nil {
+		return nil, err
+	}
+	return NewStringValue(version), nil
+}
+
+// parseRequire parses the arguments of package require and package
+// present, converting a version given with -exact into a requirement.
+func parseRequire(args []*Value) (string, []string, error) {
+	exact := len(args) > 2 && args[2].String() == "-exact"
+	switch {
+	case exact && len(args) != 5:
+		return "", nil, wrongNumArgs(args, 2, "-exact package version")
+	case len(args) < 3:
+		return "", nil, wrongNumArgs(args, 2, "?-exact? package ?requirement ...?")
+	}
+	if exact {
+		v := args[4].String()
+		if _, err := parseVersion(v); err != nil {
+			return "", nil, err
+		}
+		return args[3].String(), []string{v + "-" + v}, nil
+	}
+	reqs := valuesToStrings(args[3:])
+	for _, req := range reqs {
+		if err := checkRequirement(req); err != nil {
+			return "", nil, err
+		}
+	}
+	return args[2].String(), reqs, nil
+}
+
+// package unknown ?command?
+//
+// This command supplies a "last resort" command to invoke during
+// package require if no suitable version of a package can be found
+// in the package ifneeded database. If the command argument is
+// supplied, it contains the first part of a command; when the
+// command is invoked during a package require command, Tcl appends
+// one or more additional arguments giving the desired package name
+// and requirements. If command is an empty string, then the current
+// package unknown script is removed. If the command argument is not
+// specified, then the command returns the current package unknown
+// script.
+func cmdPackageUnknown(interp *Interp, args []*Value) (*Value, error) {
+	switch len(args) {
+	case 2:
+		if interp.pkgUnknown == nil {
+			return emptyValue(), nil
+		}
+		return interp.pkgUnknown, nil
+	case 3:
+		interp.pkgUnknown = args[2]
+		if args[2].String() == "" {
+			interp.pkgUnknown = nil
+		}
+		return emptyValue(), nil
+	}
+	return nil, wrongNumArgs(args, 2, "?command?")
+}
+
+// package vcompare version1 version2
+//
+// Compares the two version numbers given by version1 and version2.
+// Returns -1 if version1 is an earlier version than version2, 0 if
+// they are equal, and 1 if version1 is later than version2.
+func cmdPackageVcompare(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "version1 version2")
+	}
+	for _, v := range args[2:] {
+		if _, err := parseVersion(v.String()); err != nil {
+			return nil, err
+		}
+	}
+	return NewIntValue(int64(compareVersions(args[2].String(), args[3].String()))), nil
+}
+
+// package versions package
+//
+// Returns a list of all the version numbers of package for which
+// information has been provided by package ifneeded commands, in
+// increasing order.
+func cmdPackageVersions(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "package")
+	}
+	var versions []string
+	if p, ok := interp.packages[args[2].String()]; ok {
+		for v := range p.ifneeded {
+			versions = append(versions, v)
+		}
+	}
+	sortVersions(versions)
+	return NewListValue(stringsToValues(versions)), nil
+}
+
+// package vsatisfies version requirement ?requirement ...?
+//
+// Returns 1 if the version satisfies at least one of the given
+// requirements, and 0 otherwise.
+func cmdPackageVsatisfies(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 4 {
+		return nil, wrongNumArgs(args, 2, "version ?requirement ...?")
+	}
+	if _, err := parseVersion(args[2].String()); err != nil {
+		return nil, err
+	}
+	reqs := valuesToStrings(args[3:])
+	for _, req := range reqs {
+		if err := checkRequirement(req); err != nil {
+			return nil, err
+		}
+	}
+	return NewBoolValue(satisfiesAny(args[2].String(), reqs)), nil
+}
@@ -0,0 +1,229 @@
+package gotcl
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// proc name args body
+//
+// The proc command creates a new Tcl procedure named name, replacing
+// any existing command or procedure there may have been by that
+// name. Whenever the new command is invoked, the contents of body
+// will be executed by the Tcl interpreter. Args specifies the formal
+// arguments to the procedure. It consists of a list, possibly empty,
+// each of whose elements specifies one argument. Each argument
+// specifier is also a list with either one or two fields. If there
+// is only a single field in the specifier then it is the name of the
+// argument; if there are two fields, then the first is the argument
+// name and the second is its default value. If the last formal
+// argument has the name args, then it is assigned a list of all the
+// remaining actual arguments.
+func cmdProc(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 {
+		return nil, wrongNumArgs(args, 1, "name args body")
+	}
+	params, err := parseProcParams(args[2])
+	if err != nil {
+		return nil, err
+	}
+	proc := &procedure{params: params, body: args[3]}
+	interp.createCommand(&command{
+		name: commandName(args[1].String()),
+		fn: func(interp *Interp, args []*Value) (*Value, error) {
+			return interp.callProc(proc, args, nil)
+		},
+		proc: proc,
+		kind: "proc",
+	})
+	return emptyValue(), nil
+}
+
+// rename oldName newName
+//
+// Rename the command that used to be called oldName so that it is
+// now called newName. If newName is an empty string then oldName is
+// deleted. The rename and delete traces of the command are invoked.
+func cmdRename(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 1, "oldName newName")
+	}
+	var err error
+	if args[2].String() == "" {
+		err = interp.DeleteCommand(args[1].String())
+	} else {
+		err = interp.RenameCommand(args[1].String(), args[2].String())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return emptyValue(), nil
+}
+
+// returnCodes names the completion codes accepted by return -code.
+var returnCodes = []string{"ok", "error", "return", "break", "continue"}
+
+// parseReturnCode parses the value of the -code option of return.
+func parseReturnCode(v *Value) (Code, error) {
+	for i, name := range returnCodes {
+		if v.String() == name {
+			return Code(i), nil
+		}
+	}
+	if n, err := strconv.Atoi(v.String()); err == nil {
+		return Code(n), nil
+	}
+	return 0, fmt.Errorf("bad completion code \"%s\": must be ok, error, return, break, continue, or an integer", v)
+}
+
+// return ?result?
+// return ?-code code? ?result?
+// return ?option value ...? ?result?
+//
+// Return immediately from the current procedure (or top-level
+// command or source command), with result as the return value of
+// the procedure. If result is not specified, an empty string will be
+// returned as result.
+//
+// The -code option makes the procedure return with the completion
+// code code, which is one of ok, error, return, break, continue, or
+// an integer. The -level option, a non-negative integer defaulting
+// to 1, is the number of levels of the call stack to unwind before
+// the code takes effect; with -level 0, return itself completes with
+// code. Other options are accepted and ignored.
+func cmdReturn(interp *Interp, args []*Value) (*Value, error) {
+	code, level := CodeOK, 1
+	opts := args[1:]
+	if len(opts)%2 != 0 {
+		opts = opts[:len(opts)-1]
+	}
+	for i := 0; i < len(opts); i += 2 {
+		switch opts[i].String() {
+		case "-code":
+			c, err := parseReturnCode(opts[i+1])
+			if err != nil {
+				return nil, err
+			}
+			code = c
+		case "-level":
+			n, err := strconv.Atoi(opts[i+1].String())
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("bad -level value: expected non-negative integer but got \"%s\"", opts[i+1])
+			}
+			level = n
+		}
+	}
+	result := emptyValue()
+	if len(args[1:])%2 != 0 {
+		result = args[len(args)-1]
+	}
+	if level == 0 {
+		return completeReturn(result, code)
+	}
+	return nil, &codeError{code: CodeReturn, value: result, level: level, returnCode: code}
+}
+
+// tailcall command ?arg ...?
+//
+// The tailcall command replaces the currently executing procedure
+// with another command. The command, which will have arg ...
+// appended to it, is evaluated in the context of the caller of the
+// current procedure, once the current procedure's frame has been
+// discarded. Its result becomes the result of the procedure.
+//
+// Since the frame is discarded first, procedures calling each other
+// with tailcall may recurse without limit.
+func cmdTailcall(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "command ?arg ...?")
+	}
+	tc := &tailcallError{command: append([]*Value(nil), args[1:]...), method: interp.frame.method}
+	if interp.frame.proc == nil {
+		return nil, errors.New(tc.Error())
+	}
+	return nil, tc
+}
+
+// uplevel ?level? arg ?arg ...?
+//
+// All of the arg arguments are concatenated as if they had been
+// passed to concat; the result is then evaluated in the variable
+// context indicated by level. Uplevel returns the result of that
+// evaluation.
+//
+// If level is an integer then it gives a distance (up the procedure
+// calling stack) to move before executing the command. If level
+// consists of # followed by an integer then the level gives an
+// absolute level. If level is omitted then it defaults to 1.
+func cmdUplevel(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "?level? command ?arg ...?")
+	}
+	level, words := NewStringValue("1"), args[1:]
+	if isLevel(args[1].String()) {
+		if len(args) < 3 {
+			return nil, wrongNumArgs(args, 1, "?level? command ?arg ...?")
+		}
+		level, words = args[1], args[2:]
+	}
+	frame, err := interp.frameAtLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	saved := interp.frame
+	interp.frame = frame
+	defer func() { interp.frame = saved }()
+	return interp.evalScriptFrom([]rune(concatValues(words)), evalSource)
+}
+
+// apply func ?arg1 arg2 ...?
+//
+// The command apply applies the function func to the arguments arg1
+// arg2 ... and returns the result. The function func is a two
+// element list {args body} or a three element list {args body
+// namespace} (as if the list command had been used). The first
+// element args specifies the formal arguments to func, in the same
+// way as for proc. The body is executed in a new call frame, as the
+// body of a procedure would be.
+//
+// The parsed function is cached in the func value, so that applying
+// the same value again does not parse it again.
+func cmdApply(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "lambdaExpr ?arg ...?")
+	}
+	proc, words, err := lambdaCall(args)
+	if err != nil {
+		return nil, err
+	}
+	return interp.callProc(proc, words, nil)
+}
+
+// lambdaCall returns the procedure of the lambda applied by the
+// apply command args, and the words to bind it to.
+func lambdaCall(args []*Value) (*procedure, []*Value, error) {
+	proc, err := args[1].lambda()
+	if err != nil {
+		return nil, nil, err
+	}
+	// The procedure is invoked as "apply lambdaExpr", which is how
+	// it is shown in wrong # args errors.
+	words := make([]*Value, 0, len(args)-1)
+	words = append(words, NewListValue(args[:2:2]))
+	words = append(words, args[2:]...)
+	return proc, words, nil
+}
+
+// applyTarget resolves the apply command args to its lambda for
+// tailcall.
+func applyTarget(interp *Interp, args []*Value, method *methodContext) (*procedure, []*Value, *methodContext) {
+	if len(args) < 2 {
+		return nil, nil, nil
+	}
+	proc, words, err := lambdaCall(args)
+	if err != nil {
+		return nil, nil, nil
+	}
+	return proc, words, nil
+}
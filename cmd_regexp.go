@@ -0,0 +1,331 @@
+package gotcl
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	regexpOptions = []string{
+		"-all", "-about", "-indices", "-inline", "-expanded", "-line",
+		"-linestop", "-lineanchor", "-nocase", "-start", "--",
+	}
+	regsubOptions = []string{
+		"-all", "-command", "-expanded", "-line", "-linestop",
+		"-lineanchor", "-nocase", "-start", "--",
+	}
+)
+
+// regexpSwitches holds the switches shared by regexp and regsub.
+type regexpSwitches struct {
+	flags   regexpFlags
+	all     bool
+	about   bool
+	indices bool
+	inline  bool
+	command bool
+	start   *Value
+}
+
+// parseRegexpSwitches parses the switches of regexp or regsub from
+// table, returning the index of the first argument after them.
+func parseRegexpSwitches(args []*Value, table []string, usage string) (*regexpSwitches, int, error) {
+	s := &regexpSwitches{}
+	i := 1
+	for ; i < len(args); i++ {
+		if !strings.HasPrefix(args[i].String(), "-") {
+			break
+		}
+		idx, err := lookupIndex(table, "switch", args[i])
+		if err != nil {
+			return nil, 0, err
+		}
+		switch table[idx] {
+		case "-all":
+			s.all = true
+		case "-about":
+			s.about = true
+		case "-indices":
+			s.indices = true
+		case "-inline":
+			s.inline = true
+		case "-command":
+			s.command = true
+		case "-expanded":
+			s.flags |= reExpanded
+		case "-line":
+			s.flags |= reLine
+		case "-linestop":
+			s.flags |= reLineStop
+		case "-lineanchor":
+			s.flags |= reLineAnchor
+		case "-nocase":
+			s.flags |= reNocase
+		case "-start":
+			if i++; i >= len(args) {
+				return nil, 0, wrongNumArgs(args, 1, usage)
+			}
+			s.start = args[i]
+		case "--":
+			return s, i + 1, nil
+		}
+	}
+	return s, i, nil
+}
+
+// startOffset returns the offset given by -start into a string of
+// length n, clamped to the string.
+func (s *regexpSwitches) startOffset(n int) (int, error) {
+	if s.start == nil {
+		return 0, nil
+	}
+	offset, err := getIndex(s.start, n-1)
+	if err != nil {
+		return 0, err
+	}
+	return max(0, min(offset, n)), nil
+}
+
+// regexpNotBOL reports whether ^ must not match at offset in input
+// when matching resumes there.
+func regexpNotBOL(input []rune, offset int) bool {
+	return offset > 0 && (offset > len(input) || input[offset-1] != '\n')
+}
+
+// regexp ?switches? exp string ?matchVar? ?subMatchVar subMatchVar ...?
+//
+// Determines whether the regular expression exp matches part or all
+// of string and returns 1 if it does, 0 if it does not, unless
+// -inline is specified. If additional arguments are specified after
+// string then they are treated as the names of variables in which
+// to return information about which part(s) of string matched exp.
+// MatchVar will be set to the range of string that matched all of
+// exp. The first subMatchVar will contain the characters in string
+// that matched the leftmost parenthesized subexpression within exp,
+// the next subMatchVar will contain the characters that matched the
+// next parenthesized subexpression to the right in exp, and so on.
+//
+// The switches are -about, -expanded, -indices, -line, -linestop,
+// -lineanchor, -nocase, -all, -inline, -start index and --. Exp is an
+// advanced regular expression as described in re_syntax.
+func cmdRegexp(interp *Interp, args []*Value) (*Value, error) {
+	const usage = "?-option ...? exp string ?matchVar? ?subMatchVar ...?"
+	s, i, err := parseRegexpSwitches(args, regexpOptions, usage)
+	if err != nil {
+		return nil, err
+	}
+	if s.about && len(args)-i >= 1 {
+		re, err := args[i].regexp(s.flags)
+		if err != nil {
+			return nil, err
+		}
+		return NewListValue([]*Value{
+			NewIntValue(int64(re.nsub)),
+			NewListValue(stringsToValues(re.info.names())),
+		}), nil
+	}
+	if len(args)-i < 2 {
+		return nil, wrongNumArgs(args, 1, usage)
+	}
+	varNames := args[i+2:]
+	if s.inline && len(varNames) > 0 {
+		return nil, fmt.Errorf("regexp match variables not allowed when using -inline")
+	}
+	re, err := args[i].regexp(s.flags)
+	if err != nil {
+		return nil, err
+	}
+	input := args[i+1].Runes()
+	offset, err := s.startOffset(len(input))
+	if err != nil {
+		return nil, err
+	}
+
+	// The number of values reported for each match.
+	nvalues := len(varNames)
+	if s.inline {
+		nvalues = re.nsub + 1
+	}
+	var inline []*Value
+	numMatches := 0
+	for {
+		caps := re.exec(input[offset:], regexpNotBOL(input, offset))
+		if caps == nil {
+			break
+		}
+		numMatches++
+		values := make([]*Value, nvalues)
+		for j := range values {
+			start, end := -1, -1
+			if j <= re.nsub {
+				start, end = caps[2*j], caps[2*j+1]
+			}
+			switch {
+			case s.indices && start < 0:
+				values[j] = NewListValue([]*Value{NewIntValue(-1), NewIntValue(-1)})
+			case s.indices:
+				values[j] = NewListValue([]*Value{NewIntValue(int64(offset + start)), NewIntValue(int64(offset + end - 1))})
+			case start < 0:
+				values[j] = emptyValue()
+			default:
+				values[j] = newRunesValue(input[offset+start : offset+end])
+			}
+		}
+		if s.inline {
+			inline = append(inline, values...)
+		} else {
+			for j, name := range varNames {
+				if _, err := interp.setVar(name.String(), values[j]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if !s.all {
+			break
+		}
+		end := caps[1]
+		offset += end
+		if end == 0 {
+			offset++
+		}
+		if offset >= len(input) {
+			break
+		}
+	}
+	switch {
+	case s.inline:
+		return NewListValue(inline), nil
+	case s.all:
+		return NewIntValue(int64(numMatches)), nil
+	}
+	return NewBoolValue(numMatches > 0), nil
+}
+
+// regsub ?switches? exp string subSpec ?varName?
+//
+// This command matches the regular expression exp against string,
+// and either copies string to the variable whose name is given by
+// varName or returns string if varName is not present. If there is
+// a match, then while copying string the portion of string that
+// matched exp is replaced with subSpec. If subSpec contains a "&"
+// or "\0", then it is replaced in the substitution with the portion
+// of string that matched exp. If subSpec contains a "\n", where n
+// is a digit between 1 and 9, then it is replaced in the
+// substitution with the portion of string that matched the n'th
+// parenthesized subexpression of exp. If varName is given, the
+// number of matching ranges found is returned.
+//
+// The switches are -all, -command, -expanded, -line, -linestop,
+// -lineanchor, -nocase, -start index and --. With -command, subSpec
+// is a command prefix that is invoked with the matched substring
+// and submatches appended, and its result is substituted.
+func cmdRegsub(interp *Interp, args []*Value) (*Value, error) {
+	const usage = "?-option ...? exp string subSpec ?varName?"
+	s, i, err := parseRegexpSwitches(args, regsubOptions, usage)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(args) - i; n != 3 && n != 4 {
+		return nil, wrongNumArgs(args, 1, usage)
+	}
+	re, err := args[i].regexp(s.flags)
+	if err != nil {
+		return nil, err
+	}
+	input := args[i+1].Runes()
+	var prefix []*Value
+	if s.command {
+		if prefix, err = args[i+2].List(); err != nil {
+			return nil, err
+		}
+		if len(prefix) == 0 {
+			return nil, fmt.Errorf("command prefix must be a list of at least one element")
+		}
+	}
+	offset, err := s.startOffset(len(input))
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	b.WriteString(string(input[:offset]))
+	numMatches := 0
+	for offset <= len(input) {
+		caps := re.exec(input[offset:], regexpNotBOL(input, offset))
+		if caps == nil {
+			break
+		}
+		numMatches++
+		submatch := func(n int) []rune {
+			if n > re.nsub || caps[2*n] < 0 {
+				return nil
+			}
+			return input[offset+caps[2*n] : offset+caps[2*n+1]]
+		}
+		b.WriteString(string(input[offset : offset+caps[0]]))
+		if s.command {
+			words := append([]*Value(nil), prefix...)
+			for n := 0; n <= re.nsub; n++ {
+				words = append(words, newRunesValue(submatch(n)))
+			}
+			v, err := interp.invoke(words)
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString(v.String())
+		} else {
+			regsubSpec(&b, args[i+2].Runes(), submatch)
+		}
+		start, end := caps[0], caps[1]
+		offset += end
+		if end == 0 || start == end {
+			// Always consume at least one character so that
+			// empty matches do not loop forever.
+			if offset < len(input) {
+				b.WriteRune(input[offset])
+			}
+			offset++
+		}
+		// An empty match at the end of string is only made if
+		// it is the first.
+		if !s.all || offset >= len(input) {
+			break
+		}
+	}
+	var result *Value
+	if numMatches == 0 {
+		result = args[i+1]
+	} else {
+		if offset < len(input) {
+			b.WriteString(string(input[offset:]))
+		}
+		result = NewStringValue(b.String())
+	}
+	if len(args)-i == 4 {
+		if _, err := interp.setVar(args[i+3].String(), result); err != nil {
+			return nil, err
+		}
+		return NewIntValue(int64(numMatches)), nil
+	}
+	return result, nil
+}
+
+// regsubSpec writes the substitution spec to b, replacing & and \0
+// with the match and \1 to \9 with submatches.
+func regsubSpec(b *strings.Builder, spec []rune, submatch func(int) []rune) {
+	for i := 0; i < len(spec); i++ {
+		c := spec[i]
+		switch {
+		case c == '&':
+			b.WriteString(string(submatch(0)))
+		case c == '\\' && i+1 < len(spec) && isASCIIDigit(spec[i+1]):
+			i++
+			b.WriteString(string(submatch(int(spec[i] - '0'))))
+		case c == '\\' && i+1 < len(spec) && (spec[i+1] == '&' || spec[i+1] == '\\'):
+			i++
+			b.WriteRune(spec[i])
+		default:
+			b.WriteRune(c)
+		}
+	}
+}
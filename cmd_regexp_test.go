@@ -0,0 +1,153 @@
+package gotcl
+
+import (
+	"testing"
+)
+
+func TestRegexp(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `regexp {b+} abbbc`, result: "1"},
+		{script: `regexp {^b} abc`, result: "0"},
+		{script: `regexp {(\w+)@(\w+)\.com} {mail bob@example.com now} m user host`, result: "1"},
+		{script: `list $m $user $host`, result: "bob@example.com bob example"},
+		{script: `regexp -inline {(a)|(b)} b`, result: "b {} b"},
+		{script: `regexp -indices {(a)|(b)} xb m x y; list $m $x $y`, result: "{1 1} {-1 -1} {1 1}"},
+		{script: `regexp {(a)} a m s1 s2; list $m $s1 $s2`, result: "a a {}"},
+		{script: `regexp -inline -all {\d+} {a1 b22 c333}`, result: "1 22 333"},
+		{script: `regexp -all {\d+} {a1 b22 c333}`, result: "3"},
+		{script: `regexp -inline -all {(\w)(\d)} {a1 b2}`, result: "a1 a 1 b2 b 2"},
+		{script: `regexp -inline -all {x*} abc`, result: "{} {} {}"},
+		{script: `regexp -inline -start 2 {\w} abcd`, result: "c"},
+		{script: `regexp -inline -start 2 {^\w} abcd`, result: ""},
+		{script: `regexp -indices -inline -start 1 {\w+} {ab cd}`, result: "{1 1}"},
+		{script: `regexp -inline -start end {\w} abcd`, result: "d"},
+		{script: `regexp -nocase -inline {HELLO} {say hello}`, result: "hello"},
+		{script: `regexp -inline {(?i)[A-C]+} xaBcD`, result: "aBc"},
+		{script: `regexp -inline {a|ab} abc`, result: "ab"},
+		{script: `regexp -inline {(week|wee)(night|knights)} weeknights`, result: "weeknights wee knights"},
+		{script: `regexp -inline {a.*?b} axxbyyb`, result: "axxb"},
+		{script: `regexp -inline {a.*b} axxbyyb`, result: "axxbyyb"},
+		{script: `regexp -inline {x{2,3}} xxxxx`, result: "xxx"},
+		{script: `regexp -inline {x{2,3}?} xxxxx`, result: "xx"},
+		{script: `regexp -inline {x{2}y{1,}} axxyyy`, result: "xxyyy"},
+		{script: `regexp -inline {a{x}b} a{x}b`, result: "{a{x}b}"},
+		{script: `regexp -inline {(\w)\1} abccd`, result: "cc c"},
+		{script: `regexp -nocase -inline {(\w)\1} abcCd`, result: "cC c"},
+		{script: `regexp -inline {\mfoo\M} {xfoo foo foox}`, result: "foo"},
+		{script: `regexp -inline {\y\w\w} {x ab}`, result: "ab"},
+		{script: `regexp -inline {o\Y\w} {foo o}`, result: "oo"},
+		{script: `regexp -inline {[[:<:]]cat} concat.cat`, result: "cat"},
+		{script: `regexp -indices -inline {[[:<:]]cat} concat.cat`, result: "{7 9}"},
+		{script: `regexp -inline {\d+(?=px)} {12em 34px}`, result: "34"},
+		{script: `regexp -inline {\d+(?!px|\d)} {34px 56em}`, result: "56"},
+		{script: `regexp -inline {(?<=\$)\d+} {cost $42}`, result: "42"},
+		{script: `regexp -inline {(?<!\$)\m\d+} {$42 17}`, result: "17"},
+		{script: `regexp -inline {(?:ab)+} xababab`, result: "ababab"},
+		{script: `regexp -inline {[^a-c]+} abcdefabc`, result: "def"},
+		{script: `regexp -inline {[]a]+} {x]a]y}`, result: "{]a]}"},
+		{script: `regexp -inline {[a-]+} {x-a-y}`, result: "-a-"},
+		{script: `regexp -inline {[[:digit:][:upper:]]+} {abC12d}`, result: "C12"},
+		{script: `regexp -inline {[\d.]+} {v1.25!}`, result: "1.25"},
+		{script: `regexp -inline {[[.-.]]+} {a--b}`, result: "--"},
+		{script: `regexp -inline {\x41B\103\t} "ABC\t"`, result: "{ABC\t}"},
+		{script: `regexp -inline {\S+\s\D+} {12 abc 34}`, result: "{12 abc }"},
+		{script: `regexp -inline {***=a.b} {axb a.b}`, result: "a.b"},
+		{script: `regexp -inline {(?q)a+} {aa a+}`, result: "a+"},
+		{script: `regexp -expanded -inline { a b  # comment
+			c } xabcx`, result: "abc"},
+		{script: `regexp -inline {(?x) a \  b } {a b}`, result: "{a b}"},
+		{script: `regexp -inline {^b.*$} "a\nb\nc"`, result: ""},
+		{script: `regexp -line -inline {^b.*$} "a\nb\nc"`, result: "b"},
+		{script: `regexp -lineanchor -inline {^b.*$} "a\nb\nc"`, result: "{b\nc}"},
+		{script: `regexp -linestop -inline {a.*} "ab\nc"`, result: "ab"},
+		{script: `regexp -inline {a[^x]c} "a\nc"`, result: "{a\nc}"},
+		{script: `regexp -line -inline {a[^x]c} "a\nc"`, result: ""},
+		{script: `regexp -inline {\Aa|b\Z} bab`, result: "b"},
+		{script: `regexp -inline {(a*)*b} aaab`, result: "aaab aaa"},
+		{script: `regexp -inline {(a|b)*c} ababc`, result: "ababc b"},
+		{script: `regexp -inline {} abc`, result: "{}"},
+		{script: `regexp -about {(a)(b)\1}`, result: "2 REG_UBACKREF"},
+		{script: `regexp -about {a*}`, result: "0 REG_UEMPTYMATCH"},
+		{script: `regexp -about {a+?\d}`, result: "0 {REG_UNONPOSIX REG_ULOCALE REG_USHORTEST}"},
+		{script: `regexp -about {a{2}(?=b)}`, result: "0 {REG_ULOOKAHEAD REG_UBOUNDS REG_UNONPOSIX}"},
+		{script: `regexp -inline -- -a x-ay`, result: "-a"},
+		{script: `regexp -inline {a} b x`, err: `regexp match variables not allowed when using -inline`},
+		{script: `regexp -bogus a b`, err: `bad switch "-bogus": must be -all, -about, -indices, -inline, -expanded, -line, -linestop, -lineanchor, -nocase, -start, or --`},
+		{script: `regexp a`, err: `wrong # args: should be "regexp ?-option ...? exp string ?matchVar? ?subMatchVar ...?"`},
+		{script: `regexp -start`, err: `wrong # args: should be "regexp ?-option ...? exp string ?matchVar? ?subMatchVar ...?"`},
+		{script: `regexp {(a} a`, err: `couldn't compile regular expression pattern: parentheses () not balanced`},
+		{script: `regexp {a)} a`, err: `couldn't compile regular expression pattern: parentheses () not balanced`},
+		{script: `regexp {[a} a`, err: `couldn't compile regular expression pattern: brackets [] not balanced`},
+		{script: `regexp "a{1" a`, err: `couldn't compile regular expression pattern: braces {} not balanced`},
+		{script: `regexp {a{3,1}} a`, err: `couldn't compile regular expression pattern: invalid repetition count(s)`},
+		{script: `regexp {a{256}} a`, err: `couldn't compile regular expression pattern: invalid repetition count(s)`},
+		{script: `regexp {[z-a]} a`, err: `couldn't compile regular expression pattern: invalid character range`},
+		{script: `regexp {[a-c-e]} a`, err: `couldn't compile regular expression pattern: invalid character range`},
+		{script: `regexp {[a-c-]} -`, result: "1"},
+		{script: `regexp {[[:bogus:]]} a`, err: `couldn't compile regular expression pattern: invalid character class`},
+		{script: `regexp {\q} a`, err: `couldn't compile regular expression pattern: invalid escape \ sequence`},
+		{script: `regexp {(a)\2} a`, err: `couldn't compile regular expression pattern: invalid backreference number`},
+		{script: `regexp {(a)(?=\1)} a`, err: `couldn't compile regular expression pattern: invalid backreference number`},
+		{script: `regexp {*a} a`, err: `couldn't compile regular expression pattern: quantifier operand invalid`},
+		{script: `regexp {a**} a`, err: `couldn't compile regular expression pattern: quantifier operand invalid`},
+		{script: `regexp {^*} a`, err: `couldn't compile regular expression pattern: quantifier operand invalid`},
+		{script: `regexp {a(?i)b} a`, err: `couldn't compile regular expression pattern: quantifier operand invalid`},
+		{script: `regexp {(?z)a} a`, err: `couldn't compile regular expression pattern: invalid embedded option`},
+		{script: `regexp {(?b)a} a`, err: `couldn't compile regular expression pattern: basic regular expressions are not supported`},
+		{script: `regexp {[[.ab.]]} a`, err: `couldn't compile regular expression pattern: invalid collating element`},
+	})
+}
+
+func TestRegsub(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `regsub {b+} abbbcb X`, result: "aXcb"},
+		{script: `regsub -all {b+} abbbcb X`, result: "aXcX"},
+		{script: `regsub -all {(\w+)@(\w+)} {a@b c@d} {\2 at \1}`, result: "b at a d at c"},
+		{script: `regsub {\w+} {hello world} {<&> <\0> \& \\}`, result: `<hello> <hello> & \ world`},
+		{script: `regsub {(a)} abc {[\1\9\q]}`, result: `[a\q]bc`},
+		{script: `regsub -all {x*} abc -`, result: "-a-b-c"},
+		{script: `regsub -all {} abc -`, result: "-a-b-c"},
+		{script: `regsub -all {} {} -`, result: "-"},
+		{script: `regsub -all {b*} ab -`, result: "-a-"},
+		{script: `regsub -all {^} "a\nb" >`, result: ">a\nb"},
+		{script: `regsub -all -line {^} "a\nb" >`, result: ">a\n>b"},
+		{script: `regsub -start 2 -all a aaaa b`, result: "aabb"},
+		{script: `regsub -nocase -all {A} aAa x`, result: "xxx"},
+		{script: `regsub z abc x`, result: "abc"},
+		{script: `regsub -all {o} {foo boo} 0 out`, result: "4"},
+		{script: `set out`, result: "f00 b00"},
+		{script: `regsub z abc x out`, result: "0"},
+		{script: `set out`, result: "abc"},
+		{script: `regsub -all -command {\w+} {ab cd} {string toupper} out`, result: "2"},
+		{script: `set out`, result: "AB CD"},
+		{script: `regsub -all -command {\d} {a1 b2} {string cat <}`, result: "a<1 b<2"},
+		{script: `regsub -all -command {(\w)=(\w)} {k=v x=y} {string cat}`, result: "k=vkv x=yxy"},
+		{script: `regsub -command {\w+} {ab cd} {string repeat x}`, err: `expected integer but got "ab"`},
+		{script: `regsub -command a a {}`, err: `command prefix must be a list of at least one element`},
+		{script: `regsub -all -expanded { a # x
+		} banana _`, result: "b_n_n_"},
+		{script: `regsub -bogus a b c`, err: `bad switch "-bogus": must be -all, -command, -expanded, -line, -linestop, -lineanchor, -nocase, -start, or --`},
+		{script: `regsub a b`, err: `wrong # args: should be "regsub ?-option ...? exp string subSpec ?varName?"`},
+	})
+}
+
+func TestRegexpCache(t *testing.T) {
+	v := NewStringValue(`(\w+)`)
+	re1, err := v.regexp(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re2, _ := v.regexp(0)
+	if re1 != re2 {
+		t.Error("regexp was not cached")
+	}
+	re3, _ := v.regexp(reNocase)
+	if re3 == re1 {
+		t.Error("regexp with different flags was cached")
+	}
+	if v.String() != `(\w+)` {
+		t.Errorf("string changed to %q", v.String())
+	}
+}
@@ -0,0 +1,465 @@
+package gotcl
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// scanSpec is a conversion specifier of a scan format string.
+type scanSpec struct {
+	// position is the XPG3 position of the variable to assign, or
+	// zero when the specifier has none.
+	position int
+	suppress bool
+	width    int
+	// size is 'l' for 64-bit or 'L' for arbitrary-precision
+	// integers.
+	size byte
+	conv rune
+	// set holds the characters between the brackets of a %[...]
+	// conversion.
+	set []rune
+}
+
+// parseScanSpec parses the conversion specifier that follows the %
+// at format[i-1] and returns it with the index of the rest of the
+// format. A conv of % stands for a literal percent sign.
+func parseScanSpec(format []rune, i int) (*scanSpec, int, error) {
+	spec := &scanSpec{}
+	if i < len(format) && format[i] == '%' {
+		spec.conv = '%'
+		return spec, i + 1, nil
+	}
+	if i < len(format) && format[i] == '*' {
+		spec.suppress = true
+		i++
+	} else {
+		j := i
+		for j < len(format) && isASCIIDigit(format[j]) {
+			j++
+		}
+		if j > i && j < len(format) && format[j] == '$' {
+			n, err := strconv.Atoi(string(format[i:j]))
+			if err != nil || n < 1 {
+				return nil, 0, fmt.Errorf("\"%%n$\" argument index out of range")
+			}
+			spec.position = n
+			i = j + 1
+		}
+	}
+	for ; i < len(format) && isASCIIDigit(format[i]); i++ {
+		spec.width = spec.width*10 + int(format[i]-'0')
+	}
+	if i < len(format) {
+		switch format[i] {
+		case 'h':
+			i++
+		case 'l':
+			spec.size = 'l'
+			i++
+			if i < len(format) && format[i] == 'l' {
+				spec.size = 'L'
+				i++
+			}
+		case 'L':
+			spec.size = 'L'
+			i++
+		}
+	}
+	if i >= len(format) {
+		return nil, 0, fmt.Errorf("bad scan conversion character \"\"")
+	}
+	spec.conv = format[i]
+	i++
+	switch spec.conv {
+	case 'c':
+		if spec.width != 0 {
+			return nil, 0, fmt.Errorf("field width may not be specified in %%c conversion")
+		}
+	case 'n', 'd', 'i', 'u', 'o', 'x', 'X', 'b', 's', 'e', 'E', 'f', 'g', 'G':
+	case '[':
+		j := i
+		if j < len(format) && format[j] == '^' {
+			j++
+		}
+		if j < len(format) && format[j] == ']' {
+			j++
+		}
+		for j < len(format) && format[j] != ']' {
+			j++
+		}
+		if j >= len(format) {
+			return nil, 0, fmt.Errorf("unmatched [ in format string")
+		}
+		spec.set = format[i:j]
+		i = j + 1
+	default:
+		return nil, 0, fmt.Errorf("bad scan conversion character \"%c\"", spec.conv)
+	}
+	return spec, i, nil
+}
+
+// validateScanFormat checks format for errors and returns the number
+// of values it assigns. numVars is the number of variables given to
+// scan, or zero for inline mode.
+func validateScanFormat(format []rune, numVars int) (int, error) {
+	gotXpg, gotSequential := false, false
+	var assigned []int
+	objIndex := 0
+	for i := 0; i < len(format); {
+		if format[i] != '%' {
+			i++
+			continue
+		}
+		spec, next, err := parseScanSpec(format, i+1)
+		if err != nil {
+			return 0, err
+		}
+		i = next
+		if spec.conv == '%' || spec.suppress {
+			continue
+		}
+		if spec.position > 0 {
+			if gotSequential {
+				return 0, fmt.Errorf("cannot mix \"%%\" and \"%%n$\" conversion specifiers")
+			}
+			gotXpg = true
+			objIndex = spec.position - 1
+			if numVars > 0 && objIndex >= numVars {
+				return 0, fmt.Errorf("\"%%n$\" argument index out of range")
+			}
+		} else {
+			if gotXpg {
+				return 0, fmt.Errorf("cannot mix \"%%\" and \"%%n$\" conversion specifiers")
+			}
+			gotSequential = true
+			if numVars > 0 && objIndex >= numVars {
+				return 0, fmt.Errorf("different numbers of variable names and field specifiers")
+			}
+		}
+		for len(assigned) <= objIndex {
+			assigned = append(assigned, 0)
+		}
+		assigned[objIndex]++
+		if gotXpg && assigned[objIndex] > 1 {
+			return 0, fmt.Errorf("variable is assigned by multiple \"%%n$\" conversion specifiers")
+		}
+		objIndex++
+	}
+	total := len(assigned)
+	if numVars > 0 {
+		total = numVars
+	}
+	for i := 0; i < total; i++ {
+		if i >= len(assigned) || assigned[i] == 0 {
+			if gotXpg {
+				return 0, fmt.Errorf("variable is not assigned by any conversion specifiers")
+			}
+			return 0, fmt.Errorf("different numbers of variable names and field specifiers")
+		}
+	}
+	return total, nil
+}
+
+// scanInteger parses the longest prefix of field that is an integer
+// for the conversion conv and returns it with the number of runes it
+// takes up, or zero runes if field does not start with an integer.
+func scanInteger(field []rune, conv rune) (*big.Int, int) {
+	i := 0
+	neg := false
+	if i < len(field) && (field[i] == '+' || field[i] == '-') {
+		neg = field[i] == '-'
+		i++
+	}
+	base := 10
+	prefixes := "dD"
+	switch conv {
+	case 'o':
+		base, prefixes = 8, "oO"
+	case 'x', 'X':
+		base, prefixes = 16, "xX"
+	case 'b':
+		base, prefixes = 2, "bB"
+	case 'i':
+		prefixes = "xXoObBdD"
+	}
+	if i+2 < len(field) && field[i] == '0' && strings.ContainsRune(prefixes, field[i+1]) {
+		b := base
+		switch field[i+1] {
+		case 'x', 'X':
+			b = 16
+		case 'o', 'O':
+			b = 8
+		case 'b', 'B':
+			b = 2
+		case 'd', 'D':
+			b = 10
+		}
+		if field[i+2] < 128 && digitValue(byte(field[i+2])) < b {
+			base = b
+			i += 2
+		}
+	}
+	start := i
+	for i < len(field) && field[i] < 128 && digitValue(byte(field[i])) < base {
+		i++
+	}
+	if i == start {
+		return nil, 0
+	}
+	n, _ := new(big.Int).SetString(string(field[start:i]), base)
+	if neg {
+		n.Neg(n)
+	}
+	return n, i
+}
+
+// scanDouble parses the longest prefix of field that is a
+// floating-point number and returns it with the number of runes it
+// takes up, or zero runes if field does not start with a number.
+func scanDouble(field []rune) (float64, int) {
+	i := 0
+	if i < len(field) && (field[i] == '+' || field[i] == '-') {
+		i++
+	}
+	for _, word := range []string{"infinity", "inf", "nan"} {
+		if len(field)-i >= len(word) && strings.EqualFold(string(field[i:i+len(word)]), word) {
+			f, _ := strconv.ParseFloat(string(field[:i+len(word)]), 64)
+			return f, i + len(word)
+		}
+	}
+	digits := 0
+	for i < len(field) && isASCIIDigit(field[i]) {
+		i++
+		digits++
+	}
+	if i < len(field) && field[i] == '.' {
+		i++
+		for i < len(field) && isASCIIDigit(field[i]) {
+			i++
+			digits++
+		}
+	}
+	if digits == 0 {
+		return 0, 0
+	}
+	if i < len(field) && (field[i] == 'e' || field[i] == 'E') {
+		j := i + 1
+		if j < len(field) && (field[j] == '+' || field[j] == '-') {
+			j++
+		}
+		if j < len(field) && isASCIIDigit(field[j]) {
+			for j < len(field) && isASCIIDigit(field[j]) {
+				j++
+			}
+			i = j
+		}
+	}
+	f, _ := strconv.ParseFloat(string(field[:i]), 64)
+	return f, i
+}
+
+// inCharSet reports whether c is in the %[...] character set set.
+func inCharSet(set []rune, c rune) bool {
+	exclude := len(set) > 0 && set[0] == '^'
+	if exclude {
+		set = set[1:]
+	}
+	found := false
+	for i := 0; i < len(set) && !found; i++ {
+		if i+2 < len(set) && set[i+1] == '-' {
+			lo, hi := set[i], set[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			found = lo <= c && c <= hi
+			i += 2
+			continue
+		}
+		found = set[i] == c
+	}
+	return found != exclude
+}
+
+// scan string format ?varName varName ...?
+//
+// This command parses substrings from an input string in a fashion
+// similar to the ANSI C sscanf procedure and returns a count of the
+// number of conversions performed, or -1 if the end of the input
+// string is reached before any conversions have been performed. If
+// no varName variables are specified, then scan works in an inline
+// manner, returning the data that would otherwise be stored in the
+// variables as a list. In the inline case, an empty string is
+// returned when the end of the input string is reached before any
+// conversions have been performed.
+//
+// Each conversion specifier may contain an XPG3 position specifier
+// or a * to discard the converted value, a maximum field width, a
+// size modifier (l for 64-bit and ll or L for unlimited integers)
+// and one of the conversion characters d, i, u, o, x, X, b, c, s,
+// e, f, g, [chars], [^chars] or n.
+func cmdScan(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 1, "string format ?varName ...?")
+	}
+	input := args[1].Runes()
+	format := args[2].Runes()
+	varNames := args[3:]
+	total, err := validateScanFormat(format, len(varNames))
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]*Value, total)
+	objIndex := 0
+	nconversions := 0
+	underflow := false
+	si := 0
+scan:
+	for fi := 0; fi < len(format); {
+		c := format[fi]
+		if unicode.IsSpace(c) {
+			for si < len(input) && unicode.IsSpace(input[si]) {
+				si++
+			}
+			fi++
+			continue
+		}
+		var spec *scanSpec
+		if c == '%' {
+			spec, fi, _ = parseScanSpec(format, fi+1)
+		} else {
+			fi++
+		}
+		if c != '%' || spec.conv == '%' {
+			if si >= len(input) {
+				underflow = true
+				break
+			}
+			if input[si] != c {
+				break
+			}
+			si++
+			continue
+		}
+
+		var v *Value
+		if spec.conv == 'n' {
+			v = NewIntValue(int64(si))
+		} else {
+			if si >= len(input) {
+				underflow = true
+				break
+			}
+			if spec.conv != 'c' && spec.conv != '[' {
+				for si < len(input) && unicode.IsSpace(input[si]) {
+					si++
+				}
+				if si >= len(input) {
+					underflow = true
+					break
+				}
+			}
+			field := input[si:]
+			if spec.width > 0 && spec.width < len(field) {
+				field = field[:spec.width]
+			}
+			n := 0
+			switch spec.conv {
+			case 'c':
+				v, n = NewIntValue(int64(field[0])), 1
+			case 's':
+				for n < len(field) && !unicode.IsSpace(field[n]) {
+					n++
+				}
+				v = newRunesValue(field[:n])
+			case '[':
+				for n < len(field) && inCharSet(spec.set, field[n]) {
+					n++
+				}
+				v = newRunesValue(field[:n])
+			case 'e', 'E', 'f', 'g', 'G':
+				var f float64
+				f, n = scanDouble(field)
+				v = NewDoubleValue(f)
+			default:
+				var i *big.Int
+				i, n = scanInteger(field, spec.conv)
+				if n == 0 {
+					break
+				}
+				v, err = scanIntegerValue(i, spec)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if n == 0 {
+				break scan
+			}
+			si += n
+		}
+		if !spec.suppress {
+			if spec.position > 0 {
+				objIndex = spec.position - 1
+			}
+			values[objIndex] = v
+			objIndex++
+		}
+		nconversions++
+	}
+
+	if len(varNames) == 0 {
+		if underflow && nconversions == 0 {
+			return emptyValue(), nil
+		}
+		for i, v := range values {
+			if v == nil {
+				values[i] = emptyValue()
+			}
+		}
+		return NewListValue(values), nil
+	}
+	result := 0
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		if _, err := interp.setVar(varNames[i].String(), v); err != nil {
+			return nil, err
+		}
+		result++
+	}
+	if underflow && nconversions == 0 {
+		result = -1
+	}
+	return NewIntValue(int64(result)), nil
+}
+
+// scanIntegerValue converts an integer scanned by spec to the range
+// given by its size modifier. Without ll, integers saturate at the
+// 64-bit limits, and %u treats negative integers as unsigned.
+func scanIntegerValue(i *big.Int, spec *scanSpec) (*Value, error) {
+	if spec.size == 'L' {
+		if spec.conv == 'u' && i.Sign() < 0 {
+			return nil, fmt.Errorf("unsigned bignum scans are invalid")
+		}
+		return NewBigIntValue(i), nil
+	}
+	var n int64
+	switch {
+	case i.IsInt64():
+		n = i.Int64()
+	case i.Sign() < 0:
+		n = math.MinInt64
+	default:
+		n = math.MaxInt64
+	}
+	if spec.conv == 'u' && n < 0 {
+		return NewBigIntValue(new(big.Int).SetUint64(uint64(n))), nil
+	}
+	return NewIntValue(n), nil
+}
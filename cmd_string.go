@@ -0,0 +1,887 @@
+package gotcl
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var stringSubcommands = []subcommand{
+	{"cat", cmdStringCat},
+	{"compare", cmdStringCompare},
+	{"equal", cmdStringEqual},
+	{"first", cmdStringFirst},
+	{"index", cmdStringIndex},
+	{"is", cmdStringIs},
+	{"last", cmdStringLast},
+	{"length", cmdStringLength},
+	{"map", cmdStringMap},
+	{"match", cmdStringMatch},
+	{"range", cmdStringRange},
+	{"repeat", cmdStringRepeat},
+	{"replace", cmdStringReplace},
+	{"reverse", cmdStringReverse},
+	{"tolower", cmdStringToLower},
+	{"totitle", cmdStringToTitle},
+	{"toupper", cmdStringToUpper},
+	{"trim", cmdStringTrim},
+	{"trimleft", cmdStringTrimLeft},
+	{"trimright", cmdStringTrimRight},
+	{"wordend", cmdStringWordEnd},
+	{"wordstart", cmdStringWordStart},
+}
+
+// string option arg ?arg ...?
+//
+// Performs one of several string operations, depending on option.
+func cmdString(interp *Interp, args []*Value) (*Value, error) {
+	return dispatchSubcommand(interp, args, stringSubcommands)
+}
+
+// string cat ?string1? ?string2...?
+//
+// Concatenate the given strings just like placing them directly next
+// to each other and return the resulting compound string.
+func cmdStringCat(interp *Interp, args []*Value) (*Value, error) {
+	var b strings.Builder
+	for _, arg := range args[2:] {
+		b.WriteString(arg.String())
+	}
+	return NewStringValue(b.String()), nil
+}
+
+// parseCompareOptions parses the -nocase and -length options of
+// string compare and string equal.
+func parseCompareOptions(args []*Value, usage string) (bool, int, error) {
+	if len(args) < 4 {
+		return false, 0, wrongNumArgs(args, 2, usage)
+	}
+	nocase, length := false, -1
+	for i := 2; i < len(args)-2; i++ {
+		opt, err := lookupIndex([]string{"-length", "-nocase"}, "option", args[i])
+		if err != nil {
+			return false, 0, err
+		}
+		switch opt {
+		case 0:
+			if i+1 >= len(args)-2 {
+				return false, 0, wrongNumArgs(args, 2, usage)
+			}
+			i++
+			length, err = getInt(args[i])
+			if err != nil {
+				return false, 0, err
+			}
+		case 1:
+			nocase = true
+		}
+	}
+	return nocase, length, nil
+}
+
+// compareRunes compares a and b by code point, considering at most
+// length runes if length is not negative.
+func compareRunes(a, b []rune, nocase bool, length int) int {
+	if length >= 0 {
+		if len(a) > length {
+			a = a[:length]
+		}
+		if len(b) > length {
+			b = b[:length]
+		}
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		ca, cb := a[i], b[i]
+		if nocase {
+			ca, cb = unicode.ToLower(ca), unicode.ToLower(cb)
+		}
+		if ca != cb {
+			if ca < cb {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
+
+// string compare ?-nocase? ?-length length? string1 string2
+//
+// Perform a character-by-character comparison of strings string1
+// and string2. Returns -1, 0, or 1, depending on whether string1 is
+// lexicographically less than, equal to, or greater than string2.
+func cmdStringCompare(interp *Interp, args []*Value) (*Value, error) {
+	nocase, length, err := parseCompareOptions(args, "?-nocase? ?-length length? string1 string2")
+	if err != nil {
+		return nil, err
+	}
+	n := len(args)
+	return NewIntValue(int64(compareRunes(args[n-2].Runes(), args[n-1].Runes(), nocase, length))), nil
+}
+
+// string equal ?-nocase? ?-length length? string1 string2
+//
+// Perform a character-by-character comparison of strings string1
+// and string2. Returns 1 if string1 and string2 are identical, or 0
+// when not.
+func cmdStringEqual(interp *Interp, args []*Value) (*Value, error) {
+	nocase, length, err := parseCompareOptions(args, "?-nocase? ?-length length? string1 string2")
+	if err != nil {
+		return nil, err
+	}
+	n := len(args)
+	return NewBoolValue(compareRunes(args[n-2].Runes(), args[n-1].Runes(), nocase, length) == 0), nil
+}
+
+// indexRunes returns the index of the first occurrence of needle in
+// haystack at or after start, or -1.
+func indexRunes(haystack, needle []rune, start int) int {
+	if start < 0 {
+		start = 0
+	}
+	if len(needle) == 0 {
+		return -1
+	}
+	for i := start; i+len(needle) <= len(haystack); i++ {
+		if runesHavePrefix(haystack[i:], needle) {
+			return i
+		}
+	}
+	return -1
+}
+
+func runesHavePrefix(r, prefix []rune) bool {
+	if len(r) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if r[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// string first needleString haystackString ?startIndex?
+//
+// Search haystackString for a sequence of characters that exactly
+// match the characters in needleString. If found, return the index
+// of the first character in the first such match within
+// haystackString. If not found, return -1.
+func cmdStringFirst(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 && len(args) != 5 {
+		return nil, wrongNumArgs(args, 2, "needleString haystackString ?startIndex?")
+	}
+	haystack := args[3].Runes()
+	start := 0
+	if len(args) == 5 {
+		var err error
+		start, err = getIndex(args[4], len(haystack)-1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewIntValue(int64(indexRunes(haystack, args[2].Runes(), start))), nil
+}
+
+// string last needleString haystackString ?lastIndex?
+//
+// Search haystackString for a sequence of characters that exactly
+// match the characters in needleString. If found, return the index
+// of the first character in the last such match within
+// haystackString. If there is no match, then return -1.
+func cmdStringLast(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 && len(args) != 5 {
+		return nil, wrongNumArgs(args, 2, "needleString haystackString ?lastIndex?")
+	}
+	needle, haystack := args[2].Runes(), args[3].Runes()
+	last := len(haystack) - 1
+	if len(args) == 5 {
+		var err error
+		last, err = getIndex(args[4], len(haystack)-1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result := -1
+	if len(needle) > 0 {
+		for i := len(haystack) - len(needle); i >= 0; i-- {
+			if i <= last && runesHavePrefix(haystack[i:], needle) {
+				result = i
+				break
+			}
+		}
+	}
+	return NewIntValue(int64(result)), nil
+}
+
+// string index string charIndex
+//
+// Returns the charIndex'th character of the string argument. If
+// charIndex is less than 0 or greater than or equal to the length of
+// the string then this command returns an empty string.
+func cmdStringIndex(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "string charIndex")
+	}
+	r := args[2].Runes()
+	i, err := getIndex(args[3], len(r)-1)
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 || i >= len(r) {
+		return emptyValue(), nil
+	}
+	return newRunesValue(r[i : i+1]), nil
+}
+
+// string length string
+//
+// Returns a decimal string giving the number of characters in
+// string.
+func cmdStringLength(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "string")
+	}
+	return NewIntValue(int64(len(args[2].Runes()))), nil
+}
+
+// string map ?-nocase? mapping string
+//
+// Replaces substrings in string based on the key-value pairs in
+// mapping. Replacement is done in an ordered manner, so the key
+// appearing first in the list will be checked first, and so on.
+// string is only iterated over once, so earlier key replacements
+// will have no affect for later key matches.
+func cmdStringMap(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 && len(args) != 5 {
+		return nil, wrongNumArgs(args, 2, "?-nocase? charMap string")
+	}
+	nocase := false
+	if len(args) == 5 {
+		if _, err := lookupIndex([]string{"-nocase"}, "option", args[2]); err != nil {
+			return nil, err
+		}
+		nocase = true
+	}
+	mapping, err := args[len(args)-2].List()
+	if err != nil {
+		return nil, err
+	}
+	if len(mapping)%2 != 0 {
+		return nil, fmt.Errorf("char map list unbalanced")
+	}
+	keys := make([][]rune, 0, len(mapping)/2)
+	values := make([]string, 0, len(mapping)/2)
+	for i := 0; i < len(mapping); i += 2 {
+		key := mapping[i].Runes()
+		if nocase {
+			key = []rune(strings.ToLower(string(key)))
+		}
+		keys = append(keys, key)
+		values = append(values, mapping[i+1].String())
+	}
+	s := args[len(args)-1].Runes()
+	folded := s
+	if nocase {
+		folded = make([]rune, len(s))
+		for i, c := range s {
+			folded[i] = unicode.ToLower(c)
+		}
+	}
+	var b strings.Builder
+outer:
+	for i := 0; i < len(s); {
+		for k, key := range keys {
+			if len(key) > 0 && runesHavePrefix(folded[i:], key) {
+				b.WriteString(values[k])
+				i += len(key)
+				continue outer
+			}
+		}
+		b.WriteRune(s[i])
+		i++
+	}
+	return NewStringValue(b.String()), nil
+}
+
+// stringMatch reports whether str matches the glob-style pattern.
+// "*" matches any sequence of characters, "?" matches any single
+// character, "[chars]" matches any character in the set (which may
+// contain ranges such as a-z) and "\x" matches x literally.
+func stringMatch(pattern, str []rune, nocase bool) bool {
+	fold := func(c rune) rune {
+		if nocase {
+			return unicode.ToLower(c)
+		}
+		return c
+	}
+	p, s := 0, 0
+	for {
+		if p == len(pattern) {
+			return s == len(str)
+		}
+		c := pattern[p]
+		if c == '*' {
+			for p < len(pattern) && pattern[p] == '*' {
+				p++
+			}
+			if p == len(pattern) {
+				return true
+			}
+			for ; s <= len(str); s++ {
+				if stringMatch(pattern[p:], str[s:], nocase) {
+					return true
+				}
+			}
+			return false
+		}
+		if s == len(str) {
+			return false
+		}
+		switch c {
+		case '?':
+		case '[':
+			p++
+			ch := fold(str[s])
+			matched := false
+			for {
+				if p >= len(pattern) {
+					return false
+				}
+				if pattern[p] == ']' {
+					break
+				}
+				if pattern[p] == '\\' && p+1 < len(pattern) {
+					p++
+				}
+				start := fold(pattern[p])
+				p++
+				if p+1 < len(pattern) && pattern[p] == '-' && pattern[p+1] != ']' {
+					p++
+					if pattern[p] == '\\' && p+1 < len(pattern) {
+						p++
+					}
+					end := fold(pattern[p])
+					p++
+					if start > end {
+						start, end = end, start
+					}
+					if start <= ch && ch <= end {
+						matched = true
+					}
+					continue
+				}
+				if start == ch {
+					matched = true
+				}
+			}
+			if !matched {
+				return false
+			}
+		case '\\':
+			if p+1 < len(pattern) {
+				p++
+			}
+			if fold(pattern[p]) != fold(str[s]) {
+				return false
+			}
+		default:
+			if fold(c) != fold(str[s]) {
+				return false
+			}
+		}
+		p++
+		s++
+	}
+}
+
+// string match ?-nocase? pattern string
+//
+// See if pattern matches string; return 1 if it does, 0 if it
+// does not.
+func cmdStringMatch(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 && len(args) != 5 {
+		return nil, wrongNumArgs(args, 2, "?-nocase? pattern string")
+	}
+	nocase := false
+	if len(args) == 5 {
+		if _, err := lookupIndex([]string{"-nocase"}, "option", args[2]); err != nil {
+			return nil, err
+		}
+		nocase = true
+	}
+	return NewBoolValue(stringMatch(args[len(args)-2].Runes(), args[len(args)-1].Runes(), nocase)), nil
+}
+
+// string range string first last
+//
+// Returns a range of consecutive characters from string, starting
+// with the character whose index is first and ending with the
+// character whose index is last.
+func cmdStringRange(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 5 {
+		return nil, wrongNumArgs(args, 2, "string first last")
+	}
+	r := args[2].Runes()
+	first, err := getIndex(args[3], len(r)-1)
+	if err != nil {
+		return nil, err
+	}
+	last, err := getIndex(args[4], len(r)-1)
+	if err != nil {
+		return nil, err
+	}
+	if first < 0 {
+		first = 0
+	}
+	if last >= len(r) {
+		last = len(r) - 1
+	}
+	if first > last {
+		return emptyValue(), nil
+	}
+	return newRunesValue(r[first : last+1]), nil
+}
+
+// string repeat string count
+//
+// Returns a string consisting of string concatenated with itself
+// count times.
+func cmdStringRepeat(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "string count")
+	}
+	count, err := getInt(args[3])
+	if err != nil {
+		return nil, err
+	}
+	if count <= 0 {
+		return emptyValue(), nil
+	}
+	s := args[2].String()
+	if len(s) > 0 && count > (1<<31-1)/len(s) {
+		return nil, fmt.Errorf("result exceeds max size for a Tcl value (%d bytes)", 1<<31-1)
+	}
+	return NewStringValue(strings.Repeat(s, count)), nil
+}
+
+// string replace string first last ?newstring?
+//
+// Removes a range of consecutive characters from string, starting
+// with the character whose index is first and ending with the
+// character whose index is last. If newstring is specified, then it
+// is placed in the removed character range.
+func cmdStringReplace(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 5 && len(args) != 6 {
+		return nil, wrongNumArgs(args, 2, "string first last ?string?")
+	}
+	r := args[2].Runes()
+	first, err := getIndex(args[3], len(r)-1)
+	if err != nil {
+		return nil, err
+	}
+	last, err := getIndex(args[4], len(r)-1)
+	if err != nil {
+		return nil, err
+	}
+	if first < 0 {
+		first = 0
+	}
+	if last >= len(r) {
+		last = len(r) - 1
+	}
+	if first > last || first >= len(r) || last < 0 {
+		return args[2], nil
+	}
+	out := make([]rune, 0, len(r))
+	out = append(out, r[:first]...)
+	if len(args) == 6 {
+		out = append(out, args[5].Runes()...)
+	}
+	out = append(out, r[last+1:]...)
+	return newRunesValue(out), nil
+}
+
+// string reverse string
+//
+// Returns a string that has the same length as string but with its
+// characters in the reverse order.
+func cmdStringReverse(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "string")
+	}
+	r := args[2].Runes()
+	out := make([]rune, len(r))
+	for i, c := range r {
+		out[len(r)-1-i] = c
+	}
+	return newRunesValue(out), nil
+}
+
+// convertCase applies convert to the characters of args[2] between
+// the optional indices args[3] and args[4].
+func convertCase(args []*Value, convert func(r []rune)) (*Value, error) {
+	if len(args) < 3 || len(args) > 5 {
+		return nil, wrongNumArgs(args, 2, "string ?first? ?last?")
+	}
+	r := args[2].Runes()
+	first, last := 0, len(r)-1
+	if len(args) > 3 {
+		var err error
+		first, err = getIndex(args[3], len(r)-1)
+		if err != nil {
+			return nil, err
+		}
+		last = first
+		if len(args) > 4 {
+			last, err = getIndex(args[4], len(r)-1)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if first < 0 {
+		first = 0
+	}
+	if last >= len(r) {
+		last = len(r) - 1
+	}
+	if first > last {
+		return args[2], nil
+	}
+	out := make([]rune, len(r))
+	copy(out, r)
+	convert(out[first : last+1])
+	return newRunesValue(out), nil
+}
+
+// string tolower string ?first? ?last?
+//
+// Returns a value equal to string except that all upper (or title)
+// case letters have been converted to lower case.
+func cmdStringToLower(interp *Interp, args []*Value) (*Value, error) {
+	return convertCase(args, func(r []rune) {
+		for i, c := range r {
+			r[i] = unicode.ToLower(c)
+		}
+	})
+}
+
+// string toupper string ?first? ?last?
+//
+// Returns a value equal to string except that all lower (or title)
+// case letters have been converted to upper case.
+func cmdStringToUpper(interp *Interp, args []*Value) (*Value, error) {
+	return convertCase(args, func(r []rune) {
+		for i, c := range r {
+			r[i] = unicode.ToUpper(c)
+		}
+	})
+}
+
+// string totitle string ?first? ?last?
+//
+// Returns a value equal to string except that the first character in
+// string is converted to its Unicode title case variant (or upper
+// case if there is no title case variant) and the rest of the string
+// is converted to lower case.
+func cmdStringToTitle(interp *Interp, args []*Value) (*Value, error) {
+	return convertCase(args, func(r []rune) {
+		for i, c := range r {
+			if i == 0 {
+				r[i] = unicode.ToTitle(c)
+			} else {
+				r[i] = unicode.ToLower(c)
+			}
+		}
+	})
+}
+
+// defaultTrimChars are the characters removed by the trim commands
+// when no set of characters is given: Unicode white space and the
+// null character.
+const defaultTrimChars = " \t\n\v\f\r\u0000\u0085\u00a0\u1680\u180e" +
+	"\u2000\u2001\u2002\u2003\u2004\u2005\u2006\u2007\u2008\u2009\u200a\u200b" +
+	"\u2028\u2029\u202f\u205f\u3000\ufeff"
+
+func trimCommand(args []*Value, left, right bool) (*Value, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "string ?chars?")
+	}
+	chars := defaultTrimChars
+	if len(args) == 4 {
+		chars = args[3].String()
+	}
+	s := args[2].String()
+	if left {
+		s = strings.TrimLeft(s, chars)
+	}
+	if right {
+		s = strings.TrimRight(s, chars)
+	}
+	return NewStringValue(s), nil
+}
+
+// string trim string ?chars?
+//
+// Returns a value equal to string except that any leading or
+// trailing characters present in the string given by chars are
+// removed. If chars is not specified then white space is removed.
+func cmdStringTrim(interp *Interp, args []*Value) (*Value, error) {
+	return trimCommand(args, true, true)
+}
+
+// string trimleft string ?chars?
+//
+// Returns a value equal to string except that any leading characters
+// present in the string given by chars are removed.
+func cmdStringTrimLeft(interp *Interp, args []*Value) (*Value, error) {
+	return trimCommand(args, true, false)
+}
+
+// string trimright string ?chars?
+//
+// Returns a value equal to string except that any trailing
+// characters present in the string given by chars are removed.
+func cmdStringTrimRight(interp *Interp, args []*Value) (*Value, error) {
+	return trimCommand(args, false, true)
+}
+
+// isWordChar reports whether c is a word character: a letter, digit
+// or connector punctuation such as underscore.
+func isWordChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || unicode.Is(unicode.Pc, c)
+}
+
+// string wordend string charIndex
+//
+// Returns the index of the character just after the last one in the
+// word containing character charIndex of string.
+func cmdStringWordEnd(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "string index")
+	}
+	r := args[2].Runes()
+	idx, err := getIndex(args[3], len(r)-1)
+	if err != nil {
+		return nil, err
+	}
+	if idx < 0 {
+		idx = 0
+	}
+	cur := len(r)
+	if idx < len(r) {
+		for cur = idx; cur < len(r) && isWordChar(r[cur]); cur++ {
+		}
+		if cur == idx {
+			cur++
+		}
+	}
+	return NewIntValue(int64(cur)), nil
+}
+
+// string wordstart string charIndex
+//
+// Returns the index of the first character in the word containing
+// character charIndex of string.
+func cmdStringWordStart(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "string index")
+	}
+	r := args[2].Runes()
+	idx, err := getIndex(args[3], len(r)-1)
+	if err != nil {
+		return nil, err
+	}
+	if idx >= len(r) {
+		idx = len(r) - 1
+	}
+	cur := 0
+	if idx > 0 {
+		for cur = idx; cur >= 0 && isWordChar(r[cur]); cur-- {
+		}
+		if cur != idx {
+			cur++
+		}
+	}
+	return NewIntValue(int64(cur)), nil
+}
+
+var stringIsClasses = []string{
+	"alnum", "alpha", "ascii", "control", "boolean", "dict", "digit",
+	"double", "entier", "false", "graph", "integer", "list", "lower",
+	"print", "punct", "space", "true", "unicode", "upper",
+	"wideinteger", "wordchar", "xdigit",
+}
+
+// stringIsCharClasses maps the classes of string is that test each
+// character in turn to the test.
+var stringIsCharClasses = map[string]func(rune) bool{
+	"alnum": func(c rune) bool { return unicode.IsLetter(c) || unicode.Is(unicode.Nd, c) },
+	"alpha": unicode.IsLetter,
+	"ascii": func(c rune) bool { return c < utf8.RuneSelf },
+	"control": func(c rune) bool {
+		return unicode.In(c, unicode.Cc, unicode.Cf)
+	},
+	"digit": func(c rune) bool { return unicode.Is(unicode.Nd, c) },
+	"graph": func(c rune) bool { return unicode.IsGraphic(c) && !unicode.IsSpace(c) },
+	"lower": unicode.IsLower,
+	"print": func(c rune) bool { return unicode.IsGraphic(c) },
+	"punct": unicode.IsPunct,
+	"space": func(c rune) bool {
+		return unicode.IsSpace(c) || strings.ContainsRune("\u180e\u200b\u2060\ufeff", c)
+	},
+	"unicode": func(c rune) bool {
+		return c <= unicode.MaxRune && c&0xfffe != 0xfffe &&
+			!(0xd800 <= c && c <= 0xdfff) && !(0xfdd0 <= c && c <= 0xfdef)
+	},
+	"upper":    unicode.IsUpper,
+	"wordchar": isWordChar,
+	"xdigit": func(c rune) bool {
+		return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+	},
+}
+
+// string is class ?-strict? ?-failindex varname? string
+//
+// Returns 1 if string is a valid member of the specified character
+// class, otherwise returns 0. If -strict is specified, then an empty
+// string returns 0, otherwise an empty string will return 1 on any
+// class. If -failindex is specified, then if the function returns 0,
+// the index in the string where the class was no longer valid will
+// be stored in the variable named varname.
+func cmdStringIs(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 4 || len(args) > 7 {
+		return nil, wrongNumArgs(args, 2, "class ?-strict? ?-failindex var? str")
+	}
+	ci, err := lookupIndex(stringIsClasses, "class", args[2])
+	if err != nil {
+		return nil, err
+	}
+	class := stringIsClasses[ci]
+	strict, failVar := false, ""
+	for i := 3; i < len(args)-1; i++ {
+		opt, err := lookupIndex([]string{"-strict", "-failindex"}, "option", args[i])
+		if err != nil {
+			return nil, err
+		}
+		switch opt {
+		case 0:
+			strict = true
+		case 1:
+			if i+2 >= len(args) {
+				return nil, wrongNumArgs(args, 2, "class ?-strict? ?-failindex var? str")
+			}
+			i++
+			failVar = args[i].String()
+		}
+	}
+	str := args[len(args)-1]
+	s := str.String()
+	if s == "" {
+		if strict && failVar != "" {
+			if _, err := interp.setVar(failVar, NewIntValue(0)); err != nil {
+				return nil, err
+			}
+		}
+		return NewBoolValue(!strict), nil
+	}
+	result, failAt := stringIs(class, str)
+	if !result && failVar != "" {
+		if _, err := interp.setVar(failVar, NewIntValue(int64(failAt))); err != nil {
+			return nil, err
+		}
+	}
+	return NewBoolValue(result), nil
+}
+
+// stringIs reports whether the non-empty str is a member of class,
+// and if not, the index of the character at which it failed to be
+// one.
+func stringIs(class string, str *Value) (bool, int) {
+	s := str.String()
+	if test, ok := stringIsCharClasses[class]; ok {
+		for i, c := range str.Runes() {
+			if !test(c) {
+				return false, i
+			}
+		}
+		return true, 0
+	}
+	switch class {
+	case "boolean", "true", "false":
+		b, ok := parseBoolean(s)
+		switch {
+		case !ok:
+			return false, 0
+		case class == "true":
+			return b, 0
+		case class == "false":
+			return !b, 0
+		}
+		return true, 0
+	case "integer", "entier", "wideinteger":
+		i, pos, ok := parseInteger(s)
+		if !ok {
+			return false, utf8.RuneCountInString(s[:pos])
+		}
+		if class == "wideinteger" && !i.IsInt64() {
+			return false, 0
+		}
+		return true, 0
+	case "double":
+		if _, ok := parseDouble(s); ok {
+			return true, 0
+		}
+		return false, doubleFailIndex(s)
+	case "list", "dict":
+		elems, err := parseList(str.Runes())
+		if err != nil {
+			return false, listFailIndex(str.Runes())
+		}
+		if class == "dict" && len(elems)%2 != 0 {
+			return false, len(str.Runes())
+		}
+		return true, 0
+	}
+	return false, 0
+}
+
+// doubleFailIndex returns the length of the longest prefix of s that
+// is a valid floating-point number.
+func doubleFailIndex(s string) int {
+	r := []rune(s)
+	for n := len(r) - 1; n > 0; n-- {
+		if _, ok := parseDouble(string(r[:n])); ok {
+			return n
+		}
+	}
+	return 0
+}
+
+// listFailIndex returns the index at which the element of r that is
+// not a valid list element starts.
+func listFailIndex(r []rune) int {
+	idx := 0
+	for {
+		for idx < len(r) && isListSpace(r[idx]) {
+			idx++
+		}
+		if idx >= len(r) {
+			return idx
+		}
+		_, size, err := parseListElement(r[idx:])
+		if err != nil {
+			return idx
+		}
+		idx += size
+	}
+}
@@ -0,0 +1,108 @@
+package gotcl
+
+import (
+	"testing"
+)
+
+func TestStringCommand(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `string length héllo`, result: "5"},
+		{script: `string length {}`, result: "0"},
+		{script: `string index héllo 1`, result: "é"},
+		{script: `string index abcde end`, result: "e"},
+		{script: `string index abcde end-1`, result: "d"},
+		{script: `string index abcde 1+2`, result: "d"},
+		{script: `string index abcde 4-3`, result: "b"},
+		{script: `string index abcde 10`, result: ""},
+		{script: `string index abcde foo`, err: `bad index "foo": must be integer?[+-]integer? or end?[+-]integer?`},
+		{script: `string range 日本語テキスト 1 end-2`, result: "本語テキ"},
+		{script: `string range abc -5 100`, result: "abc"},
+		{script: `string range abc 2 1`, result: ""},
+		{script: `string first b abcabc`, result: "1"},
+		{script: `string first b abcabc 2`, result: "4"},
+		{script: `string first x abc`, result: "-1"},
+		{script: `string last b abcabc`, result: "4"},
+		{script: `string last b abcabc 3`, result: "1"},
+		{script: `string compare abc abd`, result: "-1"},
+		{script: `string compare -nocase ABC abc`, result: "0"},
+		{script: `string compare -length 2 abc abd`, result: "0"},
+		{script: `string compare b a`, result: "1"},
+		{script: `string equal abc abc`, result: "1"},
+		{script: `string equal -nocase ÄBC äbc`, result: "1"},
+		{script: `string equal -length 3 abcx abcy`, result: "1"},
+		{script: `string equal -bogus a b`, err: `bad option "-bogus": must be -length or -nocase`},
+		{script: `string equal a`, err: `wrong # args: should be "string equal ?-nocase? ?-length length? string1 string2"`},
+		{script: `string match a*c abbbc`, result: "1"},
+		{script: `string match {a?[x-z]} abz`, result: "1"},
+		{script: `string match {a[z-x]} ay`, result: "1"},
+		{script: `string match {*\*} abc*`, result: "1"},
+		{script: `string match {*\*} abc`, result: "0"},
+		{script: `string match -nocase A* abc`, result: "1"},
+		{script: `string match ?? é1`, result: "1"},
+		{script: `string map {abc 1 ab 2 a 3 1 0} 1abcaababcabababc`, result: "01321221"},
+		{script: `string map -nocase {A x} aAb`, result: "xxb"},
+		{script: `string map {a} b`, err: `char map list unbalanced`},
+		{script: `string repeat ab 3`, result: "ababab"},
+		{script: `string repeat ab 0`, result: ""},
+		{script: `string reverse héllo`, result: "olléh"},
+		{script: `string replace abcdef 1 2`, result: "adef"},
+		{script: `string replace abcdef 1 2 XY`, result: "aXYdef"},
+		{script: `string replace abcdef 4 1 XY`, result: "abcdef"},
+		{script: `string toupper straße`, result: "STRAßE"},
+		{script: `string tolower ABC 1`, result: "AbC"},
+		{script: `string toupper abc 1 end`, result: "aBC"},
+		{script: `string totitle hELLO`, result: "Hello"},
+		{script: `string trim "  abc  "`, result: "abc"},
+		{script: `string trim xxabcxx x`, result: "abc"},
+		{script: `string trimleft xxabcxx x`, result: "abcxx"},
+		{script: `string trimright xxabcxx x`, result: "xxabc"},
+		{script: `string cat a b c`, result: "abc"},
+		{script: `string cat`, result: ""},
+		{script: `string wordstart {hello world} 8`, result: "6"},
+		{script: `string wordend {hello world} 1`, result: "5"},
+		{script: `string wordend {hello world} 5`, result: "6"},
+		{script: `string bogus`, err: `unknown or ambiguous subcommand "bogus": must be cat, compare, equal, first, index, is, last, length, map, match, range, repeat, replace, reverse, tolower, totitle, toupper, trim, trimleft, trimright, wordend, or wordstart`},
+		{script: `string len abc`, result: "3"},
+	})
+}
+
+func TestStringIs(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `string is integer 42`, result: "1"},
+		{script: `string is integer 0x2A`, result: "1"},
+		{script: `string is integer " 42 "`, result: "1"},
+		{script: `string is integer 123456789012345678901234567890`, result: "1"},
+		{script: `string is wideinteger 123456789012345678901234567890`, result: "0"},
+		{script: `string is integer 4.2`, result: "0"},
+		{script: `string is integer {}`, result: "1"},
+		{script: `string is integer -strict {}`, result: "0"},
+		{script: `string is double 4.2e3`, result: "1"},
+		{script: `string is double Inf`, result: "1"},
+		{script: `string is double 4.2x`, result: "0"},
+		{script: `string is boolean yes`, result: "1"},
+		{script: `string is boolean o`, result: "0"},
+		{script: `string is true On`, result: "1"},
+		{script: `string is false 0`, result: "1"},
+		{script: `string is alpha héllo`, result: "1"},
+		{script: `string is alpha abc1`, result: "0"},
+		{script: `string is digit ١٢٣`, result: "1"},
+		{script: `string is upper ABC`, result: "1"},
+		{script: `string is space " \t\n"`, result: "1"},
+		{script: `string is wordchar a_1`, result: "1"},
+		{script: `string is xdigit 0fF`, result: "1"},
+		{script: `string is ascii é`, result: "0"},
+		{script: `string is list {a {b c} d}`, result: "1"},
+		{script: `string is list {a {b c}d}`, result: "0"},
+		{script: `string is dict {a 1 b 2}`, result: "1"},
+		{script: `string is dict {a 1 b}`, result: "0"},
+		{script: `string is alnum -failindex i ab-c`, result: "0"},
+		{script: `set i`, result: "2"},
+		{script: `string is integer -failindex i 12a`, result: "0"},
+		{script: `set i`, result: "2"},
+		{script: `string is list -failindex i {a "b"c d}`, result: "0"},
+		{script: `set i`, result: "2"},
+		{script: `string is bogus x`, err: `bad class "bogus": must be alnum, alpha, ascii, control, boolean, dict, digit, double, entier, false, graph, integer, list, lower, print, punct, space, true, unicode, upper, wideinteger, wordchar, or xdigit`},
+	})
+}
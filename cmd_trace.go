@@ -0,0 +1,166 @@
+package gotcl
+
+import (
+	"fmt"
+)
+
+var (
+	traceOptions = []string{"add", "info", "remove"}
+	traceTypes   = []string{"command", "execution", "variable"}
+
+	// traceTypeOps lists the operations of each type of trace, in
+	// the order used in error messages.
+	traceTypeOps = map[string][]string{
+		"command":   {"delete", "rename"},
+		"execution": {"enter", "leave", "enterstep", "leavestep"},
+		"variable":  {"array", "read", "unset", "write"},
+	}
+)
+
+// parseTraceOps parses the operation list v of a trace of type typ.
+func parseTraceOps(typ string, v *Value) (TraceOp, error) {
+	elems, err := v.List()
+	if err != nil {
+		return 0, err
+	}
+	names := traceTypeOps[typ]
+	if len(elems) == 0 {
+		return 0, fmt.Errorf("bad operation list \"\": must be one or more of %s", mustBe(names))
+	}
+	var ops TraceOp
+	for _, elem := range elems {
+		i, err := lookupIndex(names, "operation", elem)
+		if err != nil {
+			return 0, err
+		}
+		for _, n := range traceOpNames {
+			if n.name == names[i] {
+				ops |= n.op
+			}
+		}
+	}
+	return ops, nil
+}
+
+// trace option ?arg arg ...?
+//
+// This command causes Tcl commands to be executed whenever certain
+// operations are invoked.
+//
+// trace add type name ops command
+//
+// Where type is command, execution, or variable, arranges for
+// command to be executed whenever one of the operations in the list
+// ops is performed on the command or variable name.
+//
+// trace remove type name opList command
+//
+// Removes the trace with the given operations and command, if there
+// is one.
+//
+// trace info type name
+//
+// Returns a list containing one element for each trace of the given
+// type currently set on name. Each element is a list of two
+// elements: the operations and the command of the trace.
+func cmdTrace(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "option ?arg ...?")
+	}
+	opt, err := lookupIndex(traceOptions, "option", args[1])
+	if err != nil {
+		return nil, err
+	}
+	option := traceOptions[opt]
+	switch option {
+	case "add", "remove":
+		if len(args) != 6 {
+			return nil, wrongNumArgs(args, 2, "type name opList command")
+		}
+	case "info":
+		if len(args) != 4 {
+			return nil, wrongNumArgs(args, 2, "type name")
+		}
+	}
+	typ, err := lookupIndex(traceTypes, "option", args[2])
+	if err != nil {
+		return nil, err
+	}
+	kind := traceTypes[typ]
+	name := args[3].String()
+
+	// Find the list of traces to operate on.
+	var traces *[]*trace
+	if kind == "variable" {
+		vr, err := interp.traceTarget(name, option == "add")
+		if err != nil {
+			return nil, err
+		}
+		if vr != nil {
+			traces = &vr.traces
+		}
+	} else {
+		cmd, ok := interp.commands[commandName(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown command \"%s\"", name)
+		}
+		traces = &cmd.traces
+	}
+	mask := TraceRead | TraceWrite | TraceUnset | TraceArray
+	switch kind {
+	case "command":
+		mask = traceCommandOps
+	case "execution":
+		mask = traceExecutionOps
+	}
+
+	if option == "info" {
+		var out []*Value
+		if traces != nil {
+			for _, t := range *traces {
+				if t.fn == nil && t.ops&mask != 0 {
+					var ops []*Value
+					for _, n := range traceOpNames {
+						if t.ops&mask&n.op != 0 {
+							ops = append(ops, NewStringValue(n.name))
+						}
+					}
+					out = append(out, NewListValue([]*Value{NewListValue(ops), NewStringValue(t.command)}))
+				}
+			}
+		}
+		return NewListValue(out), nil
+	}
+	ops, err := parseTraceOps(kind, args[4])
+	if err != nil {
+		return nil, err
+	}
+	command := args[5].String()
+	if option == "add" {
+		*traces = append([]*trace{{ops: ops, command: command}}, *traces...)
+		return nil, nil
+	}
+	if traces == nil {
+		return nil, nil
+	}
+	for _, t := range *traces {
+		if t.fn == nil && t.ops == ops && t.command == command {
+			if kind == "variable" {
+				interp.removeVarTrace(name, t)
+			} else {
+				*traces = removeTrace(*traces, t)
+			}
+			break
+		}
+	}
+	return nil, nil
+}
+
+func removeTrace(traces []*trace, t *trace) []*trace {
+	for i, tt := range traces {
+		if tt == t {
+			return append(traces[:i:i], traces[i+1:]...)
+		}
+	}
+	return traces
+}
@@ -0,0 +1,98 @@
+package gotcl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVariableTraces(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `set log {}; trace add variable x {read write unset} {lappend log}`, result: ""},
+		{script: `set x 1; set x; unset x; set log`, result: "x {} write x {} read x {} unset"},
+		{script: `trace info variable x`, result: ""},
+		{script: `set log {}; trace add variable a write {lappend log}; set a(k) v; set log`, result: "a k write"},
+		{script: `set log {}; trace add variable a(j) read {lappend log}; set a(j) 1; set a(j); set a(k); set log`, result: "a j write a j read"},
+		{script: `set log {}; trace add variable a array {lappend log}; array names a; set log`, result: "a {} array"},
+		{script: `trace info variable a`, result: "{array {lappend log}} {write {lappend log}}"},
+		{script: `trace remove variable a array {lappend log}; trace info variable a`, result: "{write {lappend log}}"},
+		{script: `trace add variable a unset {lappend log}; set log {}; unset a; set log`, result: "a {} unset"},
+		{script: `trace add variable y read {set y traced; string cat}; set y`, result: "traced"},
+		{script: `trace add variable z write {string bogus}; set z 1`, err: `can't set "z": unknown or ambiguous subcommand "bogus": must be cat, compare, equal, first, index, is, last, length, map, match, range, repeat, replace, reverse, tolower, totitle, toupper, trim, trimleft, trimright, wordend, or wordstart`},
+		{script: `set z`, result: "1"},
+		{script: `trace add variable r read {unset -nocomplain}; set r`, err: `can't read "r": no such variable`},
+		{script: `trace add variable w write {set w 2; string cat}; set w 1`, result: "2"},
+		{script: `set s 1; trace add variable s(x) read {lappend log}`, err: `can't trace "s(x)": variable isn't array`},
+		{script: `trace add variable q bogus {}`, err: `bad operation "bogus": must be array, read, unset, or write`},
+		{script: `trace add variable q {} {}`, err: `bad operation list "": must be one or more of array, read, unset, or write`},
+		{script: `trace bogus`, err: `bad option "bogus": must be add, info, or remove`},
+		{script: `trace add bogus x read {}`, err: `bad option "bogus": must be command, execution, or variable`},
+		{script: `trace add variable x read`, err: `wrong # args: should be "trace add type name opList command"`},
+	})
+}
+
+func TestTraceVar(t *testing.T) {
+	interp := NewInterp()
+	var got []string
+	untrace, err := interp.TraceVar("cfg", TraceWrite|TraceUnset, func(interp *Interp, name1, name2 string, op TraceOp) error {
+		v, _ := interp.GetVar(name1, name2)
+		got = append(got, name1+" "+name2+" "+op.String()+" "+v)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interp.Eval(`set cfg(a) 1; set cfg(b) 2; unset cfg(a)`); err != nil {
+		t.Fatal(err)
+	}
+	untrace()
+	if _, err := interp.Eval(`set cfg(c) 3`); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"cfg a write 1", "cfg b write 2", "cfg a unset "}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if _, err := interp.TraceVar("ro", TraceWrite, func(interp *Interp, name1, name2 string, op TraceOp) error {
+		return errors.New("variable is read-only")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interp.Eval(`set ro 1`); err == nil || err.Error() != `can't set "ro": variable is read-only` {
+		t.Errorf("set ro 1: got error %v", err)
+	}
+}
+
+func TestCommandTraces(t *testing.T) {
+	interp := NewInterp()
+	interp.CreateCommand("echo", echoCommand)
+	interp.CreateCommand("doomed", echoCommand)
+	runEvalTests(t, interp, []evalTest{
+		{script: `set log {}; trace add command doomed delete {lappend log}`, result: ""},
+		{script: `trace info command doomed`, result: "{delete {lappend log}}"},
+		{script: `trace add command nosuch delete {lappend log}`, err: `unknown command "nosuch"`},
+		{script: `trace add command echo bogus {}`, err: `bad operation "bogus": must be delete or rename`},
+		{script: `set log {}; trace add execution echo {enter leave} {lappend log}; echo a b`, result: "a b"},
+		{script: `set log`, result: "{echo a b} enter {echo a b} 0 {a b} leave"},
+		{script: `trace info execution echo`, result: "{{enter leave} {lappend log}}"},
+		{script: `trace remove execution echo {enter leave} {lappend log}; trace info execution echo`, result: ""},
+		{script: `trace add execution lmap enterstep {lappend log}; set log {}; lmap x {1 2} {echo $x}; set log`, result: "{echo 1} enterstep {echo 2} enterstep"},
+		{script: `trace add execution echo enter {string bogus}; echo a`, err: `unknown or ambiguous subcommand "bogus": must be cat, compare, equal, first, index, is, last, length, map, match, range, repeat, replace, reverse, tolower, totitle, toupper, trim, trimleft, trimright, wordend, or wordstart`},
+		{script: `trace add execution lmap bogus {}`, err: `bad operation "bogus": must be enter, leave, enterstep, or leavestep`},
+	})
+	if _, err := interp.Eval(`set log {}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.DeleteCommand("doomed"); err != nil {
+		t.Fatal(err)
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `set log`, result: "::doomed {} delete"},
+	})
+}
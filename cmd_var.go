@@ -0,0 +1,117 @@
+package gotcl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// set varName ?value?
+//
+// Returns the value of variable varName. If value is specified, then
+// set the value of varName to value, creating a new variable if one
+// does not already exist, and return its value.
+func cmdSet(interp *Interp, args []*Value) (*Value, error) {
+	switch len(args) {
+	case 2:
+		return interp.getVar(args[1].String())
+	case 3:
+		return interp.setVar(args[1].String(), args[2])
+	}
+	return nil, wrongNumArgs(args, 1, "varName ?newValue?")
+}
+
+// unset ?-nocomplain? ?--? ?name name name ...?
+//
+// This command removes one or more variables. If -nocomplain is
+// specified, any possible errors are eliminated.
+func cmdUnset(interp *Interp, args []*Value) (*Value, error) {
+	idx, nocomplain := 1, false
+	for ; idx < len(args); idx++ {
+		s := args[idx].String()
+		if s == "-nocomplain" {
+			nocomplain = true
+			continue
+		}
+		if s == "--" {
+			idx++
+		}
+		break
+	}
+	for _, name := range args[idx:] {
+		if err := interp.unsetVar(name.String()); err != nil && !nocomplain {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// upvar ?level? otherVar myVar ?otherVar myVar ...?
+//
+// This command arranges for one or more local variables in the
+// current procedure to refer to variables in an enclosing procedure
+// call or to global variables. Level may have any of the forms
+// permitted for the uplevel command, and may be omitted (it defaults
+// to 1). For each otherVar argument, upvar makes the variable by that
+// name in the procedure frame given by level accessible in the
+// current procedure by the name given in the corresponding myVar
+// argument.
+func cmdUpvar(interp *Interp, args []*Value) (*Value, error) {
+	level, names := NewStringValue("1"), args[1:]
+	if len(names)%2 != 0 && isLevel(names[0].String()) {
+		level, names = names[0], names[1:]
+	}
+	if len(names) < 2 || len(names)%2 != 0 {
+		return nil, wrongNumArgs(args, 1, "?level? otherVar localVar ?otherVar localVar ...?")
+	}
+	frame, err := interp.frameAtLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(names); i += 2 {
+		other, local := names[i].String(), names[i+1].String()
+		if _, _, isElem := splitVarName(local); isElem {
+			return nil, fmt.Errorf("bad variable name \"%s\": can't create a scalar variable that looks like an array element", local)
+		}
+		target, err := interp.frameVar(frame, other)
+		if err != nil {
+			return nil, err
+		}
+		if frame == interp.frame && commandName(other) == commandName(local) {
+			return nil, fmt.Errorf("can't upvar from variable to itself")
+		}
+		if err := interp.linkVar(local, target); err != nil {
+			return nil, err
+		}
+	}
+	return emptyValue(), nil
+}
+
+// global ?varname ...?
+//
+// This command has no effect unless executed in the context of a
+// proc body. If the global command is executed in the context of a
+// proc body, it creates local variables linked to the corresponding
+// global variables.
+func cmdGlobal(interp *Interp, args []*Value) (*Value, error) {
+	for _, arg := range args[1:] {
+		name := arg.String()
+		if _, _, isElem := splitVarName(name); isElem {
+			return nil, fmt.Errorf("can't define \"%s\": name refers to an element in an array", name)
+		}
+		if interp.frame == interp.globalFrame {
+			continue
+		}
+		target, err := interp.frameVar(interp.globalFrame, name)
+		if err != nil {
+			return nil, err
+		}
+		local := name
+		if i := strings.LastIndex(name, "::"); i >= 0 {
+			local = name[i+2:]
+		}
+		if err := interp.linkVar(local, target); err != nil {
+			return nil, err
+		}
+	}
+	return emptyValue(), nil
+}
@@ -0,0 +1,312 @@
+package gotcl
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// zipfsRoot is the directory under which zipfs mounts archives.
+const zipfsRoot = "//zipfs:/"
+
+var zipfsSubcommands = []subcommand{
+	{"canonical", cmdZipfsCanonical},
+	{"exists", cmdZipfsExists},
+	{"find", cmdZipfsFind},
+	{"info", cmdZipfsInfo},
+	{"list", cmdZipfsList},
+	{"mount", cmdZipfsMount},
+	{"mountdata", cmdZipfsMountdata},
+	{"root", cmdZipfsRoot},
+	{"unmount", cmdZipfsUnmount},
+}
+
+// zipfs subcommand ?arg ...?
+//
+// The zipfs command provides Tcl with the ability to mount the
+// contents of a ZIP archive file as a virtual file system. Archives
+// are mounted read-only under the zipfs root, //zipfs:/, where
+// source, open, glob and the file commands see their files. The host
+// may also mount any fs.FS or VFS anywhere with Interp.Mount and
+// Interp.MountVFS; those are not archives and zipfs list and zipfs
+// info ignore them. Creating archives and encrypted archives are not
+// supported.
+func cmdZipfs(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "subcommand ?arg ...?")
+	}
+	return dispatchSubcommand(interp, args, zipfsSubcommands)
+}
+
+// zipfsCanonical returns the name under the zipfs root of the file
+// name, taken relative to the mount point dir.
+func zipfsCanonical(dir, name string) string {
+	if strings.HasPrefix(name, zipfsRoot) {
+		return joinPath([]string{name})
+	}
+	if !strings.HasPrefix(dir, zipfsRoot) {
+		dir = zipfsRoot + strings.TrimLeft(dir, "/")
+	}
+	return joinPath([]string{dir, strings.TrimLeft(name, "/")})
+}
+
+// zipfsName returns the cleaned path p, as used for mount points, in
+// the form zipfs reports it.
+func zipfsName(p string) string {
+	if root := path.Clean(zipfsRoot); p == root || strings.HasPrefix(p, root+"/") {
+		return zipfsRoot + strings.TrimPrefix(strings.TrimPrefix(p, root), "/")
+	}
+	return p
+}
+
+// zipEntry returns the mounted archive holding the file name, if
+// there is one, and the name of the file in it.
+func (interp *Interp) zipEntry(name string) (*mount, string) {
+	p := path.Clean(zipfsCanonical(zipfsRoot, name))
+	for _, m := range interp.mounts.mounts {
+		if m.zip != nil && within(p, m.prefix) {
+			return m, fsPath(strings.TrimPrefix(p, m.prefix))
+		}
+	}
+	return nil, ""
+}
+
+// zipfs canonical ?mountpoint? filename
+//
+// This takes the name of a file, filename, and produces where it
+// would be mapped into a zipfs mount as its result. If specified,
+// mountpoint says within which mount the mapping will be done; if
+// omitted, the main root of the zipfs system is used.
+func cmdZipfsCanonical(interp *Interp, args []*Value) (*Value, error) {
+	switch len(args) {
+	case 3:
+		return NewStringValue(zipfsCanonical(zipfsRoot, args[2].String())), nil
+	case 4:
+		return NewStringValue(zipfsCanonical(args[2].String(), args[3].String())), nil
+	}
+	return nil, wrongNumArgs(args, 2, "?mountpoint? filename")
+}
+
+// zipfs exists filename
+//
+// Return 1 if the given filename exists in the mounted zipfs and 0
+// if it does not.
+func cmdZipfsExists(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "filename")
+	}
+	m, name := interp.zipEntry(args[2].String())
+	if m == nil {
+		return NewBoolValue(false), nil
+	}
+	_, err := fs.Stat(m.zip, name)
+	return NewBoolValue(err == nil), nil
+}
+
+// zipfs find directoryName
+//
+// Returns the list of paths under directory directoryName, which need
+// not be within a zipfs mounted archive, recursively.
+func cmdZipfsFind(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "directoryName")
+	}
+	var names []*Value
+	var find func(dir string)
+	find = func(dir string) {
+		entries, _ := interp.filesystem().ReadDir(dir)
+		for _, e := range entries {
+			name := joinPath([]string{dir, e.Name()})
+			names = append(names, NewStringValue(name))
+			if e.IsDir() {
+				find(name)
+			}
+		}
+	}
+	find(args[2].String())
+	return NewListValue(names), nil
+}
+
+// zipfs info file
+//
+// Return information about the given file in the mounted zipfs: a
+// list of the name of the ZIP archive that contains the file, the
+// size of the file after decompressions, the compressed size of the
+// file, and the offset of the compressed data in the ZIP archive.
+func cmdZipfsInfo(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "filename")
+	}
+	if m, name := interp.zipEntry(args[2].String()); m != nil {
+		for _, f := range m.zip.File {
+			if strings.TrimSuffix(f.Name, "/") != name {
+				continue
+			}
+			offset, err := f.DataOffset()
+			if err != nil {
+				return nil, err
+			}
+			return NewListValue([]*Value{
+				NewStringValue(m.archive),
+				NewIntValue(int64(f.UncompressedSize64)),
+				NewIntValue(int64(f.CompressedSize64)),
+				NewIntValue(offset),
+			}), nil
+		}
+	}
+	return nil, fmt.Errorf("path \"%s\" not found in any zipfs volume", args[2])
+}
+
+// zipfs list ?(-glob|-regexp)? ?pattern?
+//
+// If pattern is not specified, the command returns a list of files
+// across all zipfs mounted archives. If pattern is specified, only
+// those paths matching the pattern are returned. By default, or with
+// the -glob option, pattern is treated as a glob pattern and matching
+// is done as described for string match. With the -regexp option,
+// pattern is treated as a regular expression.
+func cmdZipfsList(interp *Interp, args []*Value) (*Value, error) {
+	match := func(string) bool { return true }
+	switch len(args) {
+	case 2:
+	case 3, 4:
+		mode, pattern := "-glob", args[len(args)-1]
+		if len(args) == 4 {
+			mode = args[2].String()
+		}
+		switch mode {
+		case "-glob":
+			match = func(s string) bool { return stringMatch(pattern.Runes(), []rune(s), false) }
+		case "-regexp":
+			re, err := pattern.regexp(0)
+			if err != nil {
+				return nil, err
+			}
+			match = re.matchString
+		default:
+			return nil, fmt.Errorf("bad option \"%s\": must be -glob or -regexp", mode)
+		}
+	default:
+		return nil, wrongNumArgs(args, 2, "?(-glob|-regexp)? ?pattern?")
+	}
+	var names []*Value
+	for _, m := range interp.mounts.mounts {
+		if m.zip == nil {
+			continue
+		}
+		for _, f := range m.zip.File {
+			name := joinPath([]string{zipfsName(m.prefix), strings.TrimSuffix(f.Name, "/")})
+			if match(name) {
+				names = append(names, NewStringValue(name))
+			}
+		}
+	}
+	return NewListValue(names), nil
+}
+
+// zipfs mount ?zipfile mountpoint ?password??
+//
+// The zipfs mount command mounts the ZIP archive zipfile as a Tcl
+// virtual file system at mountpoint, which is taken relative to the
+// zipfs root, and returns the path at which it was mounted. With a
+// single argument, it returns the archive mounted at mountpoint. With
+// no arguments, it returns a list of the mount points and archives of
+// all the mounted archives.
+func cmdZipfsMount(interp *Interp, args []*Value) (*Value, error) {
+	switch len(args) {
+	case 2:
+		var list []*Value
+		for _, m := range interp.mounts.mounts {
+			if m.zip != nil {
+				list = append(list, NewStringValue(zipfsName(m.prefix)), NewStringValue(m.archive))
+			}
+		}
+		return NewListValue(list), nil
+	case 3:
+		if m := interp.lookupMount(path.Clean(zipfsCanonical(zipfsRoot, args[2].String()))); m != nil && m.zip != nil {
+			return NewStringValue(m.archive), nil
+		}
+		return emptyValue(), nil
+	case 4:
+	case 5:
+		return nil, errors.New("encrypted archives are not supported")
+	default:
+		return nil, wrongNumArgs(args, 2, "?zipfile? ?mountpoint? ?password?")
+	}
+	archive := args[2].String()
+	f, err := interp.filesystem().OpenFile(archive, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open \"%s\": %s", archive, posixError(err))
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading \"%s\": %s", archive, posixError(err))
+	}
+	if name, err := interp.normalizePath(archive); err == nil {
+		archive = name
+	}
+	return interp.mountZipData(data, args[3].String(), archive)
+}
+
+// zipfs mountdata data mountpoint
+//
+// Mounts the ZIP archive content data as a Tcl virtual filesystem at
+// mountpoint.
+func cmdZipfsMountdata(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 4 {
+		return nil, wrongNumArgs(args, 2, "data mountpoint")
+	}
+	data, err := args[2].Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return interp.mountZipData(data, args[3].String(), "")
+}
+
+// mountZipData mounts the zip archive data, read from the file
+// archive if it is not empty, at mountpoint under the zipfs root.
+func (interp *Interp) mountZipData(data []byte, mountpoint, archive string) (*Value, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if errors.Is(err, zip.ErrFormat) {
+		return nil, errors.New("archive directory end signature not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	prefix := zipfsCanonical(zipfsRoot, mountpoint)
+	if err := interp.addMount(&mount{prefix: prefix, vfs: &fsVFS{zr}, archive: archive, zip: zr}); err != nil {
+		return nil, err
+	}
+	return NewStringValue(prefix), nil
+}
+
+// zipfs root
+//
+// Returns a constant string which indicates the mount point for
+// zipfs volumes for the current platform.
+func cmdZipfsRoot(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 2 {
+		return nil, wrongNumArgs(args, 2, "")
+	}
+	return NewStringValue(zipfsRoot), nil
+}
+
+// zipfs unmount mountpoint
+//
+// Unmounts a previously mounted ZIP archive mounted to mountpoint.
+func cmdZipfsUnmount(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 3 {
+		return nil, wrongNumArgs(args, 2, "mountpoint")
+	}
+	if err := interp.Unmount(zipfsCanonical(zipfsRoot, args[2].String())); err != nil {
+		return nil, err
+	}
+	return emptyValue(), nil
+}
@@ -0,0 +1,459 @@
+package gotcl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A CommandFunc implements a Tcl command. args holds the words of
+// the command after substitution; args[0] is the name the command
+// was invoked by.
+type CommandFunc func(interp *Interp, args []*Value) (*Value, error)
+
+type command struct {
+	name string
+	fn   CommandFunc
+	// proc is set for procedures defined by proc.
+	proc *procedure
+	// kind is the type of the command reported by info cmdtype, or
+	// empty for commands implemented in Go.
+	kind string
+	// tail, if set, resolves an invocation of the command in the
+	// method context method to the procedure it runs, so that
+	// tailcall can run the procedure in place; see tailTarget.
+	tail func(interp *Interp, args []*Value, method *methodContext) (*procedure, []*Value, *methodContext)
+	// deleteProc, if set, is called when the command is deleted.
+	deleteProc func()
+	// traces holds the command and execution traces on the
+	// command, most recently added first.
+	traces []*trace
+}
+
+type builtinCommand struct {
+	name string
+	fn   CommandFunc
+	// unsafe commands have access to the filesystem, processes,
+	// the network or the environment and are hidden in safe
+	// interpreters.
+	unsafe bool
+}
+
+var builtinCommands []builtinCommand
+
+// builtinCommands is assigned in init because the builtins refer to
+// it indirectly when creating child interpreters.
+func init() {
+	builtinCommands = []builtinCommand{
+		{"after", cmdAfter, false},
+		{"apply", cmdApply, false},
+		{"array", cmdArray, false},
+		{"auto_load", cmdAutoLoad, true},
+		{"binary", cmdBinary, false},
+		{"break", cmdBreak, false},
+		{"cd", cmdCd, true},
+		{"chan", cmdChan, false},
+		{"clock", cmdClock, false},
+		{"close", cmdClose, false},
+		{"concat", cmdConcat, false},
+		{"continue", cmdContinue, false},
+		{"coroutine", cmdCoroutine, false},
+		{"dict", cmdDict, false},
+		{"eof", cmdEOF, false},
+		{"exec", cmdExec, true},
+		{"fconfigure", cmdFconfigure, false},
+		{"fcopy", cmdFcopy, false},
+		{"file", cmdFile, true},
+		{"fileevent", cmdFileevent, false},
+		{"flush", cmdFlush, false},
+		{"format", cmdFormat, false},
+		{"gets", cmdGets, false},
+		{"glob", cmdGlob, true},
+		{"global", cmdGlobal, false},
+		{"info", cmdInfo, false},
+		{"interp", cmdInterp, false},
+		{"join", cmdJoin, false},
+		{"lappend", cmdLappend, false},
+		{"lassign", cmdLassign, false},
+		{"ledit", cmdLedit, false},
+		{"lindex", cmdLindex, false},
+		{"linsert", cmdLinsert, false},
+		{"list", cmdList, false},
+		{"llength", cmdLlength, false},
+		{"lmap", cmdLmap, false},
+		{"lpop", cmdLpop, false},
+		{"lrange", cmdLrange, false},
+		{"lrepeat", cmdLrepeat, false},
+		{"lreplace", cmdLreplace, false},
+		{"lreverse", cmdLreverse, false},
+		{"lsearch", cmdLsearch, false},
+		{"lseq", cmdLseq, false},
+		{"lset", cmdLset, false},
+		{"lsort", cmdLsort, false},
+		{"my", cmdMy, false},
+		{"next", cmdNext, false},
+		{"nextto", cmdNextto, false},
+		{"oo::define", cmdOODefine, false},
+		{"oo::objdefine", cmdOOObjdefine, false},
+		{"open", cmdOpen, true},
+		{"package", cmdPackage, false},
+		{"pid", cmdPid, true},
+		{"proc", cmdProc, false},
+		{"puts", cmdPuts, false},
+		{"pwd", cmdPwd, true},
+		{"read", cmdRead, false},
+		{"regexp", cmdRegexp, false},
+		{"regsub", cmdRegsub, false},
+		{"rename", cmdRename, false},
+		{"return", cmdReturn, false},
+		{"scan", cmdScan, false},
+		{"seek", cmdSeek, false},
+		{"self", cmdSelf, false},
+		{"set", cmdSet, false},
+		{"source", cmdSource, true},
+		{"split", cmdSplit, false},
+		{"string", cmdString, false},
+		{"tailcall", cmdTailcall, false},
+		{"tcl::tm::UnknownHandler", cmdTmUnknownHandler, false},
+		{"tcl::tm::path", cmdTmPath, false},
+		{"tclPkgUnknown", cmdTclPkgUnknown, false},
+		{"tell", cmdTell, false},
+		{"trace", cmdTrace, false},
+		{"unknown", cmdUnknown, false},
+		{"unset", cmdUnset, false},
+		{"update", cmdUpdate, false},
+		{"uplevel", cmdUplevel, false},
+		{"upvar", cmdUpvar, false},
+		{"vwait", cmdVwait, false},
+		{"yield", cmdYield, false},
+		{"yieldto", cmdYieldto, false},
+		{"zipfs", cmdZipfs, true},
+	}
+}
+
+func (interp *Interp) registerBuiltins() {
+	for _, b := range builtinCommands {
+		cmd := &command{name: b.name, fn: b.fn}
+		switch b.name {
+		case "apply":
+			cmd.tail = applyTarget
+		case "my":
+			cmd.tail = myTarget
+		}
+		if b.unsafe && interp.safe {
+			interp.hidden[b.name] = cmd
+			continue
+		}
+		interp.commands[b.name] = cmd
+	}
+}
+
+// commandName strips the global namespace qualifier from name.
+func commandName(name string) string {
+	return strings.TrimPrefix(name, "::")
+}
+
+// CreateCommand creates a command called name that is implemented
+// by fn, replacing any existing command of the same name.
+func (interp *Interp) CreateCommand(name string, fn CommandFunc) {
+	interp.createCommand(&command{name: commandName(name), fn: fn})
+}
+
+func (interp *Interp) createCommand(cmd *command) {
+	if old, ok := interp.commands[cmd.name]; ok {
+		interp.removeCommand(old)
+	}
+	interp.commands[cmd.name] = cmd
+}
+
+// removeCommand deletes the exposed command cmd, invoking its delete
+// traces and deleteProc.
+func (interp *Interp) removeCommand(cmd *command) {
+	delete(interp.commands, cmd.name)
+	interp.callCommandTraces(cmd, cmd.name, "", traceDelete)
+	if cmd.deleteProc != nil {
+		cmd.deleteProc()
+	}
+}
+
+// DeleteCommand deletes the command called name.
+func (interp *Interp) DeleteCommand(name string) error {
+	name = commandName(name)
+	cmd, ok := interp.commands[name]
+	if !ok {
+		return fmt.Errorf("can't delete \"%s\": command doesn't exist", name)
+	}
+	interp.removeCommand(cmd)
+	return nil
+}
+
+// RenameCommand renames the command oldName to newName, invoking its
+// rename traces.
+func (interp *Interp) RenameCommand(oldName, newName string) error {
+	oldName, newName = commandName(oldName), commandName(newName)
+	cmd, ok := interp.commands[oldName]
+	if !ok {
+		return fmt.Errorf("can't rename \"%s\": command doesn't exist", oldName)
+	}
+	if _, ok := interp.commands[newName]; ok {
+		return fmt.Errorf("can't rename to \"%s\": command already exists", newName)
+	}
+	delete(interp.commands, oldName)
+	cmd.name = newName
+	interp.commands[newName] = cmd
+	interp.callCommandTraces(cmd, oldName, newName, traceRename)
+	return nil
+}
+
+// CommandExists reports whether a command called name is exposed.
+func (interp *Interp) CommandExists(name string) bool {
+	_, ok := interp.commands[commandName(name)]
+	return ok
+}
+
+// HideCommand hides the exposed command cmdName, renaming it to
+// hiddenName. Hidden commands cannot be invoked by scripts running
+// in the interpreter, only by InvokeHidden or by the interp
+// invokehidden command of a trusted interpreter.
+func (interp *Interp) HideCommand(cmdName, hiddenName string) error {
+	if strings.Contains(hiddenName, "::") {
+		return fmt.Errorf("cannot use namespace qualifiers in hidden command token (rename)")
+	}
+	cmdName = commandName(cmdName)
+	cmd, ok := interp.commands[cmdName]
+	if !ok {
+		return fmt.Errorf("unknown command \"%s\"", cmdName)
+	}
+	if _, ok := interp.hidden[hiddenName]; ok {
+		return fmt.Errorf("hidden command named \"%s\" already exists", hiddenName)
+	}
+	delete(interp.commands, cmdName)
+	interp.hidden[hiddenName] = cmd
+	return nil
+}
+
+// ExposeCommand makes the hidden command hiddenName available to
+// scripts under the name cmdName.
+func (interp *Interp) ExposeCommand(hiddenName, cmdName string) error {
+	if strings.Contains(cmdName, "::") {
+		return fmt.Errorf("cannot expose to a namespace (use expose to toplevel, then rename)")
+	}
+	cmd, ok := interp.hidden[hiddenName]
+	if !ok {
+		return fmt.Errorf("unknown hidden command \"%s\"", hiddenName)
+	}
+	if _, ok := interp.commands[cmdName]; ok {
+		return fmt.Errorf("exposed command \"%s\" already exists", cmdName)
+	}
+	delete(interp.hidden, hiddenName)
+	interp.commands[cmdName] = cmd
+	return nil
+}
+
+// HiddenCommands returns the sorted names of the hidden commands.
+func (interp *Interp) HiddenCommands() []string {
+	names := make([]string, 0, len(interp.hidden))
+	for name := range interp.hidden {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InvokeHidden invokes the hidden command args[0] with the remaining
+// elements of args as its arguments.
+func (interp *Interp) InvokeHidden(args ...string) (string, error) {
+	v, err := interp.invokeHidden(stringsToValues(args))
+	if err != nil {
+		return "", err
+	}
+	return v.String(), nil
+}
+
+func (interp *Interp) invoke(args []*Value) (*Value, error) {
+	if len(args) == 0 {
+		return emptyValue(), nil
+	}
+	cmd, ok := interp.commands[commandName(args[0].String())]
+	if !ok {
+		return interp.invokeUnknown(args)
+	}
+	return interp.call(cmd, args)
+}
+
+// invokeUnknown invokes the unknown command with args, the words of a
+// command that does not exist.
+func (interp *Interp) invokeUnknown(args []*Value) (*Value, error) {
+	cmd, ok := interp.commands["unknown"]
+	if !ok || commandName(args[0].String()) == "unknown" {
+		return nil, fmt.Errorf("invalid command name \"%s\"", args[0])
+	}
+	words := make([]*Value, 0, len(args)+1)
+	words = append(words, NewStringValue("unknown"))
+	words = append(words, args...)
+	return interp.call(cmd, words)
+}
+
+func (interp *Interp) invokeHidden(args []*Value) (*Value, error) {
+	if len(args) == 0 {
+		return emptyValue(), nil
+	}
+	cmd, ok := interp.hidden[args[0].String()]
+	if !ok {
+		return nil, fmt.Errorf("invalid hidden command name \"%s\"", args[0])
+	}
+	return interp.call(cmd, args)
+}
+
+func (interp *Interp) call(cmd *command, args []*Value) (*Value, error) {
+	if interp.nesting >= maxCommandDepth {
+		return nil, errTooDeep
+	}
+	interp.nesting++
+	interp.cmdCount++
+	defer func() { interp.nesting-- }()
+	var v *Value
+	var err error
+	if interp.execTracing == 0 && (len(cmd.traces) > 0 || len(interp.stepTraces) > 0) {
+		v, err = interp.callTraced(cmd, args)
+	} else {
+		v, err = interp.callCommand(cmd, args)
+	}
+	if err != nil {
+		interp.logError(err, args)
+	} else {
+		interp.errorLogged = false
+	}
+	return v, err
+}
+
+// logError starts the error stack reported by info errorstack when
+// err is a new error raised by the command args.
+func (interp *Interp) logError(err error, args []*Value) {
+	if _, ok := err.(*tailcallError); ok || interp.errorLogged || errorCode(err) != CodeError {
+		return
+	}
+	interp.errorLogged = true
+	interp.errorStack = []*Value{NewStringValue("INNER"), NewListValue(append([]*Value(nil), args...))}
+	interp.globals["errorCode"] = &variable{value: errorCodeOf(err)}
+}
+
+func (interp *Interp) callCommand(cmd *command, args []*Value) (*Value, error) {
+	v, err := cmd.fn(interp, args)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		v = emptyValue()
+	}
+	return v, nil
+}
+
+// wrongNumArgs returns the standard error for a command invoked with
+// the wrong number of arguments, quoting the first n words of args
+// followed by message.
+func wrongNumArgs(args []*Value, n int, message string) error {
+	var b strings.Builder
+	for i := 0; i < n && i < len(args); i++ {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(args[i].String())
+	}
+	if message != "" {
+		if b.Len() > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(message)
+	}
+	return fmt.Errorf("wrong # args: should be \"%s\"", b.String())
+}
+
+// mustBe formats table as "a, b, or c" for use in error messages.
+func mustBe(table []string) string {
+	switch len(table) {
+	case 0:
+		return ""
+	case 1:
+		return table[0]
+	case 2:
+		return table[0] + " or " + table[1]
+	}
+	return strings.Join(table[:len(table)-1], ", ") + ", or " + table[len(table)-1]
+}
+
+// lookupIndex returns the index of v in table. Unique abbreviations
+// of the entries in table are accepted. what names the kind of
+// entry being looked up ("option", "subcommand", ...) for the error
+// message.
+func lookupIndex(table []string, what string, v *Value) (int, error) {
+	s := v.String()
+	found := -1
+	for i, name := range table {
+		if name == s {
+			return i, nil
+		}
+		if s != "" && strings.HasPrefix(name, s) {
+			if found >= 0 {
+				found = -2
+				continue
+			}
+			if found == -1 {
+				found = i
+			}
+		}
+	}
+	if found >= 0 {
+		return found, nil
+	}
+	adjective := "bad"
+	if found == -2 {
+		adjective = "ambiguous"
+	}
+	return 0, fmt.Errorf("%s %s \"%s\": must be %s", adjective, what, s, mustBe(table))
+}
+
+// A subcommand is one subcommand of an ensemble command such as
+// interp or string. Its CommandFunc receives all of the words of
+// the command, so args[1] is the subcommand name.
+type subcommand struct {
+	name string
+	fn   CommandFunc
+}
+
+func ensembleNames(subs []subcommand) []string {
+	names := make([]string, len(subs))
+	for i, sub := range subs {
+		names[i] = sub.name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dispatchSubcommand invokes the subcommand of subs named by args[1].
+func dispatchSubcommand(interp *Interp, args []*Value, subs []subcommand) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "subcommand ?arg ...?")
+	}
+	s := args[1].String()
+	var match *subcommand
+	ambiguous := false
+	for i := range subs {
+		sub := &subs[i]
+		if sub.name == s {
+			match, ambiguous = sub, false
+			break
+		}
+		if s != "" && strings.HasPrefix(sub.name, s) {
+			if match != nil {
+				ambiguous = true
+			}
+			match = sub
+		}
+	}
+	if match == nil || ambiguous {
+		return nil, fmt.Errorf("unknown or ambiguous subcommand \"%s\": must be %s",
+			s, mustBe(ensembleNames(subs)))
+	}
+	return match.fn(interp, args)
+}
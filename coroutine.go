@@ -0,0 +1,246 @@
+package gotcl
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A coroutine is a command whose execution can be suspended by yield
+// and resumed by invoking the coroutine command. Each coroutine runs
+// in its own goroutine, but only one of the goroutines of an
+// interpreter runs at a time: control is handed back and forth over
+// the in and out channels, so the interpreter is never used
+// concurrently.
+type coroutine struct {
+	cmd   *command
+	words []*Value
+	// frame, depth, nesting, cmdFrames and src hold the current
+	// frame, nesting depths, commands being executed and script
+	// source of the coroutine while it is suspended.
+	frame     *callFrame
+	depth     int
+	nesting   int
+	cmdFrames []cmdFrame
+	src       *scriptSource
+	in        chan coTransfer
+	out       chan coTransfer
+	// running is set while the coroutine has control. yieldto is
+	// set while it is suspended in yieldto rather than yield.
+	running bool
+	yieldto bool
+	started bool
+	done    bool
+	// deleted is set if the coroutine command is deleted while the
+	// coroutine is running, so that it unwinds at its next yield.
+	deleted bool
+}
+
+// A coTransfer passes control to or from a coroutine.
+type coTransfer struct {
+	value *Value
+	err   error
+	// done is set when the coroutine has finished, and kill when
+	// the coroutine must unwind because its command was deleted.
+	// yieldto holds the command to run in the resumer for yieldto.
+	done    bool
+	kill    bool
+	yieldto []*Value
+}
+
+// errCoroutineDeleted unwinds a coroutine whose command has been
+// deleted while it was suspended.
+var errCoroutineDeleted = errors.New("coroutine deleted")
+
+// coroutine name command ?arg ...?
+//
+// The coroutine command creates a new coroutine context (with
+// associated command) named name and executes that context by
+// calling command, passing in the other remaining arguments without
+// further interpretation. Once command returns normally or with an
+// exception (e.g., an error) the coroutine context name is deleted.
+//
+// Within the context, values may be generated as results by using
+// the yield command; if no yield is executed, the coroutine returns
+// the result of command. The result of the coroutine command is the
+// value of the first yield. The coroutine command name may then be
+// invoked to resume the context, and the value passed to it becomes
+// the result of the yield that suspended it.
+func cmdCoroutine(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 1, "name cmd ?arg ...?")
+	}
+	co := &coroutine{
+		words: append([]*Value(nil), args[2:]...),
+		frame: interp.globalFrame,
+		in:    make(chan coTransfer),
+		out:   make(chan coTransfer),
+	}
+	co.cmd = &command{
+		name: commandName(args[1].String()),
+		fn: func(interp *Interp, args []*Value) (*Value, error) {
+			return interp.resumeCoroutine(co, args)
+		},
+		kind: "coroutine",
+		deleteProc: func() {
+			interp.killCoroutine(co)
+		},
+	}
+	interp.createCommand(co.cmd)
+	return interp.transfer(co, coTransfer{})
+}
+
+// run is the body of the goroutine of co.
+func (co *coroutine) run(interp *Interp) {
+	t := <-co.in
+	if t.kill {
+		co.out <- coTransfer{done: true}
+		return
+	}
+	v, err := interp.invoke(co.words)
+	co.out <- coTransfer{value: v, err: err, done: true}
+}
+
+// transfer passes control to co with t and waits for it to yield or
+// finish, returning the value it yields or its result.
+func (interp *Interp) transfer(co *coroutine, t coTransfer) (*Value, error) {
+	if !co.started {
+		co.started = true
+		go co.run(interp)
+	}
+	caller := interp.coroutine
+	callerFrame, callerDepth, callerNesting, callerCmds, callerSrc := interp.frame, interp.depth, interp.nesting, interp.cmdFrames, interp.src
+	interp.coroutine = co
+	interp.frame, interp.depth, interp.nesting, interp.cmdFrames, interp.src = co.frame, co.depth, co.nesting, co.cmdFrames, co.src
+	co.running = true
+	co.in <- t
+	r := <-co.out
+	co.running = false
+	co.frame, co.depth, co.nesting, co.cmdFrames, co.src = interp.frame, interp.depth, interp.nesting, interp.cmdFrames, interp.src
+	interp.coroutine = caller
+	interp.frame, interp.depth, interp.nesting, interp.cmdFrames, interp.src = callerFrame, callerDepth, callerNesting, callerCmds, callerSrc
+
+	if r.done {
+		co.done = true
+		if interp.commands[co.cmd.name] == co.cmd {
+			interp.removeCommand(co.cmd)
+		}
+		if errors.Is(r.err, errCoroutineDeleted) {
+			r.err = nil
+		}
+		return r.value, r.err
+	}
+	if co.deleted {
+		// The coroutine deleted its own command; unwind it now
+		// that it has yielded.
+		interp.killCoroutine(co)
+		return r.value, nil
+	}
+	co.yieldto = r.yieldto != nil
+	if co.yieldto {
+		return interp.invoke(r.yieldto)
+	}
+	return r.value, nil
+}
+
+// resumeCoroutine implements the coroutine command of co.
+func (interp *Interp) resumeCoroutine(co *coroutine, args []*Value) (*Value, error) {
+	if co.running {
+		return nil, fmt.Errorf("coroutine \"%s\" is already running", args[0])
+	}
+	var value *Value
+	switch {
+	case co.yieldto:
+		value = NewListValue(append([]*Value(nil), args[1:]...))
+	case len(args) > 2:
+		return nil, wrongNumArgs(args, 1, "?arg?")
+	case len(args) == 2:
+		value = args[1]
+	default:
+		value = emptyValue()
+	}
+	return interp.transfer(co, coTransfer{value: value})
+}
+
+// killCoroutine unwinds co, which is called when its command is
+// deleted. A suspended coroutine is resumed with an error that
+// unwinds it until it finishes, so that its goroutine exits.
+func (interp *Interp) killCoroutine(co *coroutine) {
+	switch {
+	case co.done:
+		return
+	case co.running:
+		co.deleted = true
+		return
+	case !co.started:
+		co.done = true
+		return
+	}
+	co.done = true
+	caller := interp.coroutine
+	callerFrame, callerDepth, callerNesting, callerCmds, callerSrc := interp.frame, interp.depth, interp.nesting, interp.cmdFrames, interp.src
+	interp.coroutine = co
+	interp.frame, interp.depth, interp.nesting, interp.cmdFrames, interp.src = co.frame, co.depth, co.nesting, co.cmdFrames, co.src
+	co.running = true
+	co.in <- coTransfer{kill: true}
+	for r := <-co.out; !r.done; r = <-co.out {
+		co.in <- coTransfer{kill: true}
+	}
+	co.running = false
+	interp.coroutine = caller
+	interp.frame, interp.depth, interp.nesting, interp.cmdFrames, interp.src = callerFrame, callerDepth, callerNesting, callerCmds, callerSrc
+}
+
+// yield passes control from the running coroutine back to the
+// command that resumed it, passing value, or running yieldto in the
+// resumer if it is not nil. It returns the value the coroutine is
+// resumed with.
+func (interp *Interp) yield(value *Value, yieldto []*Value) (*Value, error) {
+	co := interp.coroutine
+	if co.deleted {
+		return nil, errCoroutineDeleted
+	}
+	co.out <- coTransfer{value: value, yieldto: yieldto}
+	t := <-co.in
+	if t.kill {
+		return nil, errCoroutineDeleted
+	}
+	return t.value, nil
+}
+
+// yield ?value?
+//
+// This should only be called inside a coroutine. It suspends the
+// execution of the current coroutine, and the command that resumed
+// it returns value. When the coroutine is resumed, yield returns the
+// value it was resumed with.
+func cmdYield(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) > 2 {
+		return nil, wrongNumArgs(args, 1, "?returnValue?")
+	}
+	if interp.coroutine == nil {
+		return nil, fmt.Errorf("yield can only be called in a coroutine")
+	}
+	value := emptyValue()
+	if len(args) == 2 {
+		value = args[1]
+	}
+	return interp.yield(value, nil)
+}
+
+// yieldto command ?arg ...?
+//
+// This should only be called inside a coroutine. It suspends the
+// execution of the current coroutine and has the command that
+// resumed it run command with the given arguments in its place, its
+// result becoming the result of the resuming command. When the
+// coroutine is next resumed, which may be with any number of
+// arguments, yieldto returns the list of those arguments.
+func cmdYieldto(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "command ?arg ...?")
+	}
+	if interp.coroutine == nil {
+		return nil, fmt.Errorf("yieldto can only be called in a coroutine")
+	}
+	return interp.yield(nil, append([]*Value(nil), args[1:]...))
+}
@@ -0,0 +1,98 @@
+package gotcl
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// evalCommand evaluates its arguments, joined by spaces, as a script.
+func evalCommand(interp *Interp, args []*Value) (*Value, error) {
+	return interp.evalScript([]rune(concatValues(args[1:])))
+}
+
+func TestCoroutine(t *testing.T) {
+	interp := NewInterp()
+	interp.CreateCommand("run", evalCommand)
+	runEvalTests(t, interp, []evalTest{
+		{script: `coroutine gen run {set x [yield a]; lappend got $x; set y [yield b]; lappend got $y; list done}`, result: "a"},
+		{script: `gen 1`, result: "b"},
+		{script: `info coroutine`, result: ""},
+		{script: `gen 2`, result: "done"},
+		{script: `set got`, result: "1 2"},
+		{script: `gen`, err: `invalid command name "gen"`},
+		{script: `coroutine once list a b`, result: "a b"},
+		{script: `once`, err: `invalid command name "once"`},
+		{script: `coroutine c yield first`, result: "first"},
+		{script: `c 1 2`, err: `wrong # args: should be "c ?arg?"`},
+		{script: `c last`, result: "last"},
+		{script: `coroutine who run {yield [info coroutine]; info coroutine}`, result: "::who"},
+		{script: `who`, result: "::who"},
+		{script: `coroutine self run {yield; self}`},
+		{script: `self`, err: `coroutine "self" is already running`},
+		{script: `coroutine bad run {yield; error-command}`},
+		{script: `bad`, err: `invalid command name "error-command"`},
+		{script: `bad`, err: `invalid command name "bad"`},
+		{script: `yield`, err: `yield can only be called in a coroutine`},
+		{script: `yieldto list`, err: `yieldto can only be called in a coroutine`},
+		{script: `yield a b`, err: `wrong # args: should be "yield ?returnValue?"`},
+		{script: `coroutine x`, err: `wrong # args: should be "coroutine name cmd ?arg ...?"`},
+	})
+}
+
+func TestCoroutineNested(t *testing.T) {
+	interp := NewInterp()
+	interp.CreateCommand("run", evalCommand)
+	runEvalTests(t, interp, []evalTest{
+		{script: `coroutine inner run {yield i1; yield i2}`, result: "i1"},
+		{script: `coroutine outer run {yield [list o1 [inner]]; yield [info coroutine]}`, result: "o1 i2"},
+		{script: `outer`, result: "::outer"},
+	})
+}
+
+func TestYieldto(t *testing.T) {
+	interp := NewInterp()
+	interp.CreateCommand("run", evalCommand)
+	runEvalTests(t, interp, []evalTest{
+		{script: `coroutine co run {set args [yieldto list x y]; yieldto string length $args; list end $args}`, result: "x y"},
+		{script: `co a {b c}`, result: "7"},
+		{script: `co`, result: "end {a {b c}}"},
+		{script: `set args`, result: "a {b c}"},
+	})
+}
+
+func TestCoroutineDeleteUnwinds(t *testing.T) {
+	before := runtime.NumGoroutine()
+	interp := NewInterp()
+	interp.CreateCommand("run", evalCommand)
+	runEvalTests(t, interp, []evalTest{
+		{script: `coroutine a run {yield; set reached 1}`},
+		{script: `coroutine b run {yield}`},
+		{script: `coroutine c run {yield}`},
+		{script: `coroutine d yield`},
+	})
+	for _, name := range []string{"a", "b"} {
+		if err := interp.DeleteCommand(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `set reached`, err: `can't read "reached": no such variable`},
+		{script: `coroutine e run {yield; set x [e]; yield $x}`},
+		{script: `interp create child`, result: "child"},
+		{script: `child eval {coroutine f yield}`},
+		{script: `interp delete child`},
+		{script: `coroutine g yield; interp hide {} g`},
+	})
+	interp.Delete()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d coroutine goroutines leaked", n-before)
+	}
+	if _, err := interp.Eval(`set x 1`); err == nil || err.Error() != "attempt to call eval in deleted interpreter" {
+		t.Errorf("eval in a deleted interpreter: got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package gotcl
+
+import (
+	"fmt"
+)
+
+// A dictEntry is one key/value pair of a dictionary.
+type dictEntry struct {
+	key, value *Value
+}
+
+// A dictRep is the internal representation of a Value that has been
+// parsed as a dictionary. Entries are kept in insertion order, which
+// is the order in which keys are iterated over and formatted.
+type dictRep struct {
+	entries []dictEntry
+	index   map[string]int
+}
+
+func newDictRep() *dictRep {
+	return &dictRep{index: make(map[string]int)}
+}
+
+func (d *dictRep) String() string {
+	elems := make([]string, 0, 2*len(d.entries))
+	for _, e := range d.entries {
+		elems = append(elems, e.key.String(), e.value.String())
+	}
+	return formatList(elems)
+}
+
+// get returns the value stored under key.
+func (d *dictRep) get(key *Value) (*Value, bool) {
+	i, ok := d.index[key.String()]
+	if !ok {
+		return nil, false
+	}
+	return d.entries[i].value, true
+}
+
+// set stores value under key. A new key is added after all existing
+// keys; an existing key keeps its position.
+func (d *dictRep) set(key, value *Value) {
+	if i, ok := d.index[key.String()]; ok {
+		d.entries[i].value = value
+		return
+	}
+	d.index[key.String()] = len(d.entries)
+	d.entries = append(d.entries, dictEntry{key, value})
+}
+
+// remove deletes key from d, if present.
+func (d *dictRep) remove(key *Value) {
+	i, ok := d.index[key.String()]
+	if !ok {
+		return
+	}
+	delete(d.index, key.String())
+	d.entries = append(d.entries[:i:i], d.entries[i+1:]...)
+	for j := i; j < len(d.entries); j++ {
+		d.index[d.entries[j].key.String()] = j
+	}
+}
+
+// clone returns a copy of d that may be modified without affecting
+// d.
+func (d *dictRep) clone() *dictRep {
+	c := &dictRep{
+		entries: make([]dictEntry, len(d.entries)),
+		index:   make(map[string]int, len(d.index)),
+	}
+	copy(c.entries, d.entries)
+	for k, i := range d.index {
+		c.index[k] = i
+	}
+	return c
+}
+
+// newDictValue returns a Value whose internal representation is d.
+// d must not be modified afterwards.
+func newDictValue(d *dictRep) *Value {
+	return newRepValue(d)
+}
+
+// dict returns v parsed as a dictionary. The returned dictRep must
+// not be modified; use clone to obtain a modifiable copy.
+func (v *Value) dict() (*dictRep, error) {
+	if d, ok := v.rep.(*dictRep); ok {
+		return d, nil
+	}
+	elems, err := v.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(elems)%2 != 0 {
+		return nil, fmt.Errorf("missing value to go with key")
+	}
+	d := newDictRep()
+	for i := 0; i < len(elems); i += 2 {
+		d.set(elems[i], elems[i+1])
+	}
+	v.setRep(d)
+	return d, nil
+}
+
+// dictGetPath returns the value found by following keys through the
+// nested dictionaries starting with v.
+func dictGetPath(v *Value, keys []*Value) (*Value, error) {
+	for _, key := range keys {
+		d, err := v.dict()
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if v, ok = d.get(key); !ok {
+			return nil, fmt.Errorf("key \"%s\" not known in dictionary", key)
+		}
+	}
+	return v, nil
+}
+
+// dictSetPath returns a copy of the dictionary v in which the value
+// found by following keys has been replaced by value. Missing
+// dictionaries along the path are created. A nil v is treated as an
+// empty dictionary.
+func dictSetPath(v *Value, keys []*Value, value *Value) (*Value, error) {
+	d := newDictRep()
+	if v != nil {
+		orig, err := v.dict()
+		if err != nil {
+			return nil, err
+		}
+		d = orig.clone()
+	}
+	if len(keys) > 1 {
+		sub, _ := d.get(keys[0])
+		var err error
+		if value, err = dictSetPath(sub, keys[1:], value); err != nil {
+			return nil, err
+		}
+	}
+	d.set(keys[0], value)
+	return newDictValue(d), nil
+}
+
+// dictUnsetPath returns a copy of the dictionary v in which the key
+// found by following keys has been removed. Every key but the last
+// must exist.
+func dictUnsetPath(v *Value, keys []*Value) (*Value, error) {
+	orig, err := v.dict()
+	if err != nil {
+		return nil, err
+	}
+	d := orig.clone()
+	if len(keys) == 1 {
+		d.remove(keys[0])
+		return newDictValue(d), nil
+	}
+	sub, ok := d.get(keys[0])
+	if !ok {
+		return nil, fmt.Errorf("key \"%s\" not known in dictionary", keys[0])
+	}
+	if sub, err = dictUnsetPath(sub, keys[1:]); err != nil {
+		return nil, err
+	}
+	d.set(keys[0], sub)
+	return newDictValue(d), nil
+}
@@ -0,0 +1,331 @@
+package gotcl
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EventFlags select the kinds of events processed by DoOneEvent.
+type EventFlags int
+
+const (
+	// QueuedEvents are events added with QueueEvent.
+	QueuedEvents EventFlags = 1 << iota
+	// FileEvents are the channel handlers created by fileevent.
+	FileEvents
+	// TimerEvents are the timer handlers created by after.
+	TimerEvents
+	// IdleEvents are the idle handlers created by after idle.
+	IdleEvents
+	// DontWait makes DoOneEvent return immediately if there is no
+	// event ready to process.
+	DontWait
+
+	AllEvents = QueuedEvents | FileEvents | TimerEvents | IdleEvents
+)
+
+// An EventFunc is run by the event loop of interp. An error it
+// returns is reported as a background error.
+type EventFunc func(interp *Interp) error
+
+// eventLoop holds the event sources of an interpreter.
+type eventLoop struct {
+	// mu guards queue and holds, which other goroutines may
+	// change. wakeup is signalled when they do, or when a
+	// background read of a channel completes.
+	mu     sync.Mutex
+	queue  []EventFunc
+	holds  int
+	wakeup chan struct{}
+
+	afterEvents []*afterEvent
+	nextAfterID int
+	handlers    []*fileHandler
+}
+
+func newEventLoop() *eventLoop {
+	return &eventLoop{wakeup: make(chan struct{}, 1)}
+}
+
+func (el *eventLoop) wake() {
+	select {
+	case el.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// An afterEvent is a timer or idle handler created by after.
+type afterEvent struct {
+	id     string
+	script *Value
+	when   time.Time
+	idle   bool
+}
+
+// A fileHandler holds the readable and writable scripts of a
+// channel created by fileevent.
+type fileHandler struct {
+	ch       *channel
+	readable *Value
+	writable *Value
+}
+
+// QueueEvent adds fn to the end of the event queue of interp, to be
+// run by DoOneEvent. Unlike the other methods of Interp, it may be
+// called from any goroutine, so background work can use it to call
+// back into the interpreter.
+func (interp *Interp) QueueEvent(fn EventFunc) {
+	el := interp.events
+	el.mu.Lock()
+	el.queue = append(el.queue, fn)
+	el.mu.Unlock()
+	el.wake()
+}
+
+// HoldEvents tells the event loop of interp that another goroutine
+// will queue events, so that DoOneEvent and vwait wait for them
+// instead of reporting that there is nothing to wait for. The hold
+// lasts until the returned function is called. HoldEvents may be
+// called from any goroutine.
+func (interp *Interp) HoldEvents() (release func()) {
+	el := interp.events
+	el.mu.Lock()
+	el.holds++
+	el.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			el.mu.Lock()
+			el.holds--
+			el.mu.Unlock()
+			el.wake()
+		})
+	}
+}
+
+// DoOneEvent processes a single event of one of the kinds in flags,
+// waiting for one to become ready unless flags includes DontWait.
+// Queued events are processed first, then timers, all of those due
+// at once, then channel handlers, and idle handlers only when nothing
+// else is ready. It reports whether an event was processed; it
+// returns false without waiting if there is no event source that
+// could produce one.
+func (interp *Interp) DoOneEvent(flags EventFlags) bool {
+	el := interp.events
+	for !interp.deleted {
+		if flags&QueuedEvents != 0 {
+			el.mu.Lock()
+			var fn EventFunc
+			if len(el.queue) > 0 {
+				fn = el.queue[0]
+				el.queue = el.queue[1:]
+			}
+			el.mu.Unlock()
+			if fn != nil {
+				if err := fn(interp); err != nil {
+					interp.backgroundError(err)
+				}
+				return true
+			}
+		}
+		now := time.Now()
+		if flags&TimerEvents != 0 {
+			if due := interp.dueTimers(now); len(due) > 0 {
+				for _, ev := range due {
+					interp.runAfterEvent(ev)
+				}
+				return true
+			}
+		}
+		next := interp.nextTimer()
+		if flags&FileEvents != 0 && interp.serviceFileHandler() {
+			return true
+		}
+		if flags&IdleEvents != 0 && interp.serviceIdle() {
+			return true
+		}
+		if flags&DontWait != 0 {
+			return false
+		}
+
+		el.mu.Lock()
+		waiting := el.holds > 0 || len(el.queue) > 0
+		el.mu.Unlock()
+		if flags&FileEvents != 0 && len(el.handlers) > 0 {
+			waiting = true
+		}
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if flags&TimerEvents != 0 && next != nil {
+			timer = time.NewTimer(next.when.Sub(now))
+			timeout = timer.C
+			waiting = true
+		}
+		if !waiting {
+			return false
+		}
+		select {
+		case <-el.wakeup:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+	return false
+}
+
+// backgroundError reports an error from a script run by the event
+// loop. It invokes the bgerror command with the error message if
+// there is one, and writes the message to stderr otherwise.
+func (interp *Interp) backgroundError(err error) {
+	msg := err.Error()
+	if interp.CommandExists("bgerror") {
+		_, berr := interp.invoke([]*Value{NewStringValue("bgerror"), NewStringValue(msg)})
+		if berr == nil || errorCode(berr) == CodeBreak {
+			return
+		}
+		msg = fmt.Sprintf("bgerror failed to handle background error.\n    Original error: %s\n    Error in bgerror: %s", msg, berr)
+	}
+	if ch, ok := interp.channels["stderr"]; ok && ch.w != nil {
+		ch.write(msg + "\n")
+		ch.flush()
+	}
+}
+
+// nextTimer returns the timer handler due first, or nil.
+func (interp *Interp) nextTimer() *afterEvent {
+	var next *afterEvent
+	for _, ev := range interp.events.afterEvents {
+		if !ev.idle && (next == nil || ev.when.Before(next.when)) {
+			next = ev
+		}
+	}
+	return next
+}
+
+// dueTimers returns the timer handlers due by now, in the order they
+// are due.
+func (interp *Interp) dueTimers(now time.Time) []*afterEvent {
+	var due []*afterEvent
+	for _, ev := range interp.events.afterEvents {
+		if !ev.idle && !ev.when.After(now) {
+			due = append(due, ev)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].when.Before(due[j].when) })
+	return due
+}
+
+// serviceIdle runs the idle handlers that exist when it is called
+// and reports whether there were any.
+func (interp *Interp) serviceIdle() bool {
+	var idle []*afterEvent
+	for _, ev := range interp.events.afterEvents {
+		if ev.idle {
+			idle = append(idle, ev)
+		}
+	}
+	for _, ev := range idle {
+		interp.runAfterEvent(ev)
+	}
+	return len(idle) > 0
+}
+
+// runAfterEvent removes ev and evaluates its script, if it has not
+// already been cancelled.
+func (interp *Interp) runAfterEvent(ev *afterEvent) {
+	if !interp.removeAfterEvent(ev) {
+		return
+	}
+	if _, err := interp.evalGlobal(ev.script); err != nil {
+		interp.backgroundError(err)
+	}
+}
+
+func (interp *Interp) removeAfterEvent(ev *afterEvent) bool {
+	el := interp.events
+	for i, e := range el.afterEvents {
+		if e == ev {
+			el.afterEvents = append(el.afterEvents[:i:i], el.afterEvents[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// serviceFileHandler runs the script of the first channel handler
+// whose channel is ready and reports whether there was one. The
+// handler is then moved to the end of the list, so that each
+// channel gets its turn.
+func (interp *Interp) serviceFileHandler() bool {
+	el := interp.events
+	for i, h := range el.handlers {
+		var script *Value
+		readable := false
+		switch {
+		case h.readable != nil && h.ch.r != nil && h.ch.inputReady():
+			script, readable = h.readable, true
+		case h.writable != nil && h.ch.w != nil:
+			script = h.writable
+		default:
+			if h.readable != nil {
+				h.ch.readAhead(el.wake)
+			}
+			continue
+		}
+		el.handlers = append(append(el.handlers[:i:i], el.handlers[i+1:]...), h)
+		if _, err := interp.evalGlobal(script); err != nil {
+			// A handler that fails is deleted.
+			interp.setFileHandler(h.ch, readable, nil)
+			interp.backgroundError(err)
+		}
+		return true
+	}
+	return false
+}
+
+// fileHandler returns the handler of ch, or nil.
+func (interp *Interp) fileHandler(ch *channel) *fileHandler {
+	for _, h := range interp.events.handlers {
+		if h.ch == ch {
+			return h
+		}
+	}
+	return nil
+}
+
+// setFileHandler sets the readable or writable script of ch,
+// removing it if script is nil.
+func (interp *Interp) setFileHandler(ch *channel, readable bool, script *Value) {
+	el := interp.events
+	h := interp.fileHandler(ch)
+	if h == nil {
+		if script == nil {
+			return
+		}
+		h = &fileHandler{ch: ch}
+		el.handlers = append(el.handlers, h)
+	}
+	if readable {
+		h.readable = script
+	} else {
+		h.writable = script
+	}
+	if h.readable == nil && h.writable == nil {
+		interp.removeFileHandlers(ch)
+	}
+}
+
+// removeFileHandlers removes the handlers of ch.
+func (interp *Interp) removeFileHandlers(ch *channel) {
+	el := interp.events
+	for i, h := range el.handlers {
+		if h.ch == ch {
+			el.handlers = append(el.handlers[:i:i], el.handlers[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,488 @@
+package gotcl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// A pipeline is a sequence of processes started by exec or open,
+// with the standard output of each connected to the standard input
+// of the next.
+type pipeline struct {
+	cmds []*exec.Cmd
+	// background is set if the pipeline ends with &.
+	background bool
+	// stdin, stdout and stderr are set by redirections. stdout and
+	// stderr are nil when not redirected; errToOut is set by 2>@1.
+	stdin    io.Reader
+	stdout   io.Writer
+	stderr   io.Writer
+	errToOut bool
+	// joinErr[i] is set if the standard error of command i is
+	// piped to the next command along with its output, by |&.
+	joinErr []bool
+	// files holds the files opened for redirections, and pipes the
+	// parent's copies of the pipe ends given to the processes,
+	// which are closed once the processes have started.
+	files []*os.File
+	pipes []*os.File
+	// errOut collects the standard error of the processes when it
+	// is not redirected.
+	errOut syncBuffer
+}
+
+// A syncBuffer is a bytes.Buffer that may be written to by several
+// goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// parsePipeline parses the words of a pipeline, with its commands
+// separated by | or |& and followed by redirections anywhere in it.
+func (interp *Interp) parsePipeline(words []string) (*pipeline, error) {
+	p := &pipeline{}
+	if n := len(words); n > 0 && words[n-1] == "&" {
+		p.background, words = true, words[:n-1]
+	}
+	var argv []string
+	var err error
+	for i := 0; i < len(words) && err == nil; i++ {
+		w := words[i]
+		if w == "|" || w == "|&" {
+			if len(argv) == 0 {
+				return nil, p.fail(errors.New("illegal use of | or |& in command"))
+			}
+			p.cmds = append(p.cmds, exec.Command(argv[0], argv[1:]...))
+			p.joinErr = append(p.joinErr, w == "|&")
+			argv = nil
+			continue
+		}
+		op := redirection(w)
+		if op == "" {
+			argv = append(argv, w)
+			continue
+		}
+		target := w[len(op):]
+		if target == "" && op != "2>@1" {
+			if i++; i == len(words) {
+				return nil, p.fail(fmt.Errorf("can't specify \"%s\" as last word in command", op))
+			}
+			target = words[i]
+		}
+		err = interp.redirect(p, op, target)
+	}
+	if err != nil {
+		return nil, p.fail(err)
+	}
+	if len(argv) == 0 {
+		return nil, p.fail(errors.New("illegal use of | or |& in command"))
+	}
+	p.cmds = append(p.cmds, exec.Command(argv[0], argv[1:]...))
+	p.joinErr = append(p.joinErr, false)
+	return p, nil
+}
+
+// redirections holds the redirection operators, longest first among
+// those sharing a prefix.
+var redirections = []string{
+	"<<", "<@", "<",
+	">>&", ">>", ">&@", ">&", ">@", ">",
+	"2>@1", "2>>", "2>@", "2>",
+}
+
+// redirection returns the redirection operator w starts with, or ""
+// if w is not a redirection.
+func redirection(w string) string {
+	for _, op := range redirections {
+		if strings.HasPrefix(w, op) && (op != "2>@1" || w == op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// redirect applies the redirection op to target in p.
+func (interp *Interp) redirect(p *pipeline, op, target string) error {
+	switch op {
+	case "<<":
+		p.stdin = strings.NewReader(target)
+	case "<":
+		f, err := os.Open(target)
+		if err != nil {
+			return fmt.Errorf("couldn't read file \"%s\": %s", target, posixError(err))
+		}
+		p.files = append(p.files, f)
+		p.stdin = f
+	case "<@":
+		ch, err := interp.lookupChannel(target)
+		if err != nil {
+			return err
+		}
+		if err := ch.checkReadable(); err != nil {
+			return err
+		}
+		p.stdin = io.MultiReader(bytes.NewReader(ch.in), ch.r)
+		ch.in = nil
+	case "2>@1":
+		p.errToOut = true
+	default:
+		var w io.Writer
+		if strings.HasSuffix(op, "@") {
+			ch, err := interp.lookupChannel(target)
+			if err != nil {
+				return err
+			}
+			if err := ch.checkWritable(); err != nil {
+				return err
+			}
+			if err := ch.flush(); err != nil {
+				return ch.ioError("flushing", err)
+			}
+			w = ch.w
+		} else {
+			flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			if strings.Contains(op, ">>") {
+				flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+			}
+			f, err := os.OpenFile(target, flags, 0o666)
+			if err != nil {
+				return fmt.Errorf("couldn't write file \"%s\": %s", target, posixError(err))
+			}
+			p.files = append(p.files, f)
+			w = f
+		}
+		if !strings.HasPrefix(op, "2") {
+			p.stdout = w
+		}
+		if strings.HasPrefix(op, "2") || strings.Contains(op, "&") {
+			p.stderr = w
+		}
+	}
+	return nil
+}
+
+// fail closes the files of p and returns err.
+func (p *pipeline) fail(err error) error {
+	p.closeFiles()
+	return err
+}
+
+// closeFiles closes the files opened for redirections of p and the
+// parent's copies of its pipes.
+func (p *pipeline) closeFiles() {
+	for _, f := range p.files {
+		f.Close()
+	}
+	for _, f := range p.pipes {
+		f.Close()
+	}
+	p.files, p.pipes = nil, nil
+}
+
+// start connects the processes of p to each other, to stdin and
+// stdout, which are used unless redirected, and starts them.
+func (p *pipeline) start(stdin io.Reader, stdout io.Writer) error {
+	if p.stdin == nil {
+		p.stdin = stdin
+	}
+	if p.stdout == nil {
+		p.stdout = stdout
+	}
+	errOut := p.stderr
+	if errOut == nil {
+		errOut = &p.errOut
+	}
+	if p.errToOut {
+		errOut = p.stdout
+	}
+	for i, cmd := range p.cmds {
+		cmd.Stderr = errOut
+		if i == 0 {
+			cmd.Stdin = p.stdin
+		}
+		if i == len(p.cmds)-1 {
+			cmd.Stdout = p.stdout
+			break
+		}
+		r, w, err := os.Pipe()
+		if err != nil {
+			return p.fail(err)
+		}
+		p.pipes = append(p.pipes, r, w)
+		cmd.Stdout = w
+		if p.joinErr[i] {
+			cmd.Stderr = w
+		}
+		p.cmds[i+1].Stdin = r
+	}
+	for i, cmd := range p.cmds {
+		if err := cmd.Start(); err != nil {
+			for _, started := range p.cmds[:i] {
+				started.Process.Kill()
+				started.Wait()
+			}
+			if errors.Is(err, exec.ErrNotFound) {
+				err = fs.ErrNotExist
+			}
+			return p.fail(fmt.Errorf("couldn't execute \"%s\": %s", cmd.Args[0], posixError(err)))
+		}
+	}
+	for _, f := range p.pipes {
+		f.Close()
+	}
+	p.pipes = nil
+	return nil
+}
+
+// exec ?switches? arg ?arg ...? ?&?
+//
+// This command treats its arguments as the specification of one or
+// more subprocesses to execute. The arguments take the form of a
+// standard shell pipeline where each arg becomes one word of a
+// command, and each distinct command becomes a subprocess. The
+// result is the standard output of the last command, without its
+// trailing newline unless -keepnewline is given.
+//
+// Commands are separated by | to pipe the standard output of the
+// previous command into the next, or |& to pipe both its standard
+// output and standard error. The redirections < fileName, <@ fileId
+// and << value set the standard input of the first command; >
+// fileName, >> fileName (appending) and >@ fileId the standard
+// output of the last command; 2>, 2>> and 2>@ the standard error of
+// all commands, and 2>@1 sends it to the result; >&, >>& and >&@
+// redirect both standard output and standard error. The file name
+// or channel may be given in the same word as the operator.
+//
+// If a command exits abnormally, exec returns an error with errorCode
+// CHILDSTATUS pid code, or CHILDKILLED pid signal message if it was
+// killed by a signal. Output to a standard error that is not
+// redirected also makes exec return an error, unless -ignorestderr is
+// given. If the last arg is &, the pipeline runs in the background
+// and exec returns the list of the process ids of its commands.
+func cmdExec(interp *Interp, args []*Value) (*Value, error) {
+	ignoreStderr, keepNewline := false, false
+	i := 1
+options:
+	for ; i < len(args) && strings.HasPrefix(args[i].String(), "-"); i++ {
+		switch args[i].String() {
+		case "-ignorestderr":
+			ignoreStderr = true
+		case "-keepnewline":
+			keepNewline = true
+		case "--":
+			i++
+			break options
+		default:
+			return nil, fmt.Errorf("bad option \"%s\": must be -ignorestderr, -keepnewline, or --", args[i])
+		}
+	}
+	if i == len(args) {
+		return nil, wrongNumArgs(args, 1, "?-option ...? arg ?arg ...?")
+	}
+	p, err := interp.parsePipeline(valuesToStrings(args[i:]))
+	if err != nil {
+		return nil, err
+	}
+	if p.background {
+		if p.stderr == nil && !p.errToOut {
+			p.stderr = os.Stderr
+		}
+		if err := p.start(nil, os.Stdout); err != nil {
+			return nil, err
+		}
+		go p.wait(true)
+		return NewListValue(p.pids()), nil
+	}
+	var out syncBuffer
+	if err := p.start(os.Stdin, &out); err != nil {
+		return nil, err
+	}
+	waitErr := p.wait(ignoreStderr)
+	output := out.String()
+	if !keepNewline {
+		output = strings.TrimSuffix(output, "\n")
+	}
+	if waitErr != nil {
+		if output != "" {
+			waitErr.Msg = output + "\n" + waitErr.Msg
+		}
+		return nil, waitErr
+	}
+	return NewStringValue(output), nil
+}
+
+// checkPipelinePermission returns an error unless open may start the
+// processes of a command pipeline. A pipeline runs host processes
+// just as exec does, so it is refused in a safe interpreter, even one
+// whose open command has been exposed, and while exec is hidden.
+func (interp *Interp) checkPipelinePermission() error {
+	if interp.safe {
+		return fmt.Errorf("permission denied: safe interpreter cannot open command pipelines")
+	}
+	if _, ok := interp.hidden["exec"]; ok {
+		return fmt.Errorf("permission denied: exec is hidden, so command pipelines cannot be opened")
+	}
+	return nil
+}
+
+// openPipeline opens a channel to the pipeline words, with the
+// access mode of flags: the channel writes to the standard input of
+// the first command if it is writable, and reads the standard output
+// of the last command if it is readable. Closing the channel waits
+// for the processes to exit.
+func (interp *Interp) openPipeline(words []string, flags int) (*channel, error) {
+	p, err := interp.parsePipeline(words)
+	if err != nil {
+		return nil, err
+	}
+	var stdin io.Reader = os.Stdin
+	var stdout io.Writer = os.Stdout
+	var r, w, childIn, childOut *os.File
+	mode := flags & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR)
+	if mode != os.O_RDONLY {
+		if p.stdin != nil {
+			return nil, p.fail(errors.New("can't write input to command: standard input was redirected"))
+		}
+		if childIn, w, err = os.Pipe(); err != nil {
+			return nil, p.fail(err)
+		}
+		p.pipes = append(p.pipes, childIn)
+		stdin = childIn
+	}
+	if mode != os.O_WRONLY {
+		if p.stdout != nil {
+			return nil, p.fail(errors.New("can't read output from command: standard output was redirected"))
+		}
+		if r, childOut, err = os.Pipe(); err != nil {
+			return nil, p.fail(err)
+		}
+		p.pipes = append(p.pipes, childOut)
+		stdout = childOut
+	}
+	if err := p.start(stdin, stdout); err != nil {
+		for _, f := range []*os.File{r, w} {
+			if f != nil {
+				f.Close()
+			}
+		}
+		return nil, err
+	}
+	var reader io.Reader
+	var writer io.Writer
+	var name string
+	if w != nil {
+		writer, name = w, fmt.Sprintf("file%d", w.Fd())
+	}
+	if r != nil {
+		reader, name = r, fmt.Sprintf("file%d", r.Fd())
+	}
+	ch := newChannel(name, reader, writer, nil, func() error {
+		for _, f := range []*os.File{r, w} {
+			if f != nil {
+				f.Close()
+			}
+		}
+		if err := p.wait(false); err != nil {
+			return err
+		}
+		return nil
+	})
+	if w != nil {
+		ch.closeWrite = w.Close
+	}
+	ch.pids = p.pids()
+	return ch, nil
+}
+
+// pid ?fileId?
+//
+// If the fileId argument is given then the command returns a list
+// whose elements are the process identifiers of all the processes in
+// the pipeline associated with the channel fileId, or an empty
+// string if the channel is not a command pipeline. Otherwise the
+// process identifier of the current process is returned.
+func cmdPid(interp *Interp, args []*Value) (*Value, error) {
+	switch len(args) {
+	case 1:
+		return NewIntValue(int64(os.Getpid())), nil
+	case 2:
+		ch, err := interp.lookupChannel(args[1].String())
+		if err != nil {
+			return nil, err
+		}
+		return NewListValue(ch.pids), nil
+	}
+	return nil, wrongNumArgs(args, 1, "?channelId?")
+}
+
+// pids returns the process ids of p.
+func (p *pipeline) pids() []*Value {
+	pids := make([]*Value, len(p.cmds))
+	for i, cmd := range p.cmds {
+		pids[i] = NewIntValue(int64(cmd.Process.Pid))
+	}
+	return pids
+}
+
+// wait waits for the processes of p to exit and closes its files. It
+// returns an error for processes that exited abnormally and for
+// output to the standard error that was not redirected, unless
+// ignoreStderr is set.
+func (p *pipeline) wait(ignoreStderr bool) *Error {
+	code := []string{"NONE"}
+	var msgs []string
+	abnormal := false
+	for _, cmd := range p.cmds {
+		err := cmd.Wait()
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			continue
+		}
+		pid := strconv.Itoa(cmd.Process.Pid)
+		ws, ok := exitErr.Sys().(syscall.WaitStatus)
+		if ok && ws.Signaled() {
+			msg := signalMsg(ws.Signal())
+			code = []string{"CHILDKILLED", pid, signalName(ws.Signal()), msg}
+			msgs = append(msgs, "child killed: "+msg)
+			continue
+		}
+		code = []string{"CHILDSTATUS", pid, strconv.Itoa(exitErr.ExitCode())}
+		abnormal = true
+	}
+	p.closeFiles()
+	stderr := strings.TrimSuffix(p.errOut.String(), "\n")
+	if ignoreStderr {
+		stderr = ""
+	}
+	if stderr != "" {
+		msgs = append(msgs, stderr)
+	} else if abnormal {
+		msgs = append(msgs, "child process exited abnormally")
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return &Error{Code: code, Msg: strings.Join(msgs, "\n")}
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package gotcl
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// signalName returns the name of sig for CHILDKILLED error codes.
+// Other systems than Unix have no signal names, so the number is
+// reported.
+func signalName(sig syscall.Signal) string {
+	return fmt.Sprintf("SIG%d", int(sig))
+}
+
+// signalMsg returns the message of sig for CHILDKILLED errors.
+func signalMsg(sig syscall.Signal) string {
+	return "unknown signal"
+}
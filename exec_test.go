@@ -0,0 +1,78 @@
+package gotcl
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExec(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.txt")
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `exec echo hello world`, result: "hello world"},
+		{script: `exec -keepnewline echo hi`, result: "hi\n"},
+		{script: `exec printf "b\na\n" | sort | tr a-z A-Z`, result: "A\nB"},
+		{script: `exec cat << "from stdin"`, result: "from stdin"},
+		{script: `exec echo saved > ` + out + `; exec echo more >>` + out + `; exec cat < ` + out, result: "saved\nmore"},
+		{script: `exec sh -c {echo out; echo err >&2} 2>@1`, result: "out\nerr"},
+		{script: `exec sh -c {echo err >&2} |& tr a-z A-Z`, result: "ERR"},
+		{script: `exec -ignorestderr sh -c {echo out; echo err >&2}`, result: "out"},
+		{script: `exec sh -c {echo out; echo err >&2}`, err: "out\nerr"},
+		{script: `exec sh -c {echo partial; exit 3}`, err: "partial\nchild process exited abnormally"},
+		{script: `lindex $errorCode 0`, result: "CHILDSTATUS"},
+		{script: `lindex $errorCode 2`, result: "3"},
+		{script: `exec sh -c {kill -9 $$}`, err: "child killed: kill signal"},
+		{script: `lindex $errorCode 2`, result: "SIGKILL"},
+		{script: `lindex $errorCode 3`, result: "kill signal"},
+		{script: `exec sh -c {kill -SEGV $$}`, err: "child killed: segmentation violation"},
+		{script: `exec nosuchcommand-gotcl`, err: `couldn't execute "nosuchcommand-gotcl": no such file or directory`},
+		{script: `exec echo x |`, err: `illegal use of | or |& in command`},
+		{script: `exec echo x >`, err: `can't specify ">" as last word in command`},
+		{script: `exec -bogus echo`, err: `bad option "-bogus": must be -ignorestderr, -keepnewline, or --`},
+		{script: `llength [exec sleep 0 &]`, result: "1"},
+		{script: `exec`, err: `wrong # args: should be "exec ?-option ...? arg ?arg ...?"`},
+	})
+	_, err := interp.Eval(`exec false`)
+	var tclErr *Error
+	if !errors.As(err, &tclErr) || tclErr.Code[0] != "CHILDSTATUS" || tclErr.Code[2] != "1" {
+		t.Errorf("exec false: got %#v, want a CHILDSTATUS error", err)
+	}
+	if _, err := NewSafeInterp().Eval(`exec echo`); err == nil || !strings.Contains(err.Error(), "invalid command name") {
+		t.Errorf("exec in a safe interpreter: got %v", err)
+	}
+}
+
+func TestPipelineChannel(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `set f [open "|tr a-z A-Z" r+]; puts $f hello; close $f write; gets $f`, result: "HELLO"},
+		{script: `llength [pid $f]`, result: "1"},
+		{script: `close $f`},
+		{script: `set f [open "|echo one two"]; set line [gets $f]; close $f; set line`, result: "one two"},
+		{script: `set f [open "|sh -c {exit 2}"]; read $f; close $f`, err: "child process exited abnormally"},
+		{script: `open "|cat > /dev/null" r`, err: `can't read output from command: standard output was redirected`},
+		{script: `string is integer [pid]`, result: "1"},
+		{script: `pid stdout`, result: ""},
+	})
+
+	// A safe interpreter given a private filesystem and open must not
+	// be able to start processes through it.
+	safe := NewSafeInterp()
+	safe.SetVFS(NewMemFS())
+	if err := safe.ExposeCommand("open", "open"); err != nil {
+		t.Fatal(err)
+	}
+	runEvalTests(t, safe, []evalTest{
+		{script: `open "|sh -c {id -un}"`, err: "permission denied: safe interpreter cannot open command pipelines"},
+		{script: `set f [open /scratch w]; puts $f ok; close $f; set f [open /scratch]; gets $f`, result: "ok"},
+	})
+	if err := interp.HideCommand("exec", "exec"); err != nil {
+		t.Fatal(err)
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `open "|echo hi"`, err: "permission denied: exec is hidden, so command pipelines cannot be opened"},
+	})
+}
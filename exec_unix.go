@@ -0,0 +1,41 @@
+//go:build unix
+
+package gotcl
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// signals holds the names and messages of the signals reported in
+// CHILDKILLED error codes. The messages are those of Tcl.
+var signals = map[syscall.Signal]struct{ name, msg string }{
+	syscall.SIGABRT: {"SIGABRT", "SIGABRT signal"},
+	syscall.SIGALRM: {"SIGALRM", "alarm clock"},
+	syscall.SIGBUS:  {"SIGBUS", "bus error"},
+	syscall.SIGFPE:  {"SIGFPE", "floating-point exception"},
+	syscall.SIGHUP:  {"SIGHUP", "hangup signal"},
+	syscall.SIGILL:  {"SIGILL", "illegal instruction"},
+	syscall.SIGINT:  {"SIGINT", "interrupt signal"},
+	syscall.SIGKILL: {"SIGKILL", "kill signal"},
+	syscall.SIGPIPE: {"SIGPIPE", "write on pipe with no readers"},
+	syscall.SIGQUIT: {"SIGQUIT", "quit signal"},
+	syscall.SIGSEGV: {"SIGSEGV", "segmentation violation"},
+	syscall.SIGTERM: {"SIGTERM", "software termination signal"},
+	syscall.SIGUSR1: {"SIGUSR1", "user-defined signal 1"},
+	syscall.SIGUSR2: {"SIGUSR2", "user-defined signal 2"},
+}
+
+func signalName(sig syscall.Signal) string {
+	if s, ok := signals[sig]; ok {
+		return s.name
+	}
+	return fmt.Sprintf("SIG%d", int(sig))
+}
+
+func signalMsg(sig syscall.Signal) string {
+	if s, ok := signals[sig]; ok {
+		return s.msg
+	}
+	return "unknown signal"
+}
@@ -2,32 +2,120 @@ package gotcl
 
 import (
 	"fmt"
+	"io/fs"
 	"strings"
 )
 
 type Interp struct {
+	commands map[string]*command
+	hidden   map[string]*command
+	safe     bool
+
+	parent   *Interp
+	name     string
+	children map[string]*Interp
+	aliases  map[string]*alias
+	// targets holds the aliases in other interpreters that
+	// invoke commands in this one.
+	targets  map[*alias]bool
+	channels map[string]*channel
+	events   *eventLoop
+	deleted  bool
+
+	globals map[string]*variable
+	// frame is the frame in which variables are resolved, and
+	// globalFrame the frame holding globals. depth counts the
+	// procedure calls being executed and nesting the commands, to
+	// limit recursion.
+	frame       *callFrame
+	globalFrame *callFrame
+	depth       int
+	nesting     int
+	// cmdFrames holds a record of each command being executed,
+	// innermost last, as reported by info frame, and src the source
+	// of the script being evaluated. cmdCount counts the commands
+	// invoked.
+	cmdFrames []cmdFrame
+	src       *scriptSource
+	cmdCount  int
+
+	// errorStack holds the call stack of the most recent error, as
+	// reported by info errorstack. errorLogged is set while an
+	// error is propagating, once it has been recorded there.
+	errorStack  []*Value
+	errorLogged bool
+
+	// scriptFile is the name of the script being sourced, and fs
+	// the filesystem scripts are sourced from, or nil for vfs. vfs
+	// is the filesystem of the file commands, or nil for the host
+	// filesystem, and mounts the filesystems mounted over it.
+	scriptFile string
+	fs         fs.FS
+	vfs        VFS
+	mounts     mountTable
+	// autoPath is the value of auto_path the auto_index array was
+	// last loaded for, if autoIndexed is set.
+	autoPath    string
+	autoIndexed bool
+
+	// packages holds the package database, pkgUnknown the package
+	// unknown handler, or nil, and tmPaths the Tcl module paths.
+	packages   map[string]*tclPackage
+	pkgUnknown *Value
+	tmPaths    []string
+
+	// stepTraces holds the enterstep and leavestep traces of the
+	// traced commands that are executing. execTracing is non-zero
+	// while an execution or command trace is running, which
+	// disables execution traces.
+	stepTraces  []*trace
+	execTracing int
+
+	// coroutine is the coroutine that is running, or nil.
+	coroutine *coroutine
+
+	oo ooState
 }
 
 func NewInterp() *Interp {
-	return &Interp{}
+	return newInterp(false)
 }
 
-func (interp *Interp) GetVar(name, index string) (string, error) {
-	if len(index) == 0 {
-		return name, nil
+// NewSafeInterp returns a new safe interpreter. Every command with
+// access to the filesystem, processes, the network or the
+// environment is hidden: scripts cannot invoke it, but the host can
+// with InvokeHidden, or can re-enable a vetted command with
+// ExposeCommand.
+func NewSafeInterp() *Interp {
+	return newInterp(true)
+}
+
+func newInterp(safe bool) *Interp {
+	interp := &Interp{
+		commands: map[string]*command{},
+		hidden:   map[string]*command{},
+		safe:     safe,
+		children: map[string]*Interp{},
+		aliases:  map[string]*alias{},
+		targets:  map[*alias]bool{},
+		channels: map[string]*channel{},
+		events:   newEventLoop(),
+		globals:  map[string]*variable{},
 	}
-	var (
-		b   strings.Builder
-		err error
-	)
-	_, err = b.WriteString(name)
-	_, err = b.WriteString("(")
-	_, err = b.WriteString(index)
-	_, err = b.WriteString(")")
-	if err != nil {
-		return "", err
+	interp.globalFrame = &callFrame{vars: interp.globals}
+	interp.frame = interp.globalFrame
+	interp.registerBuiltins()
+	interp.initOO()
+	interp.initPackages()
+	if !safe {
+		interp.registerStdChannels()
 	}
-	return b.String(), nil
+	return interp
+}
+
+// IsSafe reports whether interp is a safe interpreter.
+func (interp *Interp) IsSafe() bool {
+	return interp.safe
 }
 
 func (interp *Interp) EvalTokens(tok token) (string, error) {
@@ -35,18 +123,224 @@ func (interp *Interp) EvalTokens(tok token) (string, error) {
 }
 
 func (interp *Interp) Eval(script string) (string, error) {
-	ts, _, err := ParseCommand([]rune(script), false)
+	// An error left by a previous script is no longer in progress.
+	interp.errorLogged = false
+	v, err := interp.evalScript([]rune(script))
+	if e, ok := err.(*codeError); ok && e.code == CodeReturn {
+		// A return outside of any procedure ends the script.
+		v, err = completeReturn(e.value, e.returnCode)
+	}
 	if err != nil {
 		return "", err
 	}
-	ws := make([]string, 0, len(ts))
+	return v.String(), nil
+}
+
+// evalScript evaluates each command of script in turn and returns
+// the result of the last one.
+func (interp *Interp) evalScript(script []rune) (*Value, error) {
+	return interp.evalScriptFrom(script, nil)
+}
+
+// evalScriptFrom evaluates script like evalScript, recording src as
+// where its commands come from for info frame. A nil src is that of
+// the script it is nested in, such as the script whose command it is
+// substituted into; errors in such a script are located by the
+// script it is nested in, at the command that evaluated it.
+func (interp *Interp) evalScriptFrom(script []rune, src *scriptSource) (*Value, error) {
+	if interp.deleted {
+		return nil, fmt.Errorf("attempt to call eval in deleted interpreter")
+	}
+	nested := src == nil
+	if nested {
+		src = evalSource
+		if interp.src != nil {
+			src = interp.src
+		}
+	}
+	saved := interp.src
+	interp.src = src
+	defer func() { interp.src = saved }()
+	locate := func(err error, line int) error {
+		if nested {
+			return err
+		}
+		return src.locate(err, line)
+	}
+	result := emptyValue()
+	line := 1
+	for idx := 0; idx < len(script); {
+		ts, size, err := ParseCommand(script[idx:], false)
+		if err != nil {
+			return nil, locate(err, line)
+		}
+		if size == 0 {
+			break
+		}
+		text := script[idx : idx+size]
+		start := commandStart(text)
+		cmdLine := line + countLines(text[:start])
+		line += countLines(text)
+		idx += size
+		if len(ts) == 0 {
+			continue
+		}
+		words, err := interp.substWords(ts)
+		if err != nil {
+			return nil, locate(err, cmdLine)
+		}
+		interp.cmdFrames = append(interp.cmdFrames, cmdFrame{
+			text:  text[start:],
+			line:  cmdLine,
+			src:   src,
+			level: interp.frame.level,
+		})
+		result, err = interp.invoke(words)
+		interp.cmdFrames = interp.cmdFrames[:len(interp.cmdFrames)-1]
+		if err != nil {
+			return nil, locate(err, cmdLine)
+		}
+	}
+	return result, nil
+}
+
+// A scriptSource describes where a script being evaluated comes
+// from: typ is "source" for a sourced file, "proc" for the body of a
+// procedure, and "eval" otherwise.
+type scriptSource struct {
+	typ  string
+	file string
+	proc string
+}
+
+var evalSource = &scriptSource{typ: "eval"}
+
+// A cmdFrame records a command being executed: its text, line within
+// its script, the source of the script, and the level of the frame
+// it runs in.
+type cmdFrame struct {
+	text  []rune
+	line  int
+	src   *scriptSource
+	level int
+}
+
+// dict returns the description of f reported by info frame.
+func (f *cmdFrame) dict() *Value {
+	d := []*Value{
+		NewStringValue("type"), NewStringValue(f.src.typ),
+		NewStringValue("line"), NewIntValue(int64(f.line)),
+	}
+	if f.src.file != "" {
+		d = append(d, NewStringValue("file"), NewStringValue(f.src.file))
+	}
+	d = append(d, NewStringValue("cmd"), NewStringValue(strings.TrimRight(string(f.text), " \t\r\n;")))
+	if f.src.proc != "" {
+		d = append(d, NewStringValue("proc"), NewStringValue(f.src.proc))
+	}
+	return NewListValue(append(d, NewStringValue("level"), NewIntValue(int64(f.level))))
+}
+
+// commandStart returns the offset in text of the first word of the
+// command it holds, skipping leading white space and comments.
+func commandStart(text []rune) int {
+	i := 0
+	for i < len(text) {
+		switch text[i] {
+		case ' ', '\t', '\r', '\n', ';':
+			i++
+		case '#':
+			for i < len(text) && text[i] != '\n' {
+				if text[i] == '\\' {
+					i++
+				}
+				i++
+			}
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// countLines returns the number of newlines in text.
+func countLines(text []rune) int {
+	n := 0
+	for _, c := range text {
+		if c == '\n' {
+			n++
+		}
+	}
+	return n
+}
+
+// evalValue evaluates script. A script that is a pure list, built
+// as a list and never converted to a string, is invoked as a single
+// command without being reparsed, so that its words keep their
+// internal representations.
+func (interp *Interp) evalValue(script *Value) (*Value, error) {
+	if l, ok := script.rep.(listRep); ok && !script.valid && len(l) > 0 && !interp.deleted {
+		return interp.invoke(append([]*Value(nil), l...))
+	}
+	return interp.evalScript(script.Runes())
+}
+
+// evalGlobal evaluates script in the global frame, as is done for
+// scripts run by the event loop.
+func (interp *Interp) evalGlobal(script *Value) (*Value, error) {
+	saved := interp.frame
+	interp.frame = interp.globalFrame
+	defer func() { interp.frame = saved }()
+	return interp.evalValue(script)
+}
+
+// substWords performs substitutions on the words of a command,
+// expanding words prefixed with {*} into their list elements.
+func (interp *Interp) substWords(ts tokens) ([]*Value, error) {
+	words := make([]*Value, 0, len(ts))
 	for i := 0; i < len(ts); i++ {
-		tok := ts[i]
-		s, err := SubstTokens(interp, SubstAll, tok)
+		if w, ok := ts[i].(expandWordToken); ok {
+			s, err := SubstTokens(interp, SubstAll, w.token)
+			if err != nil {
+				return nil, err
+			}
+			elems, err := NewStringValue(s).List()
+			if err != nil {
+				return nil, err
+			}
+			words = append(words, elems...)
+			continue
+		}
+		if v, err := interp.wordValue(ts[i]); v != nil || err != nil {
+			if err != nil {
+				return nil, err
+			}
+			words = append(words, v)
+			continue
+		}
+		s, err := SubstTokens(interp, SubstAll, ts[i])
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		ws = append(ws, s)
+		words = append(words, NewStringValue(s))
+	}
+	return words, nil
+}
+
+// wordValue returns the value of a word that consists of a single
+// variable or command substitution, or nil for any other word. The
+// value is used as is rather than rebuilt from its string, so that
+// its internal representation is kept.
+func (interp *Interp) wordValue(tok token) (*Value, error) {
+	w, ok := tok.(wordToken)
+	if !ok || len(w) != 1 {
+		return nil, nil
+	}
+	switch t := w[0].(type) {
+	case variableToken:
+		return t.value(interp, SubstAll)
+	case commandToken:
+		return t.value(interp)
 	}
-	return fmt.Sprintf("%q", ws), nil
+	return nil, nil
 }
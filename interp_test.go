@@ -0,0 +1,189 @@
+package gotcl
+
+import (
+	"testing"
+)
+
+type evalTest struct {
+	script string
+	result string
+	err    string
+}
+
+func runEvalTests(t *testing.T, interp *Interp, tests []evalTest) {
+	t.Helper()
+	for _, test := range tests {
+		result, err := interp.Eval(test.script)
+		if test.err != "" {
+			if err == nil {
+				t.Errorf("%q: expected error %q, got result %q", test.script, test.err, result)
+			} else if err.Error() != test.err {
+				t.Errorf("%q: expected error %q, got %q", test.script, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error %q", test.script, err)
+			continue
+		}
+		if result != test.result {
+			t.Errorf("%q: expected %q, got %q", test.script, test.result, result)
+		}
+	}
+}
+
+func echoCommand(interp *Interp, args []*Value) (*Value, error) {
+	return NewStringValue(formatList(valuesToStrings(args[1:]))), nil
+}
+
+func TestSafeInterpHidesUnsafeCommands(t *testing.T) {
+	interp := NewSafeInterp()
+	if !interp.IsSafe() {
+		t.Fatalf("NewSafeInterp returned an unsafe interpreter")
+	}
+	for _, b := range builtinCommands {
+		if b.unsafe && interp.CommandExists(b.name) {
+			t.Errorf("unsafe command %q is exposed in safe interpreter", b.name)
+		}
+		if !b.unsafe && !interp.CommandExists(b.name) {
+			t.Errorf("safe command %q is missing in safe interpreter", b.name)
+		}
+	}
+}
+
+func TestHiddenCommands(t *testing.T) {
+	interp := NewInterp()
+	interp.CreateCommand("echo", echoCommand)
+	runEvalTests(t, interp, []evalTest{
+		{script: `interp issafe`, result: "0"},
+		{script: `interp hide {} echo`},
+		{script: `echo a b`, err: `invalid command name "echo"`},
+		{script: `interp hidden {}`, result: "echo"},
+		{script: `interp invokehidden {} echo a {b c}`, result: "a {b c}"},
+		{script: `interp invokehidden {} -global -- echo x`, result: "x"},
+		{script: `interp invokehidden {} nope`, err: `invalid hidden command name "nope"`},
+		{script: `interp hide {} echo`, err: `unknown command "echo"`},
+		{script: `interp expose {} echo say`},
+		{script: `say hi`, result: "hi"},
+		{script: `interp hide {} say ::x`, err: `cannot use namespace qualifiers in hidden command token (rename)`},
+		{script: `interp hide foo say`, err: `could not find interpreter "foo"`},
+		{script: `interp hid`, err: `unknown or ambiguous subcommand "hid": must be alias, aliases, children, create, delete, eval, exists, expose, hidden, hide, invokehidden, issafe, share, slaves, target, or transfer`},
+		{script: `interp hide {}`, err: `wrong # args: should be "interp hide path cmdName ?hiddenCmdName?"`},
+	})
+}
+
+func TestSafeInterpPermissions(t *testing.T) {
+	interp := NewSafeInterp()
+	interp.CreateCommand("echo", echoCommand)
+	if err := interp.HideCommand("echo", "echo"); err != nil {
+		t.Fatal(err)
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `interp issafe`, result: "1"},
+		{script: `echo hi`, err: `invalid command name "echo"`},
+		{script: `interp invokehidden {} echo hi`, err: `not allowed to invoke hidden commands from safe interpreter`},
+		{script: `interp expose {} echo`, err: `permission denied: safe interpreter cannot expose commands`},
+		{script: `interp hide {} interp`, err: `permission denied: safe interpreter cannot hide commands`},
+	})
+	if s, err := interp.InvokeHidden("echo", "from", "host"); err != nil || s != "from host" {
+		t.Errorf("InvokeHidden: got %q, %v", s, err)
+	}
+	if err := interp.ExposeCommand("echo", "echo"); err != nil {
+		t.Fatal(err)
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `echo vetted`, result: "vetted"},
+	})
+}
+
+func TestChildInterps(t *testing.T) {
+	interp := NewInterp()
+	interp.CreateCommand("echo", echoCommand)
+	runEvalTests(t, interp, []evalTest{
+		{script: `interp create`, result: "interp0"},
+		{script: `interp create -safe p`, result: "p"},
+		{script: `interp create p`, err: `interpreter named "p" already exists, cannot create`},
+		{script: `interp create {p q}`, result: "p q"},
+		{script: `interp issafe {p q}`, result: "1"},
+		{script: `interp children`, result: "interp0 p"},
+		{script: `interp children p`, result: "q"},
+		{script: `interp exists {p q}`, result: "1"},
+		{script: `interp exists {p r}`, result: "0"},
+		{script: `interp eval p echo hi`, err: `invalid command name "echo"`},
+		{script: `interp alias p log {} echo plugin:`, result: "log"},
+		{script: `interp eval p log {hello world}`, result: "plugin: hello world"},
+		{script: `interp eval p {log {hello world}}`, result: "plugin: {hello world}"},
+		{script: `p eval {log a; log b}`, result: "plugin: b"},
+		{script: `interp alias p log`, result: "echo plugin:"},
+		{script: `interp aliases p`, result: "log"},
+		{script: `interp target p log`, result: ""},
+		{script: `interp alias {p q} up p log nested`, result: "up"},
+		{script: `interp eval {p q} up x`, result: "plugin: nested x"},
+		{script: `interp target {p q} up`, result: "p"},
+		{script: `interp alias p log {}`},
+		{script: `interp eval p log x`, err: `invalid command name "log"`},
+		{script: `interp alias {} a {} b`, result: "a"},
+		{script: `interp alias {} b {} a`, err: `cannot define or rename alias "b": would create a loop`},
+		{script: `p alias f list x`, result: "f"},
+		{script: `p eval f y`, result: "x y"},
+		{script: `p alias f`, result: "list x"},
+		{script: `interp target p f`, result: ""},
+		{script: `p alias f {}; p aliases`, result: ""},
+		{script: `p issafe`, result: "1"},
+		{script: `interp delete {}`, err: `cannot delete the current interpreter`},
+		{script: `interp delete p`},
+		{script: `interp exists p`, result: "0"},
+		{script: `p eval x`, err: `invalid command name "p"`},
+		{script: `interp delete p`, err: `could not find interpreter "p"`},
+	})
+}
+
+func TestChildInterpGoAPI(t *testing.T) {
+	host := NewInterp()
+	host.CreateCommand("echo", echoCommand)
+	plugin, err := host.CreateChild("plugin", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plugin.IsSafe() || plugin.Parent() != host || host.Child("plugin") != plugin {
+		t.Fatalf("unexpected child interpreter state")
+	}
+	if err := plugin.Alias("api", host, "echo", "called"); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := plugin.Eval(`api x`); err != nil || s != "called x" {
+		t.Errorf("alias: got %q, %v", s, err)
+	}
+	if err := host.DeleteChild("plugin"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plugin.Eval(`api x`); err == nil || err.Error() != "attempt to call eval in deleted interpreter" {
+		t.Errorf("eval in deleted interpreter: got %v", err)
+	}
+}
+
+func TestInterpShareTransfer(t *testing.T) {
+	interp := NewInterp()
+	closed := 0
+	interp.registerChannel(&channel{name: "chan0", close: func() error {
+		closed++
+		return nil
+	}})
+	runEvalTests(t, interp, []evalTest{
+		{script: `interp create a`, result: "a"},
+		{script: `interp create b`, result: "b"},
+		{script: `interp share {} chan0 a`},
+		{script: `interp transfer {} chan0 b`},
+		{script: `interp share {} chan0 a`, err: `can not find channel named "chan0"`},
+		{script: `interp delete a`},
+	})
+	if closed != 0 {
+		t.Errorf("channel closed while still shared")
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `interp delete b`},
+	})
+	if closed != 1 {
+		t.Errorf("channel closed %d times, expected 1", closed)
+	}
+}
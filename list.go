@@ -0,0 +1,280 @@
+package gotcl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// formatList returns the canonical string representation of a list
+// whose elements are elems, quoting elements as necessary so that
+// parsing the result yields elems again.
+func formatList(elems []string) string {
+	var b strings.Builder
+	for i, elem := range elems {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		writeListElement(&b, elem, i == 0)
+	}
+	return b.String()
+}
+
+type elementQuoting int
+
+const (
+	quoteNone elementQuoting = iota
+	quoteBraces
+	quoteEscape
+)
+
+// scanListElement determines how elem must be quoted to be a single
+// list element. A leading "#" is only special in the first element
+// of a list, where it would otherwise start a comment.
+func scanListElement(elem string, first bool) elementQuoting {
+	if elem == "" {
+		return quoteBraces
+	}
+	forbidNone, requireEscape := false, false
+	switch elem[0] {
+	case '{', '"':
+		forbidNone = true
+	case '#':
+		if first {
+			forbidNone = true
+		}
+	}
+	nesting := 0
+	for i := 0; i < len(elem); i++ {
+		switch elem[i] {
+		case '{':
+			nesting++
+			forbidNone = true
+		case '}':
+			nesting--
+			forbidNone = true
+			if nesting < 0 {
+				requireEscape = true
+			}
+		case '[', ']', '$', ';', '"', ' ', '\f', '\n', '\r', '\t', '\v':
+			forbidNone = true
+		case '\\':
+			forbidNone = true
+			if i+1 == len(elem) || elem[i+1] == '\n' {
+				requireEscape = true
+			}
+		}
+	}
+	if nesting != 0 {
+		requireEscape = true
+	}
+	switch {
+	case requireEscape:
+		return quoteEscape
+	case forbidNone:
+		return quoteBraces
+	}
+	return quoteNone
+}
+
+func writeListElement(b *strings.Builder, elem string, first bool) {
+	switch scanListElement(elem, first) {
+	case quoteNone:
+		b.WriteString(elem)
+	case quoteBraces:
+		b.WriteByte('{')
+		b.WriteString(elem)
+		b.WriteByte('}')
+	case quoteEscape:
+		if first && elem[0] == '#' {
+			b.WriteByte('\\')
+		}
+		for _, c := range elem {
+			switch c {
+			case '{', '}', '[', ']', '$', ';', '"', ' ', '\\':
+				b.WriteByte('\\')
+				b.WriteRune(c)
+			case '\f':
+				b.WriteString(`\f`)
+			case '\n':
+				b.WriteString(`\n`)
+			case '\r':
+				b.WriteString(`\r`)
+			case '\t':
+				b.WriteString(`\t`)
+			case '\v':
+				b.WriteString(`\v`)
+			default:
+				b.WriteRune(c)
+			}
+		}
+	}
+}
+
+// A listRep is the internal representation of a Value that has been
+// parsed as a list.
+type listRep []*Value
+
+func (l listRep) String() string {
+	return formatList(valuesToStrings(l))
+}
+
+// NewListValue returns a Value that is a list of elems.
+func NewListValue(elems []*Value) *Value {
+	return newRepValue(listRep(elems))
+}
+
+// List returns the elements of v parsed as a list. The returned
+// slice must not be modified.
+func (v *Value) List() ([]*Value, error) {
+	switch rep := v.rep.(type) {
+	case listRep:
+		return rep, nil
+	case *dictRep:
+		// Keep the dictionary representation, which is more
+		// expensive to rebuild than the list.
+		l := make([]*Value, 0, 2*len(rep.entries))
+		for _, e := range rep.entries {
+			l = append(l, e.key, e.value)
+		}
+		return l, nil
+	}
+	elems, err := parseList(v.Runes())
+	if err != nil {
+		return nil, err
+	}
+	l := make(listRep, len(elems))
+	for i, elem := range elems {
+		l[i] = newRunesValue(elem)
+	}
+	v.setRep(l)
+	return l, nil
+}
+
+func isListSpace(c rune) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\v' || c == '\f' || c == '\r'
+}
+
+// parseList splits r into list elements, removing the braces or
+// quotes around elements and performing backslash substitution on
+// elements that are not enclosed in braces.
+func parseList(r []rune) ([][]rune, error) {
+	var elems [][]rune
+	idx := 0
+	for {
+		for idx < len(r) && isListSpace(r[idx]) {
+			idx++
+		}
+		if idx >= len(r) {
+			break
+		}
+		elem, size, err := parseListElement(r[idx:])
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+		idx += size
+	}
+	return elems, nil
+}
+
+func parseListElement(r []rune) ([]rune, int, error) {
+	switch r[0] {
+	case '{':
+		open := 1
+		for i := 1; i < len(r); i++ {
+			switch r[i] {
+			case '\\':
+				i++
+			case '{':
+				open++
+			case '}':
+				open--
+				if open == 0 {
+					if i+1 < len(r) && !isListSpace(r[i+1]) {
+						return nil, 0, listExtraCharsError("braces", r[i+1:])
+					}
+					return r[1:i], i + 1, nil
+				}
+			}
+		}
+		return nil, 0, fmt.Errorf("unmatched open brace in list")
+	case '"':
+		for i := 1; i < len(r); i++ {
+			switch r[i] {
+			case '\\':
+				i++
+			case '"':
+				if i+1 < len(r) && !isListSpace(r[i+1]) {
+					return nil, 0, listExtraCharsError("quotes", r[i+1:])
+				}
+				return substListBackslashes(r[1:i]), i + 1, nil
+			}
+		}
+		return nil, 0, fmt.Errorf("unmatched open quote in list")
+	}
+	i := 0
+	for ; i < len(r) && !isListSpace(r[i]); i++ {
+		if r[i] == '\\' {
+			i++
+		}
+	}
+	if i > len(r) {
+		i = len(r)
+	}
+	return substListBackslashes(r[:i]), i, nil
+}
+
+func listExtraCharsError(what string, rest []rune) error {
+	n := 0
+	for n < len(rest) && n < 20 && !isListSpace(rest[n]) {
+		n++
+	}
+	return fmt.Errorf("list element in %s followed by \"%s\" instead of space", what, string(rest[:n]))
+}
+
+// substListBackslashes returns r with backslash sequences replaced
+// by the characters they represent.
+func substListBackslashes(r []rune) []rune {
+	idx := 0
+	for idx < len(r) && r[idx] != '\\' {
+		idx++
+	}
+	if idx == len(r) {
+		return r
+	}
+	out := make([]rune, 0, len(r))
+	out = append(out, r[:idx]...)
+	for idx < len(r) {
+		if r[idx] != '\\' || idx+1 >= len(r) {
+			out = append(out, r[idx])
+			idx++
+			continue
+		}
+		tok, size, err := ParseBackslashToken(r[idx:])
+		if err != nil || size == 0 {
+			out = append(out, r[idx])
+			idx++
+			continue
+		}
+		s, err := tok.Subst(nil, SubstBackslashes)
+		if err != nil {
+			return r
+		}
+		out = append(out, []rune(s)...)
+		idx += size
+	}
+	return out
+}
+
+// concatValues joins vs together with spaces after trimming leading
+// and trailing white space from each, as the concat command does.
+func concatValues(vs []*Value) string {
+	parts := make([]string, 0, len(vs))
+	for _, v := range vs {
+		s := strings.Trim(v.String(), " \t\n\v\f\r")
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, " ")
+}
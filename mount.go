@@ -0,0 +1,423 @@
+package gotcl
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// A mount is a filesystem mounted in an interpreter at prefix, a
+// cleaned absolute path. archive is the name of the zip archive it was
+// mounted from by zipfs, and zip the archive itself, if it is one.
+type mount struct {
+	prefix  string
+	vfs     VFS
+	archive string
+	zip     *zip.Reader
+}
+
+// A mountTable holds the mounts of an interpreter, longest prefix
+// first, and the working directory while it is inside a mount or an
+// ancestor of one, which the underlying VFS cannot change to.
+type mountTable struct {
+	mounts []*mount
+	wd     string
+}
+
+// Mount makes the files of fsys appear, read-only, under the
+// directory prefix in the file commands, source, open and glob of
+// interp, as with zipfs mount. prefix must be an absolute path; the
+// directories leading to it appear to exist even if they do not in
+// the underlying filesystem. A program can mount an embed.FS holding
+// its Tcl library tree to carry it in its own binary.
+func (interp *Interp) Mount(prefix string, fsys fs.FS) error {
+	return interp.addMount(&mount{prefix: prefix, vfs: &fsVFS{fsys}})
+}
+
+// MountVFS is like Mount for a writable filesystem, such as a MemFS.
+func (interp *Interp) MountVFS(prefix string, vfs VFS) error {
+	return interp.addMount(&mount{prefix: prefix, vfs: vfs})
+}
+
+// MountZip mounts the zip archive of size bytes read from r under the
+// directory prefix, like Mount.
+func (interp *Interp) MountZip(prefix string, r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	return interp.addMount(&mount{prefix: prefix, vfs: &fsVFS{zr}, zip: zr})
+}
+
+// Unmount removes the filesystem mounted at prefix.
+func (interp *Interp) Unmount(prefix string) error {
+	p := path.Clean(prefix)
+	for i, m := range interp.mounts.mounts {
+		if m.prefix == p {
+			interp.mounts.mounts = append(interp.mounts.mounts[:i:i], interp.mounts.mounts[i+1:]...)
+			if wd := interp.mounts.wd; wd != "" && within(wd, p) {
+				interp.mounts.wd = ""
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("no filesystem is mounted at \"%s\"", prefix)
+}
+
+func (interp *Interp) addMount(m *mount) error {
+	if !path.IsAbs(m.prefix) {
+		return fmt.Errorf("mount point \"%s\" is not an absolute path", m.prefix)
+	}
+	prefix := m.prefix
+	m.prefix = path.Clean(prefix)
+	if interp.lookupMount(m.prefix) != nil {
+		return fmt.Errorf("a filesystem is already mounted at \"%s\"", prefix)
+	}
+	mounts := append(interp.mounts.mounts, m)
+	sort.SliceStable(mounts, func(i, j int) bool { return len(mounts[i].prefix) > len(mounts[j].prefix) })
+	interp.mounts.mounts = mounts
+	return nil
+}
+
+// lookupMount returns the mount at prefix, or nil.
+func (interp *Interp) lookupMount(prefix string) *mount {
+	for _, m := range interp.mounts.mounts {
+		if m.prefix == prefix {
+			return m
+		}
+	}
+	return nil
+}
+
+// within reports whether the cleaned absolute path p is dir or is
+// inside it.
+func within(p, dir string) bool {
+	return p == dir || dir == "/" || strings.HasPrefix(p, dir+"/")
+}
+
+// A mountFS is the VFS of an interpreter with mounts: it passes each
+// operation to the mount holding the file, or to base.
+type mountFS struct {
+	base VFS
+	t    *mountTable
+}
+
+// abs returns name as a cleaned absolute path.
+func (m *mountFS) abs(name string) (string, error) {
+	if path.IsAbs(name) {
+		return path.Clean(name), nil
+	}
+	wd := m.t.wd
+	if wd == "" {
+		var err error
+		if wd, err = m.base.Getwd(); err != nil {
+			return "", err
+		}
+	}
+	return path.Join(wd, name), nil
+}
+
+// resolve returns the VFS holding the file name and its name there.
+func (m *mountFS) resolve(name string) (VFS, string) {
+	p, err := m.abs(name)
+	if err != nil {
+		return m.base, name
+	}
+	for _, mt := range m.t.mounts {
+		if within(p, mt.prefix) {
+			return mt.vfs, path.Join("/", strings.TrimPrefix(p, mt.prefix))
+		}
+	}
+	if m.t.wd == "" {
+		return m.base, name
+	}
+	return m.base, p
+}
+
+// mountChildren returns the names of the directories inside name that
+// lead to mount points, if name is not itself inside a mount.
+func (m *mountFS) mountChildren(name string) []string {
+	p, err := m.abs(name)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, mt := range m.t.mounts {
+		if within(p, mt.prefix) {
+			return nil
+		}
+		if p != mt.prefix && within(mt.prefix, p) {
+			rest := strings.TrimPrefix(strings.TrimPrefix(mt.prefix, p), "/")
+			child, _, _ := strings.Cut(rest, "/")
+			if !containsString(names, child) {
+				names = append(names, child)
+			}
+		}
+	}
+	return names
+}
+
+// mountDirInfo describes a directory leading to a mount point.
+func mountDirInfo(name string) fs.FileInfo {
+	return &memInfo{name: name, mode: fs.ModeDir | 0o555, stat: statFields{nlink: 2}}
+}
+
+func (m *mountFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	vfs, name := m.resolve(name)
+	return vfs.OpenFile(name, flag, perm)
+}
+
+func (m *mountFS) Stat(name string) (fs.FileInfo, error) {
+	return m.stat(name, VFS.Stat)
+}
+
+func (m *mountFS) Lstat(name string) (fs.FileInfo, error) {
+	return m.stat(name, VFS.Lstat)
+}
+
+func (m *mountFS) stat(name string, stat func(VFS, string) (fs.FileInfo, error)) (fs.FileInfo, error) {
+	vfs, rel := m.resolve(name)
+	info, err := stat(vfs, rel)
+	if err != nil && len(m.mountChildren(name)) > 0 {
+		return mountDirInfo(path.Base(name)), nil
+	}
+	return info, err
+}
+
+func (m *mountFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	vfs, rel := m.resolve(name)
+	entries, err := vfs.ReadDir(rel)
+	children := m.mountChildren(name)
+	if len(children) == 0 {
+		return entries, err
+	}
+	for _, child := range children {
+		found := false
+		for _, e := range entries {
+			found = found || e.Name() == child
+		}
+		if !found {
+			entries = append(entries, fs.FileInfoToDirEntry(mountDirInfo(child)))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m *mountFS) Mkdir(name string, perm fs.FileMode) error {
+	vfs, name := m.resolve(name)
+	return vfs.Mkdir(name, perm)
+}
+
+func (m *mountFS) Remove(name string) error {
+	vfs, name := m.resolve(name)
+	return vfs.Remove(name)
+}
+
+// resolve2 resolves the names of a rename or link, which must be in
+// the same filesystem.
+func (m *mountFS) resolve2(op, oldname, newname string) (VFS, string, string, error) {
+	ovfs, orel := m.resolve(oldname)
+	nvfs, nrel := m.resolve(newname)
+	if ovfs != nvfs {
+		return nil, "", "", &os.LinkError{Op: op, Old: oldname, New: newname, Err: syscall.EXDEV}
+	}
+	return ovfs, orel, nrel, nil
+}
+
+func (m *mountFS) Rename(oldname, newname string) error {
+	vfs, oldname, newname, err := m.resolve2("rename", oldname, newname)
+	if err != nil {
+		return err
+	}
+	return vfs.Rename(oldname, newname)
+}
+
+func (m *mountFS) Link(oldname, newname string) error {
+	vfs, oldname, newname, err := m.resolve2("link", oldname, newname)
+	if err != nil {
+		return err
+	}
+	return vfs.Link(oldname, newname)
+}
+
+func (m *mountFS) Symlink(oldname, newname string) error {
+	vfs, newname := m.resolve(newname)
+	return vfs.Symlink(oldname, newname)
+}
+
+func (m *mountFS) Chmod(name string, mode fs.FileMode) error {
+	vfs, name := m.resolve(name)
+	return vfs.Chmod(name, mode)
+}
+
+func (m *mountFS) Chown(name string, uid, gid int) error {
+	vfs, name := m.resolve(name)
+	return vfs.Chown(name, uid, gid)
+}
+
+func (m *mountFS) Chtimes(name string, atime, mtime time.Time) error {
+	vfs, name := m.resolve(name)
+	return vfs.Chtimes(name, atime, mtime)
+}
+
+func (m *mountFS) Access(name string, mode uint32) error {
+	vfs, rel := m.resolve(name)
+	err := vfs.Access(rel, mode)
+	if err != nil && mode&2 == 0 && len(m.mountChildren(name)) > 0 {
+		return nil
+	}
+	return err
+}
+
+func (m *mountFS) Readlink(name string) (string, error) {
+	vfs, name := m.resolve(name)
+	return vfs.Readlink(name)
+}
+
+func (m *mountFS) Getwd() (string, error) {
+	if m.t.wd != "" {
+		return m.t.wd, nil
+	}
+	return m.base.Getwd()
+}
+
+func (m *mountFS) Chdir(dir string) error {
+	p, err := m.abs(dir)
+	if err != nil {
+		return err
+	}
+	vfs, rel := m.resolve(dir)
+	if vfs == m.base {
+		if err := m.base.Chdir(rel); err == nil {
+			m.t.wd = ""
+			return nil
+		} else if len(m.mountChildren(dir)) == 0 {
+			return err
+		}
+	} else {
+		info, err := vfs.Stat(rel)
+		switch {
+		case err != nil:
+			return err
+		case !info.IsDir():
+			return &fs.PathError{Op: "chdir", Path: dir, Err: syscall.ENOTDIR}
+		}
+	}
+	m.t.wd = p
+	return nil
+}
+
+// An fsVFS is a read-only VFS holding the files of an fs.FS. Names
+// are taken relative to the root of the fs.FS.
+type fsVFS struct {
+	fsys fs.FS
+}
+
+func readOnly(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: syscall.EROFS}
+}
+
+func (v *fsVFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		if _, err := v.Stat(name); err != nil && flag&os.O_CREATE == 0 {
+			return nil, err
+		}
+		return nil, readOnly("open", name)
+	}
+	f, err := v.fsys.Open(fsPath(name))
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	}
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return &fsFile{rs, f.Close}, nil
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &fsFile{bytes.NewReader(data), func() error { return nil }}, nil
+}
+
+func (v *fsVFS) Stat(name string) (fs.FileInfo, error)  { return fs.Stat(v.fsys, fsPath(name)) }
+func (v *fsVFS) Lstat(name string) (fs.FileInfo, error) { return v.Stat(name) }
+
+func (v *fsVFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(v.fsys, fsPath(name))
+}
+
+func (v *fsVFS) Mkdir(name string, perm fs.FileMode) error { return readOnly("mkdir", name) }
+func (v *fsVFS) Remove(name string) error                  { return readOnly("remove", name) }
+func (v *fsVFS) Chmod(name string, mode fs.FileMode) error { return readOnly("chmod", name) }
+func (v *fsVFS) Chown(name string, uid, gid int) error     { return readOnly("chown", name) }
+func (v *fsVFS) Symlink(oldname, newname string) error     { return readOnly("symlink", newname) }
+
+func (v *fsVFS) Rename(oldname, newname string) error {
+	return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.EROFS}
+}
+
+func (v *fsVFS) Link(oldname, newname string) error {
+	return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: syscall.EROFS}
+}
+
+func (v *fsVFS) Chtimes(name string, atime, mtime time.Time) error {
+	return readOnly("chtimes", name)
+}
+
+func (v *fsVFS) Access(name string, mode uint32) error {
+	info, err := v.Stat(name)
+	switch {
+	case err != nil:
+		return err
+	case mode&2 != 0:
+		return readOnly("access", name)
+	case mode&1 != 0 && !info.IsDir() && info.Mode()&0o111 == 0:
+		return &fs.PathError{Op: "access", Path: name, Err: syscall.EACCES}
+	}
+	return nil
+}
+
+func (v *fsVFS) Readlink(name string) (string, error) {
+	if _, err := v.Stat(name); err != nil {
+		return "", err
+	}
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: syscall.EINVAL}
+}
+
+func (v *fsVFS) Getwd() (string, error) { return "/", nil }
+
+func (v *fsVFS) Chdir(dir string) error {
+	return &fs.PathError{Op: "chdir", Path: dir, Err: syscall.ENOTSUP}
+}
+
+// An fsFile is an open file of an fsVFS.
+type fsFile struct {
+	io.ReadSeeker
+	close func() error
+}
+
+func (f *fsFile) Write(p []byte) (int, error) {
+	return 0, syscall.EBADF
+}
+
+func (f *fsFile) Close() error {
+	return f.close()
+}
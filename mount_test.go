@@ -0,0 +1,152 @@
+package gotcl
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMount(t *testing.T) {
+	interp := NewInterp()
+	interp.SetVFS(NewMemFS())
+	lib := fstest.MapFS{
+		"init.tcl":     {Data: []byte("set loaded init\n")},
+		"pkg/util.tcl": {Data: []byte("proc util {} { return util }\n")},
+	}
+	if err := interp.Mount("/opt/lib", lib); err != nil {
+		t.Fatal(err)
+	}
+	scratch := NewMemFS()
+	if err := interp.MountVFS("/scratch", scratch); err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.Mount("/opt/lib/", lib); err == nil || err.Error() != `a filesystem is already mounted at "/opt/lib/"` {
+		t.Errorf("duplicate mount: got %v", err)
+	}
+	if err := interp.Mount("lib", lib); err == nil || err.Error() != `mount point "lib" is not an absolute path` {
+		t.Errorf("relative mount: got %v", err)
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `source /opt/lib/init.tcl; set loaded`, result: "init"},
+		{script: `source /opt/lib/pkg/util.tcl; util`, result: "util"},
+		{script: `set f [open /opt/lib/init.tcl]; set line [gets $f]; close $f; set line`, result: "set loaded init"},
+		{script: `glob -tails -directory /opt/lib *`, result: "init.tcl pkg"},
+		{script: `glob /opt/lib/pkg/*.tcl`, result: "/opt/lib/pkg/util.tcl"},
+		{script: `glob -types d /*`, result: "/opt /scratch"},
+		{script: `list [file isdirectory /opt] [file isfile /opt/lib/init.tcl] [file size /opt/lib/init.tcl]`, result: "1 1 16"},
+		{script: `file writable /opt/lib/init.tcl`, result: "0"},
+		{script: `open /opt/lib/new.tcl w`, err: `couldn't open "/opt/lib/new.tcl": read-only file system`},
+		{script: `file delete /opt/lib/init.tcl`, err: `error deleting "/opt/lib/init.tcl": read-only file system`},
+		{script: `cd /opt/lib/pkg; list [pwd] [glob *]`, result: "/opt/lib/pkg util.tcl"},
+		{script: `cd ..; source init.tcl; pwd`, result: "/opt/lib"},
+		{script: `cd /; pwd`, result: "/"},
+
+		{script: `set f [open /scratch/out.txt w]; puts $f scratch; close $f; file size /scratch/out.txt`, result: "8"},
+		{script: `file copy /opt/lib/init.tcl /scratch; file size /scratch/init.tcl`, result: "16"},
+		{script: `file rename /scratch/out.txt /out.txt; list [file exists /scratch/out.txt] [file size /out.txt]`, result: "0 8"},
+		{script: `file link -hard /scratch/hard /out.txt`, err: `could not create new link "/scratch/hard" pointing to "/out.txt": invalid cross-device link`},
+	})
+	if _, err := scratch.Stat("/init.tcl"); err != nil {
+		t.Errorf("file copied into the mounted VFS: %v", err)
+	}
+
+	if err := interp.Unmount("/opt/lib"); err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.Unmount("/opt/lib"); err == nil || err.Error() != `no filesystem is mounted at "/opt/lib"` {
+		t.Errorf("second unmount: got %v", err)
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `file exists /opt/lib/init.tcl`, result: "0"},
+	})
+}
+
+// testZip returns a zip archive holding the named files.
+func testZip(t *testing.T, files map[string]string, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range names {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestZipfs(t *testing.T) {
+	files := map[string]string{
+		"main.tcl":     "set app main\n",
+		"lib/a.tcl":    "proc a {} { return a }\n",
+		"lib/data.txt": "data",
+	}
+	data := testZip(t, files, "main.tcl", "lib/a.tcl", "lib/data.txt")
+	interp := NewInterp()
+	fsys := NewMemFS()
+	interp.SetVFS(fsys)
+	if err := interp.MountZip("/app", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	f, err := fsys.OpenFile("/app.zip", 0o101, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(data)
+	f.Close()
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	if err := interp.SetVar("zipdata", "", string(runes)); err != nil {
+		t.Fatal(err)
+	}
+
+	runEvalTests(t, interp, []evalTest{
+		{script: `source /app/main.tcl; set app`, result: "main"},
+		{script: `glob -tails -directory /app/lib *`, result: "a.tcl data.txt"},
+		{script: `zipfs root`, result: "//zipfs:/"},
+		{script: `zipfs canonical lib/a.tcl`, result: "//zipfs:/lib/a.tcl"},
+		{script: `zipfs canonical app lib/a.tcl`, result: "//zipfs:/app/lib/a.tcl"},
+		{script: `zipfs canonical //zipfs:/x/y`, result: "//zipfs:/x/y"},
+		{script: `zipfs mount /app.zip app`, result: "//zipfs:/app"},
+		{script: `zipfs mountdata $zipdata mem`, result: "//zipfs:/mem"},
+		{script: `zipfs mount`, result: "//zipfs:/app /app.zip //zipfs:/mem {} /app {}"},
+		{script: `zipfs mount app`, result: "/app.zip"},
+		{script: `zipfs mount nothing`, result: ""},
+		{script: `zipfs mount /app.zip app`, err: `a filesystem is already mounted at "//zipfs:/app"`},
+		{script: `zipfs mount /app.zip other secret`, err: "encrypted archives are not supported"},
+		{script: `zipfs mount /none.zip other`, err: `couldn't open "/none.zip": no such file or directory`},
+		{script: `zipfs mountdata notazip other`, err: "archive directory end signature not found"},
+
+		{script: `zipfs list //zipfs:/app/*`, result: "//zipfs:/app/main.tcl //zipfs:/app/lib/a.tcl //zipfs:/app/lib/data.txt"},
+		{script: `zipfs list -regexp {mem/.*\.tcl$}`, result: "//zipfs:/mem/main.tcl //zipfs:/mem/lib/a.tcl"},
+		{script: `zipfs list -bogus x`, err: `bad option "-bogus": must be -glob or -regexp`},
+		{script: `list [zipfs exists //zipfs:/app/lib/a.tcl] [zipfs exists app/lib] [zipfs exists app/none]`, result: "1 1 0"},
+		{script: `lrange [zipfs info //zipfs:/app/lib/data.txt] 0 1`, result: "/app.zip 4"},
+		{script: `zipfs info //zipfs:/app/none`, err: `path "//zipfs:/app/none" not found in any zipfs volume`},
+		{script: `zipfs find //zipfs:/mem/lib`, result: "//zipfs:/mem/lib/a.tcl //zipfs:/mem/lib/data.txt"},
+
+		{script: `file split //zipfs:/app/lib`, result: "//zipfs:/ app lib"},
+		{script: `file join //zipfs:/ app main.tcl`, result: "//zipfs:/app/main.tcl"},
+		{script: `file dirname //zipfs:/app`, result: "//zipfs:/"},
+		{script: `file pathtype //zipfs:/app`, result: "absolute"},
+		{script: `source //zipfs:/app/lib/a.tcl; a`, result: "a"},
+		{script: `set f [open //zipfs:/mem/lib/data.txt]; set d [read $f]; close $f; set d`, result: "data"},
+		{script: `glob //zipfs:/*`, result: "//zipfs:/app //zipfs:/mem"},
+		{script: `cd //zipfs:/app/lib; glob *`, result: "a.tcl data.txt"},
+		{script: `cd /`},
+
+		{script: `zipfs unmount app; zipfs mount`, result: "//zipfs:/mem {} /app {}"},
+		{script: `zipfs unmount app`, err: `no filesystem is mounted at "//zipfs:/app"`},
+		{script: `file exists //zipfs:/app/main.tcl`, result: "0"},
+		{script: `zipfs bogus`, err: `unknown or ambiguous subcommand "bogus": must be canonical, exists, find, info, list, mount, mountdata, root, or unmount`},
+	})
+}
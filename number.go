@@ -0,0 +1,373 @@
+package gotcl
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// An intRep is the internal representation of an integer Value that
+// fits in 64 bits.
+type intRep int64
+
+func (i intRep) String() string { return strconv.FormatInt(int64(i), 10) }
+
+// A bigRep is the internal representation of an integer Value that
+// does not fit in 64 bits.
+type bigRep struct{ *big.Int }
+
+// A doubleRep is the internal representation of a floating-point
+// Value.
+type doubleRep float64
+
+func (d doubleRep) String() string { return formatDouble(float64(d)) }
+
+// NewIntValue returns an integer Value.
+func NewIntValue(i int64) *Value {
+	return newRepValue(intRep(i))
+}
+
+// NewBigIntValue returns an integer Value, which is stored in 64
+// bits if it fits.
+func NewBigIntValue(i *big.Int) *Value {
+	if i.IsInt64() {
+		return NewIntValue(i.Int64())
+	}
+	return newRepValue(bigRep{new(big.Int).Set(i)})
+}
+
+// NewDoubleValue returns a floating-point Value.
+func NewDoubleValue(f float64) *Value {
+	return newRepValue(doubleRep(f))
+}
+
+// NewBoolValue returns the Value 1 if b is true and 0 otherwise.
+func NewBoolValue(b bool) *Value {
+	if b {
+		return NewIntValue(1)
+	}
+	return NewIntValue(0)
+}
+
+// formatDouble formats f the way Tcl does: the shortest string that
+// reads back as f, always containing a decimal point or exponent so
+// that it is not mistaken for an integer.
+func formatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	mant, exp := s, 0
+	if i := strings.IndexByte(s, 'e'); i >= 0 {
+		mant = s[:i]
+		exp, _ = strconv.Atoi(s[i+1:])
+	}
+	neg := strings.HasPrefix(mant, "-")
+	digits := strings.Replace(strings.TrimPrefix(mant, "-"), ".", "", 1)
+	var b strings.Builder
+	if neg {
+		b.WriteByte('-')
+	}
+	switch {
+	case exp < -4 || exp >= 17:
+		b.WriteByte(digits[0])
+		if len(digits) > 1 {
+			b.WriteByte('.')
+			b.WriteString(digits[1:])
+		}
+		fmt.Fprintf(&b, "e%+03d", exp)
+	case exp < 0:
+		b.WriteString("0.")
+		b.WriteString(strings.Repeat("0", -exp-1))
+		b.WriteString(digits)
+	case exp+1 >= len(digits):
+		b.WriteString(digits)
+		b.WriteString(strings.Repeat("0", exp+1-len(digits)))
+		b.WriteString(".0")
+	default:
+		b.WriteString(digits[:exp+1])
+		b.WriteByte('.')
+		b.WriteString(digits[exp+1:])
+	}
+	return b.String()
+}
+
+const tclSpace = " \t\n\v\f\r"
+
+// parseInteger parses s as a Tcl integer: optional surrounding white
+// space, an optional sign, then decimal digits or hexadecimal, octal
+// or binary digits introduced by 0x, 0o or 0b (0d introduces
+// decimal). Digits may be separated by single underscores. It
+// returns the number of leading runes that form a valid prefix when
+// s is not an integer.
+func parseInteger(s string) (*big.Int, int, bool) {
+	t := strings.TrimLeft(s, tclSpace)
+	pos := len(s) - len(t)
+	t = strings.TrimRight(t, tclSpace)
+	neg := false
+	if len(t) > 0 && (t[0] == '+' || t[0] == '-') {
+		neg = t[0] == '-'
+		t = t[1:]
+		pos++
+	}
+	base := 10
+	if len(t) > 2 && t[0] == '0' {
+		switch t[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		case 'd', 'D':
+			base = 10
+		}
+		if strings.ContainsRune("xXoObBdD", rune(t[1])) {
+			t = t[2:]
+			pos += 2
+		}
+	}
+	digits := make([]byte, 0, len(t))
+	for i := 0; i < len(t); i++ {
+		c := t[i]
+		if c == '_' && i > 0 && i+1 < len(t) && t[i-1] != '_' && digitValue(t[i+1]) < base {
+			continue
+		}
+		if digitValue(c) >= base {
+			return nil, pos + i, false
+		}
+		digits = append(digits, c)
+	}
+	if len(digits) == 0 {
+		return nil, pos, false
+	}
+	i, ok := new(big.Int).SetString(string(digits), base)
+	if !ok {
+		return nil, pos, false
+	}
+	if neg {
+		i.Neg(i)
+	}
+	return i, len(s), true
+}
+
+func digitValue(c byte) int {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0')
+	case 'a' <= c && c <= 'z':
+		return int(c-'a') + 10
+	case 'A' <= c && c <= 'Z':
+		return int(c-'A') + 10
+	}
+	return 36
+}
+
+// parseDouble parses s as a Tcl floating-point number, which is any
+// integer, a decimal number with optional fraction and exponent, or
+// Inf or NaN, with optional surrounding white space.
+func parseDouble(s string) (float64, bool) {
+	if i, _, ok := parseInteger(s); ok {
+		f, _ := new(big.Float).SetInt(i).Float64()
+		return f, true
+	}
+	t := strings.Trim(s, tclSpace)
+	if t == "" || strings.ContainsAny(t, "_xXpP") {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(t, 64)
+	if err != nil {
+		if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+			return f, true
+		}
+		return 0, false
+	}
+	return f, true
+}
+
+// Int returns v as an integer that fits in 64 bits.
+func (v *Value) Int() (int64, error) {
+	switch rep := v.rep.(type) {
+	case intRep:
+		return int64(rep), nil
+	case bigRep:
+		return 0, fmt.Errorf("integer value too large to represent")
+	}
+	i, err := v.BigInt()
+	if err != nil {
+		return 0, err
+	}
+	if !i.IsInt64() {
+		return 0, fmt.Errorf("integer value too large to represent")
+	}
+	return i.Int64(), nil
+}
+
+// BigInt returns v as an arbitrary precision integer.
+func (v *Value) BigInt() (*big.Int, error) {
+	switch rep := v.rep.(type) {
+	case intRep:
+		return big.NewInt(int64(rep)), nil
+	case bigRep:
+		return new(big.Int).Set(rep.Int), nil
+	}
+	i, _, ok := parseInteger(v.String())
+	if !ok {
+		return nil, fmt.Errorf("expected integer but got \"%s\"", v)
+	}
+	if i.IsInt64() {
+		v.setRep(intRep(i.Int64()))
+	} else {
+		v.setRep(bigRep{i})
+	}
+	return new(big.Int).Set(i), nil
+}
+
+// Double returns v as a floating-point number.
+func (v *Value) Double() (float64, error) {
+	switch rep := v.rep.(type) {
+	case doubleRep:
+		return float64(rep), nil
+	case intRep:
+		return float64(rep), nil
+	case bigRep:
+		f, _ := new(big.Float).SetInt(rep.Int).Float64()
+		return f, nil
+	}
+	f, ok := parseDouble(v.String())
+	if !ok {
+		return 0, fmt.Errorf("expected floating-point number but got \"%s\"", v)
+	}
+	v.setRep(doubleRep(f))
+	return f, nil
+}
+
+// Bool returns v as a boolean. Any number is a boolean, as are
+// unique abbreviations of true, false, yes, no, on and off,
+// regardless of case.
+func (v *Value) Bool() (bool, error) {
+	if b, ok := parseBoolean(v.String()); ok {
+		return b, nil
+	}
+	return false, fmt.Errorf("expected boolean value but got \"%s\"", v)
+}
+
+func parseBoolean(s string) (bool, bool) {
+	if f, ok := parseDouble(s); ok && !math.IsNaN(f) {
+		return f != 0, true
+	}
+	t := strings.ToLower(strings.Trim(s, tclSpace))
+	if t == "" {
+		return false, false
+	}
+	for _, word := range []struct {
+		name  string
+		value bool
+		min   int
+	}{
+		{"true", true, 1},
+		{"false", false, 1},
+		{"yes", true, 1},
+		{"no", false, 1},
+		{"on", true, 2},
+		{"off", false, 2},
+	} {
+		if len(t) >= word.min && strings.HasPrefix(word.name, t) {
+			return word.value, true
+		}
+	}
+	return false, false
+}
+
+// getInt returns v as an int, for use as a count or index.
+func getInt(v *Value) (int, error) {
+	i, err := v.Int()
+	if err != nil {
+		return 0, err
+	}
+	if i > math.MaxInt32 || i < math.MinInt32 {
+		return 0, fmt.Errorf("integer value too large to represent")
+	}
+	return int(i), nil
+}
+
+// getIndex parses v as an index into a sequence whose last element
+// is at end. Indices are integers, "end", or either of those followed
+// by +N or -N. The returned index may lie outside of 0..end.
+func getIndex(v *Value, end int) (int, error) {
+	s := v.String()
+	bad := fmt.Errorf("bad index \"%s\": must be integer?[+-]integer? or end?[+-]integer?", s)
+	base, rest := 0, s
+	if strings.HasPrefix(s, "end") {
+		base, rest = end, s[3:]
+		if rest == "" {
+			return end, nil
+		}
+		if rest[0] != '+' && rest[0] != '-' {
+			return 0, bad
+		}
+	} else {
+		// Split M+N or M-N at an operator that is not a leading
+		// sign.
+		split := -1
+		for i := 1; i < len(s); i++ {
+			if s[i] == '+' || s[i] == '-' {
+				split = i
+				break
+			}
+		}
+		if split < 0 {
+			i, _, ok := parseInteger(s)
+			if !ok || strings.TrimSpace(s) != s {
+				return 0, bad
+			}
+			return clampIndex(i), nil
+		}
+		m, _, ok := parseInteger(s[:split])
+		if !ok || strings.TrimSpace(s[:split]) != s[:split] {
+			return 0, bad
+		}
+		base, rest = clampIndex(m), s[split:]
+	}
+	n, _, ok := parseInteger(rest[1:])
+	if !ok || strings.TrimSpace(rest[1:]) != rest[1:] || rest[1] == '+' || rest[1] == '-' {
+		return 0, bad
+	}
+	offset := clampIndex(n)
+	if rest[0] == '-' {
+		offset = -offset
+	}
+	return base + offset, nil
+}
+
+// clampIndex converts i to an int, clamping values that are too
+// large to be meaningful indices.
+func clampIndex(i *big.Int) int {
+	switch {
+	case i.Cmp(big.NewInt(math.MaxInt32)) > 0:
+		return math.MaxInt32
+	case i.Cmp(big.NewInt(math.MinInt32)) < 0:
+		return math.MinInt32
+	}
+	return int(i.Int64())
+}
+
+// incrValue returns the sum of the integers v and inc.
+func incrValue(v, inc *Value) (*Value, error) {
+	x, err := v.BigInt()
+	if err != nil {
+		return nil, err
+	}
+	y, err := inc.BigInt()
+	if err != nil {
+		return nil, err
+	}
+	return NewBigIntValue(x.Add(x, y)), nil
+}
@@ -0,0 +1,612 @@
+package gotcl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ooState holds the objects of an interpreter.
+type ooState struct {
+	objects map[*command]*object
+	nextID  int
+	// object and class are the root class oo::object and the
+	// metaclass oo::class.
+	object *class
+	class  *class
+}
+
+// An object is a TclOO object. Every object is an instance of a
+// class, and may have methods, mixins and filters of its own. The
+// variables of an object are kept as global variables under the
+// object's namespace, so that they can be named from anywhere.
+type object struct {
+	cmd *command
+	// ns is the namespace holding the variables of the object.
+	ns      string
+	class   *class
+	methods map[string]*method
+	mixins  []*class
+	filters []string
+	vars    []string
+	// classDef is set if the object is a class.
+	classDef *class
+	// inFilter is non-zero while a filter of the object runs;
+	// filters are not applied to the calls it makes.
+	inFilter  int
+	destroyed bool
+}
+
+// A class is the class part of an object that is a class.
+type class struct {
+	obj          *object
+	superclasses []*class
+	subclasses   []*class
+	instances    []*object
+	mixins       []*class
+	filters      []string
+	methods      map[string]*method
+	vars         []string
+	constructor  *method
+	destructor   *method
+}
+
+// A method is a method of a class or object. Exactly one of proc,
+// forward and native is set, unless the method only records the
+// visibility given to a name by export or unexport.
+type method struct {
+	name     string
+	exported bool
+	proc     *procedure
+	forward  []*Value
+	native   func(interp *Interp, ctx *methodContext, args []*Value) (*Value, error)
+	// class is the class that declares the method, or nil for a
+	// method of the object obj.
+	class *class
+	obj   *object
+}
+
+// A chainEntry is one implementation in a method call chain.
+type chainEntry struct {
+	m      *method
+	filter bool
+}
+
+// A methodContext describes a method invocation: the object, the
+// chain of implementations of the method and the position in it of
+// the implementation running. next continues along the chain.
+type methodContext struct {
+	obj *object
+	// name is the name of the method, and kind is "method",
+	// "constructor" or "destructor".
+	name  string
+	kind  string
+	chain []chainEntry
+	index int
+	// prefix holds the words the method was invoked with, ahead
+	// of its arguments, for wrong # args messages.
+	prefix []*Value
+	// private is set for calls made with my, which may invoke
+	// unexported methods.
+	private bool
+}
+
+// isImpl reports whether m has an implementation.
+func (m *method) isImpl() bool {
+	return m.proc != nil || m.forward != nil || m.native != nil
+}
+
+// methodType returns the type of m as reported by info.
+func (m *method) methodType() string {
+	switch {
+	case m.forward != nil:
+		return "forward"
+	case m.native != nil:
+		return "core"
+	}
+	return "method"
+}
+
+// declarer returns the name of the class or object declaring m.
+func (m *method) declarer() string {
+	if m.class != nil {
+		return m.class.obj.name()
+	}
+	return m.obj.name()
+}
+
+// isExportedName reports whether a method called name is exported
+// by default, which it is if it begins with a lower case letter.
+func isExportedName(name string) bool {
+	return name != "" && name[0] >= 'a' && name[0] <= 'z'
+}
+
+// name returns the fully qualified name of obj.
+func (obj *object) name() string {
+	return "::" + obj.cmd.name
+}
+
+// linearize appends to out c and the classes it inherits from, in
+// the order their methods are searched: the mixins of a class come
+// before it and its superclasses after it.
+func (c *class) linearize(out []*class, visiting map[*class]bool) []*class {
+	if visiting[c] {
+		return out
+	}
+	visiting[c] = true
+	for _, m := range c.mixins {
+		out = m.linearize(out, visiting)
+	}
+	out = append(out, c)
+	for _, s := range c.superclasses {
+		out = s.linearize(out, visiting)
+	}
+	delete(visiting, c)
+	return out
+}
+
+// dedupClasses removes all but the last occurrence of each class
+// from cs, so that a class inherited along several paths comes after
+// all the classes inheriting from it.
+func dedupClasses(cs []*class) []*class {
+	last := map[*class]int{}
+	for i, c := range cs {
+		last[c] = i
+	}
+	out := cs[:0]
+	for i, c := range cs {
+		if c == nil || last[c] == i {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// ancestors returns c and all the classes it inherits from.
+func (c *class) ancestors() []*class {
+	return dedupClasses(c.linearize(nil, map[*class]bool{}))
+}
+
+// isSubclassOf reports whether c is other or inherits from it.
+func (c *class) isSubclassOf(other *class) bool {
+	for _, a := range c.ancestors() {
+		if a == other {
+			return true
+		}
+	}
+	return false
+}
+
+// methodOrder returns where the methods of obj are looked up, in
+// order: its mixins, the object itself, which is represented by nil,
+// and then its class and the classes that class inherits from.
+func (obj *object) methodOrder() []*class {
+	var order []*class
+	visiting := map[*class]bool{}
+	for _, m := range obj.mixins {
+		order = m.linearize(order, visiting)
+	}
+	order = append(order, nil)
+	order = obj.class.linearize(order, visiting)
+	return dedupClasses(order)
+}
+
+// methodsOf returns the methods declared by c, or by obj if c is nil.
+func (obj *object) methodsOf(c *class) map[string]*method {
+	if c == nil {
+		return obj.methods
+	}
+	return c.methods
+}
+
+// methodChain returns the implementations of the method name of obj,
+// most specific first, and whether the method is exported, which is
+// decided by the most specific declaration of the name.
+func (obj *object) methodChain(name string) ([]chainEntry, bool) {
+	var chain []chainEntry
+	exported, decided := false, false
+	for _, c := range obj.methodOrder() {
+		m := obj.methodsOf(c)[name]
+		if m == nil {
+			continue
+		}
+		if !decided {
+			exported, decided = m.exported, true
+		}
+		if m.isImpl() {
+			chain = append(chain, chainEntry{m: m})
+		}
+	}
+	return chain, exported
+}
+
+// filterNames returns the names of the filters applying to obj: its
+// own, then those of its classes.
+func (obj *object) filterNames() []string {
+	var names []string
+	seen := map[string]bool{}
+	add := func(filters []string) {
+		for _, f := range filters {
+			if !seen[f] {
+				seen[f] = true
+				names = append(names, f)
+			}
+		}
+	}
+	add(obj.filters)
+	for _, c := range obj.methodOrder() {
+		if c != nil {
+			add(c.filters)
+		}
+	}
+	return names
+}
+
+// callChain returns the call chain of the method name of obj,
+// starting with its filters, and whether the method may be invoked.
+func (obj *object) callChain(name string, private bool) ([]chainEntry, bool) {
+	chain, exported := obj.methodChain(name)
+	if len(chain) == 0 || !(private || exported) {
+		return nil, false
+	}
+	if obj.inFilter > 0 {
+		return chain, true
+	}
+	var filtered []chainEntry
+	for _, f := range obj.filterNames() {
+		fchain, _ := obj.methodChain(f)
+		for _, e := range fchain {
+			filtered = append(filtered, chainEntry{m: e.m, filter: true})
+		}
+	}
+	return append(filtered, chain...), true
+}
+
+// methodNames returns the sorted names of the methods of obj that
+// may be invoked, including unexported ones if private is set.
+func (obj *object) methodNames(private bool) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, c := range obj.methodOrder() {
+		for name := range obj.methodsOf(c) {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if chain, exported := obj.methodChain(name); len(chain) > 0 && (private || exported) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupObject returns the object whose command is name.
+func (interp *Interp) lookupObject(name *Value) (*object, error) {
+	if cmd, ok := interp.commands[commandName(name.String())]; ok {
+		if obj, ok := interp.oo.objects[cmd]; ok {
+			return obj, nil
+		}
+	}
+	return nil, fmt.Errorf("%s does not refer to an object", name)
+}
+
+// lookupClass returns the class whose command is name.
+func (interp *Interp) lookupClass(name *Value) (*class, error) {
+	obj, err := interp.lookupObject(name)
+	if err != nil {
+		return nil, err
+	}
+	if obj.classDef == nil {
+		return nil, fmt.Errorf("\"%s\" is not a class", name)
+	}
+	return obj.classDef, nil
+}
+
+// objectCommand implements the command of obj.
+func (interp *Interp) objectCommand(obj *object, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "method ?arg ...?")
+	}
+	return interp.invokeMethod(obj, args[1].String(), args[2:], args[:2], false)
+}
+
+// invokeMethod invokes the method name of obj with args. prefix holds
+// the words naming the method. A method that does not exist, or may
+// not be invoked, is passed to the unknown method instead.
+func (interp *Interp) invokeMethod(obj *object, name string, args, prefix []*Value, private bool) (*Value, error) {
+	ctx, args := obj.methodCall(name, args, prefix, private)
+	return interp.callChainEntry(ctx, args)
+}
+
+// methodCall returns the context for invoking the method name of obj
+// with args, and the arguments to pass to its first implementation,
+// as invokeMethod does.
+func (obj *object) methodCall(name string, args, prefix []*Value, private bool) (*methodContext, []*Value) {
+	ctx := &methodContext{obj: obj, name: name, kind: "method", prefix: prefix, private: private}
+	chain, ok := obj.callChain(name, private)
+	if !ok {
+		ctx.name, ctx.prefix = "unknown", prefix[:len(prefix)-1]
+		chain, _ = obj.methodChain("unknown")
+		args = append([]*Value{NewStringValue(name)}, args...)
+	}
+	ctx.chain = chain
+	return ctx, args
+}
+
+// methodTarget resolves the method of obj invoked by args, with
+// prefix naming it, to its procedure for tailcall. Methods that are
+// not procedures, and filters, are invoked as usual.
+func methodTarget(obj *object, name string, args, prefix []*Value, private bool) (*procedure, []*Value, *methodContext) {
+	ctx, args := obj.methodCall(name, args, prefix, private)
+	e := ctx.chain[ctx.index]
+	if e.filter || e.m.proc == nil {
+		return nil, nil, nil
+	}
+	return e.m.proc, ctx.procArgs(args), ctx
+}
+
+// callChainEntry invokes the implementation at the position of ctx
+// in its chain with args.
+func (interp *Interp) callChainEntry(ctx *methodContext, args []*Value) (*Value, error) {
+	e := ctx.chain[ctx.index]
+	if e.filter {
+		ctx.obj.inFilter++
+		defer func() { ctx.obj.inFilter-- }()
+	}
+	m := e.m
+	switch {
+	case m.native != nil:
+		return m.native(interp, ctx, args)
+	case m.forward != nil:
+		// A forward runs in the frame of its caller, but with the
+		// object as context so that my refers to it.
+		caller := interp.frame
+		frame := *caller
+		frame.method = ctx
+		interp.frame = &frame
+		defer func() { interp.frame = caller }()
+		words := append(append([]*Value(nil), m.forward...), args...)
+		return interp.invoke(words)
+	}
+	return interp.callProc(m.proc, ctx.procArgs(args), ctx)
+}
+
+// procArgs returns the words to bind the procedure of a method of ctx
+// to for args, named by the prefix of ctx.
+func (ctx *methodContext) procArgs(args []*Value) []*Value {
+	words := make([]*Value, 0, len(args)+1)
+	words = append(words, NewStringValue(strings.Join(valuesToStrings(ctx.prefix), " ")))
+	return append(words, args...)
+}
+
+// bindMethodFrame prepares frame for running the method of ctx,
+// linking the variables declared by the class or object declaring
+// the method, unless the frame has arguments of the same names.
+func (interp *Interp) bindMethodFrame(frame *callFrame, ctx *methodContext) error {
+	frame.method = ctx
+	m := ctx.chain[ctx.index].m
+	var names []string
+	if m.class != nil {
+		names = m.class.vars
+	} else {
+		names = m.obj.vars
+	}
+	for _, name := range names {
+		if _, ok := frame.vars[name]; ok {
+			continue
+		}
+		vr, err := ctx.obj.variable(interp, name)
+		if err != nil {
+			return err
+		}
+		frame.vars[name] = vr
+		frame.link(name)
+	}
+	return nil
+}
+
+// variable returns the variable called name of obj, creating it
+// undefined if there is none, marked as linked into a frame.
+func (obj *object) variable(interp *Interp, name string) (*variable, error) {
+	vr, err := interp.frameVar(interp.globalFrame, obj.varName(name))
+	if err != nil {
+		return nil, err
+	}
+	vr.linked = true
+	return vr, nil
+}
+
+// varName returns the fully qualified name of the variable called
+// name of obj.
+func (obj *object) varName(name string) string {
+	return "::" + obj.ns + "::" + name
+}
+
+// newObject creates an object of class c with the command name, or a
+// generated name if name is empty.
+func (interp *Interp) newObject(c *class, name string) (*object, error) {
+	interp.oo.nextID++
+	ns := fmt.Sprintf("oo::Obj%d", interp.oo.nextID)
+	if name == "" {
+		name = ns
+	}
+	key := commandName(name)
+	if _, ok := interp.commands[key]; ok {
+		return nil, fmt.Errorf("can't create object \"%s\": command already exists with that name", name)
+	}
+	obj := &object{ns: ns, class: c, methods: map[string]*method{}}
+	interp.registerObject(obj, key)
+	c.instances = append(c.instances, obj)
+	if c.isSubclassOf(interp.oo.class) {
+		obj.classDef = &class{
+			obj:          obj,
+			superclasses: []*class{interp.oo.object},
+			methods:      map[string]*method{},
+		}
+		interp.oo.object.subclasses = append(interp.oo.object.subclasses, obj.classDef)
+	}
+	return obj, nil
+}
+
+// registerObject creates the command of obj, called name.
+func (interp *Interp) registerObject(obj *object, name string) {
+	obj.cmd = &command{
+		name: name,
+		fn: func(interp *Interp, args []*Value) (*Value, error) {
+			return interp.objectCommand(obj, args)
+		},
+		kind: "object",
+		tail: func(interp *Interp, args []*Value, method *methodContext) (*procedure, []*Value, *methodContext) {
+			if len(args) < 2 {
+				return nil, nil, nil
+			}
+			return methodTarget(obj, args[1].String(), args[2:], args[:2], false)
+		},
+		deleteProc: func() {
+			if err := interp.destroyObject(obj); err != nil {
+				interp.backgroundError(err)
+			}
+		},
+	}
+	interp.commands[name] = obj.cmd
+	interp.oo.objects[obj.cmd] = obj
+}
+
+// construct runs the constructors of the new object obj with args,
+// deleting the object if they fail. prefix holds the words that
+// created the object.
+func (interp *Interp) construct(obj *object, args, prefix []*Value) (*Value, error) {
+	var chain []chainEntry
+	for _, c := range obj.class.ancestors() {
+		if c.constructor != nil {
+			chain = append(chain, chainEntry{m: c.constructor})
+		}
+	}
+	var err error
+	switch {
+	case len(chain) > 0:
+		ctx := &methodContext{obj: obj, name: "<constructor>", kind: "constructor", chain: chain, prefix: prefix}
+		_, err = interp.callChainEntry(ctx, args)
+	case len(args) > 0:
+		err = wrongNumArgs(prefix, len(prefix), "")
+	}
+	if err != nil {
+		// The object is deleted without running its destructors.
+		obj.destroyed = true
+		interp.deleteObject(obj)
+		return nil, err
+	}
+	return NewStringValue(obj.name()), nil
+}
+
+// destroyObject runs the destructors of obj and deletes it. The
+// error of a destructor is returned once the object is deleted.
+func (interp *Interp) destroyObject(obj *object) error {
+	if obj.destroyed {
+		return nil
+	}
+	obj.destroyed = true
+	var err error
+	if !interp.deleted {
+		var chain []chainEntry
+		for _, c := range obj.methodOrder() {
+			if c != nil && c.destructor != nil {
+				chain = append(chain, chainEntry{m: c.destructor})
+			}
+		}
+		if len(chain) > 0 {
+			ctx := &methodContext{obj: obj, name: "<destructor>", kind: "destructor", chain: chain, prefix: []*Value{NewStringValue(obj.name())}}
+			_, err = interp.callChainEntry(ctx, nil)
+		}
+	}
+	interp.deleteObject(obj)
+	return err
+}
+
+// deleteObject deletes obj, its command and its variables. Deleting
+// a class also destroys its instances and subclasses.
+func (interp *Interp) deleteObject(obj *object) {
+	if c := obj.classDef; c != nil {
+		for _, inst := range append([]*object(nil), c.instances...) {
+			interp.deleteObjectCommand(inst)
+		}
+		for _, sub := range append([]*class(nil), c.subclasses...) {
+			interp.deleteObjectCommand(sub.obj)
+		}
+		for _, s := range c.superclasses {
+			s.subclasses = removeClass(s.subclasses, c)
+		}
+		for _, other := range interp.oo.objects {
+			other.mixins = removeClass(other.mixins, c)
+			if other.classDef != nil {
+				other.classDef.mixins = removeClass(other.classDef.mixins, c)
+			}
+		}
+	}
+	obj.class.instances = removeObject(obj.class.instances, obj)
+	delete(interp.oo.objects, obj.cmd)
+	prefix := obj.ns + "::"
+	for key := range interp.globals {
+		if strings.HasPrefix(key, prefix) {
+			delete(interp.globals, key)
+		}
+	}
+	if interp.commands[obj.cmd.name] == obj.cmd {
+		interp.removeCommand(obj.cmd)
+	}
+}
+
+// deleteObjectCommand deletes the command of obj, which destroys it.
+func (interp *Interp) deleteObjectCommand(obj *object) {
+	if interp.commands[obj.cmd.name] == obj.cmd {
+		interp.removeCommand(obj.cmd)
+		return
+	}
+	if err := interp.destroyObject(obj); err != nil {
+		interp.backgroundError(err)
+	}
+}
+
+func removeClass(cs []*class, c *class) []*class {
+	for i, x := range cs {
+		if x == c {
+			return append(cs[:i:i], cs[i+1:]...)
+		}
+	}
+	return cs
+}
+
+func removeObject(objs []*object, obj *object) []*object {
+	for i, x := range objs {
+		if x == obj {
+			return append(objs[:i:i], objs[i+1:]...)
+		}
+	}
+	return objs
+}
+
+// initOO creates the classes oo::object and oo::class.
+func (interp *Interp) initOO() {
+	interp.oo.objects = map[*command]*object{}
+	rootObj := &object{ns: "oo::object", methods: map[string]*method{}}
+	classObj := &object{ns: "oo::class", methods: map[string]*method{}}
+	root := &class{obj: rootObj, methods: map[string]*method{}}
+	meta := &class{obj: classObj, superclasses: []*class{root}, methods: map[string]*method{}}
+	rootObj.class, rootObj.classDef = meta, root
+	classObj.class, classObj.classDef = meta, meta
+	root.subclasses = []*class{meta}
+	meta.instances = []*object{rootObj, classObj}
+	interp.oo.object, interp.oo.class = root, meta
+	interp.registerObject(rootObj, "oo::object")
+	interp.registerObject(classObj, "oo::class")
+
+	for _, m := range objectMethods {
+		root.methods[m.name] = &method{name: m.name, exported: m.exported, native: m.fn, class: root}
+	}
+	for _, m := range classMethods {
+		meta.methods[m.name] = &method{name: m.name, exported: m.exported, native: m.fn, class: meta}
+	}
+	meta.constructor = &method{name: "<constructor>", native: classConstructor, class: meta}
+}
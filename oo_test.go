@@ -0,0 +1,163 @@
+package gotcl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOOClass(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `oo::class create Counter {
+			variable count
+			constructor {{start 0}} {set count $start}
+			method incr {} {lappend count x; llength $count}
+			method value {} {set count}
+			method Secret {} {list secret}
+			method reveal {} {my Secret}
+		}`, result: "::Counter"},
+		{script: `Counter create c`, result: "::c"},
+		{script: `c incr; c incr`, result: "3"},
+		{script: `c value`, result: "0 x x"},
+		{script: `c reveal`, result: "secret"},
+		{script: `c Secret`, err: `unknown method "Secret": must be destroy, incr, reveal or value`},
+		{script: `c bogus`, err: `unknown method "bogus": must be destroy, incr, reveal or value`},
+		{script: `c`, err: `wrong # args: should be "c method ?arg ...?"`},
+		{script: `set o [Counter new 5]; $o value`, result: "5"},
+		{script: `$o destroy; $o value`, err: `invalid command name "::oo::Obj3"`},
+		{script: `Counter create c2 1 2`, err: `wrong # args: should be "Counter create c2 ?start?"`},
+		{script: `c2 value`, err: `invalid command name "c2"`},
+		{script: `Counter create c`, err: `can't create object "c": command already exists with that name`},
+		{script: `Counter create`, err: `wrong # args: should be "Counter create objectName ?arg ...?"`},
+		{script: `c destroy; c value`, err: `invalid command name "c"`},
+		{script: `oo::class create Empty; Empty new 1`, err: `wrong # args: should be "Empty new"`},
+		{script: `oo::define Nothing method x {} {}`, err: `Nothing does not refer to an object`},
+		{script: `oo::define Counter bogus`, err: `invalid command name "bogus"`},
+	})
+}
+
+func TestOOInheritance(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `oo::class create A {
+			constructor {} {lappend ::log A}
+			destructor {lappend ::log ~A}
+			method who {} {list A}
+			method chain {} {list A}
+		}`, result: "::A"},
+		{script: `oo::class create B {
+			superclass A
+			constructor {} {lappend ::log B; next}
+			destructor {lappend ::log ~B; next}
+			method chain {} {list B {*}[next]}
+		}`, result: "::B"},
+		{script: `oo::class create C {
+			superclass A
+			method chain {} {list C {*}[next]}
+		}`, result: "::C"},
+		{script: `oo::class create D {
+			superclass B C
+			method chain {} {list D {*}[next]}
+			method skip {} {nextto A}
+		}`, result: "::D"},
+		{script: `D create d; set log`, result: "B A"},
+		{script: `d chain`, result: "D B C A"},
+		{script: `d who`, result: "A"},
+		{script: `d skip`, err: `method has no non-filter implementation by "A"`},
+		{script: `set log {}; d destroy; set log`, result: "~B ~A"},
+		{script: `info class superclasses D`, result: "::B ::C"},
+		{script: `info class subclasses A`, result: "::B ::C"},
+		{script: `oo::define A superclass D`, err: `attempt to form circular dependency graph`},
+		{script: `oo::class create Top {method m {} {next}}; [Top new] m`, err: `no next method implementation`},
+		{script: `oo::define D method skip {} {list [nextto A]}; oo::define D method chain {} {nextto C}; D create e; e chain`, result: "C A"},
+		{script: `A destroy; info object isa object e`, result: "0"},
+		{script: `info object isa object B`, result: "0"},
+	})
+}
+
+func TestOOMixinFilterForward(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `oo::class create Logged {method greet {name} {list logged {*}[next $name]}}`, result: "::Logged"},
+		{script: `oo::class create Greeter {
+			method greet {name} {list hello $name}
+			method Trace args {lappend ::calls [lindex [self target] 1]; next {*}$args}
+			filter Trace
+			forward shout string toupper
+			forward me my greet
+		}`, result: "::Greeter"},
+		{script: `Greeter create g; g greet bob`, result: "hello bob"},
+		{script: `set calls`, result: "greet"},
+		{script: `oo::objdefine g mixin Logged; g greet ann`, result: "logged hello ann"},
+		{script: `g shout hi`, result: "HI"},
+		{script: `g me you`, result: "logged hello you"},
+		{script: `info object mixins g`, result: "::Logged"},
+		{script: `info object isa mixin g Logged`, result: "1"},
+		{script: `info object isa typeof g Logged`, result: "1"},
+		{script: `oo::define Greeter filter -clear; set calls {}; g greet x; set calls`, result: ""},
+		{script: `oo::define Greeter mixin Greeter`, err: `may not mix a class into itself`},
+		{script: `info class forward Greeter shout`, result: "string toupper"},
+		{script: `info class methodtype Greeter shout`, result: "forward"},
+	})
+}
+
+func TestOOExportSelfVariables(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `oo::class create P {
+			method hidden {} {list h}
+			method Visible {} {list v}
+			unexport hidden
+			export Visible
+			method me {} {list [self] [self class] [self method] [self namespace]}
+			method store {v} {my variable data; set data $v}
+			method name {} {my varname data}
+		}`, result: "::P"},
+		{script: `P create p; p Visible`, result: "v"},
+		{script: `p hidden`, err: `unknown method "hidden": must be Visible, destroy, me, name or store`},
+		{script: `p me`, result: "::p ::P me ::oo::Obj2"},
+		{script: `p store 42; set [p name]`, result: "42"},
+		{script: `info object vars p`, result: "data"},
+		{script: `info object namespace p`, result: "::oo::Obj2"},
+		{script: `p eval self`, err: `unknown method "eval": must be Visible, destroy, me, name or store`},
+		{script: `oo::objdefine p export eval; p eval {my store 7; self}`, result: "::p"},
+		{script: `set [p name]`, result: "7"},
+		{script: `oo::objdefine p method solo {} {list solo [self]}; p solo`, result: "solo ::p"},
+		{script: `oo::objdefine p {variable data; method peek {} {set data}}; p peek`, result: "7"},
+		{script: `oo::define P self method make {} {my new}; info object isa object [P make]`, result: "1"},
+		{script: `info object methods p`, result: "peek solo"},
+		{script: `info object methods p -all`, result: "Visible destroy eval me name peek solo store"},
+		{script: `info class methods P -private`, result: "Visible hidden me name store"},
+		{script: `info class definition P store`, result: "v {my variable data; set data $v}"},
+		{script: `info object class p`, result: "::P"},
+		{script: `info object class p oo::object`, result: "1"},
+		{script: `info object isa class P`, result: "1"},
+		{script: `info object isa metaclass oo::class`, result: "1"},
+		{script: `info class instances P ::p`, result: "::p"},
+		{script: `self`, err: `self may only be called from inside a method`},
+		{script: `next`, err: `next may only be called from inside a method`},
+		{script: `my x`, err: `invalid command name "my"`},
+		{script: `p destroy; info object vars p`, err: `p does not refer to an object`},
+		{script: `oo::define P variable a(1)`, err: `invalid declared variable name "a(1)": must not refer to an array element`},
+		{script: `info object bogus p`, err: `unknown or ambiguous subcommand "bogus": must be class, definition, filters, forward, isa, methods, methodtype, mixins, namespace, variables, or vars`},
+	})
+}
+
+func TestOOTailcall(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `oo::class create Walker {
+			variable steps
+			method run {} {tailcall my Helper 1}
+			method Helper {x} {list helped $x [self]}
+			method who {} {tailcall self}
+			method walk {s} {lappend steps x; tailcall my [lindex {walk Done} [string equal $s ""]] [string range $s 1 end]}
+			method Done {s} {llength $steps}
+		}`, result: "::Walker"},
+		{script: `Walker create w`, result: "::w"},
+		{script: `w run`, result: "helped 1 ::w"},
+		{script: `w who`, result: "::w"},
+		{script: `w walk ` + strings.Repeat("x", 5*maxNestingDepth), result: "5001"},
+		{script: `proc outside {} {tailcall my Helper 1}; outside`, err: `invalid command name "my"`},
+	})
+}
@@ -69,47 +69,32 @@ func ParseComment(r []rune) ([]rune, int, error) {
 	var (
 		idx int
 	)
-	for idx = 0; idx < len(r); idx++ {
+	for idx < len(r) {
 		_, size, err := ParseAllWhiteSpace(r[idx:])
 		if err != nil {
 			return nil, 0, err
 		}
-		if size > 0 {
-			idx += size
-		}
-		if r[idx] != '#' {
+		idx += size
+		if idx >= len(r) || r[idx] != '#' {
 			break
 		}
+		// A comment extends to the next newline that is not
+		// preceded by a backslash.
 		for ; idx < len(r); idx++ {
-			var next rune
 			c := r[idx]
-			if idx < len(r)-1 {
-				next = r[idx+1]
+			if c == '\\' {
+				idx++
+				continue
 			}
-			if c != '\\' {
-				if next == '\n' {
-					idx++
-					break
-				}
-			} else {
-				_, size, err := ParseWhiteSpace(r[idx:])
-				if err != nil {
-					return nil, 0, err
-				}
-				if size > 0 {
-					idx += size - 1
-				} else {
-					_, size, err := ParseBackslashToken(r[idx:])
-					if err != nil {
-						return nil, 0, err
-					}
-					if size > 0 {
-						idx += size - 1
-					}
-				}
+			if c == '\n' {
+				idx++
+				break
 			}
 		}
 	}
+	if idx > len(r) {
+		idx = len(r)
+	}
 	return r[:idx], idx, nil
 }
 
@@ -164,11 +149,32 @@ func ParseCommand(r []rune, nested bool) (tokens, int, error) {
 			idx += size - 1
 		}
 		ts = append(ts, w)
-		prev = r[idx]
+		prev = 0
 	}
 	return ts, idx, nil
 }
 
+// parseNestedScript parses the commands of a command substitution
+// up to, but not including, the close bracket that terminates it and
+// returns the number of runes consumed.
+func parseNestedScript(r []rune) (int, error) {
+	idx := 0
+	for idx < len(r) && r[idx] != ']' {
+		_, size, err := ParseCommand(r[idx:], true)
+		if err != nil {
+			return 0, err
+		}
+		if size == 0 {
+			break
+		}
+		idx += size
+	}
+	if idx >= len(r) || r[idx] != ']' {
+		return 0, fmt.Errorf("missing close-bracket")
+	}
+	return idx, nil
+}
+
 func ParseWord(r []rune, nested bool) (token, int, error) {
 	if len(r) == 0 {
 		return simpleWordToken(r), 0, nil
@@ -254,8 +260,7 @@ outerLoop:
 		case (terminators&TermCloseBracket) != 0 &&
 			prev != '\\' && c == ']':
 			break outerLoop
-		case prev != '\\' && c == '\n',
-			prev != '\\' && c == '\\',
+		case prev != '\\' && c == '\\',
 			prev != '\\' && c == '[',
 			prev != '\\' && c == '$':
 			break outerLoop
@@ -278,21 +283,33 @@ func ParseQuotedStringWord(r []rune, nested bool) (token, int, error) {
 		return nil, 0, fmt.Errorf("word does not start with double-quote")
 	}
 
-	var prev rune
-
 	closed := false
 	idx := 1
 
 	for i := idx; i < len(r); i++ {
 		c := r[i]
 
-		if c == '"' && prev != '\\' {
+		if c == '\\' {
+			i++
+			continue
+		}
+
+		// Close brackets and double-quotes inside a command
+		// substitution do not terminate the word.
+		if c == '[' {
+			size, err := parseNestedScript(r[i+1:])
+			if err != nil {
+				return nil, 0, err
+			}
+			i += size + 1
+			continue
+		}
+
+		if c == '"' {
 			closed = true
 			idx = i
 			break
 		}
-
-		prev = c
 	}
 
 	if !closed {
@@ -346,23 +363,23 @@ func ParseBracesWord(r []rune, nested bool) (token, int, error) {
 		return nil, 0, fmt.Errorf("word does not start with open brace")
 	}
 
-	var prev rune
 	open := 1
 	idx := 1
 
 	for i := idx; i < len(r); i++ {
 		c := r[i]
-		switch {
-		case c == '{' && prev != '\\':
+		switch c {
+		case '\\':
+			i++
+		case '{':
 			open++
-		case c == '}' && prev != '\\':
+		case '}':
 			open--
 		}
 		if open == 0 {
 			idx = i
 			break
 		}
-		prev = c
 	}
 
 	if open != 0 {
@@ -466,7 +483,7 @@ func ParseTokens(r []rune, terminators TermType, substs SubstType) (tokens, int,
 			if (substs & SubstCommands) == 0 {
 				tok, size, err = textToken(r[idx:idx+1]), 1, nil
 			} else {
-				_, size, err = ParseCommand(r[idx+1:], true)
+				size, err = parseNestedScript(r[idx+1:])
 				if err != nil {
 					return nil, 0, err
 				}
@@ -490,6 +507,9 @@ func ParseTokens(r []rune, terminators TermType, substs SubstType) (tokens, int,
 		}
 		ts = append(ts, tok)
 		prev = r[idx]
+		if _, ok := tok.(bsToken); ok {
+			prev = 0
+		}
 	}
 
 	return ts, idx, nil
@@ -984,9 +1004,16 @@ func (t commandToken) Subst(interp *Interp, substs SubstType) (string, error) {
 	if (SubstCommands & substs) == 0 {
 		return t.String(), nil
 	}
-	s := t.String()
-	script := s[1 : len(s)-1]
-	return interp.Eval(script)
+	v, err := t.value(interp)
+	if err != nil {
+		return "", err
+	}
+	return v.String(), nil
+}
+
+// value evaluates the command and returns its result.
+func (t commandToken) value(interp *Interp) (*Value, error) {
+	return interp.evalScript([]rune(t[1 : len(t)-1]))
 }
 
 // The token describes a variable substitution, including the $,
@@ -1008,23 +1035,28 @@ func (t variableToken) Subst(interp *Interp, substs SubstType) (string, error) {
 	if len(t) < 2 {
 		return "", nil
 	}
+	v, err := t.value(interp, substs)
+	return v.String(), err
+}
+
+// value returns the value of the variable.
+func (t variableToken) value(interp *Interp, substs SubstType) (*Value, error) {
 	name := t[1].String()
 	if len(t) == 2 {
-		return interp.GetVar(name, "")
+		return interp.getVar2(name, "", false)
 	}
 	var b strings.Builder
 	for i := 2; i < len(t); i++ {
 		s, err := t[i].Subst(interp, substs)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		_, err = b.WriteString(s)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 	}
-	index := b.String()
-	return interp.GetVar(name, index)
+	return interp.getVar2(name, b.String(), true)
 }
 
 // The token describes one subexpression of an expression (or an
@@ -215,3 +215,48 @@ puts "stdout [ blah blah blah ] hello $there(is${a}way$out) \
 		fmt.Println(s, err)
 	}
 }
+
+func TestParseCommentEnd(t *testing.T) {
+	for _, tt := range []struct {
+		str  string
+		size int
+	}{
+		{"#", 1},
+		{"# no newline", 12},
+		{"# ends with a backslash\\", 24},
+		{"# one\n# two\\\n still two\nset x 1", 24},
+		{"   ", 3},
+	} {
+		_, size, err := ParseComment([]rune(tt.str))
+		if err != nil || size != tt.size {
+			t.Errorf("ParseComment(%q) = %d, %v; want %d", tt.str, size, err, tt.size)
+		}
+	}
+}
+
+func TestParseNestedWords(t *testing.T) {
+	for _, tt := range []struct {
+		str   string
+		words int
+	}{
+		{`set x "a [list "b c"] d"`, 3},
+		{`set x "a [list \]] d"`, 3},
+		{`set x "a \" b"`, 3},
+		{`set x {a \} b}`, 3},
+		{`set x {a \{ b}`, 3},
+		{`set x [set y 1; set z "]"]`, 3},
+		{`set x [list a
+b]`, 3},
+	} {
+		ws, size, err := ParseCommand([]rune(tt.str), false)
+		if err != nil || len(ws) != tt.words || size != len([]rune(tt.str)) {
+			t.Errorf("ParseCommand(%q) = %d words, size %d, %v; want %d words, size %d",
+				tt.str, len(ws), size, err, tt.words, len([]rune(tt.str)))
+		}
+	}
+	for _, str := range []string{`"a [list b`, `[set x`} {
+		if _, _, err := ParseCommand([]rune("set x "+str), false); err == nil {
+			t.Errorf("ParseCommand(%q): expected an error", str)
+		}
+	}
+}
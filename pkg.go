@@ -0,0 +1,392 @@
+package gotcl
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A PackageLoader loads a package implemented in Go into interp,
+// typically by creating its commands. The package is provided once
+// the loader returns, unless the loader provided it itself.
+type PackageLoader func(interp *Interp) error
+
+// registeredPackages holds the packages registered with
+// RegisterPackage and RegisterSafePackage, which are available in
+// new interpreters.
+var registeredPackages struct {
+	sync.Mutex
+	list []registeredPackage
+}
+
+type registeredPackage struct {
+	name, version string
+	load          PackageLoader
+	// safe is set if the package may be loaded into safe
+	// interpreters.
+	safe bool
+}
+
+// RegisterPackage makes the Go package name, at version, available
+// to package require in interpreters created afterwards, other than
+// safe interpreters. Go packages typically register their Tcl
+// packages from an init function; load is only called when a script
+// requires the package.
+func RegisterPackage(name, version string, load PackageLoader) {
+	registerPackage(registeredPackage{name, version, load, false})
+}
+
+// RegisterSafePackage is like RegisterPackage, but also makes the
+// package available in safe interpreters. Its commands must give
+// scripts no access to the filesystem, processes, the network or the
+// environment.
+func RegisterSafePackage(name, version string, load PackageLoader) {
+	registerPackage(registeredPackage{name, version, load, true})
+}
+
+func registerPackage(p registeredPackage) {
+	registeredPackages.Lock()
+	defer registeredPackages.Unlock()
+	registeredPackages.list = append(registeredPackages.list, p)
+}
+
+// A tclPackage holds the state of a package in an interpreter:
+// the version provided, if any, and how to load each of its known
+// versions.
+type tclPackage struct {
+	version  string
+	ifneeded map[string]*pkgScript
+	// loading is set while a version of the package is being
+	// loaded, to detect circular dependencies.
+	loading bool
+}
+
+// A pkgScript loads a version of a package, by evaluating script or
+// calling load.
+type pkgScript struct {
+	script *Value
+	load   PackageLoader
+}
+
+// defaultPackageUnknown is the initial package unknown handler,
+// which looks for Tcl modules and then scans pkgIndex.tcl files.
+const defaultPackageUnknown = "::tcl::tm::UnknownHandler ::tclPkgUnknown"
+
+// initPackages provides the Tcl package and the registered Go
+// packages; a safe interpreter only gets those registered as safe.
+func (interp *Interp) initPackages() {
+	interp.packages = map[string]*tclPackage{}
+	interp.pkgUnknown = NewStringValue(defaultPackageUnknown)
+	interp.pkg("Tcl").version = tclPatchLevel
+	registeredPackages.Lock()
+	defer registeredPackages.Unlock()
+	for _, p := range registeredPackages.list {
+		if interp.safe && !p.safe {
+			continue
+		}
+		interp.pkg(p.name).ifneeded[p.version] = &pkgScript{load: p.load}
+	}
+}
+
+// pkg returns the package called name, creating it if needed.
+func (interp *Interp) pkg(name string) *tclPackage {
+	p, ok := interp.packages[name]
+	if !ok {
+		p = &tclPackage{ifneeded: map[string]*pkgScript{}}
+		interp.packages[name] = p
+	}
+	return p
+}
+
+// PackageIfNeeded makes version of the package name available to
+// package require in interp, loaded by calling load.
+func (interp *Interp) PackageIfNeeded(name, version string, load PackageLoader) error {
+	if _, err := parseVersion(version); err != nil {
+		return err
+	}
+	interp.pkg(name).ifneeded[version] = &pkgScript{load: load}
+	return nil
+}
+
+// PackageProvide records that version of the package name is
+// present in interp.
+func (interp *Interp) PackageProvide(name, version string) error {
+	if _, err := parseVersion(version); err != nil {
+		return err
+	}
+	p := interp.pkg(name)
+	if p.version != "" && p.version != version {
+		return fmt.Errorf("conflicting versions provided for package \"%s\": %s, then %s", name, p.version, version)
+	}
+	p.version = version
+	return nil
+}
+
+// PackageRequire loads the package name, in a version satisfying
+// one of requirements if any are given, and returns the version
+// loaded. Requirements have the forms min, min- and min-max accepted
+// by package require.
+func (interp *Interp) PackageRequire(name string, requirements ...string) (string, error) {
+	for _, req := range requirements {
+		if err := checkRequirement(req); err != nil {
+			return "", err
+		}
+	}
+	return interp.requirePackage(name, requirements)
+}
+
+func (interp *Interp) requirePackage(name string, reqs []string) (string, error) {
+	p := interp.pkg(name)
+	if p.version != "" {
+		if !satisfiesAny(p.version, reqs) {
+			return "", fmt.Errorf("version conflict for package \"%s\": have %s, need %s", name, p.version, strings.Join(reqs, " "))
+		}
+		return p.version, nil
+	}
+	version := p.bestVersion(reqs)
+	if version == "" && interp.pkgUnknown != nil {
+		words, err := interp.pkgUnknown.List()
+		if err != nil {
+			return "", err
+		}
+		words = append(append(words, NewStringValue(name)), stringsToValues(reqs)...)
+		if _, err := interp.evalGlobal(NewListValue(words)); err != nil {
+			return "", err
+		}
+		version = p.bestVersion(reqs)
+	}
+	if version == "" {
+		return "", fmt.Errorf("can't find package %s", strings.Join(append([]string{name}, reqs...), " "))
+	}
+	if p.loading {
+		return "", fmt.Errorf("circular package dependency: attempt to provide %s %s requires %s", name, version, name)
+	}
+	p.loading = true
+	err := interp.loadPackage(name, version, p.ifneeded[version])
+	p.loading = false
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case p.version == "":
+		return "", fmt.Errorf("attempt to provide package %s %s failed: no version of package %s provided", name, version, name)
+	case compareVersions(p.version, version) != 0:
+		return "", fmt.Errorf("attempt to provide package %s %s failed: package %s %s provided instead", name, version, name, p.version)
+	}
+	return p.version, nil
+}
+
+// loadPackage loads version of the package name with s.
+func (interp *Interp) loadPackage(name, version string, s *pkgScript) error {
+	if s.load == nil {
+		_, err := interp.evalGlobal(s.script)
+		return err
+	}
+	if err := s.load(interp); err != nil {
+		return err
+	}
+	if interp.pkg(name).version == "" {
+		return interp.PackageProvide(name, version)
+	}
+	return nil
+}
+
+// bestVersion returns the highest version of p that can be loaded
+// and satisfies one of reqs, preferring stable versions to alpha and
+// beta ones, or "" if there is none.
+func (p *tclPackage) bestVersion(reqs []string) string {
+	best, bestStable := "", ""
+	for v := range p.ifneeded {
+		if !satisfiesAny(v, reqs) {
+			continue
+		}
+		if best == "" || compareVersions(v, best) > 0 {
+			best = v
+		}
+		if !strings.ContainsAny(v, "ab") && (bestStable == "" || compareVersions(v, bestStable) > 0) {
+			bestStable = v
+		}
+	}
+	if bestStable != "" {
+		return bestStable
+	}
+	return best
+}
+
+// tclPkgUnknown name ?requirement ...?
+//
+// The default package unknown handler behind the Tcl module handler.
+// It evaluates the pkgIndex.tcl files of the directories in auto_path
+// and of their immediate subdirectories, each with dir set to its
+// directory. Earlier directories take precedence, so they are scanned
+// last. Safe interpreters do not scan the filesystem.
+func cmdTclPkgUnknown(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "name ?requirement ...?")
+	}
+	if err := interp.scanPackageIndexes(); err != nil {
+		return nil, err
+	}
+	return emptyValue(), nil
+}
+
+// scanPackageIndexes evaluates the pkgIndex.tcl files found from
+// auto_path, as tclPkgUnknown does.
+func (interp *Interp) scanPackageIndexes() error {
+	if interp.safe {
+		return nil
+	}
+	path, err := interp.getVar("::auto_path")
+	if err != nil {
+		return nil
+	}
+	dirs, err := path.List()
+	if err != nil {
+		return err
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		dir := dirs[i].String()
+		if err := interp.loadPackageIndex(dir); err != nil {
+			return err
+		}
+		entries, _ := interp.readScriptDir(dir)
+		for _, e := range entries {
+			if e.IsDir() {
+				if err := interp.loadPackageIndex(filepath.Join(dir, e.Name())); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// loadPackageIndex evaluates the pkgIndex.tcl file of dir, if there
+// is one.
+func (interp *Interp) loadPackageIndex(dir string) error {
+	file := filepath.Join(dir, "pkgIndex.tcl")
+	data, err := interp.readScriptFile(file)
+	if err != nil {
+		return nil
+	}
+	return interp.evalIndexFile(file, decodeScript(data, "utf-8"))
+}
+
+// parseVersion parses a version number: integers separated by dots,
+// with at most one of the separators replaced by a, for an alpha
+// release, or b, for a beta release. The a and b separators are
+// represented by -2 and -1 in the result.
+func parseVersion(v string) ([]int, error) {
+	bad := fmt.Errorf("expected version number but got \"%s\"", v)
+	var parts []int
+	prerelease := false
+	for i, start := 0, 0; i <= len(v); i++ {
+		if i < len(v) && v[i] >= '0' && v[i] <= '9' {
+			continue
+		}
+		if i == start {
+			return nil, bad
+		}
+		n, err := strconv.Atoi(v[start:i])
+		if err != nil {
+			return nil, bad
+		}
+		parts = append(parts, n)
+		if i == len(v) {
+			break
+		}
+		switch v[i] {
+		case '.':
+		case 'a', 'b':
+			if prerelease {
+				return nil, bad
+			}
+			prerelease = true
+			parts = append(parts, int(v[i])-'a'-2)
+		default:
+			return nil, bad
+		}
+		start = i + 1
+	}
+	return parts, nil
+}
+
+// compareVersions compares the valid versions a and b, returning -1,
+// 0 or 1. Missing trailing components are taken as 0.
+func compareVersions(a, b string) int {
+	pa, _ := parseVersion(a)
+	pb, _ := parseVersion(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		x, y := 0, 0
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// checkRequirement checks that req is a valid version requirement.
+func checkRequirement(req string) error {
+	min, max, ranged := strings.Cut(req, "-")
+	_, err := parseVersion(min)
+	if err == nil && ranged && max != "" {
+		_, err = parseVersion(max)
+	}
+	if err != nil {
+		return fmt.Errorf("expected versionMin-versionMax but got \"%s\"", req)
+	}
+	return nil
+}
+
+// satisfies reports whether version satisfies the valid requirement
+// req. A requirement min is satisfied by versions from min up to but
+// excluding the next major version, min- by versions from min, and
+// min-max by versions from min up to but excluding max, or by min
+// alone if min and max are the same.
+func satisfies(version, req string) bool {
+	min, max, ranged := strings.Cut(req, "-")
+	if compareVersions(version, min) < 0 {
+		return false
+	}
+	switch {
+	case !ranged:
+		major, _, _ := strings.Cut(min, ".")
+		n, _ := parseVersion(major)
+		return compareVersions(version, strconv.Itoa(n[0]+1)) < 0
+	case max == "":
+		return true
+	case compareVersions(min, max) == 0:
+		return compareVersions(version, min) == 0
+	}
+	return compareVersions(version, max) < 0
+}
+
+// satisfiesAny reports whether version satisfies one of reqs, or
+// whether reqs is empty.
+func satisfiesAny(version string, reqs []string) bool {
+	for _, req := range reqs {
+		if satisfies(version, req) {
+			return true
+		}
+	}
+	return len(reqs) == 0
+}
+
+// sortVersions sorts versions in increasing order.
+func sortVersions(versions []string) {
+	sort.Slice(versions, func(i, j int) bool {
+		return compareVersions(versions[i], versions[j]) < 0
+	})
+}
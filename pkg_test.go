@@ -0,0 +1,102 @@
+package gotcl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPackageVersions(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `list [package vcompare 1.2 1.10] [package vcompare 1.0 1] [package vcompare 2a1 2b1] [package vcompare 2.0 2b1]`, result: "-1 0 -1 1"},
+		{script: `package vsatisfies 8.6.1 8.5`, result: "1"},
+		{script: `package vsatisfies 9.0 8.5`, result: "0"},
+		{script: `list [package vsatisfies 8.6 8.5-8.6] [package vsatisfies 8.5 8.5-8.5] [package vsatisfies 12 8-] [package vsatisfies 2.0a1 2-]`, result: "0 1 1 0"},
+		{script: `package vsatisfies 3 1 2 3`, result: "1"},
+		{script: `package vcompare 1..2 1`, err: `expected version number but got "1..2"`},
+		{script: `package vcompare 1a2b3 1`, err: `expected version number but got "1a2b3"`},
+		{script: `package vsatisfies 1 x-y`, err: `expected versionMin-versionMax but got "x-y"`},
+		{script: `package require Tcl 8.6-`, result: tclPatchLevel},
+		{script: `package bogus`, err: `unknown or ambiguous subcommand "bogus": must be forget, ifneeded, names, present, provide, require, unknown, vcompare, versions, or vsatisfies`},
+	})
+}
+
+func TestPackageRequire(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `package ifneeded foo 1.0 {package provide foo 1.0; lappend ::loaded 1.0}`},
+		{script: `package ifneeded foo 1.5 {package provide foo 1.5; lappend ::loaded 1.5}`},
+		{script: `package ifneeded foo 2.0b1 {package provide foo 2.0b1}`},
+		{script: `package versions foo`, result: "1.0 1.5 2.0b1"},
+		{script: `package present foo`, err: `package foo is not present`},
+		{script: `package require foo`, result: "1.5"},
+		{script: `package require foo 1.2; set loaded`, result: "1.5"},
+		{script: `package require foo 2`, err: `version conflict for package "foo": have 1.5, need 2`},
+		{script: `package require -exact foo 1.0`, err: `version conflict for package "foo": have 1.5, need 1.0-1.0`},
+		{script: `list [package provide foo] [package present foo 1]`, result: "1.5 1.5"},
+		{script: `package forget foo; package ifneeded foo 1.0 {package provide foo 1.0}; package require -exact foo 1.0`, result: "1.0"},
+		{script: `package provide foo 1.1`, err: `conflicting versions provided for package "foo": 1.0, then 1.1`},
+		{script: `package ifneeded bar 2.0b1 {package provide bar 2.0b1}; package require bar 1-`, result: "2.0b1"},
+		{script: `package require nope 1.0`, err: `can't find package nope 1.0`},
+		{script: `package ifneeded lazy 1.0 {}; package require lazy`, err: `attempt to provide package lazy 1.0 failed: no version of package lazy provided`},
+		{script: `package ifneeded odd 1.0 {package provide odd 1.1}; package require odd`, err: `attempt to provide package odd 1.0 failed: package odd 1.1 provided instead`},
+		{script: `package ifneeded loop 1.0 {package require loop}; package require loop`, err: `circular package dependency: attempt to provide loop 1.0 requires loop`},
+		{script: `package ifneeded foo 1.0`, result: "package provide foo 1.0"},
+		{script: `package require`, err: `wrong # args: should be "package require ?-exact? package ?requirement ...?"`},
+		{script: `package require -exact foo`, err: `wrong # args: should be "package require -exact package version"`},
+	})
+}
+
+func TestPackageIndex(t *testing.T) {
+	lib := t.TempDir()
+	sub := filepath.Join(lib, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, data string) {
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(lib, "pkgIndex.tcl"), `package ifneeded top 1.0 [list source $dir/top.tcl]`)
+	write(filepath.Join(lib, "top.tcl"), "package provide top 1.0\nproc top {} {package require nested}\n")
+	write(filepath.Join(sub, "pkgIndex.tcl"), `package ifneeded nested 0.3 "package provide nested 0.3; set ::nestedDir [list $dir]"`)
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `package require top`, err: `can't find package top`},
+		{script: `set auto_path [list ` + lib + `]; package require top`, result: "1.0"},
+		{script: `list [top] $nestedDir`, result: "0.3 " + sub},
+	})
+}
+
+func TestPackageGo(t *testing.T) {
+	RegisterPackage("gopkg", "1.2", func(interp *Interp) error {
+		interp.CreateCommand("gocmd", func(interp *Interp, args []*Value) (*Value, error) {
+			return NewStringValue("from go"), nil
+		})
+		return nil
+	})
+	interp := NewInterp()
+	if err := interp.PackageIfNeeded("local", "0.1", func(interp *Interp) error {
+		return interp.PackageProvide("local", "0.1")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `info commands gocmd`, result: ""},
+		{script: `package require gopkg 1; gocmd`, result: "from go"},
+		{script: `package require local`, result: "0.1"},
+	})
+	if v, err := interp.PackageRequire("gopkg", "2-"); err == nil {
+		t.Errorf("PackageRequire gopkg 2-: got %s", v)
+	}
+
+	RegisterSafePackage("gosafe", "1.0", func(interp *Interp) error {
+		return nil
+	})
+	runEvalTests(t, NewSafeInterp(), []evalTest{
+		{script: `package require gopkg`, err: `can't find package gopkg`},
+		{script: `package require gosafe`, result: "1.0"},
+	})
+}
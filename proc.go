@@ -0,0 +1,320 @@
+package gotcl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxNestingDepth limits how deeply procedure calls may be nested,
+// so that runaway recursion is reported as an error before it
+// exhausts the Go stack. maxCommandDepth limits the nesting of
+// commands in the same way, for recursion through commands other
+// than procedures, such as an alias of itself.
+const (
+	maxNestingDepth = 1000
+	maxCommandDepth = 50 * maxNestingDepth
+)
+
+// errTooDeep is returned when a nesting limit is reached.
+var errTooDeep = errors.New("too many nested evaluations (infinite loop?)")
+
+// A callFrame holds the local variables of a procedure invocation.
+// The global frame has level 0 and holds the global variables.
+type callFrame struct {
+	vars  map[string]*variable
+	level int
+	// caller is the frame the procedure was invoked from, which
+	// need not be the frame below it on the Go stack if it was
+	// invoked by uplevel.
+	caller *callFrame
+	// args holds the words the procedure was invoked with.
+	args []*Value
+	proc *procedure
+	// method is set in the frames of object methods.
+	method *methodContext
+	// links holds the names of the variables of the frame that are
+	// linked to variables of other frames, which are not locals.
+	links map[string]bool
+}
+
+// link records that the variable called name of frame is linked to a
+// variable of another frame.
+func (frame *callFrame) link(name string) {
+	if frame.links == nil {
+		frame.links = map[string]bool{}
+	}
+	frame.links[name] = true
+}
+
+// A procedure is a command defined by proc.
+type procedure struct {
+	params []procParam
+	body   *Value
+}
+
+// A procParam is a formal parameter of a procedure. The last
+// parameter collects any remaining arguments if it is called args.
+type procParam struct {
+	name   string
+	def    *Value
+	hasDef bool
+}
+
+// parseProcParams parses the formal parameter list of a procedure.
+func parseProcParams(v *Value) ([]procParam, error) {
+	specs, err := v.List()
+	if err != nil {
+		return nil, err
+	}
+	params := make([]procParam, len(specs))
+	for i, spec := range specs {
+		fields, err := spec.List()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case len(fields) > 2:
+			return nil, fmt.Errorf("too many fields in argument specifier \"%s\"", spec)
+		case len(fields) == 0 || fields[0].String() == "":
+			return nil, fmt.Errorf("argument with no name")
+		}
+		name := fields[0].String()
+		if _, _, isElem := splitVarName(name); isElem {
+			return nil, fmt.Errorf("formal parameter \"%s\" is an array element", name)
+		}
+		if strings.Contains(name, "::") {
+			return nil, fmt.Errorf("formal parameter \"%s\" is not a simple name", name)
+		}
+		params[i] = procParam{name: name}
+		if len(fields) == 2 {
+			params[i].def, params[i].hasDef = fields[1], true
+		}
+	}
+	return params, nil
+}
+
+// usage returns the wrong # args message of a procedure invoked as
+// name.
+func (proc *procedure) usage(name *Value) error {
+	words := []string{name.String()}
+	for i, p := range proc.params {
+		switch {
+		case p.name == "args" && i == len(proc.params)-1:
+			words = append(words, "?arg ...?")
+		case p.hasDef:
+			words = append(words, "?"+p.name+"?")
+		default:
+			words = append(words, p.name)
+		}
+	}
+	return fmt.Errorf("wrong # args: should be \"%s\"", strings.Join(words, " "))
+}
+
+// bind returns a new frame for an invocation of proc with args,
+// called from the current frame of interp.
+func (proc *procedure) bind(interp *Interp, args []*Value) (*callFrame, error) {
+	frame := &callFrame{
+		vars:   map[string]*variable{},
+		level:  interp.frame.level + 1,
+		caller: interp.frame,
+		args:   args,
+		proc:   proc,
+	}
+	actual := args[1:]
+	for i, p := range proc.params {
+		var v *Value
+		switch {
+		case p.name == "args" && i == len(proc.params)-1:
+			v = NewListValue(append([]*Value(nil), actual...))
+			actual = nil
+		case len(actual) > 0:
+			v, actual = actual[0], actual[1:]
+		case p.hasDef:
+			v = p.def
+		default:
+			return nil, proc.usage(args[0])
+		}
+		frame.vars[p.name] = &variable{value: v}
+	}
+	if len(actual) > 0 {
+		return nil, proc.usage(args[0])
+	}
+	return frame, nil
+}
+
+// callProc invokes proc with args, as the method of ctx if ctx is
+// not nil. If the body ends with tailcall, the procedure's frame is
+// discarded and the tail command is invoked in its place; further
+// procedures, lambdas and methods reached that way are run by the
+// same loop, so a chain of tail calls does not grow the Go stack.
+func (interp *Interp) callProc(proc *procedure, args []*Value, ctx *methodContext) (*Value, error) {
+	if interp.depth >= maxNestingDepth {
+		return nil, errTooDeep
+	}
+	interp.depth++
+	defer func() { interp.depth-- }()
+	for {
+		frame, err := proc.bind(interp, args)
+		if err != nil {
+			return nil, err
+		}
+		if ctx != nil {
+			if err := interp.bindMethodFrame(frame, ctx); err != nil {
+				return nil, err
+			}
+			ctx = nil
+		}
+		caller := interp.frame
+		interp.frame = frame
+		v, err := interp.evalScriptFrom(proc.body.Runes(), &scriptSource{typ: "proc", proc: "::" + commandName(args[0].String())})
+		interp.frame = caller
+		if interp.errorLogged && errorCode(err) == CodeError {
+			interp.errorStack = append(interp.errorStack, NewStringValue("CALL"), NewListValue(args))
+		}
+
+		tc, ok := err.(*tailcallError)
+		if !ok {
+			return procResult(v, err)
+		}
+		if proc, args, ctx = interp.tailTarget(tc); proc == nil {
+			return interp.invokeTail(tc)
+		}
+	}
+}
+
+// tailTarget resolves the command run by tailcall to the procedure it
+// invokes, returning the words to bind the procedure to and, for a
+// method, its context. It returns a nil procedure if the command must
+// be invoked as usual.
+func (interp *Interp) tailTarget(tc *tailcallError) (*procedure, []*Value, *methodContext) {
+	args := tc.command
+	cmd, ok := interp.commands[commandName(args[0].String())]
+	if !ok || len(cmd.traces) > 0 || len(interp.stepTraces) > 0 {
+		return nil, nil, nil
+	}
+	if cmd.tail != nil {
+		return cmd.tail(interp, args, tc.method)
+	}
+	return cmd.proc, args, nil
+}
+
+// invokeTail invokes the command run by tailcall in the frame of the
+// caller, but in the method context tailcall was called in, as a
+// forward is.
+func (interp *Interp) invokeTail(tc *tailcallError) (*Value, error) {
+	if tc.method == nil {
+		return interp.invoke(tc.command)
+	}
+	caller := interp.frame
+	frame := *caller
+	frame.method = tc.method
+	interp.frame = &frame
+	defer func() { interp.frame = caller }()
+	return interp.invoke(tc.command)
+}
+
+// procResult converts the completion of a procedure body into the
+// result of the procedure.
+func procResult(v *Value, err error) (*Value, error) {
+	e, ok := err.(*codeError)
+	if !ok {
+		return v, err
+	}
+	switch e.code {
+	case CodeBreak, CodeContinue:
+		return nil, errors.New(e.Error())
+	case CodeReturn:
+		if e.level > 1 {
+			return nil, &codeError{code: CodeReturn, value: e.value, level: e.level - 1, returnCode: e.returnCode}
+		}
+		return completeReturn(e.value, e.returnCode)
+	}
+	return nil, err
+}
+
+// completeReturn returns the completion of a command with code and
+// result value.
+func completeReturn(value *Value, code Code) (*Value, error) {
+	switch code {
+	case CodeOK:
+		return value, nil
+	case CodeError:
+		return nil, errors.New(value.String())
+	case CodeBreak:
+		return nil, errBreak
+	case CodeContinue:
+		return nil, errContinue
+	}
+	return nil, &codeError{code: code, value: value}
+}
+
+// isLevel reports whether s has the form of a stack level: an
+// integer or # followed by an integer.
+func isLevel(s string) bool {
+	s = strings.TrimPrefix(s, "#")
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// frameAtLevel returns the frame identified by the stack level v,
+// which is either #n, an absolute level, or n, the number of levels
+// to go up from the current frame.
+func (interp *Interp) frameAtLevel(v *Value) (*callFrame, error) {
+	s := v.String()
+	if !isLevel(s) {
+		return nil, fmt.Errorf("bad level \"%s\"", s)
+	}
+	n, err := v.Int()
+	absolute := strings.HasPrefix(s, "#")
+	if absolute {
+		n, err = NewStringValue(s[1:]).Int()
+	}
+	target := int64(interp.frame.level) - n
+	if absolute {
+		target = n
+	}
+	if err != nil || target < 0 || target > int64(interp.frame.level) {
+		return nil, fmt.Errorf("bad level \"%s\"", s)
+	}
+	frame := interp.frame
+	for int64(frame.level) > target {
+		frame = frame.caller
+	}
+	return frame, nil
+}
+
+// lambdaRep is the internal representation of a Value used as a
+// lambda expression by apply.
+type lambdaRep struct {
+	str  string
+	proc *procedure
+}
+
+func (rep *lambdaRep) String() string { return rep.str }
+
+// lambda returns v parsed as a lambda expression, a list of the form
+// {args body ?namespace?}, caching the parsed procedure in v.
+func (v *Value) lambda() (*procedure, error) {
+	if rep, ok := v.rep.(*lambdaRep); ok {
+		return rep.proc, nil
+	}
+	elems, err := v.List()
+	if err != nil || len(elems) < 2 || len(elems) > 3 {
+		return nil, fmt.Errorf("can't interpret \"%s\" as a lambda expression", v)
+	}
+	params, err := parseProcParams(elems[0])
+	if err != nil {
+		return nil, err
+	}
+	proc := &procedure{params: params, body: elems[1]}
+	v.setRep(&lambdaRep{str: v.String(), proc: proc})
+	return proc, nil
+}
@@ -0,0 +1,142 @@
+package gotcl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProc(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `proc add {a {b 10} args} {list $a $b $args}`},
+		{script: `add 1`, result: "1 10 {}"},
+		{script: `add 1 2 3 4`, result: "1 2 {3 4}"},
+		{script: `add`, err: `wrong # args: should be "add a ?b? ?arg ...?"`},
+		{script: `proc one {x} {set x}; one a b`, err: `wrong # args: should be "one x"`},
+		{script: `proc local {} {set v inner; set v}; set v outer; list [local] $v`, result: "inner outer"},
+		{script: `proc early {} {return first; set x second}; early`, result: "first"},
+		{script: `proc none {} {return}; none`, result: ""},
+		{script: `proc fail {} {return -code error oops}; fail`, err: "oops"},
+		{script: `proc up {} {return -level 2 up}; proc mid {} {up; return mid}; mid`, result: "up"},
+		{script: `return -level 0 -code ok now`, result: "now"},
+		{script: `return top; set x unreached`, result: "top"},
+		{script: `return -code bogus`, err: `bad completion code "bogus": must be ok, error, return, break, continue, or an integer`},
+		{script: `return -level -1 x`, err: `bad -level value: expected non-negative integer but got "-1"`},
+		{script: `proc brk {} {break}; brk`, err: `invoked "break" outside of a loop`},
+		{script: `proc bad {{}} {}`, err: `argument with no name`},
+		{script: `proc bad {{a b c}} {}`, err: `too many fields in argument specifier "a b c"`},
+		{script: `proc bad {a(1)} {}`, err: `formal parameter "a(1)" is an array element`},
+		{script: `proc forever {} {forever}; forever`, err: `too many nested evaluations (infinite loop?)`},
+	})
+}
+
+func TestUplevelUpvar(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `proc incr2 {name} {upvar $name v; lappend v x}`},
+		{script: `set l a; incr2 l; set l`, result: "a x"},
+		{script: `proc setter {} {uplevel {set made 1}}; setter; set made`, result: "1"},
+		{script: `proc outer {} {set o 1; inner; set o}; proc inner {} {upvar 1 o x; set x 2}; outer`, result: "2"},
+		{script: `proc abs {} {uplevel #0 {set abs 1}}; proc wrap {} {abs}; wrap; set abs`, result: "1"},
+		{script: `proc g {} {global gv; set gv changed}; set gv orig; g; set gv`, result: "changed"},
+		{script: `proc u {} {global gone; unset gone; set gone back}; set gone 1; u; set gone`, result: "back"},
+		{script: `proc arr {} {upvar a(k) e; set e val}; arr; set a(k)`, result: "val"},
+		{script: `global x`},
+		{script: `uplevel 1 {set x}`, err: `bad level "1"`},
+		{script: `proc lvl {} {uplevel 5 {}}; lvl`, err: `bad level "5"`},
+		{script: `proc self {} {set x 1; upvar 0 x x}; self`, err: `can't upvar from variable to itself`},
+		{script: `proc exists {} {set y 1; upvar 1 l y}; exists`, err: `variable "y" already exists`},
+		{script: `proc elem {} {upvar 1 l e(1)}; elem`, err: `bad variable name "e(1)": can't create a scalar variable that looks like an array element`},
+		{script: `proc gelem {} {global a(k)}; gelem`, err: `can't define "a(k)": name refers to an element in an array`},
+		{script: `upvar x`, err: `wrong # args: should be "upvar ?level? otherVar localVar ?otherVar localVar ...?"`},
+		{script: `uplevel`, err: `wrong # args: should be "uplevel ?level? command ?arg ...?"`},
+	})
+}
+
+func TestTailcall(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `proc done {s} {return finished}`},
+		{script: `proc loop {s} {tailcall [lindex {loop done} [string equal $s ""]] [string range $s 1 end]}`},
+		{script: `loop ` + strings.Repeat("x", 5*maxNestingDepth), result: "finished"},
+		{script: `proc caller {} {set v caller; callee}; proc callee {} {tailcall set v}; set v global; caller`, result: "caller"},
+		{script: `proc tolist {} {tailcall list a b}; tolist`, result: "a b"},
+		{script: `tailcall list`, err: `tailcall can only be called from a proc, lambda or method`},
+		{script: `proc empty {} {tailcall}; empty`, err: `wrong # args: should be "tailcall command ?arg ...?"`},
+
+		{script: `set step {s {tailcall {*}[lindex [list [list apply $::step] done] [string equal $s ""]] [string range $s 1 end]}}; llength $step`, result: "2"},
+		{script: `apply $step ` + strings.Repeat("x", 5*maxNestingDepth), result: "finished"},
+		{script: `oo::class create Walker {method walk {s} {tailcall {*}[lindex [list [list [self] walk] done] [string equal $s ""]] [string range $s 1 end]}}`, result: "::Walker"},
+		{script: `[Walker new] walk ` + strings.Repeat("x", 5*maxNestingDepth), result: "finished"},
+	})
+}
+
+func TestNestingDepth(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `proc done {s} {return finished}`},
+		{script: `proc up {s} {uplevel 1 [list [lindex {up done} [string equal $s ""]] [string range $s 1 end]]}`},
+		{script: `up ` + strings.Repeat("x", maxNestingDepth-10), result: "finished"},
+		{script: `up ` + strings.Repeat("x", maxNestingDepth), err: `too many nested evaluations (infinite loop?)`},
+		{script: `set loop {uplevel 0 $loop}; uplevel 0 $loop`, err: `too many nested evaluations (infinite loop?)`},
+	})
+}
+
+func TestApply(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `apply {{a {b 2}} {list $a $b}} 1`, result: "1 2"},
+		{script: `apply {args {set args}} x y`, result: "x y"},
+		{script: `set v outer; apply {{} {set v inner}}; set v`, result: "outer"},
+		{script: `apply {{} {return -code error failed}}`, err: "failed"},
+		{script: `apply {{} {tailcall list tail}}`, result: "tail"},
+		{script: `set cmp {{a b} {string compare $b $a}}; lsort -command [list apply $cmp] {b c a}`, result: "c b a"},
+		{script: `lmap x {1 2} {apply {y {list <$y>}} $x}`, result: "<1> <2>"},
+		{script: `after 0 [list apply {{} {global fired; set fired yes}}]; update; set fired`, result: "yes"},
+		{script: `apply {{x y} {}} 1`, err: `wrong # args: should be "apply {{x y} {}} x y"`},
+		{script: `apply {x}`, err: `can't interpret "x" as a lambda expression`},
+		{script: `apply {{{}} {}}`, err: `argument with no name`},
+		{script: `apply`, err: `wrong # args: should be "apply lambdaExpr ?arg ...?"`},
+	})
+}
+
+func TestApplyCachesLambda(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `set f {x {list got $x}}; apply $f 1`, result: "got 1"},
+	})
+	v, err := interp.getVar("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep, ok := v.rep.(*lambdaRep)
+	if !ok {
+		t.Fatalf("lambda not cached: rep is %T", v.rep)
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `apply $f 2`, result: "got 2"},
+	})
+	if v.rep != rep {
+		t.Error("lambda was parsed again")
+	}
+}
+
+func TestRenameUnknown(t *testing.T) {
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `proc p {} {list p}; rename p q; q`, result: "p"},
+		{script: `p`, err: `invalid command name "p"`},
+		{script: `info procs`, result: "q"},
+		{script: `rename q ""; q`, err: `invalid command name "q"`},
+		{script: `rename nope x`, err: `can't rename "nope": command doesn't exist`},
+		{script: `rename nope ""`, err: `can't delete "nope": command doesn't exist`},
+		{script: `rename set list`, err: `can't rename to "list": command already exists`},
+		{script: `proc r {} {}; trace add command r {rename delete} {lappend ::ops}; rename r s; rename s ""; set ops`, result: "::r ::s rename ::s {} delete"},
+		{script: `oo::class create C {method m {} {self}}; C create o; rename o obj; obj m`, result: "::obj"},
+		{script: `interp alias {} al {} list a; rename al al2; al2 b`, result: "a b"},
+		{script: `interp alias {} al {}; al2 c`, err: `invalid command name "al2"`},
+		{script: `proc unknown {args} {list unknown {*}$args}; missing a b`, result: "unknown missing a b"},
+		{script: `rename unknown ""; missing`, err: `invalid command name "missing"`},
+		{script: `rename`, err: `wrong # args: should be "rename oldName newName"`},
+	})
+}
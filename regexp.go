@@ -0,0 +1,1210 @@
+package gotcl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// This file implements Tcl's Advanced Regular Expressions (AREs), as
+// described in the re_syntax manual page, with a backtracking
+// matcher. Go's regexp package cannot be used because it lacks back
+// references, lookahead and lookbehind constraints and Tcl's word
+// boundary escapes.
+//
+// Tcl matches an RE as a whole with a preference for either the
+// longest or the shortest match, decided by its first quantifier or
+// alternation, and finds submatches along the way. Here every path
+// through the pattern is explored from the leftmost position that
+// matches, and the submatches are taken from the highest-priority
+// path that reaches the preferred end. Unless the RE has back
+// references, states already explored are remembered so that
+// matching takes time proportional to the pattern size times the
+// input length.
+
+// regexpFlags are the options a regular expression is compiled with.
+type regexpFlags int
+
+const (
+	reNocase regexpFlags = 1 << iota
+	reExpanded
+	// reLineStop stops . and negated brackets from matching a
+	// newline.
+	reLineStop
+	// reLineAnchor makes ^ and $ match at the beginning and end of
+	// lines.
+	reLineAnchor
+
+	reLine = reLineStop | reLineAnchor
+)
+
+// Compilation errors, as reported by Tcl's regerror.
+const (
+	reErrParen    = "parentheses () not balanced"
+	reErrBracket  = "brackets [] not balanced"
+	reErrBrace    = "braces {} not balanced"
+	reErrCount    = "invalid repetition count(s)"
+	reErrRange    = "invalid character range"
+	reErrCtype    = "invalid character class"
+	reErrEscape   = "invalid escape \\ sequence"
+	reErrSubreg   = "invalid backreference number"
+	reErrRepeat   = "quantifier operand invalid"
+	reErrOption   = "invalid embedded option"
+	reErrCollate  = "invalid collating element"
+	reErrTooBig   = "nfa has too many states"
+	reErrBasic    = "basic regular expressions are not supported"
+	reErrorPrefix = "couldn't compile regular expression pattern: "
+)
+
+const (
+	reMaxInsts  = 100000
+	reMaxRepeat = 255
+)
+
+// reInfo records features of an RE for regexp -about.
+type reInfo int
+
+const (
+	reUBackref reInfo = 1 << iota
+	reULookahead
+	reUBounds
+	reUBraces
+	reUBsalnum
+	reUPbotch
+	reUBbs
+	reUNonposix
+	reUUnspec
+	reUUnport
+	reULocale
+	reUEmptymatch
+	reUImpossible
+	reUShortest
+)
+
+var reInfoNames = []string{
+	"REG_UBACKREF", "REG_ULOOKAHEAD", "REG_UBOUNDS", "REG_UBRACES",
+	"REG_UBSALNUM", "REG_UPBOTCH", "REG_UBBS", "REG_UNONPOSIX",
+	"REG_UUNSPEC", "REG_UUNPORT", "REG_ULOCALE", "REG_UEMPTYMATCH",
+	"REG_UIMPOSSIBLE", "REG_USHORTEST",
+}
+
+// names returns the names of the bits set in info.
+func (info reInfo) names() []string {
+	var names []string
+	for i, name := range reInfoNames {
+		if info&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+type reOp uint8
+
+const (
+	reLiteral reOp = iota
+	reClass
+	reAny
+	reConcat
+	reAlternate
+	reCapture
+	reRepeat
+	reBackref
+	reLook
+	// Constraints.
+	reBol
+	reEol
+	reBos
+	reEos
+	reWordStart
+	reWordEnd
+	reWordBoundary
+	reNotWordBoundary
+)
+
+// A reNode is a node of the syntax tree of a regular expression.
+type reNode struct {
+	op    reOp
+	r     rune
+	class *charClass
+	subs  []*reNode
+	// min and max bound a repetition; max is -1 when unbounded.
+	min, max int
+	greedy   bool
+	// n is the number of a capturing group or back reference.
+	n int
+	// neg and behind select the kind of a lookaround constraint.
+	neg, behind bool
+}
+
+// isConstraint reports whether n matches an empty string at a
+// position, which cannot be quantified.
+func (n *reNode) isConstraint() bool {
+	return n.op == reLook || n.op >= reBol
+}
+
+// A charClass is a set of characters given by a bracket expression
+// or class-shorthand escape.
+type charClass struct {
+	// ranges holds pairs of inclusive bounds.
+	ranges []rune
+	named  []func(rune) bool
+	negate bool
+}
+
+func (c *charClass) contains(r rune) bool {
+	for i := 0; i < len(c.ranges); i += 2 {
+		if c.ranges[i] <= r && r <= c.ranges[i+1] {
+			return true
+		}
+	}
+	for _, f := range c.named {
+		if f(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether r is in the class. A negated class never
+// matches a newline when lineStop is set.
+func (c *charClass) matches(r rune, nocase, lineStop bool) bool {
+	in := c.contains(r)
+	if !in && nocase {
+		in = c.contains(unicode.ToLower(r)) || c.contains(unicode.ToUpper(r))
+	}
+	if c.negate {
+		return !in && !(lineStop && r == '\n')
+	}
+	return in
+}
+
+var reNamedClasses = map[string]func(rune) bool{
+	"alnum": func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) },
+	"alpha": unicode.IsLetter,
+	"blank": func(r rune) bool { return r == ' ' || r == '\t' },
+	"cntrl": unicode.IsControl,
+	"digit": unicode.IsDigit,
+	"graph": func(r rune) bool { return unicode.IsGraphic(r) && !unicode.IsSpace(r) },
+	"lower": unicode.IsLower,
+	"print": unicode.IsPrint,
+	"punct": unicode.IsPunct,
+	"space": unicode.IsSpace,
+	"upper": unicode.IsUpper,
+	"xdigit": func(r rune) bool {
+		return '0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F'
+	},
+}
+
+// shorthandClass returns the class for the escape \d, \s or \w.
+func shorthandClass(c rune) func(rune) bool {
+	switch c {
+	case 'd':
+		return unicode.IsDigit
+	case 's':
+		return unicode.IsSpace
+	}
+	return isWordChar
+}
+
+// reParser parses the syntax of a regular expression into a tree.
+type reParser struct {
+	src   []rune
+	pos   int
+	flags regexpFlags
+	info  reInfo
+	// ngroup counts the capturing groups opened so far.
+	ngroup int
+	// look is the depth of lookaround constraints being parsed,
+	// within which parentheses do not capture.
+	look int
+}
+
+func (p *reParser) more() bool { return p.pos < len(p.src) }
+
+func (p *reParser) peek() rune { return p.src[p.pos] }
+
+func (p *reParser) lookingAt(s string) bool {
+	r := []rune(s)
+	if len(p.src)-p.pos < len(r) {
+		return false
+	}
+	for i, c := range r {
+		if p.src[p.pos+i] != c {
+			return false
+		}
+	}
+	return true
+}
+
+// skip skips white space and comments in expanded syntax.
+func (p *reParser) skip() {
+	if p.flags&reExpanded == 0 {
+		return
+	}
+	for p.more() {
+		switch c := p.peek(); {
+		case unicode.IsSpace(c):
+			p.pos++
+		case c == '#':
+			for p.more() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// options parses the ***= and ***: directors and embedded options
+// that may begin an RE. It reports whether the rest of the RE is a
+// literal string.
+func (p *reParser) options() (bool, error) {
+	literal := false
+	switch {
+	case p.lookingAt("***="):
+		p.pos += 4
+		return true, nil
+	case p.lookingAt("***:"):
+		p.pos += 4
+	}
+	if !p.lookingAt("(?") || p.pos+2 >= len(p.src) || !unicode.IsLetter(p.src[p.pos+2]) {
+		return false, nil
+	}
+	p.info |= reUNonposix
+	for p.pos += 2; p.more() && p.peek() != ')'; p.pos++ {
+		switch p.peek() {
+		case 'b':
+			return false, errors.New(reErrBasic)
+		case 'c':
+			p.flags &^= reNocase
+		case 'e':
+		case 'i':
+			p.flags |= reNocase
+		case 'm', 'n':
+			p.flags |= reLine
+		case 'p':
+			p.flags = p.flags&^reLine | reLineStop
+		case 'q':
+			literal = true
+		case 's':
+			p.flags &^= reLine
+		case 't':
+			p.flags &^= reExpanded
+		case 'w':
+			p.flags = p.flags&^reLine | reLineAnchor
+		case 'x':
+			p.flags |= reExpanded
+		default:
+			return false, errors.New(reErrOption)
+		}
+	}
+	if !p.more() {
+		return false, errors.New(reErrOption)
+	}
+	p.pos++
+	return literal, nil
+}
+
+func (p *reParser) parseAlternation() (*reNode, error) {
+	var branches []*reNode
+	for {
+		b, err := p.parseBranch()
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, b)
+		if !p.more() || p.peek() != '|' {
+			break
+		}
+		p.pos++
+	}
+	if len(branches) == 1 {
+		return branches[0], nil
+	}
+	return &reNode{op: reAlternate, subs: branches}, nil
+}
+
+func (p *reParser) parseBranch() (*reNode, error) {
+	n := &reNode{op: reConcat}
+	for {
+		p.skip()
+		if !p.more() || p.peek() == '|' || p.peek() == ')' {
+			return n, nil
+		}
+		atom, err := p.parseAtom()
+		if err != nil {
+			return nil, err
+		}
+		if atom, err = p.parseQuantifier(atom); err != nil {
+			return nil, err
+		}
+		n.subs = append(n.subs, atom)
+	}
+}
+
+func (p *reParser) parseAtom() (*reNode, error) {
+	c := p.peek()
+	p.pos++
+	switch c {
+	case '(':
+		return p.parseGroup()
+	case '[':
+		return p.parseBracket()
+	case '.':
+		return &reNode{op: reAny}, nil
+	case '^':
+		return &reNode{op: reBol}, nil
+	case '$':
+		return &reNode{op: reEol}, nil
+	case '\\':
+		return p.parseEscape()
+	case '*', '+', '?':
+		return nil, errors.New(reErrRepeat)
+	case '{':
+		if p.more() && isASCIIDigit(p.peek()) {
+			return nil, errors.New(reErrRepeat)
+		}
+	}
+	return &reNode{op: reLiteral, r: c}, nil
+}
+
+func (p *reParser) parseGroup() (*reNode, error) {
+	n := 0
+	var look *reNode
+	switch {
+	case p.lookingAt("?:"):
+		p.pos += 2
+		p.info |= reUNonposix
+	case p.lookingAt("?="), p.lookingAt("?!"):
+		look = &reNode{op: reLook, neg: p.src[p.pos+1] == '!'}
+		p.pos += 2
+	case p.lookingAt("?<="), p.lookingAt("?<!"):
+		look = &reNode{op: reLook, neg: p.src[p.pos+2] == '!', behind: true}
+		p.pos += 3
+	case p.lookingAt("?"):
+		return nil, errors.New(reErrRepeat)
+	default:
+		if p.look == 0 {
+			p.ngroup++
+			n = p.ngroup
+		}
+	}
+	if look != nil {
+		p.info |= reULookahead | reUNonposix
+		p.look++
+		defer func() { p.look-- }()
+	}
+	sub, err := p.parseAlternation()
+	if err != nil {
+		return nil, err
+	}
+	if !p.more() {
+		return nil, errors.New(reErrParen)
+	}
+	p.pos++
+	switch {
+	case look != nil:
+		look.subs = []*reNode{sub}
+		return look, nil
+	case n > 0:
+		return &reNode{op: reCapture, n: n, subs: []*reNode{sub}}, nil
+	}
+	return sub, nil
+}
+
+// parseQuantifier parses the quantifier, if any, that follows atom.
+func (p *reParser) parseQuantifier(atom *reNode) (*reNode, error) {
+	p.skip()
+	if !p.more() {
+		return atom, nil
+	}
+	min, max := 0, -1
+	switch p.peek() {
+	case '*':
+		p.pos++
+	case '+':
+		min = 1
+		p.pos++
+	case '?':
+		max = 1
+		p.pos++
+	case '{':
+		if p.pos+1 >= len(p.src) || !isASCIIDigit(p.src[p.pos+1]) {
+			return atom, nil
+		}
+		p.pos++
+		var err error
+		if min, max, err = p.parseBound(); err != nil {
+			return nil, err
+		}
+	default:
+		return atom, nil
+	}
+	if atom.isConstraint() {
+		return nil, errors.New(reErrRepeat)
+	}
+	greedy := true
+	if p.more() && p.peek() == '?' {
+		p.pos++
+		greedy = false
+		p.info |= reUNonposix
+	}
+	p.skip()
+	if p.more() {
+		switch c := p.peek(); {
+		case c == '*' || c == '+' || c == '?',
+			c == '{' && p.pos+1 < len(p.src) && isASCIIDigit(p.src[p.pos+1]):
+			return nil, errors.New(reErrRepeat)
+		}
+	}
+	return &reNode{op: reRepeat, min: min, max: max, greedy: greedy, subs: []*reNode{atom}}, nil
+}
+
+// parseBound parses the {m}, {m,} or {m,n} bound following the
+// opening brace.
+func (p *reParser) parseBound() (int, int, error) {
+	p.info |= reUBounds
+	number := func() int {
+		n := 0
+		for p.more() && isASCIIDigit(p.peek()) {
+			if n <= reMaxRepeat {
+				n = n*10 + int(p.peek()-'0')
+			}
+			p.pos++
+		}
+		return n
+	}
+	min := number()
+	max := min
+	if p.more() && p.peek() == ',' {
+		p.pos++
+		max = -1
+		if p.more() && isASCIIDigit(p.peek()) {
+			max = number()
+		}
+	}
+	if !p.more() {
+		return 0, 0, errors.New(reErrBrace)
+	}
+	if p.peek() != '}' {
+		return 0, 0, errors.New(reErrCount)
+	}
+	p.pos++
+	if min > reMaxRepeat || max > reMaxRepeat || max >= 0 && min > max {
+		return 0, 0, errors.New(reErrCount)
+	}
+	return min, max, nil
+}
+
+// parseEscape parses an escape following a backslash outside a
+// bracket expression.
+func (p *reParser) parseEscape() (*reNode, error) {
+	if !p.more() {
+		return nil, errors.New(reErrEscape)
+	}
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'd', 's', 'w', 'D', 'S', 'W':
+		p.info |= reUNonposix | reULocale
+		lower := unicode.ToLower(c)
+		return &reNode{op: reClass, class: &charClass{
+			named:  []func(rune) bool{shorthandClass(lower)},
+			negate: c != lower,
+		}}, nil
+	case 'A':
+		return &reNode{op: reBos}, nil
+	case 'Z':
+		return &reNode{op: reEos}, nil
+	case 'm':
+		return &reNode{op: reWordStart}, nil
+	case 'M':
+		return &reNode{op: reWordEnd}, nil
+	case 'y':
+		return &reNode{op: reWordBoundary}, nil
+	case 'Y':
+		return &reNode{op: reNotWordBoundary}, nil
+	}
+	if '1' <= c && c <= '9' {
+		start := p.pos
+		n := int(c - '0')
+		for p.more() && isASCIIDigit(p.peek()) && n <= reMaxRepeat {
+			n = n*10 + int(p.peek()-'0')
+			p.pos++
+		}
+		if p.pos == start || n <= p.ngroup {
+			if n > p.ngroup || p.look > 0 {
+				return nil, errors.New(reErrSubreg)
+			}
+			p.info |= reUBackref
+			return &reNode{op: reBackref, n: n}, nil
+		}
+		// Not a back reference after all, so an octal escape.
+		p.pos = start
+	}
+	r, err := p.charEscape(c)
+	if err != nil {
+		return nil, err
+	}
+	return &reNode{op: reLiteral, r: r}, nil
+}
+
+// charEscape returns the character given by a character-entry
+// escape c, whose backslash and c have been consumed, or by an
+// escaped non-alphanumeric character.
+func (p *reParser) charEscape(c rune) (rune, error) {
+	hex := func(maxDigits int) (rune, error) {
+		var r rune
+		n := 0
+		for ; n < maxDigits && p.more() && p.peek() < 128 && digitValue(byte(p.peek())) < 16; n++ {
+			r = r*16 + rune(digitValue(byte(p.peek())))
+			p.pos++
+		}
+		if n == 0 || r > unicode.MaxRune {
+			return 0, errors.New(reErrEscape)
+		}
+		return r, nil
+	}
+	switch c {
+	case 'a':
+		return '\a', nil
+	case 'b':
+		return '\b', nil
+	case 'B':
+		return '\\', nil
+	case 'c':
+		if !p.more() {
+			return 0, errors.New(reErrEscape)
+		}
+		r := p.peek() & 0x1f
+		p.pos++
+		return r, nil
+	case 'e':
+		return 0x1b, nil
+	case 'f':
+		return '\f', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case 'v':
+		return '\v', nil
+	case 'u':
+		return hex(4)
+	case 'U':
+		return hex(8)
+	case 'x':
+		return hex(2)
+	}
+	if '0' <= c && c <= '7' {
+		p.info |= reUUnport
+		r := c - '0'
+		for n := 1; n < 3 && p.more() && '0' <= p.peek() && p.peek() <= '7'; n++ {
+			r = r*8 + p.peek() - '0'
+			p.pos++
+		}
+		return r, nil
+	}
+	if c < 128 && (isASCIIDigit(c) || unicode.IsLetter(c)) {
+		return 0, errors.New(reErrEscape)
+	}
+	return c, nil
+}
+
+// parseBracket parses a bracket expression following the [.
+func (p *reParser) parseBracket() (*reNode, error) {
+	switch {
+	case p.lookingAt("[:<:]]"):
+		p.pos += 6
+		return &reNode{op: reWordStart}, nil
+	case p.lookingAt("[:>:]]"):
+		p.pos += 6
+		return &reNode{op: reWordEnd}, nil
+	}
+	cc := &charClass{}
+	if p.more() && p.peek() == '^' {
+		cc.negate = true
+		p.pos++
+	}
+	for first := true; ; first = false {
+		if !p.more() {
+			return nil, errors.New(reErrBracket)
+		}
+		if p.peek() == ']' && !first {
+			p.pos++
+			break
+		}
+		lo, class, err := p.bracketElement()
+		if err != nil {
+			return nil, err
+		}
+		if class != nil {
+			cc.named = append(cc.named, class)
+			continue
+		}
+		hi := lo
+		if p.lookingAt("-") && p.pos+1 < len(p.src) && p.src[p.pos+1] != ']' {
+			p.pos++
+			if hi, class, err = p.bracketElement(); err != nil {
+				return nil, err
+			}
+			if class != nil || hi < lo {
+				return nil, errors.New(reErrRange)
+			}
+			// A range may not start at the end of another.
+			if p.lookingAt("-") && p.pos+1 < len(p.src) && p.src[p.pos+1] != ']' {
+				return nil, errors.New(reErrRange)
+			}
+		}
+		cc.ranges = append(cc.ranges, lo, hi)
+	}
+	return &reNode{op: reClass, class: cc}, nil
+}
+
+// bracketElement parses a character, collating element, equivalence
+// class or character class within a bracket expression. It returns
+// either the character or a function matching the class.
+func (p *reParser) bracketElement() (rune, func(rune) bool, error) {
+	c := p.peek()
+	p.pos++
+	if c == '[' && p.more() && strings.ContainsRune(":.=", p.peek()) {
+		delim := p.peek()
+		p.pos++
+		start := p.pos
+		for p.more() && !(p.peek() == delim && p.pos+1 < len(p.src) && p.src[p.pos+1] == ']') {
+			p.pos++
+		}
+		if !p.more() {
+			return 0, nil, errors.New(reErrBracket)
+		}
+		name := string(p.src[start:p.pos])
+		p.pos += 2
+		if delim == ':' {
+			p.info |= reULocale
+			class, ok := reNamedClasses[name]
+			if !ok {
+				return 0, nil, errors.New(reErrCtype)
+			}
+			return 0, class, nil
+		}
+		if r := []rune(name); len(r) == 1 {
+			return r[0], nil, nil
+		}
+		return 0, nil, errors.New(reErrCollate)
+	}
+	if c != '\\' {
+		return c, nil, nil
+	}
+	if !p.more() {
+		return 0, nil, errors.New(reErrBracket)
+	}
+	e := p.peek()
+	p.pos++
+	switch e {
+	case 'd', 's', 'w':
+		p.info |= reUNonposix | reULocale
+		return 0, shorthandClass(e), nil
+	}
+	if '1' <= e && e <= '9' {
+		return 0, nil, errors.New(reErrEscape)
+	}
+	r, err := p.charEscape(e)
+	return r, nil, err
+}
+
+// nullable reports whether n can match an empty string.
+func (n *reNode) nullable() bool {
+	switch n.op {
+	case reLiteral, reClass, reAny, reBackref:
+		return false
+	case reConcat:
+		for _, s := range n.subs {
+			if !s.nullable() {
+				return false
+			}
+		}
+		return true
+	case reAlternate:
+		for _, s := range n.subs {
+			if s.nullable() {
+				return true
+			}
+		}
+		return false
+	case reCapture:
+		return n.subs[0].nullable()
+	case reRepeat:
+		return n.min == 0 || n.subs[0].nullable()
+	}
+	return true
+}
+
+// shortest reports whether the match preference of n is for the
+// shortest match, and ok whether n has a preference at all.
+func (n *reNode) shortest() (shortest, ok bool) {
+	switch n.op {
+	case reAlternate:
+		return false, true
+	case reConcat:
+		for _, s := range n.subs {
+			if shortest, ok := s.shortest(); ok {
+				return shortest, true
+			}
+		}
+	case reCapture:
+		return n.subs[0].shortest()
+	case reRepeat:
+		return !n.greedy, true
+	}
+	return false, false
+}
+
+type instOp uint8
+
+const (
+	opRune instOp = iota
+	opClass
+	opAny
+	opSplit
+	opJmp
+	opSave
+	opAssert
+	opBackref
+	opLook
+	opMatch
+)
+
+// A reInst is an instruction of a compiled regular expression.
+// opSplit continues at x and then, if that fails, at y.
+type reInst struct {
+	op    instOp
+	r     rune
+	class *charClass
+	x, y  int
+	// n is the capture slot of opSave and the group of opBackref.
+	n      int
+	assert reOp
+	sub    *reProg
+	neg    bool
+	behind bool
+}
+
+// A reProg is a compiled regular expression or lookaround constraint.
+type reProg struct {
+	insts      []reInst
+	nocase     bool
+	lineStop   bool
+	lineAnchor bool
+	// memo is set when explored states may be remembered, which
+	// is not the case with back references.
+	memo bool
+}
+
+func (prog *reProg) emit(in reInst) int {
+	prog.insts = append(prog.insts, in)
+	return len(prog.insts) - 1
+}
+
+func (prog *reProg) compile(n *reNode) error {
+	if len(prog.insts) > reMaxInsts {
+		return errors.New(reErrTooBig)
+	}
+	switch n.op {
+	case reLiteral:
+		prog.emit(reInst{op: opRune, r: n.r})
+	case reClass:
+		prog.emit(reInst{op: opClass, class: n.class})
+	case reAny:
+		prog.emit(reInst{op: opAny})
+	case reConcat:
+		for _, s := range n.subs {
+			if err := prog.compile(s); err != nil {
+				return err
+			}
+		}
+	case reAlternate:
+		var jmps []int
+		for i, s := range n.subs {
+			split := -1
+			if i < len(n.subs)-1 {
+				split = prog.emit(reInst{op: opSplit})
+				prog.insts[split].x = split + 1
+			}
+			if err := prog.compile(s); err != nil {
+				return err
+			}
+			if split >= 0 {
+				jmps = append(jmps, prog.emit(reInst{op: opJmp}))
+				prog.insts[split].y = len(prog.insts)
+			}
+		}
+		for _, j := range jmps {
+			prog.insts[j].x = len(prog.insts)
+		}
+	case reCapture:
+		prog.emit(reInst{op: opSave, n: 2 * n.n})
+		if err := prog.compile(n.subs[0]); err != nil {
+			return err
+		}
+		prog.emit(reInst{op: opSave, n: 2*n.n + 1})
+	case reRepeat:
+		return prog.compileRepeat(n)
+	case reBackref:
+		prog.memo = false
+		prog.emit(reInst{op: opBackref, n: n.n})
+	case reLook:
+		sub := &reProg{nocase: prog.nocase, lineStop: prog.lineStop, lineAnchor: prog.lineAnchor, memo: true}
+		if err := sub.compile(n.subs[0]); err != nil {
+			return err
+		}
+		sub.emit(reInst{op: opMatch})
+		prog.emit(reInst{op: opLook, sub: sub, neg: n.neg, behind: n.behind})
+	default:
+		prog.emit(reInst{op: opAssert, assert: n.op})
+	}
+	return nil
+}
+
+func (prog *reProg) compileRepeat(n *reNode) error {
+	sub := n.subs[0]
+	for i := 0; i < n.min; i++ {
+		if err := prog.compile(sub); err != nil {
+			return err
+		}
+	}
+	// branch points split at to enter sub or skip to the end,
+	// depending on greediness.
+	branch := func(split, end int) {
+		if n.greedy {
+			prog.insts[split].x, prog.insts[split].y = split+1, end
+		} else {
+			prog.insts[split].x, prog.insts[split].y = end, split+1
+		}
+	}
+	if n.max < 0 {
+		split := prog.emit(reInst{op: opSplit})
+		if err := prog.compile(sub); err != nil {
+			return err
+		}
+		prog.emit(reInst{op: opJmp, x: split})
+		branch(split, len(prog.insts))
+		return nil
+	}
+	var splits []int
+	for i := n.min; i < n.max; i++ {
+		splits = append(splits, prog.emit(reInst{op: opSplit}))
+		if err := prog.compile(sub); err != nil {
+			return err
+		}
+	}
+	for _, split := range splits {
+		branch(split, len(prog.insts))
+	}
+	return nil
+}
+
+// A tclRegexp is a compiled regular expression.
+type tclRegexp struct {
+	prog *reProg
+	// nsub is the number of capturing subexpressions.
+	nsub     int
+	shortest bool
+	info     reInfo
+}
+
+// compileRegexp compiles pattern as a Tcl advanced regular
+// expression.
+func compileRegexp(pattern string, flags regexpFlags) (*tclRegexp, error) {
+	re, err := compileRegexpSyntax([]rune(pattern), flags)
+	if err != nil {
+		return nil, fmt.Errorf("%s%s", reErrorPrefix, err)
+	}
+	return re, nil
+}
+
+func compileRegexpSyntax(pattern []rune, flags regexpFlags) (*tclRegexp, error) {
+	p := &reParser{src: pattern, flags: flags}
+	literal, err := p.options()
+	if err != nil {
+		return nil, err
+	}
+	var tree *reNode
+	if literal {
+		tree = &reNode{op: reConcat}
+		for _, r := range pattern[p.pos:] {
+			tree.subs = append(tree.subs, &reNode{op: reLiteral, r: r})
+		}
+	} else {
+		if tree, err = p.parseAlternation(); err != nil {
+			return nil, err
+		}
+		if p.more() {
+			return nil, errors.New(reErrParen)
+		}
+	}
+	re := &tclRegexp{nsub: p.ngroup, info: p.info}
+	re.shortest, _ = tree.shortest()
+	if re.shortest {
+		re.info |= reUShortest
+	}
+	if tree.nullable() {
+		re.info |= reUEmptymatch
+	}
+	re.prog = &reProg{
+		nocase:     p.flags&reNocase != 0,
+		lineStop:   p.flags&reLineStop != 0,
+		lineAnchor: p.flags&reLineAnchor != 0,
+		memo:       true,
+	}
+	re.prog.emit(reInst{op: opSave, n: 0})
+	if err := re.prog.compile(tree); err != nil {
+		return nil, err
+	}
+	re.prog.emit(reInst{op: opSave, n: 1})
+	re.prog.emit(reInst{op: opMatch})
+	return re, nil
+}
+
+// regexpRep is the internal representation of a Value compiled as a
+// regular expression with the given flags.
+type regexpRep struct {
+	str   string
+	flags regexpFlags
+	re    *tclRegexp
+}
+
+func (rep *regexpRep) String() string { return rep.str }
+
+// regexp returns v compiled as a regular expression, caching the
+// compiled form in v.
+func (v *Value) regexp(flags regexpFlags) (*tclRegexp, error) {
+	if rep, ok := v.rep.(*regexpRep); ok && rep.flags == flags {
+		return rep.re, nil
+	}
+	re, err := compileRegexp(v.String(), flags)
+	if err != nil {
+		return nil, err
+	}
+	v.setRep(&regexpRep{str: v.String(), flags: flags, re: re})
+	return re, nil
+}
+
+// matchString reports whether re matches anywhere in s.
+func (re *tclRegexp) matchString(s string) bool {
+	return re.exec([]rune(s), false) != nil
+}
+
+// exec finds the leftmost match of re in input. It returns the
+// start and end of the match and of each subexpression, with -1 for
+// subexpressions that did not participate, or nil if there is no
+// match. If notbol is set, ^ does not match at the start of input.
+func (re *tclRegexp) exec(input []rune, notbol bool) []int {
+	m := newReMachine(re.prog, input, notbol)
+	m.explore = true
+	m.shortest = re.shortest
+	m.caps = make([]int, 2*(re.nsub+1))
+	m.bestCaps = make([]int, len(m.caps))
+	for start := 0; start <= len(input); start++ {
+		for i := range m.caps {
+			m.caps[i] = -1
+		}
+		m.best = -1
+		m.try(0, start)
+		if m.best >= 0 {
+			return m.bestCaps
+		}
+	}
+	return nil
+}
+
+// reMachine runs a compiled program over an input.
+type reMachine struct {
+	prog   *reProg
+	input  []rune
+	notbol bool
+	caps   []int
+	// When explore is set, every path is tried and the longest or
+	// shortest match is recorded in best and bestCaps. Otherwise
+	// the first match ending at endAt, or anywhere if endAt is
+	// negative, is taken.
+	explore  bool
+	shortest bool
+	best     int
+	bestCaps []int
+	endAt    int
+	// visited marks the split instructions explored at each input
+	// position. Without memo, only those on the current path are
+	// marked, which stops empty loops from recursing forever.
+	visited []uint64
+	looks   map[*reInst]*reMachine
+}
+
+func newReMachine(prog *reProg, input []rune, notbol bool) *reMachine {
+	return &reMachine{
+		prog:    prog,
+		input:   input,
+		notbol:  notbol,
+		endAt:   -1,
+		visited: make([]uint64, (len(prog.insts)*(len(input)+1)+63)/64),
+	}
+}
+
+func (m *reMachine) seen(pc, pos int) bool {
+	i := pc*(len(m.input)+1) + pos
+	return m.visited[i/64]&(1<<(i%64)) != 0
+}
+
+func (m *reMachine) mark(pc, pos int, on bool) {
+	i := pc*(len(m.input)+1) + pos
+	if on {
+		m.visited[i/64] |= 1 << (i % 64)
+	} else {
+		m.visited[i/64] &^= 1 << (i % 64)
+	}
+}
+
+func (m *reMachine) equal(a, b rune) bool {
+	return a == b || m.prog.nocase && (unicode.ToLower(a) == unicode.ToLower(b) || unicode.ToUpper(a) == unicode.ToUpper(b))
+}
+
+// try runs the program from instruction pc at input position pos and
+// reports whether the search is complete.
+func (m *reMachine) try(pc, pos int) bool {
+	prog := m.prog
+	for {
+		in := &prog.insts[pc]
+		switch in.op {
+		case opRune:
+			if pos >= len(m.input) || !m.equal(m.input[pos], in.r) {
+				return false
+			}
+			pc, pos = pc+1, pos+1
+		case opClass:
+			if pos >= len(m.input) || !in.class.matches(m.input[pos], prog.nocase, prog.lineStop) {
+				return false
+			}
+			pc, pos = pc+1, pos+1
+		case opAny:
+			if pos >= len(m.input) || prog.lineStop && m.input[pos] == '\n' {
+				return false
+			}
+			pc, pos = pc+1, pos+1
+		case opJmp:
+			pc = in.x
+		case opSplit:
+			if m.seen(pc, pos) {
+				return false
+			}
+			m.mark(pc, pos, true)
+			done := m.try(in.x, pos) || m.try(in.y, pos)
+			if !prog.memo {
+				m.mark(pc, pos, false)
+			}
+			return done
+		case opSave:
+			old := m.caps[in.n]
+			m.caps[in.n] = pos
+			if m.try(pc+1, pos) {
+				return true
+			}
+			m.caps[in.n] = old
+			return false
+		case opAssert:
+			if !m.assert(in.assert, pos) {
+				return false
+			}
+			pc++
+		case opBackref:
+			start, end := m.caps[2*in.n], m.caps[2*in.n+1]
+			if start < 0 || end < 0 || pos+end-start > len(m.input) {
+				return false
+			}
+			for i := start; i < end; i++ {
+				if !m.equal(m.input[i], m.input[pos]) {
+					return false
+				}
+				pos++
+			}
+			pc++
+		case opLook:
+			if m.look(in, pos) == in.neg {
+				return false
+			}
+			pc++
+		case opMatch:
+			if !m.explore {
+				return m.endAt < 0 || pos == m.endAt
+			}
+			if m.best < 0 || m.shortest && pos < m.best || !m.shortest && pos > m.best {
+				m.best = pos
+				copy(m.bestCaps, m.caps)
+			}
+			return false
+		}
+	}
+}
+
+func (m *reMachine) assert(op reOp, pos int) bool {
+	wordBefore := pos > 0 && isWordChar(m.input[pos-1])
+	wordAfter := pos < len(m.input) && isWordChar(m.input[pos])
+	switch op {
+	case reBol:
+		return pos == 0 && !m.notbol || m.prog.lineAnchor && pos > 0 && m.input[pos-1] == '\n'
+	case reEol:
+		return pos == len(m.input) || m.prog.lineAnchor && m.input[pos] == '\n'
+	case reBos:
+		return pos == 0
+	case reEos:
+		return pos == len(m.input)
+	case reWordStart:
+		return !wordBefore && wordAfter
+	case reWordEnd:
+		return wordBefore && !wordAfter
+	case reWordBoundary:
+		return wordBefore != wordAfter
+	case reNotWordBoundary:
+		return wordBefore == wordAfter
+	}
+	return false
+}
+
+// look reports whether the lookaround constraint in matches at pos,
+// ignoring its negation. A lookbehind constraint matches if its RE
+// matches some input ending at pos.
+func (m *reMachine) look(in *reInst, pos int) bool {
+	if m.looks == nil {
+		m.looks = map[*reInst]*reMachine{}
+	}
+	sub := m.looks[in]
+	if sub == nil {
+		sub = newReMachine(in.sub, m.input, m.notbol)
+		if in.behind {
+			// The end position varies, so explored states cannot
+			// be remembered.
+			sub.prog = &reProg{}
+			*sub.prog = *in.sub
+			sub.prog.memo = false
+		}
+		m.looks[in] = sub
+	}
+	if !in.behind {
+		if !sub.try(0, pos) {
+			return false
+		}
+		// States on the successful path were not failures, so
+		// they must be explored again next time.
+		clear(sub.visited)
+		return true
+	}
+	sub.endAt = pos
+	for start := pos; start >= 0; start-- {
+		if sub.try(0, start) {
+			return true
+		}
+	}
+	return false
+}
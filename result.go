@@ -0,0 +1,119 @@
+package gotcl
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A Code is the completion code of a Tcl command.
+type Code int
+
+const (
+	CodeOK Code = iota
+	CodeError
+	CodeReturn
+	CodeBreak
+	CodeContinue
+)
+
+// A codeError is returned by a command that completes with a code
+// other than CodeOK or CodeError, such as break and continue. It is
+// an error so that it unwinds through the Go call stack until a
+// command that handles it, such as a loop, is reached.
+//
+// The return command completes with CodeReturn; level is then the
+// number of procedure calls left to unwind before the procedure
+// returns with returnCode.
+type codeError struct {
+	code       Code
+	value      *Value
+	level      int
+	returnCode Code
+}
+
+var (
+	errBreak    = &codeError{code: CodeBreak}
+	errContinue = &codeError{code: CodeContinue}
+)
+
+func (e *codeError) Error() string {
+	switch e.code {
+	case CodeBreak:
+		return `invoked "break" outside of a loop`
+	case CodeContinue:
+		return `invoked "continue" outside of a loop`
+	}
+	return fmt.Sprintf("command returned bad code: %d", e.code)
+}
+
+// A tailcallError is returned by tailcall. It unwinds the body of
+// the procedure that called tailcall, which then invokes command in
+// its place once its frame has been discarded. method is the method
+// context tailcall was called in, which command keeps so that my
+// and self still refer to the object.
+type tailcallError struct {
+	command []*Value
+	method  *methodContext
+}
+
+func (e *tailcallError) Error() string {
+	return "tailcall can only be called from a proc, lambda or method"
+}
+
+// An Error is an error with an error code: a list whose first element
+// identifies the general class of the error, such as CHILDSTATUS for
+// a child process that exited abnormally. The code is stored in the
+// global variable errorCode when the error is raised.
+type Error struct {
+	Code []string
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// errorCodeOf returns the error code of err, which is NONE unless err
+// is or wraps an Error.
+func errorCodeOf(err error) *Value {
+	var e *Error
+	if errors.As(err, &e) {
+		return NewListValue(stringsToValues(e.Code))
+	}
+	return NewStringValue("NONE")
+}
+
+// errorCode returns the completion code of err.
+func errorCode(err error) Code {
+	if err == nil {
+		return CodeOK
+	}
+	if e, ok := err.(*codeError); ok {
+		return e.code
+	}
+	return CodeError
+}
+
+// break
+//
+// This command is typically invoked inside the body of a looping
+// command such as for or foreach or while. It returns a TCL_BREAK
+// code, which causes a break exception to occur.
+func cmdBreak(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 1 {
+		return nil, wrongNumArgs(args, 1, "")
+	}
+	return nil, errBreak
+}
+
+// continue
+//
+// This command is typically invoked inside the body of a looping
+// command such as for or foreach or while. It returns a
+// TCL_CONTINUE code, which causes a continue exception to occur.
+func cmdContinue(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 1 {
+		return nil, wrongNumArgs(args, 1, "")
+	}
+	return nil, errContinue
+}
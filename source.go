@@ -0,0 +1,165 @@
+package gotcl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// A ScriptError is an error raised by a command of a sourced script
+// file. It records the file and the line of the command that failed;
+// its message is that of the underlying error.
+type ScriptError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *ScriptError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// locate wraps err, raised by the command at line of the script src,
+// in a ScriptError if the script is a sourced file and the error has
+// not been located in a file already.
+func (src *scriptSource) locate(err error, line int) error {
+	if src.file == "" || errorCode(err) != CodeError {
+		return err
+	}
+	var se *ScriptError
+	if _, ok := err.(*tailcallError); ok || errors.As(err, &se) {
+		return err
+	}
+	return &ScriptError{File: src.file, Line: line, Err: err}
+}
+
+// SetFS makes source and EvalFile read script files from fsys rather
+// than the host filesystem, so that scripts embedded in the program
+// with embed.FS can be sourced. File names are resolved relative to
+// the root of fsys. A nil fsys restores the host filesystem.
+func (interp *Interp) SetFS(fsys fs.FS) {
+	interp.fs = fsys
+}
+
+// EvalFile evaluates the script in the file name, as source does,
+// and returns its result.
+func (interp *Interp) EvalFile(name string) (string, error) {
+	v, err := interp.sourceFile(name, "utf-8")
+	if err != nil {
+		return "", err
+	}
+	return v.String(), nil
+}
+
+// source ?-encoding name? fileName
+//
+// This command takes the contents of the specified file and passes
+// it to the Tcl interpreter as a text script. The return value from
+// source is the return value of the last command executed in the
+// script. If an error occurs in evaluating the contents of the
+// script then the source command will return that error. If a return
+// command is invoked from within the script then the remainder of
+// the file will be skipped and the source command will return
+// normally with the result from the return command.
+//
+// The end-of-file character for files is "\32" (^Z) and reading
+// stops there. The -encoding option specifies the encoding of the
+// file, utf-8 by default. While the script runs, info script returns
+// fileName.
+func cmdSource(interp *Interp, args []*Value) (*Value, error) {
+	encoding := "utf-8"
+	switch len(args) {
+	case 2:
+	case 4:
+		if args[1].String() != "-encoding" {
+			return nil, fmt.Errorf("bad option \"%s\": must be -encoding", args[1])
+		}
+		encoding = args[2].String()
+		if !containsString(channelEncodings, encoding) {
+			return nil, fmt.Errorf("unknown encoding \"%s\"", encoding)
+		}
+	default:
+		return nil, wrongNumArgs(args, 1, "?-encoding name? fileName")
+	}
+	return interp.sourceFile(args[len(args)-1].String(), encoding)
+}
+
+// sourceFile evaluates the script in the file name, decoded from
+// encoding.
+func (interp *Interp) sourceFile(name, encoding string) (*Value, error) {
+	data, err := interp.readScriptFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read file \"%s\": %s", name, posixError(err))
+	}
+	saved := interp.scriptFile
+	interp.scriptFile = name
+	defer func() { interp.scriptFile = saved }()
+	v, err := interp.evalScriptFrom(decodeScript(data, encoding), &scriptSource{typ: "source", file: name})
+	if e, ok := err.(*codeError); ok && e.code == CodeReturn {
+		v, err = completeReturn(e.value, e.returnCode)
+	}
+	return v, err
+}
+
+// readScriptFile reads the file name from the filesystem of interp.
+func (interp *Interp) readScriptFile(name string) ([]byte, error) {
+	if interp.fs == nil {
+		f, err := interp.filesystem().OpenFile(name, os.O_RDONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+	return fs.ReadFile(interp.fs, fsPath(name))
+}
+
+// readScriptDir reads the directory name from the filesystem of
+// interp.
+func (interp *Interp) readScriptDir(name string) ([]fs.DirEntry, error) {
+	if interp.fs == nil {
+		return interp.filesystem().ReadDir(name)
+	}
+	return fs.ReadDir(interp.fs, fsPath(name))
+}
+
+// fsPath converts the file name to a path in an fs.FS, relative to
+// its root.
+func fsPath(name string) string {
+	name = strings.TrimPrefix(path.Clean(filepath.ToSlash(name)), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// decodeScript decodes the contents of a script file from encoding,
+// up to the end-of-file character ^Z. Bytes that are not valid UTF-8
+// are taken as the characters with the same code.
+func decodeScript(data []byte, encoding string) []rune {
+	if i := strings.IndexByte(string(data), '\x1a'); i >= 0 {
+		data = data[:i]
+	}
+	runes := make([]rune, 0, len(data))
+	for len(data) > 0 {
+		r, size := rune(data[0]), 1
+		if encoding == "utf-8" && data[0] >= utf8.RuneSelf {
+			if r, size = utf8.DecodeRune(data); r == utf8.RuneError && size <= 1 {
+				r = rune(data[0])
+			}
+		}
+		runes = append(runes, r)
+		data = data[size:]
+	}
+	return runes
+}
@@ -0,0 +1,76 @@
+package gotcl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSource(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib.tcl":    "proc double {x} {list $x $x}\nset loaded [info script]\n",
+		"early.tcl":  "set a 1\nreturn early\nset a 2\n",
+		"frame.tcl":  "\n# comment\ndict get [info frame 0] file\ninfo frame 0\n",
+		"eof.tcl":    "set tail before\x1aset tail after\n",
+		"latin1.tcl": "set s \xe9\n",
+		"fail.tcl":   "set ok 1\n\nset missing\n",
+		"subst.tcl":  "set ok 1\nset x [list a \\\n  [set missing]]\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `set dir ` + dir + `; source $dir/lib.tcl; double a`, result: "a a"},
+		{script: `list $loaded [info script]`, result: filepath.Join(dir, "lib.tcl") + " {}"},
+		{script: `list [source $dir/early.tcl] $a`, result: "early 1"},
+		{script: `source $dir/frame.tcl`, result: "type source line 4 file " + filepath.Join(dir, "frame.tcl") + " cmd {info frame 0} level 0"},
+		{script: `source $dir/eof.tcl; set tail`, result: "before"},
+		{script: `source -encoding iso8859-1 $dir/latin1.tcl; string length $s`, result: "1"},
+		{script: `source -encoding bogus $dir/latin1.tcl`, err: `unknown encoding "bogus"`},
+		{script: `source -bogus utf-8 $dir/latin1.tcl`, err: `bad option "-bogus": must be -encoding`},
+		{script: `source $dir/none.tcl`, err: `couldn't read file "` + filepath.Join(dir, "none.tcl") + `": no such file or directory`},
+		{script: `source`, err: `wrong # args: should be "source ?-encoding name? fileName"`},
+	})
+
+	_, err := interp.EvalFile(filepath.Join(dir, "fail.tcl"))
+	var se *ScriptError
+	if !errors.As(err, &se) {
+		t.Fatalf("EvalFile error %v is not a ScriptError", err)
+	}
+	if se.File != filepath.Join(dir, "fail.tcl") || se.Line != 3 || se.Error() != `can't read "missing": no such variable` {
+		t.Errorf("EvalFile error: got %s:%d: %v", se.File, se.Line, se)
+	}
+	// An error in a command substitution is located at the command.
+	_, err = interp.EvalFile(filepath.Join(dir, "subst.tcl"))
+	if !errors.As(err, &se) || se.Line != 2 || se.Error() != `can't read "missing": no such variable` {
+		t.Errorf("EvalFile error in a substitution: got %v", err)
+	}
+	if _, err := NewSafeInterp().Eval(`source ` + filepath.Join(dir, "lib.tcl")); err == nil {
+		t.Error("source is available in a safe interpreter")
+	}
+}
+
+func TestSourceFS(t *testing.T) {
+	interp := NewInterp()
+	interp.SetFS(fstest.MapFS{
+		"lib/init.tcl": {Data: []byte("source lib/util.tcl\nproc greet {} {util}\n")},
+		"lib/util.tcl": {Data: []byte("proc util {} {info script}\n")},
+	})
+	if _, err := interp.EvalFile("/lib/init.tcl"); err != nil {
+		t.Fatal(err)
+	}
+	runEvalTests(t, interp, []evalTest{
+		{script: `greet`, result: ""},
+		{script: `source lib/missing.tcl`, err: `couldn't read file "lib/missing.tcl": no such file or directory`},
+	})
+	interp.SetFS(nil)
+	if _, err := interp.EvalFile("lib/init.tcl"); err == nil {
+		t.Error("EvalFile read from the host filesystem")
+	}
+}
@@ -0,0 +1,20 @@
+package gotcl
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// sysStat fills in s from the system-dependent status of info, if it
+// has any.
+func sysStat(info fs.FileInfo, s *statFields) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	s.dev, s.ino, s.nlink = uint64(st.Dev), st.Ino, uint64(st.Nlink)
+	s.uid, s.gid = uint64(st.Uid), uint64(st.Gid)
+	s.atime = time.Unix(st.Atim.Unix())
+	s.ctime = time.Unix(st.Ctim.Unix())
+}
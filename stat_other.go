@@ -0,0 +1,9 @@
+//go:build !linux
+
+package gotcl
+
+import "io/fs"
+
+// sysStat fills in s from the system-dependent status of info, which
+// is not available on this system.
+func sysStat(info fs.FileInfo, s *statFields) {}
@@ -0,0 +1,149 @@
+package gotcl
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var tmPathSubcommands = []subcommand{
+	{"add", cmdTmPathAdd},
+	{"list", cmdTmPathList},
+	{"remove", cmdTmPathRemove},
+}
+
+// tcl::tm::path subcommand ?arg ...?
+//
+// Manipulates the list of module paths searched for Tcl modules. A
+// Tcl module is a file called name-version.tm, holding version of
+// the package name; in the name of a module, the namespace
+// separators :: of the package name are replaced by directory
+// separators, so that the module of package a::b 1.0 is a/b-1.0.tm,
+// relative to one of the module paths. A module is loaded by sourcing
+// it, and the package is provided on its behalf.
+func cmdTmPath(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 2 {
+		return nil, wrongNumArgs(args, 1, "subcommand ?arg ...?")
+	}
+	return dispatchSubcommand(interp, args, tmPathSubcommands)
+}
+
+// tcl::tm::path add ?path ...?
+//
+// The paths are added at the head of the list of module paths, in
+// order of appearance, so that the last argument ends up as the new
+// head of the list. Paths already in the list are ignored. It is an
+// error for a path to be an ancestor or a subdirectory of a path in
+// the list.
+func cmdTmPathAdd(interp *Interp, args []*Value) (*Value, error) {
+	for _, arg := range args[2:] {
+		p := arg.String()
+		if containsString(interp.tmPaths, p) {
+			continue
+		}
+		for _, ep := range interp.tmPaths {
+			switch {
+			case isSubdirectory(ep, p):
+				return nil, fmt.Errorf("%s is ancestor of existing module path %s.", p, ep)
+			case isSubdirectory(p, ep):
+				return nil, fmt.Errorf("%s is subdirectory of existing module path %s.", p, ep)
+			}
+		}
+		interp.tmPaths = append([]string{p}, interp.tmPaths...)
+	}
+	return emptyValue(), nil
+}
+
+// isSubdirectory reports whether the path sub is within the
+// directory dir.
+func isSubdirectory(sub, dir string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(sub))
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// tcl::tm::path list
+//
+// Returns the list of module paths, most recently added first.
+func cmdTmPathList(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) != 2 {
+		return nil, wrongNumArgs(args, 2, "")
+	}
+	return NewListValue(stringsToValues(interp.tmPaths)), nil
+}
+
+// tcl::tm::path remove ?path ...?
+//
+// Removes the paths from the list of module paths. Paths not in the
+// list are ignored.
+func cmdTmPathRemove(interp *Interp, args []*Value) (*Value, error) {
+	for _, arg := range args[2:] {
+		for i, p := range interp.tmPaths {
+			if p == arg.String() {
+				interp.tmPaths = append(interp.tmPaths[:i:i], interp.tmPaths[i+1:]...)
+				break
+			}
+		}
+	}
+	return emptyValue(), nil
+}
+
+// tcl::tm::UnknownHandler original name ?requirement ...?
+//
+// The package unknown handler for Tcl modules. It declares with
+// package ifneeded the versions of the package name found as modules
+// in the module paths; a version found in a path earlier in the list
+// takes precedence. If none of them satisfies the requirements, the
+// command prefix original is invoked with name and the requirements
+// appended.
+func cmdTmUnknownHandler(interp *Interp, args []*Value) (*Value, error) {
+	if len(args) < 3 {
+		return nil, wrongNumArgs(args, 1, "original name ?requirement ...?")
+	}
+	name, reqs := args[2].String(), valuesToStrings(args[3:])
+	if interp.findModules(name, reqs) {
+		return emptyValue(), nil
+	}
+	words, err := args[1].List()
+	if err != nil {
+		return nil, err
+	}
+	return interp.invoke(append(words, args[2:]...))
+}
+
+// findModules declares the modules of the package name found in the
+// module paths, and reports whether one of them satisfies reqs. Safe
+// interpreters do not search the filesystem.
+func (interp *Interp) findModules(name string, reqs []string) bool {
+	if interp.safe {
+		return false
+	}
+	dir, tail := "", name
+	if i := strings.LastIndex(name, "::"); i >= 0 {
+		dir, tail = strings.ReplaceAll(name[:i], "::", string(filepath.Separator)), name[i+2:]
+	}
+	p := interp.pkg(name)
+	satisfied := false
+	for _, root := range interp.tmPaths {
+		d := filepath.Join(root, dir)
+		entries, _ := interp.readScriptDir(d)
+		for _, e := range entries {
+			v, prefixed := strings.CutPrefix(e.Name(), tail+"-")
+			v, suffixed := strings.CutSuffix(v, ".tm")
+			if !prefixed || !suffixed || e.IsDir() {
+				continue
+			}
+			if _, err := parseVersion(v); err != nil {
+				continue
+			}
+			if _, ok := p.ifneeded[v]; ok {
+				continue
+			}
+			file := filepath.Join(d, e.Name())
+			script := formatList([]string{"package", "provide", name, v}) + ";" +
+				formatList([]string{"source", "-encoding", "utf-8", file})
+			p.ifneeded[v] = &pkgScript{script: NewStringValue(script)}
+			satisfied = satisfied || satisfiesAny(v, reqs)
+		}
+	}
+	return satisfied
+}
@@ -0,0 +1,42 @@
+package gotcl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModules(t *testing.T) {
+	mods, shadow := t.TempDir(), t.TempDir()
+	for name, data := range map[string]string{
+		filepath.Join(mods, "greet-1.0.tm"):       "proc greet {} {list old}\n",
+		filepath.Join(mods, "greet-1.2.tm"):       "set ::loadedFrom [info script]\nproc greet {} {list new}\n",
+		filepath.Join(mods, "greeter-9.0.tm"):     "error never\n",
+		filepath.Join(mods, "util", "str-0.5.tm"): "proc util_str {} {package present util::str}\n",
+		filepath.Join(shadow, "greet-1.2.tm"):     "proc greet {} {list shadowed}\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	interp := NewInterp()
+	runEvalTests(t, interp, []evalTest{
+		{script: `package unknown`, result: defaultPackageUnknown},
+		{script: `tcl::tm::path add ` + shadow + ` ` + mods + `; tcl::tm::path list`, result: mods + " " + shadow},
+		{script: `package require greet`, result: "1.2"},
+		{script: `list [greet] $loadedFrom`, result: "new " + filepath.Join(mods, "greet-1.2.tm")},
+		{script: `package versions greet`, result: "1.0 1.2"},
+		{script: `package require util::str; util_str`, result: "0.5"},
+		{script: `tcl::tm::path add ` + filepath.Join(mods, "util"), err: filepath.Join(mods, "util") + ` is subdirectory of existing module path ` + mods + `.`},
+		{script: `tcl::tm::path add ` + filepath.Dir(mods), err: filepath.Dir(mods) + ` is ancestor of existing module path ` + mods + `.`},
+		{script: `tcl::tm::path remove ` + mods + `; tcl::tm::path list`, result: shadow},
+		{script: `tcl::tm::path bogus`, err: `unknown or ambiguous subcommand "bogus": must be add, list, or remove`},
+		{script: `proc handler {prefix name args} {lappend ::asked $prefix $name $args; package ifneeded $name 3.0 [list package provide $name 3.0]}`},
+		{script: `package unknown {handler pre}; package require made 3; set asked`, result: "pre made 3"},
+		{script: `package unknown {}; package unknown`, result: ""},
+		{script: `package require missing`, err: `can't find package missing`},
+	})
+}
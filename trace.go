@@ -0,0 +1,291 @@
+package gotcl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A TraceOp is a set of operations for which a trace is invoked.
+type TraceOp int
+
+const (
+	// TraceRead traces reads of a variable.
+	TraceRead TraceOp = 1 << iota
+	// TraceWrite traces writes to a variable.
+	TraceWrite
+	// TraceUnset traces the unsetting of a variable.
+	TraceUnset
+	// TraceArray traces the array command being applied to a
+	// variable.
+	TraceArray
+
+	traceRename
+	traceDelete
+
+	traceEnter
+	traceLeave
+	traceEnterStep
+	traceLeaveStep
+
+	traceCommandOps   = traceRename | traceDelete
+	traceExecutionOps = traceEnter | traceLeave | traceEnterStep | traceLeaveStep
+)
+
+// traceOpNames lists the names of the trace operations in the order
+// in which Tcl reports them.
+var traceOpNames = []struct {
+	op   TraceOp
+	name string
+}{
+	{TraceArray, "array"},
+	{TraceRead, "read"},
+	{TraceWrite, "write"},
+	{TraceUnset, "unset"},
+	{traceRename, "rename"},
+	{traceDelete, "delete"},
+	{traceEnter, "enter"},
+	{traceLeave, "leave"},
+	{traceEnterStep, "enterstep"},
+	{traceLeaveStep, "leavestep"},
+}
+
+// String returns the names of the operations in op, separated by
+// spaces.
+func (op TraceOp) String() string {
+	var names []string
+	for _, n := range traceOpNames {
+		if op&n.op != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// A VarTraceFunc is called when a traced variable is accessed. name1
+// is the name the variable was accessed by and name2 is the index of
+// the element being accessed, or empty for a scalar variable or an
+// operation on a whole array. An error returned from a read or write
+// trace is reported as the error of the access; errors from unset
+// traces are ignored.
+type VarTraceFunc func(interp *Interp, name1, name2 string, op TraceOp) error
+
+// A trace is a variable, command or execution trace. Traces added by
+// the trace command evaluate command with extra arguments appended;
+// those added from Go call fn.
+type trace struct {
+	ops     TraceOp
+	command string
+	fn      VarTraceFunc
+}
+
+// TraceVar arranges for fn to be called whenever one of the
+// operations in ops is performed on the variable name, which may
+// name an array, an array element or a scalar, and need not exist
+// yet. The returned function removes the trace.
+func (interp *Interp) TraceVar(name string, ops TraceOp, fn VarTraceFunc) (func(), error) {
+	t := &trace{ops: ops, fn: fn}
+	if err := interp.addVarTrace(name, t); err != nil {
+		return nil, err
+	}
+	return func() {
+		interp.removeVarTrace(name, t)
+	}, nil
+}
+
+// traceTarget returns the variable that a trace on name applies to,
+// creating it, undefined, if create is set.
+func (interp *Interp) traceTarget(name string, create bool) (*variable, error) {
+	n, index, isElem := splitVarName(name)
+	vr := interp.lookupVar(n)
+	if vr == nil {
+		if !create {
+			return nil, nil
+		}
+		vr = interp.createVar(n)
+	}
+	if !isElem {
+		return vr, nil
+	}
+	if vr.value != nil {
+		return nil, fmt.Errorf("can't trace \"%s\": variable isn't array", name)
+	}
+	if vr.array == nil {
+		if !create {
+			return nil, nil
+		}
+		vr.array = map[string]*variable{}
+	}
+	elem := vr.array[index]
+	if elem == nil && create {
+		elem = &variable{}
+		vr.array[index] = elem
+	}
+	return elem, nil
+}
+
+func (interp *Interp) addVarTrace(name string, t *trace) error {
+	vr, err := interp.traceTarget(name, true)
+	if err != nil {
+		return err
+	}
+	vr.traces = append([]*trace{t}, vr.traces...)
+	return nil
+}
+
+// removeVarTrace removes t from the variable name, discarding the
+// variable if it is undefined and has no traces left.
+func (interp *Interp) removeVarTrace(name string, t *trace) {
+	vr, _ := interp.traceTarget(name, false)
+	if vr == nil {
+		return
+	}
+	vr.traces = removeTrace(vr.traces, t)
+	if !vr.undefined() || len(vr.traces) > 0 {
+		return
+	}
+	n, index, isElem := splitVarName(name)
+	if isElem {
+		if array := interp.lookupVar(n); array != nil {
+			delete(array.array, index)
+		}
+		return
+	}
+	interp.deleteVar(n, vr)
+}
+
+// callVarTraces invokes the traces for op on the variable vr, or if
+// isElem is set, on the array vr and its element elem. Traces on a
+// variable are not invoked while its traces are already running.
+// Unset traces are all invoked regardless of errors.
+func (interp *Interp) callVarTraces(vr, elem *variable, isElem bool, name1, name2 string, op TraceOp) error {
+	var traces []*trace
+	for _, v := range []*variable{vr, elem} {
+		if v == nil || v.tracing {
+			continue
+		}
+		traces = append(traces, v.traces...)
+		v.tracing = true
+		defer func(v *variable) { v.tracing = false }(v)
+	}
+	var firstErr error
+	for _, t := range traces {
+		if t.ops&op == 0 {
+			continue
+		}
+		var err error
+		if t.fn != nil {
+			err = t.fn(interp, name1, name2, op)
+		} else {
+			_, err = interp.evalScript([]rune(t.command + " " + formatList([]string{name1, name2, op.String()})))
+		}
+		if err != nil && op != TraceUnset {
+			return err
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// traceArray invokes the array traces on the variable name before an
+// array command operates on it.
+func (interp *Interp) traceArray(name string) error {
+	vr := interp.lookupVar(name)
+	if vr == nil || len(vr.traces) == 0 {
+		return nil
+	}
+	if err := interp.callVarTraces(vr, nil, false, name, "", TraceArray); err != nil {
+		return fmt.Errorf("can't trace array \"%s\": %s", name, err)
+	}
+	return nil
+}
+
+// callCommandTraces invokes the rename or delete traces on cmd.
+// newName is empty for delete traces. Errors are ignored.
+func (interp *Interp) callCommandTraces(cmd *command, oldName, newName string, op TraceOp) {
+	if interp.deleted {
+		return
+	}
+	if newName != "" {
+		newName = "::" + newName
+	}
+	traces := append([]*trace(nil), cmd.traces...)
+	for _, t := range traces {
+		if t.ops&op != 0 {
+			_ = interp.runTraceScript(t.command, "::"+oldName, newName, op.String())
+		}
+	}
+}
+
+// runTraceScript evaluates the execution or command trace command
+// with args appended. Execution traces are disabled while it runs.
+func (interp *Interp) runTraceScript(command string, args ...string) error {
+	interp.execTracing++
+	defer func() { interp.execTracing-- }()
+	_, err := interp.evalScript([]rune(command + " " + formatList(args)))
+	return err
+}
+
+// traceResult returns the completion code and result of a command
+// for leave and leavestep traces.
+func traceResult(v *Value, err error) (string, string) {
+	code := errorCode(err)
+	switch e := err.(type) {
+	case nil:
+		return "0", v.String()
+	case *codeError:
+		return fmt.Sprint(int(code)), e.value.String()
+	}
+	return fmt.Sprint(int(code)), err.Error()
+}
+
+// callTraced invokes cmd with the enter and leave traces on it and
+// the step traces of any traced commands that are executing.
+func (interp *Interp) callTraced(cmd *command, args []*Value) (*Value, error) {
+	command := formatList(valuesToStrings(args))
+	steps := append([]*trace(nil), interp.stepTraces...)
+	for _, t := range steps {
+		if t.ops&traceEnterStep != 0 {
+			if err := interp.runTraceScript(t.command, command, "enterstep"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Enter traces are invoked most recently created first and
+	// leave traces in the order they were created.
+	traces := append([]*trace(nil), cmd.traces...)
+	for _, t := range traces {
+		if t.ops&traceEnter != 0 {
+			if err := interp.runTraceScript(t.command, command, "enter"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	depth := len(interp.stepTraces)
+	for _, t := range traces {
+		if t.ops&(traceEnterStep|traceLeaveStep) != 0 {
+			interp.stepTraces = append(interp.stepTraces, t)
+		}
+	}
+	v, err := interp.callCommand(cmd, args)
+	interp.stepTraces = interp.stepTraces[:depth]
+	for i := len(traces) - 1; i >= 0; i-- {
+		if t := traces[i]; t.ops&traceLeave != 0 {
+			code, result := traceResult(v, err)
+			if terr := interp.runTraceScript(t.command, command, code, result, "leave"); terr != nil {
+				v, err = nil, terr
+			}
+		}
+	}
+	for i := len(steps) - 1; i >= 0; i-- {
+		if t := steps[i]; t.ops&traceLeaveStep != 0 {
+			code, result := traceResult(v, err)
+			if terr := interp.runTraceScript(t.command, command, code, result, "leavestep"); terr != nil {
+				v, err = nil, terr
+			}
+		}
+	}
+	return v, err
+}
@@ -0,0 +1,83 @@
+package gotcl
+
+// A Value is a Tcl value. Every value has a string representation
+// and may also cache an internal representation (a parsed list, an
+// integer, ...) that was derived from, and can regenerate, that
+// string. Values are immutable once they have been handed to a
+// command or stored in a variable.
+type Value struct {
+	str   string
+	valid bool
+	runes []rune
+	rep   internalRep
+}
+
+// An internalRep is a cached internal representation of a Value.
+type internalRep interface {
+	// String regenerates the string representation of the value.
+	String() string
+}
+
+// NewStringValue returns a Value whose string representation is s.
+func NewStringValue(s string) *Value {
+	return &Value{str: s, valid: true}
+}
+
+func newRunesValue(r []rune) *Value {
+	return &Value{str: string(r), valid: true, runes: r}
+}
+
+func newRepValue(rep internalRep) *Value {
+	return &Value{rep: rep}
+}
+
+func emptyValue() *Value {
+	return NewStringValue("")
+}
+
+// String returns the string representation of v.
+func (v *Value) String() string {
+	if v == nil {
+		return ""
+	}
+	if !v.valid {
+		v.str = v.rep.String()
+		v.valid = true
+	}
+	return v.str
+}
+
+// Runes returns the string representation of v as a rune slice.
+// The returned slice must not be modified.
+func (v *Value) Runes() []rune {
+	if v == nil {
+		return nil
+	}
+	if v.runes == nil {
+		v.runes = []rune(v.String())
+	}
+	return v.runes
+}
+
+// setRep caches rep as the internal representation of v,
+// discarding any previous one.
+func (v *Value) setRep(rep internalRep) {
+	_ = v.String()
+	v.rep = rep
+}
+
+func valuesToStrings(vs []*Value) []string {
+	ss := make([]string, len(vs))
+	for i, v := range vs {
+		ss[i] = v.String()
+	}
+	return ss
+}
+
+func stringsToValues(ss []string) []*Value {
+	vs := make([]*Value, len(ss))
+	for i, s := range ss {
+		vs[i] = NewStringValue(s)
+	}
+	return vs
+}
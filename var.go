@@ -0,0 +1,296 @@
+package gotcl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A variable holds the value of a Tcl variable. An array variable
+// has a nil value and holds its elements, which are variables
+// themselves, in array. A variable with neither a value nor an array
+// is undefined; it exists only to hold traces.
+type variable struct {
+	value *Value
+	array map[string]*variable
+
+	// traces holds the traces on the variable, most recently added
+	// first. tracing is set while they are being invoked.
+	traces  []*trace
+	tracing bool
+
+	// searches holds the active array searches of an array,
+	// keyed by search identifier.
+	searches   map[string]*arraySearch
+	nextSearch int
+
+	// linked is set once the variable has been linked into another
+	// frame by upvar or global. A linked variable is never removed
+	// from its table, only made undefined, so the links stay valid.
+	linked bool
+}
+
+// splitVarName splits a variable name of the form "name(index)" into
+// the name of an array and the index of one of its elements.
+func splitVarName(name string) (string, string, bool) {
+	if !strings.HasSuffix(name, ")") {
+		return name, "", false
+	}
+	i := strings.IndexByte(name, '(')
+	if i < 0 {
+		return name, "", false
+	}
+	return name[:i], name[i+1 : len(name)-1], true
+}
+
+func varDisplayName(name, index string, isElem bool) string {
+	if isElem {
+		return name + "(" + index + ")"
+	}
+	return name
+}
+
+// GetVar returns the value of the variable called name, or of the
+// element index of the array called name if index is not empty.
+func (interp *Interp) GetVar(name, index string) (string, error) {
+	v, err := interp.getVar2(name, index, index != "")
+	if err != nil {
+		return "", err
+	}
+	return v.String(), nil
+}
+
+// SetVar sets the variable called name, or the element index of the
+// array called name if index is not empty, to value.
+func (interp *Interp) SetVar(name, index, value string) error {
+	_, err := interp.setVar2(name, index, index != "", NewStringValue(value))
+	return err
+}
+
+// UnsetVar unsets the variable called name, or the element index of
+// the array called name if index is not empty.
+func (interp *Interp) UnsetVar(name, index string) error {
+	return interp.unsetVar2(name, index, index != "")
+}
+
+// getVar returns the value of the variable whose full name, possibly
+// including an array index, is name.
+func (interp *Interp) getVar(name string) (*Value, error) {
+	n, index, isElem := splitVarName(name)
+	return interp.getVar2(n, index, isElem)
+}
+
+// setVar sets the variable whose full name, possibly including an
+// array index, is name.
+func (interp *Interp) setVar(name string, v *Value) (*Value, error) {
+	n, index, isElem := splitVarName(name)
+	return interp.setVar2(n, index, isElem, v)
+}
+
+func (interp *Interp) unsetVar(name string) error {
+	n, index, isElem := splitVarName(name)
+	return interp.unsetVar2(n, index, isElem)
+}
+
+// undefined reports whether vr has neither a value nor elements.
+func (vr *variable) undefined() bool {
+	return vr.value == nil && vr.array == nil
+}
+
+// varTable returns the table holding the variable called name and
+// its key in that table. Qualified names refer to global variables,
+// and other names to variables of the current frame.
+func (interp *Interp) varTable(name string) (map[string]*variable, string) {
+	if interp.frame == interp.globalFrame || strings.HasPrefix(name, "::") {
+		return interp.globals, commandName(name)
+	}
+	return interp.frame.vars, name
+}
+
+// lookupVar returns the variable called name, or nil if there is
+// none.
+func (interp *Interp) lookupVar(name string) *variable {
+	if interp == nil {
+		return nil
+	}
+	vars, key := interp.varTable(name)
+	return vars[key]
+}
+
+// createVar returns the variable called name, creating it, undefined,
+// if there is none.
+func (interp *Interp) createVar(name string) *variable {
+	vars, key := interp.varTable(name)
+	vr := vars[key]
+	if vr == nil {
+		vr = &variable{}
+		vars[key] = vr
+	}
+	return vr
+}
+
+// deleteVar removes the variable called name, which is vr. A linked
+// variable is made undefined instead.
+func (interp *Interp) deleteVar(name string, vr *variable) {
+	if vr.linked {
+		vr.value, vr.array, vr.traces, vr.searches = nil, nil, nil, nil
+		return
+	}
+	vars, key := interp.varTable(name)
+	delete(vars, key)
+}
+
+// lookupVar2 returns the variable called name and, if isElem is set
+// and it is an array, its element index. Either may be nil.
+func (interp *Interp) lookupVar2(name, index string, isElem bool) (*variable, *variable) {
+	vr := interp.lookupVar(name)
+	if vr == nil || !isElem || vr.array == nil {
+		return vr, nil
+	}
+	return vr, vr.array[index]
+}
+
+func (interp *Interp) getVar2(name, index string, isElem bool) (*Value, error) {
+	display := varDisplayName(name, index, isElem)
+	vr, elem := interp.lookupVar2(name, index, isElem)
+	if vr != nil && (len(vr.traces) > 0 || (elem != nil && len(elem.traces) > 0)) {
+		if err := interp.callVarTraces(vr, elem, isElem, name, index, TraceRead); err != nil {
+			return nil, fmt.Errorf("can't read \"%s\": %s", display, err)
+		}
+		// The traces may have changed the variable.
+		vr, elem = interp.lookupVar2(name, index, isElem)
+	}
+	switch {
+	case vr == nil:
+		return nil, fmt.Errorf("can't read \"%s\": no such variable", display)
+	case isElem && vr.array == nil && vr.value != nil:
+		return nil, fmt.Errorf("can't read \"%s\": variable isn't array", display)
+	case isElem && vr.array == nil:
+		return nil, fmt.Errorf("can't read \"%s\": no such variable", display)
+	case isElem && (elem == nil || elem.value == nil):
+		return nil, fmt.Errorf("can't read \"%s\": no such element in array", display)
+	case isElem:
+		return elem.value, nil
+	case vr.array != nil:
+		return nil, fmt.Errorf("can't read \"%s\": variable is array", display)
+	case vr.value == nil:
+		return nil, fmt.Errorf("can't read \"%s\": no such variable", display)
+	}
+	return vr.value, nil
+}
+
+func (interp *Interp) setVar2(name, index string, isElem bool, v *Value) (*Value, error) {
+	display := varDisplayName(name, index, isElem)
+	vr := interp.createVar(name)
+	var elem *variable
+	switch {
+	case isElem && vr.value != nil:
+		return nil, fmt.Errorf("can't set \"%s\": variable isn't array", display)
+	case isElem:
+		if vr.array == nil {
+			vr.array = map[string]*variable{}
+		}
+		if elem = vr.array[index]; elem == nil {
+			elem = &variable{}
+			vr.array[index] = elem
+		}
+		elem.value = v
+	case vr.array != nil:
+		return nil, fmt.Errorf("can't set \"%s\": variable is array", display)
+	default:
+		vr.value = v
+	}
+	if len(vr.traces) > 0 || (elem != nil && len(elem.traces) > 0) {
+		if err := interp.callVarTraces(vr, elem, isElem, name, index, TraceWrite); err != nil {
+			return nil, fmt.Errorf("can't set \"%s\": %s", display, err)
+		}
+		// Return the value as left by the traces.
+		if vr, elem = interp.lookupVar2(name, index, isElem); isElem && elem != nil && elem.value != nil {
+			return elem.value, nil
+		} else if !isElem && vr != nil && vr.value != nil {
+			return vr.value, nil
+		}
+	}
+	return v, nil
+}
+
+func (interp *Interp) unsetVar2(name, index string, isElem bool) error {
+	display := varDisplayName(name, index, isElem)
+	vr, elem := interp.lookupVar2(name, index, isElem)
+	switch {
+	case vr == nil:
+		return fmt.Errorf("can't unset \"%s\": no such variable", display)
+	case isElem && vr.array == nil && vr.value != nil:
+		return fmt.Errorf("can't unset \"%s\": variable isn't array", display)
+	case isElem && vr.array == nil:
+		return fmt.Errorf("can't unset \"%s\": no such variable", display)
+	case isElem && (elem == nil || elem.value == nil):
+		return fmt.Errorf("can't unset \"%s\": no such element in array", display)
+	case !isElem && vr.undefined():
+		return fmt.Errorf("can't unset \"%s\": no such variable", display)
+	}
+
+	// Unset traces are invoked after the variable has been removed,
+	// and errors from them are ignored.
+	if isElem {
+		delete(vr.array, index)
+		if len(vr.traces) > 0 || len(elem.traces) > 0 {
+			_ = interp.callVarTraces(vr, elem, true, name, index, TraceUnset)
+		}
+		return nil
+	}
+	unlinked := *vr
+	interp.deleteVar(name, vr)
+	vr = &unlinked
+	if len(vr.traces) > 0 {
+		_ = interp.callVarTraces(vr, nil, false, name, "", TraceUnset)
+	}
+	for _, elemName := range vr.sortedNames() {
+		if elem := vr.array[elemName]; len(elem.traces) > 0 {
+			_ = interp.callVarTraces(nil, elem, true, name, elemName, TraceUnset)
+		}
+	}
+	return nil
+}
+
+// frameVar returns the variable called name, possibly an array
+// element, in frame, creating it undefined if there is none.
+func (interp *Interp) frameVar(frame *callFrame, name string) (*variable, error) {
+	saved := interp.frame
+	interp.frame = frame
+	defer func() { interp.frame = saved }()
+	n, index, isElem := splitVarName(name)
+	vr := interp.createVar(n)
+	if !isElem {
+		return vr, nil
+	}
+	if vr.value != nil {
+		return nil, fmt.Errorf("can't upvar \"%s\": variable isn't array", name)
+	}
+	if vr.array == nil {
+		vr.array = map[string]*variable{}
+	}
+	elem := vr.array[index]
+	if elem == nil {
+		elem = &variable{}
+		vr.array[index] = elem
+	}
+	return elem, nil
+}
+
+// linkVar makes the variable called name in the current frame refer
+// to target.
+func (interp *Interp) linkVar(name string, target *variable) error {
+	vars, key := interp.varTable(name)
+	switch vr := vars[key]; {
+	case vr == target:
+		return nil
+	case vr != nil && (!vr.undefined() || len(vr.traces) > 0):
+		return fmt.Errorf("variable \"%s\" already exists", name)
+	}
+	target.linked = true
+	vars[key] = target
+	if interp.frame != interp.globalFrame && key == name {
+		interp.frame.link(name)
+	}
+	return nil
+}
@@ -0,0 +1,594 @@
+package gotcl
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A VFS is a filesystem seen by scripts through the file, glob, open,
+// cd and pwd commands, and by source when no fs.FS is set. Relative
+// names are resolved against the working directory of the VFS. Errors
+// should wrap the syscall.Errno values of the host, so that scripts
+// see the usual POSIX messages.
+type VFS interface {
+	// OpenFile opens the file name with the os.O_* flags, creating
+	// it with perm if flag includes os.O_CREATE.
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+	// Stat describes the file name, following symbolic links, and
+	// Lstat describes it without following a link at its end.
+	Stat(name string) (fs.FileInfo, error)
+	Lstat(name string) (fs.FileInfo, error)
+	// ReadDir returns the entries of the directory name, sorted by
+	// name.
+	ReadDir(name string) ([]fs.DirEntry, error)
+	Mkdir(name string, perm fs.FileMode) error
+	// Remove removes the file or empty directory name.
+	Remove(name string) error
+	Rename(oldname, newname string) error
+	Chmod(name string, mode fs.FileMode) error
+	Chown(name string, uid, gid int) error
+	Chtimes(name string, atime, mtime time.Time) error
+	// Access checks whether the file name may be read, written or
+	// executed, as for the access system call: mode is a
+	// combination of 4, 2 and 1 respectively.
+	Access(name string, mode uint32) error
+	Readlink(name string) (string, error)
+	Symlink(oldname, newname string) error
+	Link(oldname, newname string) error
+	Getwd() (string, error)
+	Chdir(dir string) error
+}
+
+// A File is an open file of a VFS.
+type File interface {
+	io.ReadWriteSeeker
+	io.Closer
+}
+
+// SetVFS makes the file, glob, open, cd and pwd commands, and source
+// when no fs.FS has been set with SetFS, use vfs rather than the host
+// filesystem. A nil vfs restores the host filesystem. These commands
+// are hidden in safe interpreters. A host that gives a safe
+// interpreter an in-memory filesystem may expose file, glob, cd and
+// pwd again with ExposeCommand, but exposing open also exposes its
+// other uses: it opens no command pipelines in a safe interpreter,
+// yet its files are only as contained as vfs itself.
+func (interp *Interp) SetVFS(vfs VFS) {
+	interp.vfs = vfs
+}
+
+// filesystem returns the VFS of interp, including its mounts.
+func (interp *Interp) filesystem() VFS {
+	base := interp.vfs
+	if base == nil {
+		base = hostFS{}
+	}
+	if len(interp.mounts.mounts) == 0 {
+		return base
+	}
+	return &mountFS{base: base, t: &interp.mounts}
+}
+
+// HostFS returns the VFS of the host filesystem, which interpreters
+// use unless given another with SetVFS.
+func HostFS() VFS {
+	return hostFS{}
+}
+
+type hostFS struct{}
+
+func (hostFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (hostFS) Stat(name string) (fs.FileInfo, error)      { return os.Stat(name) }
+func (hostFS) Lstat(name string) (fs.FileInfo, error)     { return os.Lstat(name) }
+func (hostFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+func (hostFS) Mkdir(name string, perm fs.FileMode) error  { return os.Mkdir(name, perm) }
+func (hostFS) Remove(name string) error                   { return os.Remove(name) }
+func (hostFS) Rename(oldname, newname string) error       { return os.Rename(oldname, newname) }
+func (hostFS) Chmod(name string, mode fs.FileMode) error  { return os.Chmod(name, mode) }
+func (hostFS) Chown(name string, uid, gid int) error      { return os.Chown(name, uid, gid) }
+func (hostFS) Readlink(name string) (string, error)       { return os.Readlink(name) }
+func (hostFS) Symlink(oldname, newname string) error      { return os.Symlink(oldname, newname) }
+func (hostFS) Link(oldname, newname string) error         { return os.Link(oldname, newname) }
+func (hostFS) Getwd() (string, error)                     { return os.Getwd() }
+func (hostFS) Chdir(dir string) error                     { return os.Chdir(dir) }
+
+func (hostFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+// A MemFS is an in-memory VFS, for tests and for interpreters that
+// must not see the host filesystem. Its files are owned by user and
+// group 0 and the owner permissions of a file are enforced when it is
+// opened. A MemFS is safe for concurrent use.
+type MemFS struct {
+	mu   sync.Mutex
+	root *memNode
+	wd   string
+}
+
+// A memNode is a file, directory or symbolic link in a MemFS. A file
+// may have several names, created with Link.
+type memNode struct {
+	mode     fs.FileMode
+	data     []byte
+	children map[string]*memNode
+	target   string
+	uid, gid int
+	nlink    int
+	mtime    time.Time
+	atime    time.Time
+}
+
+// NewMemFS returns an empty MemFS, holding only its root directory,
+// which is the working directory.
+func NewMemFS() *MemFS {
+	return &MemFS{root: newMemNode(fs.ModeDir | 0o755), wd: "/"}
+}
+
+func newMemNode(mode fs.FileMode) *memNode {
+	now := time.Now()
+	n := &memNode{mode: mode, nlink: 1, mtime: now, atime: now}
+	if mode.IsDir() {
+		n.children = map[string]*memNode{}
+	}
+	return n
+}
+
+func memError(op, name string, err error) error {
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// lookup resolves name to the directory holding it and the node
+// called base there, which is nil if there is none. Symbolic links
+// are followed in the directories leading to name, and at its end if
+// follow is set.
+func (m *MemFS) lookup(op, name string, follow bool) (dir *memNode, base string, n *memNode, err error) {
+	p := name
+	if !path.IsAbs(p) {
+		p = path.Join(m.wd, p)
+	}
+	p = path.Clean(p)
+	for hops := 0; ; {
+		if p == "/" {
+			return m.root, "/", m.root, nil
+		}
+		comps := strings.Split(p[1:], "/")
+		dir = m.root
+		for i, c := range comps {
+			child := dir.children[c]
+			last := i == len(comps)-1
+			if child == nil {
+				if !last {
+					return nil, "", nil, memError(op, name, syscall.ENOENT)
+				}
+				return dir, c, nil, nil
+			}
+			if child.mode&fs.ModeSymlink != 0 && (!last || follow) {
+				if hops++; hops > 40 {
+					return nil, "", nil, memError(op, name, syscall.ELOOP)
+				}
+				target := child.target
+				if !path.IsAbs(target) {
+					target = path.Join("/", strings.Join(comps[:i], "/"), target)
+				}
+				p = path.Join(append([]string{target}, comps[i+1:]...)...)
+				break
+			}
+			if last {
+				return dir, c, child, nil
+			}
+			if !child.mode.IsDir() {
+				return nil, "", nil, memError(op, name, syscall.ENOTDIR)
+			}
+			dir = child
+		}
+	}
+}
+
+// find returns the existing node name.
+func (m *MemFS) find(op, name string, follow bool) (*memNode, error) {
+	_, _, n, err := m.lookup(op, name, follow)
+	if err == nil && n == nil {
+		err = memError(op, name, syscall.ENOENT)
+	}
+	return n, err
+}
+
+// create adds a node with mode called name, which must not exist.
+func (m *MemFS) create(op, name string, mode fs.FileMode) (*memNode, error) {
+	dir, base, n, err := m.lookup(op, name, false)
+	switch {
+	case err != nil:
+		return nil, err
+	case n != nil:
+		return nil, memError(op, name, syscall.EEXIST)
+	}
+	n = newMemNode(mode)
+	dir.children[base] = n
+	dir.mtime = n.mtime
+	return n, nil
+}
+
+func (m *MemFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir, base, n, err := m.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	access := flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR)
+	switch {
+	case n == nil && flag&os.O_CREATE == 0:
+		return nil, memError("open", name, syscall.ENOENT)
+	case n == nil:
+		if dir.mode&0o200 == 0 {
+			return nil, memError("open", name, syscall.EACCES)
+		}
+		n = newMemNode(perm & fs.ModePerm)
+		dir.children[base] = n
+		dir.mtime = n.mtime
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, memError("open", name, syscall.EEXIST)
+	case n.mode.IsDir():
+		return nil, memError("open", name, syscall.EISDIR)
+	case access != os.O_WRONLY && n.mode&0o400 == 0, access != os.O_RDONLY && n.mode&0o200 == 0:
+		return nil, memError("open", name, syscall.EACCES)
+	}
+	if flag&os.O_TRUNC != 0 && access != os.O_RDONLY {
+		n.data = nil
+		n.mtime = time.Now()
+	}
+	return &memFile{fs: m, n: n, flag: flag}, nil
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	return m.stat("stat", name, true)
+}
+
+func (m *MemFS) Lstat(name string) (fs.FileInfo, error) {
+	return m.stat("lstat", name, false)
+}
+
+func (m *MemFS) stat(op, name string, follow bool) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, base, n, err := m.lookup(op, name, follow)
+	if err == nil && n == nil {
+		err = memError(op, name, syscall.ENOENT)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return n.info(base), nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.find("readdirent", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !n.mode.IsDir() {
+		return nil, memError("readdirent", name, syscall.ENOTDIR)
+	}
+	entries := make([]fs.DirEntry, 0, len(n.children))
+	for base, child := range n.children {
+		entries = append(entries, fs.FileInfoToDirEntry(child.info(base)))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m *MemFS) Mkdir(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := m.create("mkdir", name, fs.ModeDir|perm&fs.ModePerm)
+	return err
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir, base, n, err := m.lookup("remove", name, false)
+	switch {
+	case err != nil:
+		return err
+	case n == nil:
+		return memError("remove", name, syscall.ENOENT)
+	case n == m.root:
+		return memError("remove", name, syscall.EBUSY)
+	case len(n.children) > 0:
+		return memError("remove", name, syscall.ENOTEMPTY)
+	}
+	delete(dir.children, base)
+	n.nlink--
+	dir.mtime = time.Now()
+	return nil
+}
+
+func (m *MemFS) Rename(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	odir, obase, n, err := m.lookup("rename", oldname, false)
+	if err == nil && n == nil {
+		err = syscall.ENOENT
+	}
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	ndir, nbase, target, err := m.lookup("rename", newname, false)
+	switch {
+	case err != nil:
+	case n == m.root || m.contains(n, ndir):
+		err = syscall.EINVAL
+	case target == nil || target == n:
+	case n.mode.IsDir() && !target.mode.IsDir():
+		err = syscall.ENOTDIR
+	case !n.mode.IsDir() && target.mode.IsDir():
+		err = syscall.EISDIR
+	case len(target.children) > 0:
+		err = syscall.ENOTEMPTY
+	}
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	delete(odir.children, obase)
+	ndir.children[nbase] = n
+	odir.mtime, ndir.mtime = time.Now(), time.Now()
+	return nil
+}
+
+// contains reports whether the directory dir is n or is inside n.
+func (m *MemFS) contains(n, dir *memNode) bool {
+	if n == dir {
+		return true
+	}
+	for _, child := range n.children {
+		if child.mode.IsDir() && m.contains(child, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemFS) Chmod(name string, mode fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.find("chmod", name, true)
+	if err != nil {
+		return err
+	}
+	n.mode = n.mode&^(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky) |
+		mode&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)
+	return nil
+}
+
+func (m *MemFS) Chown(name string, uid, gid int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.find("chown", name, true)
+	if err != nil {
+		return err
+	}
+	if uid >= 0 {
+		n.uid = uid
+	}
+	if gid >= 0 {
+		n.gid = gid
+	}
+	return nil
+}
+
+func (m *MemFS) Chtimes(name string, atime, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.find("chtimes", name, true)
+	if err != nil {
+		return err
+	}
+	n.atime, n.mtime = atime, mtime
+	return nil
+}
+
+func (m *MemFS) Access(name string, mode uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.find("access", name, true)
+	if err != nil {
+		return err
+	}
+	if fs.FileMode(mode<<6)&^n.mode&fs.ModePerm != 0 {
+		return memError("access", name, syscall.EACCES)
+	}
+	return nil
+}
+
+func (m *MemFS) Readlink(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.find("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if n.mode&fs.ModeSymlink == 0 {
+		return "", memError("readlink", name, syscall.EINVAL)
+	}
+	return n.target, nil
+}
+
+func (m *MemFS) Symlink(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.create("symlink", newname, fs.ModeSymlink|fs.ModePerm)
+	if err != nil {
+		return err
+	}
+	n.target = oldname
+	return nil
+}
+
+func (m *MemFS) Link(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.find("link", oldname, false)
+	if err != nil {
+		return err
+	}
+	if n.mode.IsDir() {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: syscall.EPERM}
+	}
+	dir, base, existing, err := m.lookup("link", newname, false)
+	switch {
+	case err != nil:
+		return err
+	case existing != nil:
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: syscall.EEXIST}
+	}
+	dir.children[base] = n
+	n.nlink++
+	return nil
+}
+
+func (m *MemFS) Getwd() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.wd, nil
+}
+
+func (m *MemFS) Chdir(dir string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.find("chdir", dir, true)
+	if err != nil {
+		return err
+	}
+	if !n.mode.IsDir() {
+		return memError("chdir", dir, syscall.ENOTDIR)
+	}
+	if !path.IsAbs(dir) {
+		dir = path.Join(m.wd, dir)
+	}
+	m.wd = path.Clean(dir)
+	return nil
+}
+
+// info describes n, called name.
+func (n *memNode) info(name string) *memInfo {
+	size := int64(len(n.data))
+	if n.mode&fs.ModeSymlink != 0 {
+		size = int64(len(n.target))
+	}
+	return &memInfo{name: name, size: size, mode: n.mode, stat: statFields{
+		nlink: uint64(n.nlink),
+		uid:   uint64(n.uid),
+		gid:   uint64(n.gid),
+		mtime: n.mtime,
+		atime: n.atime,
+		ctime: n.mtime,
+	}}
+}
+
+// A memInfo describes a node of a MemFS. Its Sys method returns the
+// *statFields of the node.
+type memInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+	stat statFields
+}
+
+func (fi *memInfo) Name() string       { return fi.name }
+func (fi *memInfo) Size() int64        { return fi.size }
+func (fi *memInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *memInfo) ModTime() time.Time { return fi.stat.mtime }
+func (fi *memInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *memInfo) Sys() any           { return &fi.stat }
+
+// A memFile is an open file of a MemFS.
+type memFile struct {
+	fs     *MemFS
+	n      *memNode
+	flag   int
+	off    int64
+	closed bool
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	switch {
+	case f.closed:
+		return 0, os.ErrClosed
+	case f.flag&(os.O_RDONLY|os.O_WRONLY|os.O_RDWR) == os.O_WRONLY:
+		return 0, syscall.EBADF
+	case f.off >= int64(len(f.n.data)):
+		return 0, io.EOF
+	}
+	n := copy(p, f.n.data[f.off:])
+	f.off += int64(n)
+	f.n.atime = time.Now()
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	switch {
+	case f.closed:
+		return 0, os.ErrClosed
+	case f.flag&(os.O_RDONLY|os.O_WRONLY|os.O_RDWR) == os.O_RDONLY:
+		return 0, syscall.EBADF
+	}
+	if f.flag&os.O_APPEND != 0 {
+		f.off = int64(len(f.n.data))
+	}
+	if end := f.off + int64(len(p)); end > int64(len(f.n.data)) {
+		data := make([]byte, end)
+		copy(data, f.n.data)
+		f.n.data = data
+	}
+	copy(f.n.data[f.off:], p)
+	f.off += int64(len(p))
+	f.n.mtime = time.Now()
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += int64(len(f.n.data))
+	}
+	if offset < 0 {
+		return 0, syscall.EINVAL
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *memFile) Close() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	f.closed = true
+	return nil
+}
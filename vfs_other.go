@@ -0,0 +1,22 @@
+//go:build !unix
+
+package gotcl
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// Access checks the owner permissions that os.Stat reports, for
+// systems without the access system call.
+func (hostFS) Access(name string, mode uint32) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	if fs.FileMode(mode<<6)&^info.Mode()&fs.ModePerm != 0 {
+		return &fs.PathError{Op: "access", Path: name, Err: syscall.EACCES}
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package gotcl
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func (hostFS) Access(name string, mode uint32) error {
+	if err := syscall.Access(name, mode); err != nil {
+		return &fs.PathError{Op: "access", Path: name, Err: err}
+	}
+	return nil
+}